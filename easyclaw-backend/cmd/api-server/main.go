@@ -13,6 +13,21 @@ import (
 	_ "github.com/joho/godotenv/autoload"
 )
 
+// watchLogReopen reopens the logger's underlying file on every SIGHUP, so
+// external logrotate tooling can rotate the file out from under us and
+// have us pick up the new one without a restart.
+func watchLogReopen(logger *slog.Logger, reopenLogger func() error) {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	go func() {
+		for range sighup {
+			if err := reopenLogger(); err != nil {
+				logger.Error("failed to reopen log file", "err", err)
+			}
+		}
+	}()
+}
+
 func main() {
 	bootstrapLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
 
@@ -22,7 +37,7 @@ func main() {
 		os.Exit(1)
 	}
 
-	logger, closeLogger, err := logging.New("api-server", cfg.Log)
+	logger, closeLogger, reopenLogger, err := logging.New("api-server", cfg.Log)
 	if err != nil {
 		bootstrapLogger.Error("failed to initialize logger", "err", err)
 		os.Exit(1)
@@ -32,6 +47,7 @@ func main() {
 			bootstrapLogger.Error("failed to close logger", "err", closeErr)
 		}
 	}()
+	watchLogReopen(logger, reopenLogger)
 
 	if source, sourceErr := config.CurrentConfigSource(); sourceErr == nil {
 		logger.Info("configuration loaded", "phase", source.Phase, "path", source.Path, "loaded", source.Loaded)
@@ -0,0 +1,274 @@
+// Command apigen reads the indexer.QueryService interface declared in
+// internal/indexer/query.go and generates internal/apiserver/gen_handlers.go:
+// one REST handler per interface method, decoding query-string parameters
+// into that method's Filter struct according to its `query:"..."` tags,
+// then wrapping the result in listResponse[T] the same way the hand-written
+// /api/v1/* handlers do. Re-run it (go run ./cmd/apigen) after adding a
+// method to QueryService or a field to one of its Filter structs; the
+// output is marked "DO NOT EDIT" and regenerated wholesale, not patched.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"strings"
+)
+
+func main() {
+	in := flag.String("in", "internal/indexer/query.go", "source file declaring the QueryService interface and its Filter structs")
+	out := flag.String("out", "internal/apiserver/gen_handlers.go", "output file to write the generated handlers to")
+	flag.Parse()
+
+	methods, err := loadQueryService(*in)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "apigen: %v\n", err)
+		os.Exit(1)
+	}
+
+	formatted, err := format.Source([]byte(render(methods)))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "apigen: generated source does not parse: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := os.WriteFile(*out, formatted, 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "apigen: write %s: %v\n", *out, err)
+		os.Exit(1)
+	}
+}
+
+type fieldInfo struct {
+	name string
+	tag  string
+	kind string // "string", "int", "int64", or "uint64ptr"
+}
+
+type methodInfo struct {
+	name       string
+	filterType string
+	recordType string
+	fields     []fieldInfo
+}
+
+// loadQueryService parses path and extracts every method of its
+// QueryService interface, resolving each method's Filter parameter to the
+// query-tagged fields on that Filter struct declared in the same file.
+func loadQueryService(path string) ([]methodInfo, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	structs := map[string]*ast.StructType{}
+	var ifaceMethods []*ast.Field
+	for _, decl := range f.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			ts, ok := spec.(*ast.TypeSpec)
+			if !ok {
+				continue
+			}
+			switch t := ts.Type.(type) {
+			case *ast.StructType:
+				structs[ts.Name.Name] = t
+			case *ast.InterfaceType:
+				if ts.Name.Name == "QueryService" {
+					ifaceMethods = t.Methods.List
+				}
+			}
+		}
+	}
+	if ifaceMethods == nil {
+		return nil, fmt.Errorf("no QueryService interface found in %s", path)
+	}
+
+	var methods []methodInfo
+	for _, m := range ifaceMethods {
+		name := m.Names[0].Name
+		ft, ok := m.Type.(*ast.FuncType)
+		if !ok || len(ft.Params.List) < 2 || len(ft.Results.List) < 1 {
+			return nil, fmt.Errorf("%s: expected (ctx, Filter) (...) signature", name)
+		}
+		filterIdent, ok := ft.Params.List[1].Type.(*ast.Ident)
+		if !ok {
+			return nil, fmt.Errorf("%s: second parameter must be a named Filter type", name)
+		}
+		arr, ok := ft.Results.List[0].Type.(*ast.ArrayType)
+		if !ok {
+			return nil, fmt.Errorf("%s: first result must be a record slice", name)
+		}
+		recIdent, ok := arr.Elt.(*ast.Ident)
+		if !ok {
+			return nil, fmt.Errorf("%s: first result's element type must be a named Record type", name)
+		}
+
+		st, ok := structs[filterIdent.Name]
+		if !ok {
+			return nil, fmt.Errorf("%s: filter type %s not found in %s", name, filterIdent.Name, path)
+		}
+
+		var fields []fieldInfo
+		for _, field := range st.Fields.List {
+			if len(field.Names) == 0 || field.Tag == nil {
+				continue
+			}
+			fname := field.Names[0].Name
+			tag := extractTag(strings.Trim(field.Tag.Value, "`"), "query")
+			if tag == "" {
+				continue
+			}
+			kind, err := fieldKind(field.Type)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %w", filterIdent.Name, fname, err)
+			}
+			fields = append(fields, fieldInfo{name: fname, tag: tag, kind: kind})
+		}
+
+		methods = append(methods, methodInfo{
+			name:       name,
+			filterType: filterIdent.Name,
+			recordType: recIdent.Name,
+			fields:     fields,
+		})
+	}
+
+	return methods, nil
+}
+
+// fieldKind maps a Filter field's Go type to the decode helper apigen
+// knows how to emit a call to. Anything else - nested structs, slices,
+// map fields - is a generation-time error rather than a silently-skipped
+// field, so a Filter struct outgrowing what apigen understands fails loud
+// instead of shipping a handler that can never set that field.
+func fieldKind(expr ast.Expr) (string, error) {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		switch t.Name {
+		case "string":
+			return "string", nil
+		case "int":
+			return "int", nil
+		case "int64":
+			return "int64", nil
+		}
+	case *ast.StarExpr:
+		if ident, ok := t.X.(*ast.Ident); ok && ident.Name == "uint64" {
+			return "uint64ptr", nil
+		}
+	}
+	return "", fmt.Errorf("unsupported query-tagged field type %T", expr)
+}
+
+func extractTag(raw, key string) string {
+	prefix := key + `:"`
+	idx := strings.Index(raw, prefix)
+	if idx == -1 {
+		return ""
+	}
+	rest := raw[idx+len(prefix):]
+	end := strings.Index(rest, `"`)
+	if end == -1 {
+		return ""
+	}
+	return rest[:end]
+}
+
+// restPath derives the generated mount point from a QueryService method
+// name: the "List" prefix is dropped and the remaining CamelCase words are
+// kebab-cased, e.g. ListPositionHistory -> /api/v1/gen/position-history.
+// The /gen/ segment keeps these alongside, not in place of, the
+// hand-written /api/v1/* routes backed by the same Store methods.
+func restPath(methodName string) string {
+	trimmed := strings.TrimPrefix(methodName, "List")
+	var b strings.Builder
+	for i, r := range trimmed {
+		if i > 0 && r >= 'A' && r <= 'Z' {
+			b.WriteByte('-')
+		}
+		b.WriteRune(r)
+	}
+	return "/api/v1/gen/" + strings.ToLower(b.String())
+}
+
+func resourceName(methodName string) string {
+	return strings.ReplaceAll(strings.TrimPrefix(restPath(methodName), "/api/v1/gen/"), "-", " ")
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
+
+func render(methods []methodInfo) string {
+	var buf bytes.Buffer
+	buf.WriteString(`// Code generated by cmd/apigen from indexer.QueryService. DO NOT EDIT.
+//
+// Regenerate with: go run ./cmd/apigen
+
+package apiserver
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/coldbell/dex/backend/internal/indexer"
+)
+
+// registerGenHandlers mounts one REST handler per indexer.QueryService
+// method under /api/v1/gen/, alongside (not replacing) the hand-written
+// /api/v1/* handlers those same Store methods already back.
+func registerGenHandlers(mux *http.ServeMux, s *Service) {
+`)
+	for _, m := range methods {
+		fmt.Fprintf(&buf, "\tmux.HandleFunc(%q, s.handleGen%s)\n", restPath(m.name), m.name)
+	}
+	buf.WriteString("}\n\n")
+
+	for _, m := range methods {
+		fmt.Fprintf(&buf, "func (s *Service) handleGen%s(w http.ResponseWriter, r *http.Request) {\n", m.name)
+		buf.WriteString("\tif r.Method != http.MethodGet {\n\t\ts.respondMethodNotAllowed(w)\n\t\treturn\n\t}\n\n")
+		fmt.Fprintf(&buf, "\tvar filter indexer.%s\n", m.filterType)
+		for _, field := range m.fields {
+			lname := lowerFirst(field.name)
+			switch field.kind {
+			case "string":
+				fmt.Fprintf(&buf, "\tfilter.%s = strings.TrimSpace(r.URL.Query().Get(%q))\n", field.name, field.tag)
+			case "int":
+				fmt.Fprintf(&buf, "\t%sVal, err := parseOptionalInt(r, %q, 0)\n", lname, field.tag)
+				buf.WriteString("\tif err != nil {\n\t\ts.respondError(w, http.StatusBadRequest, err.Error())\n\t\treturn\n\t}\n")
+				fmt.Fprintf(&buf, "\tfilter.%s = %sVal\n", field.name, lname)
+			case "int64":
+				fmt.Fprintf(&buf, "\t%sVal, err := parseOptionalInt64(r, %q, 0)\n", lname, field.tag)
+				buf.WriteString("\tif err != nil {\n\t\ts.respondError(w, http.StatusBadRequest, err.Error())\n\t\treturn\n\t}\n")
+				fmt.Fprintf(&buf, "\tfilter.%s = %sVal\n", field.name, lname)
+			case "uint64ptr":
+				fmt.Fprintf(&buf, "\t%sVal, err := parseOptionalUint64(r, %q)\n", lname, field.tag)
+				buf.WriteString("\tif err != nil {\n\t\ts.respondError(w, http.StatusBadRequest, err.Error())\n\t\treturn\n\t}\n")
+				fmt.Fprintf(&buf, "\tfilter.%s = %sVal\n", field.name, lname)
+			}
+		}
+		buf.WriteString("\n")
+		fmt.Fprintf(&buf, "\titems, limit, offset, nextCursor, err := s.store.%s(r.Context(), filter)\n", m.name)
+		buf.WriteString("\tif err != nil {\n")
+		buf.WriteString("\t\tif isInvalidCursorErr(err) {\n\t\t\ts.respondError(w, http.StatusBadRequest, err.Error())\n\t\t\treturn\n\t\t}\n")
+		fmt.Fprintf(&buf, "\t\ts.logger.Error(%q, \"err\", err)\n", "gen "+m.name+" failed")
+		fmt.Fprintf(&buf, "\t\ts.respondError(w, http.StatusInternalServerError, %q)\n", "failed to list "+resourceName(m.name))
+		buf.WriteString("\t\treturn\n\t}\n\n")
+		fmt.Fprintf(&buf, "\ts.respondJSON(w, http.StatusOK, listResponse[indexer.%s]{\n", m.recordType)
+		buf.WriteString("\t\tItems:      items,\n\t\tLimit:      limit,\n\t\tOffset:     offset,\n\t\tNextCursor: nextCursor,\n\t})\n}\n\n")
+	}
+
+	return buf.String()
+}
@@ -0,0 +1,57 @@
+// Command backfill-candles materializes market_price_candles rows for
+// historical market_price_ticks data by calling Store.BackfillMarketPriceCandles
+// directly, independent of Service.Run's periodic RunRetention pass. Useful
+// after deploying the VWAP/TWAP aggregate columns or the retention subsystem
+// for the first time, when existing raw ticks predate CandleAggregator ever
+// having flushed candles for them. Safe to re-run: BackfillMarketPriceCandles
+// upserts, so covering an already-backfilled range is a no-op.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/coldbell/dex/backend/internal/config"
+	"github.com/coldbell/dex/backend/internal/indexer"
+)
+
+func main() {
+	market := flag.String("market", "", "market symbol to backfill (required)")
+	interval := flag.Duration("interval", time.Minute, "candle interval, e.g. 1m, 5m, 1h, 24h")
+	from := flag.Int64("from", 0, "start of backfill range, unix seconds (default: beginning of time)")
+	to := flag.Int64("to", 0, "end of backfill range, unix seconds (default: now)")
+	flag.Parse()
+
+	if *market == "" {
+		fmt.Fprintln(os.Stderr, "backfill-candles: -market is required")
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	toBucketTS := *to
+	if toBucketTS == 0 {
+		toBucketTS = time.Now().Unix()
+	}
+
+	cfg, err := config.LoadIndexerConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "backfill-candles: load indexer config: %v\n", err)
+		os.Exit(1)
+	}
+
+	store, err := indexer.NewStore(cfg.DBDSN)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "backfill-candles: open store: %v\n", err)
+		os.Exit(1)
+	}
+
+	n, err := store.BackfillMarketPriceCandles(context.Background(), *market, int64(interval.Seconds()), *from, toBucketTS)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "backfill-candles: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("backfilled %d candle(s) for %s @ %s\n", n, *market, interval)
+}
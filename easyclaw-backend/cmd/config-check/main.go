@@ -0,0 +1,172 @@
+// Command config-check loads the keeper, indexer, and api-server configs
+// exactly as their respective binaries would, validates them, and prints
+// the effective resolved values with secrets masked. It exits non-zero if
+// any config fails to load or fails Validate, so it can be wired into CI
+// or a container healthcheck without starting any of the three services.
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+
+	"github.com/coldbell/dex/backend/internal/config"
+)
+
+func main() {
+	ok := true
+
+	source, sourceErr := config.CurrentConfigSource()
+	if sourceErr == nil {
+		fmt.Printf("config source: phase=%s path=%s loaded=%v\n\n", source.Phase, source.Path, source.Loaded)
+	}
+
+	if !checkKeeperConfig() {
+		ok = false
+	}
+	if !checkIndexerConfig() {
+		ok = false
+	}
+	if !checkAPIServerConfig() {
+		ok = false
+	}
+	if !checkFundingArbConfig() {
+		ok = false
+	}
+
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+func checkKeeperConfig() bool {
+	fmt.Println("keeper:")
+	cfg, err := config.LoadKeeperConfig()
+	if err != nil {
+		fmt.Printf("  FAILED to load: %v\n\n", err)
+		return false
+	}
+	if err := cfg.Validate(); err != nil {
+		fmt.Printf("  FAILED validation: %v\n\n", err)
+		return false
+	}
+
+	fmt.Printf("  rpc_url: %s\n", cfg.RPCURL)
+	fmt.Printf("  ws_url_configured: %v\n", cfg.WSURL != "")
+	fmt.Printf("  commitment: %s\n", cfg.Commitment)
+	fmt.Printf("  keypair_path: %s\n", cfg.KeypairPath)
+	fmt.Printf("  keypair_bytes_loaded: %v\n", len(cfg.KeypairBytes) > 0)
+	fmt.Printf("  poll_interval: %s\n", cfg.PollInterval)
+	fmt.Printf("  tx_timeout: %s\n", cfg.TxTimeout)
+	fmt.Printf("  max_orders_per_tick: %d\n", cfg.MaxOrdersPerTick)
+	fmt.Printf("  max_concurrent_executions: %d\n", cfg.MaxConcurrentExecutions)
+	fmt.Printf("  batch_by_market: %v\n", cfg.BatchByMarket)
+	fmt.Printf("  max_orders_per_batch: %d\n", cfg.MaxOrdersPerBatch)
+	fmt.Printf("  trailing_stop_tiers: %d\n", len(cfg.TrailingActivationRatio))
+	fmt.Printf("  compute_unit_limit: %d\n", cfg.ComputeUnitLimit)
+	fmt.Printf("  compute_unit_price_micro_lamports: %d\n", cfg.ComputeUnitPriceMicroLamports)
+	fmt.Printf("  priority_fee_strategy: %s\n", cfg.PriorityFee.Strategy)
+	fmt.Printf("  oracle_markets_configured: %d\n", len(cfg.OracleByMarket))
+	fmt.Printf("  switchboard_markets_configured: %d\n", len(cfg.SwitchboardOracleByMarket))
+	fmt.Printf("  orca_whirlpool_markets_configured: %d\n", len(cfg.OrcaWhirlpoolOracleByMarket))
+	fmt.Println("  OK")
+	fmt.Println()
+	return true
+}
+
+func checkIndexerConfig() bool {
+	fmt.Println("indexer:")
+	cfg, err := config.LoadIndexerConfig()
+	if err != nil {
+		fmt.Printf("  FAILED to load: %v\n\n", err)
+		return false
+	}
+	if err := cfg.Validate(); err != nil {
+		fmt.Printf("  FAILED validation: %v\n\n", err)
+		return false
+	}
+
+	fmt.Printf("  rpc_url: %s\n", cfg.RPCURL)
+	fmt.Printf("  db_dsn: %s\n", maskDSN(cfg.DBDSN))
+	fmt.Printf("  poll_interval: %s\n", cfg.PollInterval)
+	fmt.Printf("  orderbook_targets: %d\n", len(cfg.OrderbookTargets))
+	fmt.Printf("  orderbook_snapshot_interval: %s\n", cfg.OrderbookSnapshotInterval)
+	fmt.Printf("  orderbook_refresh_interval: %s\n", cfg.OrderbookRefreshInterval)
+	fmt.Printf("  orderbook_websocket_addr: %s\n", cfg.OrderbookWebsocketAddr)
+	fmt.Printf("  consolidated_targets: %d\n", len(cfg.ConsolidatedTargets))
+	fmt.Printf("  enable_pyth_price_stream: %v\n", cfg.EnablePythPriceStream)
+	fmt.Printf("  chainlink_feeds: %d\n", len(cfg.ChainlinkFeeds))
+	fmt.Println("  OK")
+	fmt.Println()
+	return true
+}
+
+func checkAPIServerConfig() bool {
+	fmt.Println("api-server:")
+	cfg, err := config.LoadAPIServerConfig()
+	if err != nil {
+		fmt.Printf("  FAILED to load: %v\n\n", err)
+		return false
+	}
+	if err := cfg.Validate(); err != nil {
+		fmt.Printf("  FAILED validation: %v\n\n", err)
+		return false
+	}
+
+	fmt.Printf("  listen_addr: %s\n", cfg.ListenAddr)
+	fmt.Printf("  db_dsn: %s\n", maskDSN(cfg.DBDSN))
+	fmt.Printf("  read_timeout: %s\n", cfg.ReadTimeout)
+	fmt.Printf("  write_timeout: %s\n", cfg.WriteTimeout)
+	fmt.Printf("  allowed_origins: %d\n", len(cfg.AllowedOrigins))
+	fmt.Println("  OK")
+	fmt.Println()
+	return true
+}
+
+func checkFundingArbConfig() bool {
+	fmt.Println("funding-arb:")
+	cfg, err := config.LoadFundingArbConfig()
+	if err != nil {
+		fmt.Printf("  FAILED to load: %v\n\n", err)
+		return false
+	}
+	if err := cfg.Validate(); err != nil {
+		fmt.Printf("  FAILED validation: %v\n\n", err)
+		return false
+	}
+
+	if cfg.Symbol == "" {
+		fmt.Println("  disabled (FUNDING_ARB_SYMBOL not set)")
+		fmt.Println()
+		return true
+	}
+
+	fmt.Printf("  symbol: %s\n", cfg.Symbol)
+	fmt.Printf("  market_id: %d\n", cfg.MarketID)
+	fmt.Printf("  spot_session: %s\n", cfg.SpotSession)
+	fmt.Printf("  futures_session: %s\n", cfg.FuturesSession)
+	fmt.Printf("  quote_investment: %v\n", cfg.QuoteInvestment)
+	fmt.Printf("  incremental_quote_quantity: %v\n", cfg.IncrementalQuoteQuantity)
+	fmt.Printf("  leverage: %v\n", cfg.Leverage)
+	fmt.Printf("  short_funding_rate_high: %v\n", cfg.ShortFundingRateHigh)
+	fmt.Printf("  short_funding_rate_low: %v\n", cfg.ShortFundingRateLow)
+	fmt.Println("  OK")
+	fmt.Println()
+	return true
+}
+
+// maskDSN hides a DSN's userinfo (username/password) while keeping the
+// rest of the connection string visible enough to spot a misconfigured
+// host or database name.
+func maskDSN(dsn string) string {
+	parsed, err := url.Parse(dsn)
+	if err != nil || parsed.User == nil {
+		return dsn
+	}
+	if username := parsed.User.Username(); username != "" {
+		parsed.User = url.UserPassword(username, "****")
+	} else {
+		parsed.User = url.User("****")
+	}
+	return parsed.String()
+}
@@ -22,7 +22,7 @@ func main() {
 		os.Exit(1)
 	}
 
-	logger, closeLogger, err := logging.New("indexer", cfg.Log)
+	logger, closeLogger, _, err := logging.New("indexer", cfg.Log)
 	if err != nil {
 		bootstrapLogger.Error("failed to initialize logger", "err", err)
 		os.Exit(1)
@@ -43,6 +43,16 @@ func main() {
 		os.Exit(1)
 	}
 
+	config.Subscribe(func(_, updated config.Snapshot) {
+		feeds, err := updated.PythFeeds()
+		if err != nil {
+			logger.Error("reload pyth feeds: invalid config, keeping previous feeds", "err", err)
+			return
+		}
+		svc.ReloadPythFeeds(feeds)
+		logger.Info("reloaded pyth feeds from config change", "feed_count", len(feeds))
+	})
+
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
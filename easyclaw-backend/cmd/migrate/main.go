@@ -0,0 +1,89 @@
+// Command migrate drives the indexer's schema_migrations chain directly
+// against DBDSN from the indexer config, independent of starting the
+// indexer itself. Useful for running migrations as a separate deploy
+// step, or for inspecting/repairing schema state with up/up-to/down/redo
+// instead of waiting on NewStore's automatic Up on indexer boot.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+
+	"github.com/coldbell/dex/backend/internal/config"
+	"github.com/coldbell/dex/backend/internal/indexer/migrate"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(1)
+	}
+
+	cfg, err := config.LoadIndexerConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migrate: load indexer config: %v\n", err)
+		os.Exit(1)
+	}
+
+	db, err := sql.Open("pgx", cfg.DBDSN)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migrate: open postgres: %v\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	migrator, err := migrate.New(db)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migrate: load migrations: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	if err := run(ctx, migrator, os.Args[1], os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "migrate: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, migrator *migrate.Migrator, cmd string, args []string) error {
+	switch cmd {
+	case "up":
+		return migrator.Up(ctx)
+	case "up-to":
+		if len(args) != 1 {
+			return fmt.Errorf("up-to requires a version argument")
+		}
+		return migrator.UpTo(ctx, args[0])
+	case "down":
+		return migrator.Down(ctx)
+	case "redo":
+		return migrator.Redo(ctx)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.Applied {
+				state = "applied"
+				if !status.ChecksumOK {
+					state = "applied (CHECKSUM DRIFT)"
+				}
+			}
+			fmt.Printf("%s_%s: %s\n", status.Version, status.Name, state)
+		}
+		return nil
+	default:
+		usage()
+		return fmt.Errorf("unknown subcommand %q", cmd)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: migrate <up|up-to VERSION|down|redo|status>")
+}
@@ -0,0 +1,191 @@
+// Command record-vectors pulls fresh account payloads from a devnet (or
+// any other) RPC endpoint for a list of pubkeys and writes them out as
+// internal/indexer/testdata/vectors/*.json fixtures, replacing whatever
+// was there before. Run it via `make record-vectors` whenever an Anchor
+// IDL changes, so account_vectors_test.go is replaying real on-chain
+// layouts rather than the zero-filled placeholders new fixtures start
+// out as.
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	lpvault "github.com/coldbell/dex/backend/internal/anchor/lp_vault"
+	orderengine "github.com/coldbell/dex/backend/internal/anchor/order_engine"
+	"github.com/coldbell/dex/backend/internal/indexer"
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// knownDiscriminator pairs an account type name with its 8-byte Anchor
+// discriminator and a parser that turns raw account data into the JSON
+// that becomes a fixture's expected_parsed field. It mirrors, rather than
+// reuses, service.go's programDiscriminatorEntry tables: those need a
+// live *Service wired to real program IDs and a transaction, which this
+// standalone recorder has no use for.
+type knownDiscriminator struct {
+	accountType   string
+	table         string
+	discriminator [8]byte
+	parse         func(data []byte) (any, error)
+}
+
+var knownDiscriminators = []knownDiscriminator{
+	{"EngineConfig", "resources", orderengine.Account_EngineConfig, func(data []byte) (any, error) {
+		return orderengine.ParseAccount_EngineConfig(data)
+	}},
+	{"UserMargin", "resources", orderengine.Account_UserMargin, func(data []byte) (any, error) {
+		return orderengine.ParseAccount_UserMargin(data)
+	}},
+	{"MarketFundingState", "resources", orderengine.Account_MarketFundingState, func(data []byte) (any, error) {
+		return orderengine.ParseAccount_MarketFundingState(data)
+	}},
+	{"Order", "orders", orderengine.Account_Order, func(data []byte) (any, error) {
+		return orderengine.ParseAccount_Order(data)
+	}},
+	{"UserMarketPosition", "positions", orderengine.Account_UserMarketPosition, func(data []byte) (any, error) {
+		return orderengine.ParseAccount_UserMarketPosition(data)
+	}},
+	{"GlobalConfig", "resources", orderengine.Account_GlobalConfig, func(data []byte) (any, error) {
+		return orderengine.ParseAccount_GlobalConfig(data)
+	}},
+	{"KeeperSet", "resources", orderengine.Account_KeeperSet, func(data []byte) (any, error) {
+		return orderengine.ParseAccount_KeeperSet(data)
+	}},
+	{"Market", "resources", orderengine.Account_Market, func(data []byte) (any, error) {
+		return orderengine.ParseAccount_Market(data)
+	}},
+	{"Pool", "resources", lpvault.Account_Pool, func(data []byte) (any, error) {
+		return lpvault.ParseAccount_Pool(data)
+	}},
+	{"KeeperRebate", "resources", lpvault.Account_KeeperRebate, func(data []byte) (any, error) {
+		return lpvault.ParseAccount_KeeperRebate(data)
+	}},
+	{"LpPosition", "lp_positions", lpvault.Account_LpPosition, func(data []byte) (any, error) {
+		return lpvault.ParseAccount_LpPosition(data)
+	}},
+	{"WithdrawRequest", "resources", lpvault.Account_WithdrawRequest, func(data []byte) (any, error) {
+		return lpvault.ParseAccount_WithdrawRequest(data)
+	}},
+}
+
+func main() {
+	rpcURL := flag.String("rpc-url", "https://api.devnet.solana.com", "RPC endpoint to fetch accounts from")
+	outDir := flag.String("out-dir", "internal/indexer/testdata/vectors", "directory to write vector fixtures into")
+	pubkeysRaw := flag.String("pubkeys", "", "comma-separated list of account pubkeys to capture")
+	flag.Parse()
+
+	if strings.TrimSpace(*pubkeysRaw) == "" {
+		fmt.Fprintln(os.Stderr, "record-vectors: -pubkeys is required")
+		os.Exit(1)
+	}
+
+	var pubkeys []solana.PublicKey
+	for _, raw := range strings.Split(*pubkeysRaw, ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		pubkey, err := solana.PublicKeyFromBase58(raw)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "record-vectors: invalid pubkey %q: %v\n", raw, err)
+			os.Exit(1)
+		}
+		pubkeys = append(pubkeys, pubkey)
+	}
+
+	client := rpc.New(*rpcURL)
+	ctx := context.Background()
+
+	if err := os.MkdirAll(*outDir, 0o755); err != nil {
+		fmt.Fprintf(os.Stderr, "record-vectors: create %s: %v\n", *outDir, err)
+		os.Exit(1)
+	}
+
+	recorded := 0
+	for _, pubkey := range pubkeys {
+		result, err := client.GetAccountInfo(ctx, pubkey)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "record-vectors: fetch %s: %v\n", pubkey, err)
+			continue
+		}
+		if result == nil || result.Value == nil {
+			fmt.Fprintf(os.Stderr, "record-vectors: %s has no account data\n", pubkey)
+			continue
+		}
+		account := result.Value
+		data := account.Data.GetBinary()
+		if len(data) < 8 {
+			fmt.Fprintf(os.Stderr, "record-vectors: %s has fewer than 8 bytes of data\n", pubkey)
+			continue
+		}
+		var discriminator [8]byte
+		copy(discriminator[:], data[:8])
+
+		matched := false
+		for _, known := range knownDiscriminators {
+			if known.discriminator != discriminator {
+				continue
+			}
+			matched = true
+
+			parsed, err := known.parse(data)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "record-vectors: parse %s as %s: %v\n", pubkey, known.accountType, err)
+				break
+			}
+			expectedParsed, err := json.Marshal(parsed)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "record-vectors: marshal parsed %s: %v\n", pubkey, err)
+				break
+			}
+			expectedRow, err := json.Marshal(map[string]string{
+				"table":        known.table,
+				"account_type": known.accountType,
+			})
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "record-vectors: marshal expected_row for %s: %v\n", pubkey, err)
+				break
+			}
+
+			vector := indexer.AccountVector{
+				Name:           fmt.Sprintf("%03d_%s", recorded+1, strings.ToLower(known.accountType)),
+				AccountType:    known.accountType,
+				ProgramID:      account.Owner.String(),
+				Pubkey:         pubkey.String(),
+				Owner:          account.Owner.String(),
+				Lamports:       account.Lamports,
+				Slot:           result.Context.Slot,
+				DataBase64:     base64.StdEncoding.EncodeToString(data),
+				ExpectedParsed: expectedParsed,
+				ExpectedRow:    expectedRow,
+			}
+
+			outPath := filepath.Join(*outDir, vector.Name+".json")
+			raw, err := json.MarshalIndent(vector, "", "  ")
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "record-vectors: marshal vector for %s: %v\n", pubkey, err)
+				break
+			}
+			if err := os.WriteFile(outPath, append(raw, '\n'), 0o644); err != nil {
+				fmt.Fprintf(os.Stderr, "record-vectors: write %s: %v\n", outPath, err)
+				break
+			}
+			fmt.Printf("record-vectors: wrote %s (%s)\n", outPath, known.accountType)
+			recorded++
+			break
+		}
+		if !matched {
+			fmt.Fprintf(os.Stderr, "record-vectors: %s has an unrecognized discriminator, skipping\n", pubkey)
+		}
+	}
+
+	fmt.Printf("record-vectors: recorded %d/%d vectors\n", recorded, len(pubkeys))
+}
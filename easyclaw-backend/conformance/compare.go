@@ -0,0 +1,56 @@
+package conformance
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// Compare reports whether actual matches expected once the fields named
+// in volatileFields have been masked out of both sides, so fixtures
+// don't churn on values like timestamps that legitimately differ between
+// runs.
+func Compare(expected, actual json.RawMessage, volatileFields []string) error {
+	var expectedValue, actualValue any
+	if err := json.Unmarshal(expected, &expectedValue); err != nil {
+		return fmt.Errorf("parse expected body: %w", err)
+	}
+	if err := json.Unmarshal(actual, &actualValue); err != nil {
+		return fmt.Errorf("parse actual body: %w", err)
+	}
+
+	maskVolatileFields(expectedValue, volatileFields)
+	maskVolatileFields(actualValue, volatileFields)
+
+	if !reflect.DeepEqual(expectedValue, actualValue) {
+		expectedJSON, _ := json.MarshalIndent(expectedValue, "", "  ")
+		actualJSON, _ := json.MarshalIndent(actualValue, "", "  ")
+		return fmt.Errorf("response body mismatch:\n--- expected ---\n%s\n--- actual ---\n%s", expectedJSON, actualJSON)
+	}
+	return nil
+}
+
+// maskVolatileFields zeroes out the named fields wherever they appear as
+// object keys, at any depth, in value. It mutates maps in place; value
+// itself must be the result of unmarshaling into an any (so objects are
+// map[string]any and arrays are []any).
+func maskVolatileFields(value any, fields []string) {
+	if len(fields) == 0 {
+		return
+	}
+	switch v := value.(type) {
+	case map[string]any:
+		for _, field := range fields {
+			if _, ok := v[field]; ok {
+				v[field] = "<volatile>"
+			}
+		}
+		for _, nested := range v {
+			maskVolatileFields(nested, fields)
+		}
+	case []any:
+		for _, nested := range v {
+			maskVolatileFields(nested, fields)
+		}
+	}
+}
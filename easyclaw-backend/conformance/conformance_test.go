@@ -0,0 +1,76 @@
+//go:build conformance
+
+// Package conformance's test entry point. Split behind the "conformance"
+// build tag since it needs a real Postgres instance and is meant to be
+// run deliberately (e.g. in CI against a disposable database), not as
+// part of the default `go test ./...` sweep.
+package conformance
+
+import (
+	"flag"
+	"log/slog"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/coldbell/dex/backend/internal/apiserver"
+	"github.com/coldbell/dex/backend/internal/config"
+)
+
+var update = flag.Bool("update", false, "regenerate vector fixtures from the live server's responses instead of checking them")
+
+const vectorsDir = "testdata/vectors"
+
+// TestConformance replays every fixture in testdata/vectors against a
+// Service backed by CONFORMANCE_DB_DSN. There's no in-memory or fake
+// Store implementation anywhere in this codebase — Store is a thin,
+// direct wrapper over a real pgx connection — so unlike most Go test
+// suites this one can't seed an in-process fake; it needs a real
+// (disposable) Postgres database. The test is skipped rather than
+// failed when that database isn't configured, so it doesn't break the
+// normal build/vet/test loop for everyone else.
+func TestConformance(t *testing.T) {
+	dsn := os.Getenv("CONFORMANCE_DB_DSN")
+	if dsn == "" {
+		t.Skip("CONFORMANCE_DB_DSN not set: conformance tests need a real disposable Postgres database, since this codebase has no in-memory Store")
+	}
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+	svc, err := apiserver.New(config.APIServerConfig{
+		DBDSN:          dsn,
+		AllowedOrigins: []string{"*"},
+	}, logger)
+	if err != nil {
+		t.Fatalf("init service: %v", err)
+	}
+
+	server := httptest.NewServer(svc.Handler())
+	defer server.Close()
+
+	vectors, err := LoadVectors(vectorsDir)
+	if err != nil {
+		t.Fatalf("load vectors: %v", err)
+	}
+
+	runner := NewRunner(server.URL)
+	for _, vector := range vectors {
+		vector := vector
+		t.Run(vector.Name, func(t *testing.T) {
+			if *update {
+				status, body, err := runner.Capture(vector)
+				if err != nil {
+					t.Fatalf("capture response: %v", err)
+				}
+				vector.ExpectedStatus = status
+				vector.ExpectedBody = body
+				if err := WriteVector(vectorsDir, vector); err != nil {
+					t.Fatalf("write vector: %v", err)
+				}
+				return
+			}
+			if err := runner.Run(vector); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
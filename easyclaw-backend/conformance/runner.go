@@ -0,0 +1,112 @@
+package conformance
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Runner replays Vectors against a live server, identified only by
+// BaseURL — an httptest.Server URL for an in-process run, or a real
+// deployment URL for checking a remote environment.
+type Runner struct {
+	BaseURL string
+	Client  *http.Client
+}
+
+// NewRunner returns a Runner with a timeout-bounded default client.
+func NewRunner(baseURL string) *Runner {
+	return &Runner{
+		BaseURL: baseURL,
+		Client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Run executes vector against r.BaseURL and compares the response
+// against vector's expectations, returning a non-nil error describing
+// the first mismatch found.
+func (r *Runner) Run(vector Vector) error {
+	var bodyReader io.Reader
+	if len(vector.Body) > 0 {
+		bodyReader = bytes.NewReader(vector.Body)
+	}
+	req, err := http.NewRequest(vector.Method, r.BaseURL+vector.Path, bodyReader)
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+	for key, value := range vector.Headers {
+		req.Header.Set(key, value)
+	}
+	if bodyReader != nil && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("do request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read response body: %w", err)
+	}
+
+	if vector.ExpectedStatus != 0 && resp.StatusCode != vector.ExpectedStatus {
+		return fmt.Errorf("status mismatch: expected %d, got %d (body: %s)", vector.ExpectedStatus, resp.StatusCode, respBody)
+	}
+	if len(vector.ExpectedBody) == 0 {
+		return nil
+	}
+	if !json.Valid(respBody) {
+		return fmt.Errorf("response body is not valid JSON: %s", respBody)
+	}
+	return Compare(vector.ExpectedBody, respBody, vector.VolatileFields)
+}
+
+// RunAll runs every vector and returns a map from vector name to the
+// error Run produced, if any — vectors that passed are omitted.
+func (r *Runner) RunAll(vectors []Vector) map[string]error {
+	failures := make(map[string]error)
+	for _, vector := range vectors {
+		if err := r.Run(vector); err != nil {
+			failures[vector.Name] = err
+		}
+	}
+	return failures
+}
+
+// Capture replays vector's request and returns the status and body it
+// actually got back, without comparing against vector's expectations —
+// used to regenerate a fixture's expected_status/expected_body.
+func (r *Runner) Capture(vector Vector) (int, json.RawMessage, error) {
+	var bodyReader io.Reader
+	if len(vector.Body) > 0 {
+		bodyReader = bytes.NewReader(vector.Body)
+	}
+	req, err := http.NewRequest(vector.Method, r.BaseURL+vector.Path, bodyReader)
+	if err != nil {
+		return 0, nil, fmt.Errorf("build request: %w", err)
+	}
+	for key, value := range vector.Headers {
+		req.Header.Set(key, value)
+	}
+	if bodyReader != nil && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return 0, nil, fmt.Errorf("do request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, fmt.Errorf("read response body: %w", err)
+	}
+	return resp.StatusCode, respBody, nil
+}
@@ -0,0 +1,79 @@
+// Package conformance implements a JSON test-vector harness for the
+// public HTTP+WS API: request/expected-response fixture pairs under
+// testdata/vectors/, replayed with Runner against either a freshly
+// seeded Service (see conformance_test.go, build tag "conformance") or
+// an already-running remote deployment, to catch accidental schema
+// drift in the response shapes those fixtures pin down.
+package conformance
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Vector is one request/expected-response fixture.
+type Vector struct {
+	Name           string            `json:"name"`
+	Method         string            `json:"method"`
+	Path           string            `json:"path"`
+	Headers        map[string]string `json:"headers,omitempty"`
+	Body           json.RawMessage   `json:"body,omitempty"`
+	ExpectedStatus int               `json:"expected_status"`
+	ExpectedBody   json.RawMessage   `json:"expected_body"`
+	// VolatileFields lists top-level field names in ExpectedBody that
+	// are allowed to differ between the fixture and a live response,
+	// e.g. "ts" or "updated_at" timestamps.
+	VolatileFields []string `json:"volatile_fields,omitempty"`
+}
+
+// LoadVectors reads every *.json fixture under dir, sorted by filename
+// so a run's ordering (and -update's rewrites) are deterministic.
+func LoadVectors(dir string) ([]Vector, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read vectors dir: %w", err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+
+	vectors := make([]Vector, 0, len(names))
+	for _, name := range names {
+		raw, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("read vector %s: %w", name, err)
+		}
+		var vector Vector
+		if err := json.Unmarshal(raw, &vector); err != nil {
+			return nil, fmt.Errorf("parse vector %s: %w", name, err)
+		}
+		if vector.Name == "" {
+			vector.Name = name
+		}
+		vectors = append(vectors, vector)
+	}
+	return vectors, nil
+}
+
+// WriteVector writes (or overwrites) a vector fixture under dir, used by
+// -update runs to regenerate expected_status/expected_body from a live
+// response.
+func WriteVector(dir string, vector Vector) error {
+	raw, err := json.MarshalIndent(vector, "", "  ")
+	if err != nil {
+		return err
+	}
+	name := vector.Name
+	if filepath.Ext(name) != ".json" {
+		name += ".json"
+	}
+	return os.WriteFile(filepath.Join(dir, name), append(raw, '\n'), 0o644)
+}
@@ -0,0 +1,270 @@
+package apiserver
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coldbell/dex/backend/internal/indexer"
+)
+
+// defaultRecvWindow and maxRecvWindow bound the X-API-RECV-WINDOW header:
+// callers may widen their clock-skew tolerance up to maxRecvWindow, but
+// never disable the staleness check entirely.
+const (
+	defaultRecvWindow = 5 * time.Second
+	maxRecvWindow     = 60 * time.Second
+)
+
+// requireSignedRequest is bearerTokenFromRequest's sibling for the
+// exchange-style HMAC auth mode: the caller signs
+// timestamp+api_key+recv_window+canonical_payload with its api_secret
+// (hex(hmac_sha256(...)), via X-API-SIGN) instead of presenting a bearer
+// token. If apiKey isn't a registered api_key, it's tried as a wallet
+// pubkey instead and the same message is verified as an ed25519
+// signature with verifyWalletSignature, so a caller can sign requests
+// directly with their Solana key without minting a long-lived secret.
+func (s *Service) requireSignedRequest(r *http.Request, apiKey, scope, agentID string) (authPrincipal, error) {
+	timestamp := strings.TrimSpace(r.Header.Get("X-API-TIMESTAMP"))
+	signature := strings.TrimSpace(r.Header.Get("X-API-SIGN"))
+	if timestamp == "" || signature == "" {
+		return authPrincipal{}, fmt.Errorf("X-API-TIMESTAMP and X-API-SIGN headers are required")
+	}
+	recvWindowRaw := strings.TrimSpace(r.Header.Get("X-API-RECV-WINDOW"))
+	if recvWindowRaw == "" {
+		recvWindowRaw = strconv.Itoa(int(defaultRecvWindow / time.Second))
+	}
+	recvWindowSeconds, err := strconv.Atoi(recvWindowRaw)
+	if err != nil || recvWindowSeconds <= 0 || time.Duration(recvWindowSeconds)*time.Second > maxRecvWindow {
+		return authPrincipal{}, fmt.Errorf("recv_window must be between 1 and %d seconds", int(maxRecvWindow/time.Second))
+	}
+	timestampMS, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return authPrincipal{}, fmt.Errorf("invalid X-API-TIMESTAMP")
+	}
+	recvWindow := time.Duration(recvWindowSeconds) * time.Second
+	if delta := time.Since(time.UnixMilli(timestampMS)); delta > recvWindow || delta < -recvWindow {
+		return authPrincipal{}, fmt.Errorf("request timestamp outside recv_window")
+	}
+
+	payload, err := canonicalRequestPayload(r)
+	if err != nil {
+		return authPrincipal{}, err
+	}
+	message := timestamp + apiKey + recvWindowRaw + payload
+
+	key, err := s.store.GetAPIKeyByKey(r.Context(), apiKey)
+	if err != nil {
+		if !errors.Is(err, indexer.ErrNotFound) {
+			return authPrincipal{}, err
+		}
+		if walletErr := verifyWalletSignature(apiKey, signature, message); walletErr != nil {
+			return authPrincipal{}, fmt.Errorf("invalid api key or signature")
+		}
+		return enforcePrincipalScope(authPrincipal{WalletPubkey: apiKey}, scope, agentID)
+	}
+	if key.RevokedAt != nil || key.ExpiresAt <= time.Now().Unix() {
+		return authPrincipal{}, fmt.Errorf("invalid or expired api key")
+	}
+	if !hmac.Equal([]byte(signHMACMessage(key.APISecret, message)), []byte(strings.ToLower(signature))) {
+		return authPrincipal{}, fmt.Errorf("signature verification failed")
+	}
+	return enforcePrincipalScope(authPrincipal{WalletPubkey: key.WalletPubkey, Scopes: key.Scopes}, scope, agentID)
+}
+
+// canonicalRequestPayload is the part of the request the signature
+// covers in addition to timestamp+api_key+recv_window: the raw query
+// string for a GET/DELETE, the raw body otherwise. Reading the body here
+// drains r.Body, so it's restored for the handler's own decodeJSONBody
+// call afterward.
+func canonicalRequestPayload(r *http.Request) (string, error) {
+	if r.Method == http.MethodGet || r.Method == http.MethodDelete || r.Body == nil {
+		return r.URL.RawQuery, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("failed to read request body")
+	}
+	_ = r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return string(body), nil
+}
+
+func signHMACMessage(secret, message string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(message))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func newAPIKeyAndSecret() (string, string, error) {
+	keyRaw := make([]byte, 16)
+	if _, err := rand.Read(keyRaw); err != nil {
+		return "", "", err
+	}
+	secretRaw := make([]byte, 32)
+	if _, err := rand.Read(secretRaw); err != nil {
+		return "", "", err
+	}
+	return "ak_" + hex.EncodeToString(keyRaw), hex.EncodeToString(secretRaw), nil
+}
+
+type createAPIKeyRequest struct {
+	Scopes    []string `json:"scopes"`
+	ExpiresAt int64    `json:"expires_at,omitempty"`
+}
+
+type createAPIKeyResponse struct {
+	KeyID     string   `json:"key_id"`
+	APIKey    string   `json:"api_key"`
+	APISecret string   `json:"api_secret"`
+	Scopes    []string `json:"scopes"`
+	ExpiresAt int64    `json:"expires_at"`
+}
+
+type apiKeyResponse struct {
+	KeyID     string   `json:"key_id"`
+	APIKey    string   `json:"api_key"`
+	Scopes    []string `json:"scopes"`
+	CreatedAt int64    `json:"created_at"`
+	ExpiresAt int64    `json:"expires_at"`
+	RevokedAt *int64   `json:"revoked_at,omitempty"`
+}
+
+// handleAPIKeysRoot mints and lists api_key/api_secret pairs for the
+// signed-request auth mode. Like handleAPITokensRoot, minting requires a
+// full owner session rather than another scoped credential, so a
+// read-only credential can't mint itself a broader one.
+func (s *Service) handleAPIKeysRoot(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		session, err := s.requireSession(r)
+		if err != nil {
+			s.respondError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		var request createAPIKeyRequest
+		if err := decodeJSONBody(r, &request); err != nil {
+			s.respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		scopes := make([]string, 0, len(request.Scopes))
+		for _, scope := range request.Scopes {
+			scope = strings.TrimSpace(scope)
+			if scope == "" {
+				continue
+			}
+			if !isKnownScope(scope) {
+				s.respondError(w, http.StatusBadRequest, fmt.Sprintf("unknown scope %q", scope))
+				return
+			}
+			scopes = append(scopes, scope)
+		}
+		if len(scopes) == 0 {
+			s.respondError(w, http.StatusBadRequest, "scopes is required")
+			return
+		}
+
+		apiKey, apiSecret, err := newAPIKeyAndSecret()
+		if err != nil {
+			s.logger.Error("generate api key failed", "err", err)
+			s.respondError(w, http.StatusInternalServerError, "failed to create api key")
+			return
+		}
+		keyID, err := newID("ak")
+		if err != nil {
+			s.logger.Error("generate api key id failed", "err", err)
+			s.respondError(w, http.StatusInternalServerError, "failed to create api key")
+			return
+		}
+		now := time.Now().Unix()
+		expiresAt := request.ExpiresAt
+		if expiresAt <= now {
+			expiresAt = now + int64(apiTokenTTLDefault/time.Second)
+		}
+		record := indexer.APIKeyRecord{
+			ID:           keyID,
+			APIKey:       apiKey,
+			APISecret:    apiSecret,
+			WalletPubkey: session.WalletPubkey,
+			Scopes:       scopes,
+			CreatedAt:    now,
+			ExpiresAt:    expiresAt,
+		}
+		if err := s.store.CreateAPIKey(r.Context(), record); err != nil {
+			s.logger.Error("create api key failed", "err", err)
+			s.respondError(w, http.StatusInternalServerError, "failed to create api key")
+			return
+		}
+		s.respondJSON(w, http.StatusOK, createAPIKeyResponse{
+			KeyID:     keyID,
+			APIKey:    apiKey,
+			APISecret: apiSecret,
+			Scopes:    scopes,
+			ExpiresAt: expiresAt,
+		})
+
+	case http.MethodGet:
+		session, err := s.requireSession(r)
+		if err != nil {
+			s.respondError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		keys, err := s.store.ListAPIKeys(r.Context(), session.WalletPubkey)
+		if err != nil {
+			s.logger.Error("list api keys failed", "err", err)
+			s.respondError(w, http.StatusInternalServerError, "failed to list api keys")
+			return
+		}
+		out := make([]apiKeyResponse, 0, len(keys))
+		for _, key := range keys {
+			out = append(out, apiKeyResponse{
+				KeyID:     key.ID,
+				APIKey:    key.APIKey,
+				Scopes:    key.Scopes,
+				CreatedAt: key.CreatedAt,
+				ExpiresAt: key.ExpiresAt,
+				RevokedAt: key.RevokedAt,
+			})
+		}
+		s.respondJSON(w, http.StatusOK, out)
+
+	default:
+		s.respondMethodNotAllowed(w)
+	}
+}
+
+func (s *Service) handleAPIKeyByID(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		s.respondMethodNotAllowed(w)
+		return
+	}
+	keyID := strings.TrimSpace(strings.TrimPrefix(r.URL.Path, "/v1/auth/apikeys/"))
+	if keyID == "" {
+		s.respondError(w, http.StatusBadRequest, "key id is required")
+		return
+	}
+	session, err := s.requireSession(r)
+	if err != nil {
+		s.respondError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if err := s.store.RevokeAPIKey(r.Context(), session.WalletPubkey, keyID, time.Now().Unix()); err != nil {
+		if errors.Is(err, indexer.ErrNotFound) {
+			s.respondError(w, http.StatusNotFound, "api key not found")
+			return
+		}
+		s.logger.Error("revoke api key failed", "err", err)
+		s.respondError(w, http.StatusInternalServerError, "failed to revoke api key")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,172 @@
+package apiserver
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/coldbell/dex/backend/internal/indexer"
+)
+
+const oauthCodeTTL = 5 * time.Minute
+
+type oauthAuthorizeRequest struct {
+	ClientID    string   `json:"client_id"`
+	Scopes      []string `json:"scopes"`
+	AgentIDs    []string `json:"agent_ids,omitempty"`
+	RedirectURI string   `json:"redirect_uri"`
+}
+
+type oauthAuthorizeResponse struct {
+	Code        string `json:"code"`
+	RedirectURI string `json:"redirect_uri"`
+}
+
+type oauthTokenRequest struct {
+	GrantType string `json:"grant_type"`
+	Code      string `json:"code"`
+	ClientID  string `json:"client_id"`
+}
+
+type oauthTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresAt   int64  `json:"expires_at"`
+	Scope       string `json:"scope"`
+}
+
+// handleOAuthAuthorize is the delegated-access counterpart to
+// handleAPITokensRoot's POST path: instead of the owner minting a token for
+// themselves, they approve a third-party client_id for a scoped slice of
+// their account, authenticated with their own session (the wallet's
+// signature), and get back a short-lived authorization code to hand to that
+// client — never the session token or signature itself.
+func (s *Service) handleOAuthAuthorize(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.respondMethodNotAllowed(w)
+		return
+	}
+	session, err := s.requireSession(r)
+	if err != nil {
+		s.respondError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var request oauthAuthorizeRequest
+	if err := decodeJSONBody(r, &request); err != nil {
+		s.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	request.ClientID = strings.TrimSpace(request.ClientID)
+	request.RedirectURI = strings.TrimSpace(request.RedirectURI)
+	if request.ClientID == "" || request.RedirectURI == "" {
+		s.respondError(w, http.StatusBadRequest, "client_id and redirect_uri are required")
+		return
+	}
+
+	scopes := make([]string, 0, len(request.Scopes))
+	for _, scope := range request.Scopes {
+		scope = strings.TrimSpace(scope)
+		if scope == "" {
+			continue
+		}
+		if !isKnownScope(scope) {
+			s.respondError(w, http.StatusBadRequest, fmt.Sprintf("unknown scope %q", scope))
+			return
+		}
+		scopes = append(scopes, scope)
+	}
+	if len(scopes) == 0 {
+		s.respondError(w, http.StatusBadRequest, "scopes is required")
+		return
+	}
+	agentIDs := make([]string, 0, len(request.AgentIDs))
+	for _, agentID := range request.AgentIDs {
+		agentID = strings.TrimSpace(agentID)
+		if agentID != "" {
+			agentIDs = append(agentIDs, agentID)
+		}
+	}
+
+	code, err := newID("oauthcode")
+	if err != nil {
+		s.logger.Error("create oauth code failed", "err", err)
+		s.respondError(w, http.StatusInternalServerError, "failed to authorize client")
+		return
+	}
+	now := time.Now().Unix()
+	err = s.store.CreateOAuthCode(r.Context(), indexer.OAuthCodeRecord{
+		Code:         code,
+		ClientID:     request.ClientID,
+		WalletPubkey: session.WalletPubkey,
+		Scopes:       scopes,
+		AgentIDs:     agentIDs,
+		CreatedAt:    now,
+		ExpiresAt:    now + int64(oauthCodeTTL/time.Second),
+	})
+	if err != nil {
+		s.logger.Error("store oauth code failed", "err", err)
+		s.respondError(w, http.StatusInternalServerError, "failed to authorize client")
+		return
+	}
+
+	s.respondJSON(w, http.StatusOK, oauthAuthorizeResponse{Code: code, RedirectURI: request.RedirectURI})
+}
+
+// handleOAuthToken exchanges a code from handleOAuthAuthorize for a scoped
+// API token, the same kind handleAPITokensRoot mints directly. The
+// exchanging client never sees the owner's wallet signature or session
+// token, only this derived, narrowly-scoped credential.
+func (s *Service) handleOAuthToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.respondMethodNotAllowed(w)
+		return
+	}
+
+	var request oauthTokenRequest
+	if err := decodeJSONBody(r, &request); err != nil {
+		s.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	request.Code = strings.TrimSpace(request.Code)
+	request.ClientID = strings.TrimSpace(request.ClientID)
+	if request.GrantType != "authorization_code" {
+		s.respondError(w, http.StatusBadRequest, "grant_type must be authorization_code")
+		return
+	}
+	if request.Code == "" || request.ClientID == "" {
+		s.respondError(w, http.StatusBadRequest, "code and client_id are required")
+		return
+	}
+
+	redeemed, err := s.store.RedeemOAuthCode(r.Context(), request.Code, time.Now().Unix())
+	if err != nil {
+		if errors.Is(err, indexer.ErrNotFound) || errors.Is(err, indexer.ErrUnauthorized) {
+			s.respondError(w, http.StatusUnauthorized, "invalid, expired, or already-used code")
+			return
+		}
+		s.logger.Error("redeem oauth code failed", "err", err)
+		s.respondError(w, http.StatusInternalServerError, "failed to exchange code")
+		return
+	}
+	if redeemed.ClientID != request.ClientID {
+		s.respondError(w, http.StatusUnauthorized, "client_id mismatch")
+		return
+	}
+
+	minted, err := s.mintAPIToken(r.Context(), redeemed.WalletPubkey, redeemed.Scopes, redeemed.AgentIDs, 0)
+	if err != nil {
+		s.logger.Error("mint oauth token failed", "err", err)
+		s.respondError(w, http.StatusInternalServerError, "failed to exchange code")
+		return
+	}
+
+	s.respondJSON(w, http.StatusOK, oauthTokenResponse{
+		AccessToken: minted.Token,
+		TokenType:   "bearer",
+		ExpiresAt:   minted.ExpiresAt,
+		Scope:       strings.Join(minted.Scopes, " "),
+	})
+}
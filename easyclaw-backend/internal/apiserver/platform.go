@@ -27,20 +27,47 @@ const (
 	authSessionTTL   = 24 * time.Hour
 )
 
+// siwsVersion is the authChallengeRequest.Version value that opts into the
+// CAIP-122/SIWS-style structured challenge. Anything else (including the
+// empty string) gets the legacy free-form message, so existing clients
+// keep working unmodified.
+const siwsVersion = "siws1"
+
 type authChallengeRequest struct {
-	WalletPubkey string `json:"wallet_pubkey"`
-	Intent       string `json:"intent"`
+	WalletPubkey string   `json:"wallet_pubkey"`
+	Intent       string   `json:"intent"`
+	Version      string   `json:"version,omitempty"`
+	Resources    []string `json:"resources,omitempty"`
 }
 
 type authChallengeResponse struct {
 	ChallengeID string `json:"challenge_id"`
 	Message     string `json:"message"`
+	Version     string `json:"version,omitempty"`
 }
 
 type authVerifySignatureRequest struct {
 	ChallengeID  string `json:"challenge_id"`
 	Signature    string `json:"signature"`
 	WalletPubkey string `json:"wallet_pubkey"`
+	// Message is the exact text the wallet signed. Required only for SIWS
+	// challenges: handleAuthVerifySignature parses it and cross-checks every
+	// field against the stored AuthChallengeRecord before trusting it as the
+	// signed payload, since a SIWS message is recoverable from (and must be
+	// verified against) the structured fields rather than assumed to match
+	// the canonical message.
+	Message string `json:"message,omitempty"`
+}
+
+type authVerifyLedgerRequest struct {
+	ChallengeID  string `json:"challenge_id"`
+	Signature    string `json:"signature"`
+	WalletPubkey string `json:"wallet_pubkey"`
+	// Message is the base64-encoded Solana off-chain message: the signing
+	// domain header, version, application domain, format byte, signer list,
+	// and length-prefixed payload that Ledger and other hardware wallets
+	// wrap a signed message in.
+	Message string `json:"message"`
 }
 
 type authTokenResponse struct {
@@ -84,10 +111,20 @@ type killSwitchResponse struct {
 	StoppedCount int `json:"stopped_count"`
 }
 
+type rebalancePreviewRequest struct {
+	AgentID string                  `json:"agent_id"`
+	Period  string                  `json:"period"`
+	Config  indexer.RebalanceConfig `json:"config"`
+}
+
 type patchRiskRequest struct {
 	MaxPositionUSDC    *float64 `json:"max_position_usdc"`
 	DailyLossLimitUSDC *float64 `json:"daily_loss_limit_usdc"`
 	KillSwitchEnabled  *bool    `json:"kill_switch_enabled"`
+	// Fingerprint, if set, is checked against the risk profile's current
+	// fingerprint the same way an If-Match header is; see
+	// expectedFingerprint.
+	Fingerprint string `json:"fingerprint,omitempty"`
 }
 
 type patchRiskResponse struct {
@@ -109,6 +146,20 @@ type patchStrategyRequest struct {
 	Name       *string         `json:"name"`
 	EntryRules *map[string]any `json:"entry_rules"`
 	ExitRules  *map[string]any `json:"exit_rules"`
+	// Fingerprint, if set, is checked against the strategy's current
+	// fingerprint the same way an If-Match header is; see
+	// expectedFingerprint.
+	Fingerprint string `json:"fingerprint,omitempty"`
+}
+
+// expectedFingerprint resolves the If-Match header or a body fingerprint
+// field into the value PatchStrategy/PatchAgentRisk compare-and-swap on,
+// preferring the header when both are present.
+func expectedFingerprint(r *http.Request, bodyFingerprint string) string {
+	if header := strings.TrimSpace(r.Header.Get("If-Match")); header != "" {
+		return strings.Trim(header, `"`)
+	}
+	return bodyFingerprint
 }
 
 type publishStrategyResponse struct {
@@ -129,9 +180,20 @@ type chartCandlesResponse struct {
 	Market      string                 `json:"market"`
 	Timeframe   string                 `json:"timeframe"`
 	IntervalSec int64                  `json:"interval_sec"`
+	HeikinAshi  bool                   `json:"heikin_ashi"`
 	Candles     []indexer.CandleRecord `json:"candles"`
 }
 
+type marketInstrumentsResponse struct {
+	Items []indexer.MarketInstrumentRecord `json:"items"`
+}
+
+type fundingHistoryResponse struct {
+	Items  []indexer.FundingRecord `json:"items"`
+	Limit  int                     `json:"limit"`
+	Offset int                     `json:"offset"`
+}
+
 type websocketSubscribeRequest struct {
 	Type    string `json:"type"`
 	Channel string `json:"channel"`
@@ -185,17 +247,47 @@ func (s *Service) handleAuthChallenge(w http.ResponseWriter, r *http.Request) {
 		s.respondError(w, http.StatusInternalServerError, "failed to create challenge")
 		return
 	}
-	message := fmt.Sprintf("Easyclaw authentication\nintent: %s\nchallenge_id: %s\nwallet: %s\nexpires_at: %d", request.Intent, challengeID, request.WalletPubkey, expiresAt)
 
-	err = s.store.CreateAuthChallenge(r.Context(), indexer.AuthChallengeRecord{
+	record := indexer.AuthChallengeRecord{
 		ID:           challengeID,
 		WalletPubkey: request.WalletPubkey,
 		Intent:       request.Intent,
-		Message:      message,
 		CreatedAt:    now,
 		ExpiresAt:    expiresAt,
-	})
-	if err != nil {
+	}
+
+	var message string
+	if request.Version == siwsVersion {
+		nonce, err := randomNonce()
+		if err != nil {
+			s.logger.Error("create challenge nonce failed", "err", err)
+			s.respondError(w, http.StatusInternalServerError, "failed to create challenge")
+			return
+		}
+		message = buildSIWSMessage(siwsMessage{
+			Domain:         s.cfg.AuthDomain,
+			Address:        request.WalletPubkey,
+			Statement:      fmt.Sprintf("Sign in to Easyclaw to authorize: %s.", request.Intent),
+			URI:            s.cfg.AuthURI,
+			ChainID:        s.cfg.AuthChainID,
+			Nonce:          nonce,
+			IssuedAt:       formatUnixTime(now),
+			ExpirationTime: formatUnixTime(expiresAt),
+			NotBefore:      formatUnixTime(now),
+			RequestID:      challengeID,
+			Resources:      request.Resources,
+		})
+		record.Version = siwsVersion
+		record.Domain = s.cfg.AuthDomain
+		record.Nonce = nonce
+		record.ChainID = s.cfg.AuthChainID
+		record.RequestID = challengeID
+	} else {
+		message = fmt.Sprintf("Easyclaw authentication\nintent: %s\nchallenge_id: %s\nwallet: %s\nexpires_at: %d", request.Intent, challengeID, request.WalletPubkey, expiresAt)
+	}
+	record.Message = message
+
+	if err := s.store.CreateAuthChallenge(r.Context(), record); err != nil {
 		s.logger.Error("store auth challenge failed", "err", err)
 		s.respondError(w, http.StatusInternalServerError, "failed to create challenge")
 		return
@@ -204,6 +296,7 @@ func (s *Service) handleAuthChallenge(w http.ResponseWriter, r *http.Request) {
 	s.respondJSON(w, http.StatusOK, authChallengeResponse{
 		ChallengeID: challengeID,
 		Message:     message,
+		Version:     record.Version,
 	})
 }
 
@@ -251,17 +344,134 @@ func (s *Service) handleAuthVerifySignature(w http.ResponseWriter, r *http.Reque
 		s.respondError(w, http.StatusUnauthorized, "wallet mismatch")
 		return
 	}
-	if err := verifyWalletSignature(request.WalletPubkey, request.Signature, challenge.Message); err != nil {
+
+	signedMessage := challenge.Message
+	if challenge.Version == siwsVersion {
+		request.Message = strings.TrimSpace(request.Message)
+		if request.Message == "" {
+			s.respondError(w, http.StatusBadRequest, "message is required")
+			return
+		}
+		parsed, err := parseSIWSMessage(request.Message)
+		if err != nil {
+			s.respondError(w, http.StatusUnauthorized, "invalid message")
+			return
+		}
+		if err := verifySIWSChallengeFields(parsed, challenge, request.WalletPubkey, now); err != nil {
+			s.respondError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		signedMessage = request.Message
+	}
+	if err := verifyWalletSignature(request.WalletPubkey, request.Signature, signedMessage); err != nil {
 		s.respondError(w, http.StatusUnauthorized, "invalid signature")
 		return
 	}
 
-	if err := s.store.MarkAuthChallengeUsed(r.Context(), request.ChallengeID, now); err != nil {
-		s.logger.Error("mark challenge used failed", "err", err)
+	token, tokenHash, err := newSessionToken()
+	if err != nil {
+		s.logger.Error("create session token failed", "err", err)
+		s.respondError(w, http.StatusInternalServerError, "failed to create session")
+		return
+	}
+	expiresAt := now + int64(authSessionTTL/time.Second)
+	if _, err := s.store.FinalizeAuthChallenge(r.Context(), request.ChallengeID, request.WalletPubkey, tokenHash, now, expiresAt); err != nil {
+		if errors.Is(err, indexer.ErrUnauthorized) {
+			s.respondError(w, http.StatusUnauthorized, "challenge already used or expired")
+			return
+		}
+		s.logger.Error("finalize challenge failed", "err", err)
 		s.respondError(w, http.StatusInternalServerError, "failed to finalize challenge")
 		return
 	}
 
+	s.respondJSON(w, http.StatusOK, authTokenResponse{SessionToken: token, ExpiresAt: expiresAt})
+}
+
+// handleAuthVerifyLedger is the Ledger/hardware-wallet counterpart to
+// handleAuthVerifySignature: the device can't sign arbitrary text directly,
+// so the wallet adapter wraps the challenge message in the Solana
+// off-chain-message header before presenting it for signing, and returns
+// that full wrapped payload rather than the bare message.
+func (s *Service) handleAuthVerifyLedger(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.respondMethodNotAllowed(w)
+		return
+	}
+
+	var request authVerifyLedgerRequest
+	if err := decodeJSONBody(r, &request); err != nil {
+		s.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	request.ChallengeID = strings.TrimSpace(request.ChallengeID)
+	request.Signature = strings.TrimSpace(request.Signature)
+	request.WalletPubkey = strings.TrimSpace(request.WalletPubkey)
+	request.Message = strings.TrimSpace(request.Message)
+	if request.ChallengeID == "" || request.Signature == "" || request.WalletPubkey == "" || request.Message == "" {
+		s.respondError(w, http.StatusBadRequest, "challenge_id, signature, wallet_pubkey, message are required")
+		return
+	}
+
+	wrapped, err := base64.StdEncoding.DecodeString(request.Message)
+	if err != nil {
+		s.respondError(w, http.StatusBadRequest, "message must be base64-encoded")
+		return
+	}
+	inner, err := parseSolanaOffchainMessage(wrapped)
+	if err != nil {
+		s.respondError(w, http.StatusBadRequest, fmt.Sprintf("invalid off-chain message: %v", err))
+		return
+	}
+
+	challenge, err := s.store.GetAuthChallenge(r.Context(), request.ChallengeID)
+	if err != nil {
+		if errors.Is(err, indexer.ErrNotFound) {
+			s.respondError(w, http.StatusNotFound, "challenge not found")
+			return
+		}
+		s.logger.Error("get ledger challenge failed", "err", err)
+		s.respondError(w, http.StatusInternalServerError, "failed to verify challenge")
+		return
+	}
+
+	now := time.Now().Unix()
+	if challenge.ExpiresAt <= now {
+		s.respondError(w, http.StatusUnauthorized, "challenge expired")
+		return
+	}
+	if challenge.UsedAt != nil {
+		s.respondError(w, http.StatusUnauthorized, "challenge already used")
+		return
+	}
+	if challenge.WalletPubkey != request.WalletPubkey {
+		s.respondError(w, http.StatusUnauthorized, "wallet mismatch")
+		return
+	}
+
+	if challenge.Version == siwsVersion {
+		parsed, err := parseSIWSMessage(string(inner))
+		if err != nil {
+			s.respondError(w, http.StatusUnauthorized, "invalid message")
+			return
+		}
+		if err := verifySIWSChallengeFields(parsed, challenge, request.WalletPubkey, now); err != nil {
+			s.respondError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+	} else if string(inner) != challenge.Message {
+		s.respondError(w, http.StatusUnauthorized, "message mismatch")
+		return
+	}
+
+	// The device signs the full wrapped payload, header included, not just
+	// the inner message text.
+	if err := verifyWalletSignature(request.WalletPubkey, request.Signature, string(wrapped)); err != nil {
+		s.respondError(w, http.StatusUnauthorized, "invalid signature")
+		return
+	}
+
 	token, tokenHash, err := newSessionToken()
 	if err != nil {
 		s.logger.Error("create session token failed", "err", err)
@@ -269,9 +479,13 @@ func (s *Service) handleAuthVerifySignature(w http.ResponseWriter, r *http.Reque
 		return
 	}
 	expiresAt := now + int64(authSessionTTL/time.Second)
-	if err := s.store.CreateAuthSession(r.Context(), tokenHash, request.WalletPubkey, now, expiresAt); err != nil {
-		s.logger.Error("store session failed", "err", err)
-		s.respondError(w, http.StatusInternalServerError, "failed to create session")
+	if _, err := s.store.FinalizeAuthChallenge(r.Context(), request.ChallengeID, request.WalletPubkey, tokenHash, now, expiresAt); err != nil {
+		if errors.Is(err, indexer.ErrUnauthorized) {
+			s.respondError(w, http.StatusUnauthorized, "challenge already used or expired")
+			return
+		}
+		s.logger.Error("finalize ledger challenge failed", "err", err)
+		s.respondError(w, http.StatusInternalServerError, "failed to finalize challenge")
 		return
 	}
 
@@ -485,7 +699,7 @@ func (s *Service) handleAgentsSubroutes(w http.ResponseWriter, r *http.Request)
 			s.respondMethodNotAllowed(w)
 			return
 		}
-		session, err := s.requireSession(r)
+		principal, err := s.requireScope(r, scopeAgentsWrite, agentID)
 		if err != nil {
 			s.respondError(w, http.StatusUnauthorized, err.Error())
 			return
@@ -506,7 +720,7 @@ func (s *Service) handleAgentsSubroutes(w http.ResponseWriter, r *http.Request)
 			ID:        sessionID,
 			AgentID:   agentID,
 			Mode:      request.Mode,
-			CreatedBy: session.WalletPubkey,
+			CreatedBy: principal.WalletPubkey,
 			StartedAt: now,
 		})
 		if err != nil {
@@ -530,7 +744,7 @@ func (s *Service) handleAgentsSubroutes(w http.ResponseWriter, r *http.Request)
 			s.respondMethodNotAllowed(w)
 			return
 		}
-		if _, err := s.requireSession(r); err != nil {
+		if _, err := s.requireScope(r, scopeAgentsWrite, agentID); err != nil {
 			s.respondError(w, http.StatusUnauthorized, err.Error())
 			return
 		}
@@ -564,9 +778,10 @@ func (s *Service) handleAgentsSubroutes(w http.ResponseWriter, r *http.Request)
 				s.respondError(w, http.StatusInternalServerError, "failed to get risk")
 				return
 			}
+			w.Header().Set("ETag", indexer.AgentRiskFingerprint(risk))
 			s.respondJSON(w, http.StatusOK, risk)
 		case http.MethodPatch:
-			if _, err := s.requireSession(r); err != nil {
+			if _, err := s.requireScope(r, scopeAgentsWrite, agentID); err != nil {
 				s.respondError(w, http.StatusUnauthorized, err.Error())
 				return
 			}
@@ -576,20 +791,26 @@ func (s *Service) handleAgentsSubroutes(w http.ResponseWriter, r *http.Request)
 				return
 			}
 			updated, err := s.store.PatchAgentRisk(r.Context(), agentID, indexer.RiskPatch{
-				MaxPositionUSDC:    request.MaxPositionUSDC,
-				DailyLossLimitUSDC: request.DailyLossLimitUSDC,
-				KillSwitchEnabled:  request.KillSwitchEnabled,
-				UpdatedAt:          time.Now().Unix(),
+				MaxPositionUSDC:     request.MaxPositionUSDC,
+				DailyLossLimitUSDC:  request.DailyLossLimitUSDC,
+				KillSwitchEnabled:   request.KillSwitchEnabled,
+				UpdatedAt:           time.Now().Unix(),
+				ExpectedFingerprint: expectedFingerprint(r, request.Fingerprint),
 			})
 			if err != nil {
 				if errors.Is(err, indexer.ErrNotFound) {
 					s.respondError(w, http.StatusNotFound, "agent not found")
 					return
 				}
+				if errors.Is(err, indexer.ErrPreconditionFailed) {
+					s.respondError(w, http.StatusPreconditionFailed, "risk profile was modified since it was last read")
+					return
+				}
 				s.logger.Error("patch agent risk failed", "err", err)
 				s.respondError(w, http.StatusInternalServerError, "failed to patch risk")
 				return
 			}
+			w.Header().Set("ETag", indexer.AgentRiskFingerprint(updated))
 			s.respondJSON(w, http.StatusOK, patchRiskResponse{UpdatedRiskProfile: updated})
 		default:
 			s.respondMethodNotAllowed(w)
@@ -605,7 +826,7 @@ func (s *Service) handleKillSwitch(w http.ResponseWriter, r *http.Request) {
 		s.respondMethodNotAllowed(w)
 		return
 	}
-	if _, err := s.requireSession(r); err != nil {
+	if _, err := s.requireScope(r, scopeKillSwitch, ""); err != nil {
 		s.respondError(w, http.StatusUnauthorized, err.Error())
 		return
 	}
@@ -728,6 +949,7 @@ func (s *Service) handleStrategiesSubroutes(w http.ResponseWriter, r *http.Reque
 				s.respondError(w, http.StatusInternalServerError, "failed to get strategy")
 				return
 			}
+			w.Header().Set("ETag", indexer.StrategyFingerprint(strategy))
 			s.respondJSON(w, http.StatusOK, strategy)
 		case http.MethodPatch:
 			if _, err := s.requireSession(r); err != nil {
@@ -740,26 +962,32 @@ func (s *Service) handleStrategiesSubroutes(w http.ResponseWriter, r *http.Reque
 				return
 			}
 			updated, err := s.store.PatchStrategy(r.Context(), strategyID, indexer.StrategyPatch{
-				Name:       request.Name,
-				EntryRules: request.EntryRules,
-				ExitRules:  request.ExitRules,
-				UpdatedAt:  time.Now().Unix(),
+				Name:                request.Name,
+				EntryRules:          request.EntryRules,
+				ExitRules:           request.ExitRules,
+				UpdatedAt:           time.Now().Unix(),
+				ExpectedFingerprint: expectedFingerprint(r, request.Fingerprint),
 			})
 			if err != nil {
 				if errors.Is(err, indexer.ErrNotFound) {
 					s.respondError(w, http.StatusNotFound, "strategy not found")
 					return
 				}
+				if errors.Is(err, indexer.ErrPreconditionFailed) {
+					s.respondError(w, http.StatusPreconditionFailed, "strategy was modified since it was last read")
+					return
+				}
 				s.logger.Error("patch strategy failed", "err", err)
 				s.respondError(w, http.StatusInternalServerError, "failed to patch strategy")
 				return
 			}
+			w.Header().Set("ETag", indexer.StrategyFingerprint(updated))
 			s.respondJSON(w, http.StatusOK, updated)
 		default:
 			s.respondMethodNotAllowed(w)
 		}
 		return
-	case tail == "publish":
+	case tail == "locked-edit":
 		if r.Method != http.MethodPost {
 			s.respondMethodNotAllowed(w)
 			return
@@ -768,6 +996,50 @@ func (s *Service) handleStrategiesSubroutes(w http.ResponseWriter, r *http.Reque
 			s.respondError(w, http.StatusUnauthorized, err.Error())
 			return
 		}
+		var request patchStrategyRequest
+		if err := decodeJSONBody(r, &request); err != nil {
+			s.respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		now := time.Now().Unix()
+		updated, err := s.store.DoLockedStrategyEdit(r.Context(), strategyID, expectedFingerprint(r, request.Fingerprint), func(current indexer.StrategyRecord) (indexer.StrategyRecord, error) {
+			if request.Name != nil {
+				current.Name = strings.TrimSpace(*request.Name)
+			}
+			if request.EntryRules != nil {
+				current.EntryRules = *request.EntryRules
+			}
+			if request.ExitRules != nil {
+				current.ExitRules = *request.ExitRules
+			}
+			current.UpdatedAt = now
+			return current, nil
+		})
+		if err != nil {
+			if errors.Is(err, indexer.ErrNotFound) {
+				s.respondError(w, http.StatusNotFound, "strategy not found")
+				return
+			}
+			if errors.Is(err, indexer.ErrPreconditionFailed) {
+				s.respondError(w, http.StatusPreconditionFailed, "strategy was modified since it was last read")
+				return
+			}
+			s.logger.Error("locked edit strategy failed", "err", err)
+			s.respondError(w, http.StatusInternalServerError, "failed to edit strategy")
+			return
+		}
+		w.Header().Set("ETag", indexer.StrategyFingerprint(updated))
+		s.respondJSON(w, http.StatusOK, updated)
+		return
+	case tail == "publish":
+		if r.Method != http.MethodPost {
+			s.respondMethodNotAllowed(w)
+			return
+		}
+		if _, err := s.requireScope(r, scopeStrategiesPublish, ""); err != nil {
+			s.respondError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
 		publishedAt, err := s.store.PublishStrategy(r.Context(), strategyID, time.Now().Unix())
 		if err != nil {
 			if errors.Is(err, indexer.ErrNotFound) {
@@ -785,15 +1057,36 @@ func (s *Service) handleStrategiesSubroutes(w http.ResponseWriter, r *http.Reque
 	}
 }
 
+// periodQueryParam reads a handler's period/from/to/tz query params and
+// folds them into the single composite period string
+// indexer.portfolioPeriodStart/leaderboardPeriodStart already know how to
+// parse (e.g. "from:1700000000..to:1700086400" or "today@America/New_York"),
+// so an explicit range or a tz-aligned calendar key can be requested
+// without Store methods needing separate from/to/tz parameters of their
+// own. from/to take precedence over period when both are given.
+func periodQueryParam(r *http.Request) string {
+	query := r.URL.Query()
+	from := strings.TrimSpace(query.Get("from"))
+	to := strings.TrimSpace(query.Get("to"))
+	if from != "" || to != "" {
+		return fmt.Sprintf("from:%s..to:%s", from, to)
+	}
+	period := strings.TrimSpace(query.Get("period"))
+	if period == "" {
+		period = "7d"
+	}
+	if tz := strings.TrimSpace(query.Get("tz")); tz != "" {
+		period += "@" + tz
+	}
+	return period
+}
+
 func (s *Service) handlePortfolio(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		s.respondMethodNotAllowed(w)
 		return
 	}
-	period := strings.TrimSpace(r.URL.Query().Get("period"))
-	if period == "" {
-		period = "7d"
-	}
+	period := periodQueryParam(r)
 	summary, err := s.store.GetPortfolioSummary(r.Context(), period)
 	if err != nil {
 		if strings.Contains(err.Error(), "invalid period") {
@@ -818,10 +1111,7 @@ func (s *Service) handlePortfolioAgent(w http.ResponseWriter, r *http.Request) {
 		s.respondError(w, http.StatusBadRequest, "agent id is required")
 		return
 	}
-	period := strings.TrimSpace(r.URL.Query().Get("period"))
-	if period == "" {
-		period = "7d"
-	}
+	period := periodQueryParam(r)
 	result, err := s.store.GetAgentPortfolioSummary(r.Context(), agentID, period)
 	if err != nil {
 		if strings.Contains(err.Error(), "invalid period") {
@@ -839,6 +1129,42 @@ func (s *Service) handlePortfolioAgent(w http.ResponseWriter, r *http.Request) {
 	s.respondJSON(w, http.StatusOK, result)
 }
 
+// handlePortfolioRebalance previews what indexer.RebalanceConfig's target
+// weights would currently suggest trading, given fills since period -
+// read-only, same as handlePortfolio, so it takes a body for the weights
+// but requires no scope. It doesn't place orders or run on a schedule
+// itself; config.IntervalSec is metadata for whatever polls this endpoint.
+func (s *Service) handlePortfolioRebalance(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.respondMethodNotAllowed(w)
+		return
+	}
+	var request rebalancePreviewRequest
+	if err := decodeJSONBody(r, &request); err != nil {
+		s.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	period := strings.TrimSpace(request.Period)
+	if period == "" {
+		period = "7d"
+	}
+	result, err := s.store.PreviewRebalance(r.Context(), strings.TrimSpace(request.AgentID), request.Config, period)
+	if err != nil {
+		if strings.Contains(err.Error(), "invalid period") {
+			s.respondError(w, http.StatusBadRequest, "period must be 7d, 30d, or all")
+			return
+		}
+		if strings.HasPrefix(err.Error(), "rebalance:") {
+			s.respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		s.logger.Error("preview rebalance failed", "err", err)
+		s.respondError(w, http.StatusInternalServerError, "failed to preview rebalance")
+		return
+	}
+	s.respondJSON(w, http.StatusOK, result)
+}
+
 func (s *Service) handleChartCandles(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		s.respondMethodNotAllowed(w)
@@ -850,7 +1176,7 @@ func (s *Service) handleChartCandles(w http.ResponseWriter, r *http.Request) {
 		market = "BTCUSDT"
 	}
 
-	timeframe, intervalSec, err := parseChartTimeframe(r.URL.Query().Get("timeframe"))
+	timeframe, intervalSec, err := s.resolveChartTimeframe(r.Context(), market, r.URL.Query().Get("timeframe"))
 	if err != nil {
 		s.respondError(w, http.StatusBadRequest, err.Error())
 		return
@@ -861,22 +1187,146 @@ func (s *Service) handleChartCandles(w http.ResponseWriter, r *http.Request) {
 		s.respondError(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	heikinAshi, _ := strconv.ParseBool(r.URL.Query().Get("heikin_ashi"))
 
-	candles, err := s.store.GetMarketCandles(r.Context(), market, intervalSec, limit)
+	candles, err := s.store.GetMarketCandles(r.Context(), market, intervalSec, limit, nil)
 	if err != nil {
 		s.logger.Error("get market candles failed", "market", market, "timeframe", timeframe, "err", err)
 		s.respondError(w, http.StatusInternalServerError, "failed to load candles")
 		return
 	}
+	if heikinAshi {
+		candles = indexer.ToHeikinAshi(candles)
+	}
 
 	s.respondJSON(w, http.StatusOK, chartCandlesResponse{
 		Market:      market,
 		Timeframe:   timeframe,
 		IntervalSec: intervalSec,
+		HeikinAshi:  heikinAshi,
 		Candles:     candles,
 	})
 }
 
+// resolveChartTimeframe canonicalizes raw the way parseChartTimeframe
+// always has, then checks it against market's configured instrument (if
+// any) so a market that only publishes, say, 1h/4h/1d candles can't be
+// asked for 1m bars it has no data for. Markets without an instrument row
+// keep accepting the full hardcoded set, so this never breaks a market
+// nobody has configured yet.
+func (s *Service) resolveChartTimeframe(ctx context.Context, market, raw string) (string, int64, error) {
+	timeframe, intervalSec, err := parseChartTimeframe(raw)
+	if err != nil {
+		return "", 0, err
+	}
+
+	instrument, err := s.store.GetMarketInstrument(ctx, market)
+	if err != nil {
+		if errors.Is(err, indexer.ErrNotFound) {
+			return timeframe, intervalSec, nil
+		}
+		return "", 0, err
+	}
+	for _, supported := range instrument.SupportedIntervals {
+		if supported == timeframe {
+			return timeframe, intervalSec, nil
+		}
+	}
+	return "", 0, fmt.Errorf("timeframe %s is not supported for market %s", timeframe, market)
+}
+
+func (s *Service) handleMarketsRoot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.respondMethodNotAllowed(w)
+		return
+	}
+	items, err := s.store.ListMarketInstruments(r.Context())
+	if err != nil {
+		s.logger.Error("list market instruments failed", "err", err)
+		s.respondError(w, http.StatusInternalServerError, "failed to list markets")
+		return
+	}
+	s.respondJSON(w, http.StatusOK, marketInstrumentsResponse{Items: items})
+}
+
+func (s *Service) handleMarketsSubroutes(w http.ResponseWriter, r *http.Request) {
+	symbol, tail := splitMarketsSubroute(r.URL.Path)
+	if symbol == "" {
+		s.respondError(w, http.StatusNotFound, "market symbol is required")
+		return
+	}
+	market := indexer.NormalizeMarketSymbol(symbol)
+
+	switch {
+	case tail == "":
+		if r.Method != http.MethodGet {
+			s.respondMethodNotAllowed(w)
+			return
+		}
+		instrument, err := s.store.GetMarketInstrument(r.Context(), market)
+		if err != nil {
+			if errors.Is(err, indexer.ErrNotFound) {
+				s.respondError(w, http.StatusNotFound, "market not found")
+				return
+			}
+			s.logger.Error("get market instrument failed", "market", market, "err", err)
+			s.respondError(w, http.StatusInternalServerError, "failed to load market")
+			return
+		}
+		s.respondJSON(w, http.StatusOK, instrument)
+	case tail == "funding":
+		if r.Method != http.MethodGet {
+			s.respondMethodNotAllowed(w)
+			return
+		}
+		from, err := parseOptionalInt64(r, "from", 0)
+		if err != nil {
+			s.respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		to, err := parseOptionalInt64(r, "to", 0)
+		if err != nil {
+			s.respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if from != 0 && to != 0 && from > to {
+			s.respondError(w, http.StatusBadRequest, "from must be <= to")
+			return
+		}
+		limit, err := parseOptionalInt(r, "limit", 0)
+		if err != nil {
+			s.respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		items, normalizedLimit, normalizedOffset, err := s.store.ListFundingHistory(r.Context(), market, indexer.FundingFilter{
+			FromUnix: from,
+			ToUnix:   to,
+			Limit:    limit,
+		})
+		if err != nil {
+			s.logger.Error("list funding history failed", "market", market, "err", err)
+			s.respondError(w, http.StatusInternalServerError, "failed to load funding history")
+			return
+		}
+		s.respondJSON(w, http.StatusOK, fundingHistoryResponse{Items: items, Limit: normalizedLimit, Offset: normalizedOffset})
+	default:
+		s.respondError(w, http.StatusNotFound, "unknown market subroute")
+	}
+}
+
+func splitMarketsSubroute(path string) (string, string) {
+	trimmed := strings.Trim(strings.TrimPrefix(path, "/v1/markets/"), "/")
+	if trimmed == "" {
+		return "", ""
+	}
+	segments := strings.Split(trimmed, "/")
+	symbol := strings.TrimSpace(segments[0])
+	if len(segments) == 1 {
+		return symbol, ""
+	}
+	return symbol, strings.Join(segments[1:], "/")
+}
+
 func (s *Service) handleTrades(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		s.respondMethodNotAllowed(w)
@@ -929,16 +1379,35 @@ func (s *Service) handleLeaderboard(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	metric := strings.TrimSpace(r.URL.Query().Get("metric"))
-	period := strings.TrimSpace(r.URL.Query().Get("period"))
-	if period == "" {
-		period = "7d"
-	}
+	period := periodQueryParam(r)
 	minTrades, err := parseOptionalInt(r, "min_trades", 20)
 	if err != nil {
 		s.respondError(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	items, err := s.store.GetLeaderboard(r.Context(), metric, period, minTrades)
+	minActiveDays, err := parseOptionalInt(r, "min_active_days", 0)
+	if err != nil {
+		s.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	weights, err := indexer.ParseLeaderboardWeights(r.URL.Query().Get("weights"))
+	if err != nil {
+		s.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var excludeAgents []string
+	if raw := strings.TrimSpace(r.URL.Query().Get("exclude_agents")); raw != "" {
+		excludeAgents = strings.Split(raw, ",")
+	}
+
+	result, err := s.store.GetLeaderboard(r.Context(), indexer.LeaderboardFilter{
+		Metric:        metric,
+		Period:        period,
+		MinTrades:     minTrades,
+		MinActiveDays: minActiveDays,
+		ExcludeAgents: excludeAgents,
+		Weights:       weights,
+	})
 	if err != nil {
 		if strings.Contains(err.Error(), "invalid") {
 			s.respondError(w, http.StatusBadRequest, "invalid metric or period")
@@ -948,7 +1417,8 @@ func (s *Service) handleLeaderboard(w http.ResponseWriter, r *http.Request) {
 		s.respondError(w, http.StatusInternalServerError, "failed to load leaderboard")
 		return
 	}
-	s.respondJSON(w, http.StatusOK, leaderboardResponse{Items: items})
+	w.Header().Set("ETag", result.Fingerprint)
+	s.respondJSON(w, http.StatusOK, leaderboardResponse{Items: result.Items})
 }
 
 func (s *Service) handleSystemStatus(w http.ResponseWriter, r *http.Request) {
@@ -965,6 +1435,12 @@ func (s *Service) handleSystemStatus(w http.ResponseWriter, r *http.Request) {
 	s.respondJSON(w, http.StatusOK, status)
 }
 
+// websocketPingInterval is how often the server sends a control ping frame
+// down every connection, independent of the 90-second read-deadline/pong
+// handling in websocketReadLoop: that only keeps the deadline alive when
+// the client pongs on its own initiative, it never prompts one.
+const websocketPingInterval = 30 * time.Second
+
 func (s *Service) handleWebsocket(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		s.respondMethodNotAllowed(w)
@@ -987,11 +1463,16 @@ func (s *Service) handleWebsocket(w http.ResponseWriter, r *http.Request) {
 	defer cancel()
 
 	subs := newSubscriptionSet()
+	rpcSubs := newJSONRPCSubscriptionSet()
+	connHub := newConnectionHub(s.hub)
+	defer connHub.closeAll()
+
+	writes := make(chan []byte, 16)
 	readErrCh := make(chan error, 1)
-	go s.websocketReadLoop(ctx, conn, subs, readErrCh)
+	go s.websocketReadLoop(ctx, conn, subs, rpcSubs, connHub, writes, readErrCh)
 
-	ticker := time.NewTicker(2 * time.Second)
-	defer ticker.Stop()
+	pingTicker := time.NewTicker(websocketPingInterval)
+	defer pingTicker.Stop()
 
 	for {
 		select {
@@ -1002,18 +1483,45 @@ func (s *Service) handleWebsocket(w http.ResponseWriter, r *http.Request) {
 				s.logger.Debug("websocket read loop ended", "err", err)
 			}
 			return
-		case <-ticker.C:
-			channels := subs.List()
-			for _, channel := range channels {
-				payload, err := s.getWebsocketPayload(ctx, channel)
-				if err != nil {
-					_ = writeWebsocketJSON(conn, websocketEnvelope{Type: "error", Channel: channel, Error: "failed to fetch channel data", TS: time.Now().Unix()})
-					continue
+		case payload := <-writes:
+			if err := writeWebsocketRaw(conn, payload); err != nil {
+				return
+			}
+		case <-pingTicker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second)); err != nil {
+				return
+			}
+		case event := <-connHub.events:
+			legacyChannels := subs.List()
+			legacySet := make(map[string]struct{}, len(legacyChannels))
+			for _, channel := range legacyChannels {
+				legacySet[channel] = struct{}{}
+			}
+			rpcByChannel := rpcSubs.ByChannel()
+
+			if event.Err != nil {
+				if _, ok := legacySet[event.Channel]; ok {
+					_ = writeWebsocketJSON(conn, websocketEnvelope{Type: "error", Channel: event.Channel, Error: "failed to fetch channel data", TS: time.Now().Unix()})
+				} else {
+					s.logger.Warn("websocket hub channel fetch failed", "channel", event.Channel, "err", event.Err)
+				}
+				continue
+			}
+			if event.Data == nil {
+				continue
+			}
+			if _, ok := legacySet[event.Channel]; ok {
+				if err := writeWebsocketJSON(conn, websocketEnvelope{Type: "event", Channel: event.Channel, Data: event.Data, TS: time.Now().Unix()}); err != nil {
+					return
 				}
-				if payload == nil {
+			}
+			for _, subscriptionID := range rpcByChannel[event.Channel] {
+				notification, err := newJSONRPCNotification(subscriptionID, event.Data)
+				if err != nil {
+					s.logger.Error("failed to marshal jsonrpc notification", "channel", event.Channel, "err", err)
 					continue
 				}
-				if err := writeWebsocketJSON(conn, websocketEnvelope{Type: "event", Channel: channel, Data: payload, TS: time.Now().Unix()}); err != nil {
+				if err := writeWebsocketRaw(conn, notification); err != nil {
 					return
 				}
 			}
@@ -1021,7 +1529,15 @@ func (s *Service) handleWebsocket(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (s *Service) websocketReadLoop(ctx context.Context, conn *websocket.Conn, subs *subscriptionSet, readErrCh chan<- error) {
+func (s *Service) websocketReadLoop(
+	ctx context.Context,
+	conn *websocket.Conn,
+	subs *subscriptionSet,
+	rpcSubs *jsonrpcSubscriptionSet,
+	connHub *connectionHub,
+	writes chan<- []byte,
+	readErrCh chan<- error,
+) {
 	conn.SetReadLimit(1024 * 1024)
 	if err := conn.SetReadDeadline(time.Now().Add(90 * time.Second)); err == nil {
 		conn.SetPongHandler(func(string) error {
@@ -1035,11 +1551,27 @@ func (s *Service) websocketReadLoop(ctx context.Context, conn *websocket.Conn, s
 			return
 		default:
 		}
-		var message websocketSubscribeRequest
-		if err := conn.ReadJSON(&message); err != nil {
+		var raw json.RawMessage
+		if err := conn.ReadJSON(&raw); err != nil {
 			readErrCh <- err
 			return
 		}
+
+		if isJSONRPCRequest(raw) {
+			response := s.handleJSONRPCMessage(ctx, raw, rpcSubs)
+			resyncHubSubscriptions(subs, rpcSubs, connHub)
+			select {
+			case writes <- response:
+			case <-ctx.Done():
+				return
+			}
+			continue
+		}
+
+		var message websocketSubscribeRequest
+		if err := json.Unmarshal(raw, &message); err != nil {
+			continue
+		}
 		message.Type = strings.ToLower(strings.TrimSpace(message.Type))
 		message.Channel = strings.TrimSpace(message.Channel)
 		if message.Channel == "" {
@@ -1051,7 +1583,19 @@ func (s *Service) websocketReadLoop(ctx context.Context, conn *websocket.Conn, s
 		case "unsubscribe":
 			subs.Remove(message.Channel)
 		}
+		resyncHubSubscriptions(subs, rpcSubs, connHub)
+	}
+}
+
+// handleWebsocketStats reports each active hub channel's subscriber count
+// and cumulative backpressure drop count, for operators watching whether
+// any channel's poll loop is outpacing its slowest subscriber.
+func (s *Service) handleWebsocketStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.respondMethodNotAllowed(w)
+		return
 	}
+	s.respondJSON(w, http.StatusOK, s.hub.Stats())
 }
 
 func (s *Service) getWebsocketPayload(ctx context.Context, channel string) (any, error) {
@@ -1068,11 +1612,31 @@ func (s *Service) getWebsocketPayload(ctx context.Context, channel string) (any,
 		return price, nil
 	case strings.HasPrefix(channel, "chart.ticks."):
 		market := strings.TrimSpace(strings.TrimPrefix(channel, "chart.ticks."))
-		candles, err := s.store.GetCandles(ctx, market, 120)
+		candles, err := s.store.GetCandles(ctx, market, 60, 120)
 		if err != nil {
 			return nil, err
 		}
 		return map[string]any{"market": market, "candles": candles}, nil
+	case strings.HasPrefix(channel, "market.instruments."):
+		market := indexer.NormalizeMarketSymbol(strings.TrimPrefix(channel, "market.instruments."))
+		instrument, err := s.store.GetMarketInstrument(ctx, market)
+		if err != nil {
+			if errors.Is(err, indexer.ErrNotFound) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		return instrument, nil
+	case strings.HasPrefix(channel, "funding."):
+		market := indexer.NormalizeMarketSymbol(strings.TrimPrefix(channel, "funding."))
+		funding, err := s.store.GetLatestFunding(ctx, market)
+		if err != nil {
+			if errors.Is(err, indexer.ErrNotFound) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		return funding, nil
 	case channel == "agent.signals":
 		trades, _, _, err := s.store.ListTrades(ctx, indexer.TradeFilter{Limit: 128, Offset: 0})
 		if err != nil {
@@ -1125,12 +1689,19 @@ func (s *Service) getWebsocketPayload(ctx context.Context, channel string) (any,
 			"total_pnl":    summary.TotalPNL,
 		}, nil
 	case channel == "leaderboard.updates":
-		items, err := s.store.GetLeaderboard(ctx, "pnl_pct", "7d", 20)
+		result, err := s.store.GetLeaderboard(ctx, indexer.LeaderboardFilter{Metric: "pnl_pct", Period: "7d", MinTrades: 20})
 		if err != nil {
 			return nil, err
 		}
-		rankChanges := make([]map[string]any, 0, len(items))
-		for _, item := range items {
+		s.leaderboardPushMu.Lock()
+		unchanged := result.Fingerprint == s.lastLeaderboardHash
+		s.lastLeaderboardHash = result.Fingerprint
+		s.leaderboardPushMu.Unlock()
+		if unchanged {
+			return nil, nil
+		}
+		rankChanges := make([]map[string]any, 0, len(result.Items))
+		for _, item := range result.Items {
 			if item.RankChange == 0 {
 				continue
 			}
@@ -1140,7 +1711,7 @@ func (s *Service) getWebsocketPayload(ctx context.Context, channel string) (any,
 				"new_rank": item.Rank,
 			})
 		}
-		return map[string]any{"rank_changes": rankChanges}, nil
+		return map[string]any{"rank_changes": rankChanges, "fingerprint": result.Fingerprint}, nil
 	case channel == "system.status":
 		status, err := s.store.GetSystemStatus(ctx)
 		if err != nil {
@@ -1357,6 +1928,16 @@ func writeWebsocketJSON(conn *websocket.Conn, payload websocketEnvelope) error {
 	return conn.WriteJSON(payload)
 }
 
+// writeWebsocketRaw writes an already-marshaled frame, used for JSON-RPC
+// responses/notifications so this package's single jsonrpcResponse type
+// doesn't need a websocketEnvelope-shaped wrapper.
+func writeWebsocketRaw(conn *websocket.Conn, payload []byte) error {
+	if err := conn.SetWriteDeadline(time.Now().Add(10 * time.Second)); err != nil {
+		return err
+	}
+	return conn.WriteMessage(websocket.TextMessage, payload)
+}
+
 type subscriptionSet struct {
 	mu    sync.RWMutex
 	items map[string]struct{}
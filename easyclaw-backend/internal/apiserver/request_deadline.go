@@ -0,0 +1,146 @@
+package apiserver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const requestTimeoutHeader = "X-Request-Timeout"
+
+// withRequestDeadline installs a per-request context.WithDeadline derived
+// from the client's X-Request-Timeout header or ?timeout= query parameter
+// (either a Go duration string, e.g. "2s" or "500ms"), clamped to
+// cfg.MaxRequestTimeout. Unlike the server-wide ReadTimeout/WriteTimeout,
+// this deadline is carried on the request context that reaches s.store.*,
+// so a slow query gets cancelled server-side instead of just having its
+// response discarded at the connection level. If the deadline fires before
+// the wrapped handler finishes, the client gets a 504 instead of hanging
+// until WriteTimeout kills the connection.
+func (s *Service) withRequestDeadline(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeout, err := requestTimeoutFor(r, s.cfg.MaxRequestTimeout)
+		if err != nil {
+			s.respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if timeout <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+
+		// A fresh channel per request, closed by time.AfterFunc when the
+		// deadline elapses - the timer itself is stopped on the way out so
+		// it never fires (and never leaks) once the handler finishes first.
+		timedOut := make(chan struct{})
+		timer := time.AfterFunc(timeout, func() { close(timedOut) })
+		defer timer.Stop()
+
+		dw := &deadlineResponseWriter{ResponseWriter: w}
+		served := make(chan struct{})
+		go func() {
+			defer close(served)
+			next.ServeHTTP(dw, r.WithContext(ctx))
+		}()
+
+		select {
+		case <-served:
+		case <-timedOut:
+			cancel()
+			if dw.claimTimeout() {
+				s.respondError(w, http.StatusGatewayTimeout, fmt.Sprintf("request exceeded %s timeout", timeout))
+			} else {
+				// The handler already wrote its own header before the
+				// deadline fired, so it owns the response now - but
+				// ServeHTTP must not return while it's still writing to w
+				// underneath us (http.Handler forbids writes after
+				// ServeHTTP returns, and the server may reuse the
+				// connection the instant it does).
+				<-served
+			}
+		}
+	})
+}
+
+// requestTimeoutFor resolves the client-requested per-request timeout from
+// X-Request-Timeout (checked first) or ?timeout=, clamped to [0, max]. A
+// value of 0 (the default when neither is set) means "no deadline beyond
+// whatever the server's own ReadTimeout/WriteTimeout already impose".
+func requestTimeoutFor(r *http.Request, max time.Duration) (time.Duration, error) {
+	raw := strings.TrimSpace(r.Header.Get(requestTimeoutHeader))
+	if raw == "" {
+		raw = strings.TrimSpace(r.URL.Query().Get("timeout"))
+	}
+	if raw == "" {
+		return 0, nil
+	}
+
+	timeout, err := time.ParseDuration(raw)
+	if err != nil {
+		return 0, fmt.Errorf("invalid request timeout %q: %w", raw, err)
+	}
+	if timeout <= 0 {
+		return 0, fmt.Errorf("invalid request timeout %q: must be > 0", raw)
+	}
+	if max > 0 && timeout > max {
+		timeout = max
+	}
+	return timeout, nil
+}
+
+// deadlineResponseWriter lets withRequestDeadline write the 504 itself and
+// have the still-running handler goroutine's later writes become no-ops,
+// instead of both racing to write to the same http.ResponseWriter.
+type deadlineResponseWriter struct {
+	http.ResponseWriter
+
+	mu        sync.Mutex
+	timedOut  bool
+	wroteHead bool
+}
+
+func (w *deadlineResponseWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut || w.wroteHead {
+		return
+	}
+	w.wroteHead = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *deadlineResponseWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	if w.timedOut {
+		w.mu.Unlock()
+		return len(b), nil
+	}
+	if !w.wroteHead {
+		w.wroteHead = true
+		w.mu.Unlock()
+		w.ResponseWriter.WriteHeader(http.StatusOK)
+	} else {
+		w.mu.Unlock()
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// claimTimeout marks the response as timed out, so any write the wrapped
+// handler makes afterward is silently dropped. It returns false if the
+// handler already wrote a header first, i.e. lost the race fair and square
+// and shouldn't also get a 504 written under it.
+func (w *deadlineResponseWriter) claimTimeout() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.wroteHead {
+		return false
+	}
+	w.timedOut = true
+	return true
+}
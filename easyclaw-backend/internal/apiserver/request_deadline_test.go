@@ -0,0 +1,149 @@
+package apiserver
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/coldbell/dex/backend/internal/config"
+)
+
+func newTestDeadlineService(maxTimeout time.Duration) *Service {
+	return &Service{
+		cfg:    config.APIServerConfig{MaxRequestTimeout: maxTimeout},
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+}
+
+// TestWithRequestDeadlineTimesOutBlockingHandler simulates a handler stuck
+// behind a slow Store call (the real code has no fake Store to block
+// inside - it's a thin wrapper over a real pgx connection - so the
+// blocking work is simulated directly at the http.Handler level instead).
+func TestWithRequestDeadlineTimesOutBlockingHandler(t *testing.T) {
+	svc := newTestDeadlineService(time.Second)
+
+	ctxCancelled := make(chan struct{})
+	blocking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			close(ctxCancelled)
+		case <-time.After(2 * time.Second):
+			w.WriteHeader(http.StatusOK)
+		}
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/slow", nil)
+	req.Header.Set(requestTimeoutHeader, "20ms")
+	rec := httptest.NewRecorder()
+
+	start := time.Now()
+	svc.withRequestDeadline(blocking).ServeHTTP(rec, req)
+	elapsed := time.Since(start)
+
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusGatewayTimeout)
+	}
+	if elapsed > 500*time.Millisecond {
+		t.Fatalf("handler took %s, want it to return promptly after the 20ms deadline", elapsed)
+	}
+
+	select {
+	case <-ctxCancelled:
+	case <-time.After(time.Second):
+		t.Fatal("downstream handler's context was never cancelled")
+	}
+}
+
+// TestWithRequestDeadlineWaitsForHandlerThatAlreadyWroteHeader covers the
+// path where the deadline fires after the handler already wrote its own
+// header - claimTimeout() loses that race and returns false - so the
+// handler, not withRequestDeadline, owns the response. ServeHTTP must
+// still block until the handler goroutine actually finishes; returning
+// early would let it keep writing to w after the http.Handler contract
+// says it's no longer allowed to.
+func TestWithRequestDeadlineWaitsForHandlerThatAlreadyWroteHeader(t *testing.T) {
+	svc := newTestDeadlineService(time.Second)
+
+	handlerDone := make(chan struct{})
+	lateHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("first"))
+		time.Sleep(60 * time.Millisecond) // past the 20ms deadline below
+		_, _ = w.Write([]byte("second"))
+		close(handlerDone)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/late", nil)
+	req.Header.Set(requestTimeoutHeader, "20ms")
+	rec := httptest.NewRecorder()
+
+	svc.withRequestDeadline(lateHandler).ServeHTTP(rec, req)
+
+	select {
+	case <-handlerDone:
+	default:
+		t.Fatal("withRequestDeadline returned before the handler finished writing, violating the http.Handler contract")
+	}
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d (the handler's own header, not a 504)", rec.Code, http.StatusOK)
+	}
+	if rec.Body.String() != "firstsecond" {
+		t.Fatalf("body = %q, want %q", rec.Body.String(), "firstsecond")
+	}
+}
+
+func TestWithRequestDeadlinePassesThroughFastHandler(t *testing.T) {
+	svc := newTestDeadlineService(time.Second)
+
+	fast := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("ok"))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/fast", nil)
+	req.Header.Set(requestTimeoutHeader, "500ms")
+	rec := httptest.NewRecorder()
+
+	svc.withRequestDeadline(fast).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if rec.Body.String() != "ok" {
+		t.Fatalf("body = %q, want %q", rec.Body.String(), "ok")
+	}
+}
+
+func TestRequestTimeoutForClampsToMax(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/x?timeout=10s", nil)
+	timeout, err := requestTimeoutFor(req, 2*time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if timeout != 2*time.Second {
+		t.Fatalf("timeout = %s, want clamped to 2s", timeout)
+	}
+}
+
+func TestRequestTimeoutForPrefersHeaderOverQueryParam(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/x?timeout=10s", nil)
+	req.Header.Set(requestTimeoutHeader, "3s")
+	timeout, err := requestTimeoutFor(req, time.Minute)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if timeout != 3*time.Second {
+		t.Fatalf("timeout = %s, want the header's 3s to win over the query param", timeout)
+	}
+}
+
+func TestRequestTimeoutForRejectsInvalidValue(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/x?timeout=not-a-duration", nil)
+	if _, err := requestTimeoutFor(req, time.Second); err == nil {
+		t.Fatal("expected an error for an invalid timeout value")
+	}
+}
@@ -0,0 +1,816 @@
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coldbell/dex/backend/internal/indexer"
+)
+
+// readRequestBody reads r.Body with the same size cap decodeJSONBody
+// enforces, but without decoding it yet: handleRPC needs to peek at
+// whether the body is a single request or a batch array before it knows
+// which shape to unmarshal into.
+func readRequestBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil {
+		return nil, fmt.Errorf("request body is required")
+	}
+	defer r.Body.Close()
+	return io.ReadAll(io.LimitReader(r.Body, 1<<20))
+}
+
+// Extra JSON-RPC error codes beyond the websocket_rpc.go set, reserved in
+// the -32000 to -32099 "server error" range the spec sets aside for
+// application-defined codes.
+const (
+	jsonrpcErrUnauthorized = -32001
+	jsonrpcErrForbidden    = -32003
+	jsonrpcErrNotFound     = -32004
+	jsonrpcErrInternal     = -32000
+)
+
+// rpcPrincipal is the identity handleRPC resolved a request's bearer token
+// to: a full session or scoped API token behaves exactly like authPrincipal
+// already does for REST, and an access token additionally may be
+// ReadOnly-restricted to the handful of methods that only read state.
+type rpcPrincipal struct {
+	authPrincipal
+	ReadOnly bool
+}
+
+// rpcMethod is one named operation exposed on /v1/rpc. params is the
+// request's raw params (nil for none); the return value is marshaled as
+// the response's result, or a non-nil *jsonrpcError is returned instead.
+type rpcMethod func(ctx context.Context, s *Service, principal rpcPrincipal, params json.RawMessage) (any, *jsonrpcError)
+
+// rpcReadOnlyMethods lists which rpcMethods entries a ReadOnly principal
+// (a "client"-role access token) may still call; everything else requires
+// a full session, a scoped API token with the matching scope, or a
+// "network"-role access token.
+var rpcReadOnlyMethods = map[string]bool{
+	"agents.list":         true,
+	"portfolio.get":       true,
+	"portfolio.rebalance": true,
+	"chart.candles":       true,
+	"trades.list":         true,
+	"leaderboard.get":     true,
+}
+
+var rpcMethods = map[string]rpcMethod{
+	"agents.create":       rpcAgentsCreate,
+	"agents.list":         rpcAgentsList,
+	"agents.startSession": rpcAgentsStartSession,
+	"agents.rebindOwner":  rpcAgentsRebindOwner,
+	"strategies.create":   rpcStrategiesCreate,
+	"strategies.publish":  rpcStrategiesPublish,
+	"risk.patch":          rpcRiskPatch,
+	"killswitch.trigger":  rpcKillSwitchTrigger,
+	"portfolio.get":       rpcPortfolioGet,
+	"portfolio.rebalance": rpcPortfolioRebalance,
+	"chart.candles":       rpcChartCandles,
+	"trades.list":         rpcTradesList,
+	"leaderboard.get":     rpcLeaderboardGet,
+}
+
+// handleRPC is a JSON-RPC 2.0 surface mirroring the REST handlers above,
+// one named method per operation, so an SDK or MCP-style tool integration
+// can issue a batch of otherwise-unrelated calls (agents.create,
+// risk.patch, trades.list, ...) in a single round trip instead of one
+// HTTP request per call. Auth is the same bearer token REST accepts
+// (session or scoped API token), plus access tokens minted by
+// handleCreateAccessToken.
+func (s *Service) handleRPC(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.respondMethodNotAllowed(w)
+		return
+	}
+
+	principal, err := s.requireRPCPrincipal(r)
+	if err != nil {
+		s.respondError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	body, err := readRequestBody(r)
+	if err != nil {
+		s.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	trimmed := strings.TrimSpace(string(body))
+	if trimmed == "" {
+		s.respondError(w, http.StatusBadRequest, "request body is required")
+		return
+	}
+
+	if trimmed[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(body, &batch); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write(jsonrpcErrorResponse(nil, jsonrpcErrParse, "invalid JSON"))
+			return
+		}
+		responses := make([]json.RawMessage, 0, len(batch))
+		for _, raw := range batch {
+			if response := s.dispatchRPC(r.Context(), principal, raw); response != nil {
+				responses = append(responses, response)
+			}
+		}
+		s.respondJSON(w, http.StatusOK, responses)
+		return
+	}
+
+	response := s.dispatchRPC(r.Context(), principal, body)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if response != nil {
+		_, _ = w.Write(response)
+	}
+}
+
+// dispatchRPC runs one request frame and returns its marshaled response,
+// or nil when raw is a notification (no id) and RPC notifications, like
+// their websocket counterpart, get no response at all.
+func (s *Service) dispatchRPC(ctx context.Context, principal rpcPrincipal, raw json.RawMessage) []byte {
+	var request jsonrpcRequest
+	if err := json.Unmarshal(raw, &request); err != nil {
+		return jsonrpcErrorResponse(nil, jsonrpcErrParse, "invalid JSON")
+	}
+	isNotification := len(request.ID) == 0
+
+	method, ok := rpcMethods[request.Method]
+	if !ok {
+		if isNotification {
+			return nil
+		}
+		return jsonrpcErrorResponse(request.ID, jsonrpcErrMethodNotFound, fmt.Sprintf("unknown method %q", request.Method))
+	}
+	if principal.ReadOnly && !rpcReadOnlyMethods[request.Method] {
+		if isNotification {
+			return nil
+		}
+		return jsonrpcErrorResponse(request.ID, jsonrpcErrForbidden, fmt.Sprintf("method %q requires a non-read-only credential", request.Method))
+	}
+
+	result, rpcErr := method(ctx, s, principal, request.Params)
+	if isNotification {
+		return nil
+	}
+	if rpcErr != nil {
+		return mustMarshalJSONRPC(jsonrpcResponse{JSONRPC: jsonrpcVersion, ID: request.ID, Error: rpcErr})
+	}
+	return jsonrpcResultResponse(request.ID, result)
+}
+
+func rpcInvalidParams(err error) *jsonrpcError {
+	return &jsonrpcError{Code: jsonrpcErrInvalidParams, Message: fmt.Sprintf("invalid params: %v", err)}
+}
+
+func rpcStoreError(err error, notFoundMessage string) *jsonrpcError {
+	if errors.Is(err, indexer.ErrNotFound) {
+		return &jsonrpcError{Code: jsonrpcErrNotFound, Message: notFoundMessage}
+	}
+	return &jsonrpcError{Code: jsonrpcErrInternal, Message: "internal error"}
+}
+
+func rpcAgentsCreate(ctx context.Context, s *Service, principal rpcPrincipal, params json.RawMessage) (any, *jsonrpcError) {
+	var request createAgentRequest
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &request); err != nil {
+			return nil, rpcInvalidParams(err)
+		}
+	}
+	request.Name = strings.TrimSpace(request.Name)
+	request.StrategyID = strings.TrimSpace(request.StrategyID)
+	if request.Name == "" || request.StrategyID == "" {
+		return nil, &jsonrpcError{Code: jsonrpcErrInvalidParams, Message: "name and strategy_id are required"}
+	}
+
+	now := time.Now().Unix()
+	agentID, err := newID("agent")
+	if err != nil {
+		s.logger.Error("rpc create agent id failed", "err", err)
+		return nil, &jsonrpcError{Code: jsonrpcErrInternal, Message: "failed to create agent"}
+	}
+	riskProfile := indexer.AgentRiskProfile{}
+	if request.RiskProfile != nil {
+		riskProfile = *request.RiskProfile
+	}
+	createdAgentID, err := s.store.CreateAgent(ctx, indexer.CreateAgentInput{
+		ID:          agentID,
+		Name:        request.Name,
+		StrategyID:  request.StrategyID,
+		OwnerPubkey: principal.WalletPubkey,
+		RiskProfile: riskProfile,
+		CreatedAt:   now,
+	})
+	if err != nil {
+		s.logger.Error("rpc create agent failed", "err", err)
+		return nil, &jsonrpcError{Code: jsonrpcErrInternal, Message: "failed to create agent"}
+	}
+	return createAgentResponse{AgentID: createdAgentID}, nil
+}
+
+func rpcAgentsList(ctx context.Context, s *Service, principal rpcPrincipal, params json.RawMessage) (any, *jsonrpcError) {
+	agents, err := s.store.ListAgents(ctx)
+	if err != nil {
+		s.logger.Error("rpc list agents failed", "err", err)
+		return nil, &jsonrpcError{Code: jsonrpcErrInternal, Message: "failed to list agents"}
+	}
+	return agents, nil
+}
+
+type rpcAgentsStartSessionParams struct {
+	AgentID string `json:"agent_id"`
+	Mode    string `json:"mode"`
+}
+
+func rpcAgentsStartSession(ctx context.Context, s *Service, principal rpcPrincipal, params json.RawMessage) (any, *jsonrpcError) {
+	var request rpcAgentsStartSessionParams
+	if err := json.Unmarshal(params, &request); err != nil {
+		return nil, rpcInvalidParams(err)
+	}
+	request.AgentID = strings.TrimSpace(request.AgentID)
+	if request.AgentID == "" {
+		return nil, &jsonrpcError{Code: jsonrpcErrInvalidParams, Message: "agent_id is required"}
+	}
+	if !principal.hasScope(scopeAgentsWrite) || !principal.allowsAgent(request.AgentID) {
+		return nil, &jsonrpcError{Code: jsonrpcErrForbidden, Message: "token missing required scope or agent access"}
+	}
+
+	now := time.Now().Unix()
+	sessionID, err := newID("sess")
+	if err != nil {
+		s.logger.Error("rpc create session id failed", "err", err)
+		return nil, &jsonrpcError{Code: jsonrpcErrInternal, Message: "failed to start session"}
+	}
+	createdSessionID, startedAt, err := s.store.StartAgentSession(ctx, indexer.StartAgentSessionInput{
+		ID:        sessionID,
+		AgentID:   request.AgentID,
+		Mode:      request.Mode,
+		CreatedBy: principal.WalletPubkey,
+		StartedAt: now,
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "invalid mode") {
+			return nil, &jsonrpcError{Code: jsonrpcErrInvalidParams, Message: "mode must be paper or live"}
+		}
+		return nil, rpcStoreError(err, "agent not found")
+	}
+	return startAgentSessionResponse{SessionID: createdSessionID, StartedAt: startedAt}, nil
+}
+
+type rpcAgentsRebindOwnerParams struct {
+	AgentID     string `json:"agent_id"`
+	ChallengeID string `json:"challenge_id"`
+	Signature   string `json:"signature"`
+}
+
+func rpcAgentsRebindOwner(ctx context.Context, s *Service, principal rpcPrincipal, params json.RawMessage) (any, *jsonrpcError) {
+	var request rpcAgentsRebindOwnerParams
+	if err := json.Unmarshal(params, &request); err != nil {
+		return nil, rpcInvalidParams(err)
+	}
+	request.AgentID = strings.TrimSpace(request.AgentID)
+	request.ChallengeID = strings.TrimSpace(request.ChallengeID)
+	request.Signature = strings.TrimSpace(request.Signature)
+	if request.AgentID == "" || request.ChallengeID == "" || request.Signature == "" {
+		return nil, &jsonrpcError{Code: jsonrpcErrInvalidParams, Message: "agent_id, challenge_id and signature are required"}
+	}
+
+	challenge, err := s.store.GetAuthChallenge(ctx, request.ChallengeID)
+	if err != nil {
+		return nil, rpcStoreError(err, "challenge not found")
+	}
+	now := time.Now().Unix()
+	if challenge.ExpiresAt <= now {
+		return nil, &jsonrpcError{Code: jsonrpcErrUnauthorized, Message: "challenge expired"}
+	}
+	if challenge.UsedAt != nil {
+		return nil, &jsonrpcError{Code: jsonrpcErrUnauthorized, Message: "challenge already used"}
+	}
+	if err := verifyWalletSignature(challenge.WalletPubkey, request.Signature, challenge.Message); err != nil {
+		return nil, &jsonrpcError{Code: jsonrpcErrUnauthorized, Message: "invalid signature"}
+	}
+	if err := s.store.MarkAuthChallengeUsed(ctx, request.ChallengeID, now); err != nil {
+		s.logger.Error("rpc mark rebind challenge used failed", "err", err)
+		return nil, &jsonrpcError{Code: jsonrpcErrInternal, Message: "failed to finalize rebind"}
+	}
+
+	sessionExpiresAt := now + int64(authSessionTTL/time.Second)
+	if err := s.store.RebindAgentOwner(ctx, request.AgentID, challenge.WalletPubkey, now, sessionExpiresAt); err != nil {
+		return nil, rpcStoreError(err, "agent not found")
+	}
+	return rebindAgentOwnerResponse{BoundAt: now}, nil
+}
+
+func rpcStrategiesCreate(ctx context.Context, s *Service, principal rpcPrincipal, params json.RawMessage) (any, *jsonrpcError) {
+	var request createStrategyRequest
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &request); err != nil {
+			return nil, rpcInvalidParams(err)
+		}
+	}
+	request.Name = strings.TrimSpace(request.Name)
+	if request.Name == "" {
+		return nil, &jsonrpcError{Code: jsonrpcErrInvalidParams, Message: "name is required"}
+	}
+	if request.EntryRules == nil {
+		request.EntryRules = map[string]any{}
+	}
+	if request.ExitRules == nil {
+		request.ExitRules = map[string]any{}
+	}
+	if request.RiskDefaults == nil {
+		request.RiskDefaults = map[string]any{}
+	}
+
+	strategyID, err := newID("strategy")
+	if err != nil {
+		s.logger.Error("rpc create strategy id failed", "err", err)
+		return nil, &jsonrpcError{Code: jsonrpcErrInternal, Message: "failed to create strategy"}
+	}
+	now := time.Now().Unix()
+	createdID, err := s.store.CreateStrategy(ctx, indexer.CreateStrategyInput{
+		ID:           strategyID,
+		Name:         request.Name,
+		EntryRules:   request.EntryRules,
+		ExitRules:    request.ExitRules,
+		RiskDefaults: request.RiskDefaults,
+		OwnerPubkey:  principal.WalletPubkey,
+		CreatedAt:    now,
+	})
+	if err != nil {
+		s.logger.Error("rpc create strategy failed", "err", err)
+		return nil, &jsonrpcError{Code: jsonrpcErrInternal, Message: "failed to create strategy"}
+	}
+	return createStrategyResponse{StrategyID: createdID}, nil
+}
+
+type rpcStrategiesPublishParams struct {
+	StrategyID string `json:"strategy_id"`
+}
+
+func rpcStrategiesPublish(ctx context.Context, s *Service, principal rpcPrincipal, params json.RawMessage) (any, *jsonrpcError) {
+	var request rpcStrategiesPublishParams
+	if err := json.Unmarshal(params, &request); err != nil {
+		return nil, rpcInvalidParams(err)
+	}
+	request.StrategyID = strings.TrimSpace(request.StrategyID)
+	if request.StrategyID == "" {
+		return nil, &jsonrpcError{Code: jsonrpcErrInvalidParams, Message: "strategy_id is required"}
+	}
+	if !principal.hasScope(scopeStrategiesPublish) {
+		return nil, &jsonrpcError{Code: jsonrpcErrForbidden, Message: "token missing required scope"}
+	}
+	publishedAt, err := s.store.PublishStrategy(ctx, request.StrategyID, time.Now().Unix())
+	if err != nil {
+		return nil, rpcStoreError(err, "strategy not found")
+	}
+	return publishStrategyResponse{PublishedAt: publishedAt}, nil
+}
+
+type rpcRiskPatchParams struct {
+	AgentID string `json:"agent_id"`
+	patchRiskRequest
+}
+
+func rpcRiskPatch(ctx context.Context, s *Service, principal rpcPrincipal, params json.RawMessage) (any, *jsonrpcError) {
+	var request rpcRiskPatchParams
+	if err := json.Unmarshal(params, &request); err != nil {
+		return nil, rpcInvalidParams(err)
+	}
+	request.AgentID = strings.TrimSpace(request.AgentID)
+	if request.AgentID == "" {
+		return nil, &jsonrpcError{Code: jsonrpcErrInvalidParams, Message: "agent_id is required"}
+	}
+	if !principal.hasScope(scopeAgentsWrite) || !principal.allowsAgent(request.AgentID) {
+		return nil, &jsonrpcError{Code: jsonrpcErrForbidden, Message: "token missing required scope or agent access"}
+	}
+	updated, err := s.store.PatchAgentRisk(ctx, request.AgentID, indexer.RiskPatch{
+		MaxPositionUSDC:     request.MaxPositionUSDC,
+		DailyLossLimitUSDC:  request.DailyLossLimitUSDC,
+		KillSwitchEnabled:   request.KillSwitchEnabled,
+		UpdatedAt:           time.Now().Unix(),
+		ExpectedFingerprint: strings.TrimSpace(request.Fingerprint),
+	})
+	if err != nil {
+		if errors.Is(err, indexer.ErrPreconditionFailed) {
+			return nil, &jsonrpcError{Code: jsonrpcErrForbidden, Message: "risk profile was modified since it was last read"}
+		}
+		return nil, rpcStoreError(err, "agent not found")
+	}
+	return patchRiskResponse{UpdatedRiskProfile: updated}, nil
+}
+
+func rpcKillSwitchTrigger(ctx context.Context, s *Service, principal rpcPrincipal, params json.RawMessage) (any, *jsonrpcError) {
+	var request killSwitchRequest
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &request); err != nil {
+			return nil, rpcInvalidParams(err)
+		}
+	}
+	if !principal.hasScope(scopeKillSwitch) {
+		return nil, &jsonrpcError{Code: jsonrpcErrForbidden, Message: "token missing required scope"}
+	}
+
+	allAgents := false
+	agentIDs := make([]string, 0, len(request.AgentIDs))
+	for _, rawID := range request.AgentIDs {
+		id := strings.TrimSpace(rawID)
+		if id == "" {
+			continue
+		}
+		if strings.EqualFold(id, "all") {
+			allAgents = true
+			break
+		}
+		agentIDs = append(agentIDs, id)
+	}
+
+	count, err := s.store.KillSwitch(ctx, allAgents, agentIDs, time.Now().Unix())
+	if err != nil {
+		s.logger.Error("rpc kill switch failed", "err", err)
+		return nil, &jsonrpcError{Code: jsonrpcErrInternal, Message: "failed to stop sessions"}
+	}
+	return killSwitchResponse{StoppedCount: count}, nil
+}
+
+type rpcPortfolioGetParams struct {
+	Period string `json:"period"`
+}
+
+func rpcPortfolioGet(ctx context.Context, s *Service, principal rpcPrincipal, params json.RawMessage) (any, *jsonrpcError) {
+	var request rpcPortfolioGetParams
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &request); err != nil {
+			return nil, rpcInvalidParams(err)
+		}
+	}
+	period := strings.TrimSpace(request.Period)
+	if period == "" {
+		period = "7d"
+	}
+	summary, err := s.store.GetPortfolioSummary(ctx, period)
+	if err != nil {
+		if strings.Contains(err.Error(), "invalid period") {
+			return nil, &jsonrpcError{Code: jsonrpcErrInvalidParams, Message: "period must be 7d, 30d, or all"}
+		}
+		return nil, &jsonrpcError{Code: jsonrpcErrInternal, Message: "failed to load portfolio"}
+	}
+	return summary, nil
+}
+
+func rpcPortfolioRebalance(ctx context.Context, s *Service, principal rpcPrincipal, params json.RawMessage) (any, *jsonrpcError) {
+	var request rebalancePreviewRequest
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &request); err != nil {
+			return nil, rpcInvalidParams(err)
+		}
+	}
+	period := strings.TrimSpace(request.Period)
+	if period == "" {
+		period = "7d"
+	}
+	result, err := s.store.PreviewRebalance(ctx, strings.TrimSpace(request.AgentID), request.Config, period)
+	if err != nil {
+		if strings.Contains(err.Error(), "invalid period") {
+			return nil, &jsonrpcError{Code: jsonrpcErrInvalidParams, Message: "period must be 7d, 30d, or all"}
+		}
+		if strings.HasPrefix(err.Error(), "rebalance:") {
+			return nil, &jsonrpcError{Code: jsonrpcErrInvalidParams, Message: err.Error()}
+		}
+		s.logger.Error("rpc preview rebalance failed", "err", err)
+		return nil, &jsonrpcError{Code: jsonrpcErrInternal, Message: "failed to preview rebalance"}
+	}
+	return result, nil
+}
+
+type rpcChartCandlesParams struct {
+	Market     string `json:"market"`
+	Timeframe  string `json:"timeframe"`
+	Limit      int    `json:"limit"`
+	HeikinAshi bool   `json:"heikin_ashi"`
+}
+
+func rpcChartCandles(ctx context.Context, s *Service, principal rpcPrincipal, params json.RawMessage) (any, *jsonrpcError) {
+	var request rpcChartCandlesParams
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &request); err != nil {
+			return nil, rpcInvalidParams(err)
+		}
+	}
+	market := indexer.NormalizeMarketSymbol(request.Market)
+	if market == "" {
+		market = "BTCUSDT"
+	}
+	timeframe, intervalSec, err := s.resolveChartTimeframe(ctx, market, request.Timeframe)
+	if err != nil {
+		return nil, &jsonrpcError{Code: jsonrpcErrInvalidParams, Message: err.Error()}
+	}
+	limit := request.Limit
+	if limit <= 0 {
+		limit = 120
+	}
+	candles, err := s.store.GetMarketCandles(ctx, market, intervalSec, limit, nil)
+	if err != nil {
+		s.logger.Error("rpc get market candles failed", "market", market, "timeframe", timeframe, "err", err)
+		return nil, &jsonrpcError{Code: jsonrpcErrInternal, Message: "failed to load candles"}
+	}
+	if request.HeikinAshi {
+		candles = indexer.ToHeikinAshi(candles)
+	}
+	return chartCandlesResponse{Market: market, Timeframe: timeframe, IntervalSec: intervalSec, HeikinAshi: request.HeikinAshi, Candles: candles}, nil
+}
+
+type rpcTradesListParams struct {
+	AgentID string `json:"agent_id"`
+	From    int64  `json:"from"`
+	To      int64  `json:"to"`
+	Limit   int    `json:"limit"`
+	Offset  int    `json:"offset"`
+}
+
+func rpcTradesList(ctx context.Context, s *Service, principal rpcPrincipal, params json.RawMessage) (any, *jsonrpcError) {
+	var request rpcTradesListParams
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &request); err != nil {
+			return nil, rpcInvalidParams(err)
+		}
+	}
+	if request.From != 0 && request.To != 0 && request.From > request.To {
+		return nil, &jsonrpcError{Code: jsonrpcErrInvalidParams, Message: "from must be <= to"}
+	}
+	items, normalizedLimit, normalizedOffset, err := s.store.ListTrades(ctx, indexer.TradeFilter{
+		AgentID:  strings.TrimSpace(request.AgentID),
+		FromUnix: request.From,
+		ToUnix:   request.To,
+		Limit:    request.Limit,
+		Offset:   request.Offset,
+	})
+	if err != nil {
+		s.logger.Error("rpc list trades failed", "err", err)
+		return nil, &jsonrpcError{Code: jsonrpcErrInternal, Message: "failed to list trades"}
+	}
+	return tradesResponse{Items: items, Limit: normalizedLimit, Offset: normalizedOffset}, nil
+}
+
+type rpcLeaderboardGetParams struct {
+	Metric        string   `json:"metric"`
+	Period        string   `json:"period"`
+	MinTrades     int      `json:"min_trades"`
+	MinActiveDays int      `json:"min_active_days"`
+	ExcludeAgents []string `json:"exclude_agents"`
+	Weights       string   `json:"weights"`
+}
+
+func rpcLeaderboardGet(ctx context.Context, s *Service, principal rpcPrincipal, params json.RawMessage) (any, *jsonrpcError) {
+	var request rpcLeaderboardGetParams
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &request); err != nil {
+			return nil, rpcInvalidParams(err)
+		}
+	}
+	period := strings.TrimSpace(request.Period)
+	if period == "" {
+		period = "7d"
+	}
+	minTrades := request.MinTrades
+	if minTrades <= 0 {
+		minTrades = 20
+	}
+	weights, err := indexer.ParseLeaderboardWeights(request.Weights)
+	if err != nil {
+		return nil, rpcInvalidParams(err)
+	}
+	result, err := s.store.GetLeaderboard(ctx, indexer.LeaderboardFilter{
+		Metric:        strings.TrimSpace(request.Metric),
+		Period:        period,
+		MinTrades:     minTrades,
+		MinActiveDays: request.MinActiveDays,
+		ExcludeAgents: request.ExcludeAgents,
+		Weights:       weights,
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "invalid") {
+			return nil, &jsonrpcError{Code: jsonrpcErrInvalidParams, Message: "invalid metric or period"}
+		}
+		return nil, &jsonrpcError{Code: jsonrpcErrInternal, Message: "failed to load leaderboard"}
+	}
+	return leaderboardResponse{Items: result.Items}, nil
+}
+
+// Access-token roles: "network" behaves like a full-scope principal
+// (server-to-server automation the operator trusts broadly), "client"
+// is restricted to rpcReadOnlyMethods (handed to SDKs/tool integrations
+// that only need read access).
+const (
+	accessTokenRoleClient  = "client"
+	accessTokenRoleNetwork = "network"
+)
+
+func isKnownAccessTokenRole(role string) bool {
+	return role == accessTokenRoleClient || role == accessTokenRoleNetwork
+}
+
+const accessTokenTTLDefault = 24 * time.Hour
+const accessTokenRateLimitDefault = 60
+
+type createAccessTokenRequest struct {
+	Role               string `json:"role"`
+	RateLimitPerMinute int    `json:"rate_limit_per_minute,omitempty"`
+	ExpiresAt          int64  `json:"expires_at,omitempty"`
+}
+
+type createAccessTokenResponse struct {
+	TokenID            string `json:"token_id"`
+	Token              string `json:"token"`
+	Role               string `json:"role"`
+	RateLimitPerMinute int    `json:"rate_limit_per_minute"`
+	ExpiresAt          int64  `json:"expires_at"`
+}
+
+// handleCreateAccessToken mints an opaque access token for /v1/rpc, in the
+// style of bytom/vapor's accesstoken package: unlike the scoped API tokens
+// handleAPITokensRoot mints, it carries no scope list, only a role and a
+// rate-limit budget, and requires a full owner session the same way
+// minting a scoped token does.
+func (s *Service) handleCreateAccessToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.respondMethodNotAllowed(w)
+		return
+	}
+	session, err := s.requireSession(r)
+	if err != nil {
+		s.respondError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var request createAccessTokenRequest
+	if err := decodeJSONBody(r, &request); err != nil {
+		s.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	request.Role = strings.TrimSpace(request.Role)
+	if request.Role == "" {
+		request.Role = accessTokenRoleClient
+	}
+	if !isKnownAccessTokenRole(request.Role) {
+		s.respondError(w, http.StatusBadRequest, fmt.Sprintf("unknown role %q", request.Role))
+		return
+	}
+	rateLimit := request.RateLimitPerMinute
+	if rateLimit <= 0 {
+		rateLimit = accessTokenRateLimitDefault
+	}
+
+	token, tokenHash, err := newSessionToken()
+	if err != nil {
+		s.logger.Error("create access token failed", "err", err)
+		s.respondError(w, http.StatusInternalServerError, "failed to create token")
+		return
+	}
+	tokenID, err := newID("atok")
+	if err != nil {
+		s.logger.Error("create access token id failed", "err", err)
+		s.respondError(w, http.StatusInternalServerError, "failed to create token")
+		return
+	}
+	now := time.Now().Unix()
+	expiresAt := request.ExpiresAt
+	if expiresAt <= now {
+		expiresAt = now + int64(accessTokenTTLDefault/time.Second)
+	}
+
+	if err := s.store.CreateAccessToken(r.Context(), indexer.AccessTokenRecord{
+		ID:                 tokenID,
+		TokenHash:          tokenHash,
+		WalletPubkey:       session.WalletPubkey,
+		Role:               request.Role,
+		RateLimitPerMinute: rateLimit,
+		CreatedAt:          now,
+		ExpiresAt:          expiresAt,
+	}); err != nil {
+		s.logger.Error("store access token failed", "err", err)
+		s.respondError(w, http.StatusInternalServerError, "failed to create token")
+		return
+	}
+
+	s.respondJSON(w, http.StatusOK, createAccessTokenResponse{
+		TokenID:            tokenID,
+		Token:              token,
+		Role:               request.Role,
+		RateLimitPerMinute: rateLimit,
+		ExpiresAt:          expiresAt,
+	})
+}
+
+// requireRPCPrincipal resolves r's bearer token against every credential
+// /v1/rpc accepts, in the same preference order requireScope already uses
+// for REST (full session, then scoped API token), falling back to an
+// access token and, if it carries a budget, charging against its
+// per-minute rate limit.
+func (s *Service) requireRPCPrincipal(r *http.Request) (rpcPrincipal, error) {
+	token, err := bearerTokenFromRequest(r)
+	if err != nil {
+		return rpcPrincipal{}, err
+	}
+	tokenHash := hashToken(token)
+	now := time.Now().Unix()
+
+	session, err := s.store.GetAuthSession(r.Context(), tokenHash)
+	if err == nil {
+		if session.RevokedAt != nil || session.ExpiresAt <= now {
+			return rpcPrincipal{}, fmt.Errorf("invalid or expired session")
+		}
+		return rpcPrincipal{authPrincipal: authPrincipal{WalletPubkey: session.WalletPubkey}}, nil
+	}
+	if !errors.Is(err, indexer.ErrUnauthorized) {
+		return rpcPrincipal{}, err
+	}
+
+	apiToken, err := s.store.GetAPITokenByHash(r.Context(), tokenHash)
+	if err == nil {
+		if apiToken.RevokedAt != nil || apiToken.ExpiresAt <= now {
+			return rpcPrincipal{}, fmt.Errorf("invalid or expired session")
+		}
+		return rpcPrincipal{authPrincipal: authPrincipal{WalletPubkey: apiToken.WalletPubkey, Scopes: apiToken.Scopes, AgentIDs: apiToken.AgentIDs}}, nil
+	}
+	if !errors.Is(err, indexer.ErrNotFound) {
+		return rpcPrincipal{}, err
+	}
+
+	accessToken, err := s.store.GetAccessTokenByHash(r.Context(), tokenHash)
+	if err != nil {
+		if errors.Is(err, indexer.ErrNotFound) {
+			return rpcPrincipal{}, fmt.Errorf("invalid or expired session")
+		}
+		return rpcPrincipal{}, err
+	}
+	if accessToken.RevokedAt != nil || accessToken.ExpiresAt <= now {
+		return rpcPrincipal{}, fmt.Errorf("invalid or expired session")
+	}
+	if accessToken.RateLimitPerMinute > 0 && !s.rpcRateLimiter.Allow(accessToken.ID, accessToken.RateLimitPerMinute) {
+		return rpcPrincipal{}, fmt.Errorf("rate limit exceeded")
+	}
+
+	return rpcPrincipal{
+		authPrincipal: authPrincipal{WalletPubkey: accessToken.WalletPubkey},
+		ReadOnly:      accessToken.Role == accessTokenRoleClient,
+	}, nil
+}
+
+// rpcRateLimiter enforces each access token's per-minute request budget as
+// a token bucket keyed by token id, refilled evenly across the minute.
+type rpcRateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*rpcTokenBucket
+}
+
+type rpcTokenBucket struct {
+	tokens       float64
+	capacity     float64
+	refillPerSec float64
+	lastRefill   time.Time
+}
+
+func newRPCRateLimiter() *rpcRateLimiter {
+	return &rpcRateLimiter{buckets: make(map[string]*rpcTokenBucket)}
+}
+
+func (l *rpcRateLimiter) Allow(tokenID string, perMinute int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	bucket, ok := l.buckets[tokenID]
+	if !ok {
+		bucket = &rpcTokenBucket{
+			tokens:       float64(perMinute),
+			capacity:     float64(perMinute),
+			refillPerSec: float64(perMinute) / 60,
+			lastRefill:   time.Now(),
+		}
+		l.buckets[tokenID] = bucket
+	}
+
+	now := time.Now()
+	elapsed := now.Sub(bucket.lastRefill).Seconds()
+	bucket.lastRefill = now
+	bucket.tokens += elapsed * bucket.refillPerSec
+	if bucket.tokens > bucket.capacity {
+		bucket.tokens = bucket.capacity
+	}
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
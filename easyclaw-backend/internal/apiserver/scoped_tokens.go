@@ -0,0 +1,294 @@
+package apiserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/coldbell/dex/backend/internal/indexer"
+)
+
+// Scopes a caller can request for a minted API token. These mirror the
+// write surfaces requireScope guards: read access to agent state, write
+// access to agent lifecycle/risk, strategy publishing, and the kill switch.
+const (
+	scopeAgentsRead        = "agents:read"
+	scopeAgentsWrite       = "agents:write"
+	scopeStrategiesPublish = "strategies:publish"
+	scopeKillSwitch        = "killswitch"
+)
+
+const apiTokenTTLDefault = 30 * 24 * time.Hour
+
+func isKnownScope(scope string) bool {
+	switch scope {
+	case scopeAgentsRead, scopeAgentsWrite, scopeStrategiesPublish, scopeKillSwitch:
+		return true
+	default:
+		return false
+	}
+}
+
+type createAPITokenRequest struct {
+	Scopes    []string `json:"scopes"`
+	AgentIDs  []string `json:"agent_ids,omitempty"`
+	ExpiresAt int64    `json:"expires_at,omitempty"`
+}
+
+type createAPITokenResponse struct {
+	TokenID   string   `json:"token_id"`
+	Token     string   `json:"token"`
+	Scopes    []string `json:"scopes"`
+	AgentIDs  []string `json:"agent_ids,omitempty"`
+	ExpiresAt int64    `json:"expires_at"`
+}
+
+type apiTokenResponse struct {
+	TokenID   string   `json:"token_id"`
+	Scopes    []string `json:"scopes"`
+	AgentIDs  []string `json:"agent_ids,omitempty"`
+	CreatedAt int64    `json:"created_at"`
+	ExpiresAt int64    `json:"expires_at"`
+	RevokedAt *int64   `json:"revoked_at,omitempty"`
+}
+
+// authPrincipal is what requireScope resolves a bearer token to. A full
+// owner session (minted at sign-in) has nil Scopes and can exercise
+// everything the wallet can; a scoped API token (minted directly, or
+// delegated through the OAuth exchange) is limited to Scopes and, if
+// AgentIDs is non-empty, to that agent allowlist.
+type authPrincipal struct {
+	WalletPubkey string
+	Scopes       []string
+	AgentIDs     []string
+}
+
+func (p authPrincipal) hasScope(scope string) bool {
+	if p.Scopes == nil {
+		return true
+	}
+	for _, granted := range p.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func (p authPrincipal) allowsAgent(agentID string) bool {
+	if len(p.AgentIDs) == 0 || agentID == "" {
+		return true
+	}
+	for _, allowed := range p.AgentIDs {
+		if allowed == agentID {
+			return true
+		}
+	}
+	return false
+}
+
+// requireScope resolves the bearer token on r and checks it against scope
+// and, when agentID is non-empty, the token's agent_ids allowlist. Pass an
+// empty scope for handlers that only need an authenticated wallet, not a
+// specific permission.
+func (s *Service) requireScope(r *http.Request, scope, agentID string) (authPrincipal, error) {
+	if apiKey := strings.TrimSpace(r.Header.Get("X-API-KEY")); apiKey != "" {
+		return s.requireSignedRequest(r, apiKey, scope, agentID)
+	}
+
+	token, err := bearerTokenFromRequest(r)
+	if err != nil {
+		return authPrincipal{}, err
+	}
+	tokenHash := hashToken(token)
+	now := time.Now().Unix()
+
+	session, err := s.store.GetAuthSession(r.Context(), tokenHash)
+	if err == nil {
+		if session.RevokedAt != nil || session.ExpiresAt <= now {
+			return authPrincipal{}, fmt.Errorf("invalid or expired session")
+		}
+		return authPrincipal{WalletPubkey: session.WalletPubkey}, nil
+	}
+	if !errors.Is(err, indexer.ErrUnauthorized) {
+		return authPrincipal{}, err
+	}
+
+	apiToken, err := s.store.GetAPITokenByHash(r.Context(), tokenHash)
+	if err != nil {
+		if errors.Is(err, indexer.ErrNotFound) {
+			return authPrincipal{}, fmt.Errorf("invalid or expired session")
+		}
+		return authPrincipal{}, err
+	}
+	if apiToken.RevokedAt != nil || apiToken.ExpiresAt <= now {
+		return authPrincipal{}, fmt.Errorf("invalid or expired session")
+	}
+
+	principal := authPrincipal{WalletPubkey: apiToken.WalletPubkey, Scopes: apiToken.Scopes, AgentIDs: apiToken.AgentIDs}
+	return enforcePrincipalScope(principal, scope, agentID)
+}
+
+// enforcePrincipalScope checks principal against scope and, when agentID
+// is non-empty, its agent allowlist — the tail end requireScope and
+// requireSignedRequest both need once they've resolved a credential to a
+// principal.
+func enforcePrincipalScope(principal authPrincipal, scope, agentID string) (authPrincipal, error) {
+	if scope != "" && !principal.hasScope(scope) {
+		return authPrincipal{}, fmt.Errorf("token missing required scope %q", scope)
+	}
+	if !principal.allowsAgent(agentID) {
+		return authPrincipal{}, fmt.Errorf("token not permitted for this agent")
+	}
+	return principal, nil
+}
+
+// mintAPIToken creates and stores a scoped API token for walletPubkey,
+// shared by handleAPITokensRoot's POST path and the /oauth/token exchange.
+func (s *Service) mintAPIToken(ctx context.Context, walletPubkey string, scopes, agentIDs []string, expiresAt int64) (createAPITokenResponse, error) {
+	token, tokenHash, err := newSessionToken()
+	if err != nil {
+		return createAPITokenResponse{}, err
+	}
+	tokenID, err := newID("tok")
+	if err != nil {
+		return createAPITokenResponse{}, err
+	}
+	now := time.Now().Unix()
+	if expiresAt <= now {
+		expiresAt = now + int64(apiTokenTTLDefault/time.Second)
+	}
+
+	record := indexer.APITokenRecord{
+		ID:           tokenID,
+		TokenHash:    tokenHash,
+		WalletPubkey: walletPubkey,
+		Scopes:       scopes,
+		AgentIDs:     agentIDs,
+		CreatedAt:    now,
+		ExpiresAt:    expiresAt,
+	}
+	if err := s.store.CreateAPIToken(ctx, record); err != nil {
+		return createAPITokenResponse{}, err
+	}
+
+	return createAPITokenResponse{
+		TokenID:   tokenID,
+		Token:     token,
+		Scopes:    scopes,
+		AgentIDs:  agentIDs,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// handleAPITokensRoot mints and lists scoped API tokens. Minting a token
+// that's more broadly privileged than the caller would otherwise have
+// access to is exactly the thing requireScope exists to prevent elsewhere,
+// so token management itself requires a full owner session (the wallet's
+// own signature), not another API token — an "agents:read" token can't be
+// used to mint itself an "agents:write" one.
+func (s *Service) handleAPITokensRoot(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		session, err := s.requireSession(r)
+		if err != nil {
+			s.respondError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		principal := authPrincipal{WalletPubkey: session.WalletPubkey}
+		var request createAPITokenRequest
+		if err := decodeJSONBody(r, &request); err != nil {
+			s.respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		scopes := make([]string, 0, len(request.Scopes))
+		for _, scope := range request.Scopes {
+			scope = strings.TrimSpace(scope)
+			if scope == "" {
+				continue
+			}
+			if !isKnownScope(scope) {
+				s.respondError(w, http.StatusBadRequest, fmt.Sprintf("unknown scope %q", scope))
+				return
+			}
+			scopes = append(scopes, scope)
+		}
+		if len(scopes) == 0 {
+			s.respondError(w, http.StatusBadRequest, "scopes is required")
+			return
+		}
+		agentIDs := make([]string, 0, len(request.AgentIDs))
+		for _, agentID := range request.AgentIDs {
+			agentID = strings.TrimSpace(agentID)
+			if agentID != "" {
+				agentIDs = append(agentIDs, agentID)
+			}
+		}
+
+		response, err := s.mintAPIToken(r.Context(), principal.WalletPubkey, scopes, agentIDs, request.ExpiresAt)
+		if err != nil {
+			s.logger.Error("create api token failed", "err", err)
+			s.respondError(w, http.StatusInternalServerError, "failed to create token")
+			return
+		}
+		s.respondJSON(w, http.StatusOK, response)
+
+	case http.MethodGet:
+		session, err := s.requireSession(r)
+		if err != nil {
+			s.respondError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		tokens, err := s.store.ListAPITokens(r.Context(), session.WalletPubkey)
+		if err != nil {
+			s.logger.Error("list api tokens failed", "err", err)
+			s.respondError(w, http.StatusInternalServerError, "failed to list tokens")
+			return
+		}
+		out := make([]apiTokenResponse, 0, len(tokens))
+		for _, token := range tokens {
+			out = append(out, apiTokenResponse{
+				TokenID:   token.ID,
+				Scopes:    token.Scopes,
+				AgentIDs:  token.AgentIDs,
+				CreatedAt: token.CreatedAt,
+				ExpiresAt: token.ExpiresAt,
+				RevokedAt: token.RevokedAt,
+			})
+		}
+		s.respondJSON(w, http.StatusOK, out)
+
+	default:
+		s.respondMethodNotAllowed(w)
+	}
+}
+
+func (s *Service) handleAPITokenByID(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		s.respondMethodNotAllowed(w)
+		return
+	}
+	tokenID := strings.TrimSpace(strings.TrimPrefix(r.URL.Path, "/v1/auth/tokens/"))
+	if tokenID == "" {
+		s.respondError(w, http.StatusBadRequest, "token id is required")
+		return
+	}
+	session, err := s.requireSession(r)
+	if err != nil {
+		s.respondError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if err := s.store.RevokeAPIToken(r.Context(), session.WalletPubkey, tokenID, time.Now().Unix()); err != nil {
+		if errors.Is(err, indexer.ErrNotFound) {
+			s.respondError(w, http.StatusNotFound, "token not found")
+			return
+		}
+		s.logger.Error("revoke api token failed", "err", err)
+		s.respondError(w, http.StatusInternalServerError, "failed to revoke token")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
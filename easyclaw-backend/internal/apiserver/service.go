@@ -6,20 +6,30 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/coldbell/dex/backend/internal/config"
 	"github.com/coldbell/dex/backend/internal/indexer"
+	"github.com/coldbell/dex/backend/internal/logging"
 )
 
+const requestIDHeader = "X-Request-ID"
+
 type Service struct {
 	cfg              config.APIServerConfig
 	logger           *slog.Logger
 	store            *indexer.Store
 	allowAllOrigins  bool
 	allowedOriginSet map[string]struct{}
+	rpcRateLimiter   *rpcRateLimiter
+	hub              *websocketHub
+
+	leaderboardPushMu   sync.Mutex
+	lastLeaderboardHash string
 }
 
 func New(cfg config.APIServerConfig, logger *slog.Logger) (*Service, error) {
@@ -45,33 +55,46 @@ func New(cfg config.APIServerConfig, logger *slog.Logger) (*Service, error) {
 		allowAllOrigins = true
 	}
 
-	return &Service{
+	svc := &Service{
 		cfg:              cfg,
 		logger:           logger,
 		store:            store,
 		allowAllOrigins:  allowAllOrigins,
 		allowedOriginSet: allowedOriginSet,
-	}, nil
+		rpcRateLimiter:   newRPCRateLimiter(),
+	}
+	svc.hub = newWebsocketHub(logger, svc.getWebsocketPayload)
+	return svc, nil
 }
 
-func (s *Service) Run(ctx context.Context) error {
-	defer func() {
-		if err := s.store.Close(); err != nil {
-			s.logger.Error("failed to close store", "err", err)
-		}
-	}()
-
+// Handler builds the full routed, CORS- and logging-wrapped
+// http.Handler this service serves. Run uses it to back a real
+// listener; the conformance test harness uses it directly to back an
+// httptest.Server without binding a port.
+func (s *Service) Handler() http.Handler {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/healthz", s.handleHealth)
 	mux.HandleFunc("/api/v1/positions", s.handlePositions)
 	mux.HandleFunc("/api/v1/orders", s.handleOrders)
 	mux.HandleFunc("/api/v1/fills", s.handleFills)
 	mux.HandleFunc("/api/v1/position-history", s.handlePositionHistory)
+	mux.HandleFunc("/v1/stats/fills", s.handleStatsFills)
+	mux.HandleFunc("/v1/stats/positions/pnl", s.handleStatsPositionsPnL)
 	mux.HandleFunc("/api/v1/orderbook-heatmap", s.handleOrderbookHeatmap)
 	mux.HandleFunc("/api/v1/orderbook-heatmap-aggregated", s.handleOrderbookHeatmapAggregated)
+	mux.HandleFunc("/api/v1/orderbook/depth", s.handleOrderbookDepth)
 	mux.HandleFunc("/v1/auth/challenge", s.handleAuthChallenge)
 	mux.HandleFunc("/v1/auth/verify-signature", s.handleAuthVerifySignature)
+	mux.HandleFunc("/v1/auth/verify-ledger", s.handleAuthVerifyLedger)
 	mux.HandleFunc("/v1/auth/session/refresh", s.handleAuthSessionRefresh)
+	mux.HandleFunc("/v1/auth/tokens", s.handleAPITokensRoot)
+	mux.HandleFunc("/v1/auth/tokens/", s.handleAPITokenByID)
+	mux.HandleFunc("/v1/auth/apikeys", s.handleAPIKeysRoot)
+	mux.HandleFunc("/v1/auth/apikeys/", s.handleAPIKeyByID)
+	mux.HandleFunc("/oauth/authorize", s.handleOAuthAuthorize)
+	mux.HandleFunc("/oauth/token", s.handleOAuthToken)
+	mux.HandleFunc("/v1/auth/access_tokens", s.handleCreateAccessToken)
+	mux.HandleFunc("/v1/rpc", s.handleRPC)
 	mux.HandleFunc("/v1/agents", s.handleAgentsRoot)
 	mux.HandleFunc("/v1/agents/", s.handleAgentsSubroutes)
 	mux.HandleFunc("/v1/safety/kill-switch", s.handleKillSwitch)
@@ -80,16 +103,35 @@ func (s *Service) Run(ctx context.Context) error {
 	mux.HandleFunc("/v1/strategies/", s.handleStrategiesSubroutes)
 	mux.HandleFunc("/v1/portfolio/agents/", s.handlePortfolioAgent)
 	mux.HandleFunc("/v1/portfolio", s.handlePortfolio)
+	mux.HandleFunc("/v1/portfolio/rebalance", s.handlePortfolioRebalance)
 	mux.HandleFunc("/v1/chart/candles", s.handleChartCandles)
+	mux.HandleFunc("/v1/markets", s.handleMarketsRoot)
+	mux.HandleFunc("/v1/markets/", s.handleMarketsSubroutes)
 	mux.HandleFunc("/v1/trades", s.handleTrades)
 	mux.HandleFunc("/v1/leaderboard", s.handleLeaderboard)
 	mux.HandleFunc("/v1/system/status", s.handleSystemStatus)
+	mux.HandleFunc("/v1/system/websocket-stats", s.handleWebsocketStats)
 	mux.HandleFunc("/ws", s.handleWebsocket)
+	mux.HandleFunc("/v1/stream/", s.handleChannelStream)
+	registerGenHandlers(mux, s)
+
+	return s.withCORS(s.withRequestLogging(s.withRequestDeadline(mux)))
+}
+
+func (s *Service) Run(ctx context.Context) error {
+	defer func() {
+		if err := s.store.Close(); err != nil {
+			s.logger.Error("failed to close store", "err", err)
+		}
+	}()
 
-	handler := s.withCORS(mux)
+	handler := s.Handler()
 	server := &http.Server{
-		Addr:         s.cfg.ListenAddr,
-		Handler:      handler,
+		Addr:    s.cfg.ListenAddr,
+		Handler: handler,
+		BaseContext: func(net.Listener) context.Context {
+			return logging.IntoContext(context.Background(), s.logger)
+		},
 		ReadTimeout:  s.cfg.ReadTimeout,
 		WriteTimeout: s.cfg.WriteTimeout,
 		IdleTimeout:  s.cfg.IdleTimeout,
@@ -130,6 +172,20 @@ type listResponse[T any] struct {
 	Items  []T `json:"items"`
 	Limit  int `json:"limit"`
 	Offset int `json:"offset"`
+
+	// NextCursor, when non-empty, is the recommended way to fetch the
+	// next page: pass it back as the cursor query param instead of
+	// incrementing offset. It's set whenever the page was full, which
+	// may or may not mean more rows actually exist.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+type statsFillsResponse struct {
+	Buckets []indexer.FillBucket `json:"buckets"`
+}
+
+type statsPositionsPnLResponse struct {
+	Positions []indexer.PositionPnLSnapshot `json:"positions"`
 }
 
 type healthResponse struct {
@@ -170,22 +226,34 @@ func (s *Service) handlePositions(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	items, normalizedLimit, normalizedOffset, err := s.store.ListPositions(r.Context(), indexer.PositionFilter{
-		UserMargin: strings.TrimSpace(r.URL.Query().Get("user_margin")),
+	userMargin := strings.TrimSpace(r.URL.Query().Get("user_margin"))
+	ctx := r.Context()
+	if userMargin != "" {
+		ctx = logging.Inject(ctx, "user_margin", userMargin)
+	}
+
+	items, normalizedLimit, normalizedOffset, nextCursor, err := s.store.ListPositions(ctx, indexer.PositionFilter{
+		UserMargin: userMargin,
 		MarketID:   marketID,
 		Limit:      limit,
 		Offset:     offset,
+		Cursor:     strings.TrimSpace(r.URL.Query().Get("cursor")),
 	})
 	if err != nil {
-		s.logger.Error("list positions failed", "err", err)
+		if isInvalidCursorErr(err) {
+			s.respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		logging.With(ctx).Error("list positions failed", "err", err)
 		s.respondError(w, http.StatusInternalServerError, "failed to list positions")
 		return
 	}
 
 	s.respondJSON(w, http.StatusOK, listResponse[indexer.PositionRecord]{
-		Items:  items,
-		Limit:  normalizedLimit,
-		Offset: normalizedOffset,
+		Items:      items,
+		Limit:      normalizedLimit,
+		Offset:     normalizedOffset,
+		NextCursor: nextCursor,
 	})
 }
 
@@ -211,24 +279,36 @@ func (s *Service) handleOrders(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	items, normalizedLimit, normalizedOffset, err := s.store.ListOrders(r.Context(), indexer.OrderFilter{
-		UserMargin: strings.TrimSpace(r.URL.Query().Get("user_margin")),
+	userMargin := strings.TrimSpace(r.URL.Query().Get("user_margin"))
+	ctx := r.Context()
+	if userMargin != "" {
+		ctx = logging.Inject(ctx, "user_margin", userMargin)
+	}
+
+	items, normalizedLimit, normalizedOffset, nextCursor, err := s.store.ListOrders(ctx, indexer.OrderFilter{
+		UserMargin: userMargin,
 		UserPubkey: strings.TrimSpace(r.URL.Query().Get("user_pubkey")),
 		MarketID:   marketID,
 		Status:     strings.TrimSpace(r.URL.Query().Get("status")),
 		Limit:      limit,
 		Offset:     offset,
+		Cursor:     strings.TrimSpace(r.URL.Query().Get("cursor")),
 	})
 	if err != nil {
-		s.logger.Error("list orders failed", "err", err)
+		if isInvalidCursorErr(err) {
+			s.respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		logging.With(ctx).Error("list orders failed", "err", err)
 		s.respondError(w, http.StatusInternalServerError, "failed to list orders")
 		return
 	}
 
 	s.respondJSON(w, http.StatusOK, listResponse[indexer.OrderRecord]{
-		Items:  items,
-		Limit:  normalizedLimit,
-		Offset: normalizedOffset,
+		Items:      items,
+		Limit:      normalizedLimit,
+		Offset:     normalizedOffset,
+		NextCursor: nextCursor,
 	})
 }
 
@@ -254,23 +334,35 @@ func (s *Service) handleFills(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	items, normalizedLimit, normalizedOffset, err := s.store.ListFills(r.Context(), indexer.FillFilter{
-		UserMargin: strings.TrimSpace(r.URL.Query().Get("user_margin")),
+	userMargin := strings.TrimSpace(r.URL.Query().Get("user_margin"))
+	ctx := r.Context()
+	if userMargin != "" {
+		ctx = logging.Inject(ctx, "user_margin", userMargin)
+	}
+
+	items, normalizedLimit, normalizedOffset, nextCursor, err := s.store.ListFills(ctx, indexer.FillFilter{
+		UserMargin: userMargin,
 		UserPubkey: strings.TrimSpace(r.URL.Query().Get("user_pubkey")),
 		MarketID:   marketID,
 		Limit:      limit,
 		Offset:     offset,
+		Cursor:     strings.TrimSpace(r.URL.Query().Get("cursor")),
 	})
 	if err != nil {
-		s.logger.Error("list fills failed", "err", err)
+		if isInvalidCursorErr(err) {
+			s.respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		logging.With(ctx).Error("list fills failed", "err", err)
 		s.respondError(w, http.StatusInternalServerError, "failed to list fills")
 		return
 	}
 
 	s.respondJSON(w, http.StatusOK, listResponse[indexer.FillRecord]{
-		Items:  items,
-		Limit:  normalizedLimit,
-		Offset: normalizedOffset,
+		Items:      items,
+		Limit:      normalizedLimit,
+		Offset:     normalizedOffset,
+		NextCursor: nextCursor,
 	})
 }
 
@@ -296,23 +388,103 @@ func (s *Service) handlePositionHistory(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	items, normalizedLimit, normalizedOffset, err := s.store.ListPositionHistory(r.Context(), indexer.PositionHistoryFilter{
-		UserMargin: strings.TrimSpace(r.URL.Query().Get("user_margin")),
+	userMargin := strings.TrimSpace(r.URL.Query().Get("user_margin"))
+	ctx := r.Context()
+	if userMargin != "" {
+		ctx = logging.Inject(ctx, "user_margin", userMargin)
+	}
+
+	items, normalizedLimit, normalizedOffset, nextCursor, err := s.store.ListPositionHistory(ctx, indexer.PositionHistoryFilter{
+		UserMargin: userMargin,
 		MarketID:   marketID,
 		Limit:      limit,
 		Offset:     offset,
+		Cursor:     strings.TrimSpace(r.URL.Query().Get("cursor")),
 	})
 	if err != nil {
-		s.logger.Error("list position history failed", "err", err)
+		if isInvalidCursorErr(err) {
+			s.respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		logging.With(ctx).Error("list position history failed", "err", err)
 		s.respondError(w, http.StatusInternalServerError, "failed to list position history")
 		return
 	}
 
-	s.respondJSON(w, http.StatusOK, listResponse[indexer.PositionHistoryRecord]{
-		Items:  items,
-		Limit:  normalizedLimit,
-		Offset: normalizedOffset,
+	s.respondCacheableJSON(w, r, http.StatusOK, listResponse[indexer.PositionHistoryRecord]{
+		Items:      items,
+		Limit:      normalizedLimit,
+		Offset:     normalizedOffset,
+		NextCursor: nextCursor,
+	}, cacheControlMaxAge(s.cfg.PositionHistoryCacheMaxAge))
+}
+
+func (s *Service) handleStatsFills(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.respondMethodNotAllowed(w)
+		return
+	}
+
+	marketID, err := parseOptionalUint64(r, "market_id")
+	if err != nil {
+		s.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	fromUnix, err := parseOptionalInt64(r, "from", 0)
+	if err != nil {
+		s.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	toUnix, err := parseOptionalInt64(r, "to", 0)
+	if err != nil {
+		s.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	bucketSeconds, err := parseOptionalInt64(r, "bucket_seconds", 0)
+	if err != nil {
+		s.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	buckets, err := s.store.AggregateFills(r.Context(), indexer.AggregateFilter{
+		UserMargin:    strings.TrimSpace(r.URL.Query().Get("user_margin")),
+		MarketID:      marketID,
+		FromUnix:      fromUnix,
+		ToUnix:        toUnix,
+		BucketSeconds: bucketSeconds,
+	})
+	if err != nil {
+		s.logger.Error("aggregate fills failed", "err", err)
+		s.respondError(w, http.StatusInternalServerError, "failed to aggregate fills")
+		return
+	}
+
+	s.respondJSON(w, http.StatusOK, statsFillsResponse{Buckets: buckets})
+}
+
+func (s *Service) handleStatsPositionsPnL(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.respondMethodNotAllowed(w)
+		return
+	}
+
+	marketID, err := parseOptionalUint64(r, "market_id")
+	if err != nil {
+		s.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	snapshots, err := s.store.PositionPnLSnapshot(r.Context(), indexer.PositionPnLFilter{
+		UserMargin: strings.TrimSpace(r.URL.Query().Get("user_margin")),
+		MarketID:   marketID,
 	})
+	if err != nil {
+		s.logger.Error("position PnL snapshot failed", "err", err)
+		s.respondError(w, http.StatusInternalServerError, "failed to compute position PnL snapshot")
+		return
+	}
+
+	s.respondJSON(w, http.StatusOK, statsPositionsPnLResponse{Positions: snapshots})
 }
 
 func (s *Service) handleOrderbookHeatmap(w http.ResponseWriter, r *http.Request) {
@@ -354,25 +526,31 @@ func (s *Service) handleOrderbookHeatmap(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	items, normalizedLimit, normalizedOffset, err := s.store.ListOrderbookHeatmap(r.Context(), indexer.OrderbookHeatmapFilter{
+	items, normalizedLimit, normalizedOffset, nextCursor, err := s.store.ListOrderbookHeatmap(r.Context(), indexer.OrderbookHeatmapFilter{
 		Exchange: exchange,
 		Symbol:   symbol,
 		FromUnix: from,
 		ToUnix:   to,
 		Limit:    limit,
 		Offset:   offset,
+		Cursor:   strings.TrimSpace(r.URL.Query().Get("cursor")),
 	})
 	if err != nil {
+		if isInvalidCursorErr(err) {
+			s.respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
 		s.logger.Error("list orderbook heatmap failed", "err", err)
 		s.respondError(w, http.StatusInternalServerError, "failed to list orderbook heatmap")
 		return
 	}
 
-	s.respondJSON(w, http.StatusOK, listResponse[indexer.OrderbookHeatmapRecord]{
-		Items:  items,
-		Limit:  normalizedLimit,
-		Offset: normalizedOffset,
-	})
+	s.respondCacheableJSON(w, r, http.StatusOK, listResponse[indexer.OrderbookHeatmapRecord]{
+		Items:      items,
+		Limit:      normalizedLimit,
+		Offset:     normalizedOffset,
+		NextCursor: nextCursor,
+	}, cacheControlMaxAge(s.cfg.HeatmapCacheMaxAge))
 }
 
 func (s *Service) handleOrderbookHeatmapAggregated(w http.ResponseWriter, r *http.Request) {
@@ -429,10 +607,86 @@ func (s *Service) handleOrderbookHeatmapAggregated(w http.ResponseWriter, r *htt
 		return
 	}
 
-	s.respondJSON(w, http.StatusOK, listResponse[indexer.OrderbookHeatmapAggregatePoint]{
+	s.respondCacheableJSON(w, r, http.StatusOK, listResponse[indexer.OrderbookHeatmapAggregatePoint]{
 		Items:  items,
 		Limit:  normalizedLimit,
 		Offset: normalizedOffset,
+	}, cacheControlMaxAge(s.cfg.HeatmapCacheMaxAge))
+}
+
+// handleOrderbookDepth returns a classical L2 depth snapshot: the top N
+// aggregated price levels per side from the most recent orderbook snapshot
+// for exchange/symbol, best-to-worst. Omitting side returns both (the
+// combined both-sides variant); passing side=bid or side=ask restricts the
+// response to one.
+func (s *Service) handleOrderbookDepth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.respondMethodNotAllowed(w)
+		return
+	}
+
+	exchange := strings.TrimSpace(r.URL.Query().Get("exchange"))
+	symbol := strings.TrimSpace(r.URL.Query().Get("symbol"))
+	if exchange == "" || symbol == "" {
+		s.respondError(w, http.StatusBadRequest, "exchange and symbol are required")
+		return
+	}
+	side := strings.TrimSpace(r.URL.Query().Get("side"))
+
+	if side != "" && side != "bid" && side != "ask" {
+		s.respondError(w, http.StatusBadRequest, "side must be \"bid\" or \"ask\"")
+		return
+	}
+
+	levels, err := parseOptionalInt(r, "levels", 0)
+	if err != nil {
+		s.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	depth, err := s.store.GetOrderbookDepth(r.Context(), indexer.OrderbookDepthFilter{
+		Exchange: exchange,
+		Symbol:   symbol,
+		Side:     side,
+		Levels:   levels,
+	})
+	if err != nil {
+		s.logger.Warn("get orderbook depth failed", "exchange", exchange, "symbol", symbol, "err", err)
+		s.respondError(w, http.StatusNotFound, "no orderbook snapshot found for this exchange/symbol")
+		return
+	}
+
+	s.respondJSON(w, http.StatusOK, depth)
+}
+
+// withRequestLogging generates (or propagates) an X-Request-ID, parses any
+// W3C traceparent header, and stashes a logger decorated with request_id,
+// trace_id, and span_id into the request context via logging.Inject, so
+// every handler and Store list method can call logging.With(r.Context())
+// and get log lines tagged for correlation across the api-server and
+// indexer logs. user_margin is injected separately, closer to the handlers
+// that resolve it, since it isn't known until a filter or session is parsed.
+func (s *Service) withRequestLogging(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := strings.TrimSpace(r.Header.Get(requestIDHeader))
+		if requestID == "" {
+			var err error
+			requestID, err = newID("req")
+			if err != nil {
+				s.logger.Error("failed to generate request id", "err", err)
+				s.respondError(w, http.StatusInternalServerError, "failed to handle request")
+				return
+			}
+		}
+		w.Header().Set(requestIDHeader, requestID)
+
+		fields := []any{"request_id", requestID}
+		if traceID, spanID, ok := logging.ParseTraceparent(r.Header.Get("traceparent")); ok {
+			fields = append(fields, "trace_id", traceID, "span_id", spanID)
+		}
+
+		ctx := logging.Inject(r.Context(), fields...)
+		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
@@ -454,6 +708,7 @@ func (s *Service) withCORS(next http.Handler) http.Handler {
 				}
 				w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PATCH, DELETE, OPTIONS")
 				w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+				w.Header().Set("Access-Control-Expose-Headers", "ETag, Content-Encoding")
 				w.Header().Set("Access-Control-Max-Age", "300")
 			}
 		}
@@ -503,6 +758,13 @@ func parseOptionalInt64(r *http.Request, key string, fallback int64) (int64, err
 	return value, nil
 }
 
+// isInvalidCursorErr reports whether err came from a Store list method
+// rejecting a malformed cursor query param, which is a client error (400)
+// rather than the store-failure (500) those methods otherwise return.
+func isInvalidCursorErr(err error) bool {
+	return strings.Contains(err.Error(), "invalid cursor")
+}
+
 func (s *Service) respondMethodNotAllowed(w http.ResponseWriter) {
 	s.respondError(w, http.StatusMethodNotAllowed, "method not allowed")
 }
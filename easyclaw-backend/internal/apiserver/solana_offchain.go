@@ -0,0 +1,78 @@
+package apiserver
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// solanaOffchainSigningDomain is the fixed 16-byte prefix ("\xff" + "solana
+// offchain") every Solana off-chain message starts with, so a signature
+// over arbitrary bytes can never be mistaken for a signed transaction.
+var solanaOffchainSigningDomain = append([]byte{0xff}, []byte("solana offchain")...)
+
+const (
+	solanaOffchainApplicationDomainLen = 32
+	solanaOffchainSignerPubkeyLen      = 32
+)
+
+// parseSolanaOffchainMessage validates and strips the header hardware
+// wallets (Ledger in particular, which can't render arbitrary signing
+// prompts) wrap a message in before presenting it for signing, returning
+// the inner message bytes. Layout: signing domain (16 bytes), header
+// version (1 byte), application domain (32 bytes), message format (1 byte),
+// signer count (1 byte), signer pubkeys (32 bytes each), message length
+// (uint16 little-endian), message.
+func parseSolanaOffchainMessage(raw []byte) ([]byte, error) {
+	offset := len(solanaOffchainSigningDomain)
+	if len(raw) < offset {
+		return nil, fmt.Errorf("message shorter than signing domain")
+	}
+	if !bytes.Equal(raw[:offset], solanaOffchainSigningDomain) {
+		return nil, fmt.Errorf("not a Solana off-chain message")
+	}
+
+	if len(raw) < offset+1 {
+		return nil, fmt.Errorf("message truncated before header version")
+	}
+	version := raw[offset]
+	if version != 0 {
+		return nil, fmt.Errorf("unsupported off-chain message version %d", version)
+	}
+	offset++
+
+	if len(raw) < offset+solanaOffchainApplicationDomainLen {
+		return nil, fmt.Errorf("message truncated before application domain")
+	}
+	offset += solanaOffchainApplicationDomainLen
+
+	if len(raw) < offset+1 {
+		return nil, fmt.Errorf("message truncated before format byte")
+	}
+	format := raw[offset]
+	if format > 2 {
+		return nil, fmt.Errorf("unsupported off-chain message format %d", format)
+	}
+	offset++
+
+	if len(raw) < offset+1 {
+		return nil, fmt.Errorf("message truncated before signer count")
+	}
+	signerCount := int(raw[offset])
+	offset++
+	if signerCount != 1 {
+		return nil, fmt.Errorf("expected exactly one signer, got %d", signerCount)
+	}
+
+	if len(raw) < offset+solanaOffchainSignerPubkeyLen+2 {
+		return nil, fmt.Errorf("message truncated before length prefix")
+	}
+	offset += solanaOffchainSignerPubkeyLen
+
+	messageLen := int(binary.LittleEndian.Uint16(raw[offset : offset+2]))
+	offset += 2
+	if len(raw) < offset+messageLen {
+		return nil, fmt.Errorf("message length prefix exceeds payload")
+	}
+	return raw[offset : offset+messageLen], nil
+}
@@ -0,0 +1,87 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const channelStreamPollInterval = 2 * time.Second
+
+// handleChannelStream is the GET /v1/stream/{channel} Server-Sent-Events
+// fallback for handleWebsocket: the same channel payloads (market price,
+// chart ticks, agent signals/executions, portfolio/leaderboard updates,
+// system status) that a websocket "subscribe" delivers, for browsers
+// behind proxies that strip WebSocket upgrades without pulling in
+// gorilla/websocket client-side.
+//
+// Each event carries an `id:` so a client that reconnects with
+// Last-Event-ID continues the sequence rather than restarting it at 1.
+// These channels are live snapshots, not an append-only log, so there's no
+// backlog to actually replay from an id; deterministic replay of historical
+// rows is what the JSON-RPC subscribe path and the indexer's own
+// backfill-then-tail streams (internal/indexer's /v1/stream/* endpoints)
+// are for.
+func (s *Service) handleChannelStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.respondMethodNotAllowed(w)
+		return
+	}
+
+	channel := strings.TrimSpace(strings.TrimPrefix(r.URL.Path, "/v1/stream/"))
+	if channel == "" {
+		s.respondError(w, http.StatusBadRequest, "channel is required")
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	eventID := int64(0)
+	if last := strings.TrimSpace(r.Header.Get("Last-Event-ID")); last != "" {
+		if parsed, err := strconv.ParseInt(last, 10, 64); err == nil {
+			eventID = parsed
+		}
+	}
+
+	ctx := r.Context()
+	ticker := time.NewTicker(channelStreamPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			payload, err := s.getWebsocketPayload(ctx, channel)
+			if err != nil {
+				s.logger.Error("channel stream fetch failed", "channel", channel, "err", err)
+				continue
+			}
+			if payload == nil {
+				continue
+			}
+			raw, err := json.Marshal(payload)
+			if err != nil {
+				s.logger.Error("channel stream marshal failed", "channel", channel, "err", err)
+				continue
+			}
+			eventID++
+			if _, err := fmt.Fprintf(w, "event: %s\nid: %d\ndata: %s\n\n", channel, eventID, raw); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
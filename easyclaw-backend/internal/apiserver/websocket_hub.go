@@ -0,0 +1,253 @@
+package apiserver
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// hubEvent is one channel's latest payload (or fetch error), pushed from
+// the hub down to a single connection's subscriber channel.
+type hubEvent struct {
+	Channel string
+	Data    any
+	Err     error
+}
+
+// websocketHub is the central pub/sub fan-out handleWebsocket connections
+// subscribe into, in the style of Blockbook's websocketChannel pattern:
+// one poll goroutine per distinct channel (not one per connection) fetches
+// state and fans it out to every subscriber registered for that channel, so
+// N connections watching the same market no longer cause N redundant
+// fetches the way the old per-connection ticker in handleWebsocket did.
+type websocketHub struct {
+	fetch    func(ctx context.Context, channel string) (any, error)
+	logger   *slog.Logger
+	interval func(channel string) time.Duration
+
+	mu     sync.Mutex
+	topics map[string]*hubTopic
+
+	drops sync.Map // channel string -> *atomic.Int64
+}
+
+type hubTopic struct {
+	subscribers map[*hubSubscriber]struct{}
+	cancel      context.CancelFunc
+}
+
+type hubSubscriber struct {
+	channel string
+	send    chan hubEvent
+}
+
+// hubChannelStats is one channel's point-in-time subscriber count and
+// cumulative backpressure drop count, for a lightweight ops view of which
+// channels are falling behind their subscribers.
+type hubChannelStats struct {
+	Subscribers int   `json:"subscribers"`
+	Drops       int64 `json:"drops"`
+}
+
+func newWebsocketHub(logger *slog.Logger, fetch func(ctx context.Context, channel string) (any, error)) *websocketHub {
+	return &websocketHub{
+		fetch:    fetch,
+		logger:   logger,
+		interval: hubPollInterval,
+		topics:   make(map[string]*hubTopic),
+	}
+}
+
+// hubPollInterval mirrors the 2-second cadence the old per-connection
+// ticker used for everything, except market price ticks get a faster
+// cadence now that one poll serves every subscriber instead of one per
+// connection.
+func hubPollInterval(channel string) time.Duration {
+	if strings.HasPrefix(channel, "market.price.") {
+		return time.Second
+	}
+	return 2 * time.Second
+}
+
+// Subscribe registers send to receive channel's pushed payloads, starting
+// channel's poll goroutine if send is the first subscriber.
+func (h *websocketHub) Subscribe(channel string, send chan hubEvent) *hubSubscriber {
+	sub := &hubSubscriber{channel: channel, send: send}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	topic, ok := h.topics[channel]
+	if !ok {
+		ctx, cancel := context.WithCancel(context.Background())
+		topic = &hubTopic{subscribers: map[*hubSubscriber]struct{}{}, cancel: cancel}
+		h.topics[channel] = topic
+		go h.pollLoop(ctx, channel)
+	}
+	topic.subscribers[sub] = struct{}{}
+	return sub
+}
+
+// Unsubscribe removes sub from channel, stopping channel's poll goroutine
+// once its last subscriber has left.
+func (h *websocketHub) Unsubscribe(channel string, sub *hubSubscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	topic, ok := h.topics[channel]
+	if !ok {
+		return
+	}
+	delete(topic.subscribers, sub)
+	if len(topic.subscribers) == 0 {
+		topic.cancel()
+		delete(h.topics, channel)
+	}
+}
+
+func (h *websocketHub) pollLoop(ctx context.Context, channel string) {
+	ticker := time.NewTicker(h.interval(channel))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			data, err := h.fetch(ctx, channel)
+			if err == nil && data == nil {
+				continue
+			}
+			h.publish(channel, data, err)
+		}
+	}
+}
+
+func (h *websocketHub) publish(channel string, data any, err error) {
+	h.mu.Lock()
+	topic, ok := h.topics[channel]
+	var subs []*hubSubscriber
+	if ok {
+		subs = make([]*hubSubscriber, 0, len(topic.subscribers))
+		for sub := range topic.subscribers {
+			subs = append(subs, sub)
+		}
+	}
+	h.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	event := hubEvent{Channel: channel, Data: data, Err: err}
+	for _, sub := range subs {
+		select {
+		case sub.send <- event:
+		default:
+			// Backpressure: drop the oldest queued event for this
+			// subscriber instead of blocking the shared poll goroutine
+			// (and therefore every other subscriber of this channel) on
+			// one slow connection.
+			select {
+			case <-sub.send:
+			default:
+			}
+			select {
+			case sub.send <- event:
+			default:
+			}
+			h.countDrop(channel)
+		}
+	}
+}
+
+func (h *websocketHub) countDrop(channel string) {
+	counter, _ := h.drops.LoadOrStore(channel, new(atomic.Int64))
+	counter.(*atomic.Int64).Add(1)
+}
+
+// Stats reports every currently-active channel's subscriber count and
+// cumulative backpressure drop count.
+func (h *websocketHub) Stats() map[string]hubChannelStats {
+	h.mu.Lock()
+	out := make(map[string]hubChannelStats, len(h.topics))
+	for channel, topic := range h.topics {
+		out[channel] = hubChannelStats{Subscribers: len(topic.subscribers)}
+	}
+	h.mu.Unlock()
+
+	h.drops.Range(func(key, value any) bool {
+		channel := key.(string)
+		stats := out[channel]
+		stats.Drops = value.(*atomic.Int64).Load()
+		out[channel] = stats
+		return true
+	})
+	return out
+}
+
+const connectionHubEventBuffer = 64
+
+// connectionHub tracks the hub channels one websocket connection currently
+// cares about, so the connection's legacy subscriptionSet and JSON-RPC
+// jsonrpcSubscriptionSet can both feed the same underlying hub subscription
+// per channel without double-subscribing.
+type connectionHub struct {
+	hub    *websocketHub
+	events chan hubEvent
+
+	mu     sync.Mutex
+	active map[string]*hubSubscriber
+}
+
+func newConnectionHub(hub *websocketHub) *connectionHub {
+	return &connectionHub{
+		hub:    hub,
+		events: make(chan hubEvent, connectionHubEventBuffer),
+		active: make(map[string]*hubSubscriber),
+	}
+}
+
+// sync subscribes to every channel in wanted that isn't already active and
+// unsubscribes from every active channel no longer in wanted.
+func (c *connectionHub) sync(wanted map[string]struct{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for channel := range wanted {
+		if _, ok := c.active[channel]; !ok {
+			c.active[channel] = c.hub.Subscribe(channel, c.events)
+		}
+	}
+	for channel, sub := range c.active {
+		if _, ok := wanted[channel]; !ok {
+			c.hub.Unsubscribe(channel, sub)
+			delete(c.active, channel)
+		}
+	}
+}
+
+// closeAll unsubscribes every channel this connection is still subscribed
+// to, for use when the connection closes.
+func (c *connectionHub) closeAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for channel, sub := range c.active {
+		c.hub.Unsubscribe(channel, sub)
+		delete(c.active, channel)
+	}
+}
+
+// resyncHubSubscriptions recomputes the union of channels subs and rpcSubs
+// care about and reconciles connHub's hub subscriptions to match.
+func resyncHubSubscriptions(subs *subscriptionSet, rpcSubs *jsonrpcSubscriptionSet, connHub *connectionHub) {
+	legacyChannels := subs.List()
+	rpcByChannel := rpcSubs.ByChannel()
+
+	wanted := make(map[string]struct{}, len(legacyChannels)+len(rpcByChannel))
+	for _, channel := range legacyChannels {
+		wanted[channel] = struct{}{}
+	}
+	for channel := range rpcByChannel {
+		wanted[channel] = struct{}{}
+	}
+	connHub.sync(wanted)
+}
@@ -0,0 +1,232 @@
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+const jsonrpcVersion = "2.0"
+
+// jsonrpcRequest is a JSON-RPC 2.0 request/notification frame, accepted on
+// the same /ws connection as the bespoke {type, channel} protocol
+// websocketSubscribeRequest defines. It gives reconnect/replay deterministic
+// semantics the bespoke frame doesn't: a numeric id round-trips on the
+// response, and every active subscription gets its own numeric id that
+// each pushed notification's params.subscription carries, so a client can
+// track per-subscription cursors instead of re-deriving state from a bare
+// channel name.
+type jsonrpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type jsonrpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Result  any             `json:"result,omitempty"`
+	Error   *jsonrpcError   `json:"error,omitempty"`
+}
+
+type jsonrpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// jsonrpcNotification is a server-pushed JSON-RPC 2.0 notification (no id)
+// carrying one channel's latest payload to one subscriber.
+type jsonrpcNotification struct {
+	JSONRPC string                    `json:"jsonrpc"`
+	Method  string                    `json:"method"`
+	Params  jsonrpcNotificationParams `json:"params"`
+}
+
+type jsonrpcNotificationParams struct {
+	Subscription int64 `json:"subscription"`
+	Result       any   `json:"result"`
+}
+
+type jsonrpcSubscribeParams struct {
+	Channel string `json:"channel"`
+}
+
+type jsonrpcUnsubscribeParams struct {
+	Subscription int64 `json:"subscription"`
+}
+
+type jsonrpcSubscriptionInfo struct {
+	Subscription int64  `json:"subscription"`
+	Channel      string `json:"channel"`
+}
+
+const (
+	jsonrpcErrParse          = -32700
+	jsonrpcErrMethodNotFound = -32601
+	jsonrpcErrInvalidParams  = -32602
+)
+
+// isJSONRPCRequest reports whether raw is framed as JSON-RPC 2.0
+// ("jsonrpc": "2.0"), as opposed to websocketSubscribeRequest's bespoke
+// {type, channel} frame. Both are accepted on the same connection so
+// existing clients keep working unmodified.
+func isJSONRPCRequest(raw json.RawMessage) bool {
+	var probe struct {
+		JSONRPC string `json:"jsonrpc"`
+	}
+	if err := json.Unmarshal(raw, &probe); err != nil {
+		return false
+	}
+	return probe.JSONRPC == jsonrpcVersion
+}
+
+// websocketQueryMethods are the synchronous request/response methods this
+// package's JSON-RPC layer accepts over the same connection as pub/sub
+// subscribe/unsubscribe, so a client can do one-off queries like
+// getPortfolio without opening a second HTTP round trip. Each delegates to
+// the same rpcMethod /v1/rpc already wires up, so the validation and store
+// calls for a given query live in exactly one place.
+var websocketQueryMethods = map[string]rpcMethod{
+	"getPortfolio": rpcPortfolioGet,
+	"getCandles":   rpcChartCandles,
+	"getTrades":    rpcTradesList,
+}
+
+// handleJSONRPCMessage dispatches one JSON-RPC request against subs and
+// returns the marshaled response to send back.
+func (s *Service) handleJSONRPCMessage(ctx context.Context, raw json.RawMessage, subs *jsonrpcSubscriptionSet) []byte {
+	var request jsonrpcRequest
+	if err := json.Unmarshal(raw, &request); err != nil {
+		return mustMarshalJSONRPC(jsonrpcResponse{
+			JSONRPC: jsonrpcVersion,
+			Error:   &jsonrpcError{Code: jsonrpcErrParse, Message: "invalid JSON"},
+		})
+	}
+
+	switch request.Method {
+	case "subscribe":
+		var params jsonrpcSubscribeParams
+		channel := ""
+		if len(request.Params) > 0 {
+			if err := json.Unmarshal(request.Params, &params); err != nil {
+				return jsonrpcErrorResponse(request.ID, jsonrpcErrInvalidParams, "params.channel is required")
+			}
+			channel = strings.TrimSpace(params.Channel)
+		}
+		if channel == "" {
+			return jsonrpcErrorResponse(request.ID, jsonrpcErrInvalidParams, "params.channel is required")
+		}
+		subscriptionID := subs.Add(channel)
+		return jsonrpcResultResponse(request.ID, subscriptionID)
+	case "unsubscribe":
+		var params jsonrpcUnsubscribeParams
+		if err := json.Unmarshal(request.Params, &params); err != nil {
+			return jsonrpcErrorResponse(request.ID, jsonrpcErrInvalidParams, "params.subscription is required")
+		}
+		return jsonrpcResultResponse(request.ID, subs.Remove(params.Subscription))
+	case "list_subscriptions":
+		return jsonrpcResultResponse(request.ID, subs.List())
+	default:
+		if method, ok := websocketQueryMethods[request.Method]; ok {
+			result, rpcErr := method(ctx, s, rpcPrincipal{}, request.Params)
+			if rpcErr != nil {
+				return jsonrpcErrorResponse(request.ID, rpcErr.Code, rpcErr.Message)
+			}
+			return jsonrpcResultResponse(request.ID, result)
+		}
+		return jsonrpcErrorResponse(request.ID, jsonrpcErrMethodNotFound, fmt.Sprintf("unknown method %q", request.Method))
+	}
+}
+
+func jsonrpcResultResponse(id json.RawMessage, result any) []byte {
+	return mustMarshalJSONRPC(jsonrpcResponse{JSONRPC: jsonrpcVersion, ID: id, Result: result})
+}
+
+func jsonrpcErrorResponse(id json.RawMessage, code int, message string) []byte {
+	return mustMarshalJSONRPC(jsonrpcResponse{JSONRPC: jsonrpcVersion, ID: id, Error: &jsonrpcError{Code: code, Message: message}})
+}
+
+// mustMarshalJSONRPC marshals a response built entirely from this package's
+// own types, which can't fail to marshal; a failure here would be a bug in
+// one of those types, not bad client input, so it panics rather than
+// threading an unreachable error back through every caller.
+func mustMarshalJSONRPC(response jsonrpcResponse) []byte {
+	raw, err := json.Marshal(response)
+	if err != nil {
+		panic(fmt.Sprintf("jsonrpc response failed to marshal: %v", err))
+	}
+	return raw
+}
+
+// newJSONRPCNotification wraps result as the push counterpart to
+// eth_subscribe-style subscription notifications: method is always
+// "subscription", and params.subscription tells the client which of its
+// active subscriptions the payload belongs to.
+func newJSONRPCNotification(subscriptionID int64, result any) ([]byte, error) {
+	return json.Marshal(jsonrpcNotification{
+		JSONRPC: jsonrpcVersion,
+		Method:  "subscription",
+		Params: jsonrpcNotificationParams{
+			Subscription: subscriptionID,
+			Result:       result,
+		},
+	})
+}
+
+// jsonrpcSubscriptionSet tracks one websocket connection's JSON-RPC
+// subscriptions, each keyed by a numeric id handed back from "subscribe" so
+// a client can "unsubscribe" or correlate pushed notifications without
+// re-sending the channel name.
+type jsonrpcSubscriptionSet struct {
+	mu     sync.RWMutex
+	nextID int64
+	byID   map[int64]string
+}
+
+func newJSONRPCSubscriptionSet() *jsonrpcSubscriptionSet {
+	return &jsonrpcSubscriptionSet{byID: map[int64]string{}}
+}
+
+func (s *jsonrpcSubscriptionSet) Add(channel string) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	s.byID[s.nextID] = channel
+	return s.nextID
+}
+
+func (s *jsonrpcSubscriptionSet) Remove(subscriptionID int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.byID[subscriptionID]; !ok {
+		return false
+	}
+	delete(s.byID, subscriptionID)
+	return true
+}
+
+func (s *jsonrpcSubscriptionSet) List() []jsonrpcSubscriptionInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]jsonrpcSubscriptionInfo, 0, len(s.byID))
+	for id, channel := range s.byID {
+		out = append(out, jsonrpcSubscriptionInfo{Subscription: id, Channel: channel})
+	}
+	return out
+}
+
+// ByChannel groups active subscription ids by the channel they're
+// listening on, so the poll loop can fetch each channel's payload once and
+// fan it out to every subscriber of it.
+func (s *jsonrpcSubscriptionSet) ByChannel() map[string][]int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make(map[string][]int64, len(s.byID))
+	for id, channel := range s.byID {
+		out[channel] = append(out[channel], id)
+	}
+	return out
+}
@@ -0,0 +1,315 @@
+package config
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	consulapi "github.com/hashicorp/consul/api"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// RemoteConfigProvider is a remote KV-store backend for runtime config,
+// selected via CONFIG_PROVIDER. Values are returned keyed by the same
+// normalized env-style names flattenConfig produces (e.g.
+// "KEEPER_POLL_INTERVAL"), so they can be merged into the same precedence
+// chain as YAML and env-var values. Watch is not wired into the hot-reload
+// path yet; it exists so a future change can push remote updates through
+// the same config.Subscribe mechanism used for file-based reloads.
+type RemoteConfigProvider interface {
+	Fetch(ctx context.Context) (map[string]string, error)
+	Watch(ctx context.Context, onChange func(map[string]string)) error
+	Close() error
+}
+
+var (
+	remoteProviderOnce sync.Once
+	remoteProvider     RemoteConfigProvider
+	remoteProviderErr  error
+
+	remoteCacheMu     sync.RWMutex
+	remoteCacheValues map[string]string
+	remoteCacheAt     time.Time
+)
+
+const remoteConfigCacheTTL = 30 * time.Second
+
+// ensureRemoteConfigProvider lazily constructs the provider selected by
+// CONFIG_PROVIDER. An empty or "file" value means no remote provider is in
+// use, which is the common case and must not fail.
+func ensureRemoteConfigProvider() (RemoteConfigProvider, error) {
+	remoteProviderOnce.Do(func() {
+		switch kind := strings.ToLower(strings.TrimSpace(os.Getenv("CONFIG_PROVIDER"))); kind {
+		case "", "file":
+			return
+		case "etcd":
+			remoteProvider, remoteProviderErr = newEtcdConfigProvider()
+		case "consul":
+			remoteProvider, remoteProviderErr = newConsulConfigProvider()
+		default:
+			remoteProviderErr = fmt.Errorf("unknown CONFIG_PROVIDER %q", kind)
+		}
+	})
+	return remoteProvider, remoteProviderErr
+}
+
+// remoteValueForKey consults the remote provider (if any) for key, using a
+// short-lived cache so LoadKeeperConfig/LoadIndexerConfig/LoadAPIServerConfig
+// don't each pay for a network round trip at startup.
+func remoteValueForKey(key string) (string, bool) {
+	provider, err := ensureRemoteConfigProvider()
+	if err != nil || provider == nil {
+		return "", false
+	}
+
+	values, err := remoteConfigSnapshot(provider)
+	if err != nil {
+		runtimeConfigLogger.Error("remote config fetch failed, falling back to file/default", "err", err)
+		return "", false
+	}
+
+	value, ok := values[key]
+	if !ok || strings.TrimSpace(value) == "" {
+		return "", false
+	}
+	return value, true
+}
+
+func remoteConfigSnapshot(provider RemoteConfigProvider) (map[string]string, error) {
+	remoteCacheMu.RLock()
+	if remoteCacheValues != nil && time.Since(remoteCacheAt) < remoteConfigCacheTTL {
+		values := remoteCacheValues
+		remoteCacheMu.RUnlock()
+		return values, nil
+	}
+	remoteCacheMu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	values, err := provider.Fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	remoteCacheMu.Lock()
+	remoteCacheValues = values
+	remoteCacheAt = time.Now()
+	remoteCacheMu.Unlock()
+
+	return values, nil
+}
+
+// etcdConfigProvider reads flattened config keys from an etcd v3 cluster
+// under a fixed key prefix, e.g. "/easyclaw/KEEPER_POLL_INTERVAL".
+type etcdConfigProvider struct {
+	client *clientv3.Client
+	prefix string
+}
+
+func newEtcdConfigProvider() (*etcdConfigProvider, error) {
+	endpoints := parseCSVEnv(os.Getenv("CONFIG_ETCD_ENDPOINTS"), nil)
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("CONFIG_ETCD_ENDPOINTS must be set when CONFIG_PROVIDER=etcd")
+	}
+
+	dialTimeout, err := envDuration("CONFIG_ETCD_DIAL_TIMEOUT", 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig, err := etcdTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   endpoints,
+		DialTimeout: dialTimeout,
+		Username:    os.Getenv("CONFIG_ETCD_USERNAME"),
+		Password:    os.Getenv("CONFIG_ETCD_PASSWORD"),
+		TLS:         tlsConfig,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create etcd client: %w", err)
+	}
+
+	prefix := envOrDefaultStr("CONFIG_ETCD_PREFIX", "/easyclaw/")
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	return &etcdConfigProvider{client: client, prefix: prefix}, nil
+}
+
+func etcdTLSConfig() (*tls.Config, error) {
+	certFile := os.Getenv("CONFIG_ETCD_TLS_CERT_FILE")
+	keyFile := os.Getenv("CONFIG_ETCD_TLS_KEY_FILE")
+	caFile := os.Getenv("CONFIG_ETCD_TLS_CA_FILE")
+	insecure := os.Getenv("CONFIG_ETCD_TLS_INSECURE_SKIP_VERIFY") == "true"
+
+	if certFile == "" && keyFile == "" && caFile == "" && !insecure {
+		return nil, nil
+	}
+
+	cfg := &tls.Config{InsecureSkipVerify: insecure}
+
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load etcd client cert: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	if caFile != "" {
+		caBytes, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("read etcd CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caBytes) {
+			return nil, fmt.Errorf("parse etcd CA file %q", caFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	return cfg, nil
+}
+
+func (p *etcdConfigProvider) Fetch(ctx context.Context) (map[string]string, error) {
+	resp, err := p.client.Get(ctx, p.prefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, fmt.Errorf("etcd get %q: %w", p.prefix, err)
+	}
+
+	out := make(map[string]string, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		key := strings.TrimPrefix(string(kv.Key), p.prefix)
+		if key == "" {
+			continue
+		}
+		out[key] = string(kv.Value)
+	}
+	return out, nil
+}
+
+func (p *etcdConfigProvider) Watch(ctx context.Context, onChange func(map[string]string)) error {
+	watchCh := p.client.Watch(ctx, p.prefix, clientv3.WithPrefix())
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case resp, ok := <-watchCh:
+			if !ok {
+				return fmt.Errorf("etcd watch channel closed for prefix %q", p.prefix)
+			}
+			if resp.Err() != nil {
+				return fmt.Errorf("etcd watch %q: %w", p.prefix, resp.Err())
+			}
+			values, err := p.Fetch(ctx)
+			if err != nil {
+				return err
+			}
+			onChange(values)
+		}
+	}
+}
+
+func (p *etcdConfigProvider) Close() error {
+	return p.client.Close()
+}
+
+// consulConfigProvider reads flattened config keys from Consul's KV store
+// under a fixed key prefix and watches for changes using blocking queries.
+type consulConfigProvider struct {
+	client *consulapi.Client
+	prefix string
+}
+
+func newConsulConfigProvider() (*consulConfigProvider, error) {
+	cfg := consulapi.DefaultConfig()
+	if addr := strings.TrimSpace(os.Getenv("CONFIG_CONSUL_ADDRESS")); addr != "" {
+		cfg.Address = addr
+	}
+	if token := os.Getenv("CONFIG_CONSUL_TOKEN"); token != "" {
+		cfg.Token = token
+	}
+	if dc := os.Getenv("CONFIG_CONSUL_DATACENTER"); dc != "" {
+		cfg.Datacenter = dc
+	}
+
+	client, err := consulapi.NewClient(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("create consul client: %w", err)
+	}
+
+	prefix := envOrDefaultStr("CONFIG_CONSUL_PREFIX", "easyclaw/")
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	return &consulConfigProvider{client: client, prefix: prefix}, nil
+}
+
+func (p *consulConfigProvider) Fetch(ctx context.Context) (map[string]string, error) {
+	pairs, _, err := p.client.KV().List(p.prefix, (&consulapi.QueryOptions{}).WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("consul KV list %q: %w", p.prefix, err)
+	}
+
+	out := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		key := strings.TrimPrefix(pair.Key, p.prefix)
+		if key == "" {
+			continue
+		}
+		out[key] = string(pair.Value)
+	}
+	return out, nil
+}
+
+// Watch polls Consul's KV list with a blocking query, long-polling on the
+// KV prefix's modify index until it advances or the request times out.
+func (p *consulConfigProvider) Watch(ctx context.Context, onChange func(map[string]string)) error {
+	var waitIndex uint64
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		_, meta, err := p.client.KV().List(p.prefix, (&consulapi.QueryOptions{
+			WaitIndex: waitIndex,
+			WaitTime:  5 * time.Minute,
+		}).WithContext(ctx))
+		if err != nil {
+			return fmt.Errorf("consul KV blocking list %q: %w", p.prefix, err)
+		}
+		if meta.LastIndex == waitIndex {
+			continue
+		}
+		waitIndex = meta.LastIndex
+
+		values, err := p.Fetch(ctx)
+		if err != nil {
+			return err
+		}
+		onChange(values)
+	}
+}
+
+func (p *consulConfigProvider) Close() error {
+	return nil
+}
+
+func envOrDefaultStr(key, fallback string) string {
+	if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+		return value
+	}
+	return fallback
+}
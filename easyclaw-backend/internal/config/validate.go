@@ -0,0 +1,241 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Validate cross-checks invariants across KeeperConfig fields that the
+// individual envX helpers can't catch in isolation (they only know about
+// their own key).
+func (c KeeperConfig) Validate() error {
+	if err := validateRPCURL(c.RPCURL); err != nil {
+		return err
+	}
+	if c.PollInterval <= 0 {
+		return fmt.Errorf("KEEPER_POLL_INTERVAL must be positive, got %s", c.PollInterval)
+	}
+	if c.TxTimeout <= 0 {
+		return fmt.Errorf("KEEPER_TX_TIMEOUT must be positive, got %s", c.TxTimeout)
+	}
+	if c.PollInterval >= c.TxTimeout {
+		return fmt.Errorf("KEEPER_POLL_INTERVAL (%s) must be less than KEEPER_TX_TIMEOUT (%s)", c.PollInterval, c.TxTimeout)
+	}
+	if c.MaxOrdersPerTick <= 0 {
+		return fmt.Errorf("KEEPER_MAX_ORDERS_PER_TICK must be positive, got %d", c.MaxOrdersPerTick)
+	}
+	if c.MaxConcurrentExecutions <= 0 {
+		return fmt.Errorf("KEEPER_MAX_CONCURRENT_EXECUTIONS must be positive, got %d", c.MaxConcurrentExecutions)
+	}
+	if c.BatchByMarket && c.MaxOrdersPerBatch <= 0 {
+		return fmt.Errorf("KEEPER_MAX_ORDERS_PER_BATCH must be positive when KEEPER_BATCH_BY_MARKET is enabled, got %d", c.MaxOrdersPerBatch)
+	}
+	if err := validateComputeUnitPrice(c.ComputeUnitPriceMicroLamports); err != nil {
+		return err
+	}
+	if err := validatePriorityFee(c.PriorityFee); err != nil {
+		return err
+	}
+	for market, pyth := range c.PythPriceUpdateAccountByMarket {
+		if _, ok := c.OracleByMarket[market]; !ok {
+			return fmt.Errorf("market %d has a Pyth price update account (%s) but no entry in KEEPER_ORACLE_PRICES_JSON", market, pyth)
+		}
+	}
+	for market, kind := range c.OracleKindByMarket {
+		switch kind {
+		case OracleKindSwitchboardV2:
+			if _, ok := c.SwitchboardOracleByMarket[market]; !ok {
+				return fmt.Errorf("market %d is configured for switchboard_v2 but has no entry in KEEPER_SWITCHBOARD_ORACLES_JSON", market)
+			}
+		case OracleKindOrcaWhirlpoolTWAP:
+			if _, ok := c.OrcaWhirlpoolOracleByMarket[market]; !ok {
+				return fmt.Errorf("market %d is configured for orca_whirlpool_twap but has no entry in KEEPER_ORCA_WHIRLPOOL_ORACLES_JSON", market)
+			}
+		case OracleKindPythPush:
+			if _, ok := c.PythPriceUpdateAccountByMarket[market]; !ok {
+				return fmt.Errorf("market %d is configured for pyth_push but has no entry in KEEPER_PYTH_PRICE_UPDATE_ACCOUNTS_JSON", market)
+			}
+		case OracleKindStaticFallback:
+			if _, ok := c.OracleByMarket[market]; !ok {
+				return fmt.Errorf("market %d is configured for static_fallback but has no entry in KEEPER_ORACLE_PRICES_JSON", market)
+			}
+		default:
+			return fmt.Errorf("market %d has unknown oracle kind %q", market, kind)
+		}
+	}
+	return nil
+}
+
+// Validate cross-checks invariants across FundingArbConfig fields. An
+// empty Symbol means the feature is disabled, so everything else is left
+// unchecked.
+func (c FundingArbConfig) Validate() error {
+	if strings.TrimSpace(c.Symbol) == "" {
+		return nil
+	}
+	if c.QuoteInvestment <= 0 {
+		return fmt.Errorf("FUNDING_ARB_QUOTE_INVESTMENT must be positive, got %v", c.QuoteInvestment)
+	}
+	if c.IncrementalQuoteQuantity <= 0 {
+		return fmt.Errorf("FUNDING_ARB_INCREMENTAL_QUOTE_QUANTITY must be positive, got %v", c.IncrementalQuoteQuantity)
+	}
+	if c.IncrementalQuoteQuantity > c.QuoteInvestment {
+		return fmt.Errorf("FUNDING_ARB_INCREMENTAL_QUOTE_QUANTITY (%v) must be <= FUNDING_ARB_QUOTE_INVESTMENT (%v)", c.IncrementalQuoteQuantity, c.QuoteInvestment)
+	}
+	if c.Leverage <= 0 {
+		return fmt.Errorf("FUNDING_ARB_LEVERAGE must be positive, got %v", c.Leverage)
+	}
+	if c.ShortFundingRateHigh <= c.ShortFundingRateLow {
+		return fmt.Errorf("FUNDING_ARB_SHORT_FUNDING_RATE_HIGH (%v) must be > FUNDING_ARB_SHORT_FUNDING_RATE_LOW (%v)", c.ShortFundingRateHigh, c.ShortFundingRateLow)
+	}
+	if c.MarketID == 0 {
+		return fmt.Errorf("FUNDING_ARB_MARKET_ID must be set when FUNDING_ARB_SYMBOL is configured")
+	}
+	return nil
+}
+
+// Validate cross-checks invariants across IndexerConfig fields, including
+// ones that span multiple independently-loaded env vars.
+func (c IndexerConfig) Validate() error {
+	if err := validateRPCURL(c.RPCURL); err != nil {
+		return err
+	}
+	if c.PollInterval <= 0 {
+		return fmt.Errorf("INDEXER_POLL_INTERVAL must be positive, got %s", c.PollInterval)
+	}
+	if c.RPCRetryMaxDelay < c.RPCRetryBaseDelay {
+		return fmt.Errorf("INDEXER_RPC_RETRY_MAX_DELAY (%s) must be >= INDEXER_RPC_RETRY_BASE_DELAY (%s)", c.RPCRetryMaxDelay, c.RPCRetryBaseDelay)
+	}
+	if c.OrderbookRefreshInterval > c.OrderbookSnapshotInterval {
+		return fmt.Errorf("INDEXER_ORDERBOOK_REFRESH_INTERVAL (%s) must be <= INDEXER_ORDERBOOK_SNAPSHOT_INTERVAL (%s)", c.OrderbookRefreshInterval, c.OrderbookSnapshotInterval)
+	}
+	if c.OrderbookDepth <= 0 {
+		return fmt.Errorf("INDEXER_ORDERBOOK_DEPTH must be positive, got %d", c.OrderbookDepth)
+	}
+	if err := validateNoDuplicateOrderbookTargets(c.OrderbookTargets); err != nil {
+		return err
+	}
+	if err := validateConsolidatedTargets(c.ConsolidatedTargets, c.OrderbookTargets); err != nil {
+		return err
+	}
+	if c.EnablePythPriceStream {
+		if err := validatePythFeedMarkets(c.PythFeeds, c.PythFeedID, c.PythMarket); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Validate cross-checks invariants across APIServerConfig fields.
+func (c APIServerConfig) Validate() error {
+	if strings.TrimSpace(c.ListenAddr) == "" {
+		return fmt.Errorf("API_SERVER_LISTEN_ADDR must not be empty")
+	}
+	if c.ReadTimeout <= 0 {
+		return fmt.Errorf("API_SERVER_READ_TIMEOUT must be positive, got %s", c.ReadTimeout)
+	}
+	if c.WriteTimeout <= 0 {
+		return fmt.Errorf("API_SERVER_WRITE_TIMEOUT must be positive, got %s", c.WriteTimeout)
+	}
+	if c.IdleTimeout <= 0 {
+		return fmt.Errorf("API_SERVER_IDLE_TIMEOUT must be positive, got %s", c.IdleTimeout)
+	}
+	return nil
+}
+
+func validateRPCURL(rawURL string) error {
+	switch {
+	case strings.HasPrefix(rawURL, "http://"), strings.HasPrefix(rawURL, "https://"),
+		strings.HasPrefix(rawURL, "ws://"), strings.HasPrefix(rawURL, "wss://"):
+		return nil
+	default:
+		return fmt.Errorf("RPC URL %q must use http(s):// or ws(s)://", rawURL)
+	}
+}
+
+// maxSaneComputeUnitPriceMicroLamports guards against a config typo (e.g.
+// a value meant to be lamports, not micro-lamports) silently setting a
+// compute-unit price that would make every keeper transaction absurdly
+// expensive.
+const maxSaneComputeUnitPriceMicroLamports = 10_000_000
+
+func validateComputeUnitPrice(microLamports uint64) error {
+	if microLamports > maxSaneComputeUnitPriceMicroLamports {
+		return fmt.Errorf("KEEPER_COMPUTE_UNIT_PRICE_MICRO_LAMPORTS (%d) exceeds sane bound of %d", microLamports, maxSaneComputeUnitPriceMicroLamports)
+	}
+	return nil
+}
+
+// bpsDenomination mirrors the keeper package's bps basis-point
+// denominator (10,000 = 100%); a separate copy here avoids importing the
+// keeper package from config just for one constant.
+const bpsDenomination = 10_000
+
+func validatePriorityFee(cfg PriorityFeeConfig) error {
+	switch cfg.Strategy {
+	case PriorityFeeStrategyStatic, PriorityFeeStrategyPercentile, PriorityFeeStrategyAdaptive:
+	default:
+		return fmt.Errorf("KEEPER_PRIORITY_FEE_STRATEGY must be static, percentile, or adaptive, got %q", cfg.Strategy)
+	}
+	if cfg.Strategy != PriorityFeeStrategyStatic {
+		if cfg.Percentile <= 0 || cfg.Percentile > 100 {
+			return fmt.Errorf("KEEPER_PRIORITY_FEE_PERCENTILE must be in (0, 100], got %d", cfg.Percentile)
+		}
+		if cfg.MaxMicroLamports > 0 && cfg.MinMicroLamports > cfg.MaxMicroLamports {
+			return fmt.Errorf("KEEPER_PRIORITY_FEE_MIN_MICRO_LAMPORTS (%d) must be <= KEEPER_PRIORITY_FEE_MAX_MICRO_LAMPORTS (%d)", cfg.MinMicroLamports, cfg.MaxMicroLamports)
+		}
+	}
+	if cfg.Strategy == PriorityFeeStrategyAdaptive && cfg.AdaptiveMultiplierBps <= bpsDenomination {
+		return fmt.Errorf("KEEPER_PRIORITY_FEE_ADAPTIVE_MULTIPLIER_BPS must be > %d to actually raise the fee, got %d", bpsDenomination, cfg.AdaptiveMultiplierBps)
+	}
+	return nil
+}
+
+func validateNoDuplicateOrderbookTargets(targets []OrderbookTarget) error {
+	seen := make(map[OrderbookTarget]bool, len(targets))
+	for _, target := range targets {
+		if seen[target] {
+			return fmt.Errorf("duplicate INDEXER_ORDERBOOK_TARGETS entry %s:%s", target.Exchange, target.Symbol)
+		}
+		seen[target] = true
+	}
+	return nil
+}
+
+// validateConsolidatedTargets requires every (venue, symbol) pair a
+// consolidated target merges to also be a plain INDEXER_ORDERBOOK_TARGETS
+// entry, since the aggregator only sees data that orderbookCollector is
+// already collecting for that venue.
+func validateConsolidatedTargets(consolidated []ConsolidatedTarget, orderbookTargets []OrderbookTarget) error {
+	collected := make(map[OrderbookTarget]bool, len(orderbookTargets))
+	for _, target := range orderbookTargets {
+		collected[target] = true
+	}
+
+	for _, group := range consolidated {
+		for _, venue := range group.Venues {
+			target := OrderbookTarget{Exchange: venue, Symbol: group.Symbol}
+			if !collected[target] {
+				return fmt.Errorf("consolidated target %s references venue %q with no matching INDEXER_ORDERBOOK_TARGETS entry", group.Symbol, venue)
+			}
+		}
+	}
+	return nil
+}
+
+func validatePythFeedMarkets(feeds []PythFeed, singleFeedID, singleMarket string) error {
+	markets := make(map[string]bool, len(feeds)+1)
+	for _, feed := range feeds {
+		if strings.TrimSpace(feed.Market) == "" {
+			return fmt.Errorf("INDEXER_PYTH_FEEDS entry for feed %q has no market", feed.FeedID)
+		}
+		markets[feed.Market] = true
+	}
+	if strings.TrimSpace(singleFeedID) != "" {
+		markets[singleMarket] = true
+	}
+	if len(markets) == 0 {
+		return fmt.Errorf("INDEXER_ENABLE_PYTH_PRICE_STREAM is true but no Pyth feed or market is configured")
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package indexer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// AccessTokenRecord is a short-lived, opaque credential minted from an
+// existing session for the JSON-RPC surface (see apiserver's /v1/rpc), in
+// the style of bytom/vapor's accesstoken package: unlike APITokenRecord it
+// carries no scope list, only a coarse Role and a rate-limit budget, since
+// it's meant for SDK/automation round trips rather than delegated access to
+// a narrow slice of the account.
+type AccessTokenRecord struct {
+	ID                 string
+	TokenHash          string
+	WalletPubkey       string
+	Role               string
+	RateLimitPerMinute int
+	CreatedAt          int64
+	ExpiresAt          int64
+	RevokedAt          *int64
+}
+
+func (s *Store) CreateAccessToken(ctx context.Context, token AccessTokenRecord) error {
+	_, err := s.db.ExecContext(
+		ctx,
+		`INSERT INTO access_tokens (id, token_hash, wallet_pubkey, role, rate_limit_per_minute, created_at, expires_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		token.ID,
+		token.TokenHash,
+		token.WalletPubkey,
+		token.Role,
+		token.RateLimitPerMinute,
+		token.CreatedAt,
+		token.ExpiresAt,
+	)
+	return err
+}
+
+func (s *Store) GetAccessTokenByHash(ctx context.Context, tokenHash string) (AccessTokenRecord, error) {
+	row := s.db.QueryRowContext(
+		ctx,
+		`SELECT id, token_hash, wallet_pubkey, role, rate_limit_per_minute, created_at, expires_at, revoked_at
+		 FROM access_tokens
+		 WHERE token_hash = ?`,
+		tokenHash,
+	)
+	var out AccessTokenRecord
+	var revokedAt sql.NullInt64
+	if err := row.Scan(
+		&out.ID,
+		&out.TokenHash,
+		&out.WalletPubkey,
+		&out.Role,
+		&out.RateLimitPerMinute,
+		&out.CreatedAt,
+		&out.ExpiresAt,
+		&revokedAt,
+	); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return AccessTokenRecord{}, ErrNotFound
+		}
+		return AccessTokenRecord{}, err
+	}
+	if revokedAt.Valid {
+		out.RevokedAt = &revokedAt.Int64
+	}
+	return out, nil
+}
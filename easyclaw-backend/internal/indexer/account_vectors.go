@@ -0,0 +1,63 @@
+package indexer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// AccountVector is one captured Solana account payload plus the parsed
+// shape and DB row it's expected to produce once ParseAccount_* and the
+// matching Upsert*Tx have run against it. account_vectors_test.go replays
+// these against a real Postgres to catch drift between the on-chain
+// account layout and the indexer's parsing/upsert assumptions, which
+// otherwise only shows up once the indexer runs against mainnet.
+type AccountVector struct {
+	Name           string          `json:"name"`
+	AccountType    string          `json:"account_type"`
+	ProgramID      string          `json:"program_id"`
+	Pubkey         string          `json:"pubkey"`
+	Owner          string          `json:"owner"`
+	Lamports       uint64          `json:"lamports"`
+	Slot           uint64          `json:"slot"`
+	DataBase64     string          `json:"data_base64"`
+	ExpectedParsed json.RawMessage `json:"expected_parsed"`
+	ExpectedRow    json.RawMessage `json:"expected_row"`
+}
+
+// LoadAccountVectors reads every *.json file in dir as an AccountVector,
+// sorted by filename so a run is deterministic.
+func LoadAccountVectors(dir string) ([]AccountVector, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read vectors dir: %w", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+
+	vectors := make([]AccountVector, 0, len(names))
+	for _, name := range names {
+		raw, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("read vector %s: %w", name, err)
+		}
+		var vector AccountVector
+		if err := json.Unmarshal(raw, &vector); err != nil {
+			return nil, fmt.Errorf("parse vector %s: %w", name, err)
+		}
+		if vector.Name == "" {
+			vector.Name = name
+		}
+		vectors = append(vectors, vector)
+	}
+	return vectors, nil
+}
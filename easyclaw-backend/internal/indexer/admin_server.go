@@ -0,0 +1,107 @@
+package indexer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// runAdminServer starts the operator toggle endpoint in the background
+// when INDEXER_ADMIN_ADDR is configured, mirroring runOrderbookBroker's
+// pattern of an optional internal HTTP server bound to its own address.
+func (s *Service) runAdminServer(ctx context.Context) {
+	addr := strings.TrimSpace(s.cfg.AdminListenAddr)
+	if addr == "" {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/admin/toggles", s.handleAdminToggles)
+	mux.HandleFunc("/admin/toggles/blocklist", s.handleAdminBlocklist)
+
+	server := &http.Server{
+		Addr:    addr,
+		Handler: mux,
+	}
+
+	go func() {
+		<-ctx.Done()
+		_ = server.Shutdown(context.Background())
+	}()
+
+	go func() {
+		s.logger.Info("indexer admin server started", "listen_addr", addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error("indexer admin server exited with error", "err", err)
+		}
+	}()
+}
+
+type setSubsystemToggleRequest struct {
+	Subsystem string `json:"subsystem"`
+	Enabled   bool   `json:"enabled"`
+}
+
+// handleAdminToggles returns the current subsystem toggle state on GET,
+// and flips one subsystem on POST.
+func (s *Service) handleAdminToggles(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		respondJSON(w, http.StatusOK, s.Toggles())
+
+	case http.MethodPost:
+		var request setSubsystemToggleRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			respondJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := s.SetSubsystemToggle(r.Context(), request.Subsystem, request.Enabled); err != nil {
+			respondJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		respondJSON(w, http.StatusOK, s.Toggles())
+
+	default:
+		respondJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+type setAccountTypeBlockedRequest struct {
+	AccountType string `json:"account_type"`
+	Blocked     bool   `json:"blocked"`
+}
+
+// handleAdminBlocklist adds or removes one account type from the scan
+// blocklist on POST.
+func (s *Service) handleAdminBlocklist(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	var request setAccountTypeBlockedRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		respondJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if strings.TrimSpace(request.AccountType) == "" {
+		respondJSONError(w, http.StatusBadRequest, "account_type is required")
+		return
+	}
+	if err := s.SetAccountTypeBlocked(r.Context(), request.AccountType, request.Blocked); err != nil {
+		respondJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, s.Toggles())
+}
+
+func respondJSON(w http.ResponseWriter, code int, payload any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(payload)
+}
+
+func respondJSONError(w http.ResponseWriter, code int, message string) {
+	respondJSON(w, code, map[string]string{"error": message})
+}
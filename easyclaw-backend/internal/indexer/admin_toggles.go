@@ -0,0 +1,74 @@
+package indexer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// IndexerToggles is the persisted, operator-controlled runtime state for
+// the indexer's sync subsystems. IndexerConfig covers boot-time
+// configuration; this covers the subset operators need to flip without a
+// restart (e.g. suppress orderbook writes during a CEX incident, or stop
+// LP vault scans during a redeploy) while keeping orderbookCollector's
+// warm state intact.
+type IndexerToggles struct {
+	ConsiderOrderEngine        bool `json:"consider_order_engine"`
+	ConsiderMarketRegistry     bool `json:"consider_market_registry"`
+	ConsiderLPVault            bool `json:"consider_lp_vault"`
+	ConsiderOrderbookSnapshots bool `json:"consider_orderbook_snapshots"`
+	ConsiderPythStream         bool `json:"consider_pyth_stream"`
+	// BlockedAccountTypes skips scanAndStore for the named account types
+	// (e.g. "Order") while the rest of that subsystem's scan proceeds
+	// (e.g. "UserMarketPosition" keeps syncing).
+	BlockedAccountTypes map[string]bool `json:"blocked_account_types,omitempty"`
+}
+
+// defaultIndexerToggles is every subsystem enabled and nothing blocked,
+// matching pre-chunk7-1 always-on behavior.
+func defaultIndexerToggles() IndexerToggles {
+	return IndexerToggles{
+		ConsiderOrderEngine:        true,
+		ConsiderMarketRegistry:     true,
+		ConsiderLPVault:            true,
+		ConsiderOrderbookSnapshots: true,
+		ConsiderPythStream:         true,
+	}
+}
+
+// GetIndexerToggles loads the current operator toggle state, defaulting
+// everything on when no state has ever been saved.
+func (s *Store) GetIndexerToggles(ctx context.Context) (IndexerToggles, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT toggles_json FROM indexer_toggles WHERE id = 1`)
+	var togglesJSON string
+	if err := row.Scan(&togglesJSON); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return defaultIndexerToggles(), nil
+		}
+		return IndexerToggles{}, err
+	}
+	toggles := defaultIndexerToggles()
+	if err := json.Unmarshal([]byte(togglesJSON), &toggles); err != nil {
+		return IndexerToggles{}, err
+	}
+	return toggles, nil
+}
+
+// SetIndexerToggles persists toggles so a restart preserves the last
+// operator setting instead of reverting to everything-on.
+func (s *Store) SetIndexerToggles(ctx context.Context, toggles IndexerToggles) error {
+	togglesJSON, err := json.Marshal(toggles)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO indexer_toggles (id, toggles_json, updated_at)
+		VALUES (1, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			toggles_json = excluded.toggles_json,
+			updated_at = excluded.updated_at
+	`, string(togglesJSON), time.Now().Unix())
+	return err
+}
@@ -0,0 +1,261 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// fillSummaryBucket names an incrementally-maintained fills rollup table
+// and the bucket width (in seconds) it pre-aggregates to. UpsertOrderTx
+// keeps every bucket in sync on each new fill; AggregateFills queries the
+// matching table directly when a caller asks for one of these widths, so
+// the read stays O(buckets-in-range) instead of O(fills-in-range).
+type fillSummaryBucket struct {
+	table   string
+	seconds int64
+}
+
+var fillSummaryBuckets = []fillSummaryBucket{
+	{table: "fills_1m", seconds: 60},
+	{table: "fills_1h", seconds: 3600},
+}
+
+const defaultAggregateBucketSeconds = 60
+
+// AggregateFilter scopes a fill aggregation to a user, market, and/or time
+// range. It mirrors FillFilter's filter shape, minus pagination: an
+// aggregate returns one row per bucket, not one row per fill.
+type AggregateFilter struct {
+	UserMargin string
+	MarketID   *uint64
+	FromUnix   int64
+	ToUnix     int64
+
+	// BucketSeconds is the width of each time bucket. Zero defaults to
+	// 60. Values of 60 or 3600 are served from the fills_1m/fills_1h
+	// summary tables; any other value falls back to aggregating the raw
+	// fills table directly.
+	BucketSeconds int64
+}
+
+// FillBucket is one time bucket of aggregated fill activity for a market.
+// VolumeBase and VolumeQuote are decimal strings (not float64) for the
+// same reason FillRecord.Margin and FillRecord.Price are: they're sums of
+// on-chain raw fixed-point amounts that can exceed float64's exact range.
+type FillBucket struct {
+	MarketID    uint64 `json:"market_id"`
+	BucketTS    int64  `json:"bucket_ts"`
+	FillCount   int64  `json:"fill_count"`
+	VolumeBase  string `json:"volume_base"`
+	VolumeQuote string `json:"volume_quote"`
+}
+
+// AggregateFills buckets fills by time, ordered by bucket_ts DESC, market_id
+// ASC. See AggregateFilter.BucketSeconds for which widths hit the
+// incrementally-maintained summary tables versus scanning raw fills.
+func (s *Store) AggregateFills(ctx context.Context, filter AggregateFilter) ([]FillBucket, error) {
+	bucketSeconds := filter.BucketSeconds
+	if bucketSeconds <= 0 {
+		bucketSeconds = defaultAggregateBucketSeconds
+	}
+
+	summaryTable := ""
+	for _, bucket := range fillSummaryBuckets {
+		if bucket.seconds == bucketSeconds {
+			summaryTable = bucket.table
+			break
+		}
+	}
+
+	tsColumn := "executed_at"
+	if summaryTable != "" {
+		tsColumn = "bucket_ts"
+	}
+
+	clauses := []string{"1 = 1"}
+	args := make([]any, 0, 4)
+	if filter.UserMargin != "" {
+		clauses = append(clauses, "user_margin = ?")
+		args = append(args, filter.UserMargin)
+	}
+	if filter.MarketID != nil {
+		clauses = append(clauses, "market_id = ?")
+		args = append(args, int64(*filter.MarketID))
+	}
+	if filter.FromUnix > 0 {
+		clauses = append(clauses, tsColumn+" >= ?")
+		args = append(args, filter.FromUnix)
+	}
+	if filter.ToUnix > 0 {
+		clauses = append(clauses, tsColumn+" < ?")
+		args = append(args, filter.ToUnix)
+	}
+
+	var query string
+	if summaryTable != "" {
+		query = fmt.Sprintf(`
+			SELECT
+				market_id,
+				bucket_ts,
+				SUM(fill_count) AS fill_count,
+				SUM(volume_base)::TEXT AS volume_base,
+				SUM(volume_quote)::TEXT AS volume_quote
+			FROM %s
+			WHERE %s
+			GROUP BY market_id, bucket_ts
+			ORDER BY bucket_ts DESC, market_id ASC
+		`, summaryTable, strings.Join(clauses, " AND "))
+	} else {
+		query = fmt.Sprintf(`
+			SELECT
+				market_id,
+				(executed_at / ?) * ? AS bucket_ts,
+				COUNT(*) AS fill_count,
+				SUM(CAST(margin AS NUMERIC))::TEXT AS volume_base,
+				SUM(CAST(margin AS NUMERIC) * CAST(price AS NUMERIC))::TEXT AS volume_quote
+			FROM fills
+			WHERE %s
+			GROUP BY market_id, bucket_ts
+			ORDER BY bucket_ts DESC, market_id ASC
+		`, strings.Join(clauses, " AND "))
+		args = append([]any{bucketSeconds, bucketSeconds}, args...)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	buckets := make([]FillBucket, 0)
+	for rows.Next() {
+		var item FillBucket
+		var marketID int64
+		if err := rows.Scan(&marketID, &item.BucketTS, &item.FillCount, &item.VolumeBase, &item.VolumeQuote); err != nil {
+			return nil, err
+		}
+		item.MarketID = uint64(marketID)
+		buckets = append(buckets, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return buckets, nil
+}
+
+// PositionPnLFilter scopes a PnL snapshot to a user and/or market,
+// mirroring PositionFilter's filter shape minus pagination: a snapshot
+// returns one row per (user_margin, market_id) pair, not one row per
+// position.
+type PositionPnLFilter struct {
+	UserMargin string
+	MarketID   *uint64
+}
+
+// PositionPnLSnapshot is the aggregated unrealized PnL across a user's
+// open positions in one market, marked against that market's most recent
+// fill price. It's a last-trade mark, not an oracle mark: MarkPrice is 0
+// (and UnrealizedPnL reduces to -EntryNotional) for a market with no fills
+// yet.
+type PositionPnLSnapshot struct {
+	UserMargin    string `json:"user_margin"`
+	MarketID      uint64 `json:"market_id"`
+	PositionCount int64  `json:"position_count"`
+	NetQty        string `json:"net_qty"`
+	EntryNotional string `json:"entry_notional"`
+	MarkPrice     string `json:"mark_price"`
+	UnrealizedPnL string `json:"unrealized_pnl"`
+}
+
+// PositionPnLSnapshot aggregates open positions by (user_margin, market_id),
+// ordered by user_margin ASC, market_id ASC.
+func (s *Store) PositionPnLSnapshot(ctx context.Context, filter PositionPnLFilter) ([]PositionPnLSnapshot, error) {
+	clauses := []string{"1 = 1"}
+	args := make([]any, 0, 2)
+	if filter.UserMargin != "" {
+		clauses = append(clauses, "p.user_margin = ?")
+		args = append(args, filter.UserMargin)
+	}
+	if filter.MarketID != nil {
+		clauses = append(clauses, "p.market_id = ?")
+		args = append(args, int64(*filter.MarketID))
+	}
+
+	query := fmt.Sprintf(`
+		WITH mark_prices AS (
+			SELECT DISTINCT ON (market_id) market_id, price AS mark_price
+			FROM fills
+			ORDER BY market_id, executed_at DESC, id DESC
+		)
+		SELECT
+			p.user_margin,
+			p.market_id,
+			COUNT(*) AS position_count,
+			SUM(CAST(p.long_qty AS NUMERIC) - CAST(p.short_qty AS NUMERIC))::TEXT AS net_qty,
+			SUM(CAST(p.long_entry_notional AS NUMERIC) - CAST(p.short_entry_notional AS NUMERIC))::TEXT AS entry_notional,
+			COALESCE(MAX(mp.mark_price), '0') AS mark_price,
+			(
+				SUM(CAST(p.long_qty AS NUMERIC) - CAST(p.short_qty AS NUMERIC)) * CAST(COALESCE(MAX(mp.mark_price), '0') AS NUMERIC)
+				- SUM(CAST(p.long_entry_notional AS NUMERIC) - CAST(p.short_entry_notional AS NUMERIC))
+			)::TEXT AS unrealized_pnl
+		FROM positions p
+		LEFT JOIN mark_prices mp ON mp.market_id = p.market_id
+		WHERE %s
+		GROUP BY p.user_margin, p.market_id
+		ORDER BY p.user_margin ASC, p.market_id ASC
+	`, strings.Join(clauses, " AND "))
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snapshots := make([]PositionPnLSnapshot, 0)
+	for rows.Next() {
+		var item PositionPnLSnapshot
+		var marketID int64
+		if err := rows.Scan(
+			&item.UserMargin,
+			&marketID,
+			&item.PositionCount,
+			&item.NetQty,
+			&item.EntryNotional,
+			&item.MarkPrice,
+			&item.UnrealizedPnL,
+		); err != nil {
+			return nil, err
+		}
+		item.MarketID = uint64(marketID)
+		snapshots = append(snapshots, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snapshots, nil
+}
+
+// upsertFillSummaryTx increments every fillSummaryBuckets row for one new
+// fill. Callers must only invoke this once per fill (UpsertOrderTx checks
+// the fills INSERT's RowsAffected first) since it's a running increment,
+// not an idempotent upsert.
+func (s *Store) upsertFillSummaryTx(ctx context.Context, tx *Tx, userMargin string, marketID uint64, marginRaw, priceRaw string, executedAt int64) error {
+	for _, bucket := range fillSummaryBuckets {
+		bucketTS := (executedAt / bucket.seconds) * bucket.seconds
+		query := fmt.Sprintf(`
+			INSERT INTO %[1]s (market_id, user_margin, bucket_ts, fill_count, volume_base, volume_quote)
+			VALUES (?, ?, ?, 1, CAST(? AS NUMERIC), CAST(? AS NUMERIC) * CAST(? AS NUMERIC))
+			ON CONFLICT (market_id, user_margin, bucket_ts) DO UPDATE SET
+				fill_count = %[1]s.fill_count + excluded.fill_count,
+				volume_base = %[1]s.volume_base + excluded.volume_base,
+				volume_quote = %[1]s.volume_quote + excluded.volume_quote
+		`, bucket.table)
+		if _, err := tx.ExecContext(ctx, query, int64(marketID), userMargin, bucketTS, marginRaw, marginRaw, priceRaw); err != nil {
+			return fmt.Errorf("upsert %s: %w", bucket.table, err)
+		}
+	}
+	return nil
+}
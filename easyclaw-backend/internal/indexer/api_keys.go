@@ -0,0 +1,135 @@
+package indexer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+)
+
+// APIKeyRecord is an api_key/api_secret pair for HMAC-signed programmatic
+// access (see apiserver's requireScope and its hmacCredsFromRequest
+// sibling), the exchange-style counterpart to APITokenRecord's bearer
+// tokens. Unlike APITokenRecord.TokenHash, APISecret is stored readable
+// rather than as a one-way hash: the server has to recompute the same
+// HMAC the caller signed with, not just compare digests.
+type APIKeyRecord struct {
+	ID           string
+	APIKey       string
+	APISecret    string
+	WalletPubkey string
+	Scopes       []string
+	CreatedAt    int64
+	ExpiresAt    int64
+	RevokedAt    *int64
+}
+
+func (s *Store) CreateAPIKey(ctx context.Context, key APIKeyRecord) error {
+	scopesJSON, err := json.Marshal(key.Scopes)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(
+		ctx,
+		`INSERT INTO api_keys (id, api_key, api_secret, wallet_pubkey, scopes_json, created_at, expires_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		key.ID,
+		key.APIKey,
+		key.APISecret,
+		key.WalletPubkey,
+		string(scopesJSON),
+		key.CreatedAt,
+		key.ExpiresAt,
+	)
+	return err
+}
+
+func (s *Store) GetAPIKeyByKey(ctx context.Context, apiKey string) (APIKeyRecord, error) {
+	row := s.db.QueryRowContext(
+		ctx,
+		`SELECT id, api_key, api_secret, wallet_pubkey, scopes_json, created_at, expires_at, revoked_at
+		 FROM api_keys
+		 WHERE api_key = ?`,
+		apiKey,
+	)
+	out, err := scanAPIKeyRow(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return APIKeyRecord{}, ErrNotFound
+	}
+	return out, err
+}
+
+func (s *Store) ListAPIKeys(ctx context.Context, walletPubkey string) ([]APIKeyRecord, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT id, api_key, api_secret, wallet_pubkey, scopes_json, created_at, expires_at, revoked_at
+		 FROM api_keys
+		 WHERE wallet_pubkey = ?
+		 ORDER BY created_at DESC`,
+		walletPubkey,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]APIKeyRecord, 0)
+	for rows.Next() {
+		item, err := scanAPIKeyRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (s *Store) RevokeAPIKey(ctx context.Context, walletPubkey, keyID string, revokedAt int64) error {
+	result, err := s.db.ExecContext(
+		ctx,
+		`UPDATE api_keys
+		 SET revoked_at = ?
+		 WHERE id = ?
+		   AND wallet_pubkey = ?
+		   AND revoked_at IS NULL`,
+		revokedAt,
+		keyID,
+		walletPubkey,
+	)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func scanAPIKeyRow(row rowScanner) (APIKeyRecord, error) {
+	var out APIKeyRecord
+	var scopesJSON string
+	var revokedAt sql.NullInt64
+	if err := row.Scan(
+		&out.ID,
+		&out.APIKey,
+		&out.APISecret,
+		&out.WalletPubkey,
+		&scopesJSON,
+		&out.CreatedAt,
+		&out.ExpiresAt,
+		&revokedAt,
+	); err != nil {
+		return APIKeyRecord{}, err
+	}
+	if err := json.Unmarshal([]byte(scopesJSON), &out.Scopes); err != nil {
+		return APIKeyRecord{}, err
+	}
+	if revokedAt.Valid {
+		out.RevokedAt = &revokedAt.Int64
+	}
+	return out, nil
+}
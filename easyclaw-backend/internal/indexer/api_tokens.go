@@ -0,0 +1,253 @@
+package indexer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+)
+
+// APITokenRecord is a long-lived, scope-restricted credential a wallet
+// owner mints after signing in (directly, or via the OAuth authorization-
+// code exchange), for automation and delegated-access use cases that
+// shouldn't require holding a full session token to exercise a narrow
+// slice of the API.
+type APITokenRecord struct {
+	ID           string
+	TokenHash    string
+	WalletPubkey string
+	Scopes       []string
+	AgentIDs     []string // empty means no agent allowlist restriction
+	CreatedAt    int64
+	ExpiresAt    int64
+	RevokedAt    *int64
+}
+
+func (s *Store) CreateAPIToken(ctx context.Context, token APITokenRecord) error {
+	scopesJSON, err := json.Marshal(token.Scopes)
+	if err != nil {
+		return err
+	}
+	agentIDsJSON, err := json.Marshal(token.AgentIDs)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(
+		ctx,
+		`INSERT INTO api_tokens (id, token_hash, wallet_pubkey, scopes_json, agent_ids_json, created_at, expires_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		token.ID,
+		token.TokenHash,
+		token.WalletPubkey,
+		string(scopesJSON),
+		string(agentIDsJSON),
+		token.CreatedAt,
+		token.ExpiresAt,
+	)
+	return err
+}
+
+func (s *Store) GetAPITokenByHash(ctx context.Context, tokenHash string) (APITokenRecord, error) {
+	return scanAPIToken(s.db.QueryRowContext(
+		ctx,
+		`SELECT id, token_hash, wallet_pubkey, scopes_json, agent_ids_json, created_at, expires_at, revoked_at
+		 FROM api_tokens
+		 WHERE token_hash = ?`,
+		tokenHash,
+	))
+}
+
+func (s *Store) ListAPITokens(ctx context.Context, walletPubkey string) ([]APITokenRecord, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT id, token_hash, wallet_pubkey, scopes_json, agent_ids_json, created_at, expires_at, revoked_at
+		 FROM api_tokens
+		 WHERE wallet_pubkey = ?
+		 ORDER BY created_at DESC`,
+		walletPubkey,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]APITokenRecord, 0)
+	for rows.Next() {
+		item, err := scanAPITokenRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (s *Store) RevokeAPIToken(ctx context.Context, walletPubkey, tokenID string, revokedAt int64) error {
+	result, err := s.db.ExecContext(
+		ctx,
+		`UPDATE api_tokens
+		 SET revoked_at = ?
+		 WHERE id = ?
+		   AND wallet_pubkey = ?
+		   AND revoked_at IS NULL`,
+		revokedAt,
+		tokenID,
+		walletPubkey,
+	)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAPIToken(row *sql.Row) (APITokenRecord, error) {
+	out, err := scanAPITokenRow(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return APITokenRecord{}, ErrNotFound
+	}
+	return out, err
+}
+
+func scanAPITokenRow(row rowScanner) (APITokenRecord, error) {
+	var out APITokenRecord
+	var scopesJSON, agentIDsJSON string
+	var revokedAt sql.NullInt64
+	if err := row.Scan(
+		&out.ID,
+		&out.TokenHash,
+		&out.WalletPubkey,
+		&scopesJSON,
+		&agentIDsJSON,
+		&out.CreatedAt,
+		&out.ExpiresAt,
+		&revokedAt,
+	); err != nil {
+		return APITokenRecord{}, err
+	}
+	if err := json.Unmarshal([]byte(scopesJSON), &out.Scopes); err != nil {
+		return APITokenRecord{}, err
+	}
+	if err := json.Unmarshal([]byte(agentIDsJSON), &out.AgentIDs); err != nil {
+		return APITokenRecord{}, err
+	}
+	if revokedAt.Valid {
+		out.RevokedAt = &revokedAt.Int64
+	}
+	return out, nil
+}
+
+// OAuthCodeRecord is a short-lived authorization code minted by
+// /oauth/authorize and redeemed exactly once by /oauth/token, so a
+// third-party client can obtain a scoped API token without the owner's
+// wallet signature or session token ever passing through it.
+type OAuthCodeRecord struct {
+	Code         string
+	ClientID     string
+	WalletPubkey string
+	Scopes       []string
+	AgentIDs     []string
+	CreatedAt    int64
+	ExpiresAt    int64
+	UsedAt       *int64
+}
+
+func (s *Store) CreateOAuthCode(ctx context.Context, code OAuthCodeRecord) error {
+	scopesJSON, err := json.Marshal(code.Scopes)
+	if err != nil {
+		return err
+	}
+	agentIDsJSON, err := json.Marshal(code.AgentIDs)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(
+		ctx,
+		`INSERT INTO oauth_codes (code, client_id, wallet_pubkey, scopes_json, agent_ids_json, created_at, expires_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		code.Code,
+		code.ClientID,
+		code.WalletPubkey,
+		string(scopesJSON),
+		string(agentIDsJSON),
+		code.CreatedAt,
+		code.ExpiresAt,
+	)
+	return err
+}
+
+// RedeemOAuthCode atomically marks code used and returns it, so two
+// concurrent exchanges of the same code can't both succeed.
+func (s *Store) RedeemOAuthCode(ctx context.Context, code string, usedAt int64) (OAuthCodeRecord, error) {
+	var out OAuthCodeRecord
+	err := s.WithTx(ctx, func(tx *Tx) error {
+		row := tx.QueryRowContext(
+			ctx,
+			`SELECT code, client_id, wallet_pubkey, scopes_json, agent_ids_json, created_at, expires_at, used_at
+			 FROM oauth_codes
+			 WHERE code = ?`,
+			code,
+		)
+		var scopesJSON, agentIDsJSON string
+		var usedAtCol sql.NullInt64
+		if err := row.Scan(
+			&out.Code,
+			&out.ClientID,
+			&out.WalletPubkey,
+			&scopesJSON,
+			&agentIDsJSON,
+			&out.CreatedAt,
+			&out.ExpiresAt,
+			&usedAtCol,
+		); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrNotFound
+			}
+			return err
+		}
+		if usedAtCol.Valid {
+			return ErrUnauthorized
+		}
+		if out.ExpiresAt <= usedAt {
+			return ErrUnauthorized
+		}
+		if err := json.Unmarshal([]byte(scopesJSON), &out.Scopes); err != nil {
+			return err
+		}
+		if err := json.Unmarshal([]byte(agentIDsJSON), &out.AgentIDs); err != nil {
+			return err
+		}
+
+		result, err := tx.ExecContext(
+			ctx,
+			`UPDATE oauth_codes SET used_at = ? WHERE code = ? AND used_at IS NULL`,
+			usedAt,
+			code,
+		)
+		if err != nil {
+			return err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return ErrUnauthorized
+		}
+		return nil
+	})
+	if err != nil {
+		return OAuthCodeRecord{}, err
+	}
+	return out, nil
+}
@@ -0,0 +1,461 @@
+package indexer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// ReplaySizingRule selects how BacktestReplayParams.SizingValue is applied
+// when ReplayBacktest rescales a trade's notional for the synthetic run.
+type ReplaySizingRule string
+
+const (
+	ReplaySizingFixed         ReplaySizingRule = "fixed"          // SizingValue is a flat USDC notional per trade
+	ReplaySizingPercentEquity ReplaySizingRule = "percent_equity" // SizingValue is a percent of the running synthetic equity
+	ReplaySizingKelly         ReplaySizingRule = "kelly"          // SizingValue caps the Kelly fraction applied (e.g. 0.5 for half-Kelly)
+)
+
+// defaultATRWindow is how many true-range bars averageTrueRange smooths
+// over absent a caller-supplied BacktestReplayParams.ATRWindow, the
+// conventional ATR lookback.
+const defaultATRWindow = 14
+
+// atrIntervalSec is the candle timeframe averageTrueRange is computed on.
+// An hourly timeframe keeps a 14-bar ATR window wide enough to be a
+// meaningful volatility estimate for the intraday trades this replay engine
+// targets.
+const atrIntervalSec = 3600
+
+// BacktestReplayParams configures ReplayBacktest: which agent and window of
+// loadTradeEvents to re-run, and which alternative sizing/exit rules to
+// apply in place of what each trade actually did.
+type BacktestReplayParams struct {
+	AgentID           string              `json:"agent_id"`
+	FromUnix          int64               `json:"from_unix"`
+	ToUnix            int64               `json:"to_unix"`
+	InitialEquity     float64             `json:"initial_equity"`
+	Sizing            ReplaySizingRule    `json:"sizing"`
+	SizingValue       float64             `json:"sizing_value"`
+	TakeProfitATRMult float64             `json:"take_profit_atr_mult"` // 0 disables the ATR take-profit exit
+	ATRWindow         int                 `json:"atr_window"`           // default defaultATRWindow
+	TrailingStop      *TrailingStopParams `json:"trailing_stop"`        // nil disables the trailing-stop exit
+}
+
+// BacktestReplayMetrics is buildAgentMetrics' output shape, reused as-is so
+// ReplayBacktest's actual and synthetic sides render through the same
+// frontend components the portfolio/leaderboard pages already consume.
+type BacktestReplayMetrics = agentComputed
+
+// BacktestReplayResult is ReplayBacktest's A/B comparison: the agent's real
+// computeTradesAndLots/buildAgentMetrics result next to what the same fill
+// history would have produced under params' alternative sizing/TP/trailing
+// rules, plus the per-metric deltas (synthetic minus actual) so a caller
+// can render a side-by-side chart without recomputing them itself.
+type BacktestReplayResult struct {
+	AgentID        string                `json:"agent_id"`
+	Actual         BacktestReplayMetrics `json:"actual"`
+	Synthetic      BacktestReplayMetrics `json:"synthetic"`
+	Deltas         map[string]float64    `json:"deltas"`
+	TradesReplayed int                   `json:"trades_replayed"`
+}
+
+// ReplayBacktest re-runs agentID's closed trades for [FromUnix, ToUnix]
+// twice: once as they actually happened, and once with each trade's
+// notional rescaled per params.Sizing and its exit replaced by whichever of
+// an ATR-based take-profit or params.TrailingStop would have fired first,
+// falling back to the real exit when neither does.
+//
+// This reuses computeTradesAndLots' trade boundaries rather than replaying
+// the open/flat position state machine itself under the alternative sizing
+// - entries and exit timing windows are still the agent's real fills, only
+// the notional and the exit price/time within that window are hypothetical.
+// It answers "how would this sizing and exit discipline have paid off on
+// the trades this agent actually took", not "what trades would this agent
+// have taken with different sizing" - a full alternate entry-side replay
+// would need its own PnLEngine variant and is out of scope here.
+func (s *Store) ReplayBacktest(ctx context.Context, params BacktestReplayParams) (BacktestReplayResult, error) {
+	if params.AgentID == "" {
+		return BacktestReplayResult{}, fmt.Errorf("backtest replay: agent_id is required")
+	}
+	if params.InitialEquity <= 0 {
+		params.InitialEquity = defaultAgentEquity
+	}
+	if params.ATRWindow <= 0 {
+		params.ATRWindow = defaultATRWindow
+	}
+	if params.TrailingStop != nil {
+		if len(params.TrailingStop.ActivationRatios) == 0 || len(params.TrailingStop.ActivationRatios) != len(params.TrailingStop.CallbackRates) {
+			return BacktestReplayResult{}, fmt.Errorf("backtest replay: activation_ratios and callback_rates must be equal-length and non-empty")
+		}
+	}
+
+	agent, err := s.GetAgent(ctx, params.AgentID)
+	if err != nil {
+		return BacktestReplayResult{}, err
+	}
+
+	events, err := s.loadTradeEvents(ctx, params.AgentID, params.FromUnix, params.ToUnix)
+	if err != nil {
+		return BacktestReplayResult{}, err
+	}
+	modes, err := s.agentAccountingModes(ctx)
+	if err != nil {
+		return BacktestReplayResult{}, err
+	}
+	feeModel, err := s.loadFeeModel(ctx, params.ToUnix)
+	if err != nil {
+		return BacktestReplayResult{}, err
+	}
+	mode := AccountingWeightedAverage
+	if m, ok := modes[params.AgentID]; ok {
+		mode = m
+	}
+	actualTrades, _ := computeTradesAndLots(events, func(string) AccountingMode { return mode }, feeModel)
+
+	marketSymbols, err := s.loadExecutionPriceMarketSymbols(ctx)
+	if err != nil {
+		return BacktestReplayResult{}, err
+	}
+
+	closed := make([]TradeRecord, 0, len(actualTrades))
+	for _, trade := range actualTrades {
+		if trade.ExitTime != 0 {
+			closed = append(closed, trade)
+		}
+	}
+	sort.SliceStable(closed, func(i, j int) bool { return closed[i].ExitTime < closed[j].ExitTime })
+
+	winRate, avgWin, avgLoss := tradeStats(closed)
+
+	syntheticTrades := make([]TradeRecord, 0, len(closed))
+	equity := params.InitialEquity
+	for _, trade := range closed {
+		synthetic := trade
+		synthetic.Qty = sizedQty(params.Sizing, params.SizingValue, equity, trade.EntryPrice, winRate, avgWin, avgLoss)
+		if synthetic.Qty <= 0 {
+			synthetic.Qty = trade.Qty
+		}
+
+		exitTime, exitPrice := trade.ExitTime, trade.ExitPrice
+		if candidateTime, candidatePrice, ok := s.earliestHypotheticalExit(ctx, trade, marketSymbols[trade.MarketID], params); ok {
+			exitTime, exitPrice = candidateTime, candidatePrice
+		}
+		synthetic.ExitTime = exitTime
+		synthetic.ExitPrice = exitPrice
+
+		sign := 1.0
+		if trade.Side != "long" {
+			sign = -1.0
+		}
+		fee := 0.0
+		if trade.Qty > 0 {
+			fee = trade.Fee / trade.Qty * synthetic.Qty
+		}
+		synthetic.GrossPnl = (exitPrice - trade.EntryPrice) * synthetic.Qty * sign
+		synthetic.Fee = fee
+		synthetic.Fees = fee
+		synthetic.Pnl = synthetic.GrossPnl - fee
+		synthetic.NetPnl = synthetic.Pnl
+		syntheticTrades = append(syntheticTrades, synthetic)
+		equity += synthetic.Pnl
+	}
+
+	actualMetrics := buildAgentMetrics(agent, closed)
+	syntheticMetrics := buildAgentMetrics(agent, syntheticTrades)
+
+	deltas := map[string]float64{
+		"pnl_abs":       round2(syntheticMetrics.PnlAbs - actualMetrics.PnlAbs),
+		"pnl_pct":       round2(syntheticMetrics.PnlPct - actualMetrics.PnlPct),
+		"win_rate":      round2(syntheticMetrics.WinRate - actualMetrics.WinRate),
+		"drawdown":      round2(syntheticMetrics.Drawdown - actualMetrics.Drawdown),
+		"sharpe":        round2(syntheticMetrics.Sharpe - actualMetrics.Sharpe),
+		"sortino":       round2(syntheticMetrics.Sortino - actualMetrics.Sortino),
+		"profit_factor": round2(syntheticMetrics.ProfitFactor - actualMetrics.ProfitFactor),
+	}
+
+	return BacktestReplayResult{
+		AgentID:        params.AgentID,
+		Actual:         actualMetrics,
+		Synthetic:      syntheticMetrics,
+		Deltas:         deltas,
+		TradesReplayed: len(closed),
+	}, nil
+}
+
+// earliestHypotheticalExit evaluates params' ATR take-profit and trailing
+// stop against trade independently and returns whichever would have closed
+// the position first. ok is false when neither is configured, or neither
+// condition is ever met, telling the caller to keep the real exit.
+func (s *Store) earliestHypotheticalExit(ctx context.Context, trade TradeRecord, marketSymbol string, params BacktestReplayParams) (int64, float64, bool) {
+	if marketSymbol == "" || trade.EntryPrice <= 0 {
+		return 0, 0, false
+	}
+
+	var candidateTime int64
+	var candidatePrice float64
+	haveCandidate := false
+
+	if params.TakeProfitATRMult > 0 {
+		if exitTime, exitPrice, ok := s.atrTakeProfitExit(ctx, trade, marketSymbol, params); ok {
+			candidateTime, candidatePrice, haveCandidate = exitTime, exitPrice, true
+		}
+	}
+
+	if params.TrailingStop != nil {
+		result, err := s.simulateTrailingStopForTrade(ctx, trade, marketSymbol, *params.TrailingStop)
+		if err == nil && result.ActivatedTier >= 0 {
+			if !haveCandidate || result.HypotheticalExitTime < candidateTime {
+				candidateTime, candidatePrice, haveCandidate = result.HypotheticalExitTime, result.HypotheticalExitPrice, true
+			}
+		}
+	}
+
+	return candidateTime, candidatePrice, haveCandidate
+}
+
+// atrTakeProfitExit computes trade's entry-time ATR over params.ATRWindow
+// hourly candles and walks trade's market_price_ticks for the first crossing
+// of EntryPrice +/- ATR*TakeProfitATRMult, in the direction trade.Side profits
+// from.
+func (s *Store) atrTakeProfitExit(ctx context.Context, trade TradeRecord, marketSymbol string, params BacktestReplayParams) (int64, float64, bool) {
+	candles, err := s.GetMarketCandles(ctx, marketSymbol, atrIntervalSec, params.ATRWindow+2, nil)
+	if err != nil || len(candles) < 2 {
+		return 0, 0, false
+	}
+	atr := averageTrueRange(candles, params.ATRWindow)
+	if atr <= 0 {
+		return 0, 0, false
+	}
+
+	isLong := trade.Side == "long"
+	takeProfitPrice := trade.EntryPrice + atr*params.TakeProfitATRMult
+	if !isLong {
+		takeProfitPrice = trade.EntryPrice - atr*params.TakeProfitATRMult
+	}
+
+	ticks, err := s.loadPriceTicksBetween(ctx, marketSymbol, trade.EntryTime, trade.ExitTime)
+	if err != nil {
+		return 0, 0, false
+	}
+	for _, tick := range ticks {
+		if isLong && tick.Price >= takeProfitPrice {
+			return tick.PublishTime, tick.Price, true
+		}
+		if !isLong && tick.Price <= takeProfitPrice {
+			return tick.PublishTime, tick.Price, true
+		}
+	}
+	return 0, 0, false
+}
+
+// averageTrueRange is the simple moving average of true range
+// (max(H-L, |H-prevC|, |L-prevC|)) over the last window bars of candles,
+// the conventional ATR smoothing.
+func averageTrueRange(candles []CandleRecord, window int) float64 {
+	if len(candles) < 2 {
+		return 0
+	}
+	trueRanges := make([]float64, 0, len(candles)-1)
+	for i := 1; i < len(candles); i++ {
+		high, low, prevClose := candles[i].High, candles[i].Low, candles[i-1].Close
+		tr := math.Max(high-low, math.Max(math.Abs(high-prevClose), math.Abs(low-prevClose)))
+		trueRanges = append(trueRanges, tr)
+	}
+	if window > len(trueRanges) {
+		window = len(trueRanges)
+	}
+	if window <= 0 {
+		return 0
+	}
+	recent := trueRanges[len(trueRanges)-window:]
+	sum := 0.0
+	for _, tr := range recent {
+		sum += tr
+	}
+	return sum / float64(window)
+}
+
+// sizedQty resolves a synthetic position's quantity at price under rule,
+// using winRate/avgWin/avgLoss (computed once from the agent's actual
+// closed trades) as the kelly rule's edge estimate. It mirrors how
+// RunBacktestOverCandles sizes its synthetic positions: a notional
+// (however rule derives it) divided by price.
+func sizedQty(rule ReplaySizingRule, value, equity, price, winRate, avgWin, avgLoss float64) float64 {
+	if price <= 0 {
+		return 0
+	}
+	var notional float64
+	switch rule {
+	case ReplaySizingFixed:
+		notional = value
+	case ReplaySizingKelly:
+		fraction := kellyFraction(winRate/100, avgWin, avgLoss)
+		if value > 0 && value < 1 {
+			fraction *= value // value caps the fraction applied, e.g. 0.5 for half-Kelly
+		}
+		notional = equity * fraction
+	default: // ReplaySizingPercentEquity
+		notional = equity * (value / 100)
+	}
+	if notional <= 0 {
+		return 0
+	}
+	return notional / price
+}
+
+// kellyFraction is the standard Kelly criterion f* = W - (1-W)/R, with R the
+// win/loss payoff ratio, clamped to 0 when the edge is non-positive or
+// avgLoss leaves no usable payoff ratio.
+func kellyFraction(winFrac, avgWin, avgLoss float64) float64 {
+	if avgLoss <= 0 {
+		return 0
+	}
+	payoffRatio := avgWin / avgLoss
+	if payoffRatio <= 0 {
+		return 0
+	}
+	fraction := winFrac - (1-winFrac)/payoffRatio
+	if fraction < 0 {
+		return 0
+	}
+	return fraction
+}
+
+// tradeStats summarizes win rate and average win/loss across trades, the
+// same stats buildAgentMetrics derives internally, for callers that need
+// them before buildAgentMetrics runs (sizedQty's kelly rule).
+func tradeStats(trades []TradeRecord) (winRate, avgWin, avgLoss float64) {
+	if len(trades) == 0 {
+		return 0, 0, 0
+	}
+	wins := 0
+	grossProfit, grossLoss := 0.0, 0.0
+	for _, trade := range trades {
+		if trade.Pnl > 0 {
+			wins++
+			grossProfit += trade.Pnl
+		} else {
+			grossLoss += -trade.Pnl
+		}
+	}
+	winRate = (float64(wins) / float64(len(trades))) * 100
+	if wins > 0 {
+		avgWin = grossProfit / float64(wins)
+	}
+	if losses := len(trades) - wins; losses > 0 {
+		avgLoss = grossLoss / float64(losses)
+	}
+	return winRate, avgWin, avgLoss
+}
+
+// BacktestPreset is a named, reusable BacktestReplayParams configuration
+// for a given agent, so a user iterating on sizing/TP/trailing-stop
+// settings doesn't have to re-enter them for every ReplayBacktest call.
+type BacktestPreset struct {
+	ID        int64                `json:"id"`
+	Name      string               `json:"name"`
+	AgentID   string               `json:"agent_id"`
+	Params    BacktestReplayParams `json:"params"`
+	CreatedAt int64                `json:"created_at"`
+	UpdatedAt int64                `json:"updated_at"`
+}
+
+// SaveBacktestPreset creates or replaces the (agentID, name) preset.
+func (s *Store) SaveBacktestPreset(ctx context.Context, agentID, name string, params BacktestReplayParams) (BacktestPreset, error) {
+	if agentID == "" || name == "" {
+		return BacktestPreset{}, fmt.Errorf("backtest preset: agent_id and name are required")
+	}
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		return BacktestPreset{}, err
+	}
+	now := time.Now().Unix()
+	_, err = s.db.ExecContext(
+		ctx,
+		`INSERT INTO backtest_presets (name, agent_id, params_json, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?)
+		 ON CONFLICT (agent_id, name) DO UPDATE SET
+			params_json = EXCLUDED.params_json,
+			updated_at = EXCLUDED.updated_at`,
+		name,
+		agentID,
+		string(paramsJSON),
+		now,
+		now,
+	)
+	if err != nil {
+		return BacktestPreset{}, err
+	}
+	return s.GetBacktestPreset(ctx, agentID, name)
+}
+
+// GetBacktestPreset returns the preset named name saved for agentID, or
+// ErrNotFound if there is none.
+func (s *Store) GetBacktestPreset(ctx context.Context, agentID, name string) (BacktestPreset, error) {
+	row := s.db.QueryRowContext(
+		ctx,
+		`SELECT id, name, agent_id, params_json, created_at, updated_at
+		 FROM backtest_presets
+		 WHERE agent_id = ? AND name = ?`,
+		agentID,
+		name,
+	)
+	return scanBacktestPreset(row)
+}
+
+// ListBacktestPresets returns every preset saved for agentID, most recently
+// updated first.
+func (s *Store) ListBacktestPresets(ctx context.Context, agentID string) ([]BacktestPreset, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT id, name, agent_id, params_json, created_at, updated_at
+		 FROM backtest_presets
+		 WHERE agent_id = ?
+		 ORDER BY updated_at DESC`,
+		agentID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	presets := make([]BacktestPreset, 0, 8)
+	for rows.Next() {
+		preset, err := scanBacktestPreset(rows)
+		if err != nil {
+			return nil, err
+		}
+		presets = append(presets, preset)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return presets, nil
+}
+
+// presetScanner is the subset of *sql.Row/*sql.Rows scanBacktestPreset
+// needs, mirroring instrumentScanner so the same scan logic works for both
+// GetBacktestPreset's single row and ListBacktestPresets' row iterator.
+type presetScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanBacktestPreset(row presetScanner) (BacktestPreset, error) {
+	var preset BacktestPreset
+	var paramsJSON string
+	if err := row.Scan(&preset.ID, &preset.Name, &preset.AgentID, &paramsJSON, &preset.CreatedAt, &preset.UpdatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return BacktestPreset{}, ErrNotFound
+		}
+		return BacktestPreset{}, err
+	}
+	if err := json.Unmarshal([]byte(paramsJSON), &preset.Params); err != nil {
+		return BacktestPreset{}, err
+	}
+	return preset, nil
+}
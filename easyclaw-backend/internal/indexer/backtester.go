@@ -0,0 +1,238 @@
+package indexer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// BacktestResult is the outcome of replaying a StrategyRecord's
+// EntryRules/ExitRules through RunBacktestOverCandles: a synthetic trade
+// history plus the same RiskMetrics the live leaderboard/portfolio paths
+// expose, so a strategy can be judged before PublishStrategy flips
+// is_published.
+type BacktestResult struct {
+	StrategyID    string        `json:"strategy_id"`
+	ParamHash     string        `json:"param_hash"`
+	Market        string        `json:"market"`
+	IntervalSec   int64         `json:"interval_sec"`
+	Trades        []TradeRecord `json:"trades"`
+	EquityHistory []EquityPoint `json:"equity_history"`
+	RiskMetrics   RiskMetrics   `json:"risk_metrics"`
+	FinalEquity   float64       `json:"final_equity"`
+	CreatedAt     int64         `json:"created_at"`
+}
+
+// RunBacktestOverCandles simulates a long-only flat/long position against
+// candles, entering on the first bar EntryRules' condition is true while
+// flat and exiting on the first bar thereafter ExitRules' condition is
+// true (or at the final bar, to mark-to-market any still-open position).
+// Position size is EntryRules' size_pct of startingEquity. It returns
+// synthetic TradeRecords (one per completed entry/exit pair), the
+// resulting equity curve, and the RiskMetrics computeRiskMetrics derives
+// from that curve.
+func RunBacktestOverCandles(strategy StrategyRecord, candles []CandleRecord, startingEquity float64) (BacktestResult, error) {
+	if len(candles) == 0 {
+		return BacktestResult{}, fmt.Errorf("backtest: no candles to replay")
+	}
+	if err := ValidateStrategyRules(strategy.EntryRules); err != nil {
+		return BacktestResult{}, fmt.Errorf("entry_rules: %w", err)
+	}
+	if err := ValidateStrategyRules(strategy.ExitRules); err != nil {
+		return BacktestResult{}, fmt.Errorf("exit_rules: %w", err)
+	}
+	entryCondition, _ := strategy.EntryRules["condition"].(map[string]any)
+	exitCondition, _ := strategy.ExitRules["condition"].(map[string]any)
+	sizePct := sizePctOf(strategy.EntryRules)
+
+	if startingEquity <= 0 {
+		startingEquity = defaultAgentEquity
+	}
+
+	var trades []TradeRecord
+	equityHistory := make([]EquityPoint, 0, len(candles))
+	equity := startingEquity
+
+	var openQty, openPrice float64
+	var openTime int64
+	var fillSeq int64
+	flat := true
+
+	for i, candle := range candles {
+		if flat {
+			enter, err := evalConditionAt(candles, entryCondition, i)
+			if err != nil {
+				return BacktestResult{}, err
+			}
+			if enter {
+				notional := equity * (sizePct / 100)
+				if candle.Close > 0 {
+					openQty = notional / candle.Close
+					openPrice = candle.Close
+					openTime = candle.TS
+					flat = false
+				}
+			}
+		} else {
+			exit, err := evalConditionAt(candles, exitCondition, i)
+			if err != nil {
+				return BacktestResult{}, err
+			}
+			if exit || i == len(candles)-1 {
+				pnl := (candle.Close - openPrice) * openQty
+				fillSeq++
+				trades = append(trades, TradeRecord{
+					ID:         fmt.Sprintf("backtest-%d", fillSeq),
+					AgentID:    strategy.ID,
+					Side:       "long",
+					EntryPrice: openPrice,
+					ExitPrice:  candle.Close,
+					Qty:        openQty,
+					Pnl:        pnl,
+					EntryTime:  openTime,
+					ExitTime:   candle.TS,
+				})
+				equity += pnl
+				flat = true
+				openQty, openPrice, openTime = 0, 0, 0
+			}
+		}
+
+		markToMarket := equity
+		if !flat {
+			markToMarket = equity + (candle.Close-openPrice)*openQty
+		}
+		equityHistory = append(equityHistory, EquityPoint{TS: candle.TS, Value: markToMarket})
+	}
+
+	return BacktestResult{
+		Trades:        trades,
+		EquityHistory: equityHistory,
+		RiskMetrics:   computeRiskMetrics(equityHistory, 0),
+		FinalEquity:   equity,
+	}, nil
+}
+
+// backtestParamHash canonicalizes params (market, interval, and any
+// strategy-specific overrides) into a stable SHA-256 hex digest, so
+// RunBacktest can key strategy_backtests on (strategy_id, param_hash) and
+// replaying the same params is an idempotent upsert rather than a new row.
+func backtestParamHash(params map[string]any) (string, error) {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	ordered := make([]any, 0, len(keys)*2)
+	for _, k := range keys {
+		ordered = append(ordered, k, params[k])
+	}
+	canonical, err := json.Marshal(ordered)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// RunBacktest loads strategyID, replays it over historical candles for the
+// market/interval/lookback given in params, persists the result in
+// strategy_backtests keyed by (strategy_id, param_hash), and returns it.
+// Recognized params: "market" (string, required), "interval_sec" (number,
+// default 60), "candle_limit" (number, default 500), "starting_equity"
+// (number, default defaultAgentEquity).
+func (s *Store) RunBacktest(ctx context.Context, strategyID string, params map[string]any) (BacktestResult, error) {
+	strategy, err := s.GetStrategy(ctx, strategyID)
+	if err != nil {
+		return BacktestResult{}, err
+	}
+
+	market, _ := params["market"].(string)
+	if market == "" {
+		return BacktestResult{}, fmt.Errorf("backtest: params.market is required")
+	}
+	intervalSec := int64(60)
+	if v, ok := toFloat(params["interval_sec"]); ok && v > 0 {
+		intervalSec = int64(v)
+	}
+	limit := 500
+	if v, ok := toFloat(params["candle_limit"]); ok && v > 0 {
+		limit = int(v)
+	}
+	startingEquity := defaultAgentEquity
+	if v, ok := toFloat(params["starting_equity"]); ok && v > 0 {
+		startingEquity = v
+	}
+
+	paramHash, err := backtestParamHash(params)
+	if err != nil {
+		return BacktestResult{}, err
+	}
+
+	candles, err := s.GetMarketCandles(ctx, market, intervalSec, limit, nil)
+	if err != nil {
+		return BacktestResult{}, err
+	}
+
+	result, err := RunBacktestOverCandles(strategy, candles, startingEquity)
+	if err != nil {
+		return BacktestResult{}, err
+	}
+	result.StrategyID = strategyID
+	result.ParamHash = paramHash
+	result.Market = market
+	result.IntervalSec = intervalSec
+	result.CreatedAt = time.Now().Unix()
+
+	tradesJSON, err := json.Marshal(result.Trades)
+	if err != nil {
+		return BacktestResult{}, err
+	}
+	equityJSON, err := json.Marshal(result.EquityHistory)
+	if err != nil {
+		return BacktestResult{}, err
+	}
+	riskJSON, err := json.Marshal(result.RiskMetrics)
+	if err != nil {
+		return BacktestResult{}, err
+	}
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		return BacktestResult{}, err
+	}
+
+	_, err = s.db.ExecContext(
+		ctx,
+		`INSERT INTO strategy_backtests (
+			strategy_id, param_hash, params_json, market, interval_sec,
+			trades_json, equity_history_json, risk_metrics_json, final_equity, created_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (strategy_id, param_hash) DO UPDATE SET
+			params_json = excluded.params_json,
+			market = excluded.market,
+			interval_sec = excluded.interval_sec,
+			trades_json = excluded.trades_json,
+			equity_history_json = excluded.equity_history_json,
+			risk_metrics_json = excluded.risk_metrics_json,
+			final_equity = excluded.final_equity,
+			created_at = excluded.created_at`,
+		strategyID,
+		paramHash,
+		string(paramsJSON),
+		result.Market,
+		result.IntervalSec,
+		string(tradesJSON),
+		string(equityJSON),
+		string(riskJSON),
+		result.FinalEquity,
+		result.CreatedAt,
+	)
+	if err != nil {
+		return BacktestResult{}, err
+	}
+	return result, nil
+}
@@ -0,0 +1,285 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// bulkIngestFlushThreshold bounds how many buffered rows BulkIngester holds
+// per table before it flushes on its own, so a long backfill run doesn't
+// grow an unbounded in-memory buffer between the caller's own Flush calls.
+const bulkIngestFlushThreshold = 10_000
+
+// BulkIngester batches the same upserts UpsertOrderTx/UpsertPositionTx/
+// UpsertResourceTx perform, but buffers them in memory and flushes each
+// table with a single COPY FROM STDIN into a staging table followed by one
+// INSERT ... SELECT ... ON CONFLICT DO UPDATE merge, instead of one
+// ExecContext per row. A full Solana program snapshot during initial chain
+// backfill is millions of accounts; at that volume the per-row placeholder
+// rebinding UpsertOrderTx et al. do is orders of magnitude slower than COPY.
+//
+// BulkIngester intentionally does NOT reproduce UpsertOrderTx/
+// UpsertPositionTx's side effects (fills, position_history snapshots,
+// events): those exist to notify live subscribers of state transitions as
+// they happen, which has no meaning while backfilling historical state
+// nobody has observed yet. A backfill followed by the normal sync loop
+// picking up from the latest slot will re-derive fills/history/events
+// correctly as the chain continues from there. Callers that need
+// position_history backfilled too should do that as a separate, explicit
+// pass over the buffered PositionRecords after BulkIngester returns.
+type BulkIngester struct {
+	store *Store
+	conn  *stdlib.Conn
+
+	orders    []OrderRecord
+	positions []PositionRecord
+	resources []bulkResourceRow
+}
+
+// bulkResourceRow mirrors UpsertResourceTx's parameters; resources has no
+// Go record type of its own (callers of UpsertResourceTx pass its fields
+// directly), so BulkIngester buffers the same fields.
+type bulkResourceRow struct {
+	Pubkey      string
+	ProgramID   string
+	AccountType string
+	Owner       string
+	Lamports    uint64
+	RawJSON     string
+	Slot        uint64
+}
+
+// BulkSession opens a dedicated connection for a COPY-based bulk ingest run
+// and returns a BulkIngester bound to it. Only Postgres supports the native
+// CopyFrom protocol this relies on, so BulkSession refuses any other
+// dialect outright rather than silently falling back to row-at-a-time
+// inserts - callers doing a SQLite/MySQL backfill still have
+// UpsertOrderTx/UpsertPositionTx/UpsertResourceTx available directly.
+//
+// The returned BulkIngester owns a connection for its whole lifetime;
+// callers must call Close when done to release it back to the pool.
+func (s *Store) BulkSession(ctx context.Context) (*BulkIngester, error) {
+	if s.db.dialect.Name() != "postgres" {
+		return nil, fmt.Errorf("bulk ingest requires postgres, got %s", s.db.dialect.Name())
+	}
+
+	sqlConn, err := s.db.raw.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("acquire connection: %w", err)
+	}
+
+	var pgxConn *stdlib.Conn
+	err = sqlConn.Raw(func(driverConn any) error {
+		conn, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return fmt.Errorf("unexpected driver connection type %T", driverConn)
+		}
+		pgxConn = conn
+		return nil
+	})
+	if err != nil {
+		_ = sqlConn.Close()
+		return nil, fmt.Errorf("unwrap pgx connection: %w", err)
+	}
+
+	return &BulkIngester{store: s, conn: pgxConn}, nil
+}
+
+// AddOrder buffers record for the next orders flush, auto-flushing first if
+// the buffer has reached bulkIngestFlushThreshold.
+func (b *BulkIngester) AddOrder(ctx context.Context, record OrderRecord) error {
+	b.orders = append(b.orders, record)
+	if len(b.orders) >= bulkIngestFlushThreshold {
+		return b.flushOrders(ctx)
+	}
+	return nil
+}
+
+// AddPosition buffers record for the next positions flush, auto-flushing
+// first if the buffer has reached bulkIngestFlushThreshold.
+func (b *BulkIngester) AddPosition(ctx context.Context, record PositionRecord) error {
+	b.positions = append(b.positions, record)
+	if len(b.positions) >= bulkIngestFlushThreshold {
+		return b.flushPositions(ctx)
+	}
+	return nil
+}
+
+// AddResource buffers one resources row for the next flush, auto-flushing
+// first if the buffer has reached bulkIngestFlushThreshold. Its parameters
+// mirror UpsertResourceTx's.
+func (b *BulkIngester) AddResource(ctx context.Context, pubkey, programID solana.PublicKey, accountType string, owner solana.PublicKey, lamports uint64, slot uint64, rawJSON string) error {
+	b.resources = append(b.resources, bulkResourceRow{
+		Pubkey:      pubkey.String(),
+		ProgramID:   programID.String(),
+		AccountType: accountType,
+		Owner:       owner.String(),
+		Lamports:    lamports,
+		RawJSON:     rawJSON,
+		Slot:        slot,
+	})
+	if len(b.resources) >= bulkIngestFlushThreshold {
+		return b.flushResources(ctx)
+	}
+	return nil
+}
+
+// Flush writes every currently-buffered row to its target table, in
+// orders/positions/resources order. Safe to call with empty buffers.
+func (b *BulkIngester) Flush(ctx context.Context) error {
+	if err := b.flushOrders(ctx); err != nil {
+		return err
+	}
+	if err := b.flushPositions(ctx); err != nil {
+		return err
+	}
+	return b.flushResources(ctx)
+}
+
+// Close flushes any remaining buffered rows and releases the underlying
+// connection back to the pool.
+func (b *BulkIngester) Close(ctx context.Context) error {
+	flushErr := b.Flush(ctx)
+	closeErr := b.conn.Close()
+	if flushErr != nil {
+		return flushErr
+	}
+	return closeErr
+}
+
+func (b *BulkIngester) flushOrders(ctx context.Context) error {
+	if len(b.orders) == 0 {
+		return nil
+	}
+	rows := b.orders
+	b.orders = nil
+
+	columns := []string{
+		"pubkey", "user_margin", "user_pubkey", "market_id", "side", "order_type",
+		"reduce_only", "margin", "price", "status", "created_at", "expires_at",
+		"client_order_id", "raw_json", "slot", "updated_at",
+	}
+	source := pgx.CopyFromSlice(len(rows), func(i int) ([]any, error) {
+		r := rows[i]
+		return []any{
+			r.Pubkey, r.UserMargin, r.UserPubkey, int64(r.MarketID), r.Side, r.OrderType,
+			r.ReduceOnly, r.Margin, r.Price, r.Status, r.CreatedAt, r.ExpiresAt,
+			r.ClientOrderID, "{}", int64(r.Slot), r.UpdatedAt,
+		}, nil
+	})
+
+	return b.copyAndMerge(ctx, "orders", "orders_bulk_staging", "pubkey", columns, source)
+}
+
+func (b *BulkIngester) flushPositions(ctx context.Context) error {
+	if len(b.positions) == 0 {
+		return nil
+	}
+	rows := b.positions
+	b.positions = nil
+
+	columns := []string{
+		"pubkey", "user_margin", "market_id", "long_qty", "long_entry_notional", "short_qty",
+		"short_entry_notional", "last_funding_index_long", "last_funding_index_short",
+		"raw_json", "slot", "updated_at",
+	}
+	source := pgx.CopyFromSlice(len(rows), func(i int) ([]any, error) {
+		r := rows[i]
+		return []any{
+			r.Pubkey, r.UserMargin, int64(r.MarketID), r.LongQty, r.LongEntryNotional, r.ShortQty,
+			r.ShortEntryNotional, r.LastFundingIndexLong, r.LastFundingIndexShort,
+			"{}", int64(r.Slot), r.UpdatedAt,
+		}, nil
+	})
+
+	return b.copyAndMerge(ctx, "positions", "positions_bulk_staging", "pubkey", columns, source)
+}
+
+func (b *BulkIngester) flushResources(ctx context.Context) error {
+	if len(b.resources) == 0 {
+		return nil
+	}
+	rows := b.resources
+	b.resources = nil
+
+	columns := []string{"pubkey", "program_id", "account_type", "owner", "lamports", "raw_json", "slot", "updated_at"}
+	now := time.Now().Unix()
+	source := pgx.CopyFromSlice(len(rows), func(i int) ([]any, error) {
+		r := rows[i]
+		return []any{
+			r.Pubkey, r.ProgramID, r.AccountType, r.Owner, int64(r.Lamports), r.RawJSON, int64(r.Slot), now,
+		}, nil
+	})
+
+	return b.copyAndMerge(ctx, "resources", "resources_bulk_staging", "pubkey", columns, source)
+}
+
+// copyAndMerge loads rows into a session-scoped temp table via COPY, then
+// merges that staging table into targetTable with one
+// INSERT ... SELECT ... ON CONFLICT DO UPDATE, all inside one transaction so
+// a failed merge can't leave rows COPY'd into staging but never applied.
+// The staging table is created ON COMMIT DROP, so nothing needs to clean it
+// up afterwards even on error - the rolled-back or committed transaction
+// takes it with it.
+func (b *BulkIngester) copyAndMerge(ctx context.Context, targetTable, stagingTable, conflictColumn string, columns []string, source pgx.CopyFromSource) error {
+	tx, err := b.conn.Conn().Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin bulk tx: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	if _, err := tx.Exec(ctx, fmt.Sprintf(
+		`CREATE TEMP TABLE %s (LIKE %s INCLUDING DEFAULTS) ON COMMIT DROP`,
+		stagingTable, targetTable,
+	)); err != nil {
+		return fmt.Errorf("create staging table %s: %w", stagingTable, err)
+	}
+
+	if _, err := b.conn.Conn().CopyFrom(ctx, pgx.Identifier{stagingTable}, columns, source); err != nil {
+		return fmt.Errorf("copy into %s: %w", stagingTable, err)
+	}
+
+	updateClauses := make([]string, 0, len(columns)-1)
+	for _, column := range columns {
+		if column == conflictColumn {
+			continue
+		}
+		updateClauses = append(updateClauses, fmt.Sprintf("%s = EXCLUDED.%s", column, column))
+	}
+
+	mergeQuery := fmt.Sprintf(
+		`INSERT INTO %s (%s) SELECT %s FROM %s ON CONFLICT (%s) DO UPDATE SET %s`,
+		targetTable,
+		joinColumns(columns),
+		joinColumns(columns),
+		stagingTable,
+		conflictColumn,
+		joinColumns(updateClauses),
+	)
+	if _, err := tx.Exec(ctx, mergeQuery); err != nil {
+		return fmt.Errorf("merge %s into %s: %w", stagingTable, targetTable, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit bulk merge of %s: %w", targetTable, err)
+	}
+	return nil
+}
+
+func joinColumns(columns []string) string {
+	out := ""
+	for i, column := range columns {
+		if i > 0 {
+			out += ", "
+		}
+		out += column
+	}
+	return out
+}
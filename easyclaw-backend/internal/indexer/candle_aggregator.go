@@ -0,0 +1,221 @@
+package indexer
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// candleAggregatorIntervals are the rollup widths CandleAggregator tracks
+// for every market, mirroring the chart intervals Binance-style venues
+// expose: 1m, 5m, 15m, 1h, 4h, 1d.
+var candleAggregatorIntervals = []int64{60, 300, 900, 3600, 14400, 86400}
+
+// ClosedCandle is a finalized OHLCV bucket: one that's rolled over and can
+// no longer change. It's the payload published on RecordTopicCandles and
+// the row CandleAggregator.FlushClosed persists into market_price_candles.
+type ClosedCandle struct {
+	Market      string `json:"market"`
+	IntervalSec int64  `json:"interval_sec"`
+	CandleRecord
+}
+
+type candleBucketKey struct {
+	market      string
+	intervalSec int64
+}
+
+// CandleAggregator maintains the current, still-open OHLCV bucket per
+// (market, interval) in memory as ticks arrive, instead of re-deriving it
+// from market_price_ticks on every chart request. This mirrors bbgo's
+// SerialMarketDataStore: aggregation happens on the write side, once per
+// tick, rather than with a window function at query time. When a bucket
+// rolls over, the finalized bucket is published on the stream Broker (so a
+// live chart/strategy can subscribe to closes instead of polling) and
+// queued for FlushClosed to persist.
+type CandleAggregator struct {
+	broker *Broker
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	open    map[candleBucketKey]*candleBucketState
+	pending []ClosedCandle
+}
+
+type candleBucketState struct {
+	bucketTS int64
+	open     float64
+	high     float64
+	low      float64
+	close    float64
+	volume   float64
+
+	// notionalSum/sizeSum accumulate sum(price*size)/sum(size) for VWAP;
+	// left at zero (and VWAP reported as zero) when ticks carry no size.
+	notionalSum float64
+	sizeSum     float64
+
+	// twapNumerator/twapDenominator accumulate sum(price*holdDuration)
+	// over sum(holdDuration), where holdDuration is how long the
+	// *previous* price was the prevailing one. lastPrice/lastTS track the
+	// most recent tick so the next Observe (or the eventual rollover) can
+	// close out that hold duration.
+	twapNumerator   float64
+	twapDenominator float64
+	lastPrice       float64
+	lastTS          int64
+}
+
+// observeTick folds one tick into a bucket's running aggregates, including
+// the bucket's own creation (state starts pre-seeded with the tick's
+// values, so this is safe to call for a brand-new bucket too).
+func (state *candleBucketState) observeTick(ts int64, price, size float64) {
+	if price > state.high {
+		state.high = price
+	}
+	if price < state.low {
+		state.low = price
+	}
+	state.close = price
+	state.volume++
+	state.notionalSum += price * size
+	state.sizeSum += size
+
+	if state.lastTS != 0 && ts > state.lastTS {
+		duration := float64(ts - state.lastTS)
+		state.twapNumerator += state.lastPrice * duration
+		state.twapDenominator += duration
+	}
+	state.lastPrice = price
+	state.lastTS = ts
+}
+
+// newCandleBucketState opens a bucket seeded with a single tick.
+func newCandleBucketState(bucketTS, ts int64, price, size float64) *candleBucketState {
+	return &candleBucketState{
+		bucketTS:    bucketTS,
+		open:        price,
+		high:        price,
+		low:         price,
+		close:       price,
+		volume:      1,
+		notionalSum: price * size,
+		sizeSum:     size,
+		lastPrice:   price,
+		lastTS:      ts,
+	}
+}
+
+// vwap returns the size-weighted average price for the bucket, or zero if
+// none of its ticks carried a size.
+func (state *candleBucketState) vwap() float64 {
+	if state.sizeSum <= 0 {
+		return 0
+	}
+	return state.notionalSum / state.sizeSum
+}
+
+// twap closes out the hold duration from the last observed tick to
+// bucketEnd (the bucket's close boundary) and returns the time-weighted
+// average price over the bucket. A bucket with only a single tick has no
+// measurable hold duration and falls back to that tick's price.
+func (state *candleBucketState) twap(bucketEnd int64) float64 {
+	numerator := state.twapNumerator
+	denominator := state.twapDenominator
+	if bucketEnd > state.lastTS {
+		duration := float64(bucketEnd - state.lastTS)
+		numerator += state.lastPrice * duration
+		denominator += duration
+	}
+	if denominator <= 0 {
+		return state.close
+	}
+	return numerator / denominator
+}
+
+func NewCandleAggregator(broker *Broker, logger *slog.Logger) *CandleAggregator {
+	return &CandleAggregator{
+		broker: broker,
+		logger: logger,
+		open:   make(map[candleBucketKey]*candleBucketState),
+	}
+}
+
+// Observe folds one accepted price tick into every configured interval's
+// bucket for market. A tick whose publish_time falls behind the bucket
+// already open for an interval is dropped from the live aggregate (it's
+// still in market_price_ticks, so a backfill/re-aggregation pass - or
+// simply re-querying history once the bucket has flushed - remains
+// correct; only the in-memory fast path skips it).
+func (a *CandleAggregator) Observe(market string, publishTime int64, price, size float64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, intervalSec := range candleAggregatorIntervals {
+		bucketTS := (publishTime / intervalSec) * intervalSec
+		key := candleBucketKey{market: market, intervalSec: intervalSec}
+		state, ok := a.open[key]
+		if !ok {
+			a.open[key] = newCandleBucketState(bucketTS, publishTime, price, size)
+			continue
+		}
+		switch {
+		case bucketTS < state.bucketTS:
+			continue
+		case bucketTS > state.bucketTS:
+			a.finalizeLocked(market, intervalSec, state)
+			a.open[key] = newCandleBucketState(bucketTS, publishTime, price, size)
+		default:
+			state.observeTick(publishTime, price, size)
+		}
+	}
+}
+
+// finalizeLocked publishes state as a ClosedCandle and queues it for the
+// next FlushClosed call. Callers must hold a.mu.
+func (a *CandleAggregator) finalizeLocked(market string, intervalSec int64, state *candleBucketState) {
+	closed := ClosedCandle{
+		Market:      market,
+		IntervalSec: intervalSec,
+		CandleRecord: CandleRecord{
+			TS:           state.bucketTS,
+			Open:         round2(state.open),
+			High:         round2(state.high),
+			Low:          round2(state.low),
+			Close:        round2(state.close),
+			Volume:       round6(state.volume),
+			VWAP:         round2(state.vwap()),
+			TWAP:         round2(state.twap(state.bucketTS + intervalSec)),
+			TypicalPrice: round2((state.high + state.low + state.close) / 3),
+		},
+	}
+	a.pending = append(a.pending, closed)
+	if a.broker != nil {
+		a.broker.Publish(RecordTopicCandles, closed)
+	}
+}
+
+// DrainClosed removes and returns every ClosedCandle queued since the last
+// call, for FlushClosed to persist.
+func (a *CandleAggregator) DrainClosed() []ClosedCandle {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.pending) == 0 {
+		return nil
+	}
+	drained := a.pending
+	a.pending = nil
+	return drained
+}
+
+// FlushClosed persists every ClosedCandle queued since the last flush into
+// market_price_candles, so Store.GetMarketCandles can serve historical
+// buckets from that table instead of re-running its aggregation query
+// against raw ticks.
+func (a *CandleAggregator) FlushClosed(ctx context.Context, store *Store) error {
+	drained := a.DrainClosed()
+	if len(drained) == 0 {
+		return nil
+	}
+	return store.InsertMarketPriceCandles(ctx, drained)
+}
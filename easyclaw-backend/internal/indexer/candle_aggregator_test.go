@@ -0,0 +1,124 @@
+package indexer
+
+import "testing"
+
+// 1_700_000_100 divides evenly by every configured interval width (60 at
+// least, which is all these tests need), so it's used as a clean bucket
+// boundary the other timestamps are offset from.
+const candleTestBucketStart = int64(1_700_000_100)
+
+func TestCandleAggregatorAccumulatesWithinABucket(t *testing.T) {
+	agg := NewCandleAggregator(nil, nil)
+
+	agg.Observe("BTCUSDT", candleTestBucketStart, 100, 0)
+	agg.Observe("BTCUSDT", candleTestBucketStart+10, 105, 0)
+	agg.Observe("BTCUSDT", candleTestBucketStart+20, 95, 0)
+
+	if got := agg.DrainClosed(); got != nil {
+		t.Fatalf("DrainClosed() = %v, want nil before any bucket rolls over", got)
+	}
+}
+
+func TestCandleAggregatorClosesBucketOnRollover(t *testing.T) {
+	agg := NewCandleAggregator(nil, nil)
+
+	// All three ticks fall in the same 60s bucket.
+	agg.Observe("BTCUSDT", candleTestBucketStart, 100, 0)
+	agg.Observe("BTCUSDT", candleTestBucketStart+30, 110, 0)
+	agg.Observe("BTCUSDT", candleTestBucketStart+59, 90, 0)
+
+	// This tick starts the next 60s bucket, closing the first.
+	agg.Observe("BTCUSDT", candleTestBucketStart+60, 120, 0)
+
+	closed := agg.DrainClosed()
+	var oneMin *ClosedCandle
+	for i := range closed {
+		if closed[i].IntervalSec == 60 {
+			oneMin = &closed[i]
+			break
+		}
+	}
+	if oneMin == nil {
+		t.Fatalf("expected a closed 60s candle, got %v", closed)
+	}
+	if oneMin.Market != "BTCUSDT" {
+		t.Fatalf("Market = %q, want BTCUSDT", oneMin.Market)
+	}
+	if oneMin.Open != 100 || oneMin.High != 110 || oneMin.Low != 90 || oneMin.Close != 90 {
+		t.Fatalf("OHLC = (%v,%v,%v,%v), want (100,110,90,90)", oneMin.Open, oneMin.High, oneMin.Low, oneMin.Close)
+	}
+	if oneMin.Volume != 3 {
+		t.Fatalf("Volume = %v, want 3 ticks", oneMin.Volume)
+	}
+
+	// Closed candles are only returned once.
+	if got := agg.DrainClosed(); got != nil {
+		t.Fatalf("DrainClosed() on second call = %v, want nil", got)
+	}
+}
+
+func TestCandleAggregatorDropsLateTicks(t *testing.T) {
+	agg := NewCandleAggregator(nil, nil)
+
+	agg.Observe("BTCUSDT", candleTestBucketStart, 100, 0)
+	agg.Observe("BTCUSDT", candleTestBucketStart+60, 200, 0) // rolls the 60s bucket forward
+	agg.DrainClosed()
+
+	// A tick timestamped back in the already-rolled-past bucket must not
+	// reopen or otherwise mutate the current one.
+	agg.Observe("BTCUSDT", candleTestBucketStart+10, 999, 0)
+
+	closed := agg.DrainClosed()
+	if closed != nil {
+		t.Fatalf("a late tick should not close the current bucket early, got %v", closed)
+	}
+}
+
+func TestCandleAggregatorComputesVWAPAndTWAP(t *testing.T) {
+	agg := NewCandleAggregator(nil, nil)
+
+	agg.Observe("BTCUSDT", candleTestBucketStart, 100, 1)
+	agg.Observe("BTCUSDT", candleTestBucketStart+30, 110, 2)
+	agg.Observe("BTCUSDT", candleTestBucketStart+50, 90, 1)
+	agg.Observe("BTCUSDT", candleTestBucketStart+60, 120, 0) // rolls the 60s bucket forward
+
+	closed := agg.DrainClosed()
+	var oneMin *ClosedCandle
+	for i := range closed {
+		if closed[i].IntervalSec == 60 {
+			oneMin = &closed[i]
+			break
+		}
+	}
+	if oneMin == nil {
+		t.Fatalf("expected a closed 60s candle, got %v", closed)
+	}
+
+	// VWAP = (100*1 + 110*2 + 90*1) / (1+2+1) = 410/4 = 102.5
+	if oneMin.VWAP != 102.5 {
+		t.Fatalf("VWAP = %v, want 102.5", oneMin.VWAP)
+	}
+	// TWAP = (100 held 30s + 110 held 20s + 90 held 10s) / 60s = 6100/60 ~= 101.67
+	if got, want := oneMin.TWAP, 101.67; got != want {
+		t.Fatalf("TWAP = %v, want %v", got, want)
+	}
+	// TypicalPrice = (High + Low + Close) / 3 = (110 + 90 + 90) / 3 ~= 96.67
+	if got, want := oneMin.TypicalPrice, 96.67; got != want {
+		t.Fatalf("TypicalPrice = %v, want %v", got, want)
+	}
+}
+
+func TestCandleAggregatorTracksMarketsAndIntervalsIndependently(t *testing.T) {
+	agg := NewCandleAggregator(nil, nil)
+
+	agg.Observe("BTCUSDT", candleTestBucketStart, 100, 0)
+	agg.Observe("ETHUSDT", candleTestBucketStart, 10, 0)
+	agg.Observe("ETHUSDT", candleTestBucketStart+60, 11, 0)
+
+	closed := agg.DrainClosed()
+	for _, c := range closed {
+		if c.Market != "ETHUSDT" {
+			t.Fatalf("only ETHUSDT should have a closed bucket yet, got a close for %q", c.Market)
+		}
+	}
+}
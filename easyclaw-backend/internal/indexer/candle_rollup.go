@@ -0,0 +1,279 @@
+package indexer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+)
+
+// candleRollupBaseTF/candleRollupBaseIntervalSec is the only timeframe
+// CandleRollup builds directly from fills. Every other tracked timeframe
+// is derived by folding closed base-interval candles (see
+// foldIntoDerivedLocked), so a fill's already-scaled price/qty only ever
+// feeds one bucket computation no matter how many timeframes are
+// configured - the caller (Service.publishBatch) is the one that ran it
+// through rawPriceScale/rawNotionalScale once, when the fill was loaded.
+const (
+	candleRollupBaseTF          = "1m"
+	candleRollupBaseIntervalSec = 60
+)
+
+// candleRollupDerivedIntervals are the timeframes CandleRollup derives from
+// the 1m base bucket: 5m, 15m, 1h, 4h, 1d.
+var candleRollupDerivedIntervals = []int64{300, 900, 3600, 14400, 86400}
+
+// candleRollupTimeframes maps every interval CandleRollup tracks to its
+// canonical tf label, the same strings GetCandles/SubscribeCandles accept.
+var candleRollupTimeframes = map[int64]string{
+	candleRollupBaseIntervalSec: candleRollupBaseTF,
+	300:                         "5m",
+	900:                         "15m",
+	3600:                        "1h",
+	14400:                       "4h",
+	86400:                       "1d",
+}
+
+// CandleRollupUpdate is the payload published on RecordTopicCandleRollups
+// and queued for FlushClosed when one (market_id, tf) bucket closes.
+type CandleRollupUpdate struct {
+	MarketID uint64 `json:"market_id"`
+	TF       string `json:"tf"`
+	CandleRecord
+}
+
+type candleRollupKey struct {
+	marketID uint64
+	tf       string
+}
+
+// rollupBucketState is the still-open OHLCV accumulator for one (market_id,
+// tf) bucket. Unlike candleBucketState (CandleAggregator's price-tick
+// counterpart), it tracks plain OHLCV only - VWAP/TWAP aren't meaningful
+// for a fill-based trade-volume candle the way they are for a price feed.
+type rollupBucketState struct {
+	bucketTS int64
+	open     float64
+	high     float64
+	low      float64
+	close    float64
+	volume   float64
+}
+
+func newRollupBucketState(bucketTS int64, open, high, low, close, volume float64) *rollupBucketState {
+	return &rollupBucketState{bucketTS: bucketTS, open: open, high: high, low: low, close: close, volume: volume}
+}
+
+// observeFill folds one fill directly into the base (1m) bucket.
+func (b *rollupBucketState) observeFill(price, qty float64) {
+	if price > b.high {
+		b.high = price
+	}
+	if price < b.low {
+		b.low = price
+	}
+	b.close = price
+	b.volume += qty
+}
+
+// foldChild folds a closed, smaller-timeframe candle into a derived bucket.
+func (b *rollupBucketState) foldChild(child CandleRecord) {
+	if child.High > b.high {
+		b.high = child.High
+	}
+	if child.Low < b.low {
+		b.low = child.Low
+	}
+	b.close = child.Close
+	b.volume += child.Volume
+}
+
+func (b *rollupBucketState) toCandleRecord() CandleRecord {
+	return CandleRecord{TS: b.bucketTS, Open: b.open, High: b.high, Low: b.low, Close: b.close, Volume: b.volume}
+}
+
+// CandleRollup is the fill-based counterpart to CandleAggregator: instead
+// of building OHLCV buckets from price ticks, it ingests fills (trades
+// actually executed against a market_id) and maintains one still-open
+// bucket per (market_id, tf) in memory. Every timeframe beyond the 1m base
+// bucket is derived by folding that base bucket's closes rather than
+// re-scanning fills - so the currently-open 5m/15m/1h/4h/1d bucket only
+// updates once per closed 1m bar, which keeps the derivation both cheap
+// and immune to re-deriving the same fill at multiple scales.
+type CandleRollup struct {
+	broker *Broker
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	open    map[candleRollupKey]*rollupBucketState
+	pending []CandleRollupUpdate
+}
+
+func NewCandleRollup(broker *Broker, logger *slog.Logger) *CandleRollup {
+	return &CandleRollup{
+		broker: broker,
+		logger: logger,
+		open:   make(map[candleRollupKey]*rollupBucketState),
+	}
+}
+
+// Observe folds one fill (already scaled by the caller via
+// rawPriceScale/rawNotionalScale) into marketID's base bucket, rolling it
+// over - and deriving every higher timeframe from it - when executedAt
+// falls in a later bucket than the one currently open. A fill landing
+// behind the open bucket is dropped from the live aggregate, same as
+// CandleAggregator.Observe: it's still in the fills table, so a
+// backfill/re-aggregation pass remains correct even though the in-memory
+// fast path skips it.
+func (r *CandleRollup) Observe(marketID uint64, executedAt int64, price, qty float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	bucketTS := (executedAt / candleRollupBaseIntervalSec) * candleRollupBaseIntervalSec
+	key := candleRollupKey{marketID: marketID, tf: candleRollupBaseTF}
+	state, ok := r.open[key]
+	switch {
+	case !ok:
+		r.open[key] = newRollupBucketState(bucketTS, price, price, price, price, qty)
+	case bucketTS < state.bucketTS:
+		return
+	case bucketTS > state.bucketTS:
+		r.rolloverLocked(marketID, candleRollupBaseTF, state)
+		r.open[key] = newRollupBucketState(bucketTS, price, price, price, price, qty)
+	default:
+		state.observeFill(price, qty)
+	}
+}
+
+// rolloverLocked publishes state as a closed CandleRollupUpdate, queues it
+// for the next FlushClosed call, and - if it was the base bucket - folds it
+// into every derived timeframe. Callers must hold r.mu.
+func (r *CandleRollup) rolloverLocked(marketID uint64, tf string, state *rollupBucketState) {
+	closed := state.toCandleRecord()
+	r.publishLocked(marketID, tf, closed)
+	if tf == candleRollupBaseTF {
+		r.foldIntoDerivedLocked(marketID, closed)
+	}
+}
+
+// foldIntoDerivedLocked folds one closed 1m candle into every derived
+// timeframe's open bucket, rolling each over independently as its own
+// bucket boundary is crossed.
+func (r *CandleRollup) foldIntoDerivedLocked(marketID uint64, base CandleRecord) {
+	for _, intervalSec := range candleRollupDerivedIntervals {
+		tf := candleRollupTimeframes[intervalSec]
+		bucketTS := (base.TS / intervalSec) * intervalSec
+		key := candleRollupKey{marketID: marketID, tf: tf}
+		state, ok := r.open[key]
+		switch {
+		case !ok:
+			r.open[key] = newRollupBucketState(bucketTS, base.Open, base.High, base.Low, base.Close, base.Volume)
+		case bucketTS < state.bucketTS:
+			continue
+		case bucketTS > state.bucketTS:
+			r.rolloverLocked(marketID, tf, state)
+			r.open[key] = newRollupBucketState(bucketTS, base.Open, base.High, base.Low, base.Close, base.Volume)
+		default:
+			state.foldChild(base)
+		}
+	}
+}
+
+func (r *CandleRollup) publishLocked(marketID uint64, tf string, candle CandleRecord) {
+	update := CandleRollupUpdate{MarketID: marketID, TF: tf, CandleRecord: candle}
+	r.pending = append(r.pending, update)
+	if r.broker != nil {
+		r.broker.Publish(RecordTopicCandleRollups, update)
+	}
+}
+
+// DrainClosed removes and returns every CandleRollupUpdate queued since the
+// last call, for FlushClosed to persist.
+func (r *CandleRollup) DrainClosed() []CandleRollupUpdate {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.pending) == 0 {
+		return nil
+	}
+	drained := r.pending
+	r.pending = nil
+	return drained
+}
+
+// FlushClosed persists every CandleRollupUpdate queued since the last flush
+// into candles, so Store.GetCandles can serve historical buckets without
+// replaying fills.
+func (r *CandleRollup) FlushClosed(ctx context.Context, store *Store) error {
+	drained := r.DrainClosed()
+	if len(drained) == 0 {
+		return nil
+	}
+	return store.UpsertCandles(ctx, drained)
+}
+
+// SubscribeCandles hands back a typed feed of closed (market_id, tf)
+// buckets, translating broker's topic-and-filter/[]byte-channel API (built
+// for the websocket layer) into the plain `<-chan CandleRecord, func()`
+// shape a strategy or backtester driver wants. It's a function rather than
+// a Store method since Store has no broker dependency anywhere else in
+// this package - Broker is owned by Service, the same as CandleAggregator
+// - so this is the free-function equivalent of "Store.SubscribeCandles"
+// wired through the broker CandleRollup already publishes on.
+func SubscribeCandles(broker *Broker, marketID uint64, tf string) (<-chan CandleRecord, func(), error) {
+	if broker == nil {
+		return nil, nil, fmt.Errorf("candle subscriptions require a stream broker")
+	}
+
+	raw := make(chan []byte, streamBrokerSubscriberBuffer)
+	filter := func(record any) bool {
+		update, ok := record.(CandleRollupUpdate)
+		return ok && update.MarketID == marketID && update.TF == tf
+	}
+	sub := broker.Subscribe(RecordTopicCandleRollups, filter, raw)
+
+	out := make(chan CandleRecord, streamBrokerSubscriberBuffer)
+	done := make(chan struct{})
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case <-done:
+				return
+			case payload, ok := <-raw:
+				if !ok {
+					return
+				}
+				update, err := decodeCandleRollupEnvelope(payload)
+				if err != nil {
+					continue
+				}
+				select {
+				case out <- update.CandleRecord:
+				case <-done:
+					return
+				}
+			}
+		}
+	}()
+
+	unsubscribe := func() {
+		broker.Unsubscribe(RecordTopicCandleRollups, sub)
+		close(done)
+	}
+	return out, unsubscribe, nil
+}
+
+// decodeCandleRollupEnvelope unwraps one of Broker's topic-tagged envelopes
+// back into the CandleRollupUpdate it was published with.
+func decodeCandleRollupEnvelope(payload []byte) (CandleRollupUpdate, error) {
+	var envelope streamEnvelope
+	if err := json.Unmarshal(payload, &envelope); err != nil {
+		return CandleRollupUpdate{}, err
+	}
+	var update CandleRollupUpdate
+	if err := json.Unmarshal(envelope.Record, &update); err != nil {
+		return CandleRollupUpdate{}, err
+	}
+	return update, nil
+}
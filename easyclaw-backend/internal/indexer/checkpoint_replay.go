@@ -0,0 +1,268 @@
+package indexer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"sort"
+)
+
+// outOfOrderWindowSec is how far behind a checkpoint's last_executed_at an
+// incremental replay re-fetches fills from. Fills generally land in
+// ExecutedAt order, but a fill that finishes settling slightly late can
+// be inserted with an ExecutedAt earlier than fills already checkpointed;
+// re-scanning this window (rather than strictly `id > last_fill_id`) lets
+// ReplayAgentMarket catch it on the next call, while the LastFillID
+// dedup below keeps re-scanned fills from being double-counted.
+const outOfOrderWindowSec = 300
+
+// lotSnapshot is openLot's exported, JSON-marshalable mirror: openLot's
+// fields are unexported since lotQueueEngine never needed to serialize
+// them before, but a reducer_checkpoints row has to persist them across
+// process restarts.
+type lotSnapshot struct {
+	Qty       float64 `json:"qty"`
+	Price     float64 `json:"price"`
+	EntryTime int64   `json:"entry_time"`
+	FillID    int64   `json:"fill_id"`
+}
+
+// reducerStateSnapshot is a (agent, market)'s PnLEngine state captured at
+// a checkpoint, covering both engine families: AvgQty/AvgPrice for
+// weightedAverageEngine's positionState, Sign/Lots for lotQueueEngine's
+// open-lot queue. Only the fields relevant to the checkpoint's Mode are
+// populated.
+type reducerStateSnapshot struct {
+	AvgQty   float64       `json:"avg_qty,omitempty"`
+	AvgPrice float64       `json:"avg_price,omitempty"`
+	Sign     float64       `json:"sign,omitempty"`
+	Lots     []lotSnapshot `json:"lots,omitempty"`
+}
+
+// ReducerCheckpoint is the persisted resume point for one agent's one
+// market's fill reducer: the last fill folded into StateJSON, so
+// ReplayAgentMarket can pick up from here instead of re-running every
+// historical fill through the PnLEngine on every call.
+type ReducerCheckpoint struct {
+	AgentID        string
+	MarketID       uint64
+	Mode           AccountingMode
+	LastFillID     int64
+	LastExecutedAt int64
+	State          reducerStateSnapshot
+	UpdatedAt      int64
+}
+
+func (c ReducerCheckpoint) positionState() positionState {
+	return positionState{Qty: c.State.AvgQty, AvgPrice: c.State.AvgPrice}
+}
+
+func (c ReducerCheckpoint) openLots() (float64, []openLot) {
+	open := make([]openLot, 0, len(c.State.Lots))
+	for _, lot := range c.State.Lots {
+		open = append(open, openLot{qty: lot.Qty, price: lot.Price, entryTime: lot.EntryTime, fillID: lot.FillID})
+	}
+	return c.State.Sign, open
+}
+
+func snapshotFromWeightedAverage(state positionState) reducerStateSnapshot {
+	return reducerStateSnapshot{AvgQty: state.Qty, AvgPrice: state.AvgPrice}
+}
+
+func snapshotFromLotQueue(sign float64, open []openLot) reducerStateSnapshot {
+	lots := make([]lotSnapshot, 0, len(open))
+	for _, lot := range open {
+		lots = append(lots, lotSnapshot{Qty: lot.qty, Price: lot.price, EntryTime: lot.entryTime, FillID: lot.fillID})
+	}
+	return reducerStateSnapshot{Sign: sign, Lots: lots}
+}
+
+// loadReducerCheckpoint returns the zero-value checkpoint (LastFillID 0,
+// empty state) when none has been saved yet, so ReplayAgentMarket's first
+// call for a given agent/market naturally replays from the beginning.
+func (s *Store) loadReducerCheckpoint(ctx context.Context, agentID string, marketID uint64) (ReducerCheckpoint, error) {
+	checkpoint := ReducerCheckpoint{AgentID: agentID, MarketID: marketID}
+	var mode string
+	var stateJSON string
+	row := s.db.QueryRowContext(
+		ctx,
+		`SELECT mode, last_fill_id, last_executed_at, state_json, updated_at
+		 FROM reducer_checkpoints
+		 WHERE agent_id = ? AND market_id = ?`,
+		agentID, marketID,
+	)
+	err := row.Scan(&mode, &checkpoint.LastFillID, &checkpoint.LastExecutedAt, &stateJSON, &checkpoint.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return checkpoint, nil
+	}
+	if err != nil {
+		return ReducerCheckpoint{}, err
+	}
+	checkpoint.Mode = AccountingMode(mode)
+	if err := json.Unmarshal([]byte(stateJSON), &checkpoint.State); err != nil {
+		return ReducerCheckpoint{}, err
+	}
+	return checkpoint, nil
+}
+
+func (s *Store) saveReducerCheckpoint(ctx context.Context, checkpoint ReducerCheckpoint) error {
+	stateJSON, err := json.Marshal(checkpoint.State)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(
+		ctx,
+		`INSERT INTO reducer_checkpoints (agent_id, market_id, mode, last_fill_id, last_executed_at, state_json, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?)
+		 ON CONFLICT (agent_id, market_id) DO UPDATE SET
+			mode = excluded.mode,
+			last_fill_id = excluded.last_fill_id,
+			last_executed_at = excluded.last_executed_at,
+			state_json = excluded.state_json,
+			updated_at = excluded.updated_at`,
+		checkpoint.AgentID,
+		checkpoint.MarketID,
+		string(checkpoint.Mode),
+		checkpoint.LastFillID,
+		checkpoint.LastExecutedAt,
+		string(stateJSON),
+		checkpoint.UpdatedAt,
+	)
+	return err
+}
+
+// ReplayAgentMarket incrementally folds an agent's fills in one market
+// into the PnLEngine the agent's AccountingMode selects, resuming from
+// the last saved ReducerCheckpoint instead of reprocessing the agent's
+// full fill history the way RecomputeAgentLots does. It re-fetches fills
+// from outOfOrderWindowSec before the checkpoint (rather than strictly
+// after LastFillID) to tolerate a late-settling fill landing with an
+// earlier ExecutedAt, and skips any fill with FillID <= LastFillID so
+// re-scanning the window is idempotent. now is the caller's current time,
+// passed in rather than read from time.Now so replay stays deterministic
+// and testable. If the agent's AccountingMode has changed since the
+// checkpoint was saved, the checkpoint's State is for the wrong engine
+// family and is discarded in favor of a full replay, same as
+// RecomputeAgentLots.
+func (s *Store) ReplayAgentMarket(ctx context.Context, agentID string, marketID uint64, now int64) ([]TradeRecord, []ClosedLot, error) {
+	agent, err := s.GetAgent(ctx, agentID)
+	if err != nil {
+		return nil, nil, err
+	}
+	mode := normalizeAccountingMode(agent.RiskProfile.AccountingMode)
+
+	checkpoint, err := s.loadReducerCheckpoint(ctx, agentID, marketID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if checkpoint.LastFillID == 0 {
+		checkpoint.Mode = mode
+	} else if checkpoint.Mode != mode {
+		// AccountingMode was patched since this checkpoint was saved (see
+		// PatchAgentRisk and RecomputeAgentLots' comment on this exact
+		// scenario). The saved State's fields only make sense for the
+		// checkpoint's old engine family - weighted-average's
+		// AvgQty/AvgPrice vs. lot-queue's Sign/Lots - so resuming from it
+		// under the new mode would silently reinterpret it as a zeroed
+		// starting state and discard the agent's real open position.
+		// Fall back to a full replay from scratch, the same way
+		// RecomputeAgentLots always does.
+		checkpoint = ReducerCheckpoint{AgentID: agentID, MarketID: marketID, Mode: mode}
+	}
+
+	fromUnix := int64(0)
+	if checkpoint.LastExecutedAt > outOfOrderWindowSec {
+		fromUnix = checkpoint.LastExecutedAt - outOfOrderWindowSec
+	}
+	allEvents, err := s.loadTradeEvents(ctx, agentID, fromUnix, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	events := make([]tradeEvent, 0, len(allEvents))
+	for _, event := range allEvents {
+		if event.MarketID == marketID {
+			events = append(events, event)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].ExecutedAt != events[j].ExecutedAt {
+			return events[i].ExecutedAt < events[j].ExecutedAt
+		}
+		return events[i].FillID < events[j].FillID
+	})
+
+	feeModel, err := s.loadFeeModel(ctx, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	trades := make([]TradeRecord, 0, len(events))
+	var lots []ClosedLot
+	avgState := checkpoint.positionState()
+	sign, open := checkpoint.openLots()
+	lastFillID := checkpoint.LastFillID
+	lastExecutedAt := checkpoint.LastExecutedAt
+
+	for _, event := range events {
+		if event.FillID <= checkpoint.LastFillID {
+			continue
+		}
+
+		var trade TradeRecord
+		ok := false
+		if mode == AccountingWeightedAverage {
+			var closed *ClosedLot
+			var newState positionState
+			newState, trade, closed, ok = stepWeightedAverage(avgState, event, feeModel)
+			if ok {
+				avgState = newState
+				if closed != nil {
+					lots = append(lots, *closed)
+				}
+			}
+		} else {
+			var closedBatch []ClosedLot
+			var newSign float64
+			var newOpen []openLot
+			newSign, newOpen, trade, closedBatch, ok = lotQueueEngine{mode: mode}.stepLotQueue(sign, open, event, feeModel)
+			if ok {
+				sign, open = newSign, newOpen
+				lots = append(lots, closedBatch...)
+			}
+		}
+		if !ok {
+			continue
+		}
+		trades = append(trades, trade)
+		if event.FillID > lastFillID {
+			lastFillID = event.FillID
+		}
+		if event.ExecutedAt > lastExecutedAt {
+			lastExecutedAt = event.ExecutedAt
+		}
+	}
+
+	if len(events) == 0 {
+		return trades, lots, nil
+	}
+
+	state := reducerStateSnapshot{}
+	if mode == AccountingWeightedAverage {
+		state = snapshotFromWeightedAverage(avgState)
+	} else {
+		state = snapshotFromLotQueue(sign, open)
+	}
+	err = s.saveReducerCheckpoint(ctx, ReducerCheckpoint{
+		AgentID:        agentID,
+		MarketID:       marketID,
+		Mode:           mode,
+		LastFillID:     lastFillID,
+		LastExecutedAt: lastExecutedAt,
+		State:          state,
+		UpdatedAt:      now,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return trades, lots, nil
+}
@@ -0,0 +1,188 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+)
+
+// DepositRecord is one confirmed on-chain deposit into the platform's
+// vault, keyed by (ProgramID, TxSignature) so a replayed or re-indexed
+// transaction upserts in place instead of duplicating. Amount, TxnFee are
+// decimal strings for the same reason FillRecord.Margin and .Price are:
+// they're on-chain raw fixed-point amounts that can exceed float64's exact
+// range.
+type DepositRecord struct {
+	ID             int64  `json:"id"`
+	ProgramID      string `json:"program_id"`
+	TxSignature    string `json:"tx_signature"`
+	Wallet         string `json:"wallet"`
+	AssetMint      string `json:"asset_mint"`
+	Amount         string `json:"amount"`
+	Network        string `json:"network"`
+	TxnFee         string `json:"txn_fee"`
+	TxnFeeCurrency string `json:"txn_fee_currency"`
+	Slot           uint64 `json:"slot"`
+	BlockTime      int64  `json:"block_time"`
+	RawJSON        string `json:"-"`
+}
+
+// WithdrawRecord mirrors DepositRecord for the opposite vault flow. It's
+// the audit trail lp_positions.pending_shares transitions through on their
+// way from "requested" to "settled", recorded separately here rather than
+// read back off pending_shares since that column only ever reflects the
+// position's current state, not its history.
+type WithdrawRecord struct {
+	ID             int64  `json:"id"`
+	ProgramID      string `json:"program_id"`
+	TxSignature    string `json:"tx_signature"`
+	Wallet         string `json:"wallet"`
+	AssetMint      string `json:"asset_mint"`
+	Amount         string `json:"amount"`
+	Network        string `json:"network"`
+	TxnFee         string `json:"txn_fee"`
+	TxnFeeCurrency string `json:"txn_fee_currency"`
+	Slot           uint64 `json:"slot"`
+	BlockTime      int64  `json:"block_time"`
+	RawJSON        string `json:"-"`
+}
+
+// UpsertDepositTx records deposit, updating the existing row in place if
+// (program_id, tx_signature) was already seen — the indexer can replay a
+// slot range after a restart, and this keeps that idempotent.
+func (s *Store) UpsertDepositTx(ctx context.Context, tx *Tx, deposit DepositRecord) error {
+	query := fmt.Sprintf(`
+		INSERT INTO deposits (
+			program_id, tx_signature, wallet, asset_mint, amount, network,
+			txn_fee, txn_fee_currency, slot, block_time, raw_json
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		%s
+	`, tx.dialect.UpsertClause([]string{"program_id", "tx_signature"}, []string{
+		"wallet", "asset_mint", "amount", "network",
+		"txn_fee", "txn_fee_currency", "slot", "block_time", "raw_json",
+	}))
+	_, err := tx.ExecContext(ctx, query,
+		deposit.ProgramID,
+		deposit.TxSignature,
+		deposit.Wallet,
+		deposit.AssetMint,
+		deposit.Amount,
+		deposit.Network,
+		deposit.TxnFee,
+		deposit.TxnFeeCurrency,
+		int64(deposit.Slot),
+		deposit.BlockTime,
+		deposit.RawJSON,
+	)
+	return err
+}
+
+// UpsertWithdrawTx is UpsertDepositTx for the withdraws table.
+func (s *Store) UpsertWithdrawTx(ctx context.Context, tx *Tx, withdraw WithdrawRecord) error {
+	query := fmt.Sprintf(`
+		INSERT INTO withdraws (
+			program_id, tx_signature, wallet, asset_mint, amount, network,
+			txn_fee, txn_fee_currency, slot, block_time, raw_json
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		%s
+	`, tx.dialect.UpsertClause([]string{"program_id", "tx_signature"}, []string{
+		"wallet", "asset_mint", "amount", "network",
+		"txn_fee", "txn_fee_currency", "slot", "block_time", "raw_json",
+	}))
+	_, err := tx.ExecContext(ctx, query,
+		withdraw.ProgramID,
+		withdraw.TxSignature,
+		withdraw.Wallet,
+		withdraw.AssetMint,
+		withdraw.Amount,
+		withdraw.Network,
+		withdraw.TxnFee,
+		withdraw.TxnFeeCurrency,
+		int64(withdraw.Slot),
+		withdraw.BlockTime,
+		withdraw.RawJSON,
+	)
+	return err
+}
+
+// ListDeposits returns wallet's deposits with block_time >= since (pass 0
+// for no floor), most recent first, capped to limit rows (clamped to
+// maxPageLimit; <= 0 defaults to defaultPageLimit). Unlike ListPositions et
+// al. this isn't cursor-paginated: deposit/withdraw history is queried as a
+// recent-activity feed, not paged through exhaustively.
+func (s *Store) ListDeposits(ctx context.Context, wallet string, since int64, limit int) ([]DepositRecord, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT id, program_id, tx_signature, wallet, asset_mint, amount, network,
+		        txn_fee, txn_fee_currency, slot, block_time
+		 FROM deposits
+		 WHERE wallet = ? AND block_time >= ?
+		 ORDER BY block_time DESC
+		 LIMIT ?`,
+		wallet, since, clampListLimit(limit),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]DepositRecord, 0)
+	for rows.Next() {
+		var item DepositRecord
+		var slot int64
+		if err := rows.Scan(
+			&item.ID, &item.ProgramID, &item.TxSignature, &item.Wallet, &item.AssetMint,
+			&item.Amount, &item.Network, &item.TxnFee, &item.TxnFeeCurrency, &slot, &item.BlockTime,
+		); err != nil {
+			return nil, err
+		}
+		item.Slot = uint64(slot)
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// ListWithdraws is ListDeposits for the withdraws table.
+func (s *Store) ListWithdraws(ctx context.Context, wallet string, since int64, limit int) ([]WithdrawRecord, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT id, program_id, tx_signature, wallet, asset_mint, amount, network,
+		        txn_fee, txn_fee_currency, slot, block_time
+		 FROM withdraws
+		 WHERE wallet = ? AND block_time >= ?
+		 ORDER BY block_time DESC
+		 LIMIT ?`,
+		wallet, since, clampListLimit(limit),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]WithdrawRecord, 0)
+	for rows.Next() {
+		var item WithdrawRecord
+		var slot int64
+		if err := rows.Scan(
+			&item.ID, &item.ProgramID, &item.TxSignature, &item.Wallet, &item.AssetMint,
+			&item.Amount, &item.Network, &item.TxnFee, &item.TxnFeeCurrency, &slot, &item.BlockTime,
+		); err != nil {
+			return nil, err
+		}
+		item.Slot = uint64(slot)
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// clampListLimit applies the same bounds ListPositions et al. enforce on
+// their Filter.Limit, for the plain int-parameter List methods that don't
+// go through a Filter struct.
+func clampListLimit(limit int) int {
+	if limit <= 0 {
+		return defaultPageLimit
+	}
+	if limit > maxPageLimit {
+		return maxPageLimit
+	}
+	return limit
+}
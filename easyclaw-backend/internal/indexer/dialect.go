@@ -0,0 +1,96 @@
+package indexer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Dialect abstracts the handful of SQL differences between the backends
+// NewStore can open: placeholder rebinding, upsert syntax, autoincrement
+// column type, and JSON column type. Only the write paths that vary
+// across backends route through it today — UpsertPositionTx,
+// UpsertLPPositionTx, UpsertOrderTx, UpsertResourceTx, UpsertSyncStateTx,
+// and hasColumn. The embedded schema in internal/indexer/migrate is still
+// Postgres-only DDL (BIGSERIAL, NUMERIC, ON CONFLICT DO UPDATE, etc.), so
+// NewStore against a mysql:// or sqlite:// DSN will select the right
+// driver and upsert syntax for the write paths above but will still fail
+// migrate()'s DDL; per-dialect migration variants are a larger follow-up.
+type Dialect interface {
+	Name() string
+	Rebind(query string) string
+	UpsertClause(conflictCols, updateCols []string) string
+	Autoincrement() string
+	JSONColumnType() string
+}
+
+// postgresDialect is what NewStore has always assumed: pgx over the
+// postgres://... scheme, ? placeholders rebound to $1, $2, ..., and
+// ON CONFLICT(...) DO UPDATE SET col = excluded.col upserts.
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string               { return "postgres" }
+func (postgresDialect) Rebind(query string) string { return rebindPostgresPlaceholders(query) }
+func (postgresDialect) Autoincrement() string      { return "BIGSERIAL" }
+func (postgresDialect) JSONColumnType() string     { return "TEXT" }
+func (postgresDialect) UpsertClause(conflictCols, updateCols []string) string {
+	return conflictDoUpdateClause(conflictCols, updateCols)
+}
+
+// sqliteDialect targets modernc.org/sqlite. SQLite's upsert syntax
+// (ON CONFLICT(...) DO UPDATE SET col = excluded.col) is identical to
+// Postgres's, and it accepts ? placeholders natively, so Rebind is a
+// no-op.
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string               { return "sqlite" }
+func (sqliteDialect) Rebind(query string) string { return query }
+func (sqliteDialect) Autoincrement() string      { return "INTEGER" }
+func (sqliteDialect) JSONColumnType() string     { return "TEXT" }
+func (sqliteDialect) UpsertClause(conflictCols, updateCols []string) string {
+	return conflictDoUpdateClause(conflictCols, updateCols)
+}
+
+// mysqlDialect targets github.com/go-sql-driver/mysql. MySQL also
+// accepts ? placeholders natively, but has no ON CONFLICT syntax at all —
+// its upsert form is ON DUPLICATE KEY UPDATE col = VALUES(col), keyed off
+// whichever unique/primary key the INSERT collided with rather than a
+// named conflict target.
+type mysqlDialect struct{}
+
+func (mysqlDialect) Name() string               { return "mysql" }
+func (mysqlDialect) Rebind(query string) string { return query }
+func (mysqlDialect) Autoincrement() string      { return "BIGINT AUTO_INCREMENT" }
+func (mysqlDialect) JSONColumnType() string     { return "JSON" }
+func (mysqlDialect) UpsertClause(_ []string, updateCols []string) string {
+	sets := make([]string, 0, len(updateCols))
+	for _, col := range updateCols {
+		sets = append(sets, fmt.Sprintf("%s = VALUES(%s)", col, col))
+	}
+	return "ON DUPLICATE KEY UPDATE " + strings.Join(sets, ", ")
+}
+
+func conflictDoUpdateClause(conflictCols, updateCols []string) string {
+	sets := make([]string, 0, len(updateCols))
+	for _, col := range updateCols {
+		sets = append(sets, fmt.Sprintf("%s = excluded.%s", col, col))
+	}
+	return fmt.Sprintf("ON CONFLICT(%s) DO UPDATE SET %s", strings.Join(conflictCols, ", "), strings.Join(sets, ", "))
+}
+
+// dialectForDSN picks the sql.Open driver name and Dialect implementation
+// from dsn's scheme: mysql:// for MySQL, sqlite:// (or a bare path ending
+// in .db/.sqlite, the way sqlite CLIs are usually invoked) for SQLite,
+// and everything else (postgres://, postgresql://, or no recognized
+// scheme) for Postgres, matching NewStore's previous hardcoded behavior.
+func dialectForDSN(dsn string) (driverName string, dialect Dialect) {
+	switch {
+	case strings.HasPrefix(dsn, "mysql://"):
+		return "mysql", mysqlDialect{}
+	case strings.HasPrefix(dsn, "sqlite://"),
+		strings.HasSuffix(dsn, ".db"),
+		strings.HasSuffix(dsn, ".sqlite"):
+		return "sqlite", sqliteDialect{}
+	default:
+		return "pgx", postgresDialect{}
+	}
+}
@@ -0,0 +1,180 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// EventTopic names one kind of meaningful state transition the indexer
+// surfaces through the events table and Notifier, as opposed to the raw
+// per-mutation RecordTopic stream Broker fans out. Topics are stable
+// strings (not iota ints) since they're persisted and may be consumed by
+// the Postgres LISTEN/NOTIFY bridge from outside this binary.
+type EventTopic string
+
+const (
+	TopicOrderExecuted       EventTopic = "order.executed"
+	TopicOrderCancelled      EventTopic = "order.cancelled"
+	TopicOrderExpired        EventTopic = "order.expired"
+	TopicPositionOpened      EventTopic = "position.opened"
+	TopicPositionClosed      EventTopic = "position.closed"
+	TopicLPWithdrawFinalized EventTopic = "lp.withdraw_finalized"
+	// TopicMarketSuspended is reserved for when the market registry gains
+	// a suspended/paused status concept; nothing in this codebase sets
+	// such a status today, so nothing emits this topic yet.
+	TopicMarketSuspended EventTopic = "market.suspended"
+)
+
+// EventSeverity classifies how urgently a consumer should react to an
+// Event. Ordinary lifecycle transitions (an order executing) are Info;
+// operator-facing conditions (a market pause) would be Warning or
+// Critical.
+type EventSeverity string
+
+const (
+	SeverityInfo     EventSeverity = "info"
+	SeverityWarning  EventSeverity = "warning"
+	SeverityCritical EventSeverity = "critical"
+)
+
+// Event is one row of the events table: a structured, typed alternative to
+// inferring state transitions by diffing position_history/fills pages
+// against whatever a consumer last saw.
+type Event struct {
+	ID          int64         `json:"id"`
+	Topic       EventTopic    `json:"topic"`
+	Severity    EventSeverity `json:"severity"`
+	Subject     string        `json:"subject"`
+	Detail      string        `json:"detail"`
+	ActorPubkey string        `json:"actor_pubkey"`
+	CreatedAt   int64         `json:"created_at"`
+}
+
+// Notifier receives every Event once the transaction that produced it has
+// committed, mirroring how Broker.Publish is only called after syncOnce's
+// WithTx returns successfully. Implementations must not block: Notify is
+// called from the sync loop's hot path.
+type Notifier interface {
+	Notify(event Event)
+}
+
+const eventChannelNotifierBuffer = 256
+
+// ChannelNotifier is the in-process Notifier: Notify pushes onto a buffered
+// Go channel that callers drain via Events(), and a full buffer drops the
+// event with a warning rather than stalling the sync loop, the same
+// trade-off Broker.Publish makes for slow subscribers.
+type ChannelNotifier struct {
+	logger *slog.Logger
+	events chan Event
+}
+
+func NewChannelNotifier(logger *slog.Logger) *ChannelNotifier {
+	return &ChannelNotifier{
+		logger: logger,
+		events: make(chan Event, eventChannelNotifierBuffer),
+	}
+}
+
+// Events returns the channel new events are pushed onto. There's
+// intentionally one shared channel rather than per-subscriber fan-out;
+// callers that need fan-out (e.g. the websocket layer) should drain this
+// once and redistribute, the same role Broker plays for record topics.
+func (n *ChannelNotifier) Events() <-chan Event {
+	return n.events
+}
+
+func (n *ChannelNotifier) Notify(event Event) {
+	select {
+	case n.events <- event:
+	default:
+		n.logger.Warn("event channel notifier buffer full, dropping event", "topic", event.Topic)
+	}
+}
+
+// recordEventTx inserts event into the events table as part of the
+// surrounding transaction, so the audit row only persists if the state
+// change it describes actually commits. On a Postgres backend it also
+// calls pg_notify on the same connection: Postgres defers NOTIFY delivery
+// until commit, so a rolled-back sync pass never reaches external
+// LISTEN/NOTIFY subscribers either. The in-process Go-channel path is
+// separate and deliberately NOT fired here — see publishBatch, which calls
+// Notifier.Notify only after WithTx has returned successfully.
+func (s *Store) recordEventTx(ctx context.Context, tx *Tx, topic EventTopic, severity EventSeverity, subject, detail, actorPubkey string) (Event, error) {
+	now := time.Now().Unix()
+	event := Event{
+		Topic:       topic,
+		Severity:    severity,
+		Subject:     subject,
+		Detail:      detail,
+		ActorPubkey: actorPubkey,
+		CreatedAt:   now,
+	}
+
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO events (topic, severity, subject, detail, actor_pubkey, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, string(event.Topic), string(event.Severity), event.Subject, event.Detail, event.ActorPubkey, event.CreatedAt)
+	if err != nil {
+		return Event{}, fmt.Errorf("insert event: %w", err)
+	}
+
+	if tx.dialect.Name() == "postgres" {
+		if _, err := tx.ExecContext(ctx, `SELECT pg_notify(?, ?)`, eventNotifyChannel, string(event.Topic)+" "+event.Subject); err != nil {
+			return Event{}, fmt.Errorf("pg_notify event: %w", err)
+		}
+	}
+
+	return event, nil
+}
+
+// eventNotifyChannel is the Postgres NOTIFY channel external listeners
+// (`LISTEN easyclaw_events;`) subscribe to. The payload is deliberately
+// terse (topic and subject only, not the full Event) since NOTIFY payloads
+// are capped at 8000 bytes by Postgres; a listener that needs the rest
+// should look the event up by subject, or just query the events table.
+const eventNotifyChannel = "easyclaw_events"
+
+// ListEvents returns events at or after since (pass 0 for no floor),
+// optionally narrowed to one topic, most recent first, capped to limit
+// rows. Like ListDeposits/ListWithdraws this is a recent-activity feed
+// rather than a cursor-paginated table.
+func (s *Store) ListEvents(ctx context.Context, topic EventTopic, since int64, limit int) ([]Event, error) {
+	clauses := []string{"created_at >= ?"}
+	args := []any{since}
+	if topic != "" {
+		clauses = append(clauses, "topic = ?")
+		args = append(args, string(topic))
+	}
+	args = append(args, clampListLimit(limit))
+
+	query := fmt.Sprintf(`
+		SELECT id, topic, severity, subject, detail, actor_pubkey, created_at
+		FROM events
+		WHERE %s
+		ORDER BY created_at DESC
+		LIMIT ?
+	`, strings.Join(clauses, " AND "))
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]Event, 0)
+	for rows.Next() {
+		var item Event
+		var topic, severity string
+		if err := rows.Scan(&item.ID, &topic, &severity, &item.Subject, &item.Detail, &item.ActorPubkey, &item.CreatedAt); err != nil {
+			return nil, err
+		}
+		item.Topic = EventTopic(topic)
+		item.Severity = EventSeverity(severity)
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
@@ -0,0 +1,265 @@
+package indexer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"strings"
+	"time"
+)
+
+// defaultMakerFeeRate and defaultTakerFeeRate are the fee rates feeRateFor
+// falls back to for a market with no market_fee_schedules row, chosen to
+// match defaultTradeFeeRate (the flat rate every trade was charged before
+// per-market schedules existed) for taker fills, with maker fills priced
+// favorably the way every venue this indexer tracks actually quotes them.
+const (
+	defaultMakerFeeRate = 0.0002
+	defaultTakerFeeRate = defaultTradeFeeRate
+)
+
+// FeeScheduleRecord is one market's maker/taker fee rates, expressed as a
+// fraction of notional (0.0004 = 4bps).
+type FeeScheduleRecord struct {
+	MarketID     uint64  `json:"market_id"`
+	MakerFeeRate float64 `json:"maker_fee_rate"`
+	TakerFeeRate float64 `json:"taker_fee_rate"`
+	UpdatedAt    int64   `json:"updated_at"`
+}
+
+// UpsertFeeSchedule creates or replaces marketID's fee schedule.
+func (s *Store) UpsertFeeSchedule(ctx context.Context, record FeeScheduleRecord) error {
+	now := time.Now().Unix()
+	_, err := s.db.ExecContext(
+		ctx,
+		`INSERT INTO market_fee_schedules (market_id, maker_fee_rate, taker_fee_rate, updated_at)
+		 VALUES (?, ?, ?, ?)
+		 ON CONFLICT (market_id) DO UPDATE SET
+			maker_fee_rate = EXCLUDED.maker_fee_rate,
+			taker_fee_rate = EXCLUDED.taker_fee_rate,
+			updated_at = EXCLUDED.updated_at`,
+		int64(record.MarketID),
+		record.MakerFeeRate,
+		record.TakerFeeRate,
+		now,
+	)
+	return err
+}
+
+// GetFeeSchedule returns marketID's configured fee schedule, or
+// ErrNotFound if it has none (callers should fall back to feeRateFor's
+// defaults rather than failing the request, same as GetMarketInstrument).
+func (s *Store) GetFeeSchedule(ctx context.Context, marketID uint64) (FeeScheduleRecord, error) {
+	row := s.db.QueryRowContext(
+		ctx,
+		`SELECT market_id, maker_fee_rate, taker_fee_rate, updated_at
+		 FROM market_fee_schedules
+		 WHERE market_id = ?`,
+		int64(marketID),
+	)
+	var record FeeScheduleRecord
+	var marketID64 int64
+	if err := row.Scan(&marketID64, &record.MakerFeeRate, &record.TakerFeeRate, &record.UpdatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return FeeScheduleRecord{}, ErrNotFound
+		}
+		return FeeScheduleRecord{}, err
+	}
+	record.MarketID = uint64(marketID64)
+	return record, nil
+}
+
+// loadFeeSchedules returns every configured market's fee schedule keyed by
+// market ID, for the trade-accounting paths that need to resolve a rate
+// per fill without a query per trade.
+func (s *Store) loadFeeSchedules(ctx context.Context) (map[uint64]FeeScheduleRecord, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT market_id, maker_fee_rate, taker_fee_rate, updated_at FROM market_fee_schedules`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schedules := make(map[uint64]FeeScheduleRecord, 16)
+	for rows.Next() {
+		var record FeeScheduleRecord
+		var marketID int64
+		if err := rows.Scan(&marketID, &record.MakerFeeRate, &record.TakerFeeRate, &record.UpdatedAt); err != nil {
+			return nil, err
+		}
+		record.MarketID = uint64(marketID)
+		schedules[record.MarketID] = record
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+// VolumeFeeTier is one global VIP tier: an agent whose trailing 30-day fill
+// notional (summed across markets, via agentTrailing30dVolume) is at least
+// MinVolume30d is charged MakerFeeRate/TakerFeeRate instead of whatever a
+// market's own schedule or defaultMakerFeeRate/defaultTakerFeeRate would
+// otherwise charge. Rates may be negative - a maker rebate, the same way
+// real venues pay makers at their top tiers.
+type VolumeFeeTier struct {
+	ID           int64   `json:"id"`
+	Name         string  `json:"name"`
+	MinVolume30d float64 `json:"min_volume_30d"`
+	MakerFeeRate float64 `json:"maker_fee_rate"`
+	TakerFeeRate float64 `json:"taker_fee_rate"`
+	UpdatedAt    int64   `json:"updated_at"`
+}
+
+// UpsertFeeTier creates or replaces the VIP tier named record.Name.
+func (s *Store) UpsertFeeTier(ctx context.Context, record VolumeFeeTier) error {
+	now := time.Now().Unix()
+	_, err := s.db.ExecContext(
+		ctx,
+		`INSERT INTO fee_tiers (name, min_volume_30d, maker_fee_rate, taker_fee_rate, updated_at)
+		 VALUES (?, ?, ?, ?, ?)
+		 ON CONFLICT (name) DO UPDATE SET
+			min_volume_30d = EXCLUDED.min_volume_30d,
+			maker_fee_rate = EXCLUDED.maker_fee_rate,
+			taker_fee_rate = EXCLUDED.taker_fee_rate,
+			updated_at = EXCLUDED.updated_at`,
+		record.Name,
+		record.MinVolume30d,
+		record.MakerFeeRate,
+		record.TakerFeeRate,
+		now,
+	)
+	return err
+}
+
+// ListFeeTiers returns every configured VIP tier, highest MinVolume30d
+// first, so scheduleFeeModel.FeeRate can pick the first tier an agent's
+// volume clears.
+func (s *Store) ListFeeTiers(ctx context.Context) ([]VolumeFeeTier, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT id, name, min_volume_30d, maker_fee_rate, taker_fee_rate, updated_at
+		 FROM fee_tiers
+		 ORDER BY min_volume_30d DESC`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tiers := make([]VolumeFeeTier, 0, 8)
+	for rows.Next() {
+		var tier VolumeFeeTier
+		if err := rows.Scan(&tier.ID, &tier.Name, &tier.MinVolume30d, &tier.MakerFeeRate, &tier.TakerFeeRate, &tier.UpdatedAt); err != nil {
+			return nil, err
+		}
+		tiers = append(tiers, tier)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return tiers, nil
+}
+
+// defaultFeeAsset is the currency every fee is charged in. The platform's
+// risk limits (AgentRiskProfile.DailyLossLimitUSDC/MaxPositionUSDC) and
+// defaultAgentEquity are already USDC-denominated, so fees are too.
+const defaultFeeAsset = "USDC"
+
+// FeeModel resolves the fee rate, maker/taker classification, and VIP tier
+// name (if any) a fill should be charged. It's consulted once per fill from
+// buildTradeRecord, pluggable so ListTrades/computePortfolio/backtests can
+// be re-run under alternative fee assumptions without recompiling.
+type FeeModel interface {
+	FeeRate(marketID uint64, agentID, orderType string) (rate float64, isMaker bool, tier string)
+}
+
+// scheduleFeeModel is the default FeeModel: a VIP tier (keyed by
+// volumeByAgent's trailing 30-day notional) takes priority over a market's
+// own schedule, which in turn takes priority over
+// defaultMakerFeeRate/defaultTakerFeeRate.
+type scheduleFeeModel struct {
+	schedules     map[uint64]FeeScheduleRecord
+	tiers         []VolumeFeeTier // sorted MinVolume30d descending
+	volumeByAgent map[string]float64
+}
+
+// FeeRate implements FeeModel. Any order_type other than "market" is
+// treated as a maker fill, mirroring the market/non-market distinction
+// lookupExecutionPrice already draws.
+func (m scheduleFeeModel) FeeRate(marketID uint64, agentID, orderType string) (float64, bool, string) {
+	isTaker := strings.EqualFold(orderType, "market")
+	isMaker := !isTaker
+
+	volume := m.volumeByAgent[agentID]
+	for _, tier := range m.tiers {
+		if volume >= tier.MinVolume30d {
+			if isTaker {
+				return tier.TakerFeeRate, isMaker, tier.Name
+			}
+			return tier.MakerFeeRate, isMaker, tier.Name
+		}
+	}
+
+	if schedule, ok := m.schedules[marketID]; ok {
+		if isTaker {
+			return schedule.TakerFeeRate, isMaker, ""
+		}
+		return schedule.MakerFeeRate, isMaker, ""
+	}
+	if isTaker {
+		return defaultTakerFeeRate, isMaker, ""
+	}
+	return defaultMakerFeeRate, isMaker, ""
+}
+
+// loadFeeModel builds the default FeeModel as of asOf (each agent's
+// trailing 30-day volume is computed from fills up to asOf; asOf <= 0 uses
+// now), combining loadFeeSchedules' per-market overrides with
+// ListFeeTiers' VIP tiers.
+func (s *Store) loadFeeModel(ctx context.Context, asOf int64) (FeeModel, error) {
+	schedules, err := s.loadFeeSchedules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tiers, err := s.ListFeeTiers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	volumeByAgent, err := s.agentTrailing30dVolume(ctx, asOf)
+	if err != nil {
+		return nil, err
+	}
+	return scheduleFeeModel{schedules: schedules, tiers: tiers, volumeByAgent: volumeByAgent}, nil
+}
+
+// trailingVolumeWindowSec is the lookback agentTrailing30dVolume sums fills
+// over for scheduleFeeModel's VIP tier lookup.
+const trailingVolumeWindowSec = 30 * 24 * 3600
+
+// agentTrailing30dVolume sums every agent's fill notional (abs(Notional),
+// or qty*price when Notional wasn't populated) over the 30 days ending
+// asOf. asOf <= 0 uses now.
+func (s *Store) agentTrailing30dVolume(ctx context.Context, asOf int64) (map[string]float64, error) {
+	if asOf <= 0 {
+		asOf = time.Now().Unix()
+	}
+	events, err := s.loadTradeEvents(ctx, "", asOf-trailingVolumeWindowSec, asOf)
+	if err != nil {
+		return nil, err
+	}
+	volume := make(map[string]float64, 16)
+	for _, event := range events {
+		notional := math.Abs(event.Notional)
+		if notional == 0 {
+			if qty, ok := normalizedFillQty(event); ok {
+				notional = qty * event.Price
+			}
+		}
+		volume[event.AgentID] += notional
+	}
+	return volume, nil
+}
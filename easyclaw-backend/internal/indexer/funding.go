@@ -0,0 +1,202 @@
+package indexer
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// FundingTickInput is one observed funding-rate settlement for a perp
+// market, as reported by the venue's funding feed.
+type FundingTickInput struct {
+	Market      string
+	FundingRate float64
+	FundingTime int64
+	MarkPrice   float64
+	IndexPrice  float64
+}
+
+// FundingRecord is one funding-rate settlement returned to API callers.
+type FundingRecord struct {
+	Market      string  `json:"market"`
+	FundingRate float64 `json:"funding_rate"`
+	FundingTime int64   `json:"funding_time"`
+	MarkPrice   float64 `json:"mark_price"`
+	IndexPrice  float64 `json:"index_price"`
+}
+
+// FundingFilter scopes a ListFundingHistory query, following the same
+// shape as TradeFilter.
+type FundingFilter struct {
+	FromUnix int64
+	ToUnix   int64
+	Limit    int
+	Offset   int
+}
+
+// InsertFundingTick records one funding settlement, deduped on
+// (market, funding_time) the same way InsertMarketPriceTick dedupes price
+// ticks. Returns whether a new row was inserted.
+func (s *Store) InsertFundingTick(ctx context.Context, input FundingTickInput) (bool, error) {
+	market := normalizeMarketWithDefault(input.Market)
+	if input.FundingTime <= 0 {
+		return false, fmt.Errorf("funding time is required")
+	}
+
+	result, err := s.db.ExecContext(
+		ctx,
+		`INSERT INTO funding_ticks (market, funding_rate, funding_time, mark_price, index_price)
+		 VALUES (?, ?, ?, ?, ?)
+		 ON CONFLICT (market, funding_time) DO NOTHING`,
+		market,
+		input.FundingRate,
+		input.FundingTime,
+		input.MarkPrice,
+		input.IndexPrice,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, nil
+	}
+	return affected > 0, nil
+}
+
+// GetLatestFunding returns market's most recent funding settlement.
+func (s *Store) GetLatestFunding(ctx context.Context, market string) (FundingRecord, error) {
+	normalized := normalizeMarketWithDefault(market)
+	row := s.db.QueryRowContext(
+		ctx,
+		`SELECT market, funding_rate, funding_time, mark_price, index_price
+		 FROM funding_ticks
+		 WHERE market = ?
+		 ORDER BY funding_time DESC
+		 LIMIT 1`,
+		normalized,
+	)
+	var item FundingRecord
+	if err := row.Scan(&item.Market, &item.FundingRate, &item.FundingTime, &item.MarkPrice, &item.IndexPrice); err != nil {
+		if err == sql.ErrNoRows {
+			return FundingRecord{}, ErrNotFound
+		}
+		return FundingRecord{}, err
+	}
+	return item, nil
+}
+
+// ListFundingHistory returns market's funding settlements within filter's
+// time range, newest first.
+func (s *Store) ListFundingHistory(ctx context.Context, market string, filter FundingFilter) ([]FundingRecord, int, int, error) {
+	normalized := normalizeMarketWithDefault(market)
+	limit, offset := normalizePagination(filter.Limit, filter.Offset)
+
+	fromUnix := filter.FromUnix
+	toUnix := filter.ToUnix
+	if toUnix <= 0 {
+		toUnix = time.Now().Unix()
+	}
+
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT market, funding_rate, funding_time, mark_price, index_price
+		 FROM funding_ticks
+		 WHERE market = ? AND funding_time >= ? AND funding_time <= ?
+		 ORDER BY funding_time DESC
+		 LIMIT ? OFFSET ?`,
+		normalized,
+		fromUnix,
+		toUnix,
+		limit,
+		offset,
+	)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	defer rows.Close()
+
+	items := make([]FundingRecord, 0, limit)
+	for rows.Next() {
+		var item FundingRecord
+		if err := rows.Scan(&item.Market, &item.FundingRate, &item.FundingTime, &item.MarkPrice, &item.IndexPrice); err != nil {
+			return nil, 0, 0, err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, 0, err
+	}
+	return items, limit, offset, nil
+}
+
+// RecordAgentFundingAccrual attributes fundingPnl accrued at accruedAt to
+// agentID's position on market, so portfolio/leaderboard summaries can
+// report funding cost as a separate line item from trading PnL.
+func (s *Store) RecordAgentFundingAccrual(ctx context.Context, agentID, market string, fundingPnl float64, accruedAt int64) error {
+	agentID = strings.TrimSpace(agentID)
+	if agentID == "" {
+		return fmt.Errorf("agent id is required")
+	}
+	_, err := s.db.ExecContext(
+		ctx,
+		`INSERT INTO agent_funding_accruals (agent_id, market, funding_pnl, accrued_at)
+		 VALUES (?, ?, ?, ?)`,
+		agentID,
+		normalizeMarketWithDefault(market),
+		fundingPnl,
+		accruedAt,
+	)
+	return err
+}
+
+// GetFundingPnLByAgent sums every agent's accrued funding PnL since
+// startUnix in a single query, mirroring computePortfolio's pattern of
+// bulk-loading then grouping in memory rather than querying per agent.
+func (s *Store) GetFundingPnLByAgent(ctx context.Context, startUnix int64) (map[string]float64, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT agent_id, SUM(funding_pnl)
+		 FROM agent_funding_accruals
+		 WHERE accrued_at >= ?
+		 GROUP BY agent_id`,
+		startUnix,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := map[string]float64{}
+	for rows.Next() {
+		var agentID string
+		var total float64
+		if err := rows.Scan(&agentID, &total); err != nil {
+			return nil, err
+		}
+		out[agentID] = total
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetAgentFundingPnL sums agentID's accrued funding PnL since startUnix.
+func (s *Store) GetAgentFundingPnL(ctx context.Context, agentID string, startUnix int64) (float64, error) {
+	row := s.db.QueryRowContext(
+		ctx,
+		`SELECT COALESCE(SUM(funding_pnl), 0)
+		 FROM agent_funding_accruals
+		 WHERE agent_id = ? AND accrued_at >= ?`,
+		agentID,
+		startUnix,
+	)
+	var total float64
+	if err := row.Scan(&total); err != nil {
+		return 0, err
+	}
+	return total, nil
+}
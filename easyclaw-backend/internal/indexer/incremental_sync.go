@@ -0,0 +1,181 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// programDiscriminatorEntry is one (account type, 8-byte Anchor
+// discriminator, parse+upsert callback) triple within a program's
+// account set. syncOrderEngine/syncMarketRegistry/syncLPVault build
+// these so syncProgramEntries can dispatch either a full scanAndStore
+// sweep or a targeted incrementalSyncProgram fetch through the same
+// callbacks.
+type programDiscriminatorEntry struct {
+	AccountType   string
+	Discriminator [8]byte
+	Handle        func(item *rpc.KeyedAccount) error
+}
+
+// incrementalSignatureBatchLimit bounds how many signatures
+// collectTouchedAccounts pages through getSignaturesForAddress per call.
+const incrementalSignatureBatchLimit = 1000
+
+// incrementalAccountBatchSize bounds how many pubkeys one
+// getMultipleAccounts call resolves at a time.
+const incrementalAccountBatchSize = 100
+
+// incrementalSyncProgram advances one program's sync state from fromSlot
+// to toSlot without a full GetProgramAccountsWithOpts scan: it walks
+// getSignaturesForAddress(programID) backward from the chain tip,
+// collecting every signature newer than fromSlot, resolves the accounts
+// referenced by those transactions, fetches their current data with
+// getMultipleAccounts, and dispatches each by discriminator to entries.
+func (s *Service) incrementalSyncProgram(ctx context.Context, programID solana.PublicKey, entries []programDiscriminatorEntry, fromSlot, toSlot uint64) error {
+	touched, err := s.collectTouchedAccounts(ctx, programID, fromSlot)
+	if err != nil {
+		return fmt.Errorf("collect touched accounts for %s: %w", programID, err)
+	}
+	if len(touched) == 0 {
+		return nil
+	}
+
+	pubkeys := make([]solana.PublicKey, 0, len(touched))
+	for pubkey := range touched {
+		pubkeys = append(pubkeys, pubkey)
+	}
+
+	for start := 0; start < len(pubkeys); start += incrementalAccountBatchSize {
+		end := start + incrementalAccountBatchSize
+		if end > len(pubkeys) {
+			end = len(pubkeys)
+		}
+		batch := pubkeys[start:end]
+
+		result, err := s.rpc.GetMultipleAccountsWithOpts(ctx, batch, &rpc.GetMultipleAccountsOpts{
+			Commitment: s.cfg.Commitment,
+		})
+		if err != nil {
+			return fmt.Errorf("get multiple accounts: %w", err)
+		}
+
+		for i, account := range result.Value {
+			if account == nil {
+				// Account was closed since fromSlot; nothing to
+				// re-upsert, and deletions aren't tracked here any more
+				// than they were by the old full-scan sweep.
+				continue
+			}
+			data := account.Data.GetBinary()
+			if len(data) < 8 {
+				continue
+			}
+			var discriminator [8]byte
+			copy(discriminator[:], data[:8])
+
+			for _, entry := range entries {
+				if entry.Discriminator != discriminator {
+					continue
+				}
+				item := &rpc.KeyedAccount{Pubkey: batch[i], Account: account}
+				if err := entry.Handle(item); err != nil {
+					s.logger.Warn("failed to index incrementally synced account",
+						"program", programID,
+						"account_type", entry.AccountType,
+						"pubkey", batch[i],
+						"slot", toSlot,
+						"err", err,
+					)
+				}
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+// collectTouchedAccounts pages backward through
+// getSignaturesForAddress(programID) until it reaches a signature at or
+// before fromSlot, fetches each transaction in between, and returns the
+// set of account pubkeys those transactions referenced. A program is
+// always part of the account list of any instruction that invokes it, so
+// this finds every transaction that could have touched one of its
+// accounts without scanning the full account set.
+func (s *Service) collectTouchedAccounts(ctx context.Context, programID solana.PublicKey, fromSlot uint64) (map[solana.PublicKey]struct{}, error) {
+	touched := make(map[solana.PublicKey]struct{})
+
+	var before solana.Signature
+	for {
+		limit := incrementalSignatureBatchLimit
+		opts := &rpc.GetSignaturesForAddressOpts{
+			Limit:      &limit,
+			Commitment: s.cfg.Commitment,
+		}
+		if !before.IsZero() {
+			opts.Before = before
+		}
+
+		signatures, err := s.rpc.GetSignaturesForAddressWithOpts(ctx, programID, opts)
+		if err != nil {
+			return nil, fmt.Errorf("get signatures for address: %w", err)
+		}
+		if len(signatures) == 0 {
+			break
+		}
+
+		reachedFromSlot := false
+		for _, sig := range signatures {
+			if sig.Slot <= fromSlot {
+				reachedFromSlot = true
+				break
+			}
+			keys, err := s.transactionAccountKeys(ctx, sig.Signature)
+			if err != nil {
+				s.logger.Warn("failed to fetch transaction for incremental sync",
+					"program", programID,
+					"signature", sig.Signature,
+					"err", err,
+				)
+				continue
+			}
+			for _, key := range keys {
+				touched[key] = struct{}{}
+			}
+		}
+
+		if reachedFromSlot || len(signatures) < incrementalSignatureBatchLimit {
+			break
+		}
+		before = signatures[len(signatures)-1].Signature
+	}
+
+	return touched, nil
+}
+
+// transactionAccountKeys returns every account key referenced by
+// signature's transaction. incrementalSyncProgram then resolves only
+// those that exist and match one of entries' discriminators, so
+// including unrelated accounts here just costs a handful of wasted
+// getMultipleAccounts lookups, not a correctness problem.
+func (s *Service) transactionAccountKeys(ctx context.Context, signature solana.Signature) ([]solana.PublicKey, error) {
+	maxSupportedTransactionVersion := uint64(0)
+	tx, err := s.rpc.GetTransaction(ctx, signature, &rpc.GetTransactionOpts{
+		Commitment:                     s.cfg.Commitment,
+		MaxSupportedTransactionVersion: &maxSupportedTransactionVersion,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if tx == nil || tx.Transaction == nil {
+		return nil, nil
+	}
+	decoded, err := tx.Transaction.GetTransaction()
+	if err != nil {
+		return nil, err
+	}
+	return decoded.Message.AccountKeys, nil
+}
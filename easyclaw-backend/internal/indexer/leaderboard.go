@@ -0,0 +1,178 @@
+package indexer
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// leaderboardMetrics is every metric GetLeaderboard can sort by, beyond
+// the original pnl_pct/win_rate pair: "score" is a composite ranking
+// computed from LeaderboardWeights rather than a single agentComputed
+// field.
+var leaderboardMetrics = map[string]bool{
+	"pnl_pct":       true,
+	"win_rate":      true,
+	"sharpe":        true,
+	"sortino":       true,
+	"max_drawdown":  true,
+	"calmar":        true,
+	"profit_factor": true,
+	"avg_hold_sec":  true,
+	"score":         true,
+}
+
+// LeaderboardWeights maps a metric component name (e.g. "sharpe",
+// "max_drawdown", "pnl_pct") to its weight in a composite "score" ranking.
+type LeaderboardWeights map[string]float64
+
+// ParseLeaderboardWeights parses the weights query param format
+// "sharpe:0.4,max_drawdown:0.3,pnl_pct:0.3" into a LeaderboardWeights map.
+func ParseLeaderboardWeights(raw string) (LeaderboardWeights, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+	weights := make(LeaderboardWeights)
+	for _, pair := range strings.Split(raw, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("weights entry %q must be metric:weight", pair)
+		}
+		key := strings.ToLower(strings.TrimSpace(parts[0]))
+		value, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("weights entry %q has a non-numeric weight", pair)
+		}
+		weights[key] = value
+	}
+	return weights, nil
+}
+
+// leaderboardComponent reads the agentComputed field a single (non-score)
+// metric name sorts by.
+func leaderboardComponent(item agentComputed, metric string) float64 {
+	switch metric {
+	case "sharpe":
+		return item.Sharpe
+	case "sortino":
+		return item.Sortino
+	case "max_drawdown":
+		return item.Drawdown
+	case "calmar":
+		return item.Calmar
+	case "profit_factor":
+		return item.ProfitFactor
+	case "avg_hold_sec":
+		return item.AvgHoldSec
+	case "win_rate":
+		return item.WinRate
+	default:
+		return item.PnlPct
+	}
+}
+
+// leaderboardScore computes the weighted-sum composite ranking value for
+// the "score" metric; components absent from weights simply contribute 0.
+func leaderboardScore(item agentComputed, weights LeaderboardWeights) float64 {
+	score := 0.0
+	for metric, weight := range weights {
+		score += leaderboardComponent(item, metric) * weight
+	}
+	return score
+}
+
+// leaderboardLess reports whether a should rank above b for metric,
+// breaking ties on pnl_pct the same way the original pnl_pct/win_rate
+// sort did.
+func leaderboardLess(a, b agentComputed, metric string, weights LeaderboardWeights) bool {
+	var av, bv float64
+	if metric == "score" {
+		av, bv = leaderboardScore(a, weights), leaderboardScore(b, weights)
+	} else {
+		av, bv = leaderboardComponent(a, metric), leaderboardComponent(b, metric)
+	}
+	if av == bv {
+		return a.PnlPct > b.PnlPct
+	}
+	return av > bv
+}
+
+// LeaderboardFilter scopes a GetLeaderboard query.
+type LeaderboardFilter struct {
+	Metric        string
+	Period        string
+	MinTrades     int
+	MinActiveDays int
+	ExcludeAgents []string
+	Weights       LeaderboardWeights
+}
+
+// LeaderboardResult is GetLeaderboard's return value: the ranked items
+// plus a content fingerprint callers can expose as an ETag, or compare
+// against a previously-fetched fingerprint to detect "nothing changed".
+type LeaderboardResult struct {
+	Items       []LeaderboardItem
+	Fingerprint string
+}
+
+// leaderboardCacheTTL bounds how long GetLeaderboard serves a cached
+// result for a given filter before recomputing from trades.
+const leaderboardCacheTTL = 5 * time.Second
+
+type leaderboardCacheEntry struct {
+	computedAt int64
+	result     LeaderboardResult
+}
+
+// leaderboardCache memoizes GetLeaderboard results per filter, since a
+// busy leaderboard.updates websocket channel would otherwise recompute
+// computePortfolio for every connected subscriber's poll tick.
+type leaderboardCache struct {
+	mu      sync.Mutex
+	entries map[string]leaderboardCacheEntry
+}
+
+func newLeaderboardCache() *leaderboardCache {
+	return &leaderboardCache{entries: map[string]leaderboardCacheEntry{}}
+}
+
+func (c *leaderboardCache) get(key string) (LeaderboardResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || time.Now().Unix()-entry.computedAt > int64(leaderboardCacheTTL/time.Second) {
+		return LeaderboardResult{}, false
+	}
+	return entry.result, true
+}
+
+func (c *leaderboardCache) set(key string, result LeaderboardResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = leaderboardCacheEntry{computedAt: time.Now().Unix(), result: result}
+}
+
+// leaderboardCacheKey derives a cache/ETag key from (metric, period,
+// min_trades, min_active_days, excluded agents, weights) so two filters
+// that differ only in, say, weights never share a cached result.
+func leaderboardCacheKey(filter LeaderboardFilter) string {
+	excluded := append([]string(nil), filter.ExcludeAgents...)
+	sort.Strings(excluded)
+	return fmt.Sprintf(
+		"%s|%s|%d|%d|%s|%s",
+		filter.Metric,
+		filter.Period,
+		filter.MinTrades,
+		filter.MinActiveDays,
+		strings.Join(excluded, ","),
+		computeFingerprint(filter.Weights),
+	)
+}
@@ -0,0 +1,170 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/coldbell/dex/backend/internal/tenant"
+)
+
+// MarginTransfer describes a collateral transfer that accompanied a
+// position-modifying instruction, for insertPositionHistoryTx to record
+// alongside the position_history row it's already writing in the same tx.
+// Direction is "deposit" or "withdraw"; Amount is the transferred amount as
+// a decimal string, always positive regardless of direction.
+type MarginTransfer struct {
+	Direction        string
+	Asset            string
+	Amount           string
+	TxnSignature     string
+	InstructionIndex int
+}
+
+// MarginLedgerEntry is one row of margin_deposits or margin_withdraws.
+type MarginLedgerEntry struct {
+	ID               int64  `json:"id"`
+	UserMargin       string `json:"user_margin"`
+	Asset            string `json:"asset"`
+	Amount           string `json:"amount"`
+	TxnSignature     string `json:"txn_signature"`
+	InstructionIndex int    `json:"instruction_index"`
+	Slot             uint64 `json:"slot"`
+	RecordedAt       int64  `json:"recorded_at"`
+}
+
+// MarginLedger is GetMarginLedger's result: userMargin's deposits and
+// withdraws over the requested window, plus the net external flow
+// (deposits - withdraws) the NAV subsystem needs to separate "equity moved
+// because collateral came in/out" from "equity moved because of trading
+// PnL or funding".
+type MarginLedger struct {
+	Deposits        []MarginLedgerEntry `json:"deposits"`
+	Withdraws       []MarginLedgerEntry `json:"withdraws"`
+	NetExternalFlow string              `json:"net_external_flow"`
+}
+
+// InsertMarginDepositTx records one collateral deposit into userMargin.
+// Idempotent on (txn_signature, instruction_index): replaying the same
+// instruction twice (e.g. after a restart re-processes a slot range)
+// upserts in place rather than double-counting it.
+func (s *Store) InsertMarginDepositTx(ctx context.Context, tx *Tx, tenantID, userMargin, asset, amount, txnSignature string, instructionIndex int, slot uint64, recordedAt int64) error {
+	return insertMarginLedgerRowTx(ctx, tx, "margin_deposits", tenantID, userMargin, asset, amount, txnSignature, instructionIndex, slot, recordedAt)
+}
+
+// InsertMarginWithdrawTx is InsertMarginDepositTx for the margin_withdraws
+// table.
+func (s *Store) InsertMarginWithdrawTx(ctx context.Context, tx *Tx, tenantID, userMargin, asset, amount, txnSignature string, instructionIndex int, slot uint64, recordedAt int64) error {
+	return insertMarginLedgerRowTx(ctx, tx, "margin_withdraws", tenantID, userMargin, asset, amount, txnSignature, instructionIndex, slot, recordedAt)
+}
+
+func insertMarginLedgerRowTx(ctx context.Context, tx *Tx, table, tenantID, userMargin, asset, amount, txnSignature string, instructionIndex int, slot uint64, recordedAt int64) error {
+	query := fmt.Sprintf(`
+		INSERT INTO %s (tenant_id, user_margin, asset, amount, txn_signature, instruction_index, slot, recorded_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		%s
+	`, table, tx.dialect.UpsertClause([]string{"txn_signature", "instruction_index"}, []string{
+		"tenant_id", "user_margin", "asset", "amount", "slot", "recorded_at",
+	}))
+	_, err := tx.ExecContext(ctx, query,
+		tenantID, userMargin, asset, amount, txnSignature, instructionIndex, int64(slot), recordedAt,
+	)
+	return err
+}
+
+// recordMarginTransferTx inserts transfer into margin_deposits or
+// margin_withdraws per its Direction, called from insertPositionHistoryTx
+// when the caller supplies one. transfer may be nil: most position
+// updates (funding accrual, liquidation, a pure size/price change) don't
+// carry an accompanying collateral transfer.
+func (s *Store) recordMarginTransferTx(ctx context.Context, tx *Tx, tenantID, userMargin string, slot uint64, recordedAt int64, transfer *MarginTransfer) error {
+	if transfer == nil {
+		return nil
+	}
+	switch transfer.Direction {
+	case "deposit":
+		return s.InsertMarginDepositTx(ctx, tx, tenantID, userMargin, transfer.Asset, transfer.Amount, transfer.TxnSignature, transfer.InstructionIndex, slot, recordedAt)
+	case "withdraw":
+		return s.InsertMarginWithdrawTx(ctx, tx, tenantID, userMargin, transfer.Asset, transfer.Amount, transfer.TxnSignature, transfer.InstructionIndex, slot, recordedAt)
+	default:
+		return fmt.Errorf("margin transfer: unknown direction %q", transfer.Direction)
+	}
+}
+
+// GetMarginLedger returns userMargin's deposits and withdraws recorded
+// between from and to (unix seconds; either may be 0 to leave that bound
+// open), oldest first, plus the net external flow across both.
+func (s *Store) GetMarginLedger(ctx context.Context, userMargin string, from, to int64) (MarginLedger, error) {
+	tenantID, err := tenant.RequireFromContext(ctx)
+	if err != nil {
+		return MarginLedger{}, err
+	}
+
+	deposits, err := listMarginLedgerEntriesTx(ctx, s.db, "margin_deposits", tenantID, userMargin, from, to)
+	if err != nil {
+		return MarginLedger{}, fmt.Errorf("list margin deposits: %w", err)
+	}
+	withdraws, err := listMarginLedgerEntriesTx(ctx, s.db, "margin_withdraws", tenantID, userMargin, from, to)
+	if err != nil {
+		return MarginLedger{}, fmt.Errorf("list margin withdraws: %w", err)
+	}
+
+	net := big.NewInt(0)
+	for _, entry := range deposits {
+		net.Add(net, parseBigIntOrZero(entry.Amount))
+	}
+	for _, entry := range withdraws {
+		net.Sub(net, parseBigIntOrZero(entry.Amount))
+	}
+
+	return MarginLedger{
+		Deposits:        deposits,
+		Withdraws:       withdraws,
+		NetExternalFlow: net.String(),
+	}, nil
+}
+
+func listMarginLedgerEntriesTx(ctx context.Context, db *DB, table, tenantID, userMargin string, from, to int64) ([]MarginLedgerEntry, error) {
+	clauses := []string{"tenant_id = ?", "user_margin = ?"}
+	args := []any{tenantID, userMargin}
+	if from > 0 {
+		clauses = append(clauses, "recorded_at >= ?")
+		args = append(args, from)
+	}
+	if to > 0 {
+		clauses = append(clauses, "recorded_at < ?")
+		args = append(args, to)
+	}
+	where := clauses[0]
+	for _, clause := range clauses[1:] {
+		where += " AND " + clause
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, user_margin, asset, amount, txn_signature, instruction_index, slot, recorded_at
+		FROM %s
+		WHERE %s
+		ORDER BY recorded_at ASC, id ASC
+	`, table, where)
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]MarginLedgerEntry, 0)
+	for rows.Next() {
+		var item MarginLedgerEntry
+		var slot int64
+		if err := rows.Scan(
+			&item.ID, &item.UserMargin, &item.Asset, &item.Amount,
+			&item.TxnSignature, &item.InstructionIndex, &slot, &item.RecordedAt,
+		); err != nil {
+			return nil, err
+		}
+		item.Slot = uint64(slot)
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
@@ -0,0 +1,193 @@
+package indexer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// contractTypePerp and its siblings are the contract_type values the
+// exchange venues this indexer tracks use for perpetual and dated futures
+// markets; spot markets use contractTypeSpot.
+const (
+	contractTypeSpot      = "spot"
+	contractTypePerp      = "perp"
+	contractTypeThisWeek  = "this_week"
+	contractTypeNextWeek  = "next_week"
+	contractTypeQuarter   = "quarter"
+	defaultPriceTickSize  = 0.01
+	defaultAmountTickSize = 0.0001
+)
+
+// defaultSupportedIntervals mirrors the chart timeframe set handleChartCandles
+// already accepts; it's used for any market without its own instrument row
+// so chart requests for unconfigured markets keep working unchanged.
+var defaultSupportedIntervals = []string{"1m", "5m", "15m", "1h", "4h", "1d"}
+
+// MarketInstrumentRecord is a market's tick-size and contract metadata,
+// letting front-ends round order sizes/prices to valid ticks and label
+// perp/futures contracts without guessing from NormalizeMarketSymbol alone.
+type MarketInstrumentRecord struct {
+	Market             string   `json:"market"`
+	PriceTickSize      float64  `json:"price_tick_size"`
+	AmountTickSize     float64  `json:"amount_tick_size"`
+	QuoteCurrency      string   `json:"quote_currency"`
+	UnderlyingIndex    string   `json:"underlying_index"`
+	ContractVal        *float64 `json:"contract_val,omitempty"`
+	ContractType       string   `json:"contract_type"`
+	Delivery           *int64   `json:"delivery,omitempty"`
+	SupportedIntervals []string `json:"supported_intervals"`
+	UpdatedAt          int64    `json:"updated_at"`
+}
+
+func isKnownContractType(contractType string) bool {
+	switch contractType {
+	case contractTypeSpot, contractTypePerp, contractTypeThisWeek, contractTypeNextWeek, contractTypeQuarter:
+		return true
+	default:
+		return false
+	}
+}
+
+// UpsertMarketInstrument creates or replaces market's instrument metadata.
+func (s *Store) UpsertMarketInstrument(ctx context.Context, record MarketInstrumentRecord) error {
+	market := normalizeMarketWithDefault(record.Market)
+	contractType := strings.ToLower(strings.TrimSpace(record.ContractType))
+	if contractType == "" {
+		contractType = contractTypeSpot
+	}
+	if !isKnownContractType(contractType) {
+		return fmt.Errorf("contract_type must be one of spot, perp, this_week, next_week, quarter")
+	}
+	priceTickSize := record.PriceTickSize
+	if priceTickSize <= 0 {
+		priceTickSize = defaultPriceTickSize
+	}
+	amountTickSize := record.AmountTickSize
+	if amountTickSize <= 0 {
+		amountTickSize = defaultAmountTickSize
+	}
+	intervals := record.SupportedIntervals
+	if len(intervals) == 0 {
+		intervals = defaultSupportedIntervals
+	}
+	intervalsJSON, err := json.Marshal(intervals)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(
+		ctx,
+		`INSERT INTO market_instruments (
+			market, price_tick_size, amount_tick_size, quote_currency, underlying_index,
+			contract_val, contract_type, delivery, supported_intervals_json, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (market) DO UPDATE SET
+			price_tick_size = EXCLUDED.price_tick_size,
+			amount_tick_size = EXCLUDED.amount_tick_size,
+			quote_currency = EXCLUDED.quote_currency,
+			underlying_index = EXCLUDED.underlying_index,
+			contract_val = EXCLUDED.contract_val,
+			contract_type = EXCLUDED.contract_type,
+			delivery = EXCLUDED.delivery,
+			supported_intervals_json = EXCLUDED.supported_intervals_json,
+			updated_at = EXCLUDED.updated_at`,
+		market,
+		priceTickSize,
+		amountTickSize,
+		record.QuoteCurrency,
+		record.UnderlyingIndex,
+		record.ContractVal,
+		contractType,
+		record.Delivery,
+		string(intervalsJSON),
+		time.Now().Unix(),
+	)
+	return err
+}
+
+// GetMarketInstrument returns market's instrument metadata. Callers that
+// want to tolerate unconfigured markets should check errors.Is(err,
+// ErrNotFound) and fall back to defaults rather than failing the request.
+func (s *Store) GetMarketInstrument(ctx context.Context, market string) (MarketInstrumentRecord, error) {
+	normalized := normalizeMarketWithDefault(market)
+	row := s.db.QueryRowContext(
+		ctx,
+		`SELECT market, price_tick_size, amount_tick_size, quote_currency, underlying_index,
+			contract_val, contract_type, delivery, supported_intervals_json, updated_at
+		 FROM market_instruments
+		 WHERE market = ?`,
+		normalized,
+	)
+	return scanMarketInstrument(row)
+}
+
+// ListMarketInstruments returns every configured market's instrument
+// metadata, ordered alphabetically by market symbol.
+func (s *Store) ListMarketInstruments(ctx context.Context) ([]MarketInstrumentRecord, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT market, price_tick_size, amount_tick_size, quote_currency, underlying_index,
+			contract_val, contract_type, delivery, supported_intervals_json, updated_at
+		 FROM market_instruments
+		 ORDER BY market ASC`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []MarketInstrumentRecord
+	for rows.Next() {
+		item, err := scanMarketInstrument(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+type instrumentScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanMarketInstrument(row instrumentScanner) (MarketInstrumentRecord, error) {
+	var item MarketInstrumentRecord
+	var contractVal sql.NullFloat64
+	var delivery sql.NullInt64
+	var intervalsJSON string
+	if err := row.Scan(
+		&item.Market,
+		&item.PriceTickSize,
+		&item.AmountTickSize,
+		&item.QuoteCurrency,
+		&item.UnderlyingIndex,
+		&contractVal,
+		&item.ContractType,
+		&delivery,
+		&intervalsJSON,
+		&item.UpdatedAt,
+	); err != nil {
+		if err == sql.ErrNoRows {
+			return MarketInstrumentRecord{}, ErrNotFound
+		}
+		return MarketInstrumentRecord{}, err
+	}
+	if contractVal.Valid {
+		item.ContractVal = &contractVal.Float64
+	}
+	if delivery.Valid {
+		item.Delivery = &delivery.Int64
+	}
+	if err := json.Unmarshal([]byte(intervalsJSON), &item.SupportedIntervals); err != nil {
+		return MarketInstrumentRecord{}, err
+	}
+	return item, nil
+}
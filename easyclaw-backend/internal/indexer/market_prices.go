@@ -10,6 +10,24 @@ import (
 
 const defaultMarketSymbol = "BTCUSDT"
 
+// Candle aggregation names accepted by GetMarketCandles' aggregations
+// parameter. OHLCV is always returned; these select which additional
+// weighted-price columns are worth computing for the caller.
+const (
+	CandleAggregationVWAP         = "vwap"
+	CandleAggregationTWAP         = "twap"
+	CandleAggregationTypicalPrice = "typical_price"
+)
+
+func hasCandleAggregation(aggregations []string, name string) bool {
+	for _, a := range aggregations {
+		if strings.EqualFold(a, name) {
+			return true
+		}
+	}
+	return false
+}
+
 type MarketPriceTickInput struct {
 	Market      string
 	Source      string
@@ -21,6 +39,19 @@ type MarketPriceTickInput struct {
 	Expo        int32
 	ReceivedAt  int64
 	RawJSON     string
+
+	// Size is the trade/update size behind this tick, in base units, if
+	// the source can report one (an oracle source like Pyth generally
+	// can't and leaves this zero). It feeds the VWAP aggregate in
+	// GetMarketCandles; buckets with no sized ticks simply report a zero
+	// VWAP rather than fabricating one.
+	Size float64
+
+	// QualityFlags records which of the source's quality gates a tick
+	// failed (see pricesource.QualityFlags). A non-zero value still gets
+	// stored, so operators can analyze rejected ticks after the fact
+	// instead of them being silently discarded.
+	QualityFlags uint32
 }
 
 type MarketPriceRecord struct {
@@ -93,8 +124,8 @@ func (s *Store) InsertMarketPriceTick(ctx context.Context, input MarketPriceTick
 		ctx,
 		`
 		INSERT INTO market_price_ticks (
-			market, source, feed_id, slot, publish_time, price, conf, expo, received_at, raw_json
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			market, source, feed_id, slot, publish_time, price, conf, expo, received_at, raw_json, quality_flags, size
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		ON CONFLICT (market, source, publish_time, slot) DO NOTHING
 		`,
 		market,
@@ -107,6 +138,8 @@ func (s *Store) InsertMarketPriceTick(ctx context.Context, input MarketPriceTick
 		int64(input.Expo),
 		receivedAt,
 		rawJSON,
+		input.QualityFlags,
+		input.Size,
 	)
 	if err != nil {
 		return false, err
@@ -127,7 +160,7 @@ func (s *Store) GetLatestMarketPrice(ctx context.Context, market string) (Market
 		`
 		SELECT market, source, feed_id, slot, publish_time, price, conf, expo, received_at
 		FROM market_price_ticks
-		WHERE market = ?
+		WHERE market = ? AND quality_flags = 0
 		ORDER BY publish_time DESC, slot DESC, id DESC
 		LIMIT 1
 		`,
@@ -159,7 +192,217 @@ func (s *Store) GetLatestMarketPrice(ctx context.Context, market string) (Market
 	return item, nil
 }
 
-func (s *Store) GetMarketCandles(ctx context.Context, market string, intervalSec int64, limit int) ([]CandleRecord, error) {
+// GetLatestMarketPricesBySource returns the most recent tick from every
+// distinct (source, feed_id) pair reporting on market, newest first. With
+// multiple oracle sources coexisting for the same market, callers use
+// this to compute cross-source deviation alerts.
+func (s *Store) GetLatestMarketPricesBySource(ctx context.Context, market string) ([]MarketPriceRecord, error) {
+	normalized := normalizeMarketWithDefault(market)
+
+	rows, err := s.db.QueryContext(
+		ctx,
+		`
+		SELECT DISTINCT ON (source, feed_id)
+			market, source, feed_id, slot, publish_time, price, conf, expo, received_at
+		FROM market_price_ticks
+		WHERE market = ? AND quality_flags = 0
+		ORDER BY source, feed_id, publish_time DESC, slot DESC, id DESC
+		`,
+		normalized,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []MarketPriceRecord
+	for rows.Next() {
+		var item MarketPriceRecord
+		var expo int64
+		if err := rows.Scan(
+			&item.Market,
+			&item.Source,
+			&item.FeedID,
+			&item.Slot,
+			&item.PublishTime,
+			&item.Price,
+			&item.Conf,
+			&expo,
+			&item.ReceivedAt,
+		); err != nil {
+			return nil, err
+		}
+		item.Expo = int32(expo)
+		item.Price = round2(item.Price)
+		item.Conf = round6(item.Conf)
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// InsertMarketPriceCandles upserts a batch of CandleAggregator's finalized
+// buckets into market_price_candles. Upserting (rather than a plain
+// INSERT) keeps a retried flush after a partial failure idempotent, since
+// a bucket only ever closes once but FlushClosed may be called again with
+// the same candle if persisting it previously errored.
+func (s *Store) InsertMarketPriceCandles(ctx context.Context, candles []ClosedCandle) error {
+	if len(candles) == 0 {
+		return nil
+	}
+	for _, candle := range candles {
+		_, err := s.db.ExecContext(
+			ctx,
+			`
+			INSERT INTO market_price_candles (market, interval_sec, bucket_ts, open, high, low, close, volume, vwap, twap, typical_price)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (market, interval_sec, bucket_ts) DO UPDATE SET
+				open = excluded.open,
+				high = excluded.high,
+				low = excluded.low,
+				close = excluded.close,
+				volume = excluded.volume,
+				vwap = excluded.vwap,
+				twap = excluded.twap,
+				typical_price = excluded.typical_price
+			`,
+			normalizeMarketWithDefault(candle.Market),
+			candle.IntervalSec,
+			candle.TS,
+			candle.Open,
+			candle.High,
+			candle.Low,
+			candle.Close,
+			candle.Volume,
+			candle.VWAP,
+			candle.TWAP,
+			candle.TypicalPrice,
+		)
+		if err != nil {
+			return fmt.Errorf("upsert candle market=%s interval=%d bucket=%d: %w", candle.Market, candle.IntervalSec, candle.TS, err)
+		}
+	}
+	return nil
+}
+
+// UpsertCandles persists a batch of CandleRollup's finalized (market_id,
+// tf) buckets into candles. Upserting on (market_id, tf, ts) keeps a
+// retried FlushClosed idempotent the same way InsertMarketPriceCandles is:
+// a bucket only closes once, but the flush that persists it may be
+// retried with the same rows if it errored partway through last time.
+func (s *Store) UpsertCandles(ctx context.Context, updates []CandleRollupUpdate) error {
+	if len(updates) == 0 {
+		return nil
+	}
+	for _, update := range updates {
+		_, err := s.db.ExecContext(
+			ctx,
+			`
+			INSERT INTO candles (market_id, tf, ts, open, high, low, close, volume)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (market_id, tf, ts) DO UPDATE SET
+				open = excluded.open,
+				high = excluded.high,
+				low = excluded.low,
+				close = excluded.close,
+				volume = excluded.volume
+			`,
+			int64(update.MarketID),
+			update.TF,
+			update.TS,
+			update.Open,
+			update.High,
+			update.Low,
+			update.Close,
+			update.Volume,
+		)
+		if err != nil {
+			return fmt.Errorf("upsert candle market_id=%d tf=%s ts=%d: %w", update.MarketID, update.TF, update.TS, err)
+		}
+	}
+	return nil
+}
+
+// GetRollupCandles returns marketID's candles for tf between from and to
+// (inclusive; either may be zero to leave that bound open), oldest first,
+// capped at limit (default/max same as GetMarketCandles). It serves
+// strictly closed buckets from the candles table CandleRollup.FlushClosed
+// populates - unlike GetMarketCandles, it doesn't merge in the
+// currently-open bucket, since fill-based rollups are typically consumed
+// for backtesting/history rather than a live-updating chart (SubscribeCandles
+// covers the live case).
+func (s *Store) GetRollupCandles(ctx context.Context, marketID uint64, tf string, from, to int64, limit int) ([]CandleRecord, error) {
+	if limit <= 0 {
+		limit = 120
+	}
+	if limit > 2000 {
+		limit = 2000
+	}
+
+	clauses := []string{"market_id = ?", "tf = ?"}
+	args := []any{int64(marketID), tf}
+	if from > 0 {
+		clauses = append(clauses, "ts >= ?")
+		args = append(args, from)
+	}
+	if to > 0 {
+		clauses = append(clauses, "ts <= ?")
+		args = append(args, to)
+	}
+	args = append(args, limit)
+
+	query := fmt.Sprintf(
+		`SELECT ts, open, high, low, close, volume
+		 FROM (
+		   SELECT ts, open, high, low, close, volume
+		   FROM candles
+		   WHERE %s
+		   ORDER BY ts DESC
+		   LIMIT ?
+		 ) recent
+		 ORDER BY ts ASC`,
+		strings.Join(clauses, " AND "),
+	)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []CandleRecord
+	for rows.Next() {
+		var candle CandleRecord
+		if err := rows.Scan(&candle.TS, &candle.Open, &candle.High, &candle.Low, &candle.Close, &candle.Volume); err != nil {
+			return nil, err
+		}
+		out = append(out, candle)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetMarketCandles returns up to limit candles for (market, intervalSec),
+// newest last. Buckets that have already closed are served from
+// market_price_candles, populated by CandleAggregator.FlushClosed, instead
+// of re-running the raw-tick aggregation query against the full lookback
+// window on every call; the current, still-open bucket is derived from
+// market_price_ticks directly (scoped to just that bucket's ticks) and
+// merged on top, since it hasn't been flushed yet.
+// GetMarketCandles returns up to limit candles for (market, intervalSec).
+// aggregations additionally selects which of CandleAggregationVWAP,
+// CandleAggregationTWAP, and CandleAggregationTypicalPrice to populate on
+// the still-open live bucket; a nil/empty slice skips all three, since
+// they're the more expensive part of the live-bucket query and many
+// callers (a plain OHLC chart) don't need them. Closed buckets always
+// carry them - CandleAggregator computes them once at flush time, so
+// there's no query-time cost to returning columns that are already there.
+func (s *Store) GetMarketCandles(ctx context.Context, market string, intervalSec int64, limit int, aggregations []string) ([]CandleRecord, error) {
 	normalized := normalizeMarketWithDefault(market)
 	if intervalSec <= 0 {
 		intervalSec = 60
@@ -171,58 +414,43 @@ func (s *Store) GetMarketCandles(ctx context.Context, market string, intervalSec
 		limit = 2000
 	}
 
-	lookbackBuckets := int64(limit * 8)
-	if lookbackBuckets < 240 {
-		lookbackBuckets = 240
+	currentBucketTS := (time.Now().Unix() / intervalSec) * intervalSec
+
+	historical, err := s.getClosedMarketCandles(ctx, normalized, intervalSec, currentBucketTS, limit)
+	if err != nil {
+		return nil, err
 	}
-	fromUnix := time.Now().Unix() - (lookbackBuckets * intervalSec)
 
+	live, ok, err := s.getLiveMarketCandle(ctx, normalized, intervalSec, currentBucketTS, aggregations)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return historical, nil
+	}
+
+	candles := append(historical, live)
+	if len(candles) > limit {
+		candles = candles[len(candles)-limit:]
+	}
+	return candles, nil
+}
+
+// getClosedMarketCandles reads already-flushed buckets from
+// market_price_candles, oldest first.
+func (s *Store) getClosedMarketCandles(ctx context.Context, normalizedMarket string, intervalSec, beforeBucketTS int64, limit int) ([]CandleRecord, error) {
 	rows, err := s.db.QueryContext(
 		ctx,
 		`
-		WITH bucketed AS (
-			SELECT
-				(publish_time / ?) * ? AS bucket_ts,
-				publish_time,
-				slot,
-				id,
-				price,
-				ROW_NUMBER() OVER (
-					PARTITION BY (publish_time / ?) * ?
-					ORDER BY publish_time ASC, slot ASC, id ASC
-				) AS rn_open,
-				ROW_NUMBER() OVER (
-					PARTITION BY (publish_time / ?) * ?
-					ORDER BY publish_time DESC, slot DESC, id DESC
-				) AS rn_close
-			FROM market_price_ticks
-			WHERE market = ?
-			  AND publish_time >= ?
-		),
-		aggregated AS (
-			SELECT
-				bucket_ts,
-				MAX(CASE WHEN rn_open = 1 THEN price END) AS open,
-				MAX(price) AS high,
-				MIN(price) AS low,
-				MAX(CASE WHEN rn_close = 1 THEN price END) AS close,
-				COUNT(*)::DOUBLE PRECISION AS volume
-			FROM bucketed
-			GROUP BY bucket_ts
-		)
-		SELECT bucket_ts, open, high, low, close, volume
-		FROM aggregated
+		SELECT bucket_ts, open, high, low, close, volume, vwap, twap, typical_price
+		FROM market_price_candles
+		WHERE market = ? AND interval_sec = ? AND bucket_ts < ?
 		ORDER BY bucket_ts DESC
 		LIMIT ?
 		`,
+		normalizedMarket,
 		intervalSec,
-		intervalSec,
-		intervalSec,
-		intervalSec,
-		intervalSec,
-		intervalSec,
-		normalized,
-		fromUnix,
+		beforeBucketTS,
 		limit,
 	)
 	if err != nil {
@@ -233,14 +461,7 @@ func (s *Store) GetMarketCandles(ctx context.Context, market string, intervalSec
 	candles := make([]CandleRecord, 0, limit)
 	for rows.Next() {
 		var item CandleRecord
-		if err := rows.Scan(
-			&item.TS,
-			&item.Open,
-			&item.High,
-			&item.Low,
-			&item.Close,
-			&item.Volume,
-		); err != nil {
+		if err := rows.Scan(&item.TS, &item.Open, &item.High, &item.Low, &item.Close, &item.Volume, &item.VWAP, &item.TWAP, &item.TypicalPrice); err != nil {
 			return nil, err
 		}
 		item.Open = round2(item.Open)
@@ -248,6 +469,9 @@ func (s *Store) GetMarketCandles(ctx context.Context, market string, intervalSec
 		item.Low = round2(item.Low)
 		item.Close = round2(item.Close)
 		item.Volume = round6(item.Volume)
+		item.VWAP = round2(item.VWAP)
+		item.TWAP = round2(item.TWAP)
+		item.TypicalPrice = round2(item.TypicalPrice)
 		candles = append(candles, item)
 	}
 	if err := rows.Err(); err != nil {
@@ -258,6 +482,80 @@ func (s *Store) GetMarketCandles(ctx context.Context, market string, intervalSec
 	for left, right := 0, len(candles)-1; left < right; left, right = left+1, right-1 {
 		candles[left], candles[right] = candles[right], candles[left]
 	}
-
 	return candles, nil
 }
+
+// getLiveMarketCandle aggregates the still-open bucket starting at
+// bucketTS directly from market_price_ticks. The scan is bounded to one
+// bucket's worth of ticks rather than the full chart lookback window, so
+// this stays cheap even though it re-aggregates on every call.
+func (s *Store) getLiveMarketCandle(ctx context.Context, normalizedMarket string, intervalSec, bucketTS int64, aggregations []string) (CandleRecord, bool, error) {
+	row := s.db.QueryRowContext(
+		ctx,
+		`
+		WITH bucketed AS (
+			SELECT
+				publish_time, slot, id, price, size,
+				LEAD(publish_time) OVER (ORDER BY publish_time ASC, slot ASC, id ASC) AS next_publish_time,
+				ROW_NUMBER() OVER (ORDER BY publish_time ASC, slot ASC, id ASC) AS rn_open,
+				ROW_NUMBER() OVER (ORDER BY publish_time DESC, slot DESC, id DESC) AS rn_close
+			FROM market_price_ticks
+			WHERE market = ? AND quality_flags = 0 AND publish_time >= ?
+		),
+		weighted AS (
+			SELECT *, COALESCE(next_publish_time, ?) - publish_time AS hold_duration
+			FROM bucketed
+		)
+		SELECT
+			MAX(CASE WHEN rn_open = 1 THEN price END) AS open,
+			MAX(price) AS high,
+			MIN(price) AS low,
+			MAX(CASE WHEN rn_close = 1 THEN price END) AS close,
+			COUNT(*)::DOUBLE PRECISION AS volume,
+			SUM(price * size) AS notional_sum,
+			SUM(size) AS size_sum,
+			SUM(price * hold_duration) AS twap_numerator,
+			SUM(hold_duration) AS twap_denominator
+		FROM weighted
+		`,
+		normalizedMarket,
+		bucketTS,
+		bucketTS+intervalSec,
+	)
+
+	var volume, notionalSum, sizeSum, twapNumerator, twapDenominator sql.NullFloat64
+	var openPrice, highPrice, lowPrice, closePrice sql.NullFloat64
+	if err := row.Scan(&openPrice, &highPrice, &lowPrice, &closePrice, &volume, &notionalSum, &sizeSum, &twapNumerator, &twapDenominator); err != nil {
+		if err == sql.ErrNoRows {
+			return CandleRecord{}, false, nil
+		}
+		return CandleRecord{}, false, err
+	}
+	if !volume.Valid || volume.Float64 == 0 {
+		return CandleRecord{}, false, nil
+	}
+
+	var item CandleRecord
+	item.TS = bucketTS
+	item.Open = round2(openPrice.Float64)
+	item.High = round2(highPrice.Float64)
+	item.Low = round2(lowPrice.Float64)
+	item.Close = round2(closePrice.Float64)
+	item.Volume = round6(volume.Float64)
+
+	if hasCandleAggregation(aggregations, CandleAggregationVWAP) && sizeSum.Valid && sizeSum.Float64 > 0 {
+		item.VWAP = round2(notionalSum.Float64 / sizeSum.Float64)
+	}
+	if hasCandleAggregation(aggregations, CandleAggregationTWAP) {
+		if twapDenominator.Valid && twapDenominator.Float64 > 0 {
+			item.TWAP = round2(twapNumerator.Float64 / twapDenominator.Float64)
+		} else {
+			item.TWAP = item.Close
+		}
+	}
+	if hasCandleAggregation(aggregations, CandleAggregationTypicalPrice) {
+		item.TypicalPrice = round2((item.High + item.Low + item.Close) / 3)
+	}
+
+	return item, true, nil
+}
@@ -0,0 +1,308 @@
+// Package migrate drives the indexer's schema through a versioned,
+// rockhopper/goose-style migration chain instead of the flat
+// CREATE-TABLE-IF-NOT-EXISTS slice Store.migrate used to run on every
+// boot. Each migration is a <version>_<name>.sql file embedded at build
+// time with a "-- +up" block (applied by Up/UpTo/Redo) and a "-- +down"
+// block (applied by Down/Redo); schema_migrations tracks which versions
+// have run and the checksum they ran with, so an edited-after-the-fact
+// migration file is caught at boot instead of silently diverging between
+// installs.
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+	"time"
+)
+
+//go:embed sql/*.sql
+var embeddedSQL embed.FS
+
+// Migration is one versioned schema change, parsed from a
+// <version>_<name>.sql file.
+type Migration struct {
+	Version  string
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// AppliedMigration is one schema_migrations row: a version Postgres has
+// actually run, the checksum it ran with, and when.
+type AppliedMigration struct {
+	Version   string
+	Checksum  string
+	AppliedAt int64
+}
+
+// StatusEntry reports one embedded migration's state against whatever (if
+// anything) schema_migrations has recorded for it.
+type StatusEntry struct {
+	Version    string
+	Name       string
+	Applied    bool
+	AppliedAt  int64
+	ChecksumOK bool
+}
+
+// Migrator drives schema_migrations against db using the migrations
+// embedded at build time.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// New loads every embedded migration file in version order. It doesn't
+// touch the database until Up/UpTo/Down/Redo/Status is called.
+func New(db *sql.DB) (*Migrator, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+func loadMigrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(embeddedSQL, "sql")
+	if err != nil {
+		return nil, fmt.Errorf("read embedded migrations: %w", err)
+	}
+
+	migrations := make([]Migration, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+		raw, err := fs.ReadFile(embeddedSQL, "sql/"+entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("read migration %s: %w", entry.Name(), err)
+		}
+		migration, err := parseMigration(entry.Name(), raw)
+		if err != nil {
+			return nil, fmt.Errorf("parse migration %s: %w", entry.Name(), err)
+		}
+		migrations = append(migrations, migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+const upMarker = "-- +up"
+const downMarker = "-- +down"
+
+func parseMigration(filename string, raw []byte) (Migration, error) {
+	version, name, ok := strings.Cut(strings.TrimSuffix(filename, ".sql"), "_")
+	if !ok {
+		return Migration{}, fmt.Errorf("filename %q must be <version>_<name>.sql", filename)
+	}
+
+	contents := string(raw)
+	upIdx := strings.Index(contents, upMarker)
+	downIdx := strings.Index(contents, downMarker)
+	if upIdx == -1 || downIdx == -1 || downIdx < upIdx {
+		return Migration{}, fmt.Errorf("must contain %q followed by %q", upMarker, downMarker)
+	}
+
+	checksum := sha256.Sum256(raw)
+	return Migration{
+		Version:  version,
+		Name:     name,
+		Up:       strings.TrimSpace(contents[upIdx+len(upMarker) : downIdx]),
+		Down:     strings.TrimSpace(contents[downIdx+len(downMarker):]),
+		Checksum: fmt.Sprintf("%x", checksum),
+	}, nil
+}
+
+const schemaMigrationsDDL = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+	version TEXT PRIMARY KEY,
+	checksum TEXT NOT NULL,
+	applied_at BIGINT NOT NULL
+);`
+
+func (m *Migrator) applied(ctx context.Context) (map[string]AppliedMigration, error) {
+	if _, err := m.db.ExecContext(ctx, schemaMigrationsDDL); err != nil {
+		return nil, fmt.Errorf("ensure schema_migrations table: %w", err)
+	}
+
+	rows, err := m.db.QueryContext(ctx, `SELECT version, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("load applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[string]AppliedMigration)
+	for rows.Next() {
+		var row AppliedMigration
+		if err := rows.Scan(&row.Version, &row.Checksum, &row.AppliedAt); err != nil {
+			return nil, err
+		}
+		applied[row.Version] = row
+	}
+	return applied, rows.Err()
+}
+
+// verifyChecksums refuses to proceed if any migration already recorded as
+// applied no longer matches the migration file embedded in this binary —
+// that mismatch means the file was edited after it shipped, which is
+// exactly the silent-drift failure mode a version+checksum table exists
+// to catch.
+func (m *Migrator) verifyChecksums(applied map[string]AppliedMigration) error {
+	var drifted []string
+	for _, migration := range m.migrations {
+		row, ok := applied[migration.Version]
+		if ok && row.Checksum != migration.Checksum {
+			drifted = append(drifted, migration.Version)
+		}
+	}
+	if len(drifted) > 0 {
+		return fmt.Errorf("schema migration checksum drift detected for version(s) %s: file contents changed after being applied", strings.Join(drifted, ", "))
+	}
+	return nil
+}
+
+// Up applies every pending migration, in version order.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.UpTo(ctx, "")
+}
+
+// UpTo applies every pending migration up to and including version, or
+// every pending migration if version is empty.
+func (m *Migrator) UpTo(ctx context.Context, version string) error {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+	if err := m.verifyChecksums(applied); err != nil {
+		return err
+	}
+
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		if err := m.applyUp(ctx, migration); err != nil {
+			return fmt.Errorf("apply migration %s_%s: %w", migration.Version, migration.Name, err)
+		}
+		if version != "" && migration.Version == version {
+			break
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) applyUp(ctx context.Context, migration Migration) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, migration.Up); err != nil {
+		return fmt.Errorf("run up: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO schema_migrations (version, checksum, applied_at) VALUES ($1, $2, $3)`,
+		migration.Version, migration.Checksum, time.Now().Unix(),
+	); err != nil {
+		return fmt.Errorf("record migration: %w", err)
+	}
+	return tx.Commit()
+}
+
+// Down rolls back the most recently applied migration.
+func (m *Migrator) Down(ctx context.Context) error {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+	if err := m.verifyChecksums(applied); err != nil {
+		return err
+	}
+
+	latest := m.latestApplied(applied)
+	if latest == nil {
+		return nil
+	}
+	return m.applyDown(ctx, *latest)
+}
+
+func (m *Migrator) latestApplied(applied map[string]AppliedMigration) *Migration {
+	var latest *Migration
+	for i := range m.migrations {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		if latest == nil || migration.Version > latest.Version {
+			latest = &migration
+		}
+	}
+	return latest
+}
+
+func (m *Migrator) applyDown(ctx context.Context, migration Migration) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, migration.Down); err != nil {
+		return fmt.Errorf("run down: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = $1`, migration.Version); err != nil {
+		return fmt.Errorf("unrecord migration: %w", err)
+	}
+	return tx.Commit()
+}
+
+// Redo rolls back and re-applies the most recently applied migration.
+func (m *Migrator) Redo(ctx context.Context) error {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+	if err := m.verifyChecksums(applied); err != nil {
+		return err
+	}
+
+	latest := m.latestApplied(applied)
+	if latest == nil {
+		return nil
+	}
+	if err := m.applyDown(ctx, *latest); err != nil {
+		return err
+	}
+	return m.applyUp(ctx, *latest)
+}
+
+// Status reports every embedded migration's applied state, in version
+// order.
+func (m *Migrator) Status(ctx context.Context) ([]StatusEntry, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]StatusEntry, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		row, ok := applied[migration.Version]
+		statuses = append(statuses, StatusEntry{
+			Version:    migration.Version,
+			Name:       migration.Name,
+			Applied:    ok,
+			AppliedAt:  row.AppliedAt,
+			ChecksumOK: !ok || row.Checksum == migration.Checksum,
+		})
+	}
+	return statuses, nil
+}
@@ -0,0 +1,327 @@
+package indexer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/coldbell/dex/backend/internal/tenant"
+)
+
+// navBucketResolutions maps GetNAVHistory's resolution argument to a bucket
+// width in seconds. "raw" (or anything else unrecognized) returns every
+// snapshot row as-is; any other value buckets to the last snapshot
+// recorded within each window, the same "latest value wins" choice
+// AggregateFills' mark-price subquery makes for point-in-time reads.
+var navBucketResolutions = map[string]int64{
+	"1m": 60,
+	"1h": 3600,
+	"1d": 86400,
+}
+
+// SnapshotUserMarginNAVTx computes userMargin's net asset value at slot and
+// upserts it into nav_history, keyed on (user_margin, slot) so a
+// reprocessed slot replaces its own snapshot rather than duplicating it.
+//
+// Collateral comes from the UserMargin resource account's "Collateral"
+// JSON field (see parseUserMarginCollateral - account_type "UserMargin" is
+// the only resource kind this reads). Unrealized PnL is summed across
+// userMargin's open positions using markPrices, keyed by market_id: a
+// market missing from markPrices is skipped rather than valued at zero, so
+// a caller that only has a fresh mark for the market it just touched
+// doesn't wipe out the NAV contribution of the user's other open
+// positions. Funding accrual is the cumulative funding paid across every
+// position_history row recorded for userMargin up to and including
+// recordedAt. Net equity is collateral + unrealizedPnL - fundingAccrual:
+// funding_paid_total is signed so that a positive value is a cost to the
+// position (see computeFundingPayment), which reduces equity.
+func (s *Store) SnapshotUserMarginNAVTx(ctx context.Context, tx *Tx, tenantID, userMargin string, slot uint64, recordedAt int64, markPrices map[uint64]string) error {
+	collateral, err := s.userMarginCollateralTx(ctx, tx, userMargin)
+	if err != nil {
+		return fmt.Errorf("collateral for %s: %w", userMargin, err)
+	}
+	unrealizedPnL, err := s.aggregateUnrealizedPnLTx(ctx, tx, tenantID, userMargin, markPrices)
+	if err != nil {
+		return fmt.Errorf("unrealized pnl for %s: %w", userMargin, err)
+	}
+	fundingAccrual, err := s.aggregateFundingAccrualTx(ctx, tx, tenantID, userMargin, recordedAt)
+	if err != nil {
+		return fmt.Errorf("funding accrual for %s: %w", userMargin, err)
+	}
+
+	netEquity := new(big.Int).Add(collateral, unrealizedPnL)
+	netEquity.Sub(netEquity, fundingAccrual)
+
+	query := fmt.Sprintf(`
+		INSERT INTO nav_history (tenant_id, user_margin, collateral, unrealized_pnl, funding_accrual, net_equity, slot, recorded_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		%s
+	`, tx.dialect.UpsertClause([]string{"tenant_id", "user_margin", "slot"}, []string{
+		"collateral", "unrealized_pnl", "funding_accrual", "net_equity", "recorded_at",
+	}))
+	_, err = tx.ExecContext(ctx, query,
+		tenantID, userMargin, collateral.String(), unrealizedPnL.String(), fundingAccrual.String(), netEquity.String(),
+		int64(slot), recordedAt,
+	)
+	return err
+}
+
+// userMarginCollateralTx reads the Collateral field out of userMargin's
+// UserMargin resource account, or 0 if that account hasn't been synced
+// yet (a position event can fire before the owning UserMargin account is
+// observed, on a fresh deposit). resources isn't one of the tables this
+// pass added tenant_id to, so this lookup stays keyed on pubkey alone.
+func (s *Store) userMarginCollateralTx(ctx context.Context, tx *Tx, userMargin string) (*big.Int, error) {
+	row := tx.QueryRowContext(ctx, `SELECT raw_json FROM resources WHERE account_type = 'UserMargin' AND pubkey = ?`, userMargin)
+	var rawJSON string
+	if err := row.Scan(&rawJSON); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return big.NewInt(0), nil
+		}
+		return nil, err
+	}
+	return parseUserMarginCollateral(rawJSON)
+}
+
+// parseUserMarginCollateral pulls the "Collateral" key out of a UserMargin
+// resource's raw_json, accepting either a JSON string or number since it's
+// unclear from this tree alone which encoding orderengine.Account_UserMargin
+// marshals to for a field that size. Absent entirely, collateral is 0
+// rather than an error: an older snapshot taken before this field existed
+// shouldn't break NAV snapshotting for every account going forward.
+func parseUserMarginCollateral(rawJSON string) (*big.Int, error) {
+	var payload map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(rawJSON), &payload); err != nil {
+		return nil, err
+	}
+	raw, ok := payload["Collateral"]
+	if !ok {
+		return big.NewInt(0), nil
+	}
+
+	var asString string
+	if err := json.Unmarshal(raw, &asString); err == nil {
+		return parseBigIntOrZero(asString), nil
+	}
+	var asNumber json.Number
+	if err := json.Unmarshal(raw, &asNumber); err == nil {
+		return parseBigIntOrZero(asNumber.String()), nil
+	}
+	return big.NewInt(0), nil
+}
+
+// aggregateUnrealizedPnLTx sums (net qty * mark price - net entry notional)
+// across every one of userMargin's open positions that has a mark price in
+// markPrices, mirroring PositionPnLSnapshot's per-position math in
+// aggregates.go but summed across all of a user's markets instead of
+// grouped by one.
+func (s *Store) aggregateUnrealizedPnLTx(ctx context.Context, tx *Tx, tenantID, userMargin string, markPrices map[uint64]string) (*big.Int, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT market_id, long_qty, long_entry_notional, short_qty, short_entry_notional
+		FROM positions
+		WHERE tenant_id = ? AND user_margin = ?
+	`, tenantID, userMargin)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	total := big.NewInt(0)
+	for rows.Next() {
+		var marketID int64
+		var longQty, longNotional, shortQty, shortNotional string
+		if err := rows.Scan(&marketID, &longQty, &longNotional, &shortQty, &shortNotional); err != nil {
+			return nil, err
+		}
+		markPrice, ok := markPrices[uint64(marketID)]
+		if !ok {
+			continue
+		}
+
+		netQty := new(big.Int).Sub(parseBigIntOrZero(longQty), parseBigIntOrZero(shortQty))
+		netNotional := new(big.Int).Sub(parseBigIntOrZero(longNotional), parseBigIntOrZero(shortNotional))
+		markValue := new(big.Int).Mul(netQty, parseBigIntOrZero(markPrice))
+		total.Add(total, new(big.Int).Sub(markValue, netNotional))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return total, nil
+}
+
+// aggregateFundingAccrualTx sums funding_paid_total across every
+// position_history row recorded for userMargin at or before
+// upToRecordedAt, giving the cumulative funding cost/credit folded into
+// net equity at that point in time.
+func (s *Store) aggregateFundingAccrualTx(ctx context.Context, tx *Tx, tenantID, userMargin string, upToRecordedAt int64) (*big.Int, error) {
+	row := tx.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(CAST(funding_paid_total AS NUMERIC)), 0)::TEXT
+		FROM position_history
+		WHERE tenant_id = ? AND user_margin = ? AND recorded_at <= ?
+	`, tenantID, userMargin, upToRecordedAt)
+	var sum string
+	if err := row.Scan(&sum); err != nil {
+		return nil, err
+	}
+	return parseBigIntOrZero(sum), nil
+}
+
+// latestMarkPriceTx returns the most recent fill price recorded for
+// marketID, or ok=false if the market hasn't traded yet.
+func (s *Store) latestMarkPriceTx(ctx context.Context, tx *Tx, marketID uint64) (price string, ok bool, err error) {
+	row := tx.QueryRowContext(ctx, `
+		SELECT price FROM fills WHERE market_id = ? ORDER BY executed_at DESC, id DESC LIMIT 1
+	`, int64(marketID))
+	if err := row.Scan(&price); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	return price, true, nil
+}
+
+// distinctUserMarginsTx lists every user_margin with at least one position,
+// open or closed, for the periodic sampler to iterate over.
+func distinctUserMarginsTx(ctx context.Context, tx *Tx, tenantID string) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT DISTINCT user_margin FROM positions WHERE tenant_id = ?`, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userMargins []string
+	for rows.Next() {
+		var userMargin string
+		if err := rows.Scan(&userMargin); err != nil {
+			return nil, err
+		}
+		userMargins = append(userMargins, userMargin)
+	}
+	return userMargins, rows.Err()
+}
+
+// latestMarkPricesTx returns each market's most recent fill price, keyed by
+// market_id, the same "last trade wins" mark used by PositionPnLSnapshot in
+// aggregates.go.
+func latestMarkPricesTx(ctx context.Context, tx *Tx) (map[uint64]string, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT DISTINCT ON (market_id) market_id, price
+		FROM fills
+		ORDER BY market_id, executed_at DESC, id DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	markPrices := make(map[uint64]string)
+	for rows.Next() {
+		var marketID int64
+		var price string
+		if err := rows.Scan(&marketID, &price); err != nil {
+			return nil, err
+		}
+		markPrices[uint64(marketID)] = price
+	}
+	return markPrices, rows.Err()
+}
+
+// NAVSnapshot is one nav_history row.
+type NAVSnapshot struct {
+	UserMargin     string `json:"user_margin"`
+	Collateral     string `json:"collateral"`
+	UnrealizedPnL  string `json:"unrealized_pnl"`
+	FundingAccrual string `json:"funding_accrual"`
+	NetEquity      string `json:"net_equity"`
+	Slot           uint64 `json:"slot"`
+	RecordedAt     int64  `json:"recorded_at"`
+}
+
+// GetNAVHistory returns userMargin's NAV snapshots between from and until
+// (unix seconds; either may be 0 to leave that bound open), oldest first
+// for charting an equity curve. resolution is "raw" for every snapshot
+// row, or "1m"/"1h"/"1d" to downsample to the last snapshot recorded in
+// each bucket - an unrecognized resolution falls back to raw.
+func (s *Store) GetNAVHistory(ctx context.Context, userMargin string, from, until int64, resolution string) ([]NAVSnapshot, error) {
+	tenantID, err := tenant.RequireFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	bucketSeconds, bucketed := navBucketResolutions[resolution]
+
+	clauses := []string{"tenant_id = ?", "user_margin = ?"}
+	args := []any{tenantID, userMargin}
+	if from > 0 {
+		clauses = append(clauses, "recorded_at >= ?")
+		args = append(args, from)
+	}
+	if until > 0 {
+		clauses = append(clauses, "recorded_at < ?")
+		args = append(args, until)
+	}
+	where := fmt.Sprintf("%s", clauses[0])
+	for _, clause := range clauses[1:] {
+		where += " AND " + clause
+	}
+
+	var query string
+	if bucketed {
+		query = fmt.Sprintf(`
+			SELECT DISTINCT ON ((recorded_at / %d))
+				user_margin, collateral, unrealized_pnl, funding_accrual, net_equity, slot, recorded_at
+			FROM nav_history
+			WHERE %s
+			ORDER BY (recorded_at / %d), recorded_at DESC
+		`, bucketSeconds, where, bucketSeconds)
+	} else {
+		query = fmt.Sprintf(`
+			SELECT user_margin, collateral, unrealized_pnl, funding_accrual, net_equity, slot, recorded_at
+			FROM nav_history
+			WHERE %s
+			ORDER BY recorded_at ASC
+		`, where)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]NAVSnapshot, 0)
+	for rows.Next() {
+		var item NAVSnapshot
+		var slot int64
+		if err := rows.Scan(
+			&item.UserMargin, &item.Collateral, &item.UnrealizedPnL, &item.FundingAccrual, &item.NetEquity,
+			&slot, &item.RecordedAt,
+		); err != nil {
+			return nil, err
+		}
+		item.Slot = uint64(slot)
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if bucketed {
+		sortNAVSnapshotsByRecordedAt(items)
+	}
+	return items, nil
+}
+
+// sortNAVSnapshotsByRecordedAt restores chronological order after the
+// bucketed query's DISTINCT ON groups rows by bucket rather than time.
+func sortNAVSnapshotsByRecordedAt(items []NAVSnapshot) {
+	for i := 1; i < len(items); i++ {
+		for j := i; j > 0 && items[j-1].RecordedAt > items[j].RecordedAt; j-- {
+			items[j-1], items[j] = items[j], items[j-1]
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package indexer
+
+import (
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coldbell/dex/backend/internal/config"
+)
+
+// consolidatedExchangePrefix marks a merged cross-venue snapshot's Exchange
+// field so it sorts alongside, but is distinguishable from, plain per-venue
+// snapshots in exchange_orderbook_snapshots.
+const consolidatedExchangePrefix = "consolidated:"
+
+type aggregatedOrderbookLevel struct {
+	level OrderbookLevel
+	venue string
+	price float64
+}
+
+type aggregatedOrderbookGroup struct {
+	target        config.ConsolidatedTarget
+	venuePriority map[string]int
+
+	mu        sync.Mutex
+	perVenue  map[string]OrderbookSnapshot
+	merged    OrderbookSnapshot
+	updatedAt time.Time
+}
+
+// aggregatedOrderbookCollector merges the per-venue snapshots of a
+// ConsolidatedTarget's venues into a single synthetic NBBO book per symbol,
+// recomputed event-driven off orderbookCollector.setCachedSnapshot.
+type aggregatedOrderbookCollector struct {
+	logger *slog.Logger
+	broker *OrderbookBroker
+	groups map[string]*aggregatedOrderbookGroup // keyed by uppercased symbol
+}
+
+func newAggregatedOrderbookCollector(targets []config.ConsolidatedTarget, logger *slog.Logger, broker *OrderbookBroker) *aggregatedOrderbookCollector {
+	if len(targets) == 0 {
+		return nil
+	}
+
+	groups := make(map[string]*aggregatedOrderbookGroup, len(targets))
+	for _, target := range targets {
+		venuePriority := make(map[string]int, len(target.Venues))
+		for i, venue := range target.Venues {
+			venuePriority[venue] = i
+		}
+		groups[strings.ToUpper(target.Symbol)] = &aggregatedOrderbookGroup{
+			target:        target,
+			venuePriority: venuePriority,
+			perVenue:      make(map[string]OrderbookSnapshot, len(target.Venues)),
+		}
+	}
+
+	return &aggregatedOrderbookCollector{logger: logger, broker: broker, groups: groups}
+}
+
+// OnSnapshot folds one venue's latest snapshot into every consolidated
+// group it feeds, recomputes that group's merged book, and - if a broker
+// was configured - publishes it live under the "consolidated:<SYMBOL>"
+// exchange key so OrderbookBroker.Subscribe callers can stream the merged,
+// per-level venue-tagged book the same way they stream any single venue's.
+func (a *aggregatedOrderbookCollector) OnSnapshot(snapshot OrderbookSnapshot) {
+	if a == nil {
+		return
+	}
+
+	group, ok := a.groups[strings.ToUpper(snapshot.Symbol)]
+	if !ok {
+		return
+	}
+	venue := strings.ToLower(snapshot.Exchange)
+	if _, tracked := group.venuePriority[venue]; !tracked {
+		return
+	}
+
+	group.mu.Lock()
+	group.perVenue[venue] = snapshot
+	group.merged = a.merge(group)
+	group.updatedAt = time.Now()
+	merged := group.merged
+	group.mu.Unlock()
+
+	if a.broker != nil {
+		a.broker.Publish(merged)
+	}
+}
+
+func (a *aggregatedOrderbookCollector) merge(group *aggregatedOrderbookGroup) OrderbookSnapshot {
+	depth := group.target.Depth
+	if depth <= 0 {
+		depth = 20
+	}
+
+	var bids, asks []aggregatedOrderbookLevel
+	var latestExchangeTS int64
+	for venue, snapshot := range group.perVenue {
+		if snapshot.ExchangeTimestamp > latestExchangeTS {
+			latestExchangeTS = snapshot.ExchangeTimestamp
+		}
+		for _, level := range snapshot.Levels {
+			price, err := strconv.ParseFloat(level.Price, 64)
+			if err != nil {
+				continue
+			}
+			tagged := aggregatedOrderbookLevel{level: level, venue: venue, price: price}
+			switch level.Side {
+			case orderbookSideBid:
+				bids = append(bids, tagged)
+			case orderbookSideAsk:
+				asks = append(asks, tagged)
+			}
+		}
+	}
+
+	sort.SliceStable(bids, func(i, j int) bool {
+		if bids[i].price != bids[j].price {
+			return bids[i].price > bids[j].price
+		}
+		return group.venuePriority[bids[i].venue] < group.venuePriority[bids[j].venue]
+	})
+	sort.SliceStable(asks, func(i, j int) bool {
+		if asks[i].price != asks[j].price {
+			return asks[i].price < asks[j].price
+		}
+		return group.venuePriority[asks[i].venue] < group.venuePriority[asks[j].venue]
+	})
+	if len(bids) > depth {
+		bids = bids[:depth]
+	}
+	if len(asks) > depth {
+		asks = asks[:depth]
+	}
+
+	a.logCrossedMarket(group.target.Symbol, bids, asks)
+
+	snapshot := OrderbookSnapshot{
+		Exchange:          consolidatedExchangePrefix + strings.ToUpper(group.target.Symbol),
+		Symbol:            group.target.Symbol,
+		ExchangeTimestamp: latestExchangeTS,
+		Levels:            make([]OrderbookLevel, 0, len(bids)+len(asks)),
+	}
+	for i, tagged := range bids {
+		level := tagged.level
+		level.Side = orderbookSideBid
+		level.Level = i
+		level.Venue = tagged.venue
+		snapshot.Levels = append(snapshot.Levels, level)
+	}
+	for i, tagged := range asks {
+		level := tagged.level
+		level.Side = orderbookSideAsk
+		level.Level = i
+		level.Venue = tagged.venue
+		snapshot.Levels = append(snapshot.Levels, level)
+	}
+	if len(bids) > 0 {
+		snapshot.BestBid = bids[0].level.Price
+	}
+	if len(asks) > 0 {
+		snapshot.BestAsk = asks[0].level.Price
+	}
+
+	return snapshot
+}
+
+// logCrossedMarket flags a locked/crossed synthetic NBBO (best bid at or
+// above best ask) on a side channel, since it usually signals a stale or
+// desynced venue rather than a genuine arbitrage opportunity.
+func (a *aggregatedOrderbookCollector) logCrossedMarket(symbol string, bids, asks []aggregatedOrderbookLevel) {
+	if len(bids) == 0 || len(asks) == 0 {
+		return
+	}
+	if bids[0].price < asks[0].price {
+		return
+	}
+	a.logger.Warn("consolidated orderbook crossed",
+		"symbol", symbol,
+		"bid_venue", bids[0].venue, "bid_price", bids[0].level.Price,
+		"ask_venue", asks[0].venue, "ask_price", asks[0].level.Price,
+	)
+}
+
+// SnapshotAll returns one merged OrderbookSnapshot per consolidated group
+// that has been updated at or after snapshotTime, mirroring
+// orderbookCollector.SnapshotAll so callers can persist both alongside each
+// other.
+func (a *aggregatedOrderbookCollector) SnapshotAll(snapshotTime int64) []OrderbookSnapshot {
+	if a == nil {
+		return nil
+	}
+
+	out := make([]OrderbookSnapshot, 0, len(a.groups))
+	for _, group := range a.groups {
+		group.mu.Lock()
+		if group.updatedAt.IsZero() || group.updatedAt.Unix() < snapshotTime {
+			group.mu.Unlock()
+			continue
+		}
+
+		snapshot := group.merged
+		snapshot.SnapshotTime = snapshotTime
+		if len(snapshot.Levels) > 0 {
+			levels := make([]OrderbookLevel, len(snapshot.Levels))
+			copy(levels, snapshot.Levels)
+			snapshot.Levels = levels
+		}
+		group.mu.Unlock()
+
+		out = append(out, snapshot)
+	}
+
+	return out
+}
@@ -0,0 +1,402 @@
+package indexer
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	orderbookBrokerPingInterval     = 20 * time.Second
+	orderbookBrokerSubscriberBuffer = 32
+)
+
+type orderbookBrokerMode string
+
+const (
+	orderbookBrokerModeSnapshot orderbookBrokerMode = "snapshot"
+	orderbookBrokerModeDelta    orderbookBrokerMode = "delta"
+)
+
+var orderbookBrokerUpgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+}
+
+type orderbookBrokerSubscribeRequest struct {
+	Op       string `json:"op"`
+	Exchange string `json:"exchange"`
+	Symbol   string `json:"symbol"`
+	Depth    int    `json:"depth"`
+	Mode     string `json:"mode"`
+}
+
+type orderbookBrokerMessage struct {
+	Type              string           `json:"type"`
+	Exchange          string           `json:"exchange"`
+	Symbol            string           `json:"symbol"`
+	Bids              []OrderbookLevel `json:"bids,omitempty"`
+	Asks              []OrderbookLevel `json:"asks,omitempty"`
+	ExchangeTimestamp int64            `json:"exchange_ts,omitempty"`
+	Error             string           `json:"error,omitempty"`
+}
+
+// OrderbookBroker fans out the live orderbook snapshots collected by
+// orderbookCollector to websocket subscribers, so downstream clients can
+// stream top-of-book changes over GET /ws/orderbook without opening a
+// direct connection to the underlying venue.
+type OrderbookBroker struct {
+	logger *slog.Logger
+
+	mu     sync.RWMutex
+	topics map[string]*orderbookBrokerTopic
+}
+
+func newOrderbookBroker(logger *slog.Logger) *OrderbookBroker {
+	return &OrderbookBroker{
+		logger: logger,
+		topics: make(map[string]*orderbookBrokerTopic),
+	}
+}
+
+type orderbookBrokerTopic struct {
+	mu          sync.Mutex
+	lastBids    []OrderbookLevel
+	lastAsks    []OrderbookLevel
+	subscribers map[*orderbookBrokerSubscriber]struct{}
+}
+
+type orderbookBrokerSubscriber struct {
+	exchange string
+	symbol   string
+	depth    int
+	mode     orderbookBrokerMode
+	send     chan []byte
+}
+
+func orderbookBrokerTopicKey(exchange, symbol string) string {
+	return strings.ToLower(exchange) + ":" + strings.ToUpper(symbol)
+}
+
+// Publish pushes exchange/symbol's latest snapshot to every subscriber on
+// that topic. It is called from orderbookCollector.setCachedSnapshot, so
+// fan-out is event-driven off the same updates that feed the poll-based
+// cache rather than a separate timer.
+func (b *OrderbookBroker) Publish(snapshot OrderbookSnapshot) {
+	key := orderbookBrokerTopicKey(snapshot.Exchange, snapshot.Symbol)
+
+	b.mu.RLock()
+	topic, ok := b.topics[key]
+	b.mu.RUnlock()
+	if !ok {
+		return
+	}
+
+	bids, asks := splitOrderbookLevels(snapshot.Levels)
+
+	topic.mu.Lock()
+	defer topic.mu.Unlock()
+
+	for sub := range topic.subscribers {
+		var payload orderbookBrokerMessage
+		if sub.mode == orderbookBrokerModeDelta && (topic.lastBids != nil || topic.lastAsks != nil) {
+			payload = orderbookBrokerMessage{
+				Type:              "delta",
+				Exchange:          snapshot.Exchange,
+				Symbol:            snapshot.Symbol,
+				ExchangeTimestamp: snapshot.ExchangeTimestamp,
+				Bids:              diffOrderbookLevels(topic.lastBids, bids, sub.depth),
+				Asks:              diffOrderbookLevels(topic.lastAsks, asks, sub.depth),
+			}
+		} else {
+			payload = orderbookBrokerMessage{
+				Type:              "snapshot",
+				Exchange:          snapshot.Exchange,
+				Symbol:            snapshot.Symbol,
+				ExchangeTimestamp: snapshot.ExchangeTimestamp,
+				Bids:              truncateLevels(bids, sub.depth),
+				Asks:              truncateLevels(asks, sub.depth),
+			}
+		}
+
+		encoded, err := json.Marshal(payload)
+		if err != nil {
+			continue
+		}
+		select {
+		case sub.send <- encoded:
+		default:
+			b.logger.Warn("orderbook broker subscriber too slow, dropping message",
+				"exchange", snapshot.Exchange, "symbol", snapshot.Symbol)
+		}
+	}
+
+	topic.lastBids = bids
+	topic.lastAsks = asks
+}
+
+// Subscribe registers sub on (exchange, symbol)'s topic and, if the topic
+// already has a cached state, immediately sends a full snapshot so the
+// subscriber has a baseline before any delta-mode updates arrive. Updates
+// for this subscriber are pushed onto send, which the caller owns.
+func (b *OrderbookBroker) Subscribe(exchange, symbol string, depth int, mode orderbookBrokerMode, send chan []byte) *orderbookBrokerSubscriber {
+	if depth <= 0 {
+		depth = 20
+	}
+	key := orderbookBrokerTopicKey(exchange, symbol)
+
+	b.mu.Lock()
+	topic, ok := b.topics[key]
+	if !ok {
+		topic = &orderbookBrokerTopic{subscribers: make(map[*orderbookBrokerSubscriber]struct{})}
+		b.topics[key] = topic
+	}
+	b.mu.Unlock()
+
+	sub := &orderbookBrokerSubscriber{
+		exchange: exchange,
+		symbol:   symbol,
+		depth:    depth,
+		mode:     mode,
+		send:     send,
+	}
+
+	topic.mu.Lock()
+	topic.subscribers[sub] = struct{}{}
+	if topic.lastBids != nil || topic.lastAsks != nil {
+		payload := orderbookBrokerMessage{
+			Type:     "snapshot",
+			Exchange: exchange,
+			Symbol:   symbol,
+			Bids:     truncateLevels(topic.lastBids, depth),
+			Asks:     truncateLevels(topic.lastAsks, depth),
+		}
+		if encoded, err := json.Marshal(payload); err == nil {
+			select {
+			case sub.send <- encoded:
+			default:
+			}
+		}
+	}
+	topic.mu.Unlock()
+
+	return sub
+}
+
+func (b *OrderbookBroker) Unsubscribe(sub *orderbookBrokerSubscriber) {
+	key := orderbookBrokerTopicKey(sub.exchange, sub.symbol)
+
+	b.mu.RLock()
+	topic, ok := b.topics[key]
+	b.mu.RUnlock()
+	if !ok {
+		return
+	}
+
+	topic.mu.Lock()
+	delete(topic.subscribers, sub)
+	topic.mu.Unlock()
+}
+
+// ServeWS upgrades r to a websocket connection, accepts one or more
+// {"op":"subscribe",...} / {"op":"unsubscribe",...} messages (one
+// subscription per (exchange, symbol) at a time), and streams the matching
+// topic's snapshots or deltas back to the client with a PingMessage every
+// orderbookBrokerPingInterval.
+func (b *OrderbookBroker) ServeWS(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	conn, err := orderbookBrokerUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		b.logger.Error("orderbook broker websocket upgrade failed", "err", err)
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	send := make(chan []byte, orderbookBrokerSubscriberBuffer)
+	active := newOrderbookBrokerActiveSubs()
+	defer active.unsubscribeAll(b)
+
+	readErrCh := make(chan error, 1)
+	go b.readLoop(ctx, conn, send, active, readErrCh)
+
+	ticker := time.NewTicker(orderbookBrokerPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case err := <-readErrCh:
+			if err != nil {
+				b.logger.Debug("orderbook broker read loop ended", "err", err)
+			}
+			return
+		case payload := <-send:
+			if err := conn.SetWriteDeadline(time.Now().Add(websocketWriteTimeout)); err != nil {
+				return
+			}
+			if err := conn.WriteMessage(websocket.TextMessage, payload); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := conn.SetWriteDeadline(time.Now().Add(websocketWriteTimeout)); err != nil {
+				return
+			}
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (b *OrderbookBroker) readLoop(
+	ctx context.Context,
+	conn *websocket.Conn,
+	send chan []byte,
+	active *orderbookBrokerActiveSubs,
+	readErrCh chan<- error,
+) {
+	conn.SetReadLimit(websocketReadLimitBytes)
+	for {
+		select {
+		case <-ctx.Done():
+			readErrCh <- nil
+			return
+		default:
+		}
+
+		var req orderbookBrokerSubscribeRequest
+		if err := conn.ReadJSON(&req); err != nil {
+			readErrCh <- err
+			return
+		}
+
+		op := strings.ToLower(strings.TrimSpace(req.Op))
+		exchange := strings.ToLower(strings.TrimSpace(req.Exchange))
+		symbol := strings.ToUpper(strings.TrimSpace(req.Symbol))
+		if exchange == "" || symbol == "" {
+			continue
+		}
+		key := orderbookBrokerTopicKey(exchange, symbol)
+
+		switch op {
+		case "subscribe":
+			mode := orderbookBrokerModeSnapshot
+			if strings.EqualFold(req.Mode, string(orderbookBrokerModeDelta)) {
+				mode = orderbookBrokerModeDelta
+			}
+			active.replace(key, b, b.Subscribe(exchange, symbol, req.Depth, mode, send))
+		case "unsubscribe":
+			active.remove(key, b)
+		}
+	}
+}
+
+// orderbookBrokerActiveSubs tracks one connection's live subscriptions so a
+// re-subscribe to the same topic replaces the old one and connection
+// teardown can unsubscribe everything cleanly.
+type orderbookBrokerActiveSubs struct {
+	mu   sync.Mutex
+	subs map[string]*orderbookBrokerSubscriber
+}
+
+func newOrderbookBrokerActiveSubs() *orderbookBrokerActiveSubs {
+	return &orderbookBrokerActiveSubs{subs: make(map[string]*orderbookBrokerSubscriber)}
+}
+
+func (a *orderbookBrokerActiveSubs) replace(key string, b *OrderbookBroker, sub *orderbookBrokerSubscriber) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if old, ok := a.subs[key]; ok {
+		b.Unsubscribe(old)
+	}
+	a.subs[key] = sub
+}
+
+func (a *orderbookBrokerActiveSubs) remove(key string, b *OrderbookBroker) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if old, ok := a.subs[key]; ok {
+		b.Unsubscribe(old)
+		delete(a.subs, key)
+	}
+}
+
+func (a *orderbookBrokerActiveSubs) unsubscribeAll(b *OrderbookBroker) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for key, sub := range a.subs {
+		b.Unsubscribe(sub)
+		delete(a.subs, key)
+	}
+}
+
+func splitOrderbookLevels(levels []OrderbookLevel) (bids, asks []OrderbookLevel) {
+	bids = make([]OrderbookLevel, 0, len(levels))
+	asks = make([]OrderbookLevel, 0, len(levels))
+	for _, level := range levels {
+		switch level.Side {
+		case orderbookSideBid:
+			bids = append(bids, level)
+		case orderbookSideAsk:
+			asks = append(asks, level)
+		}
+	}
+	sort.Slice(bids, func(i, j int) bool { return bids[i].Level < bids[j].Level })
+	sort.Slice(asks, func(i, j int) bool { return asks[i].Level < asks[j].Level })
+	return bids, asks
+}
+
+func truncateLevels(levels []OrderbookLevel, depth int) []OrderbookLevel {
+	if depth <= 0 || depth >= len(levels) {
+		out := make([]OrderbookLevel, len(levels))
+		copy(out, levels)
+		return out
+	}
+	out := make([]OrderbookLevel, depth)
+	copy(out, levels[:depth])
+	return out
+}
+
+// diffOrderbookLevels compares old and new (each truncated to depth) and
+// returns only the levels that changed: upserts for prices whose quantity
+// differs (or that are new), and zero-quantity removals for prices that
+// dropped out of the top of the book.
+func diffOrderbookLevels(old, updated []OrderbookLevel, depth int) []OrderbookLevel {
+	oldTop := truncateLevels(old, depth)
+	newTop := truncateLevels(updated, depth)
+
+	oldByPrice := make(map[string]string, len(oldTop))
+	for _, level := range oldTop {
+		oldByPrice[level.Price] = level.Quantity
+	}
+
+	var delta []OrderbookLevel
+	seen := make(map[string]bool, len(newTop))
+	for _, level := range newTop {
+		seen[level.Price] = true
+		if quantity, ok := oldByPrice[level.Price]; !ok || quantity != level.Quantity {
+			delta = append(delta, level)
+		}
+	}
+	for _, level := range oldTop {
+		if !seen[level.Price] {
+			delta = append(delta, OrderbookLevel{Side: level.Side, Level: level.Level, Price: level.Price, Quantity: "0"})
+		}
+	}
+	return delta
+}
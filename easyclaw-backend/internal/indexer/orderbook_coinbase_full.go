@@ -0,0 +1,303 @@
+package indexer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// coinbaseL3Order is one resting order on a CoinbaseFullChannelProvider's
+// in-memory L3 book, tracked only when the provider was built WithL3(true).
+type coinbaseL3Order struct {
+	symbol string
+	price  string
+	size   float64
+	side   string
+	ts     string
+}
+
+// CoinbaseL3Level is one coinbaseL3Order exposed through L3Snapshot.
+type CoinbaseL3Level struct {
+	OrderID string
+	Price   string
+	Size    float64
+	Side    string
+	Time    string
+}
+
+// CoinbaseFullChannelOption configures a CoinbaseFullChannelProvider at
+// construction time.
+type CoinbaseFullChannelOption func(*CoinbaseFullChannelProvider)
+
+// WithL3 gates whether the provider keeps its per-order L3 book (queue
+// position, individual order sizes) alongside the aggregated L2 levels it
+// always maintains. It defaults to off since the L3 book's memory cost
+// scales with open order count rather than price levels.
+func WithL3(enabled bool) CoinbaseFullChannelOption {
+	return func(p *CoinbaseFullChannelProvider) { p.keepL3 = enabled }
+}
+
+// CoinbaseFullChannelProvider streams Coinbase's "full" channel - every
+// received/open/done/match/change order event - instead of level2's
+// pre-aggregated price levels. It rebuilds the L2 view orderbookStreamHandler
+// expects by keeping a per-price-level sum(size) in the same levelBook the
+// level2 path uses, while optionally retaining the per-order L3 state that
+// level2 discards.
+type CoinbaseFullChannelProvider struct {
+	client  *http.Client
+	limiter RateLimiter
+	keepL3  bool
+
+	mu     sync.RWMutex
+	book   *levelBook
+	orders map[string]coinbaseL3Order // order_id -> order; populated only when keepL3
+}
+
+func NewCoinbaseFullChannelProvider(client *http.Client, limiter RateLimiter, opts ...CoinbaseFullChannelOption) *CoinbaseFullChannelProvider {
+	p := &CoinbaseFullChannelProvider{client: client, limiter: limiter, book: newLevelBook()}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if p.keepL3 {
+		p.orders = make(map[string]coinbaseL3Order)
+	}
+	return p
+}
+
+func (*CoinbaseFullChannelProvider) Name() string { return "coinbase" }
+
+func (*CoinbaseFullChannelProvider) SupportsWebsocket(depth int) bool {
+	return depth > 0
+}
+
+func (p *CoinbaseFullChannelProvider) FetchOrderbook(ctx context.Context, symbol string, depth int) ([]OrderbookLevel, []OrderbookLevel, int64, string, error) {
+	return fetchCoinbaseOrderbook(ctx, p.client, p.limiter, symbol, depth)
+}
+
+func (p *CoinbaseFullChannelProvider) StreamOrderbook(
+	ctx context.Context,
+	symbol string,
+	depth int,
+	handler orderbookStreamHandler,
+) error {
+	endpoint := "wss://ws-feed.exchange.coinbase.com"
+	conn, _, err := dialWebsocket(ctx, endpoint)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	stopClose := closeConnOnContextDone(ctx, conn)
+	defer stopClose()
+
+	subscribePayload := map[string]any{
+		"type": "subscribe",
+		"channels": []map[string]any{
+			{
+				"name":        "full",
+				"product_ids": []string{symbol},
+			},
+		},
+	}
+	if err := writeWebsocketJSON(conn, subscribePayload); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.book = newLevelBook()
+	p.book.initialized = true
+	if p.keepL3 {
+		for orderID, order := range p.orders {
+			if order.symbol == symbol {
+				delete(p.orders, orderID)
+			}
+		}
+	}
+	p.mu.Unlock()
+
+	for {
+		_, payload, err := conn.ReadMessage()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+
+		var message struct {
+			Type          string `json:"type"`
+			Message       string `json:"message"`
+			Reason        string `json:"reason"`
+			Time          string `json:"time"`
+			OrderID       string `json:"order_id"`
+			MakerOrderID  string `json:"maker_order_id"`
+			Side          string `json:"side"`
+			Price         string `json:"price"`
+			Size          string `json:"size"`
+			RemainingSize string `json:"remaining_size"`
+			NewSize       string `json:"new_size"`
+			OldSize       string `json:"old_size"`
+		}
+		if err := json.Unmarshal(payload, &message); err != nil {
+			continue
+		}
+		if message.Type == "error" {
+			return fmt.Errorf("coinbase full channel websocket error: %s %s", message.Reason, message.Message)
+		}
+
+		side := orderbookSideAsk
+		if strings.EqualFold(message.Side, "buy") {
+			side = orderbookSideBid
+		}
+
+		switch message.Type {
+		case "open":
+			size, err := strconv.ParseFloat(message.RemainingSize, 64)
+			if err != nil || message.Price == "" {
+				continue
+			}
+			p.mu.Lock()
+			p.adjustLevelLocked(side, message.Price, size)
+			if p.keepL3 {
+				p.orders[message.OrderID] = coinbaseL3Order{
+					symbol: symbol, price: message.Price, size: size, side: side, ts: message.Time,
+				}
+			}
+			p.mu.Unlock()
+
+		case "done":
+			p.mu.Lock()
+			remaining := 0.0
+			if order, tracked := p.orders[message.OrderID]; tracked {
+				remaining = order.size
+			} else if message.RemainingSize != "" {
+				remaining, _ = strconv.ParseFloat(message.RemainingSize, 64)
+			}
+			if message.Price != "" && remaining > 0 {
+				p.adjustLevelLocked(side, message.Price, -remaining)
+			}
+			if p.keepL3 {
+				delete(p.orders, message.OrderID)
+			}
+			p.mu.Unlock()
+
+		case "match":
+			size, err := strconv.ParseFloat(message.Size, 64)
+			if err != nil || message.Price == "" {
+				continue
+			}
+			p.mu.Lock()
+			p.adjustLevelLocked(side, message.Price, -size)
+			if p.keepL3 {
+				if order, tracked := p.orders[message.MakerOrderID]; tracked {
+					order.size -= size
+					if order.size <= 0 {
+						delete(p.orders, message.MakerOrderID)
+					} else {
+						p.orders[message.MakerOrderID] = order
+					}
+				}
+			}
+			p.mu.Unlock()
+
+		case "change":
+			if message.NewSize == "" || message.Price == "" {
+				continue
+			}
+			newSize, errNew := strconv.ParseFloat(message.NewSize, 64)
+			oldSize, errOld := strconv.ParseFloat(message.OldSize, 64)
+			if errNew != nil || errOld != nil {
+				continue
+			}
+			p.mu.Lock()
+			p.adjustLevelLocked(side, message.Price, newSize-oldSize)
+			if p.keepL3 {
+				if order, tracked := p.orders[message.OrderID]; tracked {
+					order.size = newSize
+					p.orders[message.OrderID] = order
+				}
+			}
+			p.mu.Unlock()
+
+		default:
+			// "received" and subscription acks don't change the book.
+			continue
+		}
+
+		p.mu.RLock()
+		book := p.book
+		p.mu.RUnlock()
+		if err := emitDepthUpdate(book, depth, parseCoinbaseTime(message.Time), string(payload), handler); err != nil {
+			return fmt.Errorf("coinbase full channel book invalid after %s: %w", message.Type, err)
+		}
+	}
+}
+
+// adjustLevelLocked adds delta to price's current aggregate size on side,
+// floored at zero, and writes the result back into p.book. Callers must
+// hold p.mu.
+func (p *CoinbaseFullChannelProvider) adjustLevelLocked(side, price string, delta float64) {
+	var existing string
+	if side == orderbookSideBid {
+		existing = p.book.bids[price]
+	} else {
+		existing = p.book.asks[price]
+	}
+
+	current := 0.0
+	if existing != "" {
+		current, _ = strconv.ParseFloat(existing, 64)
+	}
+	updated := current + delta
+	if updated < 0 {
+		updated = 0
+	}
+	p.book.apply(side, price, strconv.FormatFloat(updated, 'f', -1, 64))
+}
+
+// L3Snapshot returns symbol's current per-order book as ordered
+// (orderID, price, size, side, ts) tuples - best price first on each
+// side, ties broken by arrival order. It returns nil unless the provider
+// was built WithL3(true).
+func (p *CoinbaseFullChannelProvider) L3Snapshot(symbol string) []CoinbaseL3Level {
+	if !p.keepL3 {
+		return nil
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	out := make([]CoinbaseL3Level, 0, len(p.orders))
+	for orderID, order := range p.orders {
+		if order.symbol != symbol {
+			continue
+		}
+		out = append(out, CoinbaseL3Level{
+			OrderID: orderID,
+			Price:   order.price,
+			Size:    order.size,
+			Side:    order.side,
+			Time:    order.ts,
+		})
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Side != out[j].Side {
+			return out[i].Side < out[j].Side
+		}
+		priceI, _ := strconv.ParseFloat(out[i].Price, 64)
+		priceJ, _ := strconv.ParseFloat(out[j].Price, 64)
+		if priceI != priceJ {
+			if out[i].Side == orderbookSideBid {
+				return priceI > priceJ
+			}
+			return priceI < priceJ
+		}
+		return out[i].Time < out[j].Time
+	})
+	return out
+}
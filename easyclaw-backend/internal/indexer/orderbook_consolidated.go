@@ -0,0 +1,329 @@
+package indexer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defaultConsolidatedBucketSec and defaultConsolidatedDepthLevels match
+// ListOrderbookHeatmapAggregated's "no filter means show something
+// reasonable" convention rather than erroring on an unset field.
+const (
+	defaultConsolidatedBucketSec   = 1
+	defaultConsolidatedDepthLevels = 5
+)
+
+// OrderbookConsolidatedFilter selects the time-bucketed cross-venue view
+// ListOrderbookConsolidated builds. BucketSec aligns snapshots from
+// different exchanges (which rarely tick at the same instant) onto a
+// shared time grid; DepthBps and ImbalanceLevels tune the two derived
+// depth metrics below.
+type OrderbookConsolidatedFilter struct {
+	SymbolKey       string
+	FromUnix        int64
+	ToUnix          int64
+	BucketSec       int64
+	DepthBps        float64
+	ImbalanceLevels int
+	Limit           int
+	Offset          int
+}
+
+// OrderbookConsolidatedLevel is one merged price level: Quantity is the
+// sum across every exchange quoting at Price, and Exchanges lists which
+// of them contributed.
+type OrderbookConsolidatedLevel struct {
+	Side      string   `json:"side"`
+	Price     string   `json:"price"`
+	Quantity  string   `json:"quantity"`
+	Exchanges []string `json:"exchanges"`
+}
+
+// OrderbookConsolidatedBucket is one aligned time bucket's merged book
+// across every exchange quoting SymbolKey, plus the derived metrics a
+// cross-venue strategy would otherwise have to recompute from the raw
+// ladder on every read.
+type OrderbookConsolidatedBucket struct {
+	BucketTime        int64                        `json:"bucket_time"`
+	SymbolKey         string                       `json:"symbol_key"`
+	Bids              []OrderbookConsolidatedLevel `json:"bids"`
+	Asks              []OrderbookConsolidatedLevel `json:"asks"`
+	BestBid           string                       `json:"best_bid"`
+	BestAsk           string                       `json:"best_ask"`
+	SpreadBps         float64                      `json:"spread_bps"`
+	WeightedMid       float64                      `json:"weighted_mid"`
+	DepthBidWithinBps float64                      `json:"depth_bid_within_bps"`
+	DepthAskWithinBps float64                      `json:"depth_ask_within_bps"`
+	ImbalanceRatio    float64                      `json:"imbalance_ratio"`
+}
+
+type venueBucketKey struct {
+	bucketTime int64
+	exchange   string
+}
+
+// ListOrderbookConsolidated merges per-exchange snapshots for the same
+// normalized symbol onto aligned time buckets (see
+// normalizeOrderbookSymbolKey, the same symbol-matching
+// ListOrderbookHeatmapAggregated already uses), treating fragmented perp
+// venues as one book the way a cross-exchange strategy would. For each
+// (exchange, bucket) pair it keeps only the latest snapshot observed in
+// that bucket, so a venue ticking faster than bucketSec doesn't get
+// double-counted against a slower one.
+func (s *Store) ListOrderbookConsolidated(
+	ctx context.Context,
+	filter OrderbookConsolidatedFilter,
+) ([]OrderbookConsolidatedBucket, int, int, error) {
+	limit, offset := normalizePagination(filter.Limit, filter.Offset)
+	bucketSec := filter.BucketSec
+	if bucketSec <= 0 {
+		bucketSec = defaultConsolidatedBucketSec
+	}
+	depthLevels := filter.ImbalanceLevels
+	if depthLevels <= 0 {
+		depthLevels = defaultConsolidatedDepthLevels
+	}
+	symbolKey := normalizeOrderbookSymbolKey(filter.SymbolKey)
+
+	clauses := []string{"1 = 1"}
+	args := make([]any, 0, 4)
+	if filter.FromUnix > 0 {
+		clauses = append(clauses, "snapshot_time >= ?")
+		args = append(args, filter.FromUnix)
+	}
+	if filter.ToUnix > 0 {
+		clauses = append(clauses, "snapshot_time <= ?")
+		args = append(args, filter.ToUnix)
+	}
+
+	rows, err := s.db.QueryContext(
+		ctx,
+		`
+		SELECT exchange, symbol, snapshot_time, levels_json
+		FROM exchange_orderbook_snapshots
+		WHERE `+strings.Join(clauses, " AND ")+`
+		ORDER BY snapshot_time ASC, id ASC
+		`,
+		args...,
+	)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	defer rows.Close()
+
+	type venueSnapshot struct {
+		snapshotTime int64
+		levels       []OrderbookHeatmapLevel
+	}
+	latestByVenueBucket := make(map[venueBucketKey]venueSnapshot)
+
+	for rows.Next() {
+		var exchange string
+		var symbol string
+		var snapshotTime int64
+		var levelsJSON string
+		if err := rows.Scan(&exchange, &symbol, &snapshotTime, &levelsJSON); err != nil {
+			return nil, 0, 0, err
+		}
+		if symbolKey != "" && normalizeOrderbookSymbolKey(symbol) != symbolKey {
+			continue
+		}
+		if strings.TrimSpace(levelsJSON) == "" {
+			continue
+		}
+
+		var levels []OrderbookHeatmapLevel
+		if err := json.Unmarshal([]byte(levelsJSON), &levels); err != nil {
+			return nil, 0, 0, fmt.Errorf("decode levels_json for %s:%s@%d: %w", exchange, symbol, snapshotTime, err)
+		}
+
+		bucketTime := snapshotTime - (snapshotTime % bucketSec)
+		key := venueBucketKey{bucketTime: bucketTime, exchange: exchange}
+		if existing, ok := latestByVenueBucket[key]; !ok || snapshotTime > existing.snapshotTime {
+			latestByVenueBucket[key] = venueSnapshot{snapshotTime: snapshotTime, levels: levels}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, 0, err
+	}
+
+	levelsByBucket := make(map[int64][]OrderbookLevel, 64)
+	for key, snapshot := range latestByVenueBucket {
+		for _, level := range snapshot.levels {
+			levelsByBucket[key.bucketTime] = append(levelsByBucket[key.bucketTime], OrderbookLevel{
+				Side:     level.Side,
+				Price:    level.Price,
+				Quantity: level.Quantity,
+				Venue:    key.exchange,
+			})
+		}
+	}
+
+	bucketTimes := make([]int64, 0, len(levelsByBucket))
+	for bucketTime := range levelsByBucket {
+		bucketTimes = append(bucketTimes, bucketTime)
+	}
+	sort.Slice(bucketTimes, func(i, j int) bool { return bucketTimes[i] < bucketTimes[j] })
+
+	start := offset
+	if start > len(bucketTimes) {
+		start = len(bucketTimes)
+	}
+	end := start + limit
+	if end > len(bucketTimes) {
+		end = len(bucketTimes)
+	}
+
+	buckets := make([]OrderbookConsolidatedBucket, 0, end-start)
+	for _, bucketTime := range bucketTimes[start:end] {
+		buckets = append(buckets, buildConsolidatedBucket(bucketTime, symbolKey, levelsByBucket[bucketTime], filter.DepthBps, depthLevels))
+	}
+
+	return buckets, limit, offset, nil
+}
+
+func buildConsolidatedBucket(bucketTime int64, symbolKey string, levels []OrderbookLevel, depthBps float64, depthLevels int) OrderbookConsolidatedBucket {
+	merged := aggregateConsolidatedLevels(levels)
+
+	bucket := OrderbookConsolidatedBucket{BucketTime: bucketTime, SymbolKey: symbolKey}
+	for _, level := range merged {
+		if level.Side == orderbookSideBid {
+			bucket.Bids = append(bucket.Bids, level)
+		} else {
+			bucket.Asks = append(bucket.Asks, level)
+		}
+	}
+	// aggregateConsolidatedLevels sorts bids ascending by price; the best
+	// bid is the highest price, so it's the tail of the slice, not the
+	// head (asks are already ascending-from-best, so the head works).
+	sort.Slice(bucket.Bids, func(i, j int) bool {
+		return parsePriceOrZero(bucket.Bids[i].Price) > parsePriceOrZero(bucket.Bids[j].Price)
+	})
+	sort.Slice(bucket.Asks, func(i, j int) bool {
+		return parsePriceOrZero(bucket.Asks[i].Price) < parsePriceOrZero(bucket.Asks[j].Price)
+	})
+
+	if len(bucket.Bids) == 0 || len(bucket.Asks) == 0 {
+		return bucket
+	}
+
+	bestBidPrice := parsePriceOrZero(bucket.Bids[0].Price)
+	bestAskPrice := parsePriceOrZero(bucket.Asks[0].Price)
+	bestBidQty := parsePriceOrZero(bucket.Bids[0].Quantity)
+	bestAskQty := parsePriceOrZero(bucket.Asks[0].Quantity)
+	bucket.BestBid = bucket.Bids[0].Price
+	bucket.BestAsk = bucket.Asks[0].Price
+
+	mid := (bestBidPrice + bestAskPrice) / 2
+	if mid > 0 {
+		bucket.SpreadBps = (bestAskPrice - bestBidPrice) / mid * 10000
+	}
+	if bestBidQty+bestAskQty > 0 {
+		// Microprice: weights the mid toward whichever side is thinner,
+		// since a small resting quantity is easier to trade through.
+		bucket.WeightedMid = (bestBidPrice*bestAskQty + bestAskPrice*bestBidQty) / (bestBidQty + bestAskQty)
+	}
+
+	if depthBps > 0 && mid > 0 {
+		bidFloor := mid * (1 - depthBps/10000)
+		askCeil := mid * (1 + depthBps/10000)
+		for _, level := range bucket.Bids {
+			if parsePriceOrZero(level.Price) >= bidFloor {
+				bucket.DepthBidWithinBps += parsePriceOrZero(level.Quantity)
+			}
+		}
+		for _, level := range bucket.Asks {
+			if parsePriceOrZero(level.Price) <= askCeil {
+				bucket.DepthAskWithinBps += parsePriceOrZero(level.Quantity)
+			}
+		}
+	}
+
+	bidDepth, askDepth := 0.0, 0.0
+	for i := 0; i < depthLevels && i < len(bucket.Bids); i++ {
+		bidDepth += parsePriceOrZero(bucket.Bids[i].Quantity)
+	}
+	for i := 0; i < depthLevels && i < len(bucket.Asks); i++ {
+		askDepth += parsePriceOrZero(bucket.Asks[i].Quantity)
+	}
+	if bidDepth+askDepth > 0 {
+		bucket.ImbalanceRatio = (bidDepth - askDepth) / (bidDepth + askDepth)
+	}
+
+	return bucket
+}
+
+// aggregateConsolidatedLevels is aggregateOrderbookLevels's cross-venue
+// counterpart: it sums quantity by (side, price) the same way, but also
+// tracks which exchanges contributed to each merged level.
+func aggregateConsolidatedLevels(levels []OrderbookLevel) []OrderbookConsolidatedLevel {
+	type levelKey struct {
+		side  string
+		price string
+	}
+
+	quantityByKey := make(map[levelKey]float64, len(levels))
+	venuesByKey := make(map[levelKey]map[string]bool, len(levels))
+
+	for _, level := range levels {
+		side := strings.ToLower(strings.TrimSpace(level.Side))
+		if side != orderbookSideBid && side != orderbookSideAsk {
+			continue
+		}
+		price := strings.TrimSpace(level.Price)
+		quantity, err := strconv.ParseFloat(strings.TrimSpace(level.Quantity), 64)
+		if err != nil || quantity <= 0 || price == "" {
+			continue
+		}
+
+		key := levelKey{side: side, price: price}
+		quantityByKey[key] += quantity
+		if venuesByKey[key] == nil {
+			venuesByKey[key] = make(map[string]bool, 4)
+		}
+		if level.Venue != "" {
+			venuesByKey[key][level.Venue] = true
+		}
+	}
+
+	out := make([]OrderbookConsolidatedLevel, 0, len(quantityByKey))
+	for key, quantity := range quantityByKey {
+		exchanges := make([]string, 0, len(venuesByKey[key]))
+		for exchange := range venuesByKey[key] {
+			exchanges = append(exchanges, exchange)
+		}
+		sort.Strings(exchanges)
+		out = append(out, OrderbookConsolidatedLevel{
+			Side:      key.side,
+			Price:     key.price,
+			Quantity:  strconv.FormatFloat(quantity, 'f', -1, 64),
+			Exchanges: exchanges,
+		})
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Side != out[j].Side {
+			return out[i].Side < out[j].Side
+		}
+		leftPrice, leftErr := strconv.ParseFloat(out[i].Price, 64)
+		rightPrice, rightErr := strconv.ParseFloat(out[j].Price, 64)
+		if leftErr == nil && rightErr == nil && leftPrice != rightPrice {
+			return leftPrice < rightPrice
+		}
+		return out[i].Price < out[j].Price
+	})
+
+	return out
+}
+
+func parsePriceOrZero(raw string) float64 {
+	value, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+	if err != nil {
+		return 0
+	}
+	return value
+}
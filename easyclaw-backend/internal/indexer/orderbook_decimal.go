@@ -0,0 +1,406 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// FixedPoint is an allocation-free decimal: its value is Mantissa *
+// 10^-Scale. Orderbook levels use it in place of the venue wire format's
+// price/quantity strings so VWAP, spread, and matching code stop
+// reparsing the same string on every read.
+type FixedPoint struct {
+	Mantissa int64
+	Scale    int8
+}
+
+// Float64 converts f to a float64. Downstream consumers that only need a
+// display value or a rough comparison can use this instead of threading
+// FixedPoint arithmetic through, at the usual float precision cost.
+func (f FixedPoint) Float64() float64 {
+	if f.Scale == 0 {
+		return float64(f.Mantissa)
+	}
+	return float64(f.Mantissa) / float64(pow10Int(f.Scale))
+}
+
+// ParseFixedPoint parses raw (a venue's decimal string) into a FixedPoint
+// at the given scale, truncating extra fractional digits and zero-padding
+// missing ones so every level on a symbol shares one scale and can be
+// compared or summed without reconverting.
+func ParseFixedPoint(raw string, scale int8) (FixedPoint, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return FixedPoint{}, fmt.Errorf("empty decimal")
+	}
+
+	neg := false
+	switch raw[0] {
+	case '-':
+		neg = true
+		raw = raw[1:]
+	case '+':
+		raw = raw[1:]
+	}
+
+	intPart, fracPart, hasFrac := strings.Cut(raw, ".")
+	if !hasFrac {
+		fracPart = ""
+	}
+	if len(fracPart) > int(scale) {
+		fracPart = fracPart[:scale]
+	} else {
+		fracPart += strings.Repeat("0", int(scale)-len(fracPart))
+	}
+
+	digits := intPart + fracPart
+	if digits == "" {
+		digits = "0"
+	}
+	mantissa, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return FixedPoint{}, fmt.Errorf("parse decimal %q: %w", raw, err)
+	}
+	if neg {
+		mantissa = -mantissa
+	}
+	return FixedPoint{Mantissa: mantissa, Scale: scale}, nil
+}
+
+// rescale converts f to scale, truncating or zero-extending the mantissa
+// as needed so two FixedPoints can be compared or added once on a common
+// scale.
+func rescale(f FixedPoint, scale int8) FixedPoint {
+	if f.Scale == scale {
+		return f
+	}
+	if scale > f.Scale {
+		return FixedPoint{Mantissa: f.Mantissa * pow10Int(scale-f.Scale), Scale: scale}
+	}
+	return FixedPoint{Mantissa: f.Mantissa / pow10Int(f.Scale-scale), Scale: scale}
+}
+
+func pow10Int(n int8) int64 {
+	result := int64(1)
+	for i := int8(0); i < n; i++ {
+		result *= 10
+	}
+	return result
+}
+
+// inferScale returns the number of fractional digits in raw, used as a
+// fallback scale for venues whose per-symbol instrument metadata isn't
+// wired up yet (see resolveInstrumentScale).
+func inferScale(raw string) int8 {
+	_, frac, ok := strings.Cut(strings.TrimSpace(raw), ".")
+	if !ok {
+		return 0
+	}
+	if len(frac) > 18 {
+		frac = frac[:18]
+	}
+	return int8(len(frac))
+}
+
+// significantScale is inferScale over a decimal string's canonical form,
+// so a tick/step size like "0.00010000" reports scale 4 rather than 8.
+func significantScale(raw string) int8 {
+	value, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+	if err != nil {
+		return inferScale(raw)
+	}
+	return inferScale(strconv.FormatFloat(value, 'f', -1, 64))
+}
+
+// InstrumentScale is the fixed-point precision a venue uses for a given
+// symbol's price and quantity, as published by its instrument-info
+// endpoint (e.g. Binance exchangeInfo's tick/step sizes).
+type InstrumentScale struct {
+	PriceScale int8
+	QtyScale   int8
+}
+
+// instrumentScaleCache memoizes InstrumentScale per exchange+symbol so
+// FetchOrderbookDecimal and StreamOrderbookDecimal don't rediscover
+// instrument metadata on every snapshot.
+type instrumentScaleCache struct {
+	mu     sync.RWMutex
+	scales map[string]InstrumentScale
+}
+
+func newInstrumentScaleCache() *instrumentScaleCache {
+	return &instrumentScaleCache{scales: make(map[string]InstrumentScale)}
+}
+
+func instrumentScaleCacheKey(exchange, symbol string) string {
+	return strings.ToLower(exchange) + ":" + strings.ToUpper(symbol)
+}
+
+func (c *instrumentScaleCache) get(exchange, symbol string) (InstrumentScale, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	scale, ok := c.scales[instrumentScaleCacheKey(exchange, symbol)]
+	return scale, ok
+}
+
+func (c *instrumentScaleCache) set(exchange, symbol string, scale InstrumentScale) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.scales[instrumentScaleCacheKey(exchange, symbol)] = scale
+}
+
+// globalInstrumentScaleCache backs resolveInstrumentScale for every
+// provider; one process only ever trades a bounded set of symbols, so a
+// single process-wide cache (rather than one per provider) is simplest.
+var globalInstrumentScaleCache = newInstrumentScaleCache()
+
+// resolveInstrumentScale returns exchange+symbol's cached InstrumentScale,
+// discovering one from the venue's instrument-info endpoint when a
+// provider-specific lookup exists (today just Binance's exchangeInfo),
+// and otherwise inferring it from a sample snapshot's own decimal
+// strings. Either way the result is cached so later calls are free.
+func resolveInstrumentScale(ctx context.Context, client *http.Client, exchange, symbol string, sampleBids, sampleAsks []OrderbookLevel) InstrumentScale {
+	if scale, ok := globalInstrumentScaleCache.get(exchange, symbol); ok {
+		return scale
+	}
+
+	if strings.EqualFold(exchange, "binance") {
+		if scale, err := fetchBinanceInstrumentScale(ctx, client, symbol); err == nil {
+			globalInstrumentScaleCache.set(exchange, symbol, scale)
+			return scale
+		}
+	}
+
+	scale := inferInstrumentScale(sampleBids, sampleAsks)
+	globalInstrumentScaleCache.set(exchange, symbol, scale)
+	return scale
+}
+
+func inferInstrumentScale(bids, asks []OrderbookLevel) InstrumentScale {
+	var scale InstrumentScale
+	for _, sides := range [2][]OrderbookLevel{bids, asks} {
+		for _, level := range sides {
+			if s := inferScale(level.Price); s > scale.PriceScale {
+				scale.PriceScale = s
+			}
+			if s := inferScale(level.Quantity); s > scale.QtyScale {
+				scale.QtyScale = s
+			}
+		}
+	}
+	return scale
+}
+
+const binanceExchangeInfoEndpoint = "https://api.binance.com/api/v3/exchangeInfo"
+
+// fetchBinanceInstrumentScale looks up symbol's PRICE_FILTER.tickSize and
+// LOT_SIZE.stepSize via Binance's exchangeInfo endpoint and converts them
+// to decimal places.
+func fetchBinanceInstrumentScale(ctx context.Context, client *http.Client, symbol string) (InstrumentScale, error) {
+	endpoint := fmt.Sprintf("%s?symbol=%s", binanceExchangeInfoEndpoint, url.QueryEscape(symbol))
+	payload, _, err := fetchJSON(ctx, client, nil, "binance", "instrument-info", endpoint)
+	if err != nil {
+		return InstrumentScale{}, err
+	}
+
+	symbolsRaw, ok := payload["symbols"].([]any)
+	if !ok || len(symbolsRaw) == 0 {
+		return InstrumentScale{}, fmt.Errorf("binance exchangeInfo response missing symbols for %s", symbol)
+	}
+	symbolObj, ok := symbolsRaw[0].(map[string]any)
+	if !ok {
+		return InstrumentScale{}, fmt.Errorf("binance exchangeInfo symbol entry malformed for %s", symbol)
+	}
+	filtersRaw, ok := symbolObj["filters"].([]any)
+	if !ok {
+		return InstrumentScale{}, fmt.Errorf("binance exchangeInfo response missing filters for %s", symbol)
+	}
+
+	var scale InstrumentScale
+	for _, filterRaw := range filtersRaw {
+		filter, ok := filterRaw.(map[string]any)
+		if !ok {
+			continue
+		}
+		switch asString(filter["filterType"]) {
+		case "PRICE_FILTER":
+			scale.PriceScale = significantScale(asString(filter["tickSize"]))
+		case "LOT_SIZE":
+			scale.QtyScale = significantScale(asString(filter["stepSize"]))
+		}
+	}
+	return scale, nil
+}
+
+// OrderbookLevelDecimal is OrderbookLevel's allocation-free counterpart:
+// price and quantity as FixedPoint, at the symbol's InstrumentScale,
+// instead of strings.
+type OrderbookLevelDecimal struct {
+	Level    int
+	Side     string
+	Price    FixedPoint
+	Quantity FixedPoint
+	Venue    string
+}
+
+// decimalLevelsFromString converts string-based levels to
+// OrderbookLevelDecimal at the given scales, skipping any level whose
+// price or quantity fails to parse.
+func decimalLevelsFromString(levels []OrderbookLevel, priceScale, qtyScale int8) []OrderbookLevelDecimal {
+	out := make([]OrderbookLevelDecimal, 0, len(levels))
+	for _, level := range levels {
+		price, err := ParseFixedPoint(level.Price, priceScale)
+		if err != nil {
+			continue
+		}
+		quantity, err := ParseFixedPoint(level.Quantity, qtyScale)
+		if err != nil {
+			continue
+		}
+		out = append(out, OrderbookLevelDecimal{
+			Level:    level.Level,
+			Side:     level.Side,
+			Price:    price,
+			Quantity: quantity,
+			Venue:    level.Venue,
+		})
+	}
+	return out
+}
+
+// FetchOrderbookDecimal fetches provider's snapshot through its existing
+// string-based FetchOrderbook and converts it to OrderbookLevelDecimal at
+// symbol's cached InstrumentScale, the same conversion the string API's
+// callers (VWAP, spread, matching) would otherwise each do themselves.
+func FetchOrderbookDecimal(
+	ctx context.Context,
+	client *http.Client,
+	provider orderbookSnapshotProvider,
+	symbol string,
+	depth int,
+) ([]OrderbookLevelDecimal, []OrderbookLevelDecimal, int64, error) {
+	bids, asks, exchangeTS, _, err := provider.FetchOrderbook(ctx, symbol, depth)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	scale := resolveInstrumentScale(ctx, client, provider.Name(), symbol, bids, asks)
+	return decimalLevelsFromString(bids, scale.PriceScale, scale.QtyScale),
+		decimalLevelsFromString(asks, scale.PriceScale, scale.QtyScale),
+		exchangeTS, nil
+}
+
+// orderbookStreamHandlerDecimal is orderbookStreamHandler's decimal
+// counterpart.
+type orderbookStreamHandlerDecimal func(bids, asks []OrderbookLevelDecimal, exchangeTS int64, rawJSON string)
+
+// StreamOrderbookDecimal wraps a streaming provider's existing
+// string-based StreamOrderbook, converting each callback's levels to
+// OrderbookLevelDecimal at symbol's cached InstrumentScale before handing
+// them to handler. Venues don't need a second StreamOrderbook
+// implementation just to serve decimal-mode consumers.
+func StreamOrderbookDecimal(
+	ctx context.Context,
+	client *http.Client,
+	provider orderbookStreamingProvider,
+	symbol string,
+	depth int,
+	handler orderbookStreamHandlerDecimal,
+) error {
+	return provider.StreamOrderbook(ctx, symbol, depth, func(bids, asks []OrderbookLevel, exchangeTS int64, rawJSON string) {
+		scale := resolveInstrumentScale(ctx, client, provider.Name(), symbol, bids, asks)
+		handler(
+			decimalLevelsFromString(bids, scale.PriceScale, scale.QtyScale),
+			decimalLevelsFromString(asks, scale.PriceScale, scale.QtyScale),
+			exchangeTS, rawJSON,
+		)
+	})
+}
+
+// MidPrice returns the midpoint between the best bid and best ask, or
+// (FixedPoint{}, false) if either side is empty. bids and asks must
+// already be sorted best-first, as topLevels returns them.
+func MidPrice(bids, asks []OrderbookLevelDecimal) (FixedPoint, bool) {
+	if len(bids) == 0 || len(asks) == 0 {
+		return FixedPoint{}, false
+	}
+
+	scale := bids[0].Price.Scale
+	if asks[0].Price.Scale > scale {
+		scale = asks[0].Price.Scale
+	}
+	bid := rescale(bids[0].Price, scale)
+	ask := rescale(asks[0].Price, scale)
+	return FixedPoint{Mantissa: (bid.Mantissa + ask.Mantissa) / 2, Scale: scale}, true
+}
+
+// SpreadBps returns the best bid/ask spread in basis points of the
+// midpoint, or (0, false) if either side is empty.
+func SpreadBps(bids, asks []OrderbookLevelDecimal) (float64, bool) {
+	if len(bids) == 0 || len(asks) == 0 {
+		return 0, false
+	}
+
+	bid := bids[0].Price.Float64()
+	ask := asks[0].Price.Float64()
+	mid := (bid + ask) / 2
+	if mid == 0 {
+		return 0, false
+	}
+	return (ask - bid) / mid * 10000, true
+}
+
+// DepthWithin sums the bid and ask quantity within bps basis points of the
+// midpoint, a common liquidity-depth metric. bids and asks must already
+// be sorted best-first, as topLevels returns them.
+func DepthWithin(bids, asks []OrderbookLevelDecimal, bps float64) float64 {
+	mid, ok := MidPrice(bids, asks)
+	if !ok {
+		return 0
+	}
+	midFloat := mid.Float64()
+	if midFloat == 0 {
+		return 0
+	}
+	threshold := midFloat * bps / 10000
+
+	var total float64
+	for _, level := range bids {
+		if midFloat-level.Price.Float64() > threshold {
+			break
+		}
+		total += level.Quantity.Float64()
+	}
+	for _, level := range asks {
+		if level.Price.Float64()-midFloat > threshold {
+			break
+		}
+		total += level.Quantity.Float64()
+	}
+	return total
+}
+
+// VWAP returns the volume-weighted average price to fill qty against
+// levels (pass asks to price a buy, bids to price a sell), and false if
+// levels don't hold enough quantity to fill qty. levels must already be
+// sorted best-first, as topLevels returns them.
+func VWAP(levels []OrderbookLevelDecimal, qty float64) (float64, bool) {
+	var filled, notional float64
+	for _, level := range levels {
+		take := level.Quantity.Float64()
+		if filled+take > qty {
+			take = qty - filled
+		}
+		notional += take * level.Price.Float64()
+		filled += take
+		if filled >= qty {
+			return notional / qty, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,263 @@
+package indexer
+
+import (
+	"context"
+	"sync"
+)
+
+// OrderbookDelta is one per-price-level update from an L2 depth stream: a
+// zero Qty means the level was removed, the same convention levelBook.apply
+// already uses for live streaming books. Persisting deltas instead of a
+// full levels_json row per tick is what lets high-frequency venues avoid
+// storing the whole ladder on every update; exchange_orderbook_snapshots
+// still holds the periodic compacted rows MaterializeOrderbookSnapshot
+// writes.
+type OrderbookDelta struct {
+	Exchange string
+	Symbol   string
+	Seq      int64
+	PrevSeq  int64
+	Side     string
+	Price    string
+	Qty      string
+	Ts       int64
+}
+
+// ApplyOrderbookDelta persists one delta row within tx. It does not apply
+// the delta to any in-memory book itself - callers that need a canonical
+// running book (to materialize a compacted snapshot, or to detect a gap
+// before it's written) go through an orderbookDeltaBook, which wraps the
+// same levelBook the live streaming collectors use.
+func (s *Store) ApplyOrderbookDelta(ctx context.Context, tx *Tx, delta OrderbookDelta) error {
+	_, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO exchange_orderbook_deltas (exchange, symbol, seq, prev_seq, side, price, qty, ts)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		 ON CONFLICT (exchange, symbol, seq) DO NOTHING`,
+		delta.Exchange, delta.Symbol, delta.Seq, delta.PrevSeq, delta.Side, delta.Price, delta.Qty, delta.Ts,
+	)
+	return err
+}
+
+// OrderbookResyncFunc is called by orderbookDeltaBook.Apply when a
+// sequence gap leaves the running book unable to trust its state - the
+// same situation a live streaming provider handles by forcing a fresh
+// REST bootstrap (see runDepthStream); here it's left to the caller,
+// since recovering means re-fetching a snapshot from whatever venue
+// connection owns this (exchange, symbol), which this package's Store
+// layer has no handle on.
+type OrderbookResyncFunc func(exchange, symbol string)
+
+// orderbookDeltaBook is the canonical in-memory book one (exchange,
+// symbol) delta consumer folds updates into between materialized
+// snapshots, reusing levelBook (the same structure the live depth-stream
+// collectors maintain) plus the running sequence number needed to
+// detect a gap the way seqDepthContinuity does for a streaming
+// connection.
+type orderbookDeltaBook struct {
+	mu       sync.Mutex
+	book     *levelBook
+	lastSeq  int64
+	onGap    OrderbookResyncFunc
+	exchange string
+	symbol   string
+}
+
+func newOrderbookDeltaBook(exchange, symbol string, onGap OrderbookResyncFunc) *orderbookDeltaBook {
+	return &orderbookDeltaBook{
+		book:     newLevelBook(),
+		exchange: exchange,
+		symbol:   symbol,
+		onGap:    onGap,
+	}
+}
+
+// Reset seeds the book from a freshly fetched or loaded snapshot,
+// establishing the sequence number subsequent deltas are checked
+// against.
+func (d *orderbookDeltaBook) Reset(bids, asks []OrderbookLevel, seq int64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.book.resetFromLevels(bids, asks)
+	d.lastSeq = seq
+}
+
+// Apply folds delta into the running book if it bridges cleanly from the
+// last applied sequence number. A gap (delta.PrevSeq not matching the
+// book's current sequence) leaves the book untouched and invokes onGap so
+// the caller can trigger a resync instead of serving a book that silently
+// missed an update.
+func (d *orderbookDeltaBook) Apply(delta OrderbookDelta) (gap bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.lastSeq != 0 && delta.PrevSeq != 0 && delta.PrevSeq != d.lastSeq {
+		if d.onGap != nil {
+			d.onGap(d.exchange, d.symbol)
+		}
+		return true
+	}
+
+	d.book.apply(delta.Side, delta.Price, delta.Qty)
+	d.lastSeq = delta.Seq
+	return false
+}
+
+// Snapshot returns the book's current top levels alongside the sequence
+// number they're valid through, for MaterializeOrderbookSnapshot to
+// persist as a compacted row.
+func (d *orderbookDeltaBook) Snapshot(depth int) (bids, asks []OrderbookLevel, seq int64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	bids, asks = d.book.topLevels(depth)
+	return bids, asks, d.lastSeq
+}
+
+// MaterializeOrderbookSnapshot compacts book's current state into a full
+// exchange_orderbook_snapshots row and prunes the delta rows it now
+// supersedes (everything at or before throughSeq), so storage doesn't
+// grow unbounded between compactions. It mirrors
+// RecomputeAgentLots/ReplayAgentMarket's on-demand, explicitly-invoked
+// pattern rather than running as an implicit background loop, since this
+// package has no ticker-driven scheduler for per-market maintenance work
+// today.
+func (s *Store) MaterializeOrderbookSnapshot(ctx context.Context, exchange, symbol string, book *orderbookDeltaBook, snapshotTime int64, depth int) (int64, error) {
+	bids, asks, seq := book.Snapshot(depth)
+	levels := make([]OrderbookLevel, 0, len(bids)+len(asks))
+	for _, level := range bids {
+		level.Side = orderbookSideBid
+		levels = append(levels, level)
+	}
+	for _, level := range asks {
+		level.Side = orderbookSideAsk
+		levels = append(levels, level)
+	}
+
+	snapshot := OrderbookSnapshot{
+		Exchange:     exchange,
+		Symbol:       symbol,
+		SnapshotTime: snapshotTime,
+		Levels:       levels,
+	}
+	if len(bids) > 0 {
+		snapshot.BestBid = bids[0].Price
+	}
+	if len(asks) > 0 {
+		snapshot.BestAsk = asks[0].Price
+	}
+
+	var snapshotID int64
+	err := s.WithTx(ctx, func(tx *Tx) error {
+		var err error
+		snapshotID, err = s.UpsertOrderbookSnapshotTx(ctx, tx, snapshot)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(
+			ctx,
+			`DELETE FROM exchange_orderbook_deltas WHERE exchange = ? AND symbol = ? AND seq <= ?`,
+			exchange, symbol, seq,
+		)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return snapshotID, nil
+}
+
+// ReplayOrderbookAt reconstructs the book for (exchange, symbol) as of
+// atUnix by loading the latest snapshot at or before atUnix and replaying
+// every delta recorded after it up to atUnix, in sequence order. This is
+// what lets a heatmap query any point in time without exchange_orderbook_snapshots
+// having a row for that exact instant.
+func (s *Store) ReplayOrderbookAt(ctx context.Context, exchange, symbol string, atUnix int64, depth int) (OrderbookSnapshot, error) {
+	snapshots, _, _, _, err := s.ListOrderbookHeatmap(ctx, OrderbookHeatmapFilter{
+		Exchange: exchange,
+		Symbol:   symbol,
+		ToUnix:   atUnix,
+		Limit:    1,
+	})
+	if err != nil {
+		return OrderbookSnapshot{}, err
+	}
+
+	book := newLevelBook()
+	baseTime := int64(0)
+	if len(snapshots) > 0 {
+		base := snapshots[0]
+		bids := make([]OrderbookLevel, 0, len(base.Levels))
+		asks := make([]OrderbookLevel, 0, len(base.Levels))
+		for _, level := range base.Levels {
+			if level.Side == orderbookSideBid {
+				bids = append(bids, OrderbookLevel{Price: level.Price, Quantity: level.Quantity})
+			} else {
+				asks = append(asks, OrderbookLevel{Price: level.Price, Quantity: level.Quantity})
+			}
+		}
+		book.resetFromLevels(bids, asks)
+		baseTime = base.SnapshotTime
+	}
+
+	deltas, err := s.listOrderbookDeltas(ctx, exchange, symbol, baseTime, atUnix)
+	if err != nil {
+		return OrderbookSnapshot{}, err
+	}
+	for _, delta := range deltas {
+		book.apply(delta.Side, delta.Price, delta.Qty)
+	}
+
+	bids, asks := book.topLevels(depth)
+	snapshot := OrderbookSnapshot{
+		Exchange:     exchange,
+		Symbol:       symbol,
+		SnapshotTime: atUnix,
+		Levels:       make([]OrderbookLevel, 0, len(bids)+len(asks)),
+	}
+	for i := range bids {
+		bids[i].Side = orderbookSideBid
+		snapshot.Levels = append(snapshot.Levels, bids[i])
+	}
+	for i := range asks {
+		asks[i].Side = orderbookSideAsk
+		snapshot.Levels = append(snapshot.Levels, asks[i])
+	}
+	if len(bids) > 0 {
+		snapshot.BestBid = bids[0].Price
+	}
+	if len(asks) > 0 {
+		snapshot.BestAsk = asks[0].Price
+	}
+
+	return snapshot, nil
+}
+
+func (s *Store) listOrderbookDeltas(ctx context.Context, exchange, symbol string, fromUnix, toUnix int64) ([]OrderbookDelta, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT seq, prev_seq, side, price, qty, ts
+		 FROM exchange_orderbook_deltas
+		 WHERE exchange = ? AND symbol = ? AND ts > ? AND ts <= ?
+		 ORDER BY seq ASC`,
+		exchange, symbol, fromUnix, toUnix,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deltas := make([]OrderbookDelta, 0, 256)
+	for rows.Next() {
+		var delta OrderbookDelta
+		delta.Exchange = exchange
+		delta.Symbol = symbol
+		if err := rows.Scan(&delta.Seq, &delta.PrevSeq, &delta.Side, &delta.Price, &delta.Qty, &delta.Ts); err != nil {
+			return nil, err
+		}
+		deltas = append(deltas, delta)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return deltas, nil
+}
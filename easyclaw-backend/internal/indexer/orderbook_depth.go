@@ -0,0 +1,168 @@
+package indexer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const defaultOrderbookDepthLevels = 20
+
+// OrderbookDepthFilter selects the L2 depth snapshot GetOrderbookDepth
+// returns for one (Exchange, Symbol) pair. Side restricts the response to
+// just "bid" or "ask"; left empty, both sides are returned (the combined
+// variant /api/v1/orderbook/depth serves when no side query param is set).
+type OrderbookDepthFilter struct {
+	Exchange string
+	Symbol   string
+	Side     string
+	Levels   int
+}
+
+// OrderbookDepthLevel is one aggregated price level: Quantity sums every
+// ladder entry this snapshot's levels_json held at Price, and OrderCount is
+// how many of those entries were merged - an exchange-reported-level-count
+// proxy, not a true resting-order count, since the snapshot schema only
+// ever stored per-price aggregated quantity, never individual orders.
+type OrderbookDepthLevel struct {
+	Price      string `json:"price"`
+	Quantity   string `json:"quantity"`
+	OrderCount int    `json:"order_count"`
+}
+
+// OrderbookDepth is a classical L2 snapshot: SnapshotTime is the monotonic
+// field clients reconcile against, the same role snapshot_time already
+// plays for OrderbookHeatmapRecord.
+type OrderbookDepth struct {
+	Exchange     string                `json:"exchange"`
+	Symbol       string                `json:"symbol"`
+	SnapshotTime int64                 `json:"snapshot_time"`
+	Bids         []OrderbookDepthLevel `json:"bids,omitempty"`
+	Asks         []OrderbookDepthLevel `json:"asks,omitempty"`
+}
+
+// GetOrderbookDepth reads the most recent exchange_orderbook_snapshots row
+// for (filter.Exchange, filter.Symbol) and aggregates its ladder down to
+// the top filter.Levels price levels per side, sorted best-to-worst (bids
+// descending, asks ascending) - the same "walk a price-sorted structure
+// per side, merging same-price entries" shape ListOrderbookHeatmapAggregated
+// already uses via aggregateOrderbookLevels, just scoped to one snapshot
+// and truncated to a depth instead of returned in full.
+//
+// The aggregation itself happens in Go over the decoded levels_json blob,
+// not in SQL: this store keeps each snapshot's ladder as one JSON column
+// rather than a normalized per-level table (the older exchange_orderbook_levels
+// table backfillLegacyOrderbookLevels migrates away from), so there's no
+// per-price row set a SQL GROUP BY could aggregate over.
+func (s *Store) GetOrderbookDepth(ctx context.Context, filter OrderbookDepthFilter) (OrderbookDepth, error) {
+	levels := filter.Levels
+	if levels <= 0 {
+		levels = defaultOrderbookDepthLevels
+	}
+	side := strings.ToLower(strings.TrimSpace(filter.Side))
+	if side != "" && side != orderbookSideBid && side != orderbookSideAsk {
+		return OrderbookDepth{}, fmt.Errorf("invalid side %q: must be %q, %q, or empty", filter.Side, orderbookSideBid, orderbookSideAsk)
+	}
+
+	var snapshotTime int64
+	var levelsJSON string
+	err := s.db.QueryRowContext(
+		ctx,
+		`SELECT snapshot_time, levels_json
+		 FROM exchange_orderbook_snapshots
+		 WHERE exchange = ? AND symbol = ?
+		 ORDER BY snapshot_time DESC
+		 LIMIT 1`,
+		filter.Exchange, filter.Symbol,
+	).Scan(&snapshotTime, &levelsJSON)
+	if errors.Is(err, sql.ErrNoRows) {
+		return OrderbookDepth{}, fmt.Errorf("no orderbook snapshot found for %s:%s", filter.Exchange, filter.Symbol)
+	}
+	if err != nil {
+		return OrderbookDepth{}, err
+	}
+
+	var rawLevels []OrderbookHeatmapLevel
+	if strings.TrimSpace(levelsJSON) != "" {
+		if err := json.Unmarshal([]byte(levelsJSON), &rawLevels); err != nil {
+			return OrderbookDepth{}, fmt.Errorf("decode levels_json for %s:%s@%d: %w", filter.Exchange, filter.Symbol, snapshotTime, err)
+		}
+	}
+
+	bids, asks := aggregateOrderbookDepthLevels(rawLevels)
+	depth := OrderbookDepth{Exchange: filter.Exchange, Symbol: filter.Symbol, SnapshotTime: snapshotTime}
+	if side == "" || side == orderbookSideBid {
+		depth.Bids = truncateDepthLevels(bids, levels)
+	}
+	if side == "" || side == orderbookSideAsk {
+		depth.Asks = truncateDepthLevels(asks, levels)
+	}
+	return depth, nil
+}
+
+// aggregateOrderbookDepthLevels merges same-price entries per side, same as
+// aggregateOrderbookLevels, but also counts how many raw entries merged
+// into each price and returns the two sides pre-sorted best-to-worst
+// instead of one side-then-price-ascending slice.
+func aggregateOrderbookDepthLevels(levels []OrderbookHeatmapLevel) (bids, asks []OrderbookDepthLevel) {
+	type aggregate struct {
+		price    string
+		side     string
+		quantity float64
+		count    int
+	}
+	byKey := make(map[string]*aggregate, len(levels))
+	order := make([]string, 0, len(levels))
+
+	for _, level := range levels {
+		side := strings.ToLower(strings.TrimSpace(level.Side))
+		if side != orderbookSideBid && side != orderbookSideAsk {
+			continue
+		}
+		price := strings.TrimSpace(level.Price)
+		quantity, err := strconv.ParseFloat(strings.TrimSpace(level.Quantity), 64)
+		if err != nil || price == "" || quantity <= 0 {
+			continue
+		}
+
+		key := side + "|" + price
+		entry, ok := byKey[key]
+		if !ok {
+			entry = &aggregate{price: price, side: side}
+			byKey[key] = entry
+			order = append(order, key)
+		}
+		entry.quantity += quantity
+		entry.count++
+	}
+
+	for _, key := range order {
+		entry := byKey[key]
+		level := OrderbookDepthLevel{
+			Price:      entry.price,
+			Quantity:   strconv.FormatFloat(entry.quantity, 'f', -1, 64),
+			OrderCount: entry.count,
+		}
+		if entry.side == orderbookSideBid {
+			bids = append(bids, level)
+		} else {
+			asks = append(asks, level)
+		}
+	}
+
+	sort.Slice(bids, func(i, j int) bool { return parsePriceOrZero(bids[i].Price) > parsePriceOrZero(bids[j].Price) })
+	sort.Slice(asks, func(i, j int) bool { return parsePriceOrZero(asks[i].Price) < parsePriceOrZero(asks[j].Price) })
+	return bids, asks
+}
+
+func truncateDepthLevels(levels []OrderbookDepthLevel, limit int) []OrderbookDepthLevel {
+	if len(levels) <= limit {
+		return levels
+	}
+	return levels[:limit]
+}
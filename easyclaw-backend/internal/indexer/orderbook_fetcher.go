@@ -5,14 +5,18 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"hash/crc32"
 	"io"
 	"log/slog"
+	"maps"
+	"math"
 	"net/http"
 	"net/url"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/coldbell/dex/backend/internal/config"
@@ -49,6 +53,8 @@ type orderbookCollector struct {
 	refreshInterval time.Duration
 	providers       map[string]orderbookSnapshotProvider
 	logger          *slog.Logger
+	broker          *OrderbookBroker
+	aggregator      *aggregatedOrderbookCollector
 
 	mu      sync.RWMutex
 	cached  map[string]orderbookCacheEntry
@@ -61,6 +67,9 @@ func newOrderbookCollector(
 	timeout time.Duration,
 	refreshInterval time.Duration,
 	logger *slog.Logger,
+	broker *OrderbookBroker,
+	aggregator *aggregatedOrderbookCollector,
+	rateLimits []config.OrderbookRateLimit,
 ) *orderbookCollector {
 	if depth <= 0 {
 		depth = 1
@@ -73,11 +82,13 @@ func newOrderbookCollector(
 	}
 
 	httpClient := &http.Client{Timeout: timeout}
+	limiter := newTokenBucketRateLimiter(rateLimits)
 	providers := map[string]orderbookSnapshotProvider{
-		"binance":  &binanceOrderbookProvider{client: httpClient},
-		"okx":      &okxOrderbookProvider{client: httpClient},
-		"coinbase": &coinbaseOrderbookProvider{client: httpClient},
-		"bybit":    &bybitOrderbookProvider{client: httpClient},
+		"binance":  &binanceOrderbookProvider{client: httpClient, limiter: limiter},
+		"okx":      &okxOrderbookProvider{client: httpClient, limiter: limiter},
+		"coinbase": &coinbaseOrderbookProvider{client: httpClient, limiter: limiter},
+		"bybit":    &bybitOrderbookProvider{client: httpClient, limiter: limiter},
+		"kucoin":   &kucoinOrderbookProvider{client: httpClient, limiter: limiter},
 	}
 
 	return &orderbookCollector{
@@ -86,6 +97,8 @@ func newOrderbookCollector(
 		refreshInterval: refreshInterval,
 		providers:       providers,
 		logger:          logger,
+		broker:          broker,
+		aggregator:      aggregator,
 		cached:          make(map[string]orderbookCacheEntry, len(targets)),
 	}
 }
@@ -175,6 +188,8 @@ func (c *orderbookCollector) SnapshotAll(snapshotTime int64) []OrderbookSnapshot
 		out = append(out, snapshot)
 	}
 
+	out = append(out, c.aggregator.SnapshotAll(snapshotTime)...)
+
 	return out
 }
 
@@ -280,6 +295,11 @@ func (c *orderbookCollector) setCachedSnapshot(target config.OrderbookTarget, sn
 		updatedAt: updatedAt,
 	}
 	c.mu.Unlock()
+
+	if c.broker != nil {
+		c.broker.Publish(snapshot)
+	}
+	c.aggregator.OnSnapshot(snapshot)
 }
 
 func nextBackoff(current, floor time.Duration) time.Duration {
@@ -351,9 +371,21 @@ func (c *orderbookCollector) buildSnapshot(
 	return snapshot
 }
 
+// minLevelsFloor is the fewest levels a side must hold before a book is
+// considered worth publishing. A book that dips below this right after a
+// resync (e.g. a bootstrap snapshot that raced a delta clearing its only
+// level) isn't corrupted, just momentarily too thin to serve; emitDepthUpdate
+// skips the publish rather than treating it as an error.
+const minLevelsFloor = 1
+
 type levelBook struct {
 	bids map[string]string
 	asks map[string]string
+
+	// initialized is set once the book has received its first snapshot
+	// (REST bootstrap or websocket snapshot frame). Before that, an empty
+	// book is expected rather than a sign of corruption.
+	initialized bool
 }
 
 func newLevelBook() *levelBook {
@@ -372,6 +404,7 @@ func (b *levelBook) resetFromLevels(bids, asks []OrderbookLevel) {
 	for i := range asks {
 		b.apply(orderbookSideAsk, asks[i].Price, asks[i].Quantity)
 	}
+	b.initialized = true
 }
 
 func (b *levelBook) resetFromPairs(bids, asks [][]string) {
@@ -379,6 +412,18 @@ func (b *levelBook) resetFromPairs(bids, asks [][]string) {
 	clear(b.asks)
 	b.applyUpdates(orderbookSideBid, bids)
 	b.applyUpdates(orderbookSideAsk, asks)
+	b.initialized = true
+}
+
+// Copy returns a deep snapshot of the book's current state, safe to read
+// or hold onto without racing the goroutine that keeps applying deltas to
+// the original.
+func (b *levelBook) Copy() *levelBook {
+	return &levelBook{
+		bids:        maps.Clone(b.bids),
+		asks:        maps.Clone(b.asks),
+		initialized: b.initialized,
+	}
 }
 
 func (b *levelBook) applyUpdates(side string, levels [][]string) {
@@ -411,6 +456,72 @@ func (b *levelBook) apply(side, price, quantity string) {
 	b.asks[price] = quantity
 }
 
+// topLevelsValidationDepth bounds how many levels per side IsValid walks
+// to check monotonicity. Checking beyond the depth any handler actually
+// publishes would just waste cycles on corruption that's invisible anyway.
+const topLevelsValidationDepth = 200
+
+// IsValid reports whether the book is in a sane, corruption-free state: no
+// crossed book (best bid below best ask), monotonic top-of-book ordering
+// on both sides, no negative or NaN quantities, and - once the book has
+// received its first snapshot - no empty side. A depth stream consumer
+// should treat an invalid book as corrupted by a missed or misapplied diff
+// and trigger a full resync rather than serving it; the returned error
+// names the offending levels for the reconnect-loop's warning log.
+func (b *levelBook) IsValid() (bool, error) {
+	if b.initialized && (len(b.bids) == 0 || len(b.asks) == 0) {
+		return false, fmt.Errorf("book empty after initialization: bids=%d asks=%d", len(b.bids), len(b.asks))
+	}
+	if len(b.bids) == 0 || len(b.asks) == 0 {
+		return true, nil
+	}
+
+	bids := topSideLevels(b.bids, topLevelsValidationDepth, true)
+	asks := topSideLevels(b.asks, topLevelsValidationDepth, false)
+
+	if err := validateLevelOrdering(bids, true); err != nil {
+		return false, fmt.Errorf("bid side: %w", err)
+	}
+	if err := validateLevelOrdering(asks, false); err != nil {
+		return false, fmt.Errorf("ask side: %w", err)
+	}
+
+	bestBid, _ := parsePrice(bids[0].Price)
+	bestAsk, _ := parsePrice(asks[0].Price)
+	if bestBid >= bestAsk {
+		return false, fmt.Errorf("crossed book: bestBid=%s bestAsk=%s", bids[0].Price, asks[0].Price)
+	}
+
+	return true, nil
+}
+
+// validateLevelOrdering checks that levels are strictly monotonic in the
+// given direction (descending for bids, ascending for asks) and that every
+// quantity parses to a non-negative, non-NaN float.
+func validateLevelOrdering(levels []OrderbookLevel, desc bool) error {
+	prevPrice, havePrev := 0.0, false
+	for _, level := range levels {
+		price, ok := parsePrice(level.Price)
+		if !ok {
+			return fmt.Errorf("unparsable price %q", level.Price)
+		}
+		quantity, ok := parsePrice(level.Quantity)
+		if !ok || math.IsNaN(quantity) || quantity < 0 {
+			return fmt.Errorf("invalid quantity %q at price %q", level.Quantity, level.Price)
+		}
+		if havePrev {
+			if desc && price > prevPrice {
+				return fmt.Errorf("non-monotonic level %q after %q", level.Price, strconv.FormatFloat(prevPrice, 'f', -1, 64))
+			}
+			if !desc && price < prevPrice {
+				return fmt.Errorf("non-monotonic level %q after %q", level.Price, strconv.FormatFloat(prevPrice, 'f', -1, 64))
+			}
+		}
+		prevPrice, havePrev = price, true
+	}
+	return nil
+}
+
 func (b *levelBook) topLevels(depth int) ([]OrderbookLevel, []OrderbookLevel) {
 	if depth <= 0 {
 		depth = 1
@@ -466,6 +577,38 @@ func topSideLevels(levels map[string]string, depth int, desc bool) []OrderbookLe
 	return out
 }
 
+// ErrChecksumMismatch is returned by a streaming provider when its local
+// book's recomputed checksum disagrees with the exchange-supplied one,
+// signaling silent corruption (e.g. a dropped delta) that a fresh
+// REST/bootstrap snapshot is needed to recover from.
+var ErrChecksumMismatch = errors.New("orderbook checksum mismatch")
+
+// checksumDepth is the number of top-of-book levels per side OKX and
+// Bybit both checksum over.
+const checksumDepth = 25
+
+// depthChecksum recomputes the exchange-native CRC32 checksum OKX's books
+// channel and Bybit's orderbook.N topic both use: the top checksumDepth
+// bid/ask levels, interleaved bid,ask,bid,ask..., each formatted as
+// "price:quantity" and joined with ':', CRC32 (IEEE) over the UTF-8 bytes,
+// interpreted as a signed int32.
+func depthChecksum(book *levelBook) int32 {
+	bids := topSideLevels(book.bids, checksumDepth, true)
+	asks := topSideLevels(book.asks, checksumDepth, false)
+
+	parts := make([]string, 0, 2*checksumDepth)
+	for i := 0; i < checksumDepth; i++ {
+		if i < len(bids) {
+			parts = append(parts, bids[i].Price+":"+bids[i].Quantity)
+		}
+		if i < len(asks) {
+			parts = append(parts, asks[i].Price+":"+asks[i].Quantity)
+		}
+	}
+
+	return int32(crc32.ChecksumIEEE([]byte(strings.Join(parts, ":"))))
+}
+
 func parsePrice(raw string) (float64, bool) {
 	value, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
 	if err != nil {
@@ -522,8 +665,265 @@ func closeConnOnContextDone(ctx context.Context, conn *websocket.Conn) func() {
 	}
 }
 
+// heartbeatConfig configures startHeartbeat's per-connection
+// application-level keepalive, for venues whose protocol expects a client
+// ping distinct from the RFC 6455 control frames gorilla/websocket already
+// answers for free. payload builds the text frame to send on each
+// interval tick.
+type heartbeatConfig struct {
+	interval time.Duration
+	timeout  time.Duration
+	payload  func() ([]byte, error)
+}
+
+// heartbeat tracks liveness for a connection watched by startHeartbeat.
+// gorilla/websocket only supports one reader per connection, so
+// startHeartbeat can't read pong replies itself; the caller's own read
+// loop must call MarkAlive whenever it recognizes an incoming message as
+// this protocol's pong/ack reply.
+type heartbeat struct {
+	lastSeen atomic.Int64
+}
+
+// MarkAlive records that a pong/ack reply (or other liveness signal) was
+// just observed.
+func (h *heartbeat) MarkAlive() {
+	h.lastSeen.Store(time.Now().UnixNano())
+}
+
+func (h *heartbeat) stale(timeout time.Duration) bool {
+	return time.Since(time.Unix(0, h.lastSeen.Load())) > timeout
+}
+
+// startHeartbeat sends cfg.payload() on conn every cfg.interval and closes
+// conn - forcing the caller's blocking ReadMessage to return an error so
+// runTargetWebsocketLoop reconnects - if no MarkAlive call has landed
+// within cfg.timeout of the last one. The returned stop func must be
+// called once the caller's read loop exits so the goroutine doesn't leak.
+func startHeartbeat(conn *websocket.Conn, cfg heartbeatConfig) (hb *heartbeat, stop func()) {
+	hb = &heartbeat{}
+	hb.MarkAlive()
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(cfg.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if hb.stale(cfg.timeout) {
+					_ = conn.Close()
+					return
+				}
+				payload, err := cfg.payload()
+				if err != nil {
+					_ = conn.Close()
+					return
+				}
+				if err := conn.SetWriteDeadline(time.Now().Add(websocketWriteTimeout)); err != nil {
+					_ = conn.Close()
+					return
+				}
+				if err := conn.WriteMessage(websocket.TextMessage, payload); err != nil {
+					_ = conn.Close()
+					return
+				}
+			}
+		}
+	}()
+
+	return hb, func() { close(done) }
+}
+
+// depthResyncBufferSize bounds the ring of diffs runDepthStream holds
+// while a REST snapshot fetch is in flight. A few hundred buffered diffs
+// comfortably covers any realistic snapshot round-trip; once full, the
+// oldest buffered diff is dropped to make room for the newest, since only
+// diffs at or after the eventual snapshot's last_update_id matter anyway.
+const depthResyncBufferSize = 1000
+
+// depthDiff is a single buffered update from a Binance-style depth diff
+// stream, keyed by the first/final update id range the exchange assigns
+// it.
+type depthDiff struct {
+	firstUpdateID int64
+	finalUpdateID int64
+	bids          [][]string
+	asks          [][]string
+	eventTime     int64
+	raw           string
+}
+
+// depthContinuity tells runDepthStream how to recognize a stale diff, how
+// to bridge the first diff applied on top of a freshly fetched snapshot,
+// and how to compute the id a diff leaves the book at, so the same
+// buffering and bridging logic can serve venues with different resync
+// conventions: Binance's first/final update-id ranges (binanceDepthContinuity)
+// and OKX/Bybit-style prevSeq/seq pairs (seqDepthContinuity).
+type depthContinuity struct {
+	// stale reports whether diff is already covered by lastID and should
+	// be dropped without affecting sync state.
+	stale func(lastID int64, diff depthDiff) bool
+	// bridges reports whether diff can be the first diff applied on top
+	// of a book at lastID (the snapshot's reported id, or a prior diff's
+	// resulting id if resync happens mid-stream).
+	bridges func(lastID int64, diff depthDiff) bool
+	// nextID returns the id the book is at once diff has been applied.
+	nextID func(diff depthDiff) int64
+}
+
+// binanceDepthContinuity implements Binance's documented depth resync
+// algorithm: a diff bridges the snapshot if U <= lastUpdateId+1 <= u.
+var binanceDepthContinuity = depthContinuity{
+	stale: func(lastID int64, diff depthDiff) bool { return diff.finalUpdateID <= lastID },
+	bridges: func(lastID int64, diff depthDiff) bool {
+		expected := lastID + 1
+		return diff.firstUpdateID <= expected && expected <= diff.finalUpdateID
+	},
+	nextID: func(diff depthDiff) int64 { return diff.finalUpdateID },
+}
+
+// seqDepthContinuity implements the prevSeq/seq convention used by OKX and
+// Bybit-style venues: a diff's firstUpdateID carries the exchange's
+// "previous sequence" field, which must equal the book's current id for
+// the diff to bridge or advance cleanly.
+var seqDepthContinuity = depthContinuity{
+	stale: func(lastID int64, diff depthDiff) bool { return diff.finalUpdateID <= lastID },
+	bridges: func(lastID int64, diff depthDiff) bool {
+		return diff.firstUpdateID == 0 || diff.firstUpdateID == lastID
+	},
+	nextID: func(diff depthDiff) int64 { return diff.finalUpdateID },
+}
+
+// runDepthStream implements the standard depth-stream resync algorithm
+// shared by venues that pair a REST (or bootstrap) snapshot with an
+// incremental diff stream: buffer incoming diffs in a bounded ring while
+// the snapshot is fetched, drop buffered diffs continuity marks stale,
+// verify the first remaining diff bridges the snapshot per continuity,
+// then apply the remaining buffered diffs followed by live diffs from
+// readDiff in order. readDiff blocks until the next diff is available or
+// the stream breaks. If the book ever becomes invalid (crossed or empty),
+// runDepthStream returns an error so the caller's reconnect loop triggers
+// a full resync instead of serving a corrupted book.
+func runDepthStream(
+	ctx context.Context,
+	book *levelBook,
+	depth int,
+	continuity depthContinuity,
+	fetchSnapshot func() ([]OrderbookLevel, []OrderbookLevel, int64, error),
+	readDiff func() (depthDiff, error),
+	handler orderbookStreamHandler,
+) error {
+	diffs := make(chan depthDiff, depthResyncBufferSize)
+	readErrs := make(chan error, 1)
+
+	readerCtx, stopReader := context.WithCancel(ctx)
+	defer stopReader()
+
+	go func() {
+		for {
+			diff, err := readDiff()
+			if err != nil {
+				readErrs <- err
+				return
+			}
+			select {
+			case diffs <- diff:
+			default:
+				// Ring is full: drop the oldest buffered diff to make
+				// room. This only matters if the snapshot fetch is
+				// pathologically slow, in which case the verify step
+				// below will catch any resulting gap and force a resync.
+				select {
+				case <-diffs:
+				default:
+				}
+				diffs <- diff
+			}
+			if readerCtx.Err() != nil {
+				return
+			}
+		}
+	}()
+
+	bids, asks, lastUpdateID, err := fetchSnapshot()
+	if err != nil {
+		return fmt.Errorf("fetch depth snapshot: %w", err)
+	}
+	book.resetFromLevels(bids, asks)
+
+	synced := false
+	applyDiff := func(diff depthDiff) error {
+		if continuity.stale(lastUpdateID, diff) {
+			return nil
+		}
+		if !synced {
+			if !continuity.bridges(lastUpdateID, diff) {
+				return fmt.Errorf(
+					"depth stream out of sync with snapshot: snapshot=%d event=[%d,%d]",
+					lastUpdateID, diff.firstUpdateID, diff.finalUpdateID,
+				)
+			}
+			synced = true
+		}
+
+		book.applyUpdates(orderbookSideBid, diff.bids)
+		book.applyUpdates(orderbookSideAsk, diff.asks)
+		lastUpdateID = continuity.nextID(diff)
+		if err := emitDepthUpdate(book, depth, diff.eventTime, diff.raw, handler); err != nil {
+			return fmt.Errorf("depth book invalid after applying diff up to update id %d: %w", lastUpdateID, err)
+		}
+		return nil
+	}
+
+	// Drain whatever diffs the reader buffered while the snapshot fetch
+	// was in flight before falling through to the live loop below.
+	buffered := len(diffs)
+	for i := 0; i < buffered; i++ {
+		if err := applyDiff(<-diffs); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-readErrs:
+			return err
+		case diff := <-diffs:
+			if err := applyDiff(diff); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// emitDepthUpdate is the common tail every streaming provider runs after
+// applying a delta to its book: validate the book and, if it passes and
+// clears minLevelsFloor on both sides, publish the truncated top-of-book
+// view to handler. A book that fails IsValid is corrupted and returns an
+// error so the caller's reconnect loop forces a fresh bootstrap, the same
+// path a sequence gap takes; one that's merely thinner than minLevelsFloor
+// is not an error, just not worth publishing yet.
+func emitDepthUpdate(book *levelBook, depth int, exchangeTS int64, rawJSON string, handler orderbookStreamHandler) error {
+	if valid, err := book.IsValid(); !valid {
+		return err
+	}
+
+	outBids, outAsks := book.topLevels(depth)
+	if len(outBids) < minLevelsFloor || len(outAsks) < minLevelsFloor {
+		return nil
+	}
+	handler(outBids, outAsks, exchangeTS, rawJSON)
+	return nil
+}
+
 type binanceOrderbookProvider struct {
-	client *http.Client
+	client  *http.Client
+	limiter RateLimiter
 }
 
 func (*binanceOrderbookProvider) Name() string { return "binance" }
@@ -555,61 +955,51 @@ func (p *binanceOrderbookProvider) StreamOrderbook(
 		snapshotDepth = 5000
 	}
 
-	bids, asks, lastUpdateID, _, err := p.FetchOrderbook(ctx, symbol, snapshotDepth)
-	if err != nil {
-		return fmt.Errorf("binance snapshot bootstrap failed: %w", err)
-	}
-
-	book := newLevelBook()
-	book.resetFromLevels(bids, asks)
-
-	synced := false
-	for {
-		_, payload, err := conn.ReadMessage()
-		if err != nil {
-			if ctx.Err() != nil {
-				return ctx.Err()
+	readDiff := func() (depthDiff, error) {
+		for {
+			_, payload, err := conn.ReadMessage()
+			if err != nil {
+				if ctx.Err() != nil {
+					return depthDiff{}, ctx.Err()
+				}
+				return depthDiff{}, err
 			}
-			return err
-		}
 
-		var message struct {
-			EventType     string     `json:"e"`
-			EventTime     int64      `json:"E"`
-			FirstUpdateID int64      `json:"U"`
-			FinalUpdateID int64      `json:"u"`
-			Bids          [][]string `json:"b"`
-			Asks          [][]string `json:"a"`
-		}
-		if err := json.Unmarshal(payload, &message); err != nil {
-			continue
-		}
-		if message.FinalUpdateID == 0 {
-			continue
-		}
-		if message.FinalUpdateID <= lastUpdateID {
-			continue
-		}
-		if !synced {
-			expected := lastUpdateID + 1
-			if message.FirstUpdateID > expected || message.FinalUpdateID < expected {
-				return fmt.Errorf(
-					"binance stream out of sync: snapshot=%d event=[%d,%d]",
-					lastUpdateID,
-					message.FirstUpdateID,
-					message.FinalUpdateID,
-				)
+			var message struct {
+				EventType     string     `json:"e"`
+				EventTime     int64      `json:"E"`
+				FirstUpdateID int64      `json:"U"`
+				FinalUpdateID int64      `json:"u"`
+				Bids          [][]string `json:"b"`
+				Asks          [][]string `json:"a"`
+			}
+			if err := json.Unmarshal(payload, &message); err != nil {
+				continue
+			}
+			if message.FinalUpdateID == 0 {
+				continue
 			}
-			synced = true
-		}
 
-		book.applyUpdates(orderbookSideBid, message.Bids)
-		book.applyUpdates(orderbookSideAsk, message.Asks)
-		lastUpdateID = message.FinalUpdateID
+			return depthDiff{
+				firstUpdateID: message.FirstUpdateID,
+				finalUpdateID: message.FinalUpdateID,
+				bids:          message.Bids,
+				asks:          message.Asks,
+				eventTime:     message.EventTime,
+				raw:           string(payload),
+			}, nil
+		}
+	}
 
-		outBids, outAsks := book.topLevels(depth)
-		handler(outBids, outAsks, message.EventTime, string(payload))
+	fetchSnapshot := func() ([]OrderbookLevel, []OrderbookLevel, int64, error) {
+		bids, asks, lastUpdateID, _, err := p.FetchOrderbook(ctx, symbol, snapshotDepth)
+		if err != nil {
+			return nil, nil, 0, fmt.Errorf("binance snapshot bootstrap failed: %w", err)
+		}
+		return bids, asks, lastUpdateID, nil
 	}
+
+	return runDepthStream(ctx, newLevelBook(), depth, binanceDepthContinuity, fetchSnapshot, readDiff, handler)
 }
 
 func (p *binanceOrderbookProvider) FetchOrderbook(ctx context.Context, symbol string, depth int) ([]OrderbookLevel, []OrderbookLevel, int64, string, error) {
@@ -618,7 +1008,7 @@ func (p *binanceOrderbookProvider) FetchOrderbook(ctx context.Context, symbol st
 		url.QueryEscape(symbol),
 		depth,
 	)
-	payload, raw, err := fetchJSON(ctx, p.client, endpoint)
+	payload, raw, err := fetchJSON(ctx, p.client, p.limiter, "binance", "orderbook", endpoint)
 	if err != nil {
 		return nil, nil, 0, "", err
 	}
@@ -636,7 +1026,16 @@ func (p *binanceOrderbookProvider) FetchOrderbook(ctx context.Context, symbol st
 }
 
 type okxOrderbookProvider struct {
-	client *http.Client
+	client  *http.Client
+	limiter RateLimiter
+
+	checksumMismatches atomic.Uint64
+}
+
+// ChecksumMismatches reports how many times this provider's local book
+// failed to match OKX's per-message checksum since process start.
+func (p *okxOrderbookProvider) ChecksumMismatches() uint64 {
+	return p.checksumMismatches.Load()
 }
 
 func (*okxOrderbookProvider) Name() string { return "okx" }
@@ -645,6 +1044,14 @@ func (*okxOrderbookProvider) SupportsWebsocket(depth int) bool {
 	return depth > 0
 }
 
+// okxPingInterval and okxPingTimeout keep an OKX public-channel connection
+// alive: OKX expects a text "ping" roughly every 25s and drops a socket
+// that's gone quiet for 30s in either direction.
+const (
+	okxPingInterval = 20 * time.Second
+	okxPingTimeout  = 35 * time.Second
+)
+
 func (p *okxOrderbookProvider) StreamOrderbook(
 	ctx context.Context,
 	symbol string,
@@ -660,6 +1067,13 @@ func (p *okxOrderbookProvider) StreamOrderbook(
 	stopClose := closeConnOnContextDone(ctx, conn)
 	defer stopClose()
 
+	hb, stopHeartbeat := startHeartbeat(conn, heartbeatConfig{
+		interval: okxPingInterval,
+		timeout:  okxPingTimeout,
+		payload:  func() ([]byte, error) { return []byte("ping"), nil },
+	})
+	defer stopHeartbeat()
+
 	channel := okxOrderbookChannel(depth)
 	subscribePayload := map[string]any{
 		"op": "subscribe",
@@ -683,6 +1097,10 @@ func (p *okxOrderbookProvider) StreamOrderbook(
 			}
 			return err
 		}
+		if string(payload) == "pong" {
+			hb.MarkAlive()
+			continue
+		}
 
 		var message struct {
 			Event  string `json:"event"`
@@ -699,6 +1117,7 @@ func (p *okxOrderbookProvider) StreamOrderbook(
 				TS        string     `json:"ts"`
 				SeqID     int64      `json:"seqId"`
 				PrevSeqID int64      `json:"prevSeqId"`
+				Checksum  int32      `json:"checksum"`
 			} `json:"data"`
 		}
 		if err := json.Unmarshal(payload, &message); err != nil {
@@ -738,7 +1157,11 @@ func (p *okxOrderbookProvider) StreamOrderbook(
 				if channel == "books5" || message.Action == "snapshot" {
 					book.resetFromPairs(item.Bids, item.Asks)
 				} else {
-					if item.PrevSeqID != 0 && lastSeqID != 0 && item.PrevSeqID != lastSeqID {
+					// Reuse the same prevSeq/seq continuity check
+					// runDepthStream uses for its venues, so a gap is
+					// detected the same way everywhere it can occur.
+					diff := depthDiff{firstUpdateID: item.PrevSeqID, finalUpdateID: item.SeqID}
+					if !seqDepthContinuity.bridges(lastSeqID, diff) {
 						return fmt.Errorf(
 							"okx sequence gap: prev=%d expected=%d current=%d",
 							item.PrevSeqID,
@@ -752,8 +1175,19 @@ func (p *okxOrderbookProvider) StreamOrderbook(
 				lastSeqID = item.SeqID
 			}
 
-			outBids, outAsks := book.topLevels(depth)
-			handler(outBids, outAsks, asInt64(item.TS), string(payload))
+			if item.Checksum != 0 {
+				if computed := depthChecksum(book); computed != item.Checksum {
+					p.checksumMismatches.Add(1)
+					return fmt.Errorf(
+						"%w: exchange=okx symbol=%s computed=%d expected=%d",
+						ErrChecksumMismatch, symbol, computed, item.Checksum,
+					)
+				}
+			}
+
+			if err := emitDepthUpdate(book, depth, asInt64(item.TS), string(payload), handler); err != nil {
+				return fmt.Errorf("okx depth book invalid after seq %d: %w", item.SeqID, err)
+			}
 		}
 	}
 }
@@ -771,7 +1205,7 @@ func (p *okxOrderbookProvider) FetchOrderbook(ctx context.Context, symbol string
 		url.QueryEscape(symbol),
 		depth,
 	)
-	payload, raw, err := fetchJSON(ctx, p.client, endpoint)
+	payload, raw, err := fetchJSON(ctx, p.client, p.limiter, "okx", "orderbook", endpoint)
 	if err != nil {
 		return nil, nil, 0, "", err
 	}
@@ -801,7 +1235,8 @@ func (p *okxOrderbookProvider) FetchOrderbook(ctx context.Context, symbol string
 }
 
 type coinbaseOrderbookProvider struct {
-	client *http.Client
+	client  *http.Client
+	limiter RateLimiter
 }
 
 func (*coinbaseOrderbookProvider) Name() string { return "coinbase" }
@@ -871,8 +1306,9 @@ func (p *coinbaseOrderbookProvider) StreamOrderbook(
 		case "snapshot":
 			book.resetFromPairs(message.Bids, message.Asks)
 			initialized = true
-			outBids, outAsks := book.topLevels(depth)
-			handler(outBids, outAsks, parseCoinbaseTime(message.Time), string(payload))
+			if err := emitDepthUpdate(book, depth, parseCoinbaseTime(message.Time), string(payload), handler); err != nil {
+				return fmt.Errorf("coinbase depth book invalid after snapshot: %w", err)
+			}
 		case "l2update":
 			if !initialized {
 				continue
@@ -887,8 +1323,9 @@ func (p *coinbaseOrderbookProvider) StreamOrderbook(
 				}
 				book.apply(side, change[1], change[2])
 			}
-			outBids, outAsks := book.topLevels(depth)
-			handler(outBids, outAsks, parseCoinbaseTime(message.Time), string(payload))
+			if err := emitDepthUpdate(book, depth, parseCoinbaseTime(message.Time), string(payload), handler); err != nil {
+				return fmt.Errorf("coinbase depth book invalid after l2update: %w", err)
+			}
 		default:
 			continue
 		}
@@ -896,11 +1333,19 @@ func (p *coinbaseOrderbookProvider) StreamOrderbook(
 }
 
 func (p *coinbaseOrderbookProvider) FetchOrderbook(ctx context.Context, symbol string, depth int) ([]OrderbookLevel, []OrderbookLevel, int64, string, error) {
+	return fetchCoinbaseOrderbook(ctx, p.client, p.limiter, symbol, depth)
+}
+
+// fetchCoinbaseOrderbook is coinbaseOrderbookProvider.FetchOrderbook's
+// implementation, factored out so CoinbaseFullChannelProvider (which has
+// no "full channel" REST snapshot equivalent) can serve FetchOrderbook
+// off the same level-2 REST endpoint.
+func fetchCoinbaseOrderbook(ctx context.Context, client *http.Client, limiter RateLimiter, symbol string, depth int) ([]OrderbookLevel, []OrderbookLevel, int64, string, error) {
 	endpoint := fmt.Sprintf(
 		"https://api.exchange.coinbase.com/products/%s/book?level=2",
 		url.PathEscape(symbol),
 	)
-	payload, raw, err := fetchJSON(ctx, p.client, endpoint)
+	payload, raw, err := fetchJSON(ctx, client, limiter, "coinbase", "orderbook", endpoint)
 	if err != nil {
 		return nil, nil, 0, "", err
 	}
@@ -924,7 +1369,16 @@ func (p *coinbaseOrderbookProvider) FetchOrderbook(ctx context.Context, symbol s
 }
 
 type bybitOrderbookProvider struct {
-	client *http.Client
+	client  *http.Client
+	limiter RateLimiter
+
+	checksumMismatches atomic.Uint64
+}
+
+// ChecksumMismatches reports how many times this provider's local book
+// failed to match Bybit's per-message checksum since process start.
+func (p *bybitOrderbookProvider) ChecksumMismatches() uint64 {
+	return p.checksumMismatches.Load()
 }
 
 func (*bybitOrderbookProvider) Name() string { return "bybit" }
@@ -933,6 +1387,14 @@ func (*bybitOrderbookProvider) SupportsWebsocket(depth int) bool {
 	return depth > 0
 }
 
+// bybitPingInterval and bybitPingTimeout keep a Bybit public connection
+// alive: Bybit expects a {"op":"ping"} roughly every 20s and drops a
+// socket that's gone quiet for 30s.
+const (
+	bybitPingInterval = 18 * time.Second
+	bybitPingTimeout  = 28 * time.Second
+)
+
 func (p *bybitOrderbookProvider) StreamOrderbook(
 	ctx context.Context,
 	symbol string,
@@ -948,6 +1410,13 @@ func (p *bybitOrderbookProvider) StreamOrderbook(
 	stopClose := closeConnOnContextDone(ctx, conn)
 	defer stopClose()
 
+	hb, stopHeartbeat := startHeartbeat(conn, heartbeatConfig{
+		interval: bybitPingInterval,
+		timeout:  bybitPingTimeout,
+		payload:  func() ([]byte, error) { return json.Marshal(map[string]string{"op": "ping"}) },
+	})
+	defer stopHeartbeat()
+
 	streamDepth := bybitStreamDepth(depth)
 	topic := fmt.Sprintf("orderbook.%d.%s", streamDepth, symbol)
 	subscribePayload := map[string]any{
@@ -979,16 +1448,21 @@ func (p *bybitOrderbookProvider) StreamOrderbook(
 			TS      int64  `json:"ts"`
 			CTS     int64  `json:"cts"`
 			Data    struct {
-				Symbol string     `json:"s"`
-				Bids   [][]string `json:"b"`
-				Asks   [][]string `json:"a"`
-				U      int64      `json:"u"`
+				Symbol   string     `json:"s"`
+				Bids     [][]string `json:"b"`
+				Asks     [][]string `json:"a"`
+				U        int64      `json:"u"`
+				Checksum int32      `json:"cs"`
 			} `json:"data"`
 		}
 		if err := json.Unmarshal(payload, &message); err != nil {
 			continue
 		}
 
+		if message.Op == "pong" {
+			hb.MarkAlive()
+			continue
+		}
 		if message.Success != nil {
 			if !*message.Success {
 				return fmt.Errorf("bybit subscribe failed: %s", message.RetMsg)
@@ -1013,12 +1487,23 @@ func (p *bybitOrderbookProvider) StreamOrderbook(
 			continue
 		}
 
-		outBids, outAsks := book.topLevels(depth)
+		if message.Data.Checksum != 0 {
+			if computed := depthChecksum(book); computed != message.Data.Checksum {
+				p.checksumMismatches.Add(1)
+				return fmt.Errorf(
+					"%w: exchange=bybit symbol=%s computed=%d expected=%d",
+					ErrChecksumMismatch, symbol, computed, message.Data.Checksum,
+				)
+			}
+		}
+
 		exchangeTS := message.CTS
 		if exchangeTS == 0 {
 			exchangeTS = message.TS
 		}
-		handler(outBids, outAsks, exchangeTS, string(payload))
+		if err := emitDepthUpdate(book, depth, exchangeTS, string(payload), handler); err != nil {
+			return fmt.Errorf("bybit depth book invalid after update %d: %w", message.Data.U, err)
+		}
 	}
 }
 
@@ -1041,7 +1526,7 @@ func (p *bybitOrderbookProvider) FetchOrderbook(ctx context.Context, symbol stri
 		url.QueryEscape(symbol),
 		depth,
 	)
-	payload, raw, err := fetchJSON(ctx, p.client, endpoint)
+	payload, raw, err := fetchJSON(ctx, p.client, p.limiter, "bybit", "orderbook", endpoint)
 	if err != nil {
 		return nil, nil, 0, "", err
 	}
@@ -1065,32 +1550,69 @@ func (p *bybitOrderbookProvider) FetchOrderbook(ctx context.Context, symbol stri
 	return bids, asks, asInt64(resultObj["ts"]), raw, nil
 }
 
-func fetchJSON(ctx context.Context, client *http.Client, endpoint string) (map[string]any, string, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+// fetchJSON issues a rate-limited GET against endpoint. limiter may be nil
+// for call sites with no meaningful budget to share (e.g. a cached,
+// low-frequency instrument-info lookup); venue and endpointClass key the
+// shared limiter's per-(venue, endpointClass) budget. A response that
+// reports a rate-limit rejection - HTTP 429, or a retCode field bybit
+// uses for the same purpose - waits out the limiter's observed reset and
+// is retried exactly once.
+func fetchJSON(ctx context.Context, client *http.Client, limiter RateLimiter, venue, endpointClass, endpoint string) (map[string]any, string, error) {
+	payload, raw, resp, err := fetchJSONOnce(ctx, client, limiter, venue, endpointClass, endpoint)
 	if err != nil {
 		return nil, "", err
 	}
+
+	if isRateLimitedResponse(resp.StatusCode, asInt64(payload["retCode"])) {
+		if limiter != nil {
+			if err := limiter.Wait(ctx, venue, endpointClass); err != nil {
+				return nil, "", err
+			}
+		}
+		payload, raw, resp, err = fetchJSONOnce(ctx, client, limiter, venue, endpointClass, endpoint)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("request failed (%d): %s", resp.StatusCode, raw)
+	}
+	return payload, raw, nil
+}
+
+func fetchJSONOnce(ctx context.Context, client *http.Client, limiter RateLimiter, venue, endpointClass, endpoint string) (map[string]any, string, *http.Response, error) {
+	if limiter != nil {
+		if err := limiter.Wait(ctx, venue, endpointClass); err != nil {
+			return nil, "", nil, err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, "", nil, err
+	}
 	req.Header.Set("User-Agent", "dex-orderbook-collector/1.0")
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, "", err
+		return nil, "", nil, err
 	}
 	defer resp.Body.Close()
 
 	raw, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
 	if err != nil {
-		return nil, "", err
-	}
-	if resp.StatusCode != http.StatusOK {
-		return nil, "", fmt.Errorf("request failed (%d): %s", resp.StatusCode, string(raw))
+		return nil, "", nil, err
 	}
 
 	var payload map[string]any
-	if err := json.Unmarshal(raw, &payload); err != nil {
-		return nil, "", err
+	_ = json.Unmarshal(raw, &payload)
+
+	if limiter != nil {
+		limiter.Observe(venue, endpointClass, resp.Header, asInt64(payload["retCode"]))
 	}
-	return payload, string(raw), nil
+
+	return payload, string(raw), resp, nil
 }
 
 func parseOrderbookLevels(raw any, limit int) ([]OrderbookLevel, error) {
@@ -0,0 +1,263 @@
+package indexer
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	kucoinBulletEndpoint      = "https://api.kucoin.com/api/v1/bullet-public"
+	kucoinSnapshotEndpoint    = "https://api.kucoin.com/api/v3/market/orderbook/level2"
+	kucoinDefaultPingInterval = 18 * time.Second
+	kucoinDefaultPingTimeout  = 10 * time.Second
+)
+
+// kucoinOrderbookProvider streams Kucoin's level2 orderbook. Unlike the
+// other venues, every connection needs a fresh ephemeral token from a
+// bullet-public preflight call; since StreamOrderbook fetches that token
+// before dialing, the outer runTargetWebsocketLoop reconnect-on-error loop
+// already refreshes it for free whenever the token's TTL expires and the
+// server drops the connection.
+type kucoinOrderbookProvider struct {
+	client  *http.Client
+	limiter RateLimiter
+
+	requestID atomic.Uint64
+}
+
+func (*kucoinOrderbookProvider) Name() string { return "kucoin" }
+
+func (*kucoinOrderbookProvider) SupportsWebsocket(depth int) bool {
+	return depth > 0
+}
+
+type kucoinBulletToken struct {
+	token        string
+	endpoint     string
+	pingInterval time.Duration
+	pingTimeout  time.Duration
+}
+
+func (p *kucoinOrderbookProvider) fetchBulletToken(ctx context.Context) (kucoinBulletToken, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, kucoinBulletEndpoint, nil)
+	if err != nil {
+		return kucoinBulletToken{}, err
+	}
+	req.Header.Set("User-Agent", "dex-orderbook-collector/1.0")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return kucoinBulletToken{}, err
+	}
+	defer resp.Body.Close()
+
+	var payload struct {
+		Code string `json:"code"`
+		Msg  string `json:"msg"`
+		Data struct {
+			Token           string `json:"token"`
+			InstanceServers []struct {
+				Endpoint     string `json:"endpoint"`
+				PingInterval int64  `json:"pingInterval"`
+				PingTimeout  int64  `json:"pingTimeout"`
+			} `json:"instanceServers"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return kucoinBulletToken{}, err
+	}
+	if payload.Code != "200000" {
+		return kucoinBulletToken{}, fmt.Errorf("kucoin bullet-public error: code=%s msg=%s", payload.Code, payload.Msg)
+	}
+	if payload.Data.Token == "" || len(payload.Data.InstanceServers) == 0 {
+		return kucoinBulletToken{}, fmt.Errorf("kucoin bullet-public response missing token or instance servers")
+	}
+
+	server := payload.Data.InstanceServers[0]
+	pingInterval := kucoinDefaultPingInterval
+	if server.PingInterval > 0 {
+		pingInterval = time.Duration(server.PingInterval) * time.Millisecond
+	}
+	pingTimeout := kucoinDefaultPingTimeout
+	if server.PingTimeout > 0 {
+		pingTimeout = time.Duration(server.PingTimeout) * time.Millisecond
+	}
+
+	return kucoinBulletToken{
+		token:        payload.Data.Token,
+		endpoint:     server.Endpoint,
+		pingInterval: pingInterval,
+		pingTimeout:  pingTimeout,
+	}, nil
+}
+
+func (p *kucoinOrderbookProvider) nextRequestID() string {
+	return strconv.FormatUint(p.requestID.Add(1), 10)
+}
+
+func generateConnectID() (string, error) {
+	raw := make([]byte, 12)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
+
+func (p *kucoinOrderbookProvider) StreamOrderbook(
+	ctx context.Context,
+	symbol string,
+	depth int,
+	handler orderbookStreamHandler,
+) error {
+	bullet, err := p.fetchBulletToken(ctx)
+	if err != nil {
+		return fmt.Errorf("kucoin bullet token: %w", err)
+	}
+
+	connectID, err := generateConnectID()
+	if err != nil {
+		return fmt.Errorf("kucoin connect id: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("%s?token=%s&connectId=%s", bullet.endpoint, url.QueryEscape(bullet.token), connectID)
+	conn, _, err := dialWebsocket(ctx, endpoint)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	stopClose := closeConnOnContextDone(ctx, conn)
+	defer stopClose()
+
+	subscribePayload := map[string]any{
+		"id":       p.nextRequestID(),
+		"type":     "subscribe",
+		"topic":    "/market/level2:" + symbol,
+		"response": true,
+	}
+	if err := writeWebsocketJSON(conn, subscribePayload); err != nil {
+		return err
+	}
+
+	hb, stopHeartbeat := startHeartbeat(conn, heartbeatConfig{
+		interval: bullet.pingInterval,
+		timeout:  bullet.pingTimeout,
+		payload: func() ([]byte, error) {
+			return json.Marshal(map[string]any{"id": p.nextRequestID(), "type": "ping"})
+		},
+	})
+	defer stopHeartbeat()
+
+	fetchSnapshot := func() ([]OrderbookLevel, []OrderbookLevel, int64, error) {
+		bootstrapDepth := depth
+		if bootstrapDepth < 100 {
+			bootstrapDepth = 100
+		}
+		bids, asks, lastSeq, _, err := p.FetchOrderbook(ctx, symbol, bootstrapDepth)
+		if err != nil {
+			return nil, nil, 0, fmt.Errorf("kucoin snapshot bootstrap failed: %w", err)
+		}
+		return bids, asks, lastSeq, nil
+	}
+
+	readDiff := func() (depthDiff, error) {
+		for {
+			_, payload, err := conn.ReadMessage()
+			if err != nil {
+				if ctx.Err() != nil {
+					return depthDiff{}, ctx.Err()
+				}
+				return depthDiff{}, err
+			}
+
+			var message struct {
+				Type    string `json:"type"`
+				Subject string `json:"subject"`
+				Code    string `json:"code"`
+				Data    string `json:"data"`
+			}
+			// Decode the envelope with Data left as raw text first, since
+			// error/pong/ack frames don't share the level2 update shape.
+			if err := json.Unmarshal(payload, &message); err != nil {
+				continue
+			}
+			if message.Type == "error" {
+				return depthDiff{}, fmt.Errorf("kucoin websocket error: code=%s %s", message.Code, message.Data)
+			}
+			if message.Type == "pong" {
+				hb.MarkAlive()
+				continue
+			}
+			if message.Type != "message" {
+				continue
+			}
+
+			var update struct {
+				Subject string `json:"subject"`
+				Data    struct {
+					Changes struct {
+						Asks [][]string `json:"asks"`
+						Bids [][]string `json:"bids"`
+					} `json:"changes"`
+					SequenceStart int64 `json:"sequenceStart"`
+					SequenceEnd   int64 `json:"sequenceEnd"`
+					Time          int64 `json:"time"`
+				} `json:"data"`
+			}
+			if err := json.Unmarshal(payload, &update); err != nil {
+				continue
+			}
+			if update.Subject != "trade.l2update" {
+				continue
+			}
+
+			return depthDiff{
+				firstUpdateID: update.Data.SequenceStart,
+				finalUpdateID: update.Data.SequenceEnd,
+				bids:          update.Data.Changes.Bids,
+				asks:          update.Data.Changes.Asks,
+				eventTime:     update.Data.Time,
+				raw:           string(payload),
+			}, nil
+		}
+	}
+
+	return runDepthStream(ctx, newLevelBook(), depth, binanceDepthContinuity, fetchSnapshot, readDiff, handler)
+}
+
+func (p *kucoinOrderbookProvider) FetchOrderbook(ctx context.Context, symbol string, depth int) ([]OrderbookLevel, []OrderbookLevel, int64, string, error) {
+	if depth > 100 {
+		depth = 100
+	}
+	endpoint := fmt.Sprintf("%s?symbol=%s", kucoinSnapshotEndpoint, url.QueryEscape(symbol))
+	payload, raw, err := fetchJSON(ctx, p.client, p.limiter, "kucoin", "orderbook", endpoint)
+	if err != nil {
+		return nil, nil, 0, "", err
+	}
+	if code, ok := payload["code"]; ok && asString(code) != "200000" {
+		return nil, nil, 0, "", fmt.Errorf("kucoin api error: code=%s", asString(code))
+	}
+
+	dataObj, ok := payload["data"].(map[string]any)
+	if !ok {
+		return nil, nil, 0, "", fmt.Errorf("kucoin response missing data")
+	}
+
+	bids, err := parseOrderbookLevels(dataObj["bids"], depth)
+	if err != nil {
+		return nil, nil, 0, "", err
+	}
+	asks, err := parseOrderbookLevels(dataObj["asks"], depth)
+	if err != nil {
+		return nil, nil, 0, "", err
+	}
+
+	return bids, asks, asInt64(dataObj["sequence"]), raw, nil
+}
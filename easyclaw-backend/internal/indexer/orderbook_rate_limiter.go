@@ -0,0 +1,250 @@
+package indexer
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coldbell/dex/backend/internal/config"
+)
+
+// RateLimiter coordinates fetchJSON calls sharing an *http.Client so a
+// burst of requests across providers (or across a single venue's own
+// endpoints) doesn't trip a venue's IP-level rate limits.
+type RateLimiter interface {
+	// Wait blocks until venue/endpointClass has budget for one more
+	// request, or ctx is done.
+	Wait(ctx context.Context, venue, endpointClass string) error
+	// Observe updates venue/endpointClass's budget from a completed
+	// response's rate-limit headers and, for venues that report it,
+	// the response body's API-level return code.
+	Observe(venue, endpointClass string, header http.Header, retCode int64)
+}
+
+// RateLimitBudget is a token-bucket budget: Requests tokens refill evenly
+// over Per.
+type RateLimitBudget struct {
+	Requests int
+	Per      time.Duration
+}
+
+// defaultRateLimitBudgets seeds every (venue, endpointClass) pair's
+// token-bucket budget; config.OrderbookRateLimit entries override these
+// per pair, and any pair neither default nor overridden falls back to
+// fallbackRateLimitBudget.
+var defaultRateLimitBudgets = map[string]RateLimitBudget{
+	rateLimitKey("binance", "orderbook"):       {Requests: 20, Per: time.Second},
+	rateLimitKey("okx", "orderbook"):           {Requests: 20, Per: 2 * time.Second},
+	rateLimitKey("coinbase", "orderbook"):      {Requests: 10, Per: time.Second},
+	rateLimitKey("bybit", "orderbook"):         {Requests: 10, Per: time.Second},
+	rateLimitKey("kucoin", "orderbook"):        {Requests: 30, Per: 3 * time.Second},
+	rateLimitKey("binance", "instrument-info"): {Requests: 1, Per: 10 * time.Second},
+}
+
+// fallbackRateLimitBudget covers any (venue, endpointClass) pair absent
+// from both defaultRateLimitBudgets and config overrides.
+var fallbackRateLimitBudget = RateLimitBudget{Requests: 5, Per: time.Second}
+
+func rateLimitKey(venue, endpointClass string) string {
+	return strings.ToLower(venue) + ":" + endpointClass
+}
+
+// bybit retCodes signaling the request itself was rejected for exceeding
+// a rate limit, distinct from the transport-level HTTP 429.
+const (
+	bybitRetCodeRateLimited   = 10006
+	bybitRetCodeIPRateLimited = 10018
+)
+
+func isRateLimitedResponse(statusCode int, retCode int64) bool {
+	return statusCode == http.StatusTooManyRequests ||
+		retCode == bybitRetCodeRateLimited ||
+		retCode == bybitRetCodeIPRateLimited
+}
+
+// tokenBucketRateLimiter is RateLimiter's default implementation: one
+// token bucket per (venue, endpointClass), refilled from its budget and
+// additionally held closed until any reset timestamp the venue reports
+// via Observe.
+type tokenBucketRateLimiter struct {
+	budgets map[string]RateLimitBudget
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+// newTokenBucketRateLimiter builds a RateLimiter seeded from
+// defaultRateLimitBudgets with overrides applied on top, one per
+// config.OrderbookRateLimit entry.
+func newTokenBucketRateLimiter(overrides []config.OrderbookRateLimit) *tokenBucketRateLimiter {
+	budgets := make(map[string]RateLimitBudget, len(defaultRateLimitBudgets)+len(overrides))
+	for key, budget := range defaultRateLimitBudgets {
+		budgets[key] = budget
+	}
+	for _, override := range overrides {
+		budgets[rateLimitKey(override.Venue, override.EndpointClass)] = RateLimitBudget{
+			Requests: override.Requests,
+			Per:      time.Duration(override.PerSeconds * float64(time.Second)),
+		}
+	}
+
+	return &tokenBucketRateLimiter{budgets: budgets, buckets: make(map[string]*tokenBucket)}
+}
+
+func (l *tokenBucketRateLimiter) bucketFor(venue, endpointClass string) *tokenBucket {
+	key := rateLimitKey(venue, endpointClass)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if bucket, ok := l.buckets[key]; ok {
+		return bucket
+	}
+
+	budget, ok := l.budgets[key]
+	if !ok {
+		budget = fallbackRateLimitBudget
+	}
+	capacity := float64(budget.Requests)
+	if capacity <= 0 {
+		capacity = 1
+	}
+	refillPerSec := capacity / budget.Per.Seconds()
+
+	bucket := &tokenBucket{
+		tokens:       capacity,
+		capacity:     capacity,
+		refillPerSec: refillPerSec,
+		lastRefill:   time.Now(),
+	}
+	l.buckets[key] = bucket
+	return bucket
+}
+
+func (l *tokenBucketRateLimiter) Wait(ctx context.Context, venue, endpointClass string) error {
+	bucket := l.bucketFor(venue, endpointClass)
+	for {
+		wait, ok := bucket.take()
+		if ok {
+			return nil
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (l *tokenBucketRateLimiter) Observe(venue, endpointClass string, header http.Header, retCode int64) {
+	bucket := l.bucketFor(venue, endpointClass)
+
+	if resetAt, ok := bybitRateLimitReset(header, retCode); ok {
+		bucket.blockUntil(resetAt)
+	}
+	if resetAt, ok := coinbaseRateLimitReset(header); ok {
+		bucket.blockUntil(resetAt)
+	}
+}
+
+// bybitRateLimitReset reports the time bybit's X-Bapi-Limit-Reset-Timestamp
+// header says budget resets at, when X-Bapi-Limit-Status reports the
+// budget as exhausted or the response's retCode itself was a rate-limit
+// rejection.
+func bybitRateLimitReset(header http.Header, retCode int64) (time.Time, bool) {
+	resetMs := header.Get("X-Bapi-Limit-Reset-Timestamp")
+	if resetMs == "" {
+		return time.Time{}, false
+	}
+	ms, err := strconv.ParseInt(resetMs, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	exhausted := retCode == bybitRetCodeRateLimited || retCode == bybitRetCodeIPRateLimited
+	if status := header.Get("X-Bapi-Limit-Status"); status != "" {
+		if remaining, err := strconv.Atoi(status); err == nil && remaining <= 0 {
+			exhausted = true
+		}
+	}
+	if !exhausted {
+		return time.Time{}, false
+	}
+
+	return time.UnixMilli(ms), true
+}
+
+// coinbaseRateLimitReset mirrors bybitRateLimitReset for Coinbase's
+// CB-RATELIMIT-REMAINING / CB-RATELIMIT-RESET headers (a Unix-seconds
+// reset timestamp), reported exhausted once remaining reaches zero.
+func coinbaseRateLimitReset(header http.Header) (time.Time, bool) {
+	remainingRaw := header.Get("CB-RATELIMIT-REMAINING")
+	resetRaw := header.Get("CB-RATELIMIT-RESET")
+	if remainingRaw == "" || resetRaw == "" {
+		return time.Time{}, false
+	}
+
+	remaining, err := strconv.Atoi(remainingRaw)
+	if err != nil || remaining > 0 {
+		return time.Time{}, false
+	}
+	resetSec, err := strconv.ParseInt(resetRaw, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return time.Unix(resetSec, 0), true
+}
+
+// tokenBucket is a standard token bucket, plus blockedUntil so a venue's
+// own rate-limit headers can hold it closed past what the local refill
+// schedule alone would compute.
+type tokenBucket struct {
+	mu           sync.Mutex
+	tokens       float64
+	capacity     float64
+	refillPerSec float64
+	lastRefill   time.Time
+	blockedUntil time.Time
+}
+
+// take reports (0, true) and consumes one token if the bucket has budget
+// right now, or (wait, false) with how long the caller should sleep
+// before trying again.
+func (b *tokenBucket) take() (time.Duration, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	if now.Before(b.blockedUntil) {
+		return b.blockedUntil.Sub(now), false
+	}
+
+	elapsed := now.Sub(b.lastRefill).Seconds()
+	b.lastRefill = now
+	b.tokens += elapsed * b.refillPerSec
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+
+	if b.tokens < 1 {
+		missing := 1 - b.tokens
+		return time.Duration(missing/b.refillPerSec*float64(time.Second)) + time.Millisecond, false
+	}
+
+	b.tokens--
+	return 0, true
+}
+
+func (b *tokenBucket) blockUntil(t time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if t.After(b.blockedUntil) {
+		b.blockedUntil = t
+	}
+}
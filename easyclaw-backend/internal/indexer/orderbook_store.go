@@ -21,6 +21,9 @@ type OrderbookLevel struct {
 	Level    int    `json:"level"`
 	Price    string `json:"price"`
 	Quantity string `json:"quantity"`
+	// Venue identifies the originating exchange for a level in a merged
+	// consolidated:<symbol> snapshot; empty for a single-venue snapshot.
+	Venue string `json:"venue,omitempty"`
 }
 
 type OrderbookSnapshot struct {
@@ -42,6 +45,10 @@ type OrderbookHeatmapFilter struct {
 	ToUnix   int64
 	Limit    int
 	Offset   int
+	// Cursor, when set, takes priority over Offset and resumes a prior
+	// ListOrderbookHeatmap call from its NextCursor (keyset on
+	// snapshot_time, id - the same convention ListFills uses).
+	Cursor string
 }
 
 type OrderbookHeatmapRecord struct {
@@ -167,14 +174,31 @@ func (s *Store) UpsertOrderbookSnapshotTx(ctx context.Context, tx *Tx, snapshot
 	return snapshotID, nil
 }
 
+// InsertOrderBookSnapshot persists a single book snapshot outside of a
+// caller-managed transaction, for callers (like a depth stream consumer)
+// that checkpoint one book at a time rather than batching several targets
+// into the periodic heatmap flush.
+func (s *Store) InsertOrderBookSnapshot(ctx context.Context, snapshot OrderbookSnapshot) (int64, error) {
+	var id int64
+	err := s.WithTx(ctx, func(tx *Tx) error {
+		var err error
+		id, err = s.UpsertOrderbookSnapshotTx(ctx, tx, snapshot)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
 func (s *Store) ListOrderbookHeatmap(
 	ctx context.Context,
 	filter OrderbookHeatmapFilter,
-) ([]OrderbookHeatmapRecord, int, int, error) {
+) ([]OrderbookHeatmapRecord, int, int, string, error) {
 	limit, offset := normalizePagination(filter.Limit, filter.Offset)
 
 	clauses := []string{"1 = 1"}
-	args := make([]any, 0, 6)
+	args := make([]any, 0, 7)
 
 	if filter.Exchange != "" {
 		clauses = append(clauses, "exchange = ?")
@@ -193,20 +217,36 @@ func (s *Store) ListOrderbookHeatmap(
 		args = append(args, filter.ToUnix)
 	}
 
-	rows, err := s.db.QueryContext(
-		ctx,
-		`
+	useCursor := filter.Cursor != ""
+	if useCursor {
+		fields, err := decodeCursor(filter.Cursor, 2)
+		if err != nil {
+			return nil, 0, 0, "", err
+		}
+		clauses = append(clauses, "(snapshot_time < ? OR (snapshot_time = ? AND id < ?))")
+		args = append(args, fields[0], fields[0], fields[1])
+	}
+
+	pageClause := "LIMIT ? OFFSET ?"
+	if useCursor {
+		pageClause = "LIMIT ?"
+	}
+	query := `
 		SELECT
 			id, exchange, symbol, snapshot_time, exchange_ts, best_bid, best_ask, levels_json
 		FROM exchange_orderbook_snapshots
-		WHERE `+strings.Join(clauses, " AND ")+`
-		ORDER BY snapshot_time DESC
-		LIMIT ? OFFSET ?
-		`,
-		append(append(args, limit), offset)...,
-	)
+		WHERE ` + strings.Join(clauses, " AND ") + `
+		ORDER BY snapshot_time DESC, id DESC
+		` + pageClause
+	if useCursor {
+		args = append(args, limit)
+	} else {
+		args = append(args, limit, offset)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, 0, 0, err
+		return nil, 0, 0, "", err
 	}
 	defer rows.Close()
 
@@ -224,14 +264,14 @@ func (s *Store) ListOrderbookHeatmap(
 			&item.BestAsk,
 			&levelsJSON,
 		); err != nil {
-			return nil, 0, 0, err
+			return nil, 0, 0, "", err
 		}
 
 		if strings.TrimSpace(levelsJSON) == "" {
 			levelsJSON = "[]"
 		}
 		if err := json.Unmarshal([]byte(levelsJSON), &item.Levels); err != nil {
-			return nil, 0, 0, fmt.Errorf("decode levels_json for %s:%s@%d: %w", item.Exchange, item.Symbol, item.SnapshotTime, err)
+			return nil, 0, 0, "", fmt.Errorf("decode levels_json for %s:%s@%d: %w", item.Exchange, item.Symbol, item.SnapshotTime, err)
 		}
 		if item.Levels == nil {
 			item.Levels = make([]OrderbookHeatmapLevel, 0)
@@ -240,10 +280,33 @@ func (s *Store) ListOrderbookHeatmap(
 		snapshots = append(snapshots, item)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, 0, 0, err
+		return nil, 0, 0, "", err
+	}
+
+	// If OrderbookRetentionManager has already pruned the raw rows covering
+	// this range, fall back to the coarsest table that still has them: the
+	// 1-minute rollup bars. This only routes when the raw query came back
+	// empty - it doesn't yet pick among several downsample tiers, since only
+	// the 1m tier exists (see OrderbookRetentionManager), and it doesn't
+	// support cursor pagination since the rollup bars are only ever read as
+	// a last-resort fallback page, not tailed.
+	if len(snapshots) == 0 && !useCursor {
+		rollup, err := s.listOrderbookHeatmapRollup(ctx, filter, limit, offset)
+		if err != nil {
+			return nil, 0, 0, "", err
+		}
+		if len(rollup) > 0 {
+			return rollup, limit, offset, "", nil
+		}
+	}
+
+	nextCursor := ""
+	if len(snapshots) == limit {
+		last := snapshots[len(snapshots)-1]
+		nextCursor = encodeCursor(strconv.FormatInt(last.SnapshotTime, 10), strconv.FormatInt(last.ID, 10))
 	}
 
-	return snapshots, limit, offset, nil
+	return snapshots, limit, offset, nextCursor, nil
 }
 
 func (s *Store) ListOrderbookHeatmapAggregated(
@@ -0,0 +1,285 @@
+package indexer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultVolumeProfileValueAreaPct = 0.70
+	defaultVolumeProfileDwellSec     = 60
+)
+
+type OrderbookVolumeProfileFilter struct {
+	SymbolKey string
+	FromUnix  int64
+	ToUnix    int64
+	// TickSize buckets level prices into price/TickSize bands; must be > 0.
+	TickSize float64
+	// ValueAreaPct is the fraction of total liquidity the value area must
+	// contain, centered on the point of control. Defaults to 0.70 (the
+	// conventional VPVR value area) when <= 0 or > 1.
+	ValueAreaPct float64
+	Limit        int
+	Offset       int
+}
+
+type OrderbookVolumeProfileExchangeShare struct {
+	Exchange string `json:"exchange"`
+	Quantity string `json:"quantity"`
+}
+
+type OrderbookVolumeProfileBucket struct {
+	Price      string                                `json:"price"`
+	Side       string                                `json:"side"`
+	Quantity   string                                `json:"quantity"`
+	ByExchange []OrderbookVolumeProfileExchangeShare `json:"by_exchange"`
+}
+
+type OrderbookVolumeProfile struct {
+	SymbolKey      string                         `json:"symbol_key"`
+	FromUnix       int64                          `json:"from_unix"`
+	ToUnix         int64                          `json:"to_unix"`
+	TickSize       float64                        `json:"tick_size"`
+	PointOfControl string                         `json:"point_of_control"`
+	ValueAreaHigh  string                         `json:"value_area_high"`
+	ValueAreaLow   string                         `json:"value_area_low"`
+	Buckets        []OrderbookVolumeProfileBucket `json:"buckets"`
+}
+
+// ListOrderbookVolumeProfile builds a VPVR-style volume profile over
+// [filter.FromUnix, filter.ToUnix]: resting liquidity at each price bucket,
+// weighted by how long it sat there (the dwell time until the next snapshot
+// from the same exchange), separated by side and broken down per exchange.
+// It reuses aggregateOrderbookLevels' same-price-collapsing logic per
+// snapshot before bucketing by tick size, the same way
+// ListOrderbookHeatmapAggregated reuses it for its per-snapshot points.
+func (s *Store) ListOrderbookVolumeProfile(ctx context.Context, filter OrderbookVolumeProfileFilter) (OrderbookVolumeProfile, error) {
+	if filter.TickSize <= 0 {
+		return OrderbookVolumeProfile{}, fmt.Errorf("volume profile tick size must be > 0")
+	}
+	valueAreaPct := filter.ValueAreaPct
+	if valueAreaPct <= 0 || valueAreaPct > 1 {
+		valueAreaPct = defaultVolumeProfileValueAreaPct
+	}
+	limit, offset := normalizePagination(filter.Limit, filter.Offset)
+	symbolKey := normalizeOrderbookSymbolKey(filter.SymbolKey)
+
+	clauses := []string{"1 = 1"}
+	args := make([]any, 0, 4)
+	if symbolKey != "" {
+		clauses = append(
+			clauses,
+			"UPPER(REPLACE(REPLACE(REPLACE(symbol, '-', ''), '_', ''), '/', '')) LIKE ?",
+		)
+		args = append(args, "%"+symbolKey+"%")
+	}
+	if filter.FromUnix > 0 {
+		clauses = append(clauses, "snapshot_time >= ?")
+		args = append(args, filter.FromUnix)
+	}
+	if filter.ToUnix > 0 {
+		clauses = append(clauses, "snapshot_time <= ?")
+		args = append(args, filter.ToUnix)
+	}
+
+	rows, err := s.db.QueryContext(
+		ctx,
+		`
+		SELECT
+			exchange, symbol, snapshot_time, levels_json
+		FROM exchange_orderbook_snapshots
+		WHERE `+strings.Join(clauses, " AND ")+`
+		ORDER BY exchange ASC, snapshot_time ASC
+		LIMIT ? OFFSET ?
+		`,
+		append(append(args, limit), offset)...,
+	)
+	if err != nil {
+		return OrderbookVolumeProfile{}, err
+	}
+	defer rows.Close()
+
+	type snapshotRow struct {
+		snapshotTime int64
+		levels       []OrderbookHeatmapLevel
+	}
+	byExchange := make(map[string][]snapshotRow)
+
+	for rows.Next() {
+		var exchange, symbol, levelsJSON string
+		var snapshotTime int64
+		if err := rows.Scan(&exchange, &symbol, &snapshotTime, &levelsJSON); err != nil {
+			return OrderbookVolumeProfile{}, err
+		}
+		if symbolKey != "" && normalizeOrderbookSymbolKey(symbol) != symbolKey {
+			continue
+		}
+		if strings.TrimSpace(levelsJSON) == "" {
+			continue
+		}
+
+		var levels []OrderbookHeatmapLevel
+		if err := json.Unmarshal([]byte(levelsJSON), &levels); err != nil {
+			return OrderbookVolumeProfile{}, fmt.Errorf("decode levels_json for %s:%s@%d: %w", exchange, symbol, snapshotTime, err)
+		}
+
+		byExchange[exchange] = append(byExchange[exchange], snapshotRow{
+			snapshotTime: snapshotTime,
+			levels:       aggregateOrderbookLevels(levels),
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return OrderbookVolumeProfile{}, err
+	}
+
+	type bucketKey struct {
+		side  string
+		price float64
+	}
+	quantityByBucket := make(map[bucketKey]float64)
+	quantityByBucketExchange := make(map[bucketKey]map[string]float64)
+
+	for exchange, snapshots := range byExchange {
+		sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].snapshotTime < snapshots[j].snapshotTime })
+
+		for i, snapshot := range snapshots {
+			dwellSec := float64(defaultVolumeProfileDwellSec)
+			if i+1 < len(snapshots) {
+				if gap := snapshots[i+1].snapshotTime - snapshot.snapshotTime; gap > 0 {
+					dwellSec = float64(gap)
+				}
+			}
+
+			for _, level := range snapshot.levels {
+				price, err := strconv.ParseFloat(level.Price, 64)
+				if err != nil {
+					continue
+				}
+				quantity, err := strconv.ParseFloat(level.Quantity, 64)
+				if err != nil || quantity <= 0 {
+					continue
+				}
+
+				bucketPrice := math.Floor(price/filter.TickSize) * filter.TickSize
+				key := bucketKey{side: level.Side, price: bucketPrice}
+				weighted := quantity * dwellSec
+				quantityByBucket[key] += weighted
+
+				byExch, ok := quantityByBucketExchange[key]
+				if !ok {
+					byExch = make(map[string]float64)
+					quantityByBucketExchange[key] = byExch
+				}
+				byExch[exchange] += weighted
+			}
+		}
+	}
+
+	buckets := make([]OrderbookVolumeProfileBucket, 0, len(quantityByBucket))
+	combinedByPrice := make(map[float64]float64)
+	for key, quantity := range quantityByBucket {
+		shares := quantityByBucketExchange[key]
+		exchangeShares := make([]OrderbookVolumeProfileExchangeShare, 0, len(shares))
+		for exchange, exchangeQuantity := range shares {
+			exchangeShares = append(exchangeShares, OrderbookVolumeProfileExchangeShare{
+				Exchange: exchange,
+				Quantity: strconv.FormatFloat(exchangeQuantity, 'f', -1, 64),
+			})
+		}
+		sort.Slice(exchangeShares, func(i, j int) bool { return exchangeShares[i].Exchange < exchangeShares[j].Exchange })
+
+		buckets = append(buckets, OrderbookVolumeProfileBucket{
+			Price:      strconv.FormatFloat(key.price, 'f', -1, 64),
+			Side:       key.side,
+			Quantity:   strconv.FormatFloat(quantity, 'f', -1, 64),
+			ByExchange: exchangeShares,
+		})
+		combinedByPrice[key.price] += quantity
+	}
+
+	sort.Slice(buckets, func(i, j int) bool {
+		leftPrice, _ := strconv.ParseFloat(buckets[i].Price, 64)
+		rightPrice, _ := strconv.ParseFloat(buckets[j].Price, 64)
+		if leftPrice != rightPrice {
+			return leftPrice < rightPrice
+		}
+		return buckets[i].Side < buckets[j].Side
+	})
+
+	profile := OrderbookVolumeProfile{
+		SymbolKey: symbolKey,
+		FromUnix:  filter.FromUnix,
+		ToUnix:    filter.ToUnix,
+		TickSize:  filter.TickSize,
+		Buckets:   buckets,
+	}
+	if len(combinedByPrice) == 0 {
+		return profile, nil
+	}
+
+	pointOfControl, valueAreaHigh, valueAreaLow := volumeProfileValueArea(combinedByPrice, valueAreaPct)
+	profile.PointOfControl = strconv.FormatFloat(pointOfControl, 'f', -1, 64)
+	profile.ValueAreaHigh = strconv.FormatFloat(valueAreaHigh, 'f', -1, 64)
+	profile.ValueAreaLow = strconv.FormatFloat(valueAreaLow, 'f', -1, 64)
+	return profile, nil
+}
+
+// volumeProfileValueArea finds the point of control (the price bucket with
+// the most combined liquidity) and then grows a window of buckets outward
+// from it, always extending toward whichever side adds more liquidity next,
+// until the window holds at least valueAreaPct of the total - the standard
+// market-profile value-area construction.
+func volumeProfileValueArea(combinedByPrice map[float64]float64, valueAreaPct float64) (pointOfControl, high, low float64) {
+	prices := make([]float64, 0, len(combinedByPrice))
+	for price := range combinedByPrice {
+		prices = append(prices, price)
+	}
+	sort.Float64s(prices)
+
+	total := 0.0
+	pocIndex := 0
+	best := -1.0
+	for i, price := range prices {
+		quantity := combinedByPrice[price]
+		total += quantity
+		if quantity > best {
+			best = quantity
+			pocIndex = i
+		}
+	}
+	pointOfControl = prices[pocIndex]
+
+	target := total * valueAreaPct
+	loIndex, hiIndex := pocIndex, pocIndex
+	covered := combinedByPrice[prices[pocIndex]]
+	for covered < target && (loIndex > 0 || hiIndex < len(prices)-1) {
+		expandLow := loIndex > 0
+		expandHigh := hiIndex < len(prices)-1
+		lowGain := 0.0
+		if expandLow {
+			lowGain = combinedByPrice[prices[loIndex-1]]
+		}
+		highGain := 0.0
+		if expandHigh {
+			highGain = combinedByPrice[prices[hiIndex+1]]
+		}
+
+		if expandHigh && (!expandLow || highGain >= lowGain) {
+			hiIndex++
+			covered += highGain
+		} else if expandLow {
+			loIndex--
+			covered += lowGain
+		} else {
+			break
+		}
+	}
+
+	return pointOfControl, prices[hiIndex], prices[loIndex]
+}
@@ -2,11 +2,15 @@ package indexer
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"math"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
@@ -25,8 +29,41 @@ const (
 var (
 	ErrNotFound     = errors.New("not found")
 	ErrUnauthorized = errors.New("unauthorized")
+	// ErrPreconditionFailed is returned by a fingerprint-guarded patch (or
+	// DoLockedStrategyEdit) when the caller's expected fingerprint doesn't
+	// match the record's current one, i.e. it was modified since the caller
+	// last read it.
+	ErrPreconditionFailed = errors.New("precondition failed")
 )
 
+// txLike is the subset of *DB and *Tx a patch needs: read-then-write within
+// whichever one the caller is already inside, so the same fetch/update code
+// works both standalone (via *DB) and under a transaction (via *Tx).
+type txLike interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// computeFingerprint hashes the canonical (field-order-stable) JSON
+// encoding of v, for optimistic-concurrency checks on PATCH endpoints. v is
+// always one of this package's own record types, which can't fail to
+// marshal, so a failure here is a bug in that type, not caller input.
+func computeFingerprint(v any) string {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("compute fingerprint: %v", err))
+	}
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// advisoryLockKey derives a stable bigint key for pg_advisory_xact_lock
+// from an arbitrary record id.
+func advisoryLockKey(id string) int64 {
+	sum := sha256.Sum256([]byte(id))
+	return int64(binary.BigEndian.Uint64(sum[:8]))
+}
+
 type AuthChallengeRecord struct {
 	ID           string
 	WalletPubkey string
@@ -35,6 +72,17 @@ type AuthChallengeRecord struct {
 	CreatedAt    int64
 	ExpiresAt    int64
 	UsedAt       *int64
+
+	// Version distinguishes the legacy free-form challenge message ("" or
+	// "v1") from the CAIP-122/SIWS-style structured message ("siws1"). The
+	// fields below are only populated for the latter, since verification
+	// needs to cross-check the signed-back message against what the server
+	// actually issued rather than trusting the client's echo alone.
+	Version   string
+	Domain    string
+	Nonce     string
+	ChainID   string
+	RequestID string
 }
 
 type AuthSessionRecord struct {
@@ -50,6 +98,11 @@ type AgentRiskProfile struct {
 	MaxPositionUSDC    float64 `json:"max_position_usdc"`
 	DailyLossLimitUSDC float64 `json:"daily_loss_limit_usdc"`
 	KillSwitchEnabled  bool    `json:"kill_switch_enabled"`
+	// AccountingMode selects the PnLEngine (weighted_average, fifo, lifo,
+	// hifo) used to realize this agent's fills into TradeRecords.
+	// Empty/unknown values normalize to weighted_average via
+	// normalizeAccountingMode.
+	AccountingMode string `json:"accounting_mode"`
 }
 
 type AgentRecord struct {
@@ -122,13 +175,22 @@ type StrategyPatch struct {
 	EntryRules *map[string]any
 	ExitRules  *map[string]any
 	UpdatedAt  int64
+	// ExpectedFingerprint, if non-empty, must match the strategy's current
+	// fingerprint (see computeFingerprint) or PatchStrategy returns
+	// ErrPreconditionFailed instead of applying the patch.
+	ExpectedFingerprint string
 }
 
 type RiskPatch struct {
 	MaxPositionUSDC    *float64
 	DailyLossLimitUSDC *float64
 	KillSwitchEnabled  *bool
+	AccountingMode     *string
 	UpdatedAt          int64
+	// ExpectedFingerprint, if non-empty, must match the risk profile's
+	// current fingerprint or PatchAgentRisk returns ErrPreconditionFailed
+	// instead of applying the patch.
+	ExpectedFingerprint string
 }
 
 type TradeFilter struct {
@@ -153,6 +215,29 @@ type TradeRecord struct {
 	EntryTime  int64   `json:"entry_time"`
 	ExitTime   int64   `json:"exit_time"`
 	MarketID   uint64  `json:"market_id"`
+
+	// GrossPnl, Fees, and NetPnl break Pnl's fee deduction out into its own
+	// field instead of leaving it implicit: GrossPnl is the raw price
+	// difference, Fees is what buildTradeRecord charged against it (now
+	// schedule-driven via feeRateFor rather than a flat rate), and NetPnl
+	// equals Pnl (kept as its own field for symmetry with GrossPnl/Fees
+	// rather than making callers rediscover Pnl - Fees == GrossPnl). Funding
+	// isn't broken out per trade: Store.GetFundingPnLByAgent already
+	// attributes funding to an agent over a window, not to individual
+	// fills, and this schema has no way to split it onto one TradeRecord
+	// without inventing an attribution rule the funding ledger doesn't
+	// support today.
+	GrossPnl float64 `json:"gross_pnl"`
+	Fees     float64 `json:"fees"`
+	NetPnl   float64 `json:"net_pnl"`
+
+	// FeeAsset, IsMaker, and FeeTier record what buildTradeRecord's
+	// FeeModel actually charged this fill under, so a report can show which
+	// VIP tier (if any) applied and whether the fill was charged the maker
+	// or taker side of a schedule, not just the resulting Fees amount.
+	FeeAsset string `json:"fee_asset"`
+	IsMaker  bool   `json:"is_maker"`
+	FeeTier  string `json:"fee_tier,omitempty"`
 }
 
 type EquityPoint struct {
@@ -174,16 +259,28 @@ type PortfolioAgentSummary struct {
 	TotalTrades int     `json:"total_trades"`
 	Drawdown    float64 `json:"drawdown"`
 	Equity      float64 `json:"equity"`
+	FundingPnl  float64 `json:"funding_pnl"`
+	AvgWin      float64 `json:"avg_win"`
+	AvgLoss     float64 `json:"avg_loss"`
+	Expectancy  float64 `json:"expectancy"`
+	Fees        float64 `json:"fees"`
 }
 
 type PortfolioSummary struct {
-	TotalEquity   float64                 `json:"total_equity"`
-	TotalPNL      float64                 `json:"total_pnl"`
-	TotalPNLPct   float64                 `json:"total_pnl_pct"`
-	MaxDrawdown   float64                 `json:"max_drawdown"`
-	ActiveAgents  int                     `json:"active_agents"`
-	EquityHistory []EquityPoint           `json:"equity_history"`
-	Agents        []PortfolioAgentSummary `json:"agents"`
+	TotalEquity     float64                 `json:"total_equity"`
+	TotalPNL        float64                 `json:"total_pnl"`
+	TotalPNLPct     float64                 `json:"total_pnl_pct"`
+	MaxDrawdown     float64                 `json:"max_drawdown"`
+	ActiveAgents    int                     `json:"active_agents"`
+	EquityHistory   []EquityPoint           `json:"equity_history"`
+	Agents          []PortfolioAgentSummary `json:"agents"`
+	TotalFundingPnl float64                 `json:"total_funding_pnl"`
+	TotalFees       float64                 `json:"total_fees"`
+	// RiskMetricsByWindow holds Sharpe/Sortino/Calmar/CVaR for each of
+	// riskMetricsWindows ("7d", "30d", "90d", "all"), computed from
+	// EquityHistory regardless of which period the summary itself was
+	// requested for.
+	RiskMetricsByWindow map[string]RiskMetrics `json:"risk_metrics_by_window"`
 }
 
 type AgentPortfolioSummary struct {
@@ -194,18 +291,46 @@ type AgentPortfolioSummary struct {
 	Drawdown      float64         `json:"drawdown"`
 	DailyPNL      []DailyPNLPoint `json:"daily_pnl"`
 	EquityHistory []EquityPoint   `json:"equity_history"`
+	FundingPnl    float64         `json:"funding_pnl"`
+	AvgWin        float64         `json:"avg_win"`
+	AvgLoss       float64         `json:"avg_loss"`
+	Expectancy    float64         `json:"expectancy"`
+	Fees          float64         `json:"fees"`
+	// RiskMetricsByWindow holds Sharpe/Sortino/Calmar/CVaR for each of
+	// riskMetricsWindows ("7d", "30d", "90d", "all"), computed from
+	// EquityHistory regardless of which period the summary itself was
+	// requested for.
+	RiskMetricsByWindow map[string]RiskMetrics `json:"risk_metrics_by_window"`
 }
 
 type LeaderboardItem struct {
-	Rank        int       `json:"rank"`
-	RankChange  int       `json:"rank_change"`
-	AgentID     string    `json:"agent_id"`
-	AgentName   string    `json:"agent_name"`
-	WinRate     float64   `json:"win_rate"`
-	PnlPct      float64   `json:"pnl_pct"`
-	TotalTrades int       `json:"total_trades"`
-	MaxDrawdown float64   `json:"max_drawdown"`
-	Sparkline   []float64 `json:"sparkline"`
+	Rank         int       `json:"rank"`
+	RankChange   int       `json:"rank_change"`
+	AgentID      string    `json:"agent_id"`
+	AgentName    string    `json:"agent_name"`
+	WinRate      float64   `json:"win_rate"`
+	PnlPct       float64   `json:"pnl_pct"`
+	TotalTrades  int       `json:"total_trades"`
+	MaxDrawdown  float64   `json:"max_drawdown"`
+	Sparkline    []float64 `json:"sparkline"`
+	Sharpe       float64   `json:"sharpe"`
+	Sortino      float64   `json:"sortino"`
+	Calmar       float64   `json:"calmar"`
+	ProfitFactor float64   `json:"profit_factor"`
+	AvgHoldSec   float64   `json:"avg_hold_sec"`
+	AvgWin       float64   `json:"avg_win"`
+	AvgLoss      float64   `json:"avg_loss"`
+	Expectancy   float64   `json:"expectancy"`
+	Score        float64   `json:"score"`
+	// RiskMetrics are the annualized, equity-curve-based counterparts to
+	// Sharpe/Sortino/Calmar above (which are simpler per-trade-return
+	// ratios); users can sort/filter on either.
+	RiskMetrics RiskMetrics `json:"risk_metrics"`
+	// MaxDrawdownDurationSec is how long MaxDrawdown's episode lasted,
+	// peak to recovery (or to the window's last trade, if unrecovered).
+	MaxDrawdownDurationSec int64 `json:"max_drawdown_duration_sec"`
+	LongestWinStreak       int   `json:"longest_win_streak"`
+	LongestLossStreak      int   `json:"longest_loss_streak"`
 }
 
 type SystemStatusRecord struct {
@@ -222,6 +347,44 @@ type CandleRecord struct {
 	Low    float64 `json:"low"`
 	Close  float64 `json:"close"`
 	Volume float64 `json:"volume"`
+
+	// VWAP, TWAP, and TypicalPrice are only populated when requested via
+	// GetMarketCandles' aggregations parameter; they're left zero
+	// otherwise rather than computed and discarded.
+	VWAP         float64 `json:"vwap"`
+	TWAP         float64 `json:"twap"`
+	TypicalPrice float64 `json:"typical_price"`
+}
+
+// ToHeikinAshi restates candles (sorted oldest-first, as both GetCandles and
+// GetMarketCandles return them) as Heikin-Ashi bars, which smooth noise by
+// blending each bar into the running HA state instead of reporting its own
+// raw OHLC. Volume carries through unchanged; VWAP/TWAP/TypicalPrice are
+// left zero on the output the same way GetMarketCandles leaves them zero
+// when they weren't requested, since none of the three mean the same thing
+// once price has been restated.
+func ToHeikinAshi(candles []CandleRecord) []CandleRecord {
+	out := make([]CandleRecord, len(candles))
+	var prevOpen, prevClose float64
+	for i, candle := range candles {
+		haClose := (candle.Open + candle.High + candle.Low + candle.Close) / 4
+		haOpen := (prevOpen + prevClose) / 2
+		if i == 0 {
+			haOpen = (candle.Open + candle.Close) / 2
+		}
+		haHigh := math.Max(candle.High, math.Max(haOpen, haClose))
+		haLow := math.Min(candle.Low, math.Min(haOpen, haClose))
+		out[i] = CandleRecord{
+			TS:     candle.TS,
+			Open:   round2(haOpen),
+			High:   round2(haHigh),
+			Low:    round2(haLow),
+			Close:  round2(haClose),
+			Volume: candle.Volume,
+		}
+		prevOpen, prevClose = haOpen, haClose
+	}
+	return out
 }
 
 type tradeEvent struct {
@@ -256,6 +419,24 @@ type agentComputed struct {
 	DailyPNL      []DailyPNLPoint
 	EquityHistory []EquityPoint
 	Sparkline     []float64
+	Sharpe        float64
+	Sortino       float64
+	Calmar        float64
+	ProfitFactor  float64
+	AvgHoldSec    float64
+	AvgWin        float64
+	AvgLoss       float64
+	Expectancy    float64
+	Fees          float64
+	// DrawdownDurationSec is how long the Drawdown episode lasted: from
+	// the peak equity that preceded it to the point equity recovered back
+	// to that peak, or to the last trade's ExitTime if it never recovered
+	// within the computed window.
+	DrawdownDurationSec int64
+	// LongestWinStreak/LongestLossStreak are the longest runs of
+	// consecutive winning/losing trades in ExitTime order.
+	LongestWinStreak  int
+	LongestLossStreak int
 }
 
 func defaultRiskProfile() AgentRiskProfile {
@@ -263,20 +444,26 @@ func defaultRiskProfile() AgentRiskProfile {
 		MaxPositionUSDC:    5000,
 		DailyLossLimitUSDC: 500,
 		KillSwitchEnabled:  true,
+		AccountingMode:     string(AccountingWeightedAverage),
 	}
 }
 
 func (s *Store) CreateAuthChallenge(ctx context.Context, challenge AuthChallengeRecord) error {
 	_, err := s.db.ExecContext(
 		ctx,
-		`INSERT INTO auth_challenges (id, wallet_pubkey, intent, message, created_at, expires_at)
-		 VALUES (?, ?, ?, ?, ?, ?)
+		`INSERT INTO auth_challenges (id, wallet_pubkey, intent, message, created_at, expires_at, version, domain, nonce, chain_id, request_id)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		 ON CONFLICT(id) DO UPDATE SET
 		   wallet_pubkey = excluded.wallet_pubkey,
 		   intent = excluded.intent,
 		   message = excluded.message,
 		   created_at = excluded.created_at,
 		   expires_at = excluded.expires_at,
+		   version = excluded.version,
+		   domain = excluded.domain,
+		   nonce = excluded.nonce,
+		   chain_id = excluded.chain_id,
+		   request_id = excluded.request_id,
 		   used_at = NULL`,
 		challenge.ID,
 		challenge.WalletPubkey,
@@ -284,14 +471,23 @@ func (s *Store) CreateAuthChallenge(ctx context.Context, challenge AuthChallenge
 		challenge.Message,
 		challenge.CreatedAt,
 		challenge.ExpiresAt,
+		challenge.Version,
+		challenge.Domain,
+		challenge.Nonce,
+		challenge.ChainID,
+		challenge.RequestID,
 	)
 	return err
 }
 
 func (s *Store) GetAuthChallenge(ctx context.Context, challengeID string) (AuthChallengeRecord, error) {
-	row := s.db.QueryRowContext(
+	return fetchAuthChallenge(ctx, s.db, challengeID)
+}
+
+func fetchAuthChallenge(ctx context.Context, q txLike, challengeID string) (AuthChallengeRecord, error) {
+	row := q.QueryRowContext(
 		ctx,
-		`SELECT id, wallet_pubkey, intent, message, created_at, expires_at, used_at
+		`SELECT id, wallet_pubkey, intent, message, created_at, expires_at, used_at, version, domain, nonce, chain_id, request_id
 		 FROM auth_challenges
 		 WHERE id = ?`,
 		challengeID,
@@ -306,6 +502,11 @@ func (s *Store) GetAuthChallenge(ctx context.Context, challengeID string) (AuthC
 		&out.CreatedAt,
 		&out.ExpiresAt,
 		&usedAt,
+		&out.Version,
+		&out.Domain,
+		&out.Nonce,
+		&out.ChainID,
+		&out.RequestID,
 	); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return AuthChallengeRecord{}, ErrNotFound
@@ -358,6 +559,81 @@ func (s *Store) CreateAuthSession(ctx context.Context, tokenHash, walletPubkey s
 	return err
 }
 
+// FinalizeAuthChallenge marks challengeID used and creates its session in a
+// single transaction, so a crash or error between the two (previously two
+// separate Store calls made back to back by the caller) can no longer burn
+// a challenge without ever issuing its session. Signature/SIWS-field
+// verification still happens in the caller before this is reached - this
+// only closes the gap between the two writes, it doesn't re-verify
+// anything itself, so the caller must have already confirmed the signature
+// is valid for walletPubkey.
+func (s *Store) FinalizeAuthChallenge(ctx context.Context, challengeID, walletPubkey, tokenHash string, now, sessionExpiresAt int64) (AuthSessionRecord, error) {
+	var out AuthSessionRecord
+	err := s.WithTx(ctx, func(tx *Tx) error {
+		challenge, err := fetchAuthChallenge(ctx, tx, challengeID)
+		if err != nil {
+			return err
+		}
+		if challenge.UsedAt != nil {
+			return ErrUnauthorized
+		}
+		if challenge.ExpiresAt <= now {
+			return ErrUnauthorized
+		}
+		if challenge.WalletPubkey != walletPubkey {
+			return ErrUnauthorized
+		}
+
+		result, err := tx.ExecContext(
+			ctx,
+			`UPDATE auth_challenges
+			 SET used_at = ?
+			 WHERE id = ?
+			   AND used_at IS NULL`,
+			now,
+			challengeID,
+		)
+		if err != nil {
+			return err
+		}
+		if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+			return ErrUnauthorized
+		}
+
+		if _, err := tx.ExecContext(
+			ctx,
+			`INSERT INTO auth_sessions (token_hash, wallet_pubkey, created_at, expires_at, refreshed_at)
+			 VALUES (?, ?, ?, ?, ?)
+			 ON CONFLICT(token_hash) DO UPDATE SET
+			   wallet_pubkey = excluded.wallet_pubkey,
+			   created_at = excluded.created_at,
+			   expires_at = excluded.expires_at,
+			   refreshed_at = excluded.refreshed_at,
+			   revoked_at = NULL`,
+			tokenHash,
+			walletPubkey,
+			now,
+			sessionExpiresAt,
+			now,
+		); err != nil {
+			return err
+		}
+
+		out = AuthSessionRecord{
+			TokenHash:    tokenHash,
+			WalletPubkey: walletPubkey,
+			CreatedAt:    now,
+			ExpiresAt:    sessionExpiresAt,
+			RefreshedAt:  now,
+		}
+		return nil
+	})
+	if err != nil {
+		return AuthSessionRecord{}, err
+	}
+	return out, nil
+}
+
 func (s *Store) GetAuthSession(ctx context.Context, tokenHash string) (AuthSessionRecord, error) {
 	row := s.db.QueryRowContext(
 		ctx,
@@ -765,7 +1041,18 @@ func (s *Store) KillSwitch(ctx context.Context, allAgents bool, agentIDs []strin
 }
 
 func (s *Store) GetAgentRisk(ctx context.Context, agentID string) (AgentRiskProfile, error) {
-	row := s.db.QueryRowContext(ctx, `SELECT risk_profile_json FROM agents WHERE id = ?`, agentID)
+	return fetchAgentRisk(ctx, s.db, agentID)
+}
+
+// AgentRiskFingerprint exposes the fingerprint GetAgentRisk's result would
+// be checked against on a subsequent PatchAgentRisk, for handlers that want
+// to surface it (e.g. as an ETag header) without recomputing the formula.
+func AgentRiskFingerprint(profile AgentRiskProfile) string {
+	return computeFingerprint(profile)
+}
+
+func fetchAgentRisk(ctx context.Context, q txLike, agentID string) (AgentRiskProfile, error) {
+	row := q.QueryRowContext(ctx, `SELECT risk_profile_json FROM agents WHERE id = ?`, agentID)
 	var riskRaw string
 	if err := row.Scan(&riskRaw); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -777,41 +1064,55 @@ func (s *Store) GetAgentRisk(ctx context.Context, agentID string) (AgentRiskProf
 }
 
 func (s *Store) PatchAgentRisk(ctx context.Context, agentID string, patch RiskPatch) (AgentRiskProfile, error) {
-	current, err := s.GetAgentRisk(ctx, agentID)
-	if err != nil {
-		return AgentRiskProfile{}, err
-	}
-	if patch.MaxPositionUSDC != nil {
-		current.MaxPositionUSDC = *patch.MaxPositionUSDC
-	}
-	if patch.DailyLossLimitUSDC != nil {
-		current.DailyLossLimitUSDC = *patch.DailyLossLimitUSDC
-	}
-	if patch.KillSwitchEnabled != nil {
-		current.KillSwitchEnabled = *patch.KillSwitchEnabled
-	}
+	var out AgentRiskProfile
+	err := s.WithTx(ctx, func(tx *Tx) error {
+		current, err := fetchAgentRisk(ctx, tx, agentID)
+		if err != nil {
+			return err
+		}
+		if patch.ExpectedFingerprint != "" && computeFingerprint(current) != patch.ExpectedFingerprint {
+			return ErrPreconditionFailed
+		}
+		if patch.MaxPositionUSDC != nil {
+			current.MaxPositionUSDC = *patch.MaxPositionUSDC
+		}
+		if patch.DailyLossLimitUSDC != nil {
+			current.DailyLossLimitUSDC = *patch.DailyLossLimitUSDC
+		}
+		if patch.KillSwitchEnabled != nil {
+			current.KillSwitchEnabled = *patch.KillSwitchEnabled
+		}
+		if patch.AccountingMode != nil {
+			current.AccountingMode = string(normalizeAccountingMode(*patch.AccountingMode))
+		}
 
-	riskJSON, err := json.Marshal(current)
-	if err != nil {
-		return AgentRiskProfile{}, err
-	}
-	result, err := s.db.ExecContext(
-		ctx,
-		`UPDATE agents
-		 SET risk_profile_json = ?, updated_at = ?
-		 WHERE id = ?`,
-		string(riskJSON),
-		patch.UpdatedAt,
-		agentID,
-	)
+		riskJSON, err := json.Marshal(current)
+		if err != nil {
+			return err
+		}
+		result, err := tx.ExecContext(
+			ctx,
+			`UPDATE agents
+			 SET risk_profile_json = ?, updated_at = ?
+			 WHERE id = ?`,
+			string(riskJSON),
+			patch.UpdatedAt,
+			agentID,
+		)
+		if err != nil {
+			return err
+		}
+		affected, err := result.RowsAffected()
+		if err == nil && affected == 0 {
+			return ErrNotFound
+		}
+		out = current
+		return nil
+	})
 	if err != nil {
 		return AgentRiskProfile{}, err
 	}
-	affected, err := result.RowsAffected()
-	if err == nil && affected == 0 {
-		return AgentRiskProfile{}, ErrNotFound
-	}
-	return current, nil
+	return out, nil
 }
 
 func (s *Store) ListStrategyTemplates(ctx context.Context) ([]StrategyTemplateRecord, error) {
@@ -849,6 +1150,13 @@ func (s *Store) ListStrategyTemplates(ctx context.Context) ([]StrategyTemplateRe
 }
 
 func (s *Store) CreateStrategy(ctx context.Context, input CreateStrategyInput) (string, error) {
+	if err := ValidateStrategyRules(input.EntryRules); err != nil {
+		return "", fmt.Errorf("entry_rules: %w", err)
+	}
+	if err := ValidateStrategyRules(input.ExitRules); err != nil {
+		return "", fmt.Errorf("exit_rules: %w", err)
+	}
+
 	entryJSON, err := json.Marshal(input.EntryRules)
 	if err != nil {
 		return "", err
@@ -887,7 +1195,18 @@ func (s *Store) CreateStrategy(ctx context.Context, input CreateStrategyInput) (
 }
 
 func (s *Store) GetStrategy(ctx context.Context, strategyID string) (StrategyRecord, error) {
-	row := s.db.QueryRowContext(
+	return fetchStrategy(ctx, s.db, strategyID)
+}
+
+// StrategyFingerprint exposes the fingerprint GetStrategy's result would be
+// checked against on a subsequent PatchStrategy, for handlers that want to
+// surface it (e.g. as an ETag header) without recomputing the formula.
+func StrategyFingerprint(record StrategyRecord) string {
+	return computeFingerprint(record)
+}
+
+func fetchStrategy(ctx context.Context, q txLike, strategyID string) (StrategyRecord, error) {
+	row := q.QueryRowContext(
 		ctx,
 		`SELECT id, name, entry_rules_json, exit_rules_json, risk_defaults_json,
 		        owner_pubkey, is_published, published_at, created_at, updated_at
@@ -930,49 +1249,126 @@ func (s *Store) GetStrategy(ctx context.Context, strategyID string) (StrategyRec
 }
 
 func (s *Store) PatchStrategy(ctx context.Context, strategyID string, patch StrategyPatch) (StrategyRecord, error) {
-	current, err := s.GetStrategy(ctx, strategyID)
-	if err != nil {
-		return StrategyRecord{}, err
-	}
+	var out StrategyRecord
+	err := s.WithTx(ctx, func(tx *Tx) error {
+		current, err := fetchStrategy(ctx, tx, strategyID)
+		if err != nil {
+			return err
+		}
+		if patch.ExpectedFingerprint != "" && computeFingerprint(current) != patch.ExpectedFingerprint {
+			return ErrPreconditionFailed
+		}
 
-	if patch.Name != nil {
-		current.Name = strings.TrimSpace(*patch.Name)
-	}
-	if patch.EntryRules != nil {
-		current.EntryRules = *patch.EntryRules
-	}
-	if patch.ExitRules != nil {
-		current.ExitRules = *patch.ExitRules
-	}
+		if patch.Name != nil {
+			current.Name = strings.TrimSpace(*patch.Name)
+		}
+		if patch.EntryRules != nil {
+			current.EntryRules = *patch.EntryRules
+		}
+		if patch.ExitRules != nil {
+			current.ExitRules = *patch.ExitRules
+		}
+		if err := ValidateStrategyRules(current.EntryRules); err != nil {
+			return fmt.Errorf("entry_rules: %w", err)
+		}
+		if err := ValidateStrategyRules(current.ExitRules); err != nil {
+			return fmt.Errorf("exit_rules: %w", err)
+		}
 
-	entryJSON, err := json.Marshal(current.EntryRules)
-	if err != nil {
-		return StrategyRecord{}, err
-	}
-	exitJSON, err := json.Marshal(current.ExitRules)
+		entryJSON, err := json.Marshal(current.EntryRules)
+		if err != nil {
+			return err
+		}
+		exitJSON, err := json.Marshal(current.ExitRules)
+		if err != nil {
+			return err
+		}
+
+		result, err := tx.ExecContext(
+			ctx,
+			`UPDATE strategies
+			 SET name = ?, entry_rules_json = ?, exit_rules_json = ?, updated_at = ?
+			 WHERE id = ?`,
+			current.Name,
+			string(entryJSON),
+			string(exitJSON),
+			patch.UpdatedAt,
+			strategyID,
+		)
+		if err != nil {
+			return err
+		}
+		affected, err := result.RowsAffected()
+		if err == nil && affected == 0 {
+			return ErrNotFound
+		}
+		out = current
+		return nil
+	})
 	if err != nil {
 		return StrategyRecord{}, err
 	}
+	return out, nil
+}
 
-	result, err := s.db.ExecContext(
-		ctx,
-		`UPDATE strategies
-		 SET name = ?, entry_rules_json = ?, exit_rules_json = ?, updated_at = ?
-		 WHERE id = ?`,
-		current.Name,
-		string(entryJSON),
-		string(exitJSON),
-		patch.UpdatedAt,
-		strategyID,
-	)
+// DoLockedStrategyEdit runs mutate against the current strategy record under
+// a Postgres advisory lock scoped to strategyID, so two racing editors
+// serialize instead of one losing a fingerprint check and having to retry.
+// If expectedFingerprint is non-empty it's checked the same way PatchStrategy
+// checks StrategyPatch.ExpectedFingerprint.
+func (s *Store) DoLockedStrategyEdit(ctx context.Context, strategyID, expectedFingerprint string, mutate func(StrategyRecord) (StrategyRecord, error)) (StrategyRecord, error) {
+	var out StrategyRecord
+	err := s.WithTx(ctx, func(tx *Tx) error {
+		if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(?)`, advisoryLockKey(strategyID)); err != nil {
+			return err
+		}
+
+		current, err := fetchStrategy(ctx, tx, strategyID)
+		if err != nil {
+			return err
+		}
+		if expectedFingerprint != "" && computeFingerprint(current) != expectedFingerprint {
+			return ErrPreconditionFailed
+		}
+
+		updated, err := mutate(current)
+		if err != nil {
+			return err
+		}
+
+		entryJSON, err := json.Marshal(updated.EntryRules)
+		if err != nil {
+			return err
+		}
+		exitJSON, err := json.Marshal(updated.ExitRules)
+		if err != nil {
+			return err
+		}
+		result, err := tx.ExecContext(
+			ctx,
+			`UPDATE strategies
+			 SET name = ?, entry_rules_json = ?, exit_rules_json = ?, updated_at = ?
+			 WHERE id = ?`,
+			updated.Name,
+			string(entryJSON),
+			string(exitJSON),
+			updated.UpdatedAt,
+			strategyID,
+		)
+		if err != nil {
+			return err
+		}
+		affected, err := result.RowsAffected()
+		if err == nil && affected == 0 {
+			return ErrNotFound
+		}
+		out, err = fetchStrategy(ctx, tx, strategyID)
+		return err
+	})
 	if err != nil {
 		return StrategyRecord{}, err
 	}
-	affected, err := result.RowsAffected()
-	if err == nil && affected == 0 {
-		return StrategyRecord{}, ErrNotFound
-	}
-	return s.GetStrategy(ctx, strategyID)
+	return out, nil
 }
 
 func (s *Store) PublishStrategy(ctx context.Context, strategyID string, publishedAt int64) (int64, error) {
@@ -1002,7 +1398,20 @@ func (s *Store) ListTrades(ctx context.Context, filter TradeFilter) ([]TradeReco
 		return nil, 0, 0, err
 	}
 
-	trades := computeTrades(events)
+	modes, err := s.agentAccountingModes(ctx)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	feeModel, err := s.loadFeeModel(ctx, filter.ToUnix)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	trades, _ := computeTradesAndLots(events, func(agentID string) AccountingMode {
+		if mode, ok := modes[agentID]; ok {
+			return mode
+		}
+		return AccountingWeightedAverage
+	}, feeModel)
 	sort.SliceStable(trades, func(i, j int) bool {
 		if trades[i].ExitTime == trades[j].ExitTime {
 			return trades[i].ID > trades[j].ID
@@ -1041,16 +1450,26 @@ func (s *Store) GetPortfolioSummary(ctx context.Context, period string) (Portfol
 		return PortfolioSummary{}, err
 	}
 
+	fundingPnlByAgent, err := s.GetFundingPnLByAgent(ctx, startUnix)
+	if err != nil {
+		return PortfolioSummary{}, err
+	}
+
 	activeAgents := 0
 	agentSummaries := make([]PortfolioAgentSummary, 0, len(computed))
 	totalPNL := 0.0
 	totalEquity := 0.0
+	totalFundingPnl := 0.0
+	totalFees := 0.0
 	for _, item := range computed {
 		if item.Agent.Status == "paper" || item.Agent.Status == "live" {
 			activeAgents++
 		}
 		totalPNL += item.PnlAbs
 		totalEquity += item.Equity
+		totalFees += item.Fees
+		fundingPnl := fundingPnlByAgent[item.Agent.ID]
+		totalFundingPnl += fundingPnl
 		agentSummaries = append(agentSummaries, PortfolioAgentSummary{
 			ID:          item.Agent.ID,
 			Name:        item.Agent.Name,
@@ -1060,6 +1479,11 @@ func (s *Store) GetPortfolioSummary(ctx context.Context, period string) (Portfol
 			TotalTrades: item.TotalTrades,
 			Drawdown:    round2(item.Drawdown),
 			Equity:      round2(item.Equity),
+			FundingPnl:  round2(fundingPnl),
+			AvgWin:      round2(item.AvgWin),
+			AvgLoss:     round2(item.AvgLoss),
+			Expectancy:  round2(item.Expectancy),
+			Fees:        round2(item.Fees),
 		})
 	}
 
@@ -1092,18 +1516,23 @@ func (s *Store) GetPortfolioSummary(ctx context.Context, period string) (Portfol
 		}
 	}
 
+	riskMetricsByWindow := roundRiskMetricsByWindow(computeRiskMetricsByWindow(portfolioHistory))
+
 	for i := range portfolioHistory {
 		portfolioHistory[i].Value = round2(portfolioHistory[i].Value)
 	}
 
 	return PortfolioSummary{
-		TotalEquity:   round2(totalEquity),
-		TotalPNL:      round2(totalPNL),
-		TotalPNLPct:   round2(totalPNLPct),
-		MaxDrawdown:   round2(maxDrawdown),
-		ActiveAgents:  activeAgents,
-		EquityHistory: portfolioHistory,
-		Agents:        agentSummaries,
+		TotalEquity:         round2(totalEquity),
+		TotalPNL:            round2(totalPNL),
+		TotalPNLPct:         round2(totalPNLPct),
+		MaxDrawdown:         round2(maxDrawdown),
+		ActiveAgents:        activeAgents,
+		EquityHistory:       portfolioHistory,
+		Agents:              agentSummaries,
+		TotalFundingPnl:     round2(totalFundingPnl),
+		TotalFees:           round2(totalFees),
+		RiskMetricsByWindow: riskMetricsByWindow,
 	}, nil
 }
 
@@ -1124,17 +1553,19 @@ func (s *Store) GetAgentPortfolioSummary(ctx context.Context, agentID, period st
 	}
 	if len(computed) == 0 {
 		return AgentPortfolioSummary{
-			PnlPct:        0,
-			PnlAbs:        0,
-			WinRate:       0,
-			TotalTrades:   0,
-			Drawdown:      0,
-			DailyPNL:      []DailyPNLPoint{},
-			EquityHistory: []EquityPoint{},
+			PnlPct:              0,
+			PnlAbs:              0,
+			WinRate:             0,
+			TotalTrades:         0,
+			Drawdown:            0,
+			DailyPNL:            []DailyPNLPoint{},
+			EquityHistory:       []EquityPoint{},
+			RiskMetricsByWindow: roundRiskMetricsByWindow(computeRiskMetricsByWindow(nil)),
 		}, nil
 	}
 
 	entry := computed[0]
+	riskMetricsByWindow := roundRiskMetricsByWindow(computeRiskMetricsByWindow(entry.EquityHistory))
 	for i := range entry.EquityHistory {
 		entry.EquityHistory[i].Value = round2(entry.EquityHistory[i].Value)
 	}
@@ -1142,61 +1573,107 @@ func (s *Store) GetAgentPortfolioSummary(ctx context.Context, agentID, period st
 		entry.DailyPNL[i].PNL = round2(entry.DailyPNL[i].PNL)
 	}
 
+	fundingPnl, err := s.GetAgentFundingPnL(ctx, agentID, startUnix)
+	if err != nil {
+		return AgentPortfolioSummary{}, err
+	}
+
 	return AgentPortfolioSummary{
-		PnlPct:        round2(entry.PnlPct),
-		PnlAbs:        round2(entry.PnlAbs),
-		WinRate:       round2(entry.WinRate),
-		TotalTrades:   entry.TotalTrades,
-		Drawdown:      round2(entry.Drawdown),
-		DailyPNL:      entry.DailyPNL,
-		EquityHistory: entry.EquityHistory,
+		PnlPct:              round2(entry.PnlPct),
+		PnlAbs:              round2(entry.PnlAbs),
+		WinRate:             round2(entry.WinRate),
+		TotalTrades:         entry.TotalTrades,
+		Drawdown:            round2(entry.Drawdown),
+		DailyPNL:            entry.DailyPNL,
+		RiskMetricsByWindow: riskMetricsByWindow,
+		EquityHistory:       entry.EquityHistory,
+		FundingPnl:          round2(fundingPnl),
+		AvgWin:              round2(entry.AvgWin),
+		AvgLoss:             round2(entry.AvgLoss),
+		Expectancy:          round2(entry.Expectancy),
+		Fees:                round2(entry.Fees),
 	}, nil
 }
 
-func (s *Store) GetLeaderboard(ctx context.Context, metric, period string, minTrades int) ([]LeaderboardItem, error) {
+// GetLeaderboard ranks agents by filter.Metric (or a weighted composite
+// "score") over filter.Period, caching results per distinct filter for
+// leaderboardCacheTTL and persisting each ranked agent's latest metrics
+// to agent_metrics so other callers can read rolling stats without
+// recomputing computePortfolio. There is no trade-ingest pipeline hook
+// in this package to refresh agent_metrics eagerly, so it is refreshed
+// lazily instead, as a side effect of the next leaderboard computed here.
+func (s *Store) GetLeaderboard(ctx context.Context, filter LeaderboardFilter) (LeaderboardResult, error) {
+	metric := strings.ToLower(strings.TrimSpace(filter.Metric))
+	if metric == "" {
+		metric = "pnl_pct"
+	}
+	if !leaderboardMetrics[metric] {
+		return LeaderboardResult{}, fmt.Errorf("invalid metric")
+	}
+	if metric == "score" && len(filter.Weights) == 0 {
+		return LeaderboardResult{}, fmt.Errorf("invalid metric: score requires weights")
+	}
+	minTrades := filter.MinTrades
 	if minTrades < 0 {
 		minTrades = 0
 	}
-	startUnix, duration, err := leaderboardPeriodStart(period)
-	if err != nil {
-		return nil, err
+	normalizedFilter := LeaderboardFilter{
+		Metric:        metric,
+		Period:        filter.Period,
+		MinTrades:     minTrades,
+		MinActiveDays: filter.MinActiveDays,
+		ExcludeAgents: filter.ExcludeAgents,
+		Weights:       filter.Weights,
 	}
-	if metric == "" {
-		metric = "pnl_pct"
+	cacheKey := leaderboardCacheKey(normalizedFilter)
+	if cached, ok := s.leaderboardCache.get(cacheKey); ok {
+		return cached, nil
 	}
-	metric = strings.ToLower(metric)
-	if metric != "win_rate" && metric != "pnl_pct" {
-		return nil, fmt.Errorf("invalid metric")
+
+	startUnix, duration, err := leaderboardPeriodStart(filter.Period)
+	if err != nil {
+		return LeaderboardResult{}, err
 	}
 
 	agents, err := s.ListAgents(ctx)
 	if err != nil {
-		return nil, err
+		return LeaderboardResult{}, err
 	}
 	computed, _, err := s.computePortfolio(ctx, agents, startUnix, 0)
 	if err != nil {
-		return nil, err
+		return LeaderboardResult{}, err
 	}
 
-	currentItems := make([]agentComputed, 0, len(computed))
-	for _, item := range computed {
-		if item.TotalTrades < minTrades {
-			continue
+	excludeSet := make(map[string]struct{}, len(filter.ExcludeAgents))
+	for _, agentID := range filter.ExcludeAgents {
+		agentID = strings.TrimSpace(agentID)
+		if agentID != "" {
+			excludeSet[agentID] = struct{}{}
 		}
-		currentItems = append(currentItems, item)
 	}
+	minActiveCutoff := time.Now().Unix() - int64(filter.MinActiveDays)*86400
 
-	sort.SliceStable(currentItems, func(i, j int) bool {
-		if metric == "win_rate" {
-			if currentItems[i].WinRate == currentItems[j].WinRate {
-				return currentItems[i].PnlPct > currentItems[j].PnlPct
-			}
-			return currentItems[i].WinRate > currentItems[j].WinRate
+	passesFilters := func(item agentComputed) bool {
+		if item.TotalTrades < minTrades {
+			return false
+		}
+		if _, excluded := excludeSet[item.Agent.ID]; excluded {
+			return false
+		}
+		if filter.MinActiveDays > 0 && item.Agent.CreatedAt > minActiveCutoff {
+			return false
 		}
-		if currentItems[i].PnlPct == currentItems[j].PnlPct {
-			return currentItems[i].WinRate > currentItems[j].WinRate
+		return true
+	}
+
+	currentItems := make([]agentComputed, 0, len(computed))
+	for _, item := range computed {
+		if passesFilters(item) {
+			currentItems = append(currentItems, item)
 		}
-		return currentItems[i].PnlPct > currentItems[j].PnlPct
+	}
+	sort.SliceStable(currentItems, func(i, j int) bool {
+		return leaderboardLess(currentItems[i], currentItems[j], metric, filter.Weights)
 	})
 
 	previousRanks := map[string]int{}
@@ -1206,26 +1683,16 @@ func (s *Store) GetLeaderboard(ctx context.Context, metric, period string, minTr
 		prevEnd := now - duration
 		previousComputed, _, err := s.computePortfolio(ctx, agents, prevStart, prevEnd)
 		if err != nil {
-			return nil, err
+			return LeaderboardResult{}, err
 		}
 		previousItems := make([]agentComputed, 0, len(previousComputed))
 		for _, item := range previousComputed {
-			if item.TotalTrades < minTrades {
-				continue
+			if passesFilters(item) {
+				previousItems = append(previousItems, item)
 			}
-			previousItems = append(previousItems, item)
 		}
 		sort.SliceStable(previousItems, func(i, j int) bool {
-			if metric == "win_rate" {
-				if previousItems[i].WinRate == previousItems[j].WinRate {
-					return previousItems[i].PnlPct > previousItems[j].PnlPct
-				}
-				return previousItems[i].WinRate > previousItems[j].WinRate
-			}
-			if previousItems[i].PnlPct == previousItems[j].PnlPct {
-				return previousItems[i].WinRate > previousItems[j].WinRate
-			}
-			return previousItems[i].PnlPct > previousItems[j].PnlPct
+			return leaderboardLess(previousItems[i], previousItems[j], metric, filter.Weights)
 		})
 		for i, item := range previousItems {
 			previousRanks[item.Agent.ID] = i + 1
@@ -1248,18 +1715,83 @@ func (s *Store) GetLeaderboard(ctx context.Context, metric, period string, minTr
 		}
 
 		items = append(items, LeaderboardItem{
-			Rank:        rank,
-			RankChange:  rankChange,
-			AgentID:     item.Agent.ID,
-			AgentName:   item.Agent.Name,
-			WinRate:     round2(item.WinRate),
-			PnlPct:      round2(item.PnlPct),
-			TotalTrades: item.TotalTrades,
-			MaxDrawdown: round2(item.Drawdown),
-			Sparkline:   sparkline,
+			Rank:         rank,
+			RankChange:   rankChange,
+			AgentID:      item.Agent.ID,
+			AgentName:    item.Agent.Name,
+			WinRate:      round2(item.WinRate),
+			PnlPct:       round2(item.PnlPct),
+			TotalTrades:  item.TotalTrades,
+			MaxDrawdown:  round2(item.Drawdown),
+			Sparkline:    sparkline,
+			Sharpe:       round2(item.Sharpe),
+			Sortino:      round2(item.Sortino),
+			Calmar:       round2(item.Calmar),
+			ProfitFactor: round2(item.ProfitFactor),
+			AvgHoldSec:   round2(item.AvgHoldSec),
+			AvgWin:       round2(item.AvgWin),
+			AvgLoss:      round2(item.AvgLoss),
+			Expectancy:   round2(item.Expectancy),
+			Score:        round2(leaderboardScore(item, filter.Weights)),
+			RiskMetrics:  roundRiskMetrics(computeRiskMetrics(item.EquityHistory, 0)),
+
+			MaxDrawdownDurationSec: item.DrawdownDurationSec,
+			LongestWinStreak:       item.LongestWinStreak,
+			LongestLossStreak:      item.LongestLossStreak,
 		})
 	}
-	return items, nil
+
+	result := LeaderboardResult{Items: items, Fingerprint: computeFingerprint(items)}
+	s.leaderboardCache.set(cacheKey, result)
+	if err := s.persistAgentMetrics(ctx, normalizedFilter.Period, currentItems); err != nil {
+		return LeaderboardResult{}, err
+	}
+	return result, nil
+}
+
+// persistAgentMetrics upserts each ranked agent's rolling stats into
+// agent_metrics, keyed by (agent_id, period).
+func (s *Store) persistAgentMetrics(ctx context.Context, period string, items []agentComputed) error {
+	if period == "" {
+		period = "7d"
+	}
+	now := time.Now().Unix()
+	for _, item := range items {
+		_, err := s.db.ExecContext(
+			ctx,
+			`INSERT INTO agent_metrics (
+				agent_id, period, pnl_pct, win_rate, total_trades, max_drawdown,
+				sharpe, sortino, calmar, profit_factor, avg_hold_sec, computed_at
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (agent_id, period) DO UPDATE SET
+				pnl_pct = EXCLUDED.pnl_pct,
+				win_rate = EXCLUDED.win_rate,
+				total_trades = EXCLUDED.total_trades,
+				max_drawdown = EXCLUDED.max_drawdown,
+				sharpe = EXCLUDED.sharpe,
+				sortino = EXCLUDED.sortino,
+				calmar = EXCLUDED.calmar,
+				profit_factor = EXCLUDED.profit_factor,
+				avg_hold_sec = EXCLUDED.avg_hold_sec,
+				computed_at = EXCLUDED.computed_at`,
+			item.Agent.ID,
+			period,
+			round2(item.PnlPct),
+			round2(item.WinRate),
+			item.TotalTrades,
+			round2(item.Drawdown),
+			round2(item.Sharpe),
+			round2(item.Sortino),
+			round2(item.Calmar),
+			round2(item.ProfitFactor),
+			round2(item.AvgHoldSec),
+			now,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (s *Store) GetSystemStatus(ctx context.Context) (SystemStatusRecord, error) {
@@ -1304,10 +1836,19 @@ func (s *Store) GetSystemStatus(ctx context.Context) (SystemStatusRecord, error)
 	}, nil
 }
 
-func (s *Store) GetCandles(ctx context.Context, market string, limit int) ([]CandleRecord, error) {
+// GetCandles returns market's candles at intervalSec resolution, preferring
+// the tick-aggregated GetMarketCandles and falling back to aggregating raw
+// fills when the market has no price ticks at all (e.g. a market only ever
+// traded, never fed a price oracle). intervalSec defaults to 60 (same as
+// GetMarketCandles) when <= 0, so existing callers that only ever asked for
+// 1m bars don't need to change.
+func (s *Store) GetCandles(ctx context.Context, market string, intervalSec int64, limit int) ([]CandleRecord, error) {
+	if intervalSec <= 0 {
+		intervalSec = 60
+	}
 	marketSymbol := NormalizeMarketSymbol(market)
 	if marketSymbol != "" {
-		candles, err := s.GetMarketCandles(ctx, marketSymbol, 60, limit)
+		candles, err := s.GetMarketCandles(ctx, marketSymbol, intervalSec, limit, nil)
 		if err != nil {
 			return nil, err
 		}
@@ -1316,10 +1857,13 @@ func (s *Store) GetCandles(ctx context.Context, market string, limit int) ([]Can
 		}
 	}
 
-	return s.getCandlesFromFills(ctx, market, limit)
+	return s.getCandlesFromFills(ctx, market, intervalSec, limit)
 }
 
-func (s *Store) getCandlesFromFills(ctx context.Context, market string, limit int) ([]CandleRecord, error) {
+func (s *Store) getCandlesFromFills(ctx context.Context, market string, intervalSec int64, limit int) ([]CandleRecord, error) {
+	if intervalSec <= 0 {
+		intervalSec = 60
+	}
 	if limit <= 0 {
 		limit = 200
 	}
@@ -1365,7 +1909,7 @@ func (s *Store) getCandlesFromFills(ctx context.Context, market string, limit in
 		}
 		price := parseFloat(priceRaw)
 		qty := parseFloat(qtyRaw)
-		bucket := executedAt - (executedAt % 60)
+		bucket := executedAt - (executedAt % intervalSec)
 		builder, ok := builders[bucket]
 		if !ok {
 			builder = &candleBuilder{TS: bucket}
@@ -1425,7 +1969,11 @@ func (s *Store) computePortfolio(ctx context.Context, agents []AgentRecord, star
 	if err != nil {
 		return nil, nil, err
 	}
-	trades := computeTrades(events)
+	feeModel, err := s.loadFeeModel(ctx, endUnix)
+	if err != nil {
+		return nil, nil, err
+	}
+	trades, _ := computeTradesAndLots(events, modeForAgents(agents), feeModel)
 
 	tradesByAgent := map[string][]TradeRecord{}
 	portfolioEvents := make([]TradeRecord, 0, len(trades))
@@ -1484,9 +2032,23 @@ func buildAgentMetrics(agent AgentRecord, trades []TradeRecord) agentComputed {
 
 	equity := defaultAgentEquity
 	peak := equity
+	peakTime := int64(0)
 	maxDrawdown := 0.0
+	peakAtMaxDrawdown := equity
+	maxDrawdownStart := int64(0)
+	maxDrawdownTrough := int64(0)
+	maxDrawdownRecovery := int64(0)
 	wins := 0
 	totalPNL := 0.0
+	grossProfit := 0.0
+	grossLoss := 0.0
+	totalFees := 0.0
+	holdSecTotal := int64(0)
+	currentWinStreak := 0
+	currentLossStreak := 0
+	longestWinStreak := 0
+	longestLossStreak := 0
+	returns := make([]float64, 0, len(trades))
 	equityHistory := make([]EquityPoint, 0, len(trades)+1)
 	dailyPNLByDate := map[string]float64{}
 
@@ -1507,24 +2069,52 @@ func buildAgentMetrics(agent AgentRecord, trades []TradeRecord) agentComputed {
 	}
 
 	equityHistory = append(equityHistory, EquityPoint{TS: trades[0].ExitTime, Value: equity})
+	peakTime = trades[0].ExitTime
 	for _, trade := range trades {
 		totalPNL += trade.Pnl
+		totalFees += trade.Fee
 		equity += trade.Pnl
 		equityHistory = append(equityHistory, EquityPoint{TS: trade.ExitTime, Value: equity})
 		if trade.Pnl > 0 {
 			wins++
+			grossProfit += trade.Pnl
+			currentWinStreak++
+			currentLossStreak = 0
+			if currentWinStreak > longestWinStreak {
+				longestWinStreak = currentWinStreak
+			}
+		} else {
+			grossLoss += -trade.Pnl
+			currentLossStreak++
+			currentWinStreak = 0
+			if currentLossStreak > longestLossStreak {
+				longestLossStreak = currentLossStreak
+			}
 		}
 		if equity > peak {
 			peak = equity
+			peakTime = trade.ExitTime
 		}
 		if peak > 0 {
 			dd := ((equity - peak) / peak) * 100
 			if dd < maxDrawdown {
 				maxDrawdown = dd
+				peakAtMaxDrawdown = peak
+				maxDrawdownStart = peakTime
+				maxDrawdownTrough = trade.ExitTime
+				maxDrawdownRecovery = 0
+			} else if maxDrawdownTrough != 0 && maxDrawdownRecovery == 0 && equity >= peakAtMaxDrawdown {
+				maxDrawdownRecovery = trade.ExitTime
 			}
 		}
 		dateKey := time.Unix(trade.ExitTime, 0).UTC().Format("2006-01-02")
 		dailyPNLByDate[dateKey] += trade.Pnl
+		if defaultAgentEquity > 0 {
+			returns = append(returns, (trade.Pnl/defaultAgentEquity)*100)
+		}
+		if trade.ExitTime > trade.EntryTime {
+			holdSecTotal += trade.ExitTime - trade.EntryTime
+		}
 	}
 
 	dates := make([]string, 0, len(dailyPNLByDate))
@@ -1558,19 +2148,132 @@ func buildAgentMetrics(agent AgentRecord, trades []TradeRecord) agentComputed {
 		pnlPct = (totalPNL / defaultAgentEquity) * 100
 	}
 
+	profitFactor := 0.0
+	switch {
+	case grossLoss > 0:
+		profitFactor = grossProfit / grossLoss
+	case grossProfit > 0:
+		profitFactor = grossProfit
+	}
+
+	avgHoldSec := 0.0
+	if len(trades) > 0 {
+		avgHoldSec = float64(holdSecTotal) / float64(len(trades))
+	}
+
+	calmar := 0.0
+	if maxDrawdown < 0 {
+		calmar = pnlPct / -maxDrawdown
+	}
+
+	avgWin := 0.0
+	if wins > 0 {
+		avgWin = grossProfit / float64(wins)
+	}
+	losses := len(trades) - wins
+	avgLoss := 0.0
+	if losses > 0 {
+		avgLoss = grossLoss / float64(losses)
+	}
+	winFrac := winRate / 100
+	expectancy := winFrac*avgWin - (1-winFrac)*avgLoss
+
+	drawdownDurationSec := int64(0)
+	if maxDrawdown < 0 {
+		recoveryTime := maxDrawdownRecovery
+		if recoveryTime == 0 {
+			recoveryTime = trades[len(trades)-1].ExitTime
+		}
+		drawdownDurationSec = recoveryTime - maxDrawdownStart
+	}
+
 	return agentComputed{
-		Agent:         agent,
-		Trades:        trades,
-		PnlAbs:        totalPNL,
-		PnlPct:        pnlPct,
-		WinRate:       winRate,
-		TotalTrades:   len(trades),
-		Drawdown:      maxDrawdown,
-		Equity:        equity,
-		DailyPNL:      dailyPNL,
-		EquityHistory: equityHistory,
-		Sparkline:     sparkline,
+		Agent:               agent,
+		Trades:              trades,
+		PnlAbs:              totalPNL,
+		PnlPct:              pnlPct,
+		WinRate:             winRate,
+		TotalTrades:         len(trades),
+		Drawdown:            maxDrawdown,
+		Equity:              equity,
+		DailyPNL:            dailyPNL,
+		EquityHistory:       equityHistory,
+		Sparkline:           sparkline,
+		Sharpe:              sharpeRatio(returns),
+		Sortino:             sortinoRatio(returns),
+		Calmar:              calmar,
+		ProfitFactor:        profitFactor,
+		AvgHoldSec:          avgHoldSec,
+		AvgWin:              avgWin,
+		AvgLoss:             avgLoss,
+		Expectancy:          expectancy,
+		Fees:                totalFees,
+		DrawdownDurationSec: drawdownDurationSec,
+		LongestWinStreak:    longestWinStreak,
+		LongestLossStreak:   longestLossStreak,
+	}
+}
+
+// sharpeRatio is the mean of per-trade returns (in percent) divided by
+// their standard deviation, following the same "no risk-free rate"
+// simplification used throughout this package for pct-based metrics.
+func sharpeRatio(returns []float64) float64 {
+	if len(returns) == 0 {
+		return 0
 	}
+	mean := meanOf(returns)
+	stdDev := stdDevOf(returns, mean)
+	if stdDev == 0 {
+		return 0
+	}
+	return mean / stdDev
+}
+
+// sortinoRatio is sharpeRatio but penalizing only downside deviation, so
+// an agent with volatile upside but no losing trades doesn't get marked
+// down for it.
+func sortinoRatio(returns []float64) float64 {
+	if len(returns) == 0 {
+		return 0
+	}
+	mean := meanOf(returns)
+	downside := make([]float64, 0, len(returns))
+	for _, r := range returns {
+		if r < 0 {
+			downside = append(downside, r)
+		}
+	}
+	if len(downside) == 0 {
+		return 0
+	}
+	downsideDev := stdDevOf(downside, 0)
+	if downsideDev == 0 {
+		return 0
+	}
+	return mean / downsideDev
+}
+
+func meanOf(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+func stdDevOf(values []float64, mean float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	variance := 0.0
+	for _, v := range values {
+		diff := v - mean
+		variance += diff * diff
+	}
+	return math.Sqrt(variance / float64(len(values)))
 }
 
 func (s *Store) loadTradeEvents(ctx context.Context, agentID string, fromUnix, toUnix int64) ([]tradeEvent, error) {
@@ -1889,92 +2592,36 @@ func baseAssetSymbol(symbol string) string {
 	return builder.String()
 }
 
-func computeTrades(events []tradeEvent) []TradeRecord {
-	if len(events) == 0 {
-		return []TradeRecord{}
+// agentAccountingModes loads every agent's configured AccountingMode,
+// keyed by agent id, so computeTradesAndLots can dispatch each agent's
+// fills to the right PnLEngine without re-parsing risk_profile_json per
+// fill.
+func (s *Store) agentAccountingModes(ctx context.Context) (map[string]AccountingMode, error) {
+	agents, err := s.ListAgents(ctx)
+	if err != nil {
+		return nil, err
 	}
+	modes := make(map[string]AccountingMode, len(agents))
+	for _, agent := range agents {
+		modes[agent.ID] = normalizeAccountingMode(agent.RiskProfile.AccountingMode)
+	}
+	return modes, nil
+}
 
-	stateByKey := make(map[string]positionState, 64)
-	trades := make([]TradeRecord, 0, len(events))
-	for _, event := range events {
-		qty := math.Abs(event.Qty)
-		if qty == 0 && event.Price > 0 && event.Notional > 0 {
-			qty = event.Notional / event.Price
-		}
-		if qty == 0 {
-			continue
-		}
-		if event.Price <= 0 {
-			continue
-		}
-
-		key := event.AgentID + ":" + strconv.FormatUint(event.MarketID, 10)
-		state := stateByKey[key]
-		entryPrice := event.Price
-		realizedPNL := 0.0
-		buy := isBuySide(event.Side)
-		direction := -1.0
-		side := "short"
-		if buy {
-			direction = 1.0
-			side = "long"
-		}
-
-		if state.Qty == 0 || sameSign(state.Qty, direction) {
-			if state.Qty != 0 {
-				entryPrice = state.AvgPrice
-			}
-			newAbs := math.Abs(state.Qty) + qty
-			if newAbs > 0 {
-				if state.Qty == 0 {
-					state.AvgPrice = event.Price
-				} else {
-					state.AvgPrice = ((math.Abs(state.Qty) * state.AvgPrice) + (qty * event.Price)) / newAbs
-				}
-			}
-			state.Qty += direction * qty
-		} else {
-			entryPrice = state.AvgPrice
-			closeQty := math.Min(math.Abs(state.Qty), qty)
-			if state.Qty > 0 {
-				realizedPNL = closeQty * (event.Price - state.AvgPrice)
-			} else {
-				realizedPNL = closeQty * (state.AvgPrice - event.Price)
-			}
-
-			remaining := qty - closeQty
-			state.Qty += direction * qty
-			if math.Abs(state.Qty) < 1e-9 {
-				state.Qty = 0
-				state.AvgPrice = 0
-			} else if remaining > 0 {
-				state.AvgPrice = event.Price
-			}
+// modeForAgents returns a modeFor lookup backed by an already-loaded
+// agent list, so call sites that already have one (computePortfolio)
+// don't re-query agents just to resolve accounting modes.
+func modeForAgents(agents []AgentRecord) func(agentID string) AccountingMode {
+	modes := make(map[string]AccountingMode, len(agents))
+	for _, agent := range agents {
+		modes[agent.ID] = normalizeAccountingMode(agent.RiskProfile.AccountingMode)
+	}
+	return func(agentID string) AccountingMode {
+		if mode, ok := modes[agentID]; ok {
+			return mode
 		}
-
-		stateByKey[key] = state
-		feeNotional := math.Abs(event.Notional)
-		if feeNotional == 0 {
-			feeNotional = qty * event.Price
-		}
-		fee := feeNotional * defaultTradeFeeRate
-		trades = append(trades, TradeRecord{
-			ID:         strconv.FormatInt(event.FillID, 10),
-			AgentID:    event.AgentID,
-			AgentName:  event.AgentName,
-			Side:       side,
-			EntryPrice: entryPrice,
-			ExitPrice:  event.Price,
-			Qty:        qty,
-			Fee:        fee,
-			Pnl:        realizedPNL - fee,
-			TxSig:      event.TxSig,
-			EntryTime:  event.CreatedAt,
-			ExitTime:   event.ExecutedAt,
-			MarketID:   event.MarketID,
-		})
+		return AccountingWeightedAverage
 	}
-	return trades
 }
 
 func sameSign(a, b float64) bool {
@@ -1998,6 +2645,7 @@ func parseRiskProfile(raw string) AgentRiskProfile {
 	if err := json.Unmarshal([]byte(raw), &profile); err != nil {
 		return defaultRiskProfile()
 	}
+	profile.AccountingMode = string(normalizeAccountingMode(profile.AccountingMode))
 	return profile
 }
 
@@ -2035,34 +2683,224 @@ func parseScaledFloat(raw string, scale float64) float64 {
 	return value / scale
 }
 
+// portfolioPeriodStart resolves period into a start-of-window unix time,
+// accepting the legacy 7d/30d/all keys plus the richer grammar
+// parsePeriodKey understands (calendar-aligned keys, ISO-8601 durations,
+// and an explicit from:/to: range). It's kept as a single-string-argument
+// helper so every pre-existing caller (GetPortfolioSummary,
+// GetAgentPortfolioSummary, PreviewRebalance) picks up the richer grammar
+// for free without a signature change.
 func portfolioPeriodStart(period string) (int64, error) {
-	now := time.Now().Unix()
-	switch strings.ToLower(strings.TrimSpace(period)) {
-	case "", "7d":
-		return now - int64(7*24*time.Hour/time.Second), nil
-	case "30d":
-		return now - int64(30*24*time.Hour/time.Second), nil
-	case "all":
-		return 0, nil
-	default:
+	startUnix, _, ok := parsePeriodKey(time.Now(), period)
+	if !ok {
 		return 0, fmt.Errorf("invalid period")
 	}
+	return startUnix, nil
 }
 
+// leaderboardPeriodStart is portfolioPeriodStart's leaderboard-flavored
+// twin: it additionally returns duration, the window's length in seconds
+// (0 for "all"/"all_time"), which GetLeaderboard uses to look back an
+// equal-length prior window for rank-change comparison.
 func leaderboardPeriodStart(period string) (int64, int64, error) {
-	now := time.Now().Unix()
-	switch strings.ToLower(strings.TrimSpace(period)) {
+	now := time.Now()
+	startUnix, _, ok := parsePeriodKey(now, period)
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid period")
+	}
+	duration := int64(0)
+	if startUnix > 0 {
+		duration = now.Unix() - startUnix
+	}
+	return startUnix, duration, nil
+}
+
+// periodRangeRe matches the explicit-range period encoding
+// "from:<unix>..to:<unix>", the compact single-string form ParsePeriodRange
+// falls back to when query-level from/to overrides aren't available to a
+// caller that only has a bare period string to work with (e.g. a cached
+// LeaderboardFilter.Period value).
+var periodRangeRe = regexp.MustCompile(`^from:(-?\d+)\.\.to:(-?\d+)$`)
+
+// isoDurationRe matches an ISO-8601 duration: P(nY)(nM)(nW)(nD)(T(nH)(nM)(nS)),
+// every component optional but at least one required.
+var isoDurationRe = regexp.MustCompile(`(?i)^P(?:(\d+)Y)?(?:(\d+)M)?(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// parsePeriodKey parses period's mini-language against now (calendar keys
+// resolve relative to now's location) and returns (startUnix, bucketSec,
+// ok). Recognized forms:
+//   - "", "7d", "30d": a fixed trailing window
+//   - "all", "all_time": everything (startUnix 0)
+//   - "today", "ytd", "mtd", "wtd": calendar-aligned to the start of the
+//     current day/year/month/ISO week, optionally suffixed "@<tz>" (an
+//     IANA zone name) to align the boundary to that timezone instead of
+//     now's own location
+//   - an ISO-8601 duration (e.g. "P1M", "PT6H", "P1DT12H"): now minus
+//     that duration
+//   - "from:<unix>..to:<unix>": an explicit epoch-second range
+//
+// bucketSec is a stable candle width downstream aggregation (e.g. the
+// leaderboard's previous-period comparison, or a future candle grouping)
+// can group fills into without independently guessing a width per window
+// size.
+func parsePeriodKey(now time.Time, period string) (startUnix int64, bucketSec int64, ok bool) {
+	raw := strings.TrimSpace(period)
+	if m := periodRangeRe.FindStringSubmatch(raw); m != nil {
+		from, errFrom := strconv.ParseInt(m[1], 10, 64)
+		to, errTo := strconv.ParseInt(m[2], 10, 64)
+		if errFrom != nil || errTo != nil || to < from {
+			return 0, 0, false
+		}
+		return from, periodBucketSeconds(to - from), true
+	}
+
+	key, loc := splitPeriodTimezone(raw)
+	switch strings.ToLower(key) {
 	case "", "7d":
-		duration := int64(7 * 24 * time.Hour / time.Second)
-		return now - duration, duration, nil
+		window := int64(7 * 24 * time.Hour / time.Second)
+		return now.Unix() - window, periodBucketSeconds(window), true
 	case "30d":
-		duration := int64(30 * 24 * time.Hour / time.Second)
-		return now - duration, duration, nil
-	case "all_time":
-		return 0, 0, nil
-	default:
+		window := int64(30 * 24 * time.Hour / time.Second)
+		return now.Unix() - window, periodBucketSeconds(window), true
+	case "all", "all_time":
+		return 0, periodBucketSeconds(int64(30 * 24 * time.Hour / time.Second)), true
+	case "today":
+		localNow := now.In(loc)
+		start := time.Date(localNow.Year(), localNow.Month(), localNow.Day(), 0, 0, 0, 0, loc)
+		return start.Unix(), periodBucketSeconds(int64(time.Hour / time.Second)), true
+	case "ytd":
+		localNow := now.In(loc)
+		start := time.Date(localNow.Year(), time.January, 1, 0, 0, 0, 0, loc)
+		return start.Unix(), periodBucketSeconds(now.Unix() - start.Unix()), true
+	case "mtd":
+		localNow := now.In(loc)
+		start := time.Date(localNow.Year(), localNow.Month(), 1, 0, 0, 0, 0, loc)
+		return start.Unix(), periodBucketSeconds(now.Unix() - start.Unix()), true
+	case "wtd":
+		localNow := now.In(loc)
+		dayOfWeek := int(localNow.Weekday())
+		if dayOfWeek == 0 {
+			dayOfWeek = 7 // ISO week starts Monday; treat Sunday as day 7.
+		}
+		dayStart := time.Date(localNow.Year(), localNow.Month(), localNow.Day(), 0, 0, 0, 0, loc)
+		start := dayStart.AddDate(0, 0, -(dayOfWeek - 1))
+		return start.Unix(), periodBucketSeconds(now.Unix() - start.Unix()), true
+	}
+
+	if dur, ok := parseISO8601Duration(raw); ok {
+		window := int64(dur / time.Second)
+		return now.Unix() - window, periodBucketSeconds(window), true
+	}
+	return 0, 0, false
+}
+
+// splitPeriodTimezone splits an optional "@<tz>" suffix off a calendar
+// period key, defaulting to UTC (matching time.Now().Unix()'s existing
+// implicit behavior everywhere else in this file) when the suffix is
+// absent or names an unknown zone.
+func splitPeriodTimezone(period string) (key string, loc *time.Location) {
+	idx := strings.LastIndex(period, "@")
+	if idx < 0 {
+		return period, time.UTC
+	}
+	tzName := strings.TrimSpace(period[idx+1:])
+	loc, err := time.LoadLocation(tzName)
+	if err != nil {
+		return period[:idx], time.UTC
+	}
+	return period[:idx], loc
+}
+
+// parseISO8601Duration parses the Y/M/W/D/H/M/S components of an
+// ISO-8601 duration into an approximate time.Duration: years are taken as
+// 365 days and months as 30 days, matching how a "last P1M" style window
+// is meant to read (a rolling ~month, not a calendar month) rather than
+// requiring a reference date to resolve a true calendar span.
+func parseISO8601Duration(raw string) (time.Duration, bool) {
+	m := isoDurationRe.FindStringSubmatch(raw)
+	if m == nil {
+		return 0, false
+	}
+	if raw == "" || strings.EqualFold(raw, "P") || strings.EqualFold(raw, "PT") {
+		return 0, false
+	}
+	years := parseIntOr(m[1], 0)
+	months := parseIntOr(m[2], 0)
+	weeks := parseIntOr(m[3], 0)
+	days := parseIntOr(m[4], 0)
+	hours := parseIntOr(m[5], 0)
+	minutes := parseIntOr(m[6], 0)
+	seconds := parseIntOr(m[7], 0)
+	if years == 0 && months == 0 && weeks == 0 && days == 0 && hours == 0 && minutes == 0 && seconds == 0 {
+		return 0, false
+	}
+	total := time.Duration(years)*365*24*time.Hour +
+		time.Duration(months)*30*24*time.Hour +
+		time.Duration(weeks)*7*24*time.Hour +
+		time.Duration(days)*24*time.Hour +
+		time.Duration(hours)*time.Hour +
+		time.Duration(minutes)*time.Minute +
+		time.Duration(seconds)*time.Second
+	return total, true
+}
+
+func parseIntOr(raw string, fallback int64) int64 {
+	if raw == "" {
+		return fallback
+	}
+	v, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return fallback
+	}
+	return v
+}
+
+// periodBucketSeconds picks a stable candle width for a window rangeSec
+// long, so two calls with a similar-sized range (e.g. "7d" today and "7d"
+// tomorrow) always group into the same bucket width rather than each
+// computing its own. Widths are chosen to keep roughly 100-200 buckets
+// across the window.
+func periodBucketSeconds(rangeSec int64) int64 {
+	candidates := []int64{60, 300, 900, 3600, 4 * 3600, 86400, 7 * 86400}
+	if rangeSec <= 0 {
+		return candidates[len(candidates)-1]
+	}
+	target := rangeSec / 150
+	for _, bucket := range candidates {
+		if bucket >= target {
+			return bucket
+		}
+	}
+	return candidates[len(candidates)-1]
+}
+
+// ParsePeriodRange is parsePeriodKey's HTTP-facing entry point: it layers
+// an explicit from/to epoch override and a tz query param on top of the
+// same grammar, for handlers that read those as separate query
+// parameters rather than encoding them into the period string itself.
+// fromRaw/toRaw/tzName may all be empty, in which case this is exactly
+// parsePeriodKey(time.Now(), period).
+func ParsePeriodRange(period, fromRaw, toRaw, tzName string) (startUnix int64, bucketSec int64, err error) {
+	fromRaw = strings.TrimSpace(fromRaw)
+	toRaw = strings.TrimSpace(toRaw)
+	if fromRaw != "" || toRaw != "" {
+		from, errFrom := strconv.ParseInt(fromRaw, 10, 64)
+		to, errTo := strconv.ParseInt(toRaw, 10, 64)
+		if errFrom != nil || errTo != nil || to < from {
+			return 0, 0, fmt.Errorf("invalid period: from/to must be epoch seconds with from <= to")
+		}
+		return from, periodBucketSeconds(to - from), nil
+	}
+
+	tzName = strings.TrimSpace(tzName)
+	if tzName != "" {
+		period = strings.TrimSuffix(strings.TrimSpace(period), "@"+tzName) + "@" + tzName
+	}
+	startUnix, bucketSec, ok := parsePeriodKey(time.Now(), period)
+	if !ok {
 		return 0, 0, fmt.Errorf("invalid period")
 	}
+	return startUnix, bucketSec, nil
 }
 
 func round2(v float64) float64 {
@@ -2087,6 +2925,9 @@ func roundTrade(trade TradeRecord) TradeRecord {
 	trade.Qty = round6(trade.Qty)
 	trade.Fee = round6(trade.Fee)
 	trade.Pnl = round2(trade.Pnl)
+	trade.GrossPnl = round2(trade.GrossPnl)
+	trade.Fees = round6(trade.Fees)
+	trade.NetPnl = round2(trade.NetPnl)
 	return trade
 }
 
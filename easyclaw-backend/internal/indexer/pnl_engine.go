@@ -0,0 +1,509 @@
+package indexer
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// AccountingMode selects which PnLEngine implementation
+// computeTradesAndLots uses for a given agent's fills. It's stored per
+// agent on AgentRiskProfile.AccountingMode so paper and live agents can be
+// configured (and reconciled) independently.
+type AccountingMode string
+
+const (
+	AccountingWeightedAverage AccountingMode = "weighted_average"
+	AccountingFIFO            AccountingMode = "fifo"
+	AccountingLIFO            AccountingMode = "lifo"
+	AccountingHIFO            AccountingMode = "hifo"
+)
+
+// normalizeAccountingMode maps a persisted/patched string onto a known
+// AccountingMode, falling back to weighted-average (the long-standing
+// implicit behavior of computeTrades) for empty or unrecognized values
+// rather than erroring, the same leniency parseRiskProfile already applies
+// to a malformed risk_profile_json blob.
+func normalizeAccountingMode(mode string) AccountingMode {
+	switch AccountingMode(strings.ToLower(strings.TrimSpace(mode))) {
+	case AccountingFIFO:
+		return AccountingFIFO
+	case AccountingLIFO:
+		return AccountingLIFO
+	case AccountingHIFO:
+		return AccountingHIFO
+	default:
+		return AccountingWeightedAverage
+	}
+}
+
+// ClosedLot is one fully or partially closed position lot. A single
+// closing fill can realize against more than one open lot under FIFO/LIFO
+// (but never under weighted-average, which keeps one blended lot per
+// key), so ClosedLot.CloseFillID is not unique by itself - callers that
+// persist these key on (AgentID, MarketID, CloseFillID, Seq).
+type ClosedLot struct {
+	AgentID     string
+	MarketID    uint64
+	Mode        AccountingMode
+	Side        string
+	EntryPrice  float64
+	ExitPrice   float64
+	Qty         float64
+	Pnl         float64
+	EntryTime   int64
+	ExitTime    int64
+	OpenFillID  int64
+	CloseFillID int64
+	Seq         int
+}
+
+// PnLEngine walks one agent's fills (already restricted to a single
+// agent, but possibly spanning several markets) in fill order and
+// produces the realized TradeRecords - one per fill, matching the
+// per-fill granularity ListTrades has always exposed - plus the
+// ClosedLots consumed to realize them. FIFO, LIFO, and weighted-average
+// differ only in which open lot(s) a closing fill draws down first; all
+// three leave TradeRecord, AgentPortfolioSummary, and LeaderboardItem
+// derived from whichever engine the agent is configured to use.
+type PnLEngine interface {
+	Mode() AccountingMode
+	Process(events []tradeEvent, feeModel FeeModel) ([]TradeRecord, []ClosedLot)
+}
+
+func newPnLEngine(mode AccountingMode) PnLEngine {
+	switch mode {
+	case AccountingFIFO:
+		return lotQueueEngine{mode: AccountingFIFO}
+	case AccountingLIFO:
+		return lotQueueEngine{mode: AccountingLIFO}
+	case AccountingHIFO:
+		return lotQueueEngine{mode: AccountingHIFO}
+	default:
+		return weightedAverageEngine{}
+	}
+}
+
+// computeTradesAndLots groups events by agent, runs each agent's
+// configured PnLEngine over its own fills in isolation, and concatenates
+// the results back in fill order. modeFor is consulted once per distinct
+// agent id seen in events. feeModel may be nil, in which case every fill is
+// charged scheduleFeeModel's default maker/taker rate with no VIP tier.
+func computeTradesAndLots(events []tradeEvent, modeFor func(agentID string) AccountingMode, feeModel FeeModel) ([]TradeRecord, []ClosedLot) {
+	if len(events) == 0 {
+		return []TradeRecord{}, nil
+	}
+
+	order := make([]string, 0, 8)
+	byAgent := make(map[string][]tradeEvent, 8)
+	for _, event := range events {
+		if _, ok := byAgent[event.AgentID]; !ok {
+			order = append(order, event.AgentID)
+		}
+		byAgent[event.AgentID] = append(byAgent[event.AgentID], event)
+	}
+
+	trades := make([]TradeRecord, 0, len(events))
+	var lots []ClosedLot
+	for _, agentID := range order {
+		mode := AccountingWeightedAverage
+		if modeFor != nil {
+			mode = modeFor(agentID)
+		}
+		agentTrades, agentLots := newPnLEngine(mode).Process(byAgent[agentID], feeModel)
+		trades = append(trades, agentTrades...)
+		lots = append(lots, agentLots...)
+	}
+	return trades, lots
+}
+
+// weightedAverageEngine blends every open fill on a given side into a
+// single running (Qty, AvgPrice) lot per market, the behavior computeTrades
+// always had before FIFO/LIFO accounting modes existed.
+type weightedAverageEngine struct{}
+
+func (weightedAverageEngine) Mode() AccountingMode { return AccountingWeightedAverage }
+
+func (weightedAverageEngine) Process(events []tradeEvent, feeModel FeeModel) ([]TradeRecord, []ClosedLot) {
+	stateByMarket := make(map[uint64]positionState, 8)
+	trades := make([]TradeRecord, 0, len(events))
+	var lots []ClosedLot
+
+	for _, event := range events {
+		state, trade, closed, ok := stepWeightedAverage(stateByMarket[event.MarketID], event, feeModel)
+		if !ok {
+			continue
+		}
+		stateByMarket[event.MarketID] = state
+		trades = append(trades, trade)
+		if closed != nil {
+			lots = append(lots, *closed)
+		}
+	}
+	return trades, lots
+}
+
+// stepWeightedAverage is weightedAverageEngine.Process's per-fill state
+// transition, factored out so an incremental replay (see
+// checkpoint_replay.go) can resume from a previously saved positionState
+// instead of always starting a market's state at zero. ok is false (state
+// unchanged) for a fill normalizedFillQty rejects.
+func stepWeightedAverage(state positionState, event tradeEvent, feeModel FeeModel) (newState positionState, trade TradeRecord, closed *ClosedLot, ok bool) {
+	qty, valid := normalizedFillQty(event)
+	if !valid {
+		return state, TradeRecord{}, nil, false
+	}
+
+	entryPrice := event.Price
+	realizedPNL := 0.0
+	buy := isBuySide(event.Side)
+	direction := -1.0
+	side := "short"
+	if buy {
+		direction = 1.0
+		side = "long"
+	}
+
+	if state.Qty == 0 || sameSign(state.Qty, direction) {
+		if state.Qty != 0 {
+			entryPrice = state.AvgPrice
+		}
+		newAbs := math.Abs(state.Qty) + qty
+		if newAbs > 0 {
+			if state.Qty == 0 {
+				state.AvgPrice = event.Price
+			} else {
+				state.AvgPrice = ((math.Abs(state.Qty) * state.AvgPrice) + (qty * event.Price)) / newAbs
+			}
+		}
+		state.Qty += direction * qty
+	} else {
+		priorAvgPrice := state.AvgPrice
+		entryPrice = priorAvgPrice
+		closeQty := math.Min(math.Abs(state.Qty), qty)
+		if state.Qty > 0 {
+			realizedPNL = closeQty * (event.Price - priorAvgPrice)
+		} else {
+			realizedPNL = closeQty * (priorAvgPrice - event.Price)
+		}
+		closed = &ClosedLot{
+			AgentID:     event.AgentID,
+			MarketID:    event.MarketID,
+			Mode:        AccountingWeightedAverage,
+			Side:        side,
+			EntryPrice:  priorAvgPrice,
+			ExitPrice:   event.Price,
+			Qty:         closeQty,
+			Pnl:         realizedPNL,
+			EntryTime:   event.CreatedAt,
+			ExitTime:    event.ExecutedAt,
+			OpenFillID:  0,
+			CloseFillID: event.FillID,
+			Seq:         0,
+		}
+
+		remaining := qty - closeQty
+		state.Qty += direction * qty
+		if math.Abs(state.Qty) < 1e-9 {
+			state.Qty = 0
+			state.AvgPrice = 0
+		} else if remaining > 0 {
+			state.AvgPrice = event.Price
+		}
+	}
+
+	return state, buildTradeRecord(event, side, entryPrice, qty, realizedPNL, feeModel), closed, true
+}
+
+// lotQueueEngine backs FIFO, LIFO, and HIFO accounting: each opening fill
+// pushes a discrete lot, and each closing fill consumes lots - from the
+// front (FIFO), the back (LIFO), or whichever is priced highest (HIFO,
+// the common "sell the most expensive tax lot first" strategy) - until
+// the fill is fully realized, falling back to opening a new lot in the
+// opposite direction if the fill flips the position past flat.
+type lotQueueEngine struct {
+	mode AccountingMode
+}
+
+type openLot struct {
+	qty       float64
+	price     float64
+	entryTime int64
+	fillID    int64
+}
+
+func (e lotQueueEngine) Mode() AccountingMode { return e.mode }
+
+func (e lotQueueEngine) Process(events []tradeEvent, feeModel FeeModel) ([]TradeRecord, []ClosedLot) {
+	signByMarket := make(map[uint64]float64, 8)
+	lotsByMarket := make(map[uint64][]openLot, 8)
+	trades := make([]TradeRecord, 0, len(events))
+	var closed []ClosedLot
+
+	for _, event := range events {
+		sign, open := signByMarket[event.MarketID], lotsByMarket[event.MarketID]
+		newSign, newOpen, trade, closedBatch, ok := e.stepLotQueue(sign, open, event, feeModel)
+		if !ok {
+			continue
+		}
+		signByMarket[event.MarketID] = newSign
+		lotsByMarket[event.MarketID] = newOpen
+		trades = append(trades, trade)
+		closed = append(closed, closedBatch...)
+	}
+	return trades, closed
+}
+
+// stepLotQueue is lotQueueEngine.Process's per-fill state transition,
+// factored out so an incremental replay (see checkpoint_replay.go) can
+// resume a market's open-lot queue from a previously saved checkpoint
+// instead of always starting flat. ok is false (state unchanged) for a
+// fill normalizedFillQty rejects.
+func (e lotQueueEngine) stepLotQueue(sign float64, open []openLot, event tradeEvent, feeModel FeeModel) (newSign float64, newOpen []openLot, trade TradeRecord, closed []ClosedLot, ok bool) {
+	qty, valid := normalizedFillQty(event)
+	if !valid {
+		return sign, open, TradeRecord{}, nil, false
+	}
+
+	buy := isBuySide(event.Side)
+	direction := -1.0
+	side := "short"
+	if buy {
+		direction = 1.0
+		side = "long"
+	}
+
+	var entryPrice float64
+	realizedPNL := 0.0
+	seq := 0
+
+	if sign == 0 || sign == direction {
+		entryPrice = e.openingReferencePrice(open, event.Price)
+		open = append(open, openLot{qty: qty, price: event.Price, entryTime: event.CreatedAt, fillID: event.FillID})
+		sign = direction
+	} else {
+		remaining := qty
+		closedQty := 0.0
+		weightedEntry := 0.0
+		for remaining > 1e-9 && len(open) > 0 {
+			idx := e.lotIndex(open)
+			lot := open[idx]
+			closeQty := math.Min(lot.qty, remaining)
+
+			var pnl float64
+			if sign > 0 {
+				pnl = closeQty * (event.Price - lot.price)
+			} else {
+				pnl = closeQty * (lot.price - event.Price)
+			}
+			realizedPNL += pnl
+			weightedEntry += lot.price * closeQty
+			closedQty += closeQty
+			seq++
+			closed = append(closed, ClosedLot{
+				AgentID:     event.AgentID,
+				MarketID:    event.MarketID,
+				Mode:        e.mode,
+				Side:        side,
+				EntryPrice:  lot.price,
+				ExitPrice:   event.Price,
+				Qty:         closeQty,
+				Pnl:         pnl,
+				EntryTime:   lot.entryTime,
+				ExitTime:    event.ExecutedAt,
+				OpenFillID:  lot.fillID,
+				CloseFillID: event.FillID,
+				Seq:         seq,
+			})
+
+			lot.qty -= closeQty
+			remaining -= closeQty
+			if lot.qty <= 1e-9 {
+				open = append(open[:idx], open[idx+1:]...)
+			} else {
+				open[idx] = lot
+			}
+		}
+
+		if closedQty > 0 {
+			entryPrice = weightedEntry / closedQty
+		} else {
+			entryPrice = event.Price
+		}
+
+		if remaining > 1e-9 {
+			// Position flipped past flat: the unconsumed remainder
+			// opens a brand new lot on the other side.
+			open = append(open, openLot{qty: remaining, price: event.Price, entryTime: event.CreatedAt, fillID: event.FillID})
+			sign = direction
+		} else if len(open) == 0 {
+			sign = 0
+		}
+	}
+
+	return sign, open, buildTradeRecord(event, side, entryPrice, qty, realizedPNL, feeModel), closed, true
+}
+
+// lotIndex picks which open lot a closing fill draws down next: the
+// oldest (FIFO), the newest (LIFO), or the highest-priced (HIFO).
+func (e lotQueueEngine) lotIndex(open []openLot) int {
+	switch e.mode {
+	case AccountingLIFO:
+		return len(open) - 1
+	case AccountingHIFO:
+		idx := 0
+		for i, lot := range open {
+			if lot.price > open[idx].price {
+				idx = i
+			}
+		}
+		return idx
+	default:
+		return 0
+	}
+}
+
+// openingReferencePrice is the price shown as a TradeRecord's EntryPrice
+// when a fill only adds to an existing position (no lot is closed, so
+// there's no natural "entry" for this specific fill): the qty-weighted
+// average of the lots already open, or the fill's own price if this is
+// the first lot.
+func (lotQueueEngine) openingReferencePrice(open []openLot, fallback float64) float64 {
+	if len(open) == 0 {
+		return fallback
+	}
+	var qtySum, notionalSum float64
+	for _, lot := range open {
+		qtySum += lot.qty
+		notionalSum += lot.qty * lot.price
+	}
+	if qtySum <= 0 {
+		return fallback
+	}
+	return notionalSum / qtySum
+}
+
+// normalizedFillQty reproduces computeTrades' original fill validation:
+// fall back to notional/price when Qty wasn't populated, and skip fills
+// that still carry no usable size or price.
+func normalizedFillQty(event tradeEvent) (float64, bool) {
+	qty := math.Abs(event.Qty)
+	if qty == 0 && event.Price > 0 && event.Notional > 0 {
+		qty = event.Notional / event.Price
+	}
+	if qty == 0 || event.Price <= 0 {
+		return 0, false
+	}
+	return qty, true
+}
+
+// buildTradeRecord charges event's fee via feeModel (falling back to
+// scheduleFeeModel's zero-value defaults - no schedule/tier overrides - if
+// feeModel is nil, so existing callers that haven't been updated to load
+// one still compile and behave as before schedules existed).
+func buildTradeRecord(event tradeEvent, side string, entryPrice, qty, realizedPNL float64, feeModel FeeModel) TradeRecord {
+	if feeModel == nil {
+		feeModel = scheduleFeeModel{}
+	}
+	feeNotional := math.Abs(event.Notional)
+	if feeNotional == 0 {
+		feeNotional = qty * event.Price
+	}
+	rate, isMaker, tier := feeModel.FeeRate(event.MarketID, event.AgentID, event.OrderType)
+	fee := feeNotional * rate
+	netPnl := realizedPNL - fee
+	return TradeRecord{
+		ID:         strconv.FormatInt(event.FillID, 10),
+		AgentID:    event.AgentID,
+		AgentName:  event.AgentName,
+		Side:       side,
+		EntryPrice: entryPrice,
+		ExitPrice:  event.Price,
+		Qty:        qty,
+		Fee:        fee,
+		Pnl:        netPnl,
+		TxSig:      event.TxSig,
+		EntryTime:  event.CreatedAt,
+		ExitTime:   event.ExecutedAt,
+		MarketID:   event.MarketID,
+		GrossPnl:   realizedPNL,
+		Fees:       fee,
+		NetPnl:     netPnl,
+		FeeAsset:   defaultFeeAsset,
+		IsMaker:    isMaker,
+		FeeTier:    tier,
+	}
+}
+
+// RecomputeAgentLots replays agentID's fills through its configured
+// PnLEngine and upserts the resulting ClosedLots into agent_lots. It's an
+// explicit, on-demand reconciliation step (not run implicitly by
+// ListTrades/GetAgentPortfolioSummary on every read) so operators can
+// compare a paper agent's closed lots against a live agent's, or re-run
+// it after changing an agent's AccountingMode via PatchAgentRisk. Safe to
+// call repeatedly: the upsert key (agent_id, market_id, close_fill_id,
+// seq) makes replaying the same fills a no-op.
+func (s *Store) RecomputeAgentLots(ctx context.Context, agentID string) (int, error) {
+	agent, err := s.GetAgent(ctx, agentID)
+	if err != nil {
+		return 0, err
+	}
+	events, err := s.loadTradeEvents(ctx, agentID, 0, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	feeModel, err := s.loadFeeModel(ctx, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	mode := normalizeAccountingMode(agent.RiskProfile.AccountingMode)
+	_, lots := newPnLEngine(mode).Process(events, feeModel)
+	if len(lots) == 0 {
+		return 0, nil
+	}
+
+	err = s.WithTx(ctx, func(tx *Tx) error {
+		for _, lot := range lots {
+			if _, err := tx.ExecContext(
+				ctx,
+				`INSERT INTO agent_lots (
+					agent_id, market_id, mode, side, entry_price, exit_price, qty, pnl,
+					entry_time, exit_time, open_fill_id, close_fill_id, seq
+				) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+				ON CONFLICT (agent_id, market_id, close_fill_id, seq) DO UPDATE SET
+					mode = excluded.mode,
+					side = excluded.side,
+					entry_price = excluded.entry_price,
+					exit_price = excluded.exit_price,
+					qty = excluded.qty,
+					pnl = excluded.pnl,
+					entry_time = excluded.entry_time,
+					exit_time = excluded.exit_time,
+					open_fill_id = excluded.open_fill_id`,
+				lot.AgentID,
+				lot.MarketID,
+				string(lot.Mode),
+				lot.Side,
+				lot.EntryPrice,
+				lot.ExitPrice,
+				lot.Qty,
+				lot.Pnl,
+				lot.EntryTime,
+				lot.ExitTime,
+				lot.OpenFillID,
+				lot.CloseFillID,
+				lot.Seq,
+			); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(lots), nil
+}
@@ -0,0 +1,217 @@
+package indexer
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+)
+
+// divergenceKey identifies one source's ongoing deviation from the fused
+// price for a market.
+type divergenceKey struct {
+	market string
+	source string
+	feedID string
+}
+
+// divergenceBreach tracks how long a source has continuously deviated from
+// the fused price by more than the configured ratio, so DivergenceMonitor
+// can distinguish a brief, one-tick wobble from a sustained divergence
+// worth alerting on.
+type divergenceBreach struct {
+	since int64
+	alert bool // whether an (unresolved) market_price_alerts row has been written for this breach
+}
+
+// DivergenceMonitor periodically compares every source's latest price
+// against GetFusedMarketPrice's consensus and writes a market_price_alerts
+// row once a source has deviated by more than Ratio for at least Duration,
+// the same defensive cross-source check systems combining multiple
+// exchange/oracle quotes (e.g. Binance/MAX/Pyth) run to catch one feed
+// going stale or bad without taking the whole pipeline down.
+type DivergenceMonitor struct {
+	logger   *slog.Logger
+	ratio    float64
+	duration time.Duration
+
+	mu     sync.Mutex
+	breach map[divergenceKey]*divergenceBreach
+}
+
+func NewDivergenceMonitor(logger *slog.Logger, ratio float64, duration time.Duration) *DivergenceMonitor {
+	return &DivergenceMonitor{
+		logger:   logger,
+		ratio:    ratio,
+		duration: duration,
+		breach:   make(map[divergenceKey]*divergenceBreach),
+	}
+}
+
+// Check runs one pass for market: it fuses the latest per-source prices,
+// measures each source's deviation ratio from the fused price, and opens
+// or resolves market_price_alerts rows as breaches start and recover.
+func (m *DivergenceMonitor) Check(ctx context.Context, store *Store, market string) error {
+	fused, err := store.GetFusedMarketPrice(ctx, market)
+	if err != nil {
+		if err == ErrNotFound {
+			return nil
+		}
+		return err
+	}
+	if fused.Price <= 0 {
+		return nil
+	}
+
+	now := time.Now().Unix()
+	seen := make(map[divergenceKey]struct{}, len(fused.Sources))
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, source := range fused.Sources {
+		key := divergenceKey{market: fused.Market, source: source.Source, feedID: source.FeedID}
+		seen[key] = struct{}{}
+
+		deviation := math.Abs(source.Price-fused.Price) / fused.Price
+		if deviation <= m.ratio {
+			if b, ok := m.breach[key]; ok {
+				delete(m.breach, key)
+				if b.alert {
+					if err := store.resolveMarketPriceAlert(ctx, fused.Market, source.Source, source.FeedID, now); err != nil {
+						m.logger.Warn("failed to resolve price divergence alert", "market", fused.Market, "source", source.Source, "err", err)
+					}
+				}
+			}
+			continue
+		}
+
+		b, ok := m.breach[key]
+		if !ok {
+			b = &divergenceBreach{since: now}
+			m.breach[key] = b
+		}
+		if b.alert || time.Duration(now-b.since)*time.Second < m.duration {
+			continue
+		}
+
+		if err := store.insertMarketPriceAlert(ctx, MarketPriceAlertInput{
+			Market:         fused.Market,
+			Source:         source.Source,
+			FeedID:         source.FeedID,
+			FusedPrice:     fused.Price,
+			SourcePrice:    source.Price,
+			DeviationRatio: deviation,
+			BreachedSince:  b.since,
+			DetectedAt:     now,
+		}); err != nil {
+			m.logger.Warn("failed to record price divergence alert", "market", fused.Market, "source", source.Source, "err", err)
+			continue
+		}
+		b.alert = true
+	}
+
+	// A source that's stopped reporting entirely no longer shows up in
+	// fused.Sources; drop its breach tracking rather than leaving a stale
+	// entry that can never resolve.
+	for key := range m.breach {
+		if key.market != fused.Market {
+			continue
+		}
+		if _, ok := seen[key]; !ok {
+			delete(m.breach, key)
+		}
+	}
+
+	return nil
+}
+
+// MarketPriceAlertInput is one row written to market_price_alerts.
+type MarketPriceAlertInput struct {
+	Market         string
+	Source         string
+	FeedID         string
+	FusedPrice     float64
+	SourcePrice    float64
+	DeviationRatio float64
+	BreachedSince  int64
+	DetectedAt     int64
+}
+
+func (s *Store) insertMarketPriceAlert(ctx context.Context, input MarketPriceAlertInput) error {
+	_, err := s.db.ExecContext(
+		ctx,
+		`
+		INSERT INTO market_price_alerts (
+			market, source, feed_id, fused_price, source_price, deviation_ratio, breached_since, detected_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`,
+		input.Market,
+		input.Source,
+		input.FeedID,
+		input.FusedPrice,
+		input.SourcePrice,
+		input.DeviationRatio,
+		input.BreachedSince,
+		input.DetectedAt,
+	)
+	return err
+}
+
+func (s *Store) resolveMarketPriceAlert(ctx context.Context, market, source, feedID string, resolvedAt int64) error {
+	_, err := s.db.ExecContext(
+		ctx,
+		`
+		UPDATE market_price_alerts
+		SET resolved_at = ?
+		WHERE market = ? AND source = ? AND feed_id = ? AND resolved_at IS NULL
+		`,
+		resolvedAt,
+		market,
+		source,
+		feedID,
+	)
+	return err
+}
+
+// GetOpenMarketPriceAlerts returns every unresolved divergence alert for
+// market, newest first.
+func (s *Store) GetOpenMarketPriceAlerts(ctx context.Context, market string) ([]MarketPriceAlertInput, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`
+		SELECT market, source, feed_id, fused_price, source_price, deviation_ratio, breached_since, detected_at
+		FROM market_price_alerts
+		WHERE market = ? AND resolved_at IS NULL
+		ORDER BY detected_at DESC
+		`,
+		normalizeMarketWithDefault(market),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var alerts []MarketPriceAlertInput
+	for rows.Next() {
+		var alert MarketPriceAlertInput
+		if err := rows.Scan(
+			&alert.Market,
+			&alert.Source,
+			&alert.FeedID,
+			&alert.FusedPrice,
+			&alert.SourcePrice,
+			&alert.DeviationRatio,
+			&alert.BreachedSince,
+			&alert.DetectedAt,
+		); err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, alert)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return alerts, nil
+}
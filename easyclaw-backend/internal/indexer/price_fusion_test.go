@@ -0,0 +1,31 @@
+package indexer
+
+import "testing"
+
+func TestWeightedMedianPicksMiddleWithEqualWeights(t *testing.T) {
+	records := []MarketPriceRecord{{Price: 150}, {Price: 100}, {Price: 101}}
+	got := weightedMedian(records, []float64{1, 1, 1})
+	if got != 101 {
+		t.Fatalf("weightedMedian = %v, want 101", got)
+	}
+}
+
+func TestWeightedMedianFavorsHeavierWeight(t *testing.T) {
+	records := []MarketPriceRecord{{Price: 100}, {Price: 101}, {Price: 150}}
+	got := weightedMedian(records, []float64{10, 1, 1})
+	if got != 100 {
+		t.Fatalf("weightedMedian = %v, want 100 (dominated by the heavily-weighted source)", got)
+	}
+}
+
+func TestConfidenceWeightFallsBackToOneWhenUnusable(t *testing.T) {
+	if got := confidenceWeight(100, 0); got != 1 {
+		t.Fatalf("confidenceWeight with zero conf = %v, want 1", got)
+	}
+	if got := confidenceWeight(0, 1); got != 1 {
+		t.Fatalf("confidenceWeight with zero price = %v, want 1", got)
+	}
+	if got := confidenceWeight(100, 2); got != 0.5 {
+		t.Fatalf("confidenceWeight(100, 2) = %v, want 0.5", got)
+	}
+}
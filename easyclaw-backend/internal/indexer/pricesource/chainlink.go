@@ -0,0 +1,249 @@
+package pricesource
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coldbell/dex/backend/internal/config"
+)
+
+const chainlinkSourceName = "chainlink"
+
+// latestRoundData() selector: keccak256("latestRoundData()")[:4].
+const chainlinkLatestRoundDataSelector = "feaf968c"
+
+// ChainlinkSource polls one or more Chainlink EVM aggregator contracts via
+// JSON-RPC eth_call, decoding the ABI-encoded latestRoundData() response
+// without pulling in a full web3 client library.
+type ChainlinkSource struct {
+	feeds  []config.ChainlinkFeedConfig
+	client *http.Client
+	logger *slog.Logger
+}
+
+// NewChainlinkSource builds a Chainlink price source from the indexer's
+// configured aggregator feeds. It returns nil if none are configured.
+func NewChainlinkSource(cfg config.IndexerConfig, logger *slog.Logger) *ChainlinkSource {
+	if len(cfg.ChainlinkFeeds) == 0 {
+		return nil
+	}
+	return &ChainlinkSource{
+		feeds:  cfg.ChainlinkFeeds,
+		client: &http.Client{Timeout: 10 * time.Second},
+		logger: logger,
+	}
+}
+
+func (s *ChainlinkSource) Name() string { return chainlinkSourceName }
+
+func (s *ChainlinkSource) Run(ctx context.Context, emit EmitFunc) error {
+	var wg sync.WaitGroup
+	for _, feed := range s.feeds {
+		wg.Add(1)
+		go func(feed config.ChainlinkFeedConfig) {
+			defer wg.Done()
+			s.pollFeed(ctx, feed, emit)
+		}(feed)
+	}
+	wg.Wait()
+	return nil
+}
+
+func (s *ChainlinkSource) pollFeed(ctx context.Context, feed config.ChainlinkFeedConfig, emit EmitFunc) {
+	pollInterval := feed.PollInterval
+	if pollInterval <= 0 {
+		pollInterval = 15 * time.Second
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.fetchRound(ctx, feed, emit); err != nil {
+			s.logger.Warn(
+				"chainlink aggregator poll failed",
+				"market", feed.Market,
+				"aggregator", feed.AggregatorAddress,
+				"err", err,
+			)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *ChainlinkSource) fetchRound(ctx context.Context, feed config.ChainlinkFeedConfig, emit EmitFunc) error {
+	round, err := chainlinkLatestRoundData(ctx, s.client, feed.RPCURL, feed.AggregatorAddress)
+	if err != nil {
+		return err
+	}
+
+	decimals := feed.PriceDecimals
+	if decimals == 0 {
+		decimals = 8
+	}
+	price, _ := new(big.Float).Quo(
+		new(big.Float).SetInt(round.answer),
+		new(big.Float).SetFloat64(pow10(decimals)),
+	).Float64()
+	if price <= 0 {
+		return fmt.Errorf("non-positive chainlink answer for round %s", round.roundID.String())
+	}
+
+	now := time.Now().Unix()
+	rawJSON, err := json.Marshal(round)
+	if err != nil {
+		rawJSON = []byte("{}")
+	}
+
+	return emit(Tick{
+		Market:      feed.Market,
+		Source:      chainlinkSourceName,
+		FeedID:      strings.ToLower(feed.AggregatorAddress),
+		Slot:        round.roundID.Int64(),
+		PublishTime: round.updatedAt.Int64(),
+		Price:       price,
+		Expo:        -decimals,
+		ReceivedAt:  now,
+		RawJSON:     string(rawJSON),
+	})
+}
+
+func pow10(n int32) float64 {
+	result := 1.0
+	for i := int32(0); i < n; i++ {
+		result *= 10
+	}
+	return result
+}
+
+// chainlinkRound holds the decoded latestRoundData() return tuple:
+// (roundId uint80, answer int256, startedAt uint256, updatedAt uint256,
+// answeredInRound uint80).
+type chainlinkRound struct {
+	roundID         *big.Int
+	answer          *big.Int
+	startedAt       *big.Int
+	updatedAt       *big.Int
+	answeredInRound *big.Int
+}
+
+func (r chainlinkRound) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]string{
+		"round_id":          r.roundID.String(),
+		"answer":            r.answer.String(),
+		"started_at":        r.startedAt.String(),
+		"updated_at":        r.updatedAt.String(),
+		"answered_in_round": r.answeredInRound.String(),
+	})
+}
+
+type jsonRPCRequest struct {
+	JSONRPC string `json:"jsonrpc"`
+	ID      int    `json:"id"`
+	Method  string `json:"method"`
+	Params  []any  `json:"params"`
+}
+
+type jsonRPCResponse struct {
+	Result string `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func chainlinkLatestRoundData(ctx context.Context, client *http.Client, rpcURL string, aggregator string) (chainlinkRound, error) {
+	reqBody, err := json.Marshal(jsonRPCRequest{
+		JSONRPC: "2.0",
+		ID:      1,
+		Method:  "eth_call",
+		Params: []any{
+			map[string]string{
+				"to":   aggregator,
+				"data": "0x" + chainlinkLatestRoundDataSelector,
+			},
+			"latest",
+		},
+	})
+	if err != nil {
+		return chainlinkRound{}, fmt.Errorf("encode eth_call request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rpcURL, bytes.NewReader(reqBody))
+	if err != nil {
+		return chainlinkRound{}, fmt.Errorf("build eth_call request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return chainlinkRound{}, fmt.Errorf("eth_call request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return chainlinkRound{}, fmt.Errorf("read eth_call response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return chainlinkRound{}, fmt.Errorf("eth_call status=%d body=%s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var rpcResp jsonRPCResponse
+	if err := json.Unmarshal(body, &rpcResp); err != nil {
+		return chainlinkRound{}, fmt.Errorf("decode eth_call response: %w", err)
+	}
+	if rpcResp.Error != nil {
+		return chainlinkRound{}, fmt.Errorf("eth_call error: %s", rpcResp.Error.Message)
+	}
+
+	return decodeLatestRoundData(rpcResp.Result)
+}
+
+// decodeLatestRoundData parses the ABI encoding of latestRoundData()'s
+// five-word return tuple: each word is a 32-byte big-endian integer, with
+// answer being the only signed (two's complement) one.
+func decodeLatestRoundData(hexResult string) (chainlinkRound, error) {
+	raw := strings.TrimPrefix(strings.TrimSpace(hexResult), "0x")
+	data, err := hex.DecodeString(raw)
+	if err != nil {
+		return chainlinkRound{}, fmt.Errorf("decode eth_call result: %w", err)
+	}
+	if len(data) < 5*32 {
+		return chainlinkRound{}, fmt.Errorf("eth_call result too short: %d bytes", len(data))
+	}
+
+	return chainlinkRound{
+		roundID:         new(big.Int).SetBytes(data[0:32]),
+		answer:          decodeABIInt256(data[32:64]),
+		startedAt:       new(big.Int).SetBytes(data[64:96]),
+		updatedAt:       new(big.Int).SetBytes(data[96:128]),
+		answeredInRound: new(big.Int).SetBytes(data[128:160]),
+	}, nil
+}
+
+// decodeABIInt256 interprets a 32-byte big-endian word as a two's
+// complement signed integer, since Chainlink's answer field is int256.
+func decodeABIInt256(word []byte) *big.Int {
+	value := new(big.Int).SetBytes(word)
+	if len(word) > 0 && word[0]&0x80 != 0 {
+		modulus := new(big.Int).Lsh(big.NewInt(1), uint(len(word)*8))
+		value.Sub(value, modulus)
+	}
+	return value
+}
@@ -0,0 +1,810 @@
+package pricesource
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/coldbell/dex/backend/internal/config"
+	"github.com/gorilla/websocket"
+)
+
+const pythSourceName = "pyth"
+
+type pythStreamEnvelope struct {
+	Parsed []pythPriceUpdate `json:"parsed"`
+}
+
+type pythPriceUpdate struct {
+	ID       string            `json:"id"`
+	Price    pythPriceSnapshot `json:"price"`
+	Metadata pythMetadata      `json:"metadata"`
+}
+
+type pythPriceSnapshot struct {
+	Price       string `json:"price"`
+	Conf        string `json:"conf"`
+	Expo        int32  `json:"expo"`
+	PublishTime int64  `json:"publish_time"`
+}
+
+type pythMetadata struct {
+	Slot int64 `json:"slot"`
+}
+
+// PythFeedStatus reports the observed health of a single routed Pyth feed,
+// used by callers that want visibility into per-feed staleness without
+// reaching into the source's internals.
+type PythFeedStatus struct {
+	FeedID        string
+	Market        string
+	LastSlot      int64
+	LastPublishAt int64
+	UpdateCount   uint64
+}
+
+// pythFeedRouter maps feed ids to the market they should be recorded
+// against and tracks last-seen slot/publish time per feed so operators can
+// tell a healthy multiplexed subscription apart from one silently missing
+// a feed.
+type pythFeedRouter struct {
+	mu     sync.RWMutex
+	market map[string]string
+	status map[string]*PythFeedStatus
+}
+
+func newPythFeedRouter(feeds []config.PythFeed) *pythFeedRouter {
+	router := &pythFeedRouter{
+		market: make(map[string]string, len(feeds)),
+		status: make(map[string]*PythFeedStatus, len(feeds)),
+	}
+	for _, feed := range feeds {
+		router.market[feed.FeedID] = feed.Market
+		router.status[feed.FeedID] = &PythFeedStatus{FeedID: feed.FeedID, Market: feed.Market}
+	}
+	return router
+}
+
+func (r *pythFeedRouter) feedIDs() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ids := make([]string, 0, len(r.market))
+	for feedID := range r.market {
+		ids = append(ids, feedID)
+	}
+	return ids
+}
+
+func (r *pythFeedRouter) marketFor(feedID string) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	market, ok := r.market[feedID]
+	return market, ok
+}
+
+// recordUpdate records the slot/publish time of the last *accepted* tick
+// for feedID; callers must only invoke it once a tick has passed quality
+// gating, since lastAccepted is also used to detect out-of-order slots.
+func (r *pythFeedRouter) recordUpdate(feedID string, slot int64, publishTime int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	status, ok := r.status[feedID]
+	if !ok {
+		return
+	}
+	status.LastSlot = slot
+	status.LastPublishAt = publishTime
+	status.UpdateCount++
+}
+
+// lastAccepted returns the slot of the last tick accepted for feedID, if
+// any has been recorded yet.
+func (r *pythFeedRouter) lastAccepted(feedID string) (int64, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	status, ok := r.status[feedID]
+	if !ok || status.UpdateCount == 0 {
+		return 0, false
+	}
+	return status.LastSlot, true
+}
+
+// isDuplicate reports whether (slot, publishTime) exactly matches the last
+// tick accepted for feedID. Hermes redelivers its latest update on every
+// keepalive and on reconnect, so without this check a steady-state stream
+// with no new price would still hit InsertMarketPriceTick on every
+// keepalive; the DB's own (market, source, publish_time, slot) unique
+// constraint would no-op the insert, but only after a round trip.
+func (r *pythFeedRouter) isDuplicate(feedID string, slot, publishTime int64) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	status, ok := r.status[feedID]
+	if !ok || status.UpdateCount == 0 {
+		return false
+	}
+	return status.LastSlot == slot && status.LastPublishAt == publishTime
+}
+
+// reload replaces the routed feed set in place, so adding or removing a
+// market from the subscription does not require restarting the stream.
+// Status counters for feeds that remain subscribed are preserved.
+func (r *pythFeedRouter) reload(feeds []config.PythFeed) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	nextMarket := make(map[string]string, len(feeds))
+	nextStatus := make(map[string]*PythFeedStatus, len(feeds))
+	for _, feed := range feeds {
+		nextMarket[feed.FeedID] = feed.Market
+		if existing, ok := r.status[feed.FeedID]; ok {
+			existing.Market = feed.Market
+			nextStatus[feed.FeedID] = existing
+			continue
+		}
+		nextStatus[feed.FeedID] = &PythFeedStatus{FeedID: feed.FeedID, Market: feed.Market}
+	}
+	r.market = nextMarket
+	r.status = nextStatus
+}
+
+// Snapshot returns the current observed status of every routed feed,
+// primarily intended for status/health endpoints.
+func (r *pythFeedRouter) Snapshot() []PythFeedStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	out := make([]PythFeedStatus, 0, len(r.status))
+	for _, status := range r.status {
+		out = append(out, *status)
+	}
+	return out
+}
+
+// PythQualityStats is a snapshot of how many ticks a PythSource has
+// accepted versus dropped by each quality gate, for logging or export via
+// a metrics hook.
+type PythQualityStats struct {
+	Accepted          uint64
+	DroppedStale      uint64
+	DroppedLowConf    uint64
+	DroppedOutOfOrder uint64
+	DroppedDuplicate  uint64
+}
+
+// PythSource streams price updates from Pyth Hermes (SSE or websocket) or
+// Pyth Lazer, routing each update to the market it was configured for.
+type PythSource struct {
+	cfg    config.IndexerConfig
+	logger *slog.Logger
+	router *pythFeedRouter
+
+	accepted          atomic.Uint64
+	droppedStale      atomic.Uint64
+	droppedLowConf    atomic.Uint64
+	droppedOutOfOrder atomic.Uint64
+	droppedDuplicate  atomic.Uint64
+}
+
+// NewPythSource builds a Pyth price source from the indexer's Pyth
+// configuration. It returns nil if no feeds are configured.
+func NewPythSource(cfg config.IndexerConfig, logger *slog.Logger) *PythSource {
+	if len(cfg.PythFeeds) == 0 {
+		return nil
+	}
+	return &PythSource{
+		cfg:    cfg,
+		logger: logger,
+		router: newPythFeedRouter(cfg.PythFeeds),
+	}
+}
+
+// QualityStats returns a snapshot of accept/drop counters for the
+// confidence-interval and staleness gates, suitable for a metrics hook.
+func (s *PythSource) QualityStats() PythQualityStats {
+	return PythQualityStats{
+		Accepted:          s.accepted.Load(),
+		DroppedStale:      s.droppedStale.Load(),
+		DroppedLowConf:    s.droppedLowConf.Load(),
+		DroppedOutOfOrder: s.droppedOutOfOrder.Load(),
+		DroppedDuplicate:  s.droppedDuplicate.Load(),
+	}
+}
+
+func (s *PythSource) Name() string { return pythSourceName }
+
+// FeedStatuses exposes the current per-feed health of the multiplexed
+// Pyth subscription for observability endpoints.
+func (s *PythSource) FeedStatuses() []PythFeedStatus {
+	return s.router.Snapshot()
+}
+
+// ReloadFeeds swaps in a new set of routed feeds without restarting the
+// underlying stream connection; the next reconnect will also pick up any
+// change to the subscribed id list.
+func (s *PythSource) ReloadFeeds(feeds []config.PythFeed) {
+	s.router.reload(feeds)
+}
+
+// priceTransport abstracts the wire protocol used to receive Pyth price
+// updates so the reconnect/backoff loop in Run can stay
+// transport-agnostic. Hermes SSE and Hermes/Lazer websocket feeds both
+// satisfy it.
+type priceTransport interface {
+	// Connect establishes the underlying connection for the given feed ids.
+	Connect(ctx context.Context, feedIDs []string) error
+	// Next blocks until the next price update envelope is available, or
+	// returns an error (including context cancellation) if the connection
+	// is no longer usable.
+	Next(ctx context.Context) (pythStreamEnvelope, error)
+	Close() error
+}
+
+func newPriceTransport(cfg config.IndexerConfig) (priceTransport, error) {
+	switch strings.ToLower(strings.TrimSpace(cfg.PythTransport)) {
+	case "", "sse":
+		return &ssePriceTransport{endpoint: cfg.PythStreamURL, client: &http.Client{}, idleTimeout: cfg.PythIdleTimeout}, nil
+	case "ws", "lazer":
+		return &wsPriceTransport{endpoint: cfg.PythWSStreamURL}, nil
+	default:
+		return nil, fmt.Errorf("unsupported pyth transport %q", cfg.PythTransport)
+	}
+}
+
+func (s *PythSource) Run(ctx context.Context, emit EmitFunc) error {
+	transport, err := newPriceTransport(s.cfg)
+	if err != nil {
+		return fmt.Errorf("build pyth price transport: %w", err)
+	}
+
+	baseDelay := s.cfg.PythReconnectInterval
+	if baseDelay <= 0 {
+		baseDelay = 3 * time.Second
+	}
+	maxDelay := s.cfg.PythMaxReconnectInterval
+	if maxDelay < baseDelay {
+		maxDelay = baseDelay
+	}
+
+	s.logger.Info(
+		"pyth price stream enabled",
+		"transport", s.cfg.PythTransport,
+		"feed_count", len(s.cfg.PythFeeds),
+		"reconnect_base_delay", baseDelay.String(),
+		"reconnect_max_delay", maxDelay.String(),
+	)
+
+	delay := baseDelay
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil
+		}
+
+		sawEvent, err := s.consume(ctx, transport, emit)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			s.logger.Warn(
+				"pyth price stream disconnected",
+				"err", err,
+				"reason", classifyPythStreamError(err),
+				"retry_in", delay.String(),
+			)
+		}
+
+		if sawEvent {
+			delay = baseDelay
+		} else {
+			delay = nextPythBackoff(delay, baseDelay, maxDelay)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+	}
+}
+
+// consume runs a single connect-and-read cycle against the transport. It
+// returns sawEvent=true if at least one price update was successfully
+// processed, which Run uses to reset the reconnect backoff to its floor.
+func (s *PythSource) consume(ctx context.Context, transport priceTransport, emit EmitFunc) (bool, error) {
+	feedIDs := s.router.feedIDs()
+	if err := transport.Connect(ctx, feedIDs); err != nil {
+		return false, fmt.Errorf("connect pyth transport: %w", err)
+	}
+	defer transport.Close()
+
+	sawEvent := false
+	for {
+		envelope, err := transport.Next(ctx)
+		if err != nil {
+			return sawEvent, err
+		}
+		if err := s.processEnvelope(envelope, emit); err != nil && !errors.Is(err, context.Canceled) {
+			s.logger.Warn("failed to process pyth price update", "err", err)
+			continue
+		}
+		sawEvent = true
+	}
+}
+
+func (s *PythSource) processEnvelope(event pythStreamEnvelope, emit EmitFunc) error {
+	if len(event.Parsed) == 0 {
+		return nil
+	}
+
+	now := time.Now().Unix()
+	for _, update := range event.Parsed {
+		updateID := strings.ToLower(strings.TrimSpace(update.ID))
+		if updateID == "" {
+			continue
+		}
+		market, routed := s.router.marketFor(updateID)
+		if !routed {
+			continue
+		}
+
+		price, err := decodePythPrice(update.Price.Price, update.Price.Expo)
+		if err != nil || price <= 0 {
+			continue
+		}
+		conf, err := decodePythPrice(update.Price.Conf, update.Price.Expo)
+		if err != nil {
+			conf = 0
+		}
+
+		publishTime := update.Price.PublishTime
+		if publishTime <= 0 {
+			publishTime = now
+		}
+
+		if s.router.isDuplicate(updateID, update.Metadata.Slot, publishTime) {
+			s.droppedDuplicate.Add(1)
+			continue
+		}
+
+		rawUpdate, err := json.Marshal(update)
+		if err != nil {
+			rawUpdate = []byte("{}")
+		}
+
+		flags := s.qualityFlags(updateID, price, conf, publishTime, update.Metadata.Slot, now)
+		if flags != 0 {
+			s.logger.Warn(
+				"pyth tick failed quality gate",
+				"feed_id", updateID,
+				"market", market,
+				"flags", flags,
+			)
+		}
+
+		if err := emit(Tick{
+			Market:       market,
+			Source:       pythSourceName,
+			FeedID:       updateID,
+			Slot:         update.Metadata.Slot,
+			PublishTime:  publishTime,
+			Price:        price,
+			Conf:         conf,
+			Expo:         update.Price.Expo,
+			ReceivedAt:   now,
+			RawJSON:      string(rawUpdate),
+			QualityFlags: flags,
+		}); err != nil {
+			return fmt.Errorf("emit pyth tick: %w", err)
+		}
+
+		if flags == 0 {
+			s.router.recordUpdate(updateID, update.Metadata.Slot, publishTime)
+			s.accepted.Add(1)
+		}
+	}
+
+	return nil
+}
+
+// qualityFlags evaluates a decoded tick against the configured
+// confidence-interval, staleness, and slot-ordering gates. It returns the
+// bitfield of gates the tick failed, or zero if the tick is clean. Gated
+// ticks are still emitted (see processEnvelope) rather than silently
+// dropped, so operators can retain them for post-hoc analysis; only clean
+// ticks advance the feed router's last-accepted slot.
+func (s *PythSource) qualityFlags(feedID string, price, conf float64, publishTime, slot, now int64) QualityFlags {
+	var flags QualityFlags
+
+	if s.cfg.PythMaxConfBps > 0 && price > 0 {
+		confBps := uint64((conf / price) * 10_000)
+		if confBps > s.cfg.PythMaxConfBps {
+			flags |= QualityFlagLowConf
+			s.droppedLowConf.Add(1)
+		}
+	}
+
+	if s.cfg.PythMaxStaleness > 0 {
+		age := time.Duration(now-publishTime) * time.Second
+		if age > s.cfg.PythMaxStaleness {
+			flags |= QualityFlagStale
+			s.droppedStale.Add(1)
+		}
+	}
+
+	if lastSlot, ok := s.router.lastAccepted(feedID); ok && slot < lastSlot {
+		if lastSlot-slot > s.cfg.PythMinSlotDelta {
+			flags |= QualityFlagOutOfOrder
+			s.droppedOutOfOrder.Add(1)
+		}
+	}
+
+	return flags
+}
+
+// nextPythBackoff computes a decorrelated-jitter backoff delay: the next
+// delay is a random value between the floor and three times the previous
+// delay, capped at max. This avoids the thundering-herd reconnect pattern
+// of plain exponential backoff while still growing the retry interval.
+func nextPythBackoff(prev, floor, max time.Duration) time.Duration {
+	if floor <= 0 {
+		floor = time.Second
+	}
+	if max < floor {
+		max = floor
+	}
+	if prev < floor {
+		prev = floor
+	}
+
+	upper := prev * 3
+	if upper <= floor {
+		return floor
+	}
+	if upper > max {
+		upper = max
+	}
+
+	span := upper - floor
+	if span <= 0 {
+		return floor
+	}
+	next := floor + time.Duration(rand.Int63n(int64(span)))
+	if next > max {
+		return max
+	}
+	return next
+}
+
+var errPythIdleTimeout = errors.New("pyth stream idle timeout")
+
+// pythStreamHTTPError distinguishes a non-2xx Hermes response from a
+// transport-level network error so operators can alert on them
+// differently.
+type pythStreamHTTPError struct {
+	status int
+	body   string
+}
+
+func (e *pythStreamHTTPError) Error() string {
+	return fmt.Sprintf("open pyth stream: status=%d body=%s", e.status, e.body)
+}
+
+// classifyPythStreamError labels a disconnect reason for structured
+// logging/alerting: idle_timeout, http_error, or network_error.
+func classifyPythStreamError(err error) string {
+	if errors.Is(err, errPythIdleTimeout) {
+		return "idle_timeout"
+	}
+	var httpErr *pythStreamHTTPError
+	if errors.As(err, &httpErr) {
+		return "http_error"
+	}
+	return "network_error"
+}
+
+// ssePriceTransport consumes Hermes's server-sent-events price stream. An
+// idle-read timer cancels the request (and unblocks the scanner) if no
+// `data:` line arrives within idleTimeout, since a half-open TCP
+// connection would otherwise hang the scanner indefinitely.
+type ssePriceTransport struct {
+	endpoint    string
+	client      *http.Client
+	idleTimeout time.Duration
+
+	cancel       context.CancelFunc
+	idleTimer    *time.Timer
+	idleTimedOut atomic.Bool
+	resp         *http.Response
+	scanner      *bufio.Scanner
+}
+
+func (t *ssePriceTransport) Connect(ctx context.Context, feedIDs []string) error {
+	streamURL, err := buildPythStreamURL(t.endpoint, feedIDs)
+	if err != nil {
+		return err
+	}
+
+	reqCtx, cancel := context.WithCancel(ctx)
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, streamURL, nil)
+	if err != nil {
+		cancel()
+		return fmt.Errorf("build pyth stream request: %w", err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		cancel()
+		return fmt.Errorf("open pyth stream: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		cancel()
+		return &pythStreamHTTPError{status: resp.StatusCode, body: strings.TrimSpace(string(body))}
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 1024), 64*1024*1024)
+
+	t.cancel = cancel
+	t.resp = resp
+	t.scanner = scanner
+	t.idleTimedOut.Store(false)
+	t.armIdleTimer()
+	return nil
+}
+
+func (t *ssePriceTransport) armIdleTimer() {
+	if t.idleTimeout <= 0 {
+		return
+	}
+	if t.idleTimer != nil {
+		t.idleTimer.Stop()
+	}
+	t.idleTimer = time.AfterFunc(t.idleTimeout, func() {
+		t.idleTimedOut.Store(true)
+		t.cancel()
+	})
+}
+
+func (t *ssePriceTransport) Next(ctx context.Context) (pythStreamEnvelope, error) {
+	var eventData strings.Builder
+	for t.scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return pythStreamEnvelope{}, err
+		}
+
+		line := strings.TrimSpace(t.scanner.Text())
+		if line == "" {
+			if eventData.Len() == 0 {
+				continue
+			}
+			return decodePythStreamEvent(eventData.String())
+		}
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		payload := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if payload == "" {
+			continue
+		}
+		t.armIdleTimer()
+		if eventData.Len() > 0 {
+			eventData.WriteByte('\n')
+		}
+		eventData.WriteString(payload)
+	}
+
+	if err := t.scanner.Err(); err != nil {
+		if t.idleTimedOut.Load() {
+			return pythStreamEnvelope{}, fmt.Errorf("%w after %s", errPythIdleTimeout, t.idleTimeout)
+		}
+		return pythStreamEnvelope{}, fmt.Errorf("read pyth stream: %w", err)
+	}
+	if eventData.Len() > 0 {
+		return decodePythStreamEvent(eventData.String())
+	}
+	return pythStreamEnvelope{}, io.EOF
+}
+
+func (t *ssePriceTransport) Close() error {
+	if t.idleTimer != nil {
+		t.idleTimer.Stop()
+	}
+	if t.cancel != nil {
+		t.cancel()
+	}
+	if t.resp == nil {
+		return nil
+	}
+	return t.resp.Body.Close()
+}
+
+func decodePythStreamEvent(raw string) (pythStreamEnvelope, error) {
+	payload := strings.TrimSpace(raw)
+	if payload == "" || payload == "[DONE]" {
+		return pythStreamEnvelope{}, nil
+	}
+
+	var event pythStreamEnvelope
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		return pythStreamEnvelope{}, fmt.Errorf("decode pyth stream event: %w", err)
+	}
+	return event, nil
+}
+
+const (
+	pythWSWriteTimeout      = 5 * time.Second
+	pythWSPingInterval      = 15 * time.Second
+	websocketReadLimitBytes = 16 << 20
+)
+
+// pythWSSubscribeFrame asks Hermes (or Lazer) to start streaming updates
+// for the given price feed ids.
+type pythWSSubscribeFrame struct {
+	Type string   `json:"type"`
+	IDs  []string `json:"ids"`
+}
+
+// pythWSMessage is the typed envelope used by the websocket transport; it
+// carries either a price update, a keepalive pong, or an error from the
+// server.
+type pythWSMessage struct {
+	Type          string            `json:"type"`
+	PriceFeed     *pythPriceUpdate  `json:"price_feed"`
+	ParsedUpdates []pythPriceUpdate `json:"parsed"`
+	Error         string            `json:"error"`
+}
+
+// wsPriceTransport consumes Hermes's or Pyth Lazer's websocket price
+// stream. Both expose a typed JSON frame protocol with subscribe/
+// unsubscribe and ping/pong keepalive, so a single implementation serves
+// both "ws" and "lazer" transport modes.
+type wsPriceTransport struct {
+	endpoint string
+
+	conn     *websocket.Conn
+	stopPing func()
+}
+
+func (t *wsPriceTransport) Connect(ctx context.Context, feedIDs []string) error {
+	dialer := websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: 10 * time.Second,
+	}
+	conn, _, err := dialer.DialContext(ctx, t.endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("dial pyth websocket: %w", err)
+	}
+	conn.SetReadLimit(websocketReadLimitBytes)
+
+	if err := writePythWSJSON(conn, pythWSSubscribeFrame{Type: "subscribe", IDs: feedIDs}); err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("subscribe pyth websocket: %w", err)
+	}
+
+	t.conn = conn
+	t.stopPing = t.startPingLoop(ctx, conn)
+	return nil
+}
+
+func writePythWSJSON(conn *websocket.Conn, value any) error {
+	if err := conn.SetWriteDeadline(time.Now().Add(pythWSWriteTimeout)); err != nil {
+		return err
+	}
+	return conn.WriteJSON(value)
+}
+
+func (t *wsPriceTransport) startPingLoop(ctx context.Context, conn *websocket.Conn) func() {
+	ticker := time.NewTicker(pythWSPingInterval)
+	done := make(chan struct{})
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := writePythWSJSON(conn, pythWSSubscribeFrame{Type: "ping"}); err != nil {
+					return
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+func (t *wsPriceTransport) Next(ctx context.Context) (pythStreamEnvelope, error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return pythStreamEnvelope{}, err
+		}
+
+		var message pythWSMessage
+		if err := t.conn.ReadJSON(&message); err != nil {
+			return pythStreamEnvelope{}, fmt.Errorf("read pyth websocket: %w", err)
+		}
+
+		switch message.Type {
+		case "pong", "subscribed", "unsubscribed":
+			continue
+		case "error":
+			return pythStreamEnvelope{}, fmt.Errorf("pyth websocket error: %s", message.Error)
+		}
+
+		if message.PriceFeed != nil {
+			return pythStreamEnvelope{Parsed: []pythPriceUpdate{*message.PriceFeed}}, nil
+		}
+		if len(message.ParsedUpdates) > 0 {
+			return pythStreamEnvelope{Parsed: message.ParsedUpdates}, nil
+		}
+	}
+}
+
+func (t *wsPriceTransport) Close() error {
+	if t.stopPing != nil {
+		t.stopPing()
+	}
+	if t.conn == nil {
+		return nil
+	}
+	return t.conn.Close()
+}
+
+func buildPythStreamURL(endpoint string, feedIDs []string) (string, error) {
+	parsedURL, err := url.Parse(strings.TrimSpace(endpoint))
+	if err != nil {
+		return "", fmt.Errorf("parse pyth endpoint: %w", err)
+	}
+	if parsedURL.Scheme == "" || parsedURL.Host == "" {
+		return "", fmt.Errorf("invalid pyth endpoint: %q", endpoint)
+	}
+	if len(feedIDs) == 0 {
+		return "", fmt.Errorf("no pyth feed ids configured")
+	}
+
+	query := parsedURL.Query()
+	query.Del("ids[]")
+	for _, feedID := range feedIDs {
+		query.Add("ids[]", feedID)
+	}
+	if strings.TrimSpace(query.Get("parsed")) == "" {
+		query.Set("parsed", "true")
+	}
+	parsedURL.RawQuery = query.Encode()
+
+	return parsedURL.String(), nil
+}
+
+func decodePythPrice(raw string, expo int32) (float64, error) {
+	trimmed := strings.TrimSpace(raw)
+	if trimmed == "" {
+		return 0, fmt.Errorf("empty price")
+	}
+
+	value, err := strconv.ParseFloat(trimmed, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	if expo < 0 {
+		return value / math.Pow10(int(-expo)), nil
+	}
+	if expo > 0 {
+		return value * math.Pow10(int(expo)), nil
+	}
+	return value, nil
+}
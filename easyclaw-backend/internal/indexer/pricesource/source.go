@@ -0,0 +1,50 @@
+// Package pricesource defines the plugin-style interface that every
+// market price oracle integration (Pyth, Chainlink, ...) implements, in
+// the spirit of Telegraf-style input plugins: each source runs
+// independently and pushes ticks through a shared emit callback rather
+// than the indexer service reaching into source-specific internals.
+package pricesource
+
+import "context"
+
+// QualityFlags is a bitfield describing why a tick failed a source's
+// quality gates. A zero value means the tick is clean and should land in
+// the primary price table; a non-zero value means it should be retained
+// for post-hoc analysis rather than silently discarded.
+type QualityFlags uint32
+
+const (
+	QualityFlagStale QualityFlags = 1 << iota
+	QualityFlagLowConf
+	QualityFlagOutOfOrder
+)
+
+// Tick is a single observed price sample from an oracle source, decoupled
+// from the indexer's storage layer so sources don't need to depend on it.
+type Tick struct {
+	Market       string
+	Source       string
+	FeedID       string
+	Slot         int64
+	PublishTime  int64
+	Price        float64
+	Conf         float64
+	Expo         int32
+	ReceivedAt   int64
+	RawJSON      string
+	QualityFlags QualityFlags
+}
+
+// EmitFunc persists a single tick. Sources call it once per observed
+// update; a non-nil error is logged by the source but does not stop it
+// from continuing to run.
+type EmitFunc func(Tick) error
+
+// Source is a single pluggable price oracle integration. Run should block
+// until ctx is cancelled or the source gives up, handling its own
+// reconnects/retries internally; the caller starts each enabled source in
+// its own goroutine.
+type Source interface {
+	Name() string
+	Run(ctx context.Context, emit EmitFunc) error
+}
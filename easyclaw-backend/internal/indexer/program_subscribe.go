@@ -0,0 +1,227 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	wsrpc "github.com/gagliardetto/solana-go/rpc/ws"
+)
+
+// programSubscribeChannelSize bounds the per-program buffered channel that
+// serializes pushed notifications into single WithTx writes, so a burst of
+// notifications can't spawn overlapping transactions against the same rows
+// a concurrent syncOnce sweep is also writing.
+const programSubscribeChannelSize = 256
+
+// programSubscribeNotification is one pushed account update, already
+// stripped of its subscription envelope.
+type programSubscribeNotification struct {
+	pubkey  solana.PublicKey
+	account *rpc.Account
+	slot    uint64
+}
+
+// runProgramSubscriptions starts one push-based ingestion goroutine per
+// enabled program when INDEXER_WS_URL and INDEXER_ENABLE_PROGRAM_SUBSCRIBE
+// are configured. Each goroutine holds a programSubscribe connection open
+// and dispatches notifications through the same per-discriminator handlers
+// scanAndStore uses (via orderEngineEntries/marketRegistryEntries/
+// lpVaultEntries), so a pushed update and a polled one upsert identically.
+// The existing ticker-driven syncOnce keeps running unconditionally as a
+// reconciliation sweep that heals any notification a dropped connection or
+// missed message left stale. Fork detection and rewind also live solely in
+// syncOnce, not here: a push notification during a fork just upserts
+// whatever the (possibly soon-to-be-orphaned) account looked like, and
+// syncOnce's next pass rewinds and force-reconciles it away once the fork
+// is confirmed, the same way it heals a missed notification.
+func (s *Service) runProgramSubscriptions(ctx context.Context) {
+	wsURL := strings.TrimSpace(s.cfg.WSURL)
+	if !s.cfg.EnableProgramSubscribe || wsURL == "" {
+		return
+	}
+
+	if s.cfg.EnableOrderEngineSubscribe {
+		go s.subscribeProgramLoop(ctx, "order_engine", s.cfg.OrderEngineProgramID, s.processOrderEngineNotification)
+	}
+	if s.cfg.EnableMarketRegistrySubscribe {
+		go s.subscribeProgramLoop(ctx, "market_registry", s.cfg.MarketRegistryProgramID, s.processMarketRegistryNotification)
+	}
+	if s.cfg.EnableLpVaultSubscribe {
+		go s.subscribeProgramLoop(ctx, "lp_vault", s.cfg.LpVaultProgramID, s.processLpVaultNotification)
+	}
+}
+
+// subscribeProgramLoop holds one programSubscribe connection open for
+// programID, reconnecting with nextBackoff (shared with
+// orderbookCollector's worker loop) on any error, and serializes every
+// notification through a bounded channel into process so pushed writes
+// never run concurrently against each other.
+func (s *Service) subscribeProgramLoop(ctx context.Context, name string, programID solana.PublicKey, process func(ctx context.Context, pubkey solana.PublicKey, account *rpc.Account, slot uint64) error) {
+	notifications := make(chan programSubscribeNotification, programSubscribeChannelSize)
+	go s.drainProgramSubscription(ctx, name, notifications, process)
+	defer close(notifications)
+
+	backoff := time.Duration(0)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		err := s.subscribeProgramOnce(ctx, programID, notifications)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			backoff = nextBackoff(backoff, time.Second)
+			s.logger.Warn("program subscription dropped, reconnecting",
+				"program", name,
+				"backoff", backoff.String(),
+				"err", err,
+			)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			continue
+		}
+		backoff = 0
+	}
+}
+
+// subscribeProgramOnce opens one programSubscribe connection and forwards
+// every notification to notifications until the connection errors or ctx
+// is cancelled.
+func (s *Service) subscribeProgramOnce(ctx context.Context, programID solana.PublicKey, notifications chan<- programSubscribeNotification) error {
+	client, err := wsrpc.Connect(ctx, s.cfg.WSURL)
+	if err != nil {
+		return fmt.Errorf("connect: %w", err)
+	}
+	defer client.Close()
+
+	sub, err := client.ProgramSubscribeWithOpts(programID, s.cfg.Commitment, solana.EncodingBase64, nil)
+	if err != nil {
+		return fmt.Errorf("program subscribe: %w", err)
+	}
+	defer sub.Unsubscribe()
+
+	for {
+		got, err := sub.Recv(ctx)
+		if err != nil {
+			return fmt.Errorf("recv: %w", err)
+		}
+
+		select {
+		case notifications <- programSubscribeNotification{
+			pubkey:  got.Value.Pubkey,
+			account: got.Value.Account,
+			slot:    got.Context.Slot,
+		}:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// drainProgramSubscription serializes notifications into process one at a
+// time, so pushed writes for one program never race against each other.
+func (s *Service) drainProgramSubscription(ctx context.Context, name string, notifications <-chan programSubscribeNotification, process func(ctx context.Context, pubkey solana.PublicKey, account *rpc.Account, slot uint64) error) {
+	for notification := range notifications {
+		if err := process(ctx, notification.pubkey, notification.account, notification.slot); err != nil {
+			s.logger.Warn("failed to index pushed account update",
+				"program", name,
+				"pubkey", notification.pubkey,
+				"err", err,
+			)
+		}
+	}
+}
+
+// processOrderEngineNotification upserts one pushed order engine account
+// update through orderEngineEntries' handlers, inside its own transaction
+// so it commits independently of any concurrent syncOnce sweep.
+func (s *Service) processOrderEngineNotification(ctx context.Context, pubkey solana.PublicKey, account *rpc.Account, slot uint64) error {
+	if !s.Toggles().ConsiderOrderEngine {
+		return nil
+	}
+
+	stats := map[string]int{}
+	batch := &syncPublishBatch{}
+	err := s.store.WithTx(ctx, func(tx *Tx) error {
+		entries := s.orderEngineEntries(ctx, tx, slot, stats, batch)
+		return s.dispatchProgramNotification(entries, pubkey, account)
+	})
+	if err != nil {
+		return err
+	}
+	s.publishBatch(batch)
+	return nil
+}
+
+// processMarketRegistryNotification upserts one pushed market registry
+// account update through marketRegistryEntries' handlers.
+func (s *Service) processMarketRegistryNotification(ctx context.Context, pubkey solana.PublicKey, account *rpc.Account, slot uint64) error {
+	if !s.Toggles().ConsiderMarketRegistry {
+		return nil
+	}
+
+	stats := map[string]int{}
+	return s.store.WithTx(ctx, func(tx *Tx) error {
+		entries := s.marketRegistryEntries(ctx, tx, slot, stats)
+		return s.dispatchProgramNotification(entries, pubkey, account)
+	})
+}
+
+// processLpVaultNotification upserts one pushed LP vault account update
+// through lpVaultEntries' handlers.
+func (s *Service) processLpVaultNotification(ctx context.Context, pubkey solana.PublicKey, account *rpc.Account, slot uint64) error {
+	if !s.Toggles().ConsiderLPVault {
+		return nil
+	}
+
+	stats := map[string]int{}
+	batch := &syncPublishBatch{}
+	err := s.store.WithTx(ctx, func(tx *Tx) error {
+		entries := s.lpVaultEntries(ctx, tx, slot, stats, batch)
+		return s.dispatchProgramNotification(entries, pubkey, account)
+	})
+	if err != nil {
+		return err
+	}
+	s.publishBatch(batch)
+	return nil
+}
+
+// dispatchProgramNotification matches account's discriminator against
+// entries and runs the matching Handle, the same way incrementalSyncProgram
+// matches a batch of getMultipleAccounts results. Unrecognized
+// discriminators and blocked account types are dropped silently, exactly as
+// scanAndStore drops them when it finds no matching entry.
+func (s *Service) dispatchProgramNotification(entries []programDiscriminatorEntry, pubkey solana.PublicKey, account *rpc.Account) error {
+	if account == nil {
+		return nil
+	}
+	data := account.Data.GetBinary()
+	if len(data) < 8 {
+		return nil
+	}
+	var discriminator [8]byte
+	copy(discriminator[:], data[:8])
+
+	for _, entry := range entries {
+		if entry.Discriminator != discriminator {
+			continue
+		}
+		if s.isAccountTypeBlocked(entry.AccountType) {
+			return nil
+		}
+		return entry.Handle(&rpc.KeyedAccount{Pubkey: pubkey, Account: account})
+	}
+	return nil
+}
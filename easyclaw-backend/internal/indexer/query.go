@@ -2,20 +2,70 @@ package indexer
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
+	"strconv"
 	"strings"
+
+	"github.com/coldbell/dex/backend/internal/logging"
 )
 
 const (
 	defaultPageLimit = 50
 	maxPageLimit     = 200
+
+	// cursorFieldSep separates a cursor's encoded ORDER BY column values.
+	// It's the ASCII unit separator, chosen because none of the columns
+	// cursors are built from (timestamps, ids, base58 pubkeys) can contain
+	// it, unlike ":" or "|".
+	cursorFieldSep = "\x1f"
 )
 
+// encodeCursor opaquely encodes the ORDER BY column values of the last row
+// on a page, in ORDER BY order, so decodeCursor can recover them to resume
+// a keyset-paginated list from the next row.
+func encodeCursor(fields ...string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strings.Join(fields, cursorFieldSep)))
+}
+
+// decodeCursor reverses encodeCursor, reporting an error if cursor isn't
+// one this package produced.
+func decodeCursor(cursor string, wantFields int) ([]string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+	fields := strings.Split(string(raw), cursorFieldSep)
+	if len(fields) != wantFields {
+		return nil, fmt.Errorf("invalid cursor: expected %d fields, got %d", wantFields, len(fields))
+	}
+	return fields, nil
+}
+
+// QueryService is the subset of Store's list methods that follow the
+// (ctx, Filter) ([]Record, limit, offset, nextCursor, error) shape: offset
+// or keyset pagination, a uniform return tuple, a Filter struct whose
+// fields are tagged for query-string decoding. cmd/apigen reads this
+// interface to generate apiserver/gen_handlers.go, so a new method added
+// here (with a correspondingly query-tagged Filter struct) gets a REST
+// handler for free on the next `go generate` instead of a hand-written one.
+type QueryService interface {
+	ListPositions(ctx context.Context, filter PositionFilter) ([]PositionRecord, int, int, string, error)
+	ListOrders(ctx context.Context, filter OrderFilter) ([]OrderRecord, int, int, string, error)
+	ListFills(ctx context.Context, filter FillFilter) ([]FillRecord, int, int, string, error)
+	ListPositionHistory(ctx context.Context, filter PositionHistoryFilter) ([]PositionHistoryRecord, int, int, string, error)
+}
+
 type PositionFilter struct {
-	UserMargin string
-	MarketID   *uint64
-	Limit      int
-	Offset     int
+	UserMargin string  `query:"user_margin"`
+	MarketID   *uint64 `query:"market_id"`
+	Limit      int     `query:"limit"`
+	Offset     int     `query:"offset"`
+
+	// Cursor, when set, resumes a keyset-paginated list from the row
+	// after the one a prior call's NextCursor pointed at, instead of
+	// using Offset. It takes priority over Offset when both are set.
+	Cursor string `query:"cursor"`
 }
 
 type PositionRecord struct {
@@ -33,12 +83,17 @@ type PositionRecord struct {
 }
 
 type OrderFilter struct {
-	UserMargin string
-	UserPubkey string
-	MarketID   *uint64
-	Status     string
-	Limit      int
-	Offset     int
+	UserMargin string  `query:"user_margin"`
+	UserPubkey string  `query:"user_pubkey"`
+	MarketID   *uint64 `query:"market_id"`
+	Status     string  `query:"status"`
+	Limit      int     `query:"limit"`
+	Offset     int     `query:"offset"`
+
+	// Cursor, when set, resumes a keyset-paginated list from the row
+	// after the one a prior call's NextCursor pointed at, instead of
+	// using Offset. It takes priority over Offset when both are set.
+	Cursor string `query:"cursor"`
 }
 
 type OrderRecord struct {
@@ -60,11 +115,16 @@ type OrderRecord struct {
 }
 
 type FillFilter struct {
-	UserMargin string
-	UserPubkey string
-	MarketID   *uint64
-	Limit      int
-	Offset     int
+	UserMargin string  `query:"user_margin"`
+	UserPubkey string  `query:"user_pubkey"`
+	MarketID   *uint64 `query:"market_id"`
+	Limit      int     `query:"limit"`
+	Offset     int     `query:"offset"`
+
+	// Cursor, when set, resumes a keyset-paginated list from the row
+	// after the one a prior call's NextCursor pointed at, instead of
+	// using Offset. It takes priority over Offset when both are set.
+	Cursor string `query:"cursor"`
 }
 
 type FillRecord struct {
@@ -86,10 +146,15 @@ type FillRecord struct {
 }
 
 type PositionHistoryFilter struct {
-	UserMargin string
-	MarketID   *uint64
-	Limit      int
-	Offset     int
+	UserMargin string  `query:"user_margin"`
+	MarketID   *uint64 `query:"market_id"`
+	Limit      int     `query:"limit"`
+	Offset     int     `query:"offset"`
+
+	// Cursor, when set, resumes a keyset-paginated list from the row
+	// after the one a prior call's NextCursor pointed at, instead of
+	// using Offset. It takes priority over Offset when both are set.
+	Cursor string `query:"cursor"`
 }
 
 type PositionHistoryRecord struct {
@@ -110,14 +175,26 @@ type PositionHistoryRecord struct {
 	NextShortEntryNotional    string `json:"next_short_entry_notional"`
 	NextLastFundingIndexLong  string `json:"next_last_funding_index_long"`
 	NextLastFundingIndexShort string `json:"next_last_funding_index_short"`
-	Slot                      uint64 `json:"slot"`
-	RecordedAt                int64  `json:"recorded_at"`
+	// FundingPaidLong/FundingPaidShort/FundingPaidTotal are the realized
+	// funding this transition produced, computed by
+	// computeFundingPayment in funding.go: (next index - prev index) *
+	// the pre-change quantity for each leg, so a size increase doesn't
+	// retroactively owe funding on the size that didn't exist yet.
+	FundingPaidLong  string `json:"funding_paid_long"`
+	FundingPaidShort string `json:"funding_paid_short"`
+	FundingPaidTotal string `json:"funding_paid_total"`
+	Slot             uint64 `json:"slot"`
+	RecordedAt       int64  `json:"recorded_at"`
 }
 
-func (s *Store) ListPositions(ctx context.Context, filter PositionFilter) ([]PositionRecord, int, int, error) {
+// ListPositions lists positions ordered by updated_at DESC, pubkey ASC.
+// Passing filter.Cursor (from a prior call's NextCursor) resumes after the
+// last row that call returned, in O(limit) regardless of table size;
+// filter.Offset is kept for backward compat but degrades on large tables.
+func (s *Store) ListPositions(ctx context.Context, filter PositionFilter) ([]PositionRecord, int, int, string, error) {
 	limit, offset := normalizePagination(filter.Limit, filter.Offset)
 	clauses := []string{"1 = 1"}
-	args := make([]any, 0, 4)
+	args := make([]any, 0, 5)
 
 	if filter.UserMargin != "" {
 		clauses = append(clauses, "user_margin = ?")
@@ -128,6 +205,20 @@ func (s *Store) ListPositions(ctx context.Context, filter PositionFilter) ([]Pos
 		args = append(args, int64(*filter.MarketID))
 	}
 
+	useCursor := filter.Cursor != ""
+	if useCursor {
+		fields, err := decodeCursor(filter.Cursor, 2)
+		if err != nil {
+			return nil, 0, 0, "", err
+		}
+		clauses = append(clauses, "(updated_at < ? OR (updated_at = ? AND pubkey > ?))")
+		args = append(args, fields[0], fields[0], fields[1])
+	}
+
+	pageClause := "LIMIT ? OFFSET ?"
+	if useCursor {
+		pageClause = "LIMIT ?"
+	}
 	query := fmt.Sprintf(`
 		SELECT
 			pubkey,
@@ -144,13 +235,17 @@ func (s *Store) ListPositions(ctx context.Context, filter PositionFilter) ([]Pos
 		FROM positions
 		WHERE %s
 		ORDER BY updated_at DESC, pubkey ASC
-		LIMIT ? OFFSET ?
-	`, strings.Join(clauses, " AND "))
-	args = append(args, limit, offset)
+		%s
+	`, strings.Join(clauses, " AND "), pageClause)
+	if useCursor {
+		args = append(args, limit)
+	} else {
+		args = append(args, limit, offset)
+	}
 
 	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, 0, 0, err
+		return nil, 0, 0, "", err
 	}
 	defer rows.Close()
 
@@ -172,23 +267,34 @@ func (s *Store) ListPositions(ctx context.Context, filter PositionFilter) ([]Pos
 			&slot,
 			&item.UpdatedAt,
 		); err != nil {
-			return nil, 0, 0, err
+			return nil, 0, 0, "", err
 		}
 		item.MarketID = uint64(marketID)
 		item.Slot = uint64(slot)
 		items = append(items, item)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, 0, 0, err
+		return nil, 0, 0, "", err
 	}
 
-	return items, limit, offset, nil
+	nextCursor := ""
+	if len(items) == limit {
+		last := items[len(items)-1]
+		nextCursor = encodeCursor(strconv.FormatInt(last.UpdatedAt, 10), last.Pubkey)
+	}
+
+	logging.With(ctx).Debug("ListPositions page fetched", "table", "positions", "count", len(items), "limit", limit)
+	return items, limit, offset, nextCursor, nil
 }
 
-func (s *Store) ListOrders(ctx context.Context, filter OrderFilter) ([]OrderRecord, int, int, error) {
+// ListOrders lists orders ordered by updated_at DESC, pubkey ASC. Passing
+// filter.Cursor (from a prior call's NextCursor) resumes after the last row
+// that call returned, in O(limit) regardless of table size; filter.Offset
+// is kept for backward compat but degrades on large tables.
+func (s *Store) ListOrders(ctx context.Context, filter OrderFilter) ([]OrderRecord, int, int, string, error) {
 	limit, offset := normalizePagination(filter.Limit, filter.Offset)
 	clauses := []string{"1 = 1"}
-	args := make([]any, 0, 6)
+	args := make([]any, 0, 7)
 
 	if filter.UserMargin != "" {
 		clauses = append(clauses, "user_margin = ?")
@@ -207,6 +313,20 @@ func (s *Store) ListOrders(ctx context.Context, filter OrderFilter) ([]OrderReco
 		args = append(args, filter.Status)
 	}
 
+	useCursor := filter.Cursor != ""
+	if useCursor {
+		fields, err := decodeCursor(filter.Cursor, 2)
+		if err != nil {
+			return nil, 0, 0, "", err
+		}
+		clauses = append(clauses, "(updated_at < ? OR (updated_at = ? AND pubkey > ?))")
+		args = append(args, fields[0], fields[0], fields[1])
+	}
+
+	pageClause := "LIMIT ? OFFSET ?"
+	if useCursor {
+		pageClause = "LIMIT ?"
+	}
 	query := fmt.Sprintf(`
 		SELECT
 			pubkey,
@@ -227,13 +347,17 @@ func (s *Store) ListOrders(ctx context.Context, filter OrderFilter) ([]OrderReco
 		FROM orders
 		WHERE %s
 		ORDER BY updated_at DESC, pubkey ASC
-		LIMIT ? OFFSET ?
-	`, strings.Join(clauses, " AND "))
-	args = append(args, limit, offset)
+		%s
+	`, strings.Join(clauses, " AND "), pageClause)
+	if useCursor {
+		args = append(args, limit)
+	} else {
+		args = append(args, limit, offset)
+	}
 
 	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, 0, 0, err
+		return nil, 0, 0, "", err
 	}
 	defer rows.Close()
 
@@ -260,7 +384,7 @@ func (s *Store) ListOrders(ctx context.Context, filter OrderFilter) ([]OrderReco
 			&slot,
 			&item.UpdatedAt,
 		); err != nil {
-			return nil, 0, 0, err
+			return nil, 0, 0, "", err
 		}
 		item.MarketID = uint64(marketID)
 		item.ReduceOnly = reduceOnly != 0
@@ -268,16 +392,27 @@ func (s *Store) ListOrders(ctx context.Context, filter OrderFilter) ([]OrderReco
 		items = append(items, item)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, 0, 0, err
+		return nil, 0, 0, "", err
 	}
 
-	return items, limit, offset, nil
+	nextCursor := ""
+	if len(items) == limit {
+		last := items[len(items)-1]
+		nextCursor = encodeCursor(strconv.FormatInt(last.UpdatedAt, 10), last.Pubkey)
+	}
+
+	logging.With(ctx).Debug("ListOrders page fetched", "table", "orders", "count", len(items), "limit", limit)
+	return items, limit, offset, nextCursor, nil
 }
 
-func (s *Store) ListFills(ctx context.Context, filter FillFilter) ([]FillRecord, int, int, error) {
+// ListFills lists fills ordered by executed_at DESC, id DESC. Passing
+// filter.Cursor (from a prior call's NextCursor) resumes after the last row
+// that call returned, in O(limit) regardless of table size; filter.Offset
+// is kept for backward compat but degrades on large tables.
+func (s *Store) ListFills(ctx context.Context, filter FillFilter) ([]FillRecord, int, int, string, error) {
 	limit, offset := normalizePagination(filter.Limit, filter.Offset)
 	clauses := []string{"1 = 1"}
-	args := make([]any, 0, 5)
+	args := make([]any, 0, 6)
 
 	if filter.UserMargin != "" {
 		clauses = append(clauses, "user_margin = ?")
@@ -292,6 +427,20 @@ func (s *Store) ListFills(ctx context.Context, filter FillFilter) ([]FillRecord,
 		args = append(args, int64(*filter.MarketID))
 	}
 
+	useCursor := filter.Cursor != ""
+	if useCursor {
+		fields, err := decodeCursor(filter.Cursor, 2)
+		if err != nil {
+			return nil, 0, 0, "", err
+		}
+		clauses = append(clauses, "(executed_at < ? OR (executed_at = ? AND id < ?))")
+		args = append(args, fields[0], fields[0], fields[1])
+	}
+
+	pageClause := "LIMIT ? OFFSET ?"
+	if useCursor {
+		pageClause = "LIMIT ?"
+	}
 	query := fmt.Sprintf(`
 		SELECT
 			id,
@@ -312,13 +461,17 @@ func (s *Store) ListFills(ctx context.Context, filter FillFilter) ([]FillRecord,
 		FROM fills
 		WHERE %s
 		ORDER BY executed_at DESC, id DESC
-		LIMIT ? OFFSET ?
-	`, strings.Join(clauses, " AND "))
-	args = append(args, limit, offset)
+		%s
+	`, strings.Join(clauses, " AND "), pageClause)
+	if useCursor {
+		args = append(args, limit)
+	} else {
+		args = append(args, limit, offset)
+	}
 
 	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, 0, 0, err
+		return nil, 0, 0, "", err
 	}
 	defer rows.Close()
 
@@ -345,7 +498,7 @@ func (s *Store) ListFills(ctx context.Context, filter FillFilter) ([]FillRecord,
 			&executedSlot,
 			&item.ExecutedAt,
 		); err != nil {
-			return nil, 0, 0, err
+			return nil, 0, 0, "", err
 		}
 		item.MarketID = uint64(marketID)
 		item.ReduceOnly = reduceOnly != 0
@@ -353,16 +506,28 @@ func (s *Store) ListFills(ctx context.Context, filter FillFilter) ([]FillRecord,
 		items = append(items, item)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, 0, 0, err
+		return nil, 0, 0, "", err
+	}
+
+	nextCursor := ""
+	if len(items) == limit {
+		last := items[len(items)-1]
+		nextCursor = encodeCursor(strconv.FormatInt(last.ExecutedAt, 10), strconv.FormatInt(last.ID, 10))
 	}
 
-	return items, limit, offset, nil
+	logging.With(ctx).Debug("ListFills page fetched", "table", "fills", "count", len(items), "limit", limit)
+	return items, limit, offset, nextCursor, nil
 }
 
-func (s *Store) ListPositionHistory(ctx context.Context, filter PositionHistoryFilter) ([]PositionHistoryRecord, int, int, error) {
+// ListPositionHistory lists position history events ordered by recorded_at
+// DESC, id DESC. Passing filter.Cursor (from a prior call's NextCursor)
+// resumes after the last row that call returned, in O(limit) regardless of
+// table size; filter.Offset is kept for backward compat but degrades on
+// large tables.
+func (s *Store) ListPositionHistory(ctx context.Context, filter PositionHistoryFilter) ([]PositionHistoryRecord, int, int, string, error) {
 	limit, offset := normalizePagination(filter.Limit, filter.Offset)
 	clauses := []string{"1 = 1"}
-	args := make([]any, 0, 4)
+	args := make([]any, 0, 5)
 
 	if filter.UserMargin != "" {
 		clauses = append(clauses, "user_margin = ?")
@@ -373,6 +538,20 @@ func (s *Store) ListPositionHistory(ctx context.Context, filter PositionHistoryF
 		args = append(args, int64(*filter.MarketID))
 	}
 
+	useCursor := filter.Cursor != ""
+	if useCursor {
+		fields, err := decodeCursor(filter.Cursor, 2)
+		if err != nil {
+			return nil, 0, 0, "", err
+		}
+		clauses = append(clauses, "(recorded_at < ? OR (recorded_at = ? AND id < ?))")
+		args = append(args, fields[0], fields[0], fields[1])
+	}
+
+	pageClause := "LIMIT ? OFFSET ?"
+	if useCursor {
+		pageClause = "LIMIT ?"
+	}
 	query := fmt.Sprintf(`
 		SELECT
 			id,
@@ -392,18 +571,25 @@ func (s *Store) ListPositionHistory(ctx context.Context, filter PositionHistoryF
 			next_short_entry_notional,
 			next_last_funding_index_long,
 			next_last_funding_index_short,
+			funding_paid_long,
+			funding_paid_short,
+			funding_paid_total,
 			slot,
 			recorded_at
 		FROM position_history
 		WHERE %s
 		ORDER BY recorded_at DESC, id DESC
-		LIMIT ? OFFSET ?
-	`, strings.Join(clauses, " AND "))
-	args = append(args, limit, offset)
+		%s
+	`, strings.Join(clauses, " AND "), pageClause)
+	if useCursor {
+		args = append(args, limit)
+	} else {
+		args = append(args, limit, offset)
+	}
 
 	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, 0, 0, err
+		return nil, 0, 0, "", err
 	}
 	defer rows.Close()
 
@@ -430,20 +616,30 @@ func (s *Store) ListPositionHistory(ctx context.Context, filter PositionHistoryF
 			&item.NextShortEntryNotional,
 			&item.NextLastFundingIndexLong,
 			&item.NextLastFundingIndexShort,
+			&item.FundingPaidLong,
+			&item.FundingPaidShort,
+			&item.FundingPaidTotal,
 			&slot,
 			&item.RecordedAt,
 		); err != nil {
-			return nil, 0, 0, err
+			return nil, 0, 0, "", err
 		}
 		item.MarketID = uint64(marketID)
 		item.Slot = uint64(slot)
 		items = append(items, item)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, 0, 0, err
+		return nil, 0, 0, "", err
+	}
+
+	nextCursor := ""
+	if len(items) == limit {
+		last := items[len(items)-1]
+		nextCursor = encodeCursor(strconv.FormatInt(last.RecordedAt, 10), strconv.FormatInt(last.ID, 10))
 	}
 
-	return items, limit, offset, nil
+	logging.With(ctx).Debug("ListPositionHistory page fetched", "table", "position_history", "count", len(items), "limit", limit)
+	return items, limit, offset, nextCursor, nil
 }
 
 func normalizePagination(limit, offset int) (int, int) {
@@ -0,0 +1,141 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+// RebalanceConfig is a user-authored target-weight allocation: TargetWeights
+// maps a market symbol to its target fraction of total portfolio notional,
+// normalized to 1.0 the same way an external rebalance strategy's weights
+// would be. IntervalSec records how often the caller intends to re-run
+// PreviewRebalance (e.g. 3600 for hourly, 86400 for daily) - it's carried
+// through to RebalanceResult for a scheduler built on top of this to read
+// back, but this package doesn't run one itself; see PreviewRebalance.
+type RebalanceConfig struct {
+	TargetWeights map[string]float64 `json:"target_weights"`
+	IntervalSec   int64              `json:"interval_sec"`
+}
+
+// RebalanceOrderSuggestion is one market's computed gap between its current
+// and target notional share of the portfolio, expressed as the order a
+// rebalance would place to close that gap.
+type RebalanceOrderSuggestion struct {
+	Market        string  `json:"market"`
+	MarketID      uint64  `json:"market_id"`
+	Side          string  `json:"side"` // "buy" or "sell"
+	DeltaQty      float64 `json:"delta_qty"`
+	DeltaNotional float64 `json:"delta_notional"`
+	CurrentWeight float64 `json:"current_weight"`
+	TargetWeight  float64 `json:"target_weight"`
+}
+
+// RebalanceResult is PreviewRebalance's output: what it would currently
+// suggest trading to bring agentID (or the whole portfolio, if agentID is
+// empty) to config's target weights, given fills from the chosen period.
+type RebalanceResult struct {
+	AgentID       string                     `json:"agent_id,omitempty"`
+	Period        string                     `json:"period"`
+	AsOf          int64                      `json:"as_of"`
+	TotalNotional float64                    `json:"total_notional"`
+	Suggestions   []RebalanceOrderSuggestion `json:"suggestions"`
+}
+
+// PreviewRebalance computes what config's target weights would currently
+// suggest trading, given the net open position each configured market
+// built up from fills since portfolioPeriodStart(period) - the same period
+// windowing GetPortfolioSummary uses, so "preview over 7d/30d/all" means
+// "as if only fills in that window had happened" rather than a full
+// position history. agentID scopes to one agent's fills; empty means every
+// agent combined (the portfolio view).
+//
+// This only computes suggestions - it does not place orders or run on a
+// schedule itself. config.IntervalSec is carried through for a caller-side
+// scheduler (e.g. a cron job polling this endpoint) to read back; this
+// package has no order-execution client to act on the suggestions with,
+// and wiring a ticker into Service.Run without one to call would just be
+// dead weight.
+func (s *Store) PreviewRebalance(ctx context.Context, agentID string, config RebalanceConfig, period string) (RebalanceResult, error) {
+	if len(config.TargetWeights) == 0 {
+		return RebalanceResult{}, fmt.Errorf("rebalance: target_weights is required")
+	}
+	weightSum := 0.0
+	for _, weight := range config.TargetWeights {
+		weightSum += weight
+	}
+	if weightSum <= 0 {
+		return RebalanceResult{}, fmt.Errorf("rebalance: target_weights must sum to a positive total")
+	}
+
+	startUnix, err := portfolioPeriodStart(period)
+	if err != nil {
+		return RebalanceResult{}, err
+	}
+
+	events, err := s.loadTradeEvents(ctx, agentID, startUnix, 0)
+	if err != nil {
+		return RebalanceResult{}, err
+	}
+	qtyByMarket, priceByMarket := marketPositions(events)
+
+	marketSymbolsByID, err := s.loadExecutionPriceMarketSymbols(ctx)
+	if err != nil {
+		return RebalanceResult{}, err
+	}
+	marketIDBySymbol := make(map[string]uint64, len(marketSymbolsByID))
+	for marketID, symbol := range marketSymbolsByID {
+		marketIDBySymbol[NormalizeMarketSymbol(symbol)] = marketID
+	}
+
+	totalNotional := 0.0
+	for marketID, qty := range qtyByMarket {
+		totalNotional += math.Abs(qty) * priceByMarket[marketID]
+	}
+
+	suggestions := make([]RebalanceOrderSuggestion, 0, len(config.TargetWeights))
+	for symbol, rawWeight := range config.TargetWeights {
+		normalized := NormalizeMarketSymbol(symbol)
+		marketID := marketIDBySymbol[normalized]
+		price := priceByMarket[marketID]
+		if price <= 0 {
+			// No fills (and so no mark) for this market in the window -
+			// nothing to compute a delta against.
+			continue
+		}
+
+		targetWeight := rawWeight / weightSum
+		currentQty := qtyByMarket[marketID]
+		currentNotional := currentQty * price
+		currentWeight := 0.0
+		if totalNotional > 0 {
+			currentWeight = currentNotional / totalNotional
+		}
+
+		targetNotional := targetWeight * totalNotional
+		deltaNotional := targetNotional - currentNotional
+		side := "buy"
+		if deltaNotional < 0 {
+			side = "sell"
+		}
+
+		suggestions = append(suggestions, RebalanceOrderSuggestion{
+			Market:        normalized,
+			MarketID:      marketID,
+			Side:          side,
+			DeltaQty:      deltaNotional / price,
+			DeltaNotional: deltaNotional,
+			CurrentWeight: currentWeight,
+			TargetWeight:  targetWeight,
+		})
+	}
+
+	return RebalanceResult{
+		AgentID:       agentID,
+		Period:        period,
+		AsOf:          time.Now().Unix(),
+		TotalNotional: totalNotional,
+		Suggestions:   suggestions,
+	}, nil
+}
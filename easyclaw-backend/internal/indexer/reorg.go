@@ -0,0 +1,185 @@
+package indexer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SyncedBlock is one entry of the rolling (slot, block_hash, parent_hash)
+// window synced_blocks keeps, so LastSyncedBlockBefore can tell a normal
+// next-slot advance from a fork: on a fork, the new block's parent hash
+// won't match the block_hash this indexer already recorded for that parent
+// slot.
+type SyncedBlock struct {
+	Slot       uint64
+	BlockHash  string
+	ParentHash string
+	RecordedAt int64
+}
+
+// RecordSyncedBlockTx upserts slot's (block_hash, parent_hash) into the
+// rolling window. Call this once per successfully synced slot, in the same
+// transaction as the rest of that slot's upserts, so the window and the
+// data it's meant to protect never disagree about what's been committed.
+func (s *Store) RecordSyncedBlockTx(ctx context.Context, tx *Tx, slot uint64, blockHash, parentHash string) error {
+	query := fmt.Sprintf(`
+		INSERT INTO synced_blocks (slot, block_hash, parent_hash, recorded_at)
+		VALUES (?, ?, ?, ?)
+		%s
+	`, tx.dialect.UpsertClause([]string{"slot"}, []string{"block_hash", "parent_hash", "recorded_at"}))
+	_, err := tx.ExecContext(ctx, query, int64(slot), blockHash, parentHash, time.Now().Unix())
+	return err
+}
+
+// LastSyncedBlockBefore returns the newest synced_blocks row with slot <
+// before, or nil if the window is empty (a cold start, or everything in
+// it has already been pruned). Callers use this ahead of the main sync
+// transaction to compare a newly-observed block's parent hash against
+// what this indexer already committed for that parent slot.
+func (s *Store) LastSyncedBlockBefore(ctx context.Context, before uint64) (*SyncedBlock, error) {
+	row := s.db.QueryRowContext(ctx, `
+		SELECT slot, block_hash, parent_hash, recorded_at FROM synced_blocks
+		WHERE slot < ?
+		ORDER BY slot DESC
+		LIMIT 1
+	`, int64(before))
+	var block SyncedBlock
+	var slot int64
+	err := row.Scan(&slot, &block.BlockHash, &block.ParentHash, &block.RecordedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	block.Slot = uint64(slot)
+	return &block, nil
+}
+
+// PruneSyncedBlocksBeforeTx drops synced_blocks rows older than
+// keepAfterSlot, bounding the rolling window's size. LastSyncedBlockBefore
+// only ever needs the immediately preceding slot, but keeping a wider window
+// (a few hundred slots) means RewindTo has something to fall back past
+// if a fork turns out to be deeper than one slot.
+func (s *Store) PruneSyncedBlocksBeforeTx(ctx context.Context, tx *Tx, keepAfterSlot uint64) error {
+	_, err := tx.ExecContext(ctx, `DELETE FROM synced_blocks WHERE slot < ?`, int64(keepAfterSlot))
+	return err
+}
+
+// RewindTo reverts every table a fork could have corrupted back to the
+// state it was in at slot: it deletes rows written at a later slot from
+// orders, lp_positions, resources, market_price_ticks, position_history,
+// and the slot-stamped audit tables (order_status_history, nav_history,
+// margin_deposits, margin_withdraws) outright (the next sync pass will
+// re-observe whatever is actually live on the now-canonical fork, and
+// re-derive fresh audit rows for it), deletes fills executed at a later
+// slot, and for positions specifically restores each reverted position
+// from its most recent surviving position_history snapshot rather than
+// just deleting it, so a position's PnL history doesn't develop a hole
+// where a fork briefly changed its on-chain state.
+func (s *Store) RewindTo(ctx context.Context, slot uint64) error {
+	return s.WithTx(ctx, func(tx *Tx) error {
+		rows, err := tx.QueryContext(ctx, `SELECT pubkey FROM positions WHERE slot > ?`, int64(slot))
+		if err != nil {
+			return fmt.Errorf("list positions to rewind: %w", err)
+		}
+		var affectedPubkeys []string
+		for rows.Next() {
+			var pubkey string
+			if err := rows.Scan(&pubkey); err != nil {
+				rows.Close()
+				return fmt.Errorf("scan position to rewind: %w", err)
+			}
+			affectedPubkeys = append(affectedPubkeys, pubkey)
+		}
+		if err := rows.Err(); err != nil {
+			rows.Close()
+			return fmt.Errorf("list positions to rewind: %w", err)
+		}
+		rows.Close()
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM positions WHERE slot > ?`, int64(slot)); err != nil {
+			return fmt.Errorf("delete rewound positions: %w", err)
+		}
+		for _, pubkey := range affectedPubkeys {
+			if err := s.restorePositionFromHistoryTx(ctx, tx, pubkey, slot); err != nil {
+				return fmt.Errorf("restore position %s from history: %w", pubkey, err)
+			}
+		}
+
+		for _, table := range []string{
+			"orders", "lp_positions", "resources", "market_price_ticks", "position_history",
+			"order_status_history", "nav_history", "margin_deposits", "margin_withdraws",
+		} {
+			if _, err := tx.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %s WHERE slot > ?`, table), int64(slot)); err != nil {
+				return fmt.Errorf("delete rewound %s: %w", table, err)
+			}
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM fills WHERE executed_slot > ?`, int64(slot)); err != nil {
+			return fmt.Errorf("delete rewound fills: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// restorePositionFromHistoryTx re-inserts pubkey into positions from the
+// newest position_history row at or before slot, if one survived the
+// rewind. raw_json can't be reconstructed (the original account bytes
+// aren't kept in position_history), so it's left as an empty object until
+// the next sync pass re-observes the account and overwrites it.
+func (s *Store) restorePositionFromHistoryTx(ctx context.Context, tx *Tx, pubkey string, slot uint64) error {
+	row := tx.QueryRowContext(ctx, `
+		SELECT user_margin, market_id,
+		       next_long_qty, next_long_entry_notional, next_short_qty, next_short_entry_notional,
+		       next_last_funding_index_long, next_last_funding_index_short,
+		       slot, recorded_at
+		FROM position_history
+		WHERE position_pubkey = ? AND slot <= ?
+		ORDER BY slot DESC, id DESC
+		LIMIT 1
+	`, pubkey, int64(slot))
+
+	var userMargin string
+	var marketID int64
+	var snapshot positionHistorySnapshot
+	var historySlot, recordedAt int64
+	err := row.Scan(
+		&userMargin, &marketID,
+		&snapshot.LongQty, &snapshot.LongEntryNotional, &snapshot.ShortQty, &snapshot.ShortEntryNotional,
+		&snapshot.LastFundingIndexLong, &snapshot.LastFundingIndexShort,
+		&historySlot, &recordedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		// No surviving history for this pubkey at or before slot: the
+		// position was opened entirely on the forked-away branch, so
+		// leaving it deleted is correct.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf(`
+		INSERT INTO positions (
+			pubkey, user_margin, market_id, long_qty, long_entry_notional, short_qty,
+			short_entry_notional, last_funding_index_long, last_funding_index_short,
+			raw_json, slot, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		%s
+	`, tx.dialect.UpsertClause([]string{"pubkey"}, []string{
+		"user_margin", "market_id", "long_qty", "long_entry_notional", "short_qty",
+		"short_entry_notional", "last_funding_index_long", "last_funding_index_short",
+		"raw_json", "slot", "updated_at",
+	}))
+	_, err = tx.ExecContext(ctx, query,
+		pubkey, userMargin, marketID,
+		snapshot.LongQty, snapshot.LongEntryNotional, snapshot.ShortQty, snapshot.ShortEntryNotional,
+		snapshot.LastFundingIndexLong, snapshot.LastFundingIndexShort,
+		"{}", historySlot, recordedAt,
+	)
+	return err
+}
@@ -0,0 +1,215 @@
+package indexer
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// RetentionPolicy bounds how long raw market_price_ticks rows are kept.
+// Ticks older than RawTTL are downsampled into market_price_candles (if
+// they haven't been already) before being deleted, so GetMarketCandles'
+// historical read path keeps working once the raw rows backing it are
+// gone.
+type RetentionPolicy struct {
+	RawTTL time.Duration
+
+	// DeleteBatchSize bounds how many rows a single DELETE removes, so
+	// trimming a large backlog doesn't hold a long-lived lock/WAL
+	// transaction. Defaults to 5000 if <= 0.
+	DeleteBatchSize int
+}
+
+const defaultRetentionDeleteBatchSize = 5000
+
+// RunRetention backfills market_price_candles for every configured
+// interval up to the retention cutoff and then deletes raw ticks older
+// than the cutoff in batches. It's meant to run on a slow recurring
+// schedule (e.g. once an hour), not inline with ingest.
+func (s *Store) RunRetention(ctx context.Context, policy RetentionPolicy) error {
+	if policy.RawTTL <= 0 {
+		return fmt.Errorf("retention: RawTTL must be positive")
+	}
+	batchSize := policy.DeleteBatchSize
+	if batchSize <= 0 {
+		batchSize = defaultRetentionDeleteBatchSize
+	}
+
+	cutoff := time.Now().Add(-policy.RawTTL).Unix()
+
+	markets, err := s.distinctMarketsWithTicksBefore(ctx, cutoff)
+	if err != nil {
+		return fmt.Errorf("retention: list markets: %w", err)
+	}
+
+	for _, market := range markets {
+		for _, intervalSec := range candleAggregatorIntervals {
+			// Backfilling is idempotent (BackfillMarketPriceCandles
+			// upserts), so re-covering a range already flushed by
+			// CandleAggregator is just a cheap no-op write, not a
+			// correctness concern.
+			if _, err := s.BackfillMarketPriceCandles(ctx, market, intervalSec, 0, cutoff); err != nil {
+				return fmt.Errorf("retention: backfill %s/%ds: %w", market, intervalSec, err)
+			}
+		}
+	}
+
+	for {
+		deleted, err := s.deleteMarketPriceTicksBatch(ctx, cutoff, batchSize)
+		if err != nil {
+			return fmt.Errorf("retention: delete batch: %w", err)
+		}
+		if deleted < batchSize {
+			return nil
+		}
+	}
+}
+
+func (s *Store) distinctMarketsWithTicksBefore(ctx context.Context, cutoff int64) ([]string, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT DISTINCT market FROM market_price_ticks WHERE publish_time < ?`,
+		cutoff,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var markets []string
+	for rows.Next() {
+		var market string
+		if err := rows.Scan(&market); err != nil {
+			return nil, err
+		}
+		markets = append(markets, market)
+	}
+	return markets, rows.Err()
+}
+
+// deleteMarketPriceTicksBatch deletes up to batchSize raw ticks older than
+// cutoff and returns how many rows were actually removed, so RunRetention
+// can tell a full batch (more rows likely remain) from a partial one
+// (caught up).
+func (s *Store) deleteMarketPriceTicksBatch(ctx context.Context, cutoff int64, batchSize int) (int, error) {
+	result, err := s.db.ExecContext(
+		ctx,
+		`
+		DELETE FROM market_price_ticks
+		WHERE id IN (
+			SELECT id FROM market_price_ticks WHERE publish_time < ? LIMIT ?
+		)
+		`,
+		cutoff,
+		batchSize,
+	)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(affected), nil
+}
+
+// BackfillMarketPriceCandles (re-)computes every closed OHLCV bucket for
+// (market, intervalSec) in [fromBucketTS, toBucketTS) directly from raw
+// ticks and upserts them into market_price_candles, using the same
+// ON CONFLICT DO UPDATE path InsertMarketPriceCandles uses for the live
+// write path - so running it twice over the same range is a no-op the
+// second time. Operators use this both as part of RunRetention's
+// pre-delete downsample step and standalone, to materialize candles for
+// history that predates CandleAggregator.
+func (s *Store) BackfillMarketPriceCandles(ctx context.Context, market string, intervalSec, fromBucketTS, toBucketTS int64) (int, error) {
+	if intervalSec <= 0 {
+		return 0, fmt.Errorf("backfill: intervalSec must be positive")
+	}
+	normalized := normalizeMarketWithDefault(market)
+
+	rows, err := s.db.QueryContext(
+		ctx,
+		`
+		WITH bucketed AS (
+			SELECT
+				(publish_time / ?) * ? AS bucket_ts,
+				publish_time, slot, id, price, size,
+				LEAD(publish_time) OVER (PARTITION BY publish_time / ? ORDER BY publish_time ASC, slot ASC, id ASC) AS next_publish_time,
+				ROW_NUMBER() OVER (PARTITION BY publish_time / ? ORDER BY publish_time ASC, slot ASC, id ASC) AS rn_open,
+				ROW_NUMBER() OVER (PARTITION BY publish_time / ? ORDER BY publish_time DESC, slot DESC, id DESC) AS rn_close
+			FROM market_price_ticks
+			WHERE market = ? AND quality_flags = 0 AND publish_time >= ? AND publish_time < ?
+		),
+		weighted AS (
+			SELECT *, COALESCE(next_publish_time, bucket_ts + ?) - publish_time AS hold_duration
+			FROM bucketed
+		)
+		SELECT
+			bucket_ts,
+			MAX(CASE WHEN rn_open = 1 THEN price END) AS open,
+			MAX(price) AS high,
+			MIN(price) AS low,
+			MAX(CASE WHEN rn_close = 1 THEN price END) AS close,
+			COUNT(*)::DOUBLE PRECISION AS volume,
+			SUM(price * size) AS notional_sum,
+			SUM(size) AS size_sum,
+			SUM(price * hold_duration) AS twap_numerator,
+			SUM(hold_duration) AS twap_denominator
+		FROM weighted
+		GROUP BY bucket_ts
+		ORDER BY bucket_ts ASC
+		`,
+		intervalSec, intervalSec, intervalSec, intervalSec, intervalSec,
+		normalized, fromBucketTS, toBucketTS,
+		intervalSec,
+	)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var candles []ClosedCandle
+	for rows.Next() {
+		var bucketTS int64
+		var openPrice, highPrice, lowPrice, closePrice, volume sql.NullFloat64
+		var notionalSum, sizeSum, twapNumerator, twapDenominator sql.NullFloat64
+		if err := rows.Scan(&bucketTS, &openPrice, &highPrice, &lowPrice, &closePrice, &volume, &notionalSum, &sizeSum, &twapNumerator, &twapDenominator); err != nil {
+			return 0, err
+		}
+		if !volume.Valid || volume.Float64 == 0 {
+			continue
+		}
+
+		record := CandleRecord{
+			TS:     bucketTS,
+			Open:   round2(openPrice.Float64),
+			High:   round2(highPrice.Float64),
+			Low:    round2(lowPrice.Float64),
+			Close:  round2(closePrice.Float64),
+			Volume: round6(volume.Float64),
+		}
+		if sizeSum.Valid && sizeSum.Float64 > 0 {
+			record.VWAP = round2(notionalSum.Float64 / sizeSum.Float64)
+		}
+		if twapDenominator.Valid && twapDenominator.Float64 > 0 {
+			record.TWAP = round2(twapNumerator.Float64 / twapDenominator.Float64)
+		} else {
+			record.TWAP = record.Close
+		}
+		record.TypicalPrice = round2((record.High + record.Low + record.Close) / 3)
+
+		candles = append(candles, ClosedCandle{Market: normalized, IntervalSec: intervalSec, CandleRecord: record})
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(candles) == 0 {
+		return 0, nil
+	}
+
+	if err := s.InsertMarketPriceCandles(ctx, candles); err != nil {
+		return 0, err
+	}
+	return len(candles), nil
+}
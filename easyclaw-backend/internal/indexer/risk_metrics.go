@@ -0,0 +1,257 @@
+package indexer
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// RiskMetrics are rolling, risk-adjusted return statistics derived from an
+// equity curve (EquityHistory), so agents can be compared on more than raw
+// PnL: Sharpe and Sortino are annualized log-return ratios (the latter
+// penalizing only downside moves), Calmar relates annualized return to the
+// worst peak-to-trough drawdown over the same curve, and CVaR is the
+// historical average of the worst defaultCVaRAlpha fraction of returns.
+type RiskMetrics struct {
+	Sharpe  float64 `json:"sharpe"`
+	Sortino float64 `json:"sortino"`
+	Calmar  float64 `json:"calmar"`
+	CVaR    float64 `json:"cvar"`
+}
+
+// defaultCVaRAlpha is the tail fraction CVaR averages over (the worst 5%
+// of observed returns), the conventional choice absent a caller-supplied
+// alpha.
+const defaultCVaRAlpha = 0.05
+
+const secondsPerYear = 365.25 * 24 * 3600
+
+// riskMetricsWindows are the rolling windows AgentPortfolioSummary and
+// PortfolioSummary expose RiskMetrics for. "all" uses the full equity
+// curve passed in.
+var riskMetricsWindows = []string{"7d", "30d", "90d", "all"}
+
+func riskMetricsWindowSeconds(window string) int64 {
+	switch window {
+	case "7d":
+		return int64(7 * 24 * time.Hour / time.Second)
+	case "30d":
+		return int64(30 * 24 * time.Hour / time.Second)
+	case "90d":
+		return int64(90 * 24 * time.Hour / time.Second)
+	default:
+		return 0
+	}
+}
+
+// welfordStats is Welford's online algorithm for mean/variance: O(1) per
+// sample rather than re-summing the whole return series, so RiskMetrics
+// stay cheap to recompute as new fills extend the equity curve.
+type welfordStats struct {
+	count int
+	mean  float64
+	m2    float64
+}
+
+func (w *welfordStats) Add(x float64) {
+	w.count++
+	delta := x - w.mean
+	w.mean += delta / float64(w.count)
+	w.m2 += delta * (x - w.mean)
+}
+
+func (w *welfordStats) Variance() float64 {
+	if w.count < 2 {
+		return 0
+	}
+	return w.m2 / float64(w.count-1)
+}
+
+func (w *welfordStats) StdDev() float64 {
+	return math.Sqrt(w.Variance())
+}
+
+// downsideStats accumulates the RMS of negative returns against a target
+// of zero (the standard downside-deviation definition), rather than a
+// mean-centered variance - Welford's algorithm is the wrong tool here
+// since Sortino measures dispersion below a fixed target, not below the
+// series' own mean.
+type downsideStats struct {
+	count int
+	sumSq float64
+}
+
+func (d *downsideStats) Add(r float64) {
+	d.count++
+	if r < 0 {
+		d.sumSq += r * r
+	}
+}
+
+func (d *downsideStats) StdDev() float64 {
+	if d.count == 0 {
+		return 0
+	}
+	return math.Sqrt(d.sumSq / float64(d.count))
+}
+
+// computeRiskMetricsByWindow computes RiskMetrics for every window in
+// riskMetricsWindows from a single equity curve.
+func computeRiskMetricsByWindow(equityHistory []EquityPoint) map[string]RiskMetrics {
+	out := make(map[string]RiskMetrics, len(riskMetricsWindows))
+	for _, window := range riskMetricsWindows {
+		out[window] = computeRiskMetrics(equityHistory, riskMetricsWindowSeconds(window))
+	}
+	return out
+}
+
+// computeRiskMetrics computes RiskMetrics over the tail of equityHistory
+// covering windowSeconds (or the whole curve if windowSeconds <= 0).
+func computeRiskMetrics(equityHistory []EquityPoint, windowSeconds int64) RiskMetrics {
+	points := equityWindow(equityHistory, windowSeconds)
+	if len(points) < 2 {
+		return RiskMetrics{}
+	}
+
+	logReturns := make([]float64, 0, len(points)-1)
+	var returns welfordStats
+	var downside downsideStats
+	for i := 1; i < len(points); i++ {
+		prev, cur := points[i-1].Value, points[i].Value
+		if prev <= 0 || cur <= 0 {
+			continue
+		}
+		r := math.Log(cur / prev)
+		logReturns = append(logReturns, r)
+		returns.Add(r)
+		downside.Add(r)
+	}
+	if len(logReturns) == 0 {
+		return RiskMetrics{}
+	}
+
+	periodsPerYear := annualizationFactor(points)
+
+	sharpe := 0.0
+	if stdDev := returns.StdDev(); stdDev > 0 {
+		sharpe = (returns.mean / stdDev) * math.Sqrt(periodsPerYear)
+	}
+
+	sortino := 0.0
+	if stdDev := downside.StdDev(); stdDev > 0 {
+		sortino = (returns.mean / stdDev) * math.Sqrt(periodsPerYear)
+	}
+
+	maxDrawdown := equityMaxDrawdownPct(points)
+	calmar := 0.0
+	if maxDrawdown < 0 {
+		annualizedReturn := returns.mean * periodsPerYear
+		calmar = annualizedReturn / -maxDrawdown
+	}
+
+	return RiskMetrics{
+		Sharpe:  sharpe,
+		Sortino: sortino,
+		Calmar:  calmar,
+		CVaR:    historicalCVaR(logReturns, defaultCVaRAlpha),
+	}
+}
+
+// equityWindow returns the suffix of points whose TS falls within
+// windowSeconds of the most recent point, or all of points if
+// windowSeconds <= 0.
+func equityWindow(points []EquityPoint, windowSeconds int64) []EquityPoint {
+	if windowSeconds <= 0 || len(points) == 0 {
+		return points
+	}
+	cutoff := points[len(points)-1].TS - windowSeconds
+	start := 0
+	for start < len(points) && points[start].TS < cutoff {
+		start++
+	}
+	return points[start:]
+}
+
+// annualizationFactor estimates how many equity-curve periods make up a
+// year from the average spacing between points, since fills (and thus
+// equity-curve samples) arrive at irregular intervals rather than on a
+// fixed daily/hourly cadence.
+func annualizationFactor(points []EquityPoint) float64 {
+	if len(points) < 2 {
+		return 0
+	}
+	span := float64(points[len(points)-1].TS - points[0].TS)
+	if span <= 0 {
+		return 0
+	}
+	avgInterval := span / float64(len(points)-1)
+	if avgInterval <= 0 {
+		return 0
+	}
+	return secondsPerYear / avgInterval
+}
+
+// equityMaxDrawdownPct is the worst peak-to-trough percentage decline
+// over points, following the same convention used elsewhere in this
+// package: a negative number, or 0 if equity never fell below its peak.
+func equityMaxDrawdownPct(points []EquityPoint) float64 {
+	if len(points) == 0 {
+		return 0
+	}
+	peak := points[0].Value
+	maxDrawdown := 0.0
+	for _, point := range points {
+		if point.Value > peak {
+			peak = point.Value
+		}
+		if peak > 0 {
+			dd := ((point.Value - peak) / peak) * 100
+			if dd < maxDrawdown {
+				maxDrawdown = dd
+			}
+		}
+	}
+	return maxDrawdown
+}
+
+func roundRiskMetrics(m RiskMetrics) RiskMetrics {
+	return RiskMetrics{
+		Sharpe:  round2(m.Sharpe),
+		Sortino: round2(m.Sortino),
+		Calmar:  round2(m.Calmar),
+		CVaR:    round2(m.CVaR),
+	}
+}
+
+func roundRiskMetricsByWindow(byWindow map[string]RiskMetrics) map[string]RiskMetrics {
+	out := make(map[string]RiskMetrics, len(byWindow))
+	for window, m := range byWindow {
+		out[window] = roundRiskMetrics(m)
+	}
+	return out
+}
+
+// historicalCVaR is the mean of the worst ceil(len(returns)*alpha)
+// observations in returns (historical/non-parametric CVaR, as opposed to
+// a variance-covariance estimate).
+func historicalCVaR(returns []float64, alpha float64) float64 {
+	if len(returns) == 0 || alpha <= 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), returns...)
+	sort.Float64s(sorted)
+
+	tailCount := int(math.Ceil(float64(len(sorted)) * alpha))
+	if tailCount < 1 {
+		tailCount = 1
+	}
+	if tailCount > len(sorted) {
+		tailCount = len(sorted)
+	}
+
+	sum := 0.0
+	for _, r := range sorted[:tailCount] {
+		sum += r
+	}
+	return sum / float64(tailCount)
+}
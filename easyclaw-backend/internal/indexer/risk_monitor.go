@@ -0,0 +1,241 @@
+package indexer
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"time"
+)
+
+// RiskBreachType identifies which AgentRiskProfile threshold a RiskEvent
+// records a breach of.
+type RiskBreachType string
+
+const (
+	RiskBreachDailyLoss   RiskBreachType = "daily_loss_limit"
+	RiskBreachMaxPosition RiskBreachType = "max_position"
+)
+
+// RiskEvent is one audit row written to risk_events each time RiskMonitor
+// observes an agent crossing a configured threshold, whether or not
+// KillSwitchEnabled was on to actually act on it.
+type RiskEvent struct {
+	ID         int64          `json:"id"`
+	AgentID    string         `json:"agent_id"`
+	BreachType RiskBreachType `json:"breach_type"`
+	Threshold  float64        `json:"threshold"`
+	Observed   float64        `json:"observed"`
+	Action     string         `json:"action"`
+	DetectedAt int64          `json:"detected_at"`
+}
+
+const (
+	riskActionKillSwitch = "kill_switch"
+	riskActionNone       = "none"
+)
+
+// RiskMonitor periodically recomputes each active agent's intraday
+// realized PnL and open notional from its fills and compares them against
+// AgentRiskProfile.DailyLossLimitUSDC/MaxPositionUSDC, automatically
+// calling Store.KillSwitch on any agent that breaches a threshold with
+// KillSwitchEnabled set. Thresholds are read fresh from the agents table
+// on every pass (via Store.ListAgents), so a PatchAgentRisk call takes
+// effect on the monitor's next Check without a restart - the same
+// hot-reload property DivergenceMonitor gets from re-reading its inputs
+// each Check rather than caching them. Like DivergenceMonitor, RiskMonitor
+// doesn't own a goroutine itself: Check is driven by a ticker in the
+// indexer Service's run loop (see the riskTicker case in Service.Run).
+type RiskMonitor struct {
+	logger *slog.Logger
+}
+
+func NewRiskMonitor(logger *slog.Logger) *RiskMonitor {
+	return &RiskMonitor{logger: logger}
+}
+
+// Check runs one pass over every active agent, recomputing intraday
+// realized PnL and open notional from its fills and recording/acting on
+// any threshold breach.
+func (m *RiskMonitor) Check(ctx context.Context, store *Store) error {
+	agents, err := store.ListAgents(ctx)
+	if err != nil {
+		return err
+	}
+	feeModel, err := store.loadFeeModel(ctx, 0)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).Unix()
+	nowUnix := now.Unix()
+
+	var killIDs []string
+	for _, agent := range agents {
+		if agent.Status != "active" {
+			continue
+		}
+		profile := agent.RiskProfile
+
+		events, err := store.loadTradeEvents(ctx, agent.ID, 0, nowUnix)
+		if err != nil {
+			m.logger.Warn("risk monitor: failed to load trade events", "agent_id", agent.ID, "err", err)
+			continue
+		}
+
+		openNotional := openPositionNotional(events)
+		dailyPnl := intradayRealizedPnl(events, normalizeAccountingMode(profile.AccountingMode), dayStart, feeModel)
+
+		if profile.DailyLossLimitUSDC > 0 && dailyPnl <= -profile.DailyLossLimitUSDC {
+			if err := m.recordBreach(ctx, store, agent.ID, RiskBreachDailyLoss, profile.DailyLossLimitUSDC, dailyPnl, profile.KillSwitchEnabled, nowUnix); err != nil {
+				m.logger.Warn("risk monitor: failed to record breach", "agent_id", agent.ID, "err", err)
+			}
+			if profile.KillSwitchEnabled {
+				killIDs = append(killIDs, agent.ID)
+			}
+			continue
+		}
+		if profile.MaxPositionUSDC > 0 && openNotional > profile.MaxPositionUSDC {
+			if err := m.recordBreach(ctx, store, agent.ID, RiskBreachMaxPosition, profile.MaxPositionUSDC, openNotional, profile.KillSwitchEnabled, nowUnix); err != nil {
+				m.logger.Warn("risk monitor: failed to record breach", "agent_id", agent.ID, "err", err)
+			}
+			if profile.KillSwitchEnabled {
+				killIDs = append(killIDs, agent.ID)
+			}
+		}
+	}
+
+	if len(killIDs) > 0 {
+		if _, err := store.KillSwitch(ctx, false, killIDs, nowUnix); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *RiskMonitor) recordBreach(ctx context.Context, store *Store, agentID string, breachType RiskBreachType, threshold, observed float64, killSwitchEnabled bool, detectedAt int64) error {
+	action := riskActionNone
+	if killSwitchEnabled {
+		action = riskActionKillSwitch
+	}
+	return store.insertRiskEvent(ctx, RiskEvent{
+		AgentID:    agentID,
+		BreachType: breachType,
+		Threshold:  threshold,
+		Observed:   observed,
+		Action:     action,
+		DetectedAt: detectedAt,
+	})
+}
+
+// intradayRealizedPnl sums TradeRecord.Pnl (already fee-adjusted) for
+// trades closed at or after dayStart, using mode's PnLEngine over the
+// agent's full fill history so FIFO/LIFO lot state carries over correctly
+// from before dayStart.
+func intradayRealizedPnl(events []tradeEvent, mode AccountingMode, dayStart int64, feeModel FeeModel) float64 {
+	trades, _ := newPnLEngine(mode).Process(events, feeModel)
+	total := 0.0
+	for _, trade := range trades {
+		if trade.ExitTime >= dayStart {
+			total += trade.Pnl
+		}
+	}
+	return total
+}
+
+// marketPositions returns each market's net signed open qty and last
+// observed fill price, accounting-mode independent (net signed qty, not
+// lot-by-lot) - shared by openPositionNotional's position-size guard and
+// PreviewRebalance's target-weight comparison, which both just need "how
+// much of this market am I holding right now and at what mark".
+func marketPositions(events []tradeEvent) (map[uint64]float64, map[uint64]float64) {
+	qtyByMarket := make(map[uint64]float64, 8)
+	priceByMarket := make(map[uint64]float64, 8)
+	for _, event := range events {
+		qty, ok := normalizedFillQty(event)
+		if !ok {
+			continue
+		}
+		direction := -1.0
+		if isBuySide(event.Side) {
+			direction = 1.0
+		}
+		qtyByMarket[event.MarketID] += direction * qty
+		priceByMarket[event.MarketID] = event.Price
+	}
+	return qtyByMarket, priceByMarket
+}
+
+// openPositionNotional is the absolute notional value of each market's net
+// open position, summed across markets, using each market's last observed
+// fill price as a mark. It's accounting-mode independent (net signed
+// qty, not lot-by-lot), since MaxPositionUSDC is a position-size guard
+// rather than a PnL calculation.
+func openPositionNotional(events []tradeEvent) float64 {
+	qtyByMarket, priceByMarket := marketPositions(events)
+	total := 0.0
+	for marketID, qty := range qtyByMarket {
+		total += math.Abs(qty) * priceByMarket[marketID]
+	}
+	return total
+}
+
+func (s *Store) insertRiskEvent(ctx context.Context, event RiskEvent) error {
+	_, err := s.db.ExecContext(
+		ctx,
+		`INSERT INTO risk_events (agent_id, breach_type, threshold, observed, action, detected_at)
+		 VALUES (?, ?, ?, ?, ?, ?)`,
+		event.AgentID,
+		string(event.BreachType),
+		event.Threshold,
+		event.Observed,
+		event.Action,
+		event.DetectedAt,
+	)
+	return err
+}
+
+// GetRiskEvents returns agentID's risk_events rows, newest first, so
+// operators can audit what RiskMonitor observed and acted on.
+func (s *Store) GetRiskEvents(ctx context.Context, agentID string, limit int) ([]RiskEvent, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT id, agent_id, breach_type, threshold, observed, action, detected_at
+		 FROM risk_events
+		 WHERE agent_id = ?
+		 ORDER BY detected_at DESC
+		 LIMIT ?`,
+		agentID,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]RiskEvent, 0, limit)
+	for rows.Next() {
+		var event RiskEvent
+		var breachType string
+		if err := rows.Scan(
+			&event.ID,
+			&event.AgentID,
+			&breachType,
+			&event.Threshold,
+			&event.Observed,
+			&event.Action,
+			&event.DetectedAt,
+		); err != nil {
+			return nil, err
+		}
+		event.BreachType = RiskBreachType(breachType)
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
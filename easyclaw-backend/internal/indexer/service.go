@@ -2,13 +2,19 @@ package indexer
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
 	"time"
 
 	lpvault "github.com/coldbell/dex/backend/internal/anchor/lp_vault"
 	orderengine "github.com/coldbell/dex/backend/internal/anchor/order_engine"
 	"github.com/coldbell/dex/backend/internal/config"
+	"github.com/coldbell/dex/backend/internal/indexer/pricesource"
+	"github.com/coldbell/dex/backend/internal/tenant"
 	"github.com/gagliardetto/solana-go"
 	"github.com/gagliardetto/solana-go/rpc"
 )
@@ -19,6 +25,22 @@ type Service struct {
 	store              *Store
 	logger             *slog.Logger
 	orderbookCollector *orderbookCollector
+	orderbookBroker    *OrderbookBroker
+	broker             *Broker
+	notifier           *ChannelNotifier
+	priceSources       []pricesource.Source
+	pythSource         *pricesource.PythSource
+	candleAggregator   *CandleAggregator
+	candleRollup       *CandleRollup
+	divergenceMonitor  *DivergenceMonitor
+	divergenceMarkets  []string
+	riskMonitor        *RiskMonitor
+
+	togglesMu sync.RWMutex
+	toggles   IndexerToggles
+
+	reconcileMu         sync.Mutex
+	lastFullReconcileAt map[solana.PublicKey]time.Time
 }
 
 func New(cfg config.IndexerConfig, logger *slog.Logger) (*Service, error) {
@@ -30,22 +52,193 @@ func New(cfg config.IndexerConfig, logger *slog.Logger) (*Service, error) {
 	orderengine.ProgramID = cfg.OrderEngineProgramID
 	lpvault.ProgramID = cfg.LpVaultProgramID
 
-	return &Service{
-		cfg:    cfg,
-		rpc:    rpc.New(cfg.RPCURL),
-		store:  store,
-		logger: logger,
+	orderbookBroker := newOrderbookBroker(logger)
+	aggregatedOrderbook := newAggregatedOrderbookCollector(cfg.ConsolidatedTargets, logger, orderbookBroker)
+	broker := NewBroker(logger)
+
+	svc := &Service{
+		cfg:                 cfg,
+		rpc:                 rpc.New(cfg.RPCURL),
+		store:               store,
+		logger:              logger,
+		orderbookBroker:     orderbookBroker,
+		broker:              broker,
+		notifier:            NewChannelNotifier(logger),
+		candleAggregator:    NewCandleAggregator(broker, logger),
+		candleRollup:        NewCandleRollup(broker, logger),
+		divergenceMonitor:   NewDivergenceMonitor(logger, cfg.PriceDivergenceRatio, cfg.PriceDivergenceDuration),
+		divergenceMarkets:   priceSourceMarkets(cfg),
+		riskMonitor:         NewRiskMonitor(logger),
+		lastFullReconcileAt: make(map[solana.PublicKey]time.Time),
 		orderbookCollector: newOrderbookCollector(
 			cfg.OrderbookTargets,
 			cfg.OrderbookDepth,
 			cfg.OrderbookRequestTimeout,
 			cfg.OrderbookRefreshInterval,
 			logger,
+			orderbookBroker,
+			aggregatedOrderbook,
+			cfg.OrderbookRateLimits,
 		),
-	}, nil
+	}
+
+	if cfg.EnablePythPriceStream {
+		if pythSource := pricesource.NewPythSource(cfg, logger); pythSource != nil {
+			svc.pythSource = pythSource
+			svc.priceSources = append(svc.priceSources, pythSource)
+		}
+	}
+	if chainlinkSource := pricesource.NewChainlinkSource(cfg, logger); chainlinkSource != nil {
+		svc.priceSources = append(svc.priceSources, chainlinkSource)
+	}
+
+	toggles, err := store.GetIndexerToggles(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("load indexer toggles: %w", err)
+	}
+	svc.toggles = toggles
+
+	return svc, nil
+}
+
+// Toggles returns the current operator-controlled subsystem state.
+func (s *Service) Toggles() IndexerToggles {
+	s.togglesMu.RLock()
+	defer s.togglesMu.RUnlock()
+	return s.toggles
+}
+
+// SetSubsystemToggle enables or disables one named sync subsystem
+// ("order_engine", "market_registry", "lp_vault", "orderbook_snapshots",
+// "pyth_stream") and persists the change so it survives a restart.
+func (s *Service) SetSubsystemToggle(ctx context.Context, subsystem string, enabled bool) error {
+	s.togglesMu.Lock()
+	toggles := s.toggles
+	switch subsystem {
+	case "order_engine":
+		toggles.ConsiderOrderEngine = enabled
+	case "market_registry":
+		toggles.ConsiderMarketRegistry = enabled
+	case "lp_vault":
+		toggles.ConsiderLPVault = enabled
+	case "orderbook_snapshots":
+		toggles.ConsiderOrderbookSnapshots = enabled
+	case "pyth_stream":
+		toggles.ConsiderPythStream = enabled
+	default:
+		s.togglesMu.Unlock()
+		return fmt.Errorf("unknown subsystem %q", subsystem)
+	}
+	s.toggles = toggles
+	s.togglesMu.Unlock()
+
+	return s.store.SetIndexerToggles(ctx, toggles)
+}
+
+// SetAccountTypeBlocked adds or removes accountType (e.g. "Order") from
+// the scan blocklist, letting operators pause one account type within a
+// subsystem (e.g. skip Order scans while UserMarketPosition keeps
+// syncing) without disabling the whole subsystem.
+func (s *Service) SetAccountTypeBlocked(ctx context.Context, accountType string, blocked bool) error {
+	s.togglesMu.Lock()
+	toggles := s.toggles
+	blockedTypes := make(map[string]bool, len(toggles.BlockedAccountTypes))
+	for k, v := range toggles.BlockedAccountTypes {
+		blockedTypes[k] = v
+	}
+	if blocked {
+		blockedTypes[accountType] = true
+	} else {
+		delete(blockedTypes, accountType)
+	}
+	toggles.BlockedAccountTypes = blockedTypes
+	s.toggles = toggles
+	s.togglesMu.Unlock()
+
+	return s.store.SetIndexerToggles(ctx, toggles)
+}
+
+func (s *Service) isAccountTypeBlocked(accountType string) bool {
+	s.togglesMu.RLock()
+	defer s.togglesMu.RUnlock()
+	return s.toggles.BlockedAccountTypes[accountType]
+}
+
+// PythFeedStatuses exposes the current per-feed health of the multiplexed
+// Pyth subscription for observability endpoints, when the Pyth source is
+// enabled.
+func (s *Service) PythFeedStatuses() []pricesource.PythFeedStatus {
+	if s.pythSource == nil {
+		return nil
+	}
+	return s.pythSource.FeedStatuses()
+}
+
+// ReloadPythFeeds swaps in a new set of routed Pyth feeds without
+// restarting the underlying stream connection.
+func (s *Service) ReloadPythFeeds(feeds []config.PythFeed) {
+	if s.pythSource == nil {
+		return
+	}
+	s.pythSource.ReloadFeeds(feeds)
+}
+
+// PythQualityStats exposes the accept/drop counters for the Pyth source's
+// confidence-interval, staleness, and slot-ordering gates, when the Pyth
+// source is enabled.
+func (s *Service) PythQualityStats() pricesource.PythQualityStats {
+	if s.pythSource == nil {
+		return pricesource.PythQualityStats{}
+	}
+	return s.pythSource.QualityStats()
+}
+
+// runPriceSources starts every enabled price source concurrently, each
+// emitting ticks into the shared market_price_ticks store keyed on
+// (source, market, feed_id) so multiple oracles can coexist for the same
+// market.
+func (s *Service) runPriceSources(ctx context.Context) {
+	for _, source := range s.priceSources {
+		go func(source pricesource.Source) {
+			err := source.Run(ctx, func(tick pricesource.Tick) error {
+				if source.Name() == "pyth" && !s.Toggles().ConsiderPythStream {
+					return nil
+				}
+				accepted, err := s.store.InsertMarketPriceTick(ctx, MarketPriceTickInput{
+					Market:       tick.Market,
+					Source:       tick.Source,
+					FeedID:       tick.FeedID,
+					Slot:         tick.Slot,
+					PublishTime:  tick.PublishTime,
+					Price:        tick.Price,
+					Conf:         tick.Conf,
+					Expo:         tick.Expo,
+					ReceivedAt:   tick.ReceivedAt,
+					RawJSON:      tick.RawJSON,
+					QualityFlags: uint32(tick.QualityFlags),
+				})
+				if err != nil {
+					return err
+				}
+				if accepted && tick.QualityFlags == 0 {
+					s.candleAggregator.Observe(normalizeMarketWithDefault(tick.Market), tick.PublishTime, tick.Price, 0)
+				}
+				return nil
+			})
+			if err != nil && ctx.Err() == nil {
+				s.logger.Error("price source exited with error", "source", source.Name(), "err", err)
+			}
+		}(source)
+	}
 }
 
 func (s *Service) Run(ctx context.Context) error {
+	// This deployment indexes a single tenant, so anchor ctx to the default
+	// tenant here, once, rather than threading a tenant ID through every
+	// caller below - tenant.RequireFromContext elsewhere still errors loudly
+	// if a future multi-tenant entry point forgets to scope its own ctx.
+	ctx = tenant.IntoContext(ctx, tenant.Default)
+
 	defer func() {
 		if err := s.store.Close(); err != nil {
 			s.logger.Error("failed to close store", "err", err)
@@ -71,8 +264,12 @@ func (s *Service) Run(ctx context.Context) error {
 		s.logger.Error("initial sync failed", "err", err)
 	}
 	s.initializeLocalOrderbook(ctx)
-	if s.cfg.EnablePythPriceStream {
-		go s.runPythPriceStream(ctx)
+	s.runStreamBroker(ctx)
+	s.runAdminServer(ctx)
+	s.runProgramSubscriptions(ctx)
+	if len(s.priceSources) > 0 {
+		s.logger.Info("price sources enabled", "count", len(s.priceSources))
+		s.runPriceSources(ctx)
 	}
 
 	ticker := time.NewTicker(s.cfg.PollInterval)
@@ -86,6 +283,36 @@ func (s *Service) Run(ctx context.Context) error {
 		defer snapshotTimer.Stop()
 	}
 
+	navTicker := time.NewTicker(s.cfg.NAVSampleInterval)
+	defer navTicker.Stop()
+
+	candleFlushTicker := time.NewTicker(candleFlushInterval)
+	defer candleFlushTicker.Stop()
+
+	var divergenceTicker *time.Ticker
+	var divergenceTickC <-chan time.Time
+	if s.cfg.EnablePriceDivergenceAlerts && len(s.divergenceMarkets) > 0 {
+		divergenceTicker = time.NewTicker(s.cfg.PriceDivergenceCheckInterval)
+		divergenceTickC = divergenceTicker.C
+		defer divergenceTicker.Stop()
+	}
+
+	var retentionTicker *time.Ticker
+	var retentionTickC <-chan time.Time
+	if s.cfg.EnableMarketPriceRetention {
+		retentionTicker = time.NewTicker(s.cfg.MarketPriceRetentionInterval)
+		retentionTickC = retentionTicker.C
+		defer retentionTicker.Stop()
+	}
+
+	var riskTicker *time.Ticker
+	var riskTickC <-chan time.Time
+	if s.cfg.EnableRiskMonitor {
+		riskTicker = time.NewTicker(s.cfg.RiskMonitorInterval)
+		riskTickC = riskTicker.C
+		defer riskTicker.Stop()
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -97,16 +324,115 @@ func (s *Service) Run(ctx context.Context) error {
 			}
 		case now := <-snapshotTickC:
 			snapshotTime := previousSnapshotTime(now, s.cfg.OrderbookSnapshotInterval)
-			if snapshotTime > 0 {
+			if snapshotTime > 0 && s.Toggles().ConsiderOrderbookSnapshots {
 				if err := s.syncOrderbookSnapshots(ctx, snapshotTime); err != nil {
 					s.logger.Error("orderbook snapshot sync failed", "err", err)
 				}
 			}
 			snapshotTimer.Reset(nextOrderbookSnapshotDelay(s.cfg.OrderbookSnapshotInterval))
+		case now := <-navTicker.C:
+			if err := s.sampleNAV(ctx, now); err != nil {
+				s.logger.Error("nav sampling failed", "err", err)
+			}
+		case <-candleFlushTicker.C:
+			if err := s.candleAggregator.FlushClosed(ctx, s.store); err != nil {
+				s.logger.Error("candle flush failed", "err", err)
+			}
+			if err := s.candleRollup.FlushClosed(ctx, s.store); err != nil {
+				s.logger.Error("candle rollup flush failed", "err", err)
+			}
+		case <-divergenceTickC:
+			for _, market := range s.divergenceMarkets {
+				if err := s.divergenceMonitor.Check(ctx, s.store, market); err != nil {
+					s.logger.Error("price divergence check failed", "market", market, "err", err)
+				}
+			}
+		case <-retentionTickC:
+			policy := RetentionPolicy{RawTTL: s.cfg.MarketPriceRawTTL}
+			if err := s.store.RunRetention(ctx, policy); err != nil {
+				s.logger.Error("market price retention failed", "err", err)
+			}
+		case <-riskTickC:
+			if err := s.riskMonitor.Check(ctx, s.store); err != nil {
+				s.logger.Error("risk monitor check failed", "err", err)
+			}
 		}
 	}
 }
 
+// candleFlushInterval bounds how long a closed candle can sit in memory
+// before FlushClosed persists it - generous relative to even the shortest
+// (1m) aggregation interval, since a subscriber that wants the bucket the
+// instant it closes already gets it over RecordTopicCandles; this flush is
+// only for GetMarketCandles' historical read path.
+const candleFlushInterval = 5 * time.Second
+
+// priceSourceMarkets returns the distinct set of markets any configured
+// price source reports on, for DivergenceMonitor to poll. It's derived
+// from config rather than discovered from market_price_ticks so a market
+// with only one source still gets no-op divergence checks instead of
+// needing to be listed separately.
+func priceSourceMarkets(cfg config.IndexerConfig) []string {
+	seen := make(map[string]struct{})
+	var markets []string
+	add := func(market string) {
+		normalized := NormalizeMarketSymbol(market)
+		if normalized == "" {
+			return
+		}
+		if _, ok := seen[normalized]; ok {
+			return
+		}
+		seen[normalized] = struct{}{}
+		markets = append(markets, normalized)
+	}
+	for _, feed := range cfg.PythFeeds {
+		add(feed.Market)
+	}
+	for _, feed := range cfg.ChainlinkFeeds {
+		add(feed.Market)
+	}
+	return markets
+}
+
+// sampleNAV snapshots every user_margin account's NAV as of now, so
+// accounts with no position events during the sample interval still get a
+// chart point. Mark prices are taken once per market from each market's
+// latest fill and shared across every account snapshotted this pass.
+func (s *Service) sampleNAV(ctx context.Context, now time.Time) error {
+	tenantID, err := tenant.RequireFromContext(ctx)
+	if err != nil {
+		return fmt.Errorf("tenant: %w", err)
+	}
+
+	slot, err := s.store.GetLastSyncedSlot(ctx)
+	if err != nil {
+		return fmt.Errorf("latest synced slot: %w", err)
+	}
+
+	return s.store.WithTx(ctx, func(tx *Tx) error {
+		userMargins, err := distinctUserMarginsTx(ctx, tx, tenantID)
+		if err != nil {
+			return fmt.Errorf("list user margins: %w", err)
+		}
+		if len(userMargins) == 0 {
+			return nil
+		}
+
+		markPrices, err := latestMarkPricesTx(ctx, tx)
+		if err != nil {
+			return fmt.Errorf("latest mark prices: %w", err)
+		}
+
+		for _, userMargin := range userMargins {
+			if err := s.store.SnapshotUserMarginNAVTx(ctx, tx, tenantID, userMargin, slot, now.Unix(), markPrices); err != nil {
+				return fmt.Errorf("snapshot nav for %s: %w", userMargin, err)
+			}
+		}
+		return nil
+	})
+}
+
 func (s *Service) initializeLocalOrderbook(ctx context.Context) {
 	if s.orderbookCollector == nil || len(s.cfg.OrderbookTargets) == 0 {
 		return
@@ -125,6 +451,37 @@ func (s *Service) initializeLocalOrderbook(ctx context.Context) {
 	}
 
 	s.orderbookCollector.Start(ctx)
+	s.runOrderbookBroker(ctx)
+}
+
+// runOrderbookBroker starts the /ws/orderbook websocket fan-out endpoint in
+// the background when INDEXER_ORDERBOOK_WS_ADDR is configured, so clients
+// can stream live top-of-book updates without polling the snapshot store.
+func (s *Service) runOrderbookBroker(ctx context.Context) {
+	addr := strings.TrimSpace(s.cfg.OrderbookWebsocketAddr)
+	if s.orderbookBroker == nil || addr == "" {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws/orderbook", s.orderbookBroker.ServeWS)
+
+	server := &http.Server{
+		Addr:    addr,
+		Handler: mux,
+	}
+
+	go func() {
+		<-ctx.Done()
+		_ = server.Shutdown(context.Background())
+	}()
+
+	go func() {
+		s.logger.Info("orderbook websocket broker started", "listen_addr", addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error("orderbook websocket broker exited with error", "err", err)
+		}
+	}()
 }
 
 func (s *Service) syncOrderbookSnapshots(ctx context.Context, snapshotTime int64) error {
@@ -137,14 +494,53 @@ func (s *Service) syncOrderbookSnapshots(ctx context.Context, snapshotTime int64
 		return nil
 	}
 
-	return s.store.WithTx(ctx, func(tx *Tx) error {
+	published := make([]OrderbookHeatmapRecord, 0, len(targetSnapshots))
+	err := s.store.WithTx(ctx, func(tx *Tx) error {
 		for _, snapshot := range targetSnapshots {
-			if _, err := s.store.UpsertOrderbookSnapshotTx(ctx, tx, snapshot); err != nil {
+			id, err := s.store.UpsertOrderbookSnapshotTx(ctx, tx, snapshot)
+			if err != nil {
 				return fmt.Errorf("upsert orderbook snapshot for %s:%s: %w", snapshot.Exchange, snapshot.Symbol, err)
 			}
+			published = append(published, orderbookSnapshotToHeatmapRecord(id, snapshot))
 		}
 		return nil
 	})
+	if err != nil {
+		return err
+	}
+
+	if s.broker != nil {
+		for _, record := range published {
+			s.broker.Publish(RecordTopicOrderbookHeatmap, record)
+		}
+	}
+	return nil
+}
+
+// orderbookSnapshotToHeatmapRecord converts a freshly-upserted OrderbookSnapshot
+// into the OrderbookHeatmapRecord shape ListOrderbookHeatmap and its stream
+// both deal in, so a live-published record and a backfilled one are
+// indistinguishable to a /v1/stream/orderbook_heatmap subscriber.
+func orderbookSnapshotToHeatmapRecord(id int64, snapshot OrderbookSnapshot) OrderbookHeatmapRecord {
+	levels := make([]OrderbookHeatmapLevel, 0, len(snapshot.Levels))
+	for _, level := range snapshot.Levels {
+		levels = append(levels, OrderbookHeatmapLevel{
+			Side:     level.Side,
+			Level:    level.Level,
+			Price:    level.Price,
+			Quantity: level.Quantity,
+		})
+	}
+	return OrderbookHeatmapRecord{
+		ID:                id,
+		Exchange:          snapshot.Exchange,
+		Symbol:            snapshot.Symbol,
+		SnapshotTime:      snapshot.SnapshotTime,
+		ExchangeTimestamp: snapshot.ExchangeTimestamp,
+		BestBid:           snapshot.BestBid,
+		BestAsk:           snapshot.BestAsk,
+		Levels:            levels,
+	}
 }
 
 func nextOrderbookSnapshotDelay(interval time.Duration) time.Duration {
@@ -181,23 +577,56 @@ func normalizeOrderbookSnapshotInterval(interval time.Duration) time.Duration {
 	return time.Duration(wholeMinutes) * time.Minute
 }
 
+// syncedBlockWindow bounds how many trailing slots RewindTo can fall back
+// through: PruneSyncedBlocksBeforeTx drops anything older once the window
+// has this many slots behind the current one.
+const syncedBlockWindow = 500
+
 func (s *Service) syncOnce(ctx context.Context) error {
 	slot, err := s.rpc.GetSlot(ctx, s.cfg.Commitment)
 	if err != nil {
 		return fmt.Errorf("get slot: %w", err)
 	}
 
+	blockHash, parentHash, err := s.blockHashes(ctx, slot)
+	if err != nil {
+		return fmt.Errorf("get block hashes for slot %d: %w", slot, err)
+	}
+	if blockHash != "" {
+		if err := s.rewindOnFork(ctx, slot, parentHash); err != nil {
+			return fmt.Errorf("check for fork: %w", err)
+		}
+	}
+
 	stats := map[string]int{}
+	batch := &syncPublishBatch{}
 
+	toggles := s.Toggles()
 	err = s.store.WithTx(ctx, func(tx *Tx) error {
-		if err := s.syncOrderEngine(ctx, tx, slot, stats); err != nil {
-			return err
+		if toggles.ConsiderOrderEngine {
+			if err := s.syncOrderEngine(ctx, tx, slot, stats, batch); err != nil {
+				return err
+			}
 		}
-		if err := s.syncMarketRegistry(ctx, tx, slot, stats); err != nil {
-			return err
+		if toggles.ConsiderMarketRegistry {
+			if err := s.syncMarketRegistry(ctx, tx, slot, stats); err != nil {
+				return err
+			}
 		}
-		if err := s.syncLPVault(ctx, tx, slot, stats); err != nil {
-			return err
+		if toggles.ConsiderLPVault {
+			if err := s.syncLPVault(ctx, tx, slot, stats, batch); err != nil {
+				return err
+			}
+		}
+		if blockHash != "" {
+			if err := s.store.RecordSyncedBlockTx(ctx, tx, slot, blockHash, parentHash); err != nil {
+				return fmt.Errorf("record synced block: %w", err)
+			}
+			if slot > syncedBlockWindow {
+				if err := s.store.PruneSyncedBlocksBeforeTx(ctx, tx, slot-syncedBlockWindow); err != nil {
+					return fmt.Errorf("prune synced blocks: %w", err)
+				}
+			}
 		}
 		return s.store.UpsertSyncStateTx(ctx, tx, slot)
 	})
@@ -205,6 +634,8 @@ func (s *Service) syncOnce(ctx context.Context) error {
 		return err
 	}
 
+	s.publishBatch(batch)
+
 	s.logger.Info(
 		"sync complete",
 		"slot", slot,
@@ -217,166 +648,376 @@ func (s *Service) syncOnce(ctx context.Context) error {
 	return nil
 }
 
-func (s *Service) syncOrderEngine(ctx context.Context, tx *Tx, slot uint64, stats map[string]int) error {
-	programID := s.cfg.OrderEngineProgramID
-
-	if err := s.scanAndStore(ctx, tx, slot, programID, "EngineConfig", orderengine.Account_EngineConfig,
-		func(item *rpc.KeyedAccount) error {
-			payload, err := orderengine.ParseAccount_EngineConfig(item.Account.Data.GetBinary())
-			if err != nil {
-				return err
-			}
-			stats["resources"]++
-			return s.store.UpsertResourceTx(ctx, tx, item.Pubkey, programID, "EngineConfig", item.Account.Owner, item.Account.Lamports, slot, payload)
-		}); err != nil {
-		return err
+// blockHashes fetches slot's own block hash and its predecessor's hash, for
+// syncOnce's fork check. A missing block (e.g. slot was skipped, or the RPC
+// node hasn't backfilled it yet) isn't an error here: syncOnce just skips
+// the fork check for this pass and tries again next tick, since a skipped
+// slot has no hash to compare against anyway.
+func (s *Service) blockHashes(ctx context.Context, slot uint64) (blockHash, parentHash string, err error) {
+	maxSupportedTransactionVersion := uint64(0)
+	block, err := s.rpc.GetBlockWithOpts(ctx, slot, &rpc.GetBlockOpts{
+		Commitment:                     s.cfg.Commitment,
+		MaxSupportedTransactionVersion: &maxSupportedTransactionVersion,
+		TransactionDetails:             rpc.TransactionDetailsNone,
+		Rewards:                        &[]bool{false}[0],
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "skipped") || strings.Contains(err.Error(), "not available") {
+			return "", "", nil
+		}
+		return "", "", err
 	}
-
-	if err := s.scanAndStore(ctx, tx, slot, programID, "UserMargin", orderengine.Account_UserMargin,
-		func(item *rpc.KeyedAccount) error {
-			payload, err := orderengine.ParseAccount_UserMargin(item.Account.Data.GetBinary())
-			if err != nil {
-				return err
-			}
-			stats["resources"]++
-			return s.store.UpsertResourceTx(ctx, tx, item.Pubkey, programID, "UserMargin", item.Account.Owner, item.Account.Lamports, slot, payload)
-		}); err != nil {
-		return err
+	if block == nil {
+		return "", "", nil
 	}
+	return block.Blockhash.String(), block.PreviousBlockhash.String(), nil
+}
 
-	if err := s.scanAndStore(ctx, tx, slot, programID, "MarketFundingState", orderengine.Account_MarketFundingState,
-		func(item *rpc.KeyedAccount) error {
-			payload, err := orderengine.ParseAccount_MarketFundingState(item.Account.Data.GetBinary())
-			if err != nil {
-				return err
-			}
-			stats["resources"]++
-			return s.store.UpsertResourceTx(ctx, tx, item.Pubkey, programID, "MarketFundingState", item.Account.Owner, item.Account.Lamports, slot, payload)
-		}); err != nil {
-		return err
+// rewindOnFork compares slot's parentHash against the block_hash this
+// indexer already committed for the preceding synced slot. A mismatch means
+// the chain forked since that slot was synced: everything synced after the
+// fork point came from a block that's no longer canonical, so RewindTo
+// deletes/restores it, and forceFullReconcile makes the next sync pass
+// re-sweep every program fully rather than trusting a now-stale incremental
+// bookmark.
+func (s *Service) rewindOnFork(ctx context.Context, slot uint64, parentHash string) error {
+	last, err := s.store.LastSyncedBlockBefore(ctx, slot)
+	if err != nil {
+		return fmt.Errorf("last synced block: %w", err)
 	}
-
-	if err := s.scanAndStore(ctx, tx, slot, programID, "Order", orderengine.Account_Order,
-		func(item *rpc.KeyedAccount) error {
-			payload, err := orderengine.ParseAccount_Order(item.Account.Data.GetBinary())
-			if err != nil {
-				return err
-			}
-			stats["orders"]++
-			return s.store.UpsertOrderTx(ctx, tx, item.Pubkey, slot, payload)
-		}); err != nil {
-		return err
+	if last == nil || last.BlockHash == parentHash {
+		return nil
 	}
 
-	if err := s.scanAndStore(ctx, tx, slot, programID, "UserMarketPosition", orderengine.Account_UserMarketPosition,
-		func(item *rpc.KeyedAccount) error {
-			payload, err := orderengine.ParseAccount_UserMarketPosition(item.Account.Data.GetBinary())
-			if err != nil {
-				return err
-			}
-			stats["positions"]++
-			return s.store.UpsertPositionTx(ctx, tx, item.Pubkey, slot, payload)
-		}); err != nil {
-		return err
+	s.logger.Warn("fork detected, rewinding",
+		"slot", slot,
+		"rewind_to", last.Slot,
+		"expected_parent_hash", last.BlockHash,
+		"observed_parent_hash", parentHash,
+	)
+	if err := s.store.RewindTo(ctx, last.Slot); err != nil {
+		return fmt.Errorf("rewind to slot %d: %w", last.Slot, err)
 	}
-
+	s.forceFullReconcile()
 	return nil
 }
 
-func (s *Service) syncMarketRegistry(ctx context.Context, tx *Tx, slot uint64, stats map[string]int) error {
-	programID := s.cfg.MarketRegistryProgramID
+// syncPublishBatch accumulates the records touched by one syncOnce pass, so
+// they can be handed to Broker.Publish after the transaction that wrote them
+// has actually committed, rather than while it's still in flight.
+type syncPublishBatch struct {
+	orders          []OrderRecord
+	fills           []FillRecord
+	positions       []PositionRecord
+	positionHistory []PositionHistoryRecord
+	events          []Event
+}
 
-	if err := s.scanAndStore(ctx, tx, slot, programID, "GlobalConfig", orderengine.Account_GlobalConfig,
-		func(item *rpc.KeyedAccount) error {
-			payload, err := orderengine.ParseAccount_GlobalConfig(item.Account.Data.GetBinary())
-			if err != nil {
-				return err
-			}
-			stats["resources"]++
-			return s.store.UpsertResourceTx(ctx, tx, item.Pubkey, programID, "GlobalConfig", item.Account.Owner, item.Account.Lamports, slot, payload)
-		}); err != nil {
-		return err
+// publishBatch fans out every record gathered during syncOnce to s.broker,
+// one topic at a time, and every Event to s.notifier. It's a no-op for the
+// broker half when no stream subscribers exist, since Broker.Publish
+// returns immediately for a topic with no subscribers; the notifier half
+// similarly drops silently if nothing is draining ChannelNotifier.Events().
+func (s *Service) publishBatch(batch *syncPublishBatch) {
+	if s.broker != nil {
+		for _, record := range batch.orders {
+			s.broker.Publish(RecordTopicOrders, record)
+		}
+		for _, record := range batch.fills {
+			s.broker.Publish(RecordTopicFills, record)
+			price := parseScaledFloat(record.Price, rawPriceScale)
+			qty := parseScaledFloat(record.Margin, rawNotionalScale)
+			s.candleRollup.Observe(record.MarketID, record.ExecutedAt, price, qty)
+		}
+		for _, record := range batch.positions {
+			s.broker.Publish(RecordTopicPositions, record)
+		}
+		for _, record := range batch.positionHistory {
+			s.broker.Publish(RecordTopicPositionHistory, record)
+		}
 	}
+	if s.notifier != nil {
+		for _, event := range batch.events {
+			s.notifier.Notify(event)
+		}
+	}
+}
 
-	if err := s.scanAndStore(ctx, tx, slot, programID, "KeeperSet", orderengine.Account_KeeperSet,
-		func(item *rpc.KeyedAccount) error {
-			payload, err := orderengine.ParseAccount_KeeperSet(item.Account.Data.GetBinary())
-			if err != nil {
-				return err
-			}
-			stats["resources"]++
-			return s.store.UpsertResourceTx(ctx, tx, item.Pubkey, programID, "KeeperSet", item.Account.Owner, item.Account.Lamports, slot, payload)
-		}); err != nil {
-		return err
+// dueForFullReconcile reports whether programID hasn't had a full
+// GetProgramAccountsWithOpts sweep within IncrementalReconcileInterval,
+// and if so marks it as reconciled as of now. Incremental mode relies on
+// this to periodically heal any account an accidentally-dropped
+// signature or transaction fetch failure would otherwise leave stale.
+func (s *Service) dueForFullReconcile(programID solana.PublicKey) bool {
+	s.reconcileMu.Lock()
+	defer s.reconcileMu.Unlock()
+
+	last := s.lastFullReconcileAt[programID]
+	if time.Since(last) < s.cfg.IncrementalReconcileInterval {
+		return false
 	}
+	s.lastFullReconcileAt[programID] = time.Now()
+	return true
+}
 
-	if err := s.scanAndStore(ctx, tx, slot, programID, "Market", orderengine.Account_Market,
-		func(item *rpc.KeyedAccount) error {
-			payload, err := orderengine.ParseAccount_Market(item.Account.Data.GetBinary())
-			if err != nil {
-				return err
-			}
-			stats["resources"]++
-			return s.store.UpsertResourceTx(ctx, tx, item.Pubkey, programID, "Market", item.Account.Owner, item.Account.Lamports, slot, payload)
-		}); err != nil {
-		return err
+// forceFullReconcile clears every program's last-full-reconcile timestamp,
+// so the next syncOnce pass treats all of them as due for a full
+// GetProgramAccountsWithOpts sweep regardless of IncrementalReconcileInterval.
+// RewindTo deletes rows a fork invalidated; this is what makes the
+// following pass replay current on-chain state back into them, rather
+// than incremental mode trusting its now-stale last-synced-slot bookmark.
+func (s *Service) forceFullReconcile() {
+	s.reconcileMu.Lock()
+	defer s.reconcileMu.Unlock()
+	s.lastFullReconcileAt = make(map[solana.PublicKey]time.Time)
+}
+
+// syncProgramEntries dispatches programID's account scan according to
+// mode: "full" always sweeps every entry via scanAndStore, exactly as
+// before incremental mode existed; "incremental" diffs the slot window
+// since the last sync instead, unless a reconcile sweep is due.
+func (s *Service) syncProgramEntries(ctx context.Context, tx *Tx, programID solana.PublicKey, mode string, slot uint64, entries []programDiscriminatorEntry) error {
+	if mode == "incremental" && !s.dueForFullReconcile(programID) {
+		lastSlot, err := s.store.GetLastSyncedSlot(ctx)
+		if err != nil {
+			return fmt.Errorf("load last synced slot: %w", err)
+		}
+		if lastSlot > 0 {
+			return s.incrementalSyncProgram(ctx, programID, entries, lastSlot, slot)
+		}
 	}
 
+	for _, entry := range entries {
+		if err := s.scanAndStore(ctx, tx, slot, programID, entry.AccountType, entry.Discriminator, entry.Handle); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func (s *Service) syncLPVault(ctx context.Context, tx *Tx, slot uint64, stats map[string]int) error {
-	programID := s.cfg.LpVaultProgramID
+func (s *Service) syncOrderEngine(ctx context.Context, tx *Tx, slot uint64, stats map[string]int, batch *syncPublishBatch) error {
+	programID := s.cfg.OrderEngineProgramID
+	entries := s.orderEngineEntries(ctx, tx, slot, stats, batch)
+	return s.syncProgramEntries(ctx, tx, programID, s.cfg.OrderEngineSyncMode, slot, entries)
+}
 
-	if err := s.scanAndStore(ctx, tx, slot, programID, "Pool", lpvault.Account_Pool,
-		func(item *rpc.KeyedAccount) error {
-			payload, err := lpvault.ParseAccount_Pool(item.Account.Data.GetBinary())
-			if err != nil {
-				return err
-			}
-			stats["resources"]++
-			return s.store.UpsertResourceTx(ctx, tx, item.Pubkey, programID, "Pool", item.Account.Owner, item.Account.Lamports, slot, payload)
-		}); err != nil {
-		return err
-	}
+// orderEngineEntries builds the order engine's discriminator dispatch
+// table against tx/stats/batch. syncOrderEngine calls this for a full or
+// incremental sweep; runProgramSubscriptions calls it again with a
+// fresh per-notification transaction so pushed accounts are parsed and
+// upserted through the exact same callbacks.
+func (s *Service) orderEngineEntries(ctx context.Context, tx *Tx, slot uint64, stats map[string]int, batch *syncPublishBatch) []programDiscriminatorEntry {
+	programID := s.cfg.OrderEngineProgramID
 
-	if err := s.scanAndStore(ctx, tx, slot, programID, "KeeperRebate", lpvault.Account_KeeperRebate,
-		func(item *rpc.KeyedAccount) error {
-			payload, err := lpvault.ParseAccount_KeeperRebate(item.Account.Data.GetBinary())
-			if err != nil {
-				return err
-			}
-			stats["resources"]++
-			return s.store.UpsertResourceTx(ctx, tx, item.Pubkey, programID, "KeeperRebate", item.Account.Owner, item.Account.Lamports, slot, payload)
-		}); err != nil {
-		return err
+	return []programDiscriminatorEntry{
+		{
+			AccountType:   "EngineConfig",
+			Discriminator: orderengine.Account_EngineConfig,
+			Handle: func(item *rpc.KeyedAccount) error {
+				payload, err := orderengine.ParseAccount_EngineConfig(item.Account.Data.GetBinary())
+				if err != nil {
+					return err
+				}
+				stats["resources"]++
+				return s.store.UpsertResourceTx(ctx, tx, item.Pubkey, programID, "EngineConfig", item.Account.Owner, item.Account.Lamports, slot, payload)
+			},
+		},
+		{
+			AccountType:   "UserMargin",
+			Discriminator: orderengine.Account_UserMargin,
+			Handle: func(item *rpc.KeyedAccount) error {
+				payload, err := orderengine.ParseAccount_UserMargin(item.Account.Data.GetBinary())
+				if err != nil {
+					return err
+				}
+				stats["resources"]++
+				return s.store.UpsertResourceTx(ctx, tx, item.Pubkey, programID, "UserMargin", item.Account.Owner, item.Account.Lamports, slot, payload)
+			},
+		},
+		{
+			AccountType:   "MarketFundingState",
+			Discriminator: orderengine.Account_MarketFundingState,
+			Handle: func(item *rpc.KeyedAccount) error {
+				payload, err := orderengine.ParseAccount_MarketFundingState(item.Account.Data.GetBinary())
+				if err != nil {
+					return err
+				}
+				stats["resources"]++
+				return s.store.UpsertResourceTx(ctx, tx, item.Pubkey, programID, "MarketFundingState", item.Account.Owner, item.Account.Lamports, slot, payload)
+			},
+		},
+		{
+			AccountType:   "Order",
+			Discriminator: orderengine.Account_Order,
+			Handle: func(item *rpc.KeyedAccount) error {
+				payload, err := orderengine.ParseAccount_Order(item.Account.Data.GetBinary())
+				if err != nil {
+					return err
+				}
+				stats["orders"]++
+				order, fill, event, err := s.store.UpsertOrderTx(ctx, tx, item.Pubkey, slot, payload)
+				if err != nil {
+					return err
+				}
+				batch.orders = append(batch.orders, *order)
+				if fill != nil {
+					batch.fills = append(batch.fills, *fill)
+				}
+				if event != nil {
+					batch.events = append(batch.events, *event)
+				}
+				return nil
+			},
+		},
+		{
+			AccountType:   "UserMarketPosition",
+			Discriminator: orderengine.Account_UserMarketPosition,
+			Handle: func(item *rpc.KeyedAccount) error {
+				payload, err := orderengine.ParseAccount_UserMarketPosition(item.Account.Data.GetBinary())
+				if err != nil {
+					return err
+				}
+				stats["positions"]++
+				position, history, event, err := s.store.UpsertPositionTx(ctx, tx, item.Pubkey, slot, payload)
+				if err != nil {
+					return err
+				}
+				batch.positions = append(batch.positions, *position)
+				if history != nil {
+					batch.positionHistory = append(batch.positionHistory, *history)
+				}
+				if event != nil {
+					batch.events = append(batch.events, *event)
+				}
+				return nil
+			},
+		},
 	}
+}
 
-	if err := s.scanAndStore(ctx, tx, slot, programID, "LpPosition", lpvault.Account_LpPosition,
-		func(item *rpc.KeyedAccount) error {
-			payload, err := lpvault.ParseAccount_LpPosition(item.Account.Data.GetBinary())
-			if err != nil {
-				return err
-			}
-			stats["lp_positions"]++
-			return s.store.UpsertLPPositionTx(ctx, tx, item.Pubkey, slot, payload)
-		}); err != nil {
-		return err
+func (s *Service) syncMarketRegistry(ctx context.Context, tx *Tx, slot uint64, stats map[string]int) error {
+	programID := s.cfg.MarketRegistryProgramID
+	entries := s.marketRegistryEntries(ctx, tx, slot, stats)
+	return s.syncProgramEntries(ctx, tx, programID, s.cfg.MarketRegistrySyncMode, slot, entries)
+}
+
+// marketRegistryEntries builds the market registry's discriminator
+// dispatch table, shared by syncMarketRegistry and
+// runProgramSubscriptions the same way orderEngineEntries is.
+func (s *Service) marketRegistryEntries(ctx context.Context, tx *Tx, slot uint64, stats map[string]int) []programDiscriminatorEntry {
+	programID := s.cfg.MarketRegistryProgramID
+
+	return []programDiscriminatorEntry{
+		{
+			AccountType:   "GlobalConfig",
+			Discriminator: orderengine.Account_GlobalConfig,
+			Handle: func(item *rpc.KeyedAccount) error {
+				payload, err := orderengine.ParseAccount_GlobalConfig(item.Account.Data.GetBinary())
+				if err != nil {
+					return err
+				}
+				stats["resources"]++
+				return s.store.UpsertResourceTx(ctx, tx, item.Pubkey, programID, "GlobalConfig", item.Account.Owner, item.Account.Lamports, slot, payload)
+			},
+		},
+		{
+			AccountType:   "KeeperSet",
+			Discriminator: orderengine.Account_KeeperSet,
+			Handle: func(item *rpc.KeyedAccount) error {
+				payload, err := orderengine.ParseAccount_KeeperSet(item.Account.Data.GetBinary())
+				if err != nil {
+					return err
+				}
+				stats["resources"]++
+				return s.store.UpsertResourceTx(ctx, tx, item.Pubkey, programID, "KeeperSet", item.Account.Owner, item.Account.Lamports, slot, payload)
+			},
+		},
+		{
+			AccountType:   "Market",
+			Discriminator: orderengine.Account_Market,
+			Handle: func(item *rpc.KeyedAccount) error {
+				payload, err := orderengine.ParseAccount_Market(item.Account.Data.GetBinary())
+				if err != nil {
+					return err
+				}
+				stats["resources"]++
+				return s.store.UpsertResourceTx(ctx, tx, item.Pubkey, programID, "Market", item.Account.Owner, item.Account.Lamports, slot, payload)
+			},
+		},
 	}
+}
 
-	if err := s.scanAndStore(ctx, tx, slot, programID, "WithdrawRequest", lpvault.Account_WithdrawRequest,
-		func(item *rpc.KeyedAccount) error {
-			payload, err := lpvault.ParseAccount_WithdrawRequest(item.Account.Data.GetBinary())
-			if err != nil {
-				return err
-			}
-			stats["resources"]++
-			return s.store.UpsertResourceTx(ctx, tx, item.Pubkey, programID, "WithdrawRequest", item.Account.Owner, item.Account.Lamports, slot, payload)
-		}); err != nil {
-		return err
+func (s *Service) syncLPVault(ctx context.Context, tx *Tx, slot uint64, stats map[string]int, batch *syncPublishBatch) error {
+	programID := s.cfg.LpVaultProgramID
+	entries := s.lpVaultEntries(ctx, tx, slot, stats, batch)
+	return s.syncProgramEntries(ctx, tx, programID, s.cfg.LpVaultSyncMode, slot, entries)
+}
+
+// lpVaultEntries builds the LP vault's discriminator dispatch table,
+// shared by syncLPVault and runProgramSubscriptions the same way
+// orderEngineEntries is.
+func (s *Service) lpVaultEntries(ctx context.Context, tx *Tx, slot uint64, stats map[string]int, batch *syncPublishBatch) []programDiscriminatorEntry {
+	programID := s.cfg.LpVaultProgramID
+
+	return []programDiscriminatorEntry{
+		{
+			AccountType:   "Pool",
+			Discriminator: lpvault.Account_Pool,
+			Handle: func(item *rpc.KeyedAccount) error {
+				payload, err := lpvault.ParseAccount_Pool(item.Account.Data.GetBinary())
+				if err != nil {
+					return err
+				}
+				stats["resources"]++
+				return s.store.UpsertResourceTx(ctx, tx, item.Pubkey, programID, "Pool", item.Account.Owner, item.Account.Lamports, slot, payload)
+			},
+		},
+		{
+			AccountType:   "KeeperRebate",
+			Discriminator: lpvault.Account_KeeperRebate,
+			Handle: func(item *rpc.KeyedAccount) error {
+				payload, err := lpvault.ParseAccount_KeeperRebate(item.Account.Data.GetBinary())
+				if err != nil {
+					return err
+				}
+				stats["resources"]++
+				return s.store.UpsertResourceTx(ctx, tx, item.Pubkey, programID, "KeeperRebate", item.Account.Owner, item.Account.Lamports, slot, payload)
+			},
+		},
+		{
+			AccountType:   "LpPosition",
+			Discriminator: lpvault.Account_LpPosition,
+			Handle: func(item *rpc.KeyedAccount) error {
+				payload, err := lpvault.ParseAccount_LpPosition(item.Account.Data.GetBinary())
+				if err != nil {
+					return err
+				}
+				stats["lp_positions"]++
+				event, err := s.store.UpsertLPPositionTx(ctx, tx, item.Pubkey, slot, payload)
+				if err != nil {
+					return err
+				}
+				if event != nil {
+					batch.events = append(batch.events, *event)
+				}
+				return nil
+			},
+		},
+		{
+			AccountType:   "WithdrawRequest",
+			Discriminator: lpvault.Account_WithdrawRequest,
+			Handle: func(item *rpc.KeyedAccount) error {
+				payload, err := lpvault.ParseAccount_WithdrawRequest(item.Account.Data.GetBinary())
+				if err != nil {
+					return err
+				}
+				stats["resources"]++
+				return s.store.UpsertResourceTx(ctx, tx, item.Pubkey, programID, "WithdrawRequest", item.Account.Owner, item.Account.Lamports, slot, payload)
+			},
+		},
 	}
 
-	return nil
+	return s.syncProgramEntries(ctx, tx, programID, s.cfg.LpVaultSyncMode, slot, entries)
 }
 
 func (s *Service) scanAndStore(
@@ -388,6 +1029,10 @@ func (s *Service) scanAndStore(
 	discriminator [8]byte,
 	handler func(item *rpc.KeyedAccount) error,
 ) error {
+	if s.isAccountTypeBlocked(accountType) {
+		return nil
+	}
+
 	accounts, err := s.rpc.GetProgramAccountsWithOpts(ctx, programID, &rpc.GetProgramAccountsOpts{
 		Commitment: s.cfg.Commitment,
 		Filters: []rpc.RPCFilter{
@@ -12,33 +12,40 @@ import (
 
 	lpvault "github.com/coldbell/dex/backend/internal/anchor/lp_vault"
 	orderengine "github.com/coldbell/dex/backend/internal/anchor/order_engine"
+	"github.com/coldbell/dex/backend/internal/indexer/migrate"
+	"github.com/coldbell/dex/backend/internal/tenant"
 	"github.com/gagliardetto/solana-go"
 
+	_ "github.com/go-sql-driver/mysql"
 	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
 )
 
 type Store struct {
-	db *DB
+	db               *DB
+	leaderboardCache *leaderboardCache
 }
 
 type DB struct {
-	raw *sql.DB
+	raw     *sql.DB
+	dialect Dialect
 }
 
 type Tx struct {
-	raw *sql.Tx
+	raw     *sql.Tx
+	dialect Dialect
 }
 
 func (db *DB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
-	return db.raw.ExecContext(ctx, rebindPostgresPlaceholders(query), args...)
+	return db.raw.ExecContext(ctx, db.dialect.Rebind(query), args...)
 }
 
 func (db *DB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
-	return db.raw.QueryContext(ctx, rebindPostgresPlaceholders(query), args...)
+	return db.raw.QueryContext(ctx, db.dialect.Rebind(query), args...)
 }
 
 func (db *DB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
-	return db.raw.QueryRowContext(ctx, rebindPostgresPlaceholders(query), args...)
+	return db.raw.QueryRowContext(ctx, db.dialect.Rebind(query), args...)
 }
 
 func (db *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
@@ -46,7 +53,7 @@ func (db *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &Tx{raw: tx}, nil
+	return &Tx{raw: tx, dialect: db.dialect}, nil
 }
 
 func (db *DB) Close() error {
@@ -54,19 +61,19 @@ func (db *DB) Close() error {
 }
 
 func (tx *Tx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
-	return tx.raw.ExecContext(ctx, rebindPostgresPlaceholders(query), args...)
+	return tx.raw.ExecContext(ctx, tx.dialect.Rebind(query), args...)
 }
 
 func (tx *Tx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
-	return tx.raw.QueryContext(ctx, rebindPostgresPlaceholders(query), args...)
+	return tx.raw.QueryContext(ctx, tx.dialect.Rebind(query), args...)
 }
 
 func (tx *Tx) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
-	return tx.raw.QueryRowContext(ctx, rebindPostgresPlaceholders(query), args...)
+	return tx.raw.QueryRowContext(ctx, tx.dialect.Rebind(query), args...)
 }
 
 func (tx *Tx) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
-	return tx.raw.PrepareContext(ctx, rebindPostgresPlaceholders(query))
+	return tx.raw.PrepareContext(ctx, tx.dialect.Rebind(query))
 }
 
 func (tx *Tx) Commit() error {
@@ -115,9 +122,10 @@ func rebindPostgresPlaceholders(query string) string {
 }
 
 func NewStore(dbDSN string) (*Store, error) {
-	db, err := sql.Open("pgx", dbDSN)
+	driverName, dialect := dialectForDSN(dbDSN)
+	db, err := sql.Open(driverName, dbDSN)
 	if err != nil {
-		return nil, fmt.Errorf("open postgres: %w", err)
+		return nil, fmt.Errorf("open %s: %w", dialect.Name(), err)
 	}
 	db.SetConnMaxIdleTime(30 * time.Second)
 	db.SetMaxIdleConns(4)
@@ -127,10 +135,10 @@ func NewStore(dbDSN string) (*Store, error) {
 	defer cancel()
 	if err := db.PingContext(pingCtx); err != nil {
 		_ = db.Close()
-		return nil, fmt.Errorf("ping postgres: %w", err)
+		return nil, fmt.Errorf("ping %s: %w", dialect.Name(), err)
 	}
 
-	store := &Store{db: &DB{raw: db}}
+	store := &Store{db: &DB{raw: db, dialect: dialect}, leaderboardCache: newLeaderboardCache()}
 	if err := store.migrate(context.Background()); err != nil {
 		_ = db.Close()
 		return nil, err
@@ -164,349 +172,42 @@ func (s *Store) WithTx(ctx context.Context, fn func(*Tx) error) error {
 	return nil
 }
 
+// migrate applies every pending schema migration embedded in the
+// internal/indexer/migrate package, refusing to boot if a previously
+// applied migration's checksum has drifted from what's embedded in this
+// binary. See that package for the versioned migration chain this used
+// to be a flat, unconditionally-rerun DDL slice.
 func (s *Store) migrate(ctx context.Context) error {
-	ddl := []string{
-		`CREATE TABLE IF NOT EXISTS sync_state (
-			id BIGINT PRIMARY KEY CHECK (id = 1),
-			last_slot BIGINT NOT NULL,
-			updated_at BIGINT NOT NULL
-		);`,
-		`CREATE TABLE IF NOT EXISTS positions (
-			pubkey TEXT PRIMARY KEY,
-			user_margin TEXT NOT NULL,
-			market_id BIGINT NOT NULL,
-			long_qty TEXT NOT NULL,
-			long_entry_notional TEXT NOT NULL,
-			short_qty TEXT NOT NULL,
-			short_entry_notional TEXT NOT NULL,
-			last_funding_index_long TEXT NOT NULL,
-			last_funding_index_short TEXT NOT NULL,
-			raw_json TEXT NOT NULL,
-			slot BIGINT NOT NULL,
-			updated_at BIGINT NOT NULL
-		);`,
-		`CREATE INDEX IF NOT EXISTS idx_positions_user_market ON positions(user_margin, market_id);`,
-		`CREATE TABLE IF NOT EXISTS lp_positions (
-			pubkey TEXT PRIMARY KEY,
-			owner TEXT NOT NULL,
-			pool TEXT NOT NULL,
-			shares TEXT NOT NULL,
-			pending_shares TEXT NOT NULL,
-			withdraw_nonce TEXT NOT NULL,
-			raw_json TEXT NOT NULL,
-			slot BIGINT NOT NULL,
-			updated_at BIGINT NOT NULL
-		);`,
-		`CREATE INDEX IF NOT EXISTS idx_lp_positions_owner_pool ON lp_positions(owner, pool);`,
-		`CREATE TABLE IF NOT EXISTS orders (
-			pubkey TEXT PRIMARY KEY,
-			user_margin TEXT NOT NULL,
-			user_pubkey TEXT NOT NULL,
-			market_id BIGINT NOT NULL,
-			side TEXT NOT NULL,
-			order_type TEXT NOT NULL,
-			reduce_only INTEGER NOT NULL,
-			margin TEXT NOT NULL,
-			price TEXT NOT NULL,
-			status TEXT NOT NULL,
-			created_at INTEGER NOT NULL,
-			expires_at INTEGER NOT NULL,
-			client_order_id TEXT NOT NULL,
-			raw_json TEXT NOT NULL,
-			slot BIGINT NOT NULL,
-			updated_at BIGINT NOT NULL
-		);`,
-		`CREATE INDEX IF NOT EXISTS idx_orders_market_status ON orders(market_id, status);`,
-		`CREATE TABLE IF NOT EXISTS fills (
-			id BIGSERIAL PRIMARY KEY,
-			order_pubkey TEXT NOT NULL UNIQUE,
-			user_margin TEXT NOT NULL,
-			user_pubkey TEXT NOT NULL,
-			market_id BIGINT NOT NULL,
-			side TEXT NOT NULL,
-			order_type TEXT NOT NULL,
-			reduce_only INTEGER NOT NULL,
-			margin TEXT NOT NULL,
-			price TEXT NOT NULL,
-			client_order_id TEXT NOT NULL,
-			created_at INTEGER NOT NULL,
-			expires_at INTEGER NOT NULL,
-			executed_slot BIGINT NOT NULL,
-			executed_at BIGINT NOT NULL,
-			raw_json TEXT NOT NULL
-		);`,
-		`CREATE INDEX IF NOT EXISTS idx_fills_user_market_time ON fills(user_margin, market_id, executed_at DESC);`,
-		`CREATE INDEX IF NOT EXISTS idx_fills_user_pubkey_time ON fills(user_pubkey, executed_at DESC);`,
-		`CREATE TABLE IF NOT EXISTS position_history (
-			id BIGSERIAL PRIMARY KEY,
-			position_pubkey TEXT NOT NULL,
-			user_margin TEXT NOT NULL,
-			market_id BIGINT NOT NULL,
-			event_type TEXT NOT NULL,
-			prev_long_qty TEXT NOT NULL,
-			prev_long_entry_notional TEXT NOT NULL,
-			prev_short_qty TEXT NOT NULL,
-			prev_short_entry_notional TEXT NOT NULL,
-			prev_last_funding_index_long TEXT NOT NULL,
-			prev_last_funding_index_short TEXT NOT NULL,
-			next_long_qty TEXT NOT NULL,
-			next_long_entry_notional TEXT NOT NULL,
-			next_short_qty TEXT NOT NULL,
-			next_short_entry_notional TEXT NOT NULL,
-			next_last_funding_index_long TEXT NOT NULL,
-			next_last_funding_index_short TEXT NOT NULL,
-			slot BIGINT NOT NULL,
-			recorded_at BIGINT NOT NULL
-		);`,
-		`CREATE INDEX IF NOT EXISTS idx_position_history_user_market_time ON position_history(user_margin, market_id, recorded_at DESC);`,
-		`CREATE INDEX IF NOT EXISTS idx_position_history_position_slot ON position_history(position_pubkey, slot DESC);`,
-		`INSERT INTO position_history (
-			position_pubkey, user_margin, market_id, event_type,
-			prev_long_qty, prev_long_entry_notional, prev_short_qty, prev_short_entry_notional,
-			prev_last_funding_index_long, prev_last_funding_index_short,
-			next_long_qty, next_long_entry_notional, next_short_qty, next_short_entry_notional,
-			next_last_funding_index_long, next_last_funding_index_short,
-			slot, recorded_at
-		)
-		SELECT
-			p.pubkey, p.user_margin, p.market_id, 'snapshot',
-			p.long_qty, p.long_entry_notional, p.short_qty, p.short_entry_notional,
-			p.last_funding_index_long, p.last_funding_index_short,
-			p.long_qty, p.long_entry_notional, p.short_qty, p.short_entry_notional,
-			p.last_funding_index_long, p.last_funding_index_short,
-			p.slot, p.updated_at
-		FROM positions p
-		WHERE NOT EXISTS (
-			SELECT 1 FROM position_history h WHERE h.position_pubkey = p.pubkey
-		);`,
-		`CREATE TABLE IF NOT EXISTS resources (
-			pubkey TEXT PRIMARY KEY,
-			program_id TEXT NOT NULL,
-			account_type TEXT NOT NULL,
-			owner TEXT NOT NULL,
-			lamports BIGINT NOT NULL,
-			raw_json TEXT NOT NULL,
-			slot BIGINT NOT NULL,
-			updated_at BIGINT NOT NULL
-		);`,
-		`CREATE INDEX IF NOT EXISTS idx_resources_program_type ON resources(program_id, account_type);`,
-		`CREATE TABLE IF NOT EXISTS market_price_ticks (
-			id BIGSERIAL PRIMARY KEY,
-			market TEXT NOT NULL,
-			source TEXT NOT NULL,
-			feed_id TEXT NOT NULL,
-			slot BIGINT NOT NULL,
-			publish_time BIGINT NOT NULL,
-			price DOUBLE PRECISION NOT NULL,
-			conf DOUBLE PRECISION NOT NULL,
-			expo INTEGER NOT NULL,
-			received_at BIGINT NOT NULL,
-			raw_json TEXT NOT NULL
-		);`,
-		`CREATE UNIQUE INDEX IF NOT EXISTS idx_market_price_ticks_dedupe ON market_price_ticks(market, source, publish_time, slot);`,
-		`CREATE INDEX IF NOT EXISTS idx_market_price_ticks_market_time ON market_price_ticks(market, publish_time DESC, slot DESC, id DESC);`,
-		`CREATE TABLE IF NOT EXISTS auth_challenges (
-			id TEXT PRIMARY KEY,
-			wallet_pubkey TEXT NOT NULL,
-			intent TEXT NOT NULL,
-			message TEXT NOT NULL,
-			created_at BIGINT NOT NULL,
-			expires_at BIGINT NOT NULL,
-			used_at BIGINT
-		);`,
-		`CREATE INDEX IF NOT EXISTS idx_auth_challenges_wallet_created ON auth_challenges(wallet_pubkey, created_at DESC);`,
-		`CREATE TABLE IF NOT EXISTS auth_sessions (
-			token_hash TEXT PRIMARY KEY,
-			wallet_pubkey TEXT NOT NULL,
-			created_at BIGINT NOT NULL,
-			expires_at BIGINT NOT NULL,
-			refreshed_at BIGINT NOT NULL,
-			revoked_at BIGINT
-		);`,
-		`CREATE INDEX IF NOT EXISTS idx_auth_sessions_wallet_exp ON auth_sessions(wallet_pubkey, expires_at DESC);`,
-		`CREATE TABLE IF NOT EXISTS agents (
-			id TEXT PRIMARY KEY,
-			name TEXT NOT NULL,
-			strategy_id TEXT NOT NULL,
-			status TEXT NOT NULL,
-			owner_pubkey TEXT NOT NULL,
-			risk_profile_json TEXT NOT NULL,
-			bound_at BIGINT,
-			session_expires_at BIGINT,
-			created_at BIGINT NOT NULL,
-			updated_at BIGINT NOT NULL
-		);`,
-		`CREATE INDEX IF NOT EXISTS idx_agents_owner ON agents(owner_pubkey);`,
-		`CREATE INDEX IF NOT EXISTS idx_agents_status ON agents(status);`,
-		`CREATE TABLE IF NOT EXISTS agent_sessions (
-			id TEXT PRIMARY KEY,
-			agent_id TEXT NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
-			mode TEXT NOT NULL,
-			status TEXT NOT NULL,
-			started_at BIGINT NOT NULL,
-			stopped_at BIGINT,
-			created_by TEXT NOT NULL,
-			created_at BIGINT NOT NULL
-		);`,
-		`CREATE INDEX IF NOT EXISTS idx_agent_sessions_agent_status ON agent_sessions(agent_id, status, started_at DESC);`,
-		`CREATE TABLE IF NOT EXISTS strategy_templates (
-			id TEXT PRIMARY KEY,
-			name TEXT NOT NULL,
-			description TEXT NOT NULL,
-			risk_level TEXT NOT NULL,
-			rules_json TEXT NOT NULL,
-			created_at BIGINT NOT NULL
-		);`,
-		`CREATE TABLE IF NOT EXISTS strategies (
-			id TEXT PRIMARY KEY,
-			name TEXT NOT NULL,
-			entry_rules_json TEXT NOT NULL,
-			exit_rules_json TEXT NOT NULL,
-			risk_defaults_json TEXT NOT NULL,
-			owner_pubkey TEXT NOT NULL,
-			is_published INTEGER NOT NULL DEFAULT 0,
-			published_at BIGINT,
-			created_at BIGINT NOT NULL,
-			updated_at BIGINT NOT NULL
-		);`,
-		`CREATE INDEX IF NOT EXISTS idx_strategies_owner ON strategies(owner_pubkey, updated_at DESC);`,
-	}
-
-	for _, query := range ddl {
-		if _, err := s.db.ExecContext(ctx, query); err != nil {
-			return fmt.Errorf("migration failed: %w", err)
-		}
-	}
-
-	if err := s.renameLegacyOrderColumns(ctx); err != nil {
-		return err
-	}
-
-	if err := s.backfillExecutedFills(ctx); err != nil {
-		return err
+	migrator, err := migrate.New(s.db.raw)
+	if err != nil {
+		return fmt.Errorf("load schema migrations: %w", err)
 	}
-	if err := s.seedStrategyTemplates(ctx); err != nil {
-		return err
+	if err := migrator.Up(ctx); err != nil {
+		return fmt.Errorf("apply schema migrations: %w", err)
 	}
-
 	return nil
 }
 
-func (s *Store) seedStrategyTemplates(ctx context.Context) error {
-	now := time.Now().Unix()
-	templates := []struct {
-		id          string
-		name        string
-		description string
-		riskLevel   string
-		rules       string
-	}{
-		{
-			id:          "preset-momentum",
-			name:        "Momentum Trend",
-			description: "RSI/MACD based trend follow entries with ATR stop.",
-			riskLevel:   "medium",
-			rules:       `{"entry":{"indicators":["rsi","macd"],"condition":"trend_follow"},"exit":{"stop":"atr","take_profit":"dynamic"},"timeframe":"15m"}`,
-		},
-		{
-			id:          "preset-mean-reversion",
-			name:        "Mean Reversion",
-			description: "Bollinger band deviation entries with mid-band exits.",
-			riskLevel:   "low",
-			rules:       `{"entry":{"indicator":"bollinger","condition":"band_deviation"},"exit":{"target":"middle_band","stop":"fixed"},"timeframe":"5m"}`,
-		},
-		{
-			id:          "preset-breakout",
-			name:        "Volatility Breakout",
-			description: "Breakout continuation with volume confirmation.",
-			riskLevel:   "high",
-			rules:       `{"entry":{"indicator":"bollinger","condition":"breakout","confirm":"volume"},"exit":{"stop":"trailing","take_profit":"rr_2"},"timeframe":"1h"}`,
-		},
-	}
-
-	for _, template := range templates {
-		if _, err := s.db.ExecContext(
-			ctx,
-			`INSERT INTO strategy_templates (id, name, description, risk_level, rules_json, created_at)
-			 VALUES (?, ?, ?, ?, ?, ?)
-			 ON CONFLICT(id) DO UPDATE SET
-			   name = excluded.name,
-			   description = excluded.description,
-			   risk_level = excluded.risk_level,
-			   rules_json = excluded.rules_json`,
-			template.id,
-			template.name,
-			template.description,
-			template.riskLevel,
-			template.rules,
-			now,
-		); err != nil {
-			return fmt.Errorf("seed strategy template %s: %w", template.id, err)
-		}
+func (s *Store) hasColumn(ctx context.Context, table, column string) (bool, error) {
+	if s.db.dialect.Name() == "sqlite" {
+		return s.hasColumnSQLite(ctx, table, column)
 	}
-	return nil
-}
 
-func (s *Store) renameLegacyOrderColumns(ctx context.Context) error {
-	renames := []struct {
-		table string
-		from  string
-		to    string
-	}{
-		{table: "orders", from: "qty", to: "margin"},
-		{table: "orders", from: "limit_price", to: "price"},
-		{table: "fills", from: "qty", to: "margin"},
-		{table: "fills", from: "limit_price", to: "price"},
+	schemaExpr := "current_schema()"
+	if s.db.dialect.Name() == "mysql" {
+		schemaExpr = "database()"
 	}
 
-	for _, rename := range renames {
-		hasFrom, err := s.hasColumn(ctx, rename.table, rename.from)
-		if err != nil {
-			return err
-		}
-		if !hasFrom {
-			continue
-		}
-
-		hasTo, err := s.hasColumn(ctx, rename.table, rename.to)
-		if err != nil {
-			return err
-		}
-		if hasTo {
-			continue
-		}
-
-		query := fmt.Sprintf(
-			"ALTER TABLE %s RENAME COLUMN %s TO %s",
-			rename.table,
-			rename.from,
-			rename.to,
-		)
-		if _, err := s.db.ExecContext(ctx, query); err != nil {
-			return fmt.Errorf(
-				"rename legacy column %s.%s to %s: %w",
-				rename.table,
-				rename.from,
-				rename.to,
-				err,
-			)
-		}
-	}
-
-	return nil
-}
-
-func (s *Store) hasColumn(ctx context.Context, table, column string) (bool, error) {
 	row := s.db.QueryRowContext(
 		ctx,
-		`
+		fmt.Sprintf(`
 		SELECT 1
 		FROM information_schema.columns
-		WHERE table_schema = current_schema()
+		WHERE table_schema = %s
 		  AND table_name = ?
 		  AND column_name = ?
 		LIMIT 1
-		`,
+		`, schemaExpr),
 		table,
 		column,
 	)
@@ -521,46 +222,69 @@ func (s *Store) hasColumn(ctx context.Context, table, column string) (bool, erro
 	return one == 1, nil
 }
 
-func (s *Store) backfillExecutedFills(ctx context.Context) error {
-	_, err := s.db.ExecContext(ctx, `
-		INSERT INTO fills (
-			order_pubkey, user_margin, user_pubkey, market_id, side, order_type,
-			reduce_only, margin, price, client_order_id, created_at, expires_at,
-			executed_slot, executed_at, raw_json
-		)
-		SELECT
-			o.pubkey, o.user_margin, o.user_pubkey, o.market_id, o.side, o.order_type,
-			o.reduce_only, o.margin, o.price, o.client_order_id, o.created_at, o.expires_at,
-			o.slot, o.updated_at, o.raw_json
-		FROM orders o
-		WHERE o.status = 'Executed'
-		  AND NOT EXISTS (
-			SELECT 1 FROM fills f WHERE f.order_pubkey = o.pubkey
-		  )
-	`)
+// hasColumnSQLite checks column existence via PRAGMA table_info, since
+// SQLite has no information_schema.
+func (s *Store) hasColumnSQLite(ctx context.Context, table, column string) (bool, error) {
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(`PRAGMA table_info(%s)`, table))
 	if err != nil {
-		return fmt.Errorf("backfill executed fills: %w", err)
+		return false, err
 	}
+	defer rows.Close()
 
-	return nil
+	for rows.Next() {
+		var cid int
+		var name, columnType string
+		var notNull, primaryKey int
+		var defaultValue any
+		if err := rows.Scan(&cid, &name, &columnType, &notNull, &defaultValue, &primaryKey); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
+// GetLastSyncedSlot returns the slot recorded by the most recent
+// UpsertSyncStateTx, or 0 if syncOnce has never completed a pass yet.
+func (s *Store) GetLastSyncedSlot(ctx context.Context) (uint64, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT last_slot FROM sync_state WHERE id = 1`)
+	var lastSlot int64
+	if err := row.Scan(&lastSlot); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return uint64(lastSlot), nil
 }
 
 func (s *Store) UpsertSyncStateTx(ctx context.Context, tx *Tx, slot uint64) error {
 	now := time.Now().Unix()
-	_, err := tx.ExecContext(ctx, `
+	query := fmt.Sprintf(`
 		INSERT INTO sync_state (id, last_slot, updated_at)
 		VALUES (1, ?, ?)
-		ON CONFLICT(id) DO UPDATE SET
-			last_slot = excluded.last_slot,
-			updated_at = excluded.updated_at
-	`, int64(slot), now)
+		%s
+	`, tx.dialect.UpsertClause([]string{"id"}, []string{"last_slot", "updated_at"}))
+	_, err := tx.ExecContext(ctx, query, int64(slot), now)
 	return err
 }
 
-func (s *Store) UpsertPositionTx(ctx context.Context, tx *Tx, pubkey solana.PublicKey, slot uint64, position *orderengine.UserMarketPosition) error {
+// UpsertPositionTx upserts position, returns the resulting PositionRecord,
+// a PositionHistoryRecord whenever the position's net exposure changed
+// (nil otherwise), and an Event (TopicPositionOpened/TopicPositionClosed)
+// whenever this upsert is the one that moved the position from flat to
+// non-flat or back, or nil otherwise.
+func (s *Store) UpsertPositionTx(ctx context.Context, tx *Tx, pubkey solana.PublicKey, slot uint64, position *orderengine.UserMarketPosition) (*PositionRecord, *PositionHistoryRecord, *Event, error) {
+	tenantID, err := tenant.RequireFromContext(ctx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
 	raw, err := json.Marshal(position)
 	if err != nil {
-		return err
+		return nil, nil, nil, err
 	}
 
 	pubkeyText := pubkey.String()
@@ -572,32 +296,28 @@ func (s *Store) UpsertPositionTx(ctx context.Context, tx *Tx, pubkey solana.Publ
 		LastFundingIndexLong:  position.LastFundingIndexLong.String(),
 		LastFundingIndexShort: position.LastFundingIndexShort.String(),
 	}
-	prevSnapshot, err := s.getPositionHistorySnapshotTx(ctx, tx, pubkeyText)
+	prevSnapshot, err := s.getPositionHistorySnapshotTx(ctx, tx, tenantID, pubkeyText)
 	if err != nil {
-		return err
+		return nil, nil, nil, err
 	}
 
 	now := time.Now().Unix()
 
-	_, err = tx.ExecContext(ctx, `
+	positionUpsertQuery := fmt.Sprintf(`
 		INSERT INTO positions (
-			pubkey, user_margin, market_id, long_qty, long_entry_notional, short_qty,
+			tenant_id, pubkey, user_margin, market_id, long_qty, long_entry_notional, short_qty,
 			short_entry_notional, last_funding_index_long, last_funding_index_short,
 			raw_json, slot, updated_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-		ON CONFLICT(pubkey) DO UPDATE SET
-			user_margin = excluded.user_margin,
-			market_id = excluded.market_id,
-			long_qty = excluded.long_qty,
-			long_entry_notional = excluded.long_entry_notional,
-			short_qty = excluded.short_qty,
-			short_entry_notional = excluded.short_entry_notional,
-			last_funding_index_long = excluded.last_funding_index_long,
-			last_funding_index_short = excluded.last_funding_index_short,
-			raw_json = excluded.raw_json,
-			slot = excluded.slot,
-			updated_at = excluded.updated_at
-	`,
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		%s
+	`, tx.dialect.UpsertClause([]string{"tenant_id", "pubkey"}, []string{
+		"user_margin", "market_id", "long_qty", "long_entry_notional", "short_qty",
+		"short_entry_notional", "last_funding_index_long", "last_funding_index_short",
+		"raw_json", "slot", "updated_at",
+	}))
+
+	_, err = tx.ExecContext(ctx, positionUpsertQuery,
+		tenantID,
 		pubkeyText,
 		position.UserMargin.String(),
 		int64(position.MarketId),
@@ -612,114 +332,201 @@ func (s *Store) UpsertPositionTx(ctx context.Context, tx *Tx, pubkey solana.Publ
 		now,
 	)
 	if err != nil {
-		return err
+		return nil, nil, nil, err
 	}
 
+	record := &PositionRecord{
+		Pubkey:                pubkeyText,
+		UserMargin:            position.UserMargin.String(),
+		MarketID:              position.MarketId,
+		LongQty:               nextSnapshot.LongQty,
+		LongEntryNotional:     nextSnapshot.LongEntryNotional,
+		ShortQty:              nextSnapshot.ShortQty,
+		ShortEntryNotional:    nextSnapshot.ShortEntryNotional,
+		LastFundingIndexLong:  nextSnapshot.LastFundingIndexLong,
+		LastFundingIndexShort: nextSnapshot.LastFundingIndexShort,
+		Slot:                  slot,
+		UpdatedAt:             now,
+	}
+
+	event, err := s.positionTransitionEventTx(ctx, tx, pubkeyText, position.UserMargin.String(), prevSnapshot, nextSnapshot)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// UpsertPositionTx only sees the parsed position account, not the
+	// instruction log a deposit/withdraw transfer would show up in, so
+	// there's no collateral transfer to attach here yet. The hook exists on
+	// insertPositionHistoryTx for a future caller that does parse
+	// instructions to pass one through.
+	var collateralTransfer *MarginTransfer
+
 	if prevSnapshot == nil {
-		return s.insertPositionHistoryTx(
-			ctx,
-			tx,
-			pubkeyText,
-			position.UserMargin.String(),
-			position.MarketId,
-			"snapshot",
-			zeroPositionHistorySnapshot(),
-			nextSnapshot,
-			slot,
-			now,
+		history, err := s.insertPositionHistoryTx(
+			ctx, tx, tenantID, pubkeyText, position.UserMargin.String(), position.MarketId,
+			"snapshot", zeroPositionHistorySnapshot(), nextSnapshot, slot, now, collateralTransfer,
 		)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return record, history, event, nil
 	}
 	if snapshotsEqual(*prevSnapshot, nextSnapshot) {
-		return nil
+		return record, nil, event, nil
 	}
 
-	return s.insertPositionHistoryTx(
-		ctx,
-		tx,
-		pubkeyText,
-		position.UserMargin.String(),
-		position.MarketId,
-		"update",
-		*prevSnapshot,
-		nextSnapshot,
-		slot,
-		now,
+	history, err := s.insertPositionHistoryTx(
+		ctx, tx, tenantID, pubkeyText, position.UserMargin.String(), position.MarketId,
+		"update", *prevSnapshot, nextSnapshot, slot, now, collateralTransfer,
 	)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return record, history, event, nil
+}
+
+// positionIsFlat reports whether a positionHistorySnapshot has zero
+// exposure on both sides, the same "flat" notion positionTransitionEventTx
+// uses to decide whether a position just opened or closed.
+func positionIsFlat(snapshot positionHistorySnapshot) bool {
+	return snapshot.LongQty == "0" && snapshot.ShortQty == "0"
 }
 
-func (s *Store) UpsertLPPositionTx(ctx context.Context, tx *Tx, pubkey solana.PublicKey, slot uint64, position *lpvault.LpPosition) error {
+// positionTransitionEventTx records a TopicPositionOpened event when prev
+// was nil/flat and next is not, a TopicPositionClosed event when prev was
+// not flat and next is flat, or nothing for any other transition (funding
+// index updates, partial fills that don't cross flat).
+func (s *Store) positionTransitionEventTx(ctx context.Context, tx *Tx, pubkeyText, userMargin string, prevSnapshot *positionHistorySnapshot, nextSnapshot positionHistorySnapshot) (*Event, error) {
+	wasFlat := prevSnapshot == nil || positionIsFlat(*prevSnapshot)
+	isFlat := positionIsFlat(nextSnapshot)
+
+	var topic EventTopic
+	switch {
+	case wasFlat && !isFlat:
+		topic = TopicPositionOpened
+	case !wasFlat && isFlat:
+		topic = TopicPositionClosed
+	default:
+		return nil, nil
+	}
+
+	event, err := s.recordEventTx(ctx, tx, topic, SeverityInfo, pubkeyText, "position "+string(topic), userMargin)
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// UpsertLPPositionTx upserts position and returns an Event
+// (TopicLPWithdrawFinalized) whenever this upsert is the one that
+// transitions pending_shares from non-zero to zero — the withdraw request
+// that was pending on the prior row has now settled — or nil otherwise.
+func (s *Store) UpsertLPPositionTx(ctx context.Context, tx *Tx, pubkey solana.PublicKey, slot uint64, position *lpvault.LpPosition) (*Event, error) {
 	raw, err := json.Marshal(position)
 	if err != nil {
-		return err
+		return nil, err
 	}
+
+	pubkeyText := pubkey.String()
+	prevPendingShares, err := s.getLPPositionPendingSharesTx(ctx, tx, pubkeyText)
+	if err != nil {
+		return nil, err
+	}
+
 	now := time.Now().Unix()
+	pendingShares := position.PendingShares.String()
 
-	_, err = tx.ExecContext(ctx, `
+	lpPositionUpsertQuery := fmt.Sprintf(`
 		INSERT INTO lp_positions (
 			pubkey, owner, pool, shares, pending_shares, withdraw_nonce,
 			raw_json, slot, updated_at
 		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
-		ON CONFLICT(pubkey) DO UPDATE SET
-			owner = excluded.owner,
-			pool = excluded.pool,
-			shares = excluded.shares,
-			pending_shares = excluded.pending_shares,
-			withdraw_nonce = excluded.withdraw_nonce,
-			raw_json = excluded.raw_json,
-			slot = excluded.slot,
-			updated_at = excluded.updated_at
-	`,
-		pubkey.String(),
+		%s
+	`, tx.dialect.UpsertClause([]string{"pubkey"}, []string{
+		"owner", "pool", "shares", "pending_shares", "withdraw_nonce",
+		"raw_json", "slot", "updated_at",
+	}))
+
+	_, err = tx.ExecContext(ctx, lpPositionUpsertQuery,
+		pubkeyText,
 		position.Owner.String(),
 		position.Pool.String(),
 		position.Shares.String(),
-		position.PendingShares.String(),
+		pendingShares,
 		strconv.FormatUint(position.WithdrawNonce, 10),
 		string(raw),
 		int64(slot),
 		now,
 	)
-	return err
+	if err != nil {
+		return nil, err
+	}
+
+	if prevPendingShares == nil || *prevPendingShares == "0" || pendingShares != "0" {
+		return nil, nil
+	}
+
+	event, err := s.recordEventTx(ctx, tx, TopicLPWithdrawFinalized, SeverityInfo, pubkeyText, "lp withdraw finalized", position.Owner.String())
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+func (s *Store) getLPPositionPendingSharesTx(ctx context.Context, tx *Tx, pubkey string) (*string, error) {
+	row := tx.QueryRowContext(ctx, `SELECT pending_shares FROM lp_positions WHERE pubkey = ?`, pubkey)
+	var pendingShares string
+	err := row.Scan(&pendingShares)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &pendingShares, nil
 }
 
-func (s *Store) UpsertOrderTx(ctx context.Context, tx *Tx, pubkey solana.PublicKey, slot uint64, order *orderengine.Order) error {
+// UpsertOrderTx upserts order and returns the resulting OrderRecord. It also
+// returns a FillRecord whenever the upsert is the one that transitioned the
+// order into OrderStatus_Executed (i.e. a fill), or nil otherwise, and an
+// Event (TopicOrderExecuted/TopicOrderCancelled/TopicOrderExpired)
+// whenever the upsert is the one that transitioned the order into one of
+// those terminal statuses, or nil otherwise.
+func (s *Store) UpsertOrderTx(ctx context.Context, tx *Tx, pubkey solana.PublicKey, slot uint64, order *orderengine.Order) (*OrderRecord, *FillRecord, *Event, error) {
+	tenantID, err := tenant.RequireFromContext(ctx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
 	raw, err := json.Marshal(order)
 	if err != nil {
-		return err
+		return nil, nil, nil, err
 	}
 
 	pubkeyText := pubkey.String()
-	prevOrderStatus, err := s.getOrderStatusTx(ctx, tx, pubkeyText)
+	prevOrderStatus, err := s.getOrderStatusTx(ctx, tx, tenantID, pubkeyText)
 	if err != nil {
-		return err
+		return nil, nil, nil, err
 	}
 
 	now := time.Now().Unix()
 	orderStatus := order.Status.String()
 
-	_, err = tx.ExecContext(ctx, `
+	orderUpsertQuery := fmt.Sprintf(`
 		INSERT INTO orders (
-			pubkey, user_margin, user_pubkey, market_id, side, order_type,
+			tenant_id, pubkey, user_margin, user_pubkey, market_id, side, order_type,
 			reduce_only, margin, price, status, created_at, expires_at,
 			client_order_id, raw_json, slot, updated_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-		ON CONFLICT(pubkey) DO UPDATE SET
-			user_margin = excluded.user_margin,
-			user_pubkey = excluded.user_pubkey,
-			market_id = excluded.market_id,
-			side = excluded.side,
-			order_type = excluded.order_type,
-			reduce_only = excluded.reduce_only,
-			margin = excluded.margin,
-			price = excluded.price,
-			status = excluded.status,
-			created_at = excluded.created_at,
-			expires_at = excluded.expires_at,
-			client_order_id = excluded.client_order_id,
-			raw_json = excluded.raw_json,
-			slot = excluded.slot,
-			updated_at = excluded.updated_at
-	`,
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		%s
+	`, tx.dialect.UpsertClause([]string{"tenant_id", "pubkey"}, []string{
+		"user_margin", "user_pubkey", "market_id", "side", "order_type",
+		"reduce_only", "margin", "price", "status", "created_at", "expires_at",
+		"client_order_id", "raw_json", "slot", "updated_at",
+	}))
+
+	_, err = tx.ExecContext(ctx, orderUpsertQuery,
+		tenantID,
 		pubkeyText,
 		order.UserMargin.String(),
 		order.User.String(),
@@ -738,23 +545,71 @@ func (s *Store) UpsertOrderTx(ctx context.Context, tx *Tx, pubkey solana.PublicK
 		now,
 	)
 	if err != nil {
-		return err
+		return nil, nil, nil, err
+	}
+
+	record := &OrderRecord{
+		Pubkey:        pubkeyText,
+		UserMargin:    order.UserMargin.String(),
+		UserPubkey:    order.User.String(),
+		MarketID:      order.MarketId,
+		Side:          order.Side.String(),
+		OrderType:     order.OrderType.String(),
+		ReduceOnly:    order.ReduceOnly,
+		Margin:        strconv.FormatUint(order.Margin, 10),
+		Price:         strconv.FormatUint(order.Price, 10),
+		Status:        orderStatus,
+		CreatedAt:     order.CreatedAt,
+		ExpiresAt:     order.ExpiresAt,
+		ClientOrderID: strconv.FormatUint(order.ClientOrderId, 10),
+		Slot:          slot,
+		UpdatedAt:     now,
+	}
+
+	prevStatusText := ""
+	if prevOrderStatus != nil {
+		prevStatusText = *prevOrderStatus
+	}
+	if err := s.recordOrderStatusTransitionTx(ctx, tx, tenantID, pubkeyText, prevStatusText, orderStatus, slot, now, "order account upsert"); err != nil {
+		return nil, nil, nil, err
+	}
+
+	var event *Event
+	if prevOrderStatus == nil || *prevOrderStatus != orderStatus {
+		topic, severity := orderEventTopic(orderStatus)
+		if topic != "" {
+			recorded, err := s.recordEventTx(ctx, tx, topic, severity, pubkeyText, "order "+orderStatus, order.User.String())
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			event = &recorded
+		}
 	}
 
 	if orderStatus != orderengine.OrderStatus_Executed.String() {
-		return nil
+		return record, nil, event, nil
 	}
 	if prevOrderStatus != nil && *prevOrderStatus == orderStatus {
-		return nil
+		return record, nil, event, nil
 	}
 
-	_, err = tx.ExecContext(ctx, `
+	marginRaw := strconv.FormatUint(order.Margin, 10)
+	priceRaw := strconv.FormatUint(order.Price, 10)
+
+	// This insert's ON CONFLICT DO NOTHING ... RETURNING id doesn't go
+	// through Dialect.UpsertClause: RETURNING on a DO NOTHING isn't
+	// something every backend supports the same way (MySQL has no
+	// RETURNING at all), and it's a dedupe guard rather than the
+	// upsert-field-list pattern the dialect abstracts. Still Postgres-only
+	// for now.
+	row := tx.QueryRowContext(ctx, `
 		INSERT INTO fills (
 			order_pubkey, user_margin, user_pubkey, market_id, side, order_type,
 			reduce_only, margin, price, client_order_id, created_at, expires_at,
 			executed_slot, executed_at, raw_json
 		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		ON CONFLICT(order_pubkey) DO NOTHING
+		RETURNING id
 	`,
 		pubkeyText,
 		order.UserMargin.String(),
@@ -763,8 +618,8 @@ func (s *Store) UpsertOrderTx(ctx context.Context, tx *Tx, pubkey solana.PublicK
 		order.Side.String(),
 		order.OrderType.String(),
 		boolToInt(order.ReduceOnly),
-		strconv.FormatUint(order.Margin, 10),
-		strconv.FormatUint(order.Price, 10),
+		marginRaw,
+		priceRaw,
 		strconv.FormatUint(order.ClientOrderId, 10),
 		order.CreatedAt,
 		order.ExpiresAt,
@@ -772,7 +627,55 @@ func (s *Store) UpsertOrderTx(ctx context.Context, tx *Tx, pubkey solana.PublicK
 		now,
 		string(raw),
 	)
-	return err
+
+	var fillID int64
+	if err := row.Scan(&fillID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return record, nil, event, nil
+		}
+		return nil, nil, nil, err
+	}
+
+	if err := s.upsertFillSummaryTx(ctx, tx, order.UserMargin.String(), order.MarketId, marginRaw, priceRaw, now); err != nil {
+		return nil, nil, nil, err
+	}
+
+	fill := &FillRecord{
+		ID:            fillID,
+		OrderPubkey:   pubkeyText,
+		UserMargin:    order.UserMargin.String(),
+		UserPubkey:    order.User.String(),
+		MarketID:      order.MarketId,
+		Side:          order.Side.String(),
+		OrderType:     order.OrderType.String(),
+		ReduceOnly:    order.ReduceOnly,
+		Margin:        marginRaw,
+		Price:         priceRaw,
+		ClientOrderID: strconv.FormatUint(order.ClientOrderId, 10),
+		CreatedAt:     order.CreatedAt,
+		ExpiresAt:     order.ExpiresAt,
+		ExecutedSlot:  slot,
+		ExecutedAt:    now,
+	}
+
+	return record, fill, event, nil
+}
+
+// orderEventTopic maps an order's new status string to the Event topic and
+// severity UpsertOrderTx should record for transitioning into it, or ""
+// for statuses that aren't terminal/notable enough to warrant an event
+// (e.g. Open, PartiallyFilled).
+func orderEventTopic(orderStatus string) (EventTopic, EventSeverity) {
+	switch orderStatus {
+	case orderengine.OrderStatus_Executed.String():
+		return TopicOrderExecuted, SeverityInfo
+	case orderengine.OrderStatus_Cancelled.String():
+		return TopicOrderCancelled, SeverityInfo
+	case orderengine.OrderStatus_Expired.String():
+		return TopicOrderExpired, SeverityInfo
+	default:
+		return "", ""
+	}
 }
 
 func (s *Store) UpsertResourceTx(
@@ -792,19 +695,16 @@ func (s *Store) UpsertResourceTx(
 	}
 	now := time.Now().Unix()
 
-	_, err = tx.ExecContext(ctx, `
+	resourceUpsertQuery := fmt.Sprintf(`
 		INSERT INTO resources (
 			pubkey, program_id, account_type, owner, lamports, raw_json, slot, updated_at
 		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
-		ON CONFLICT(pubkey) DO UPDATE SET
-			program_id = excluded.program_id,
-			account_type = excluded.account_type,
-			owner = excluded.owner,
-			lamports = excluded.lamports,
-			raw_json = excluded.raw_json,
-			slot = excluded.slot,
-			updated_at = excluded.updated_at
-	`,
+		%s
+	`, tx.dialect.UpsertClause([]string{"pubkey"}, []string{
+		"program_id", "account_type", "owner", "lamports", "raw_json", "slot", "updated_at",
+	}))
+
+	_, err = tx.ExecContext(ctx, resourceUpsertQuery,
 		pubkey.String(),
 		programID.String(),
 		accountType,
@@ -853,13 +753,13 @@ func snapshotsEqual(left, right positionHistorySnapshot) bool {
 		left.LastFundingIndexShort == right.LastFundingIndexShort
 }
 
-func (s *Store) getPositionHistorySnapshotTx(ctx context.Context, tx *Tx, pubkey string) (*positionHistorySnapshot, error) {
+func (s *Store) getPositionHistorySnapshotTx(ctx context.Context, tx *Tx, tenantID, pubkey string) (*positionHistorySnapshot, error) {
 	row := tx.QueryRowContext(
 		ctx,
 		`SELECT long_qty, long_entry_notional, short_qty, short_entry_notional, last_funding_index_long, last_funding_index_short
 		 FROM positions
-		 WHERE pubkey = ?`,
-		pubkey,
+		 WHERE tenant_id = ? AND pubkey = ?`,
+		tenantID, pubkey,
 	)
 
 	var snapshot positionHistorySnapshot
@@ -880,9 +780,12 @@ func (s *Store) getPositionHistorySnapshotTx(ctx context.Context, tx *Tx, pubkey
 	return &snapshot, nil
 }
 
+// insertPositionHistoryTx records one position transition and returns the
+// resulting PositionHistoryRecord.
 func (s *Store) insertPositionHistoryTx(
 	ctx context.Context,
 	tx *Tx,
+	tenantID string,
 	positionPubkey string,
 	userMargin string,
 	marketID uint64,
@@ -891,17 +794,23 @@ func (s *Store) insertPositionHistoryTx(
 	next positionHistorySnapshot,
 	slot uint64,
 	recordedAt int64,
-) error {
-	_, err := tx.ExecContext(ctx, `
+	collateralTransfer *MarginTransfer,
+) (*PositionHistoryRecord, error) {
+	fundingPaidLong, fundingPaidShort, fundingPaidTotal := computeFundingPayment(prev, next)
+
+	row := tx.QueryRowContext(ctx, `
 		INSERT INTO position_history (
-			position_pubkey, user_margin, market_id, event_type,
+			tenant_id, position_pubkey, user_margin, market_id, event_type,
 			prev_long_qty, prev_long_entry_notional, prev_short_qty, prev_short_entry_notional,
 			prev_last_funding_index_long, prev_last_funding_index_short,
 			next_long_qty, next_long_entry_notional, next_short_qty, next_short_entry_notional,
 			next_last_funding_index_long, next_last_funding_index_short,
+			funding_paid_long, funding_paid_short, funding_paid_total,
 			slot, recorded_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
 	`,
+		tenantID,
 		positionPubkey,
 		userMargin,
 		int64(marketID),
@@ -918,14 +827,70 @@ func (s *Store) insertPositionHistoryTx(
 		next.ShortEntryNotional,
 		next.LastFundingIndexLong,
 		next.LastFundingIndexShort,
+		fundingPaidLong,
+		fundingPaidShort,
+		fundingPaidTotal,
 		int64(slot),
 		recordedAt,
 	)
-	return err
-}
 
-func (s *Store) getOrderStatusTx(ctx context.Context, tx *Tx, pubkey string) (*string, error) {
-	row := tx.QueryRowContext(ctx, `SELECT status FROM orders WHERE pubkey = ?`, pubkey)
+	var id int64
+	if err := row.Scan(&id); err != nil {
+		return nil, err
+	}
+
+	// Take a NAV snapshot inline so idle-but-trading accounts get a point
+	// on every position event, not just when the periodic sampler runs.
+	// This only has a mark price for the market that just changed, so it
+	// undervalues a multi-market account's NAV until the periodic sampler
+	// (service.go) next fills in the rest - an acceptable gap since this
+	// snapshot's purpose is catching this event, not producing the
+	// authoritative cross-market reading.
+	if markPrice, ok, err := s.latestMarkPriceTx(ctx, tx, marketID); err == nil && ok {
+		if err := s.SnapshotUserMarginNAVTx(ctx, tx, tenantID, userMargin, slot, recordedAt, map[uint64]string{marketID: markPrice}); err != nil {
+			return nil, fmt.Errorf("snapshot nav: %w", err)
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("latest mark price: %w", err)
+	}
+
+	// Record the accompanying collateral transfer, if any, in the same tx
+	// as the position_history row it caused - so an external deposit/
+	// withdraw and the position change it's bundled with either both land
+	// or neither does, and NAV attribution can tell them apart later via
+	// GetMarginLedger.
+	if err := s.recordMarginTransferTx(ctx, tx, tenantID, userMargin, slot, recordedAt, collateralTransfer); err != nil {
+		return nil, fmt.Errorf("record margin transfer: %w", err)
+	}
+
+	return &PositionHistoryRecord{
+		ID:                        id,
+		PositionPubkey:            positionPubkey,
+		UserMargin:                userMargin,
+		MarketID:                  marketID,
+		EventType:                 eventType,
+		PrevLongQty:               prev.LongQty,
+		PrevLongEntryNotional:     prev.LongEntryNotional,
+		PrevShortQty:              prev.ShortQty,
+		PrevShortEntryNotional:    prev.ShortEntryNotional,
+		PrevLastFundingIndexLong:  prev.LastFundingIndexLong,
+		PrevLastFundingIndexShort: prev.LastFundingIndexShort,
+		NextLongQty:               next.LongQty,
+		NextLongEntryNotional:     next.LongEntryNotional,
+		NextShortQty:              next.ShortQty,
+		NextShortEntryNotional:    next.ShortEntryNotional,
+		NextLastFundingIndexLong:  next.LastFundingIndexLong,
+		NextLastFundingIndexShort: next.LastFundingIndexShort,
+		FundingPaidLong:           fundingPaidLong,
+		FundingPaidShort:          fundingPaidShort,
+		FundingPaidTotal:          fundingPaidTotal,
+		Slot:                      slot,
+		RecordedAt:                recordedAt,
+	}, nil
+}
+
+func (s *Store) getOrderStatusTx(ctx context.Context, tx *Tx, tenantID, pubkey string) (*string, error) {
+	row := tx.QueryRowContext(ctx, `SELECT status FROM orders WHERE tenant_id = ? AND pubkey = ?`, tenantID, pubkey)
 	var status string
 	err := row.Scan(&status)
 	if errors.Is(err, sql.ErrNoRows) {
@@ -936,3 +901,74 @@ func (s *Store) getOrderStatusTx(ctx context.Context, tx *Tx, pubkey string) (*s
 	}
 	return &status, nil
 }
+
+// recordOrderStatusTransitionTx appends one row to order_status_history for
+// an order moving from prevStatus to nextStatus, skipping entirely when
+// they're equal (no transition happened). Idempotent on
+// (order_pubkey, slot, next_status): a re-processed slot that upserts the
+// same order again produces the same transition row, which ON CONFLICT DO
+// NOTHING silently drops rather than duplicating. prevStatus is "" for an
+// order's first-ever observed status (getOrderStatusTx returned nil).
+//
+// Like the fills insert in UpsertOrderTx, this ON CONFLICT DO NOTHING
+// doesn't go through Dialect.UpsertClause - it's a dedupe guard, not the
+// upsert-field-list pattern the dialect abstracts - so it's Postgres-only
+// for now.
+func (s *Store) recordOrderStatusTransitionTx(ctx context.Context, tx *Tx, tenantID, pubkey string, prevStatus, nextStatus string, slot uint64, recordedAt int64, reason string) error {
+	if prevStatus == nextStatus {
+		return nil
+	}
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO order_status_history (tenant_id, order_pubkey, prev_status, next_status, reason, slot, recorded_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(order_pubkey, slot, next_status) DO NOTHING
+	`, tenantID, pubkey, prevStatus, nextStatus, reason, int64(slot), recordedAt)
+	return err
+}
+
+// OrderStatusTransition is one row of an order's order_status_history.
+type OrderStatusTransition struct {
+	ID          int64  `json:"id"`
+	OrderPubkey string `json:"order_pubkey"`
+	PrevStatus  string `json:"prev_status"`
+	NextStatus  string `json:"next_status"`
+	Reason      string `json:"reason"`
+	Slot        uint64 `json:"slot"`
+	RecordedAt  int64  `json:"recorded_at"`
+}
+
+// GetOrderStatusHistory returns every status transition recorded for
+// pubkey, oldest first, so callers can reconstruct its full lifecycle
+// (e.g. Open -> PartiallyFilled -> Filled/Cancelled/Expired) in order.
+func (s *Store) GetOrderStatusHistory(ctx context.Context, pubkey string) ([]OrderStatusTransition, error) {
+	tenantID, err := tenant.RequireFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, order_pubkey, prev_status, next_status, reason, slot, recorded_at
+		FROM order_status_history
+		WHERE tenant_id = ? AND order_pubkey = ?
+		ORDER BY slot ASC, id ASC
+	`, tenantID, pubkey)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]OrderStatusTransition, 0)
+	for rows.Next() {
+		var item OrderStatusTransition
+		var slot int64
+		if err := rows.Scan(&item.ID, &item.OrderPubkey, &item.PrevStatus, &item.NextStatus, &item.Reason, &slot, &item.RecordedAt); err != nil {
+			return nil, err
+		}
+		item.Slot = uint64(slot)
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,452 @@
+package indexer
+
+import (
+	"fmt"
+	"math"
+)
+
+// Strategy rule documents (StrategyRecord.EntryRules/ExitRules) are a
+// small JSON DSL rather than an opaque blob:
+//
+//	{
+//	  "condition": {"type": "gt", "left": {...indicator...}, "right": {...indicator...}},
+//	  "size_pct": 100
+//	}
+//
+// "condition" is a tree of boolean composition nodes ("and"/"or"/"not")
+// over comparator nodes ("gt"/"lt"/"gte"/"lte"/"eq"/"cross_above"/
+// "cross_below"), whose operands are indicator nodes ("sma"/"ema"/"rsi"/
+// "atr"/"price"/"const"). "size_pct" is only meaningful on EntryRules: the
+// percentage of account equity a Backtester (or the live path) sizes a
+// new position at.
+
+var knownIndicatorTypes = map[string]bool{
+	"sma": true, "ema": true, "rsi": true, "atr": true, "price": true, "const": true,
+}
+
+var knownComparatorTypes = map[string]bool{
+	"gt": true, "lt": true, "gte": true, "lte": true, "eq": true,
+	"cross_above": true, "cross_below": true,
+}
+
+var knownBoolOpTypes = map[string]bool{"and": true, "or": true, "not": true}
+
+var knownPriceSources = map[string]bool{
+	"open": true, "high": true, "low": true, "close": true, "volume": true,
+}
+
+// ValidateStrategyRules checks that rules (an EntryRules or ExitRules
+// document) is a well-formed DSL document, so CreateStrategy/PatchStrategy
+// reject a malformed strategy up front instead of failing later inside a
+// backtest or the live evaluation path.
+func ValidateStrategyRules(rules map[string]any) error {
+	if rules == nil {
+		return fmt.Errorf("strategy rules: missing")
+	}
+	conditionRaw, ok := rules["condition"]
+	if !ok {
+		return fmt.Errorf("strategy rules: missing %q", "condition")
+	}
+	condition, ok := conditionRaw.(map[string]any)
+	if !ok {
+		return fmt.Errorf("strategy rules: %q must be an object", "condition")
+	}
+	if err := validateConditionNode(condition); err != nil {
+		return err
+	}
+	if sizeRaw, ok := rules["size_pct"]; ok {
+		size, ok := toFloat(sizeRaw)
+		if !ok || size <= 0 || size > 100 {
+			return fmt.Errorf("strategy rules: %q must be a number in (0, 100]", "size_pct")
+		}
+	}
+	return nil
+}
+
+func validateConditionNode(node map[string]any) error {
+	typ, _ := node["type"].(string)
+	switch {
+	case knownBoolOpTypes[typ]:
+		return validateBoolOpNode(typ, node)
+	case knownComparatorTypes[typ]:
+		return validateComparatorNode(typ, node)
+	default:
+		return fmt.Errorf("strategy rules: unknown condition type %q", typ)
+	}
+}
+
+func validateBoolOpNode(typ string, node map[string]any) error {
+	if typ == "not" {
+		child, ok := node["rule"].(map[string]any)
+		if !ok {
+			return fmt.Errorf("strategy rules: %q node requires an object %q", "not", "rule")
+		}
+		return validateConditionNode(child)
+	}
+
+	rulesRaw, ok := node["rules"].([]any)
+	if !ok || len(rulesRaw) == 0 {
+		return fmt.Errorf("strategy rules: %q node requires a non-empty %q array", typ, "rules")
+	}
+	for _, childRaw := range rulesRaw {
+		child, ok := childRaw.(map[string]any)
+		if !ok {
+			return fmt.Errorf("strategy rules: %q node's %q entries must be objects", typ, "rules")
+		}
+		if err := validateConditionNode(child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateComparatorNode(typ string, node map[string]any) error {
+	left, ok := node["left"].(map[string]any)
+	if !ok {
+		return fmt.Errorf("strategy rules: %q node requires an object %q", typ, "left")
+	}
+	right, ok := node["right"].(map[string]any)
+	if !ok {
+		return fmt.Errorf("strategy rules: %q node requires an object %q", typ, "right")
+	}
+	if err := validateIndicatorNode(left); err != nil {
+		return err
+	}
+	return validateIndicatorNode(right)
+}
+
+func validateIndicatorNode(node map[string]any) error {
+	typ, _ := node["type"].(string)
+	if !knownIndicatorTypes[typ] {
+		return fmt.Errorf("strategy rules: unknown indicator type %q", typ)
+	}
+
+	switch typ {
+	case "const":
+		if _, ok := toFloat(node["value"]); !ok {
+			return fmt.Errorf("strategy rules: %q node requires a numeric %q", "const", "value")
+		}
+	case "price":
+		if source, ok := node["source"].(string); ok && source != "" && !knownPriceSources[source] {
+			return fmt.Errorf("strategy rules: %q is not a valid price source", source)
+		}
+	case "sma", "ema", "atr", "rsi":
+		period, ok := toFloat(node["period"])
+		if !ok || period < 1 {
+			return fmt.Errorf("strategy rules: %q node requires a positive integer %q", typ, "period")
+		}
+		if source, ok := node["source"].(string); ok && source != "" && typ != "atr" && !knownPriceSources[source] {
+			return fmt.Errorf("strategy rules: %q is not a valid price source", source)
+		}
+	}
+	return nil
+}
+
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	default:
+		return 0, false
+	}
+}
+
+// --- indicator/condition evaluation over a candle series ---
+
+// indicatorSeries evaluates an indicator node into a slice aligned 1:1
+// with candles; indices before an indicator's warmup period has elapsed
+// are math.NaN(), which evalConditionAt treats as "condition not met"
+// rather than a spurious signal.
+func indicatorSeries(candles []CandleRecord, node map[string]any) ([]float64, error) {
+	typ, _ := node["type"].(string)
+	switch typ {
+	case "const":
+		value, _ := toFloat(node["value"])
+		out := make([]float64, len(candles))
+		for i := range out {
+			out[i] = value
+		}
+		return out, nil
+	case "price":
+		return priceSeries(candles, sourceOf(node))
+	case "sma":
+		period := int(mustPeriod(node))
+		return smaSeries(priceSeriesOrClose(candles, node), period), nil
+	case "ema":
+		period := int(mustPeriod(node))
+		return emaSeries(priceSeriesOrClose(candles, node), period), nil
+	case "rsi":
+		period := int(mustPeriod(node))
+		return rsiSeries(priceSeriesOrClose(candles, node), period), nil
+	case "atr":
+		period := int(mustPeriod(node))
+		return atrSeries(candles, period), nil
+	default:
+		return nil, fmt.Errorf("strategy rules: unknown indicator type %q", typ)
+	}
+}
+
+func sourceOf(node map[string]any) string {
+	source, _ := node["source"].(string)
+	if source == "" {
+		return "close"
+	}
+	return source
+}
+
+func mustPeriod(node map[string]any) float64 {
+	period, _ := toFloat(node["period"])
+	if period < 1 {
+		return 1
+	}
+	return period
+}
+
+func priceSeriesOrClose(candles []CandleRecord, node map[string]any) []float64 {
+	series, err := priceSeries(candles, sourceOf(node))
+	if err != nil {
+		series, _ = priceSeries(candles, "close")
+	}
+	return series
+}
+
+func priceSeries(candles []CandleRecord, source string) ([]float64, error) {
+	out := make([]float64, len(candles))
+	for i, c := range candles {
+		switch source {
+		case "open":
+			out[i] = c.Open
+		case "high":
+			out[i] = c.High
+		case "low":
+			out[i] = c.Low
+		case "volume":
+			out[i] = c.Volume
+		case "close", "":
+			out[i] = c.Close
+		default:
+			return nil, fmt.Errorf("strategy rules: %q is not a valid price source", source)
+		}
+	}
+	return out, nil
+}
+
+func smaSeries(values []float64, period int) []float64 {
+	out := make([]float64, len(values))
+	sum := 0.0
+	for i, v := range values {
+		sum += v
+		if i >= period {
+			sum -= values[i-period]
+		}
+		if i < period-1 {
+			out[i] = math.NaN()
+			continue
+		}
+		out[i] = sum / float64(period)
+	}
+	return out
+}
+
+func emaSeries(values []float64, period int) []float64 {
+	out := make([]float64, len(values))
+	if len(values) == 0 {
+		return out
+	}
+	multiplier := 2.0 / (float64(period) + 1.0)
+	seed := smaSeries(values, period)
+	for i := range values {
+		if i < period-1 {
+			out[i] = math.NaN()
+			continue
+		}
+		if i == period-1 {
+			out[i] = seed[i]
+			continue
+		}
+		out[i] = (values[i]-out[i-1])*multiplier + out[i-1]
+	}
+	return out
+}
+
+// rsiSeries is Wilder's RSI: average gain/loss smoothed with Wilder's own
+// moving average (a period-weighted EMA), not a plain SMA.
+func rsiSeries(values []float64, period int) []float64 {
+	out := make([]float64, len(values))
+	for i := range out {
+		out[i] = math.NaN()
+	}
+	if len(values) <= period {
+		return out
+	}
+
+	avgGain, avgLoss := 0.0, 0.0
+	for i := 1; i <= period; i++ {
+		change := values[i] - values[i-1]
+		if change > 0 {
+			avgGain += change
+		} else {
+			avgLoss += -change
+		}
+	}
+	avgGain /= float64(period)
+	avgLoss /= float64(period)
+	out[period] = rsiFromAverages(avgGain, avgLoss)
+
+	for i := period + 1; i < len(values); i++ {
+		change := values[i] - values[i-1]
+		gain, loss := 0.0, 0.0
+		if change > 0 {
+			gain = change
+		} else {
+			loss = -change
+		}
+		avgGain = (avgGain*float64(period-1) + gain) / float64(period)
+		avgLoss = (avgLoss*float64(period-1) + loss) / float64(period)
+		out[i] = rsiFromAverages(avgGain, avgLoss)
+	}
+	return out
+}
+
+func rsiFromAverages(avgGain, avgLoss float64) float64 {
+	if avgLoss == 0 {
+		if avgGain == 0 {
+			return 50
+		}
+		return 100
+	}
+	rs := avgGain / avgLoss
+	return 100 - (100 / (1 + rs))
+}
+
+// atrSeries is Wilder's Average True Range, smoothed the same way RSI's
+// averages are.
+func atrSeries(candles []CandleRecord, period int) []float64 {
+	out := make([]float64, len(candles))
+	for i := range out {
+		out[i] = math.NaN()
+	}
+	if len(candles) <= period {
+		return out
+	}
+
+	trueRange := func(i int) float64 {
+		high, low := candles[i].High, candles[i].Low
+		if i == 0 {
+			return high - low
+		}
+		prevClose := candles[i-1].Close
+		return math.Max(high-low, math.Max(math.Abs(high-prevClose), math.Abs(low-prevClose)))
+	}
+
+	sum := 0.0
+	for i := 1; i <= period; i++ {
+		sum += trueRange(i)
+	}
+	atr := sum / float64(period)
+	out[period] = atr
+	for i := period + 1; i < len(candles); i++ {
+		atr = (atr*float64(period-1) + trueRange(i)) / float64(period)
+		out[i] = atr
+	}
+	return out
+}
+
+// evalConditionAt evaluates a condition node at bar index i, given the
+// already-evaluated indicator series referenced by the tree (evalConditionAt
+// re-evaluates indicator nodes per comparator call since Backtester only
+// calls this once per bar per rule document, not per-indicator).
+func evalConditionAt(candles []CandleRecord, node map[string]any, i int) (bool, error) {
+	typ, _ := node["type"].(string)
+	switch typ {
+	case "and":
+		rules, _ := node["rules"].([]any)
+		for _, childRaw := range rules {
+			child, _ := childRaw.(map[string]any)
+			ok, err := evalConditionAt(candles, child, i)
+			if err != nil {
+				return false, err
+			}
+			if !ok {
+				return false, nil
+			}
+		}
+		return true, nil
+	case "or":
+		rules, _ := node["rules"].([]any)
+		for _, childRaw := range rules {
+			child, _ := childRaw.(map[string]any)
+			ok, err := evalConditionAt(candles, child, i)
+			if err != nil {
+				return false, err
+			}
+			if ok {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "not":
+		child, _ := node["rule"].(map[string]any)
+		ok, err := evalConditionAt(candles, child, i)
+		return !ok, err
+	case "gt", "lt", "gte", "lte", "eq", "cross_above", "cross_below":
+		return evalComparatorAt(candles, typ, node, i)
+	default:
+		return false, fmt.Errorf("strategy rules: unknown condition type %q", typ)
+	}
+}
+
+func evalComparatorAt(candles []CandleRecord, typ string, node map[string]any, i int) (bool, error) {
+	left, _ := node["left"].(map[string]any)
+	right, _ := node["right"].(map[string]any)
+	leftSeries, err := indicatorSeries(candles, left)
+	if err != nil {
+		return false, err
+	}
+	rightSeries, err := indicatorSeries(candles, right)
+	if err != nil {
+		return false, err
+	}
+	if i < 0 || i >= len(leftSeries) || i >= len(rightSeries) {
+		return false, nil
+	}
+	l, r := leftSeries[i], rightSeries[i]
+	if math.IsNaN(l) || math.IsNaN(r) {
+		return false, nil
+	}
+
+	switch typ {
+	case "gt":
+		return l > r, nil
+	case "lt":
+		return l < r, nil
+	case "gte":
+		return l >= r, nil
+	case "lte":
+		return l <= r, nil
+	case "eq":
+		return l == r, nil
+	case "cross_above", "cross_below":
+		if i == 0 || math.IsNaN(leftSeries[i-1]) || math.IsNaN(rightSeries[i-1]) {
+			return false, nil
+		}
+		prevL, prevR := leftSeries[i-1], rightSeries[i-1]
+		if typ == "cross_above" {
+			return prevL <= prevR && l > r, nil
+		}
+		return prevL >= prevR && l < r, nil
+	default:
+		return false, fmt.Errorf("strategy rules: unknown comparator type %q", typ)
+	}
+}
+
+func sizePctOf(rules map[string]any) float64 {
+	if size, ok := toFloat(rules["size_pct"]); ok && size > 0 && size <= 100 {
+		return size
+	}
+	return 100
+}
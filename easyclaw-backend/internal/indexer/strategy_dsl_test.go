@@ -0,0 +1,90 @@
+package indexer
+
+import "testing"
+
+func smaCrossStrategy() StrategyRecord {
+	fast := map[string]any{"type": "sma", "period": float64(2)}
+	slow := map[string]any{"type": "sma", "period": float64(4)}
+	return StrategyRecord{
+		ID: "strat-1",
+		EntryRules: map[string]any{
+			"condition": map[string]any{"type": "cross_above", "left": fast, "right": slow},
+			"size_pct":  float64(50),
+		},
+		ExitRules: map[string]any{
+			"condition": map[string]any{"type": "cross_below", "left": fast, "right": slow},
+		},
+	}
+}
+
+func TestValidateStrategyRulesAcceptsWellFormedDocument(t *testing.T) {
+	s := smaCrossStrategy()
+	if err := ValidateStrategyRules(s.EntryRules); err != nil {
+		t.Fatalf("ValidateStrategyRules(entry) = %v, want nil", err)
+	}
+	if err := ValidateStrategyRules(s.ExitRules); err != nil {
+		t.Fatalf("ValidateStrategyRules(exit) = %v, want nil", err)
+	}
+}
+
+func TestValidateStrategyRulesRejectsMalformedDocuments(t *testing.T) {
+	cases := []map[string]any{
+		nil,
+		{},
+		{"condition": "not-an-object"},
+		{"condition": map[string]any{"type": "bogus"}},
+		{"condition": map[string]any{"type": "gt", "left": map[string]any{"type": "sma"}}},
+		{"condition": map[string]any{"type": "gt",
+			"left":  map[string]any{"type": "sma", "period": float64(5)},
+			"right": map[string]any{"type": "const", "value": float64(1)},
+		}, "size_pct": float64(0)},
+		{"condition": map[string]any{"type": "and", "rules": []any{}}},
+	}
+	for i, rules := range cases {
+		if err := ValidateStrategyRules(rules); err == nil {
+			t.Fatalf("case %d: ValidateStrategyRules(%+v) = nil, want error", i, rules)
+		}
+	}
+}
+
+// syntheticVCandles dips, recovers past the fast/slow SMA cross point,
+// then rolls back over - hand-verified so the fast(2) SMA crosses above
+// the slow(4) SMA at index 7 (close=17) and crosses back below at index
+// 13 (close=19).
+func syntheticVCandles() []CandleRecord {
+	prices := []float64{20, 19, 18, 17, 16, 15, 16, 17, 18, 19, 20, 21, 20, 19, 18, 17, 16, 15}
+	out := make([]CandleRecord, len(prices))
+	for i, p := range prices {
+		out[i] = CandleRecord{TS: int64(i) * 60, Open: p, High: p, Low: p, Close: p}
+	}
+	return out
+}
+
+func TestRunBacktestOverCandlesEntersAndExitsOnCrosses(t *testing.T) {
+	candles := syntheticVCandles()
+	result, err := RunBacktestOverCandles(smaCrossStrategy(), candles, 10000)
+	if err != nil {
+		t.Fatalf("RunBacktestOverCandles: %v", err)
+	}
+	if len(result.Trades) == 0 {
+		t.Fatalf("expected at least one synthetic trade from the fast/slow SMA cross")
+	}
+	if len(result.EquityHistory) != len(candles) {
+		t.Fatalf("EquityHistory has %d points, want %d (one per candle)", len(result.EquityHistory), len(candles))
+	}
+	first := result.Trades[0]
+	if first.Side != "long" {
+		t.Fatalf("Trades[0].Side = %q, want %q", first.Side, "long")
+	}
+	if first.EntryPrice != 17 || first.ExitPrice != 19 {
+		t.Fatalf("Trades[0] = entry %v/exit %v, want entry 17/exit 19 (the hand-verified cross points)", first.EntryPrice, first.ExitPrice)
+	}
+}
+
+func TestRunBacktestOverCandlesRejectsInvalidRules(t *testing.T) {
+	strategy := smaCrossStrategy()
+	strategy.EntryRules = map[string]any{"condition": map[string]any{"type": "bogus"}}
+	if _, err := RunBacktestOverCandles(strategy, syntheticVCandles(), 10000); err == nil {
+		t.Fatalf("expected an error for a malformed entry condition")
+	}
+}
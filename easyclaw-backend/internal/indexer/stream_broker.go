@@ -0,0 +1,148 @@
+package indexer
+
+import (
+	"encoding/json"
+	"log/slog"
+	"sync"
+)
+
+// RecordTopic names one of the record streams Broker fans out.
+type RecordTopic string
+
+const (
+	RecordTopicOrders           RecordTopic = "orders"
+	RecordTopicFills            RecordTopic = "fills"
+	RecordTopicPositions        RecordTopic = "positions"
+	RecordTopicPositionHistory  RecordTopic = "position_history"
+	RecordTopicCandles          RecordTopic = "candles"
+	RecordTopicCandleRollups    RecordTopic = "candle_rollups"
+	RecordTopicOrderbookHeatmap RecordTopic = "orderbook_heatmap"
+)
+
+const streamBrokerSubscriberBuffer = 32
+
+// streamEnvelope is the wire format pushed to every stream subscriber.
+type streamEnvelope struct {
+	Topic  RecordTopic     `json:"topic"`
+	Record json.RawMessage `json:"record"`
+}
+
+// newStreamEnvelope marshals record into a topic-tagged envelope, the same
+// wire format Broker.Publish uses for live updates, so a backfilled record
+// and a live one are indistinguishable to the client.
+func newStreamEnvelope(topic RecordTopic, record any) ([]byte, error) {
+	raw, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(streamEnvelope{Topic: topic, Record: raw})
+}
+
+// Broker fans out indexer record mutations (orders, fills, positions,
+// position history) to subscribers as they're written, so a UI or bot can
+// observe changes as they happen instead of polling ListOrders/ListFills on
+// a timer. The ingester calls Publish immediately after the DB transaction
+// that produced the record commits.
+type Broker struct {
+	logger *slog.Logger
+
+	mu     sync.RWMutex
+	topics map[RecordTopic]*streamTopic
+}
+
+func NewBroker(logger *slog.Logger) *Broker {
+	return &Broker{
+		logger: logger,
+		topics: make(map[RecordTopic]*streamTopic),
+	}
+}
+
+type streamTopic struct {
+	mu          sync.Mutex
+	subscribers map[*streamSubscriber]struct{}
+}
+
+// streamSubscriber is one client's live subscription to a topic. Filter, when
+// non-nil, is evaluated against each published record before it's sent, so a
+// subscriber scoped to e.g. one user_margin never sees anyone else's
+// records. Send is owned by the caller (the HTTP handler), not the broker.
+type streamSubscriber struct {
+	filter func(record any) bool
+	send   chan []byte
+}
+
+func (b *Broker) topicFor(topic RecordTopic) *streamTopic {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	t, ok := b.topics[topic]
+	if !ok {
+		t = &streamTopic{subscribers: make(map[*streamSubscriber]struct{})}
+		b.topics[topic] = t
+	}
+	return t
+}
+
+// Subscribe registers a new subscriber on topic and returns it so the caller
+// can later Unsubscribe. filter may be nil to receive every record on the
+// topic.
+func (b *Broker) Subscribe(topic RecordTopic, filter func(record any) bool, send chan []byte) *streamSubscriber {
+	sub := &streamSubscriber{filter: filter, send: send}
+
+	t := b.topicFor(topic)
+	t.mu.Lock()
+	t.subscribers[sub] = struct{}{}
+	t.mu.Unlock()
+
+	return sub
+}
+
+func (b *Broker) Unsubscribe(topic RecordTopic, sub *streamSubscriber) {
+	b.mu.RLock()
+	t, ok := b.topics[topic]
+	b.mu.RUnlock()
+	if !ok {
+		return
+	}
+
+	t.mu.Lock()
+	delete(t.subscribers, sub)
+	t.mu.Unlock()
+}
+
+// Publish fans record out to every topic subscriber whose filter accepts it.
+// Send is non-blocking: a subscriber whose buffer is full is dropped with a
+// warning rather than stalling the publisher.
+func (b *Broker) Publish(topic RecordTopic, record any) {
+	b.mu.RLock()
+	t, ok := b.topics[topic]
+	b.mu.RUnlock()
+	if !ok {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.subscribers) == 0 {
+		return
+	}
+
+	var encoded []byte
+	for sub := range t.subscribers {
+		if sub.filter != nil && !sub.filter(record) {
+			continue
+		}
+		if encoded == nil {
+			payload, err := newStreamEnvelope(topic, record)
+			if err != nil {
+				b.logger.Error("stream broker failed to marshal record", "topic", topic, "err", err)
+				return
+			}
+			encoded = payload
+		}
+		select {
+		case sub.send <- encoded:
+		default:
+			b.logger.Warn("stream broker subscriber too slow, dropping message", "topic", topic)
+		}
+	}
+}
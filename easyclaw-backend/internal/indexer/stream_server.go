@@ -0,0 +1,431 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const streamPingInterval = 20 * time.Second
+
+var streamUpgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+}
+
+// streamBackfillFunc drains every historical record matching a handler's
+// filter starting after sinceCursor (empty meaning "from the start"),
+// emitting each as JSON via emit before the caller switches to live mode.
+type streamBackfillFunc func(ctx context.Context, sinceCursor string, emit func(record any) error) error
+
+// runStreamBroker starts the /v1/stream/* websocket and SSE fan-out
+// endpoints in the background when INDEXER_STREAM_WS_ADDR is configured, so
+// clients can tail order/fill/position/position-history changes instead of
+// polling ListOrders/ListFills/ListPositions/ListPositionHistory. These are
+// served from the indexer process itself rather than api-server, mirroring
+// runOrderbookBroker: s.broker.Publish is called from the same process that
+// commits the records, and api-server only holds a read-only *Store.
+func (s *Service) runStreamBroker(ctx context.Context) {
+	addr := strings.TrimSpace(s.cfg.StreamWebsocketAddr)
+	if s.broker == nil || addr == "" {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/stream/orders", s.handleStreamOrders)
+	mux.HandleFunc("/v1/stream/fills", s.handleStreamFills)
+	mux.HandleFunc("/v1/stream/positions", s.handleStreamPositions)
+	mux.HandleFunc("/v1/stream/position_history", s.handleStreamPositionHistory)
+	mux.HandleFunc("/v1/stream/orderbook_heatmap", s.handleStreamOrderbookHeatmap)
+
+	server := &http.Server{
+		Addr:    addr,
+		Handler: mux,
+	}
+
+	go func() {
+		<-ctx.Done()
+		_ = server.Shutdown(context.Background())
+	}()
+
+	go func() {
+		s.logger.Info("record stream broker started", "listen_addr", addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error("record stream broker exited with error", "err", err)
+		}
+	}()
+}
+
+func (s *Service) handleStreamOrders(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	userMargin := strings.TrimSpace(query.Get("user_margin"))
+	marketID, err := parseOptionalMarketID(query.Get("market_id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	matches := func(record any) bool {
+		order, ok := record.(OrderRecord)
+		return ok && (userMargin == "" || order.UserMargin == userMargin) && (marketID == nil || order.MarketID == *marketID)
+	}
+	backfill := func(ctx context.Context, sinceCursor string, emit func(record any) error) error {
+		cursor := sinceCursor
+		for {
+			items, _, _, nextCursor, err := s.store.ListOrders(ctx, OrderFilter{
+				UserMargin: userMargin, MarketID: marketID, Limit: maxPageLimit, Cursor: cursor,
+			})
+			if err != nil {
+				return err
+			}
+			for _, item := range items {
+				if err := emit(item); err != nil {
+					return err
+				}
+			}
+			if nextCursor == "" {
+				return nil
+			}
+			cursor = nextCursor
+		}
+	}
+
+	s.serveStream(w, r, RecordTopicOrders, matches, backfill)
+}
+
+func (s *Service) handleStreamFills(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	userMargin := strings.TrimSpace(query.Get("user_margin"))
+	marketID, err := parseOptionalMarketID(query.Get("market_id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	matches := func(record any) bool {
+		fill, ok := record.(FillRecord)
+		return ok && (userMargin == "" || fill.UserMargin == userMargin) && (marketID == nil || fill.MarketID == *marketID)
+	}
+	backfill := func(ctx context.Context, sinceCursor string, emit func(record any) error) error {
+		cursor := sinceCursor
+		for {
+			items, _, _, nextCursor, err := s.store.ListFills(ctx, FillFilter{
+				UserMargin: userMargin, MarketID: marketID, Limit: maxPageLimit, Cursor: cursor,
+			})
+			if err != nil {
+				return err
+			}
+			for _, item := range items {
+				if err := emit(item); err != nil {
+					return err
+				}
+			}
+			if nextCursor == "" {
+				return nil
+			}
+			cursor = nextCursor
+		}
+	}
+
+	s.serveStream(w, r, RecordTopicFills, matches, backfill)
+}
+
+func (s *Service) handleStreamPositions(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	userMargin := strings.TrimSpace(query.Get("user_margin"))
+	marketID, err := parseOptionalMarketID(query.Get("market_id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	matches := func(record any) bool {
+		position, ok := record.(PositionRecord)
+		return ok && (userMargin == "" || position.UserMargin == userMargin) && (marketID == nil || position.MarketID == *marketID)
+	}
+	backfill := func(ctx context.Context, sinceCursor string, emit func(record any) error) error {
+		cursor := sinceCursor
+		for {
+			items, _, _, nextCursor, err := s.store.ListPositions(ctx, PositionFilter{
+				UserMargin: userMargin, MarketID: marketID, Limit: maxPageLimit, Cursor: cursor,
+			})
+			if err != nil {
+				return err
+			}
+			for _, item := range items {
+				if err := emit(item); err != nil {
+					return err
+				}
+			}
+			if nextCursor == "" {
+				return nil
+			}
+			cursor = nextCursor
+		}
+	}
+
+	s.serveStream(w, r, RecordTopicPositions, matches, backfill)
+}
+
+func (s *Service) handleStreamPositionHistory(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	userMargin := strings.TrimSpace(query.Get("user_margin"))
+	marketID, err := parseOptionalMarketID(query.Get("market_id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	matches := func(record any) bool {
+		history, ok := record.(PositionHistoryRecord)
+		return ok && (userMargin == "" || history.UserMargin == userMargin) && (marketID == nil || history.MarketID == *marketID)
+	}
+	backfill := func(ctx context.Context, sinceCursor string, emit func(record any) error) error {
+		cursor := sinceCursor
+		for {
+			items, _, _, nextCursor, err := s.store.ListPositionHistory(ctx, PositionHistoryFilter{
+				UserMargin: userMargin, MarketID: marketID, Limit: maxPageLimit, Cursor: cursor,
+			})
+			if err != nil {
+				return err
+			}
+			for _, item := range items {
+				if err := emit(item); err != nil {
+					return err
+				}
+			}
+			if nextCursor == "" {
+				return nil
+			}
+			cursor = nextCursor
+		}
+	}
+
+	s.serveStream(w, r, RecordTopicPositionHistory, matches, backfill)
+}
+
+func (s *Service) handleStreamOrderbookHeatmap(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	exchange := strings.TrimSpace(query.Get("exchange"))
+	symbol := strings.TrimSpace(query.Get("symbol"))
+
+	matches := func(record any) bool {
+		snapshot, ok := record.(OrderbookHeatmapRecord)
+		return ok && (exchange == "" || snapshot.Exchange == exchange) && (symbol == "" || snapshot.Symbol == symbol)
+	}
+	backfill := func(ctx context.Context, sinceCursor string, emit func(record any) error) error {
+		cursor := sinceCursor
+		for {
+			items, _, _, nextCursor, err := s.store.ListOrderbookHeatmap(ctx, OrderbookHeatmapFilter{
+				Exchange: exchange, Symbol: symbol, Limit: maxPageLimit, Cursor: cursor,
+			})
+			if err != nil {
+				return err
+			}
+			for _, item := range items {
+				if err := emit(item); err != nil {
+					return err
+				}
+			}
+			if nextCursor == "" {
+				return nil
+			}
+			cursor = nextCursor
+		}
+	}
+
+	s.serveStream(w, r, RecordTopicOrderbookHeatmap, matches, backfill)
+}
+
+func parseOptionalMarketID(raw string) (*uint64, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+	value, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid market_id: %w", err)
+	}
+	return &value, nil
+}
+
+// serveStream upgrades to a websocket, or falls back to Server-Sent Events
+// for a plain GET, and in either case first drains the backfill (resuming
+// from the since_cursor query param, if present) before switching to a live
+// tail off the broker, so a client never sees a gap between the historical
+// snapshot and the start of live updates.
+func (s *Service) serveStream(w http.ResponseWriter, r *http.Request, topic RecordTopic, matches func(record any) bool, backfill streamBackfillFunc) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sinceCursor := strings.TrimSpace(r.URL.Query().Get("since_cursor"))
+
+	if websocket.IsWebSocketUpgrade(r) {
+		s.serveStreamWS(w, r, topic, matches, backfill, sinceCursor)
+		return
+	}
+	s.serveStreamSSE(w, r, topic, matches, backfill, sinceCursor)
+}
+
+func (s *Service) serveStreamWS(
+	w http.ResponseWriter,
+	r *http.Request,
+	topic RecordTopic,
+	matches func(record any) bool,
+	backfill streamBackfillFunc,
+	sinceCursor string,
+) {
+	conn, err := streamUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		s.logger.Error("stream websocket upgrade failed", "topic", topic, "err", err)
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	writeRecord := func(record any) error {
+		envelope, err := newStreamEnvelope(topic, record)
+		if err != nil {
+			return err
+		}
+		if err := conn.SetWriteDeadline(time.Now().Add(websocketWriteTimeout)); err != nil {
+			return err
+		}
+		return conn.WriteMessage(websocket.TextMessage, envelope)
+	}
+
+	if err := backfill(ctx, sinceCursor, writeRecord); err != nil {
+		s.logger.Error("stream backfill failed", "topic", topic, "err", err)
+		return
+	}
+
+	send := make(chan []byte, streamBrokerSubscriberBuffer)
+	sub := s.broker.Subscribe(topic, matches, send)
+	defer s.broker.Unsubscribe(topic, sub)
+
+	readErrCh := make(chan error, 1)
+	go streamReadLoop(ctx, conn, readErrCh)
+
+	ticker := time.NewTicker(streamPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case err := <-readErrCh:
+			if err != nil {
+				s.logger.Debug("stream read loop ended", "topic", topic, "err", err)
+			}
+			return
+		case payload := <-send:
+			if err := conn.SetWriteDeadline(time.Now().Add(websocketWriteTimeout)); err != nil {
+				return
+			}
+			if err := conn.WriteMessage(websocket.TextMessage, payload); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := conn.SetWriteDeadline(time.Now().Add(websocketWriteTimeout)); err != nil {
+				return
+			}
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// streamReadLoop just drains and discards incoming frames so the connection
+// can detect a client-initiated close; record streams are server-push only,
+// unlike the orderbook broker's subscribe/unsubscribe protocol.
+func streamReadLoop(ctx context.Context, conn *websocket.Conn, readErrCh chan<- error) {
+	conn.SetReadLimit(websocketReadLimitBytes)
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			select {
+			case <-ctx.Done():
+				readErrCh <- nil
+			default:
+				readErrCh <- err
+			}
+			return
+		}
+	}
+}
+
+func (s *Service) serveStreamSSE(
+	w http.ResponseWriter,
+	r *http.Request,
+	topic RecordTopic,
+	matches func(record any) bool,
+	backfill streamBackfillFunc,
+	sinceCursor string,
+) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	ctx := r.Context()
+
+	writeRecord := func(record any) error {
+		envelope, err := newStreamEnvelope(topic, record)
+		if err != nil {
+			return err
+		}
+		return writeSSEEvent(w, flusher, envelope)
+	}
+
+	if err := backfill(ctx, sinceCursor, writeRecord); err != nil {
+		s.logger.Error("stream backfill failed", "topic", topic, "err", err)
+		return
+	}
+
+	send := make(chan []byte, streamBrokerSubscriberBuffer)
+	sub := s.broker.Subscribe(topic, matches, send)
+	defer s.broker.Unsubscribe(topic, sub)
+
+	ticker := time.NewTicker(streamPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case payload := <-send:
+			if err := writeSSEEvent(w, flusher, payload); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func writeSSEEvent(w http.ResponseWriter, flusher http.Flusher, payload []byte) error {
+	if _, err := fmt.Fprintf(w, "data: %s\n\n", payload); err != nil {
+		return err
+	}
+	flusher.Flush()
+	return nil
+}
@@ -0,0 +1,261 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"math"
+)
+
+// TrailingStopParams configures a SimulateTrailingStops replay. Activation
+// and callback are paired by index and must be given in ascending
+// activation order (tier 0 arms first, at the smallest favorable move),
+// matching the tiered drift-style trailing stop configuration used
+// elsewhere in the platform: once price has moved ActivationRatios[k] away
+// from entry, CallbackRates[k] becomes the retracement that closes the
+// position, and a later tier re-arms with a wider (usually tighter)
+// callback as price keeps moving further in the trade's favor.
+type TrailingStopParams struct {
+	AgentID          string
+	FromUnix         int64
+	ToUnix           int64
+	ActivationRatios []float64
+	CallbackRates    []float64
+}
+
+// TrailingSimulationResult is what a single closed TradeRecord would have
+// done under TrailingStopParams instead of its actual exit. ActivatedTier
+// is -1 when the trade's price never moved far enough to arm any tier, in
+// which case the hypothetical exit falls back to the trade's real exit.
+type TrailingSimulationResult struct {
+	TradeID               string  `json:"trade_id"`
+	AgentID               string  `json:"agent_id"`
+	MarketID              uint64  `json:"market_id"`
+	Side                  string  `json:"side"`
+	EntryPrice            float64 `json:"entry_price"`
+	ActualExitPrice       float64 `json:"actual_exit_price"`
+	ActualPnl             float64 `json:"actual_pnl"`
+	HypotheticalExitTime  int64   `json:"hypothetical_exit_time"`
+	HypotheticalExitPrice float64 `json:"hypothetical_exit_price"`
+	HypotheticalPnl       float64 `json:"hypothetical_pnl"`
+	ActivatedTier         int     `json:"activated_tier"`
+	TicksProcessed        int     `json:"ticks_processed"`
+}
+
+// TrailingSimulationSummary aggregates a SimulateTrailingStops run across
+// every replayed trade.
+type TrailingSimulationSummary struct {
+	Results      []TrailingSimulationResult `json:"results"`
+	AvgPnlUplift float64                    `json:"avg_pnl_uplift"`
+	TierHitRates []float64                  `json:"tier_hit_rates"`
+}
+
+// SimulateTrailingStops replays every closed trade computeTradesAndLots
+// produces for params.AgentID within [FromUnix, ToUnix] tick-by-tick
+// against market_price_ticks, as if each position had been managed by the
+// tiered trailing stop in params instead of whatever actually closed it.
+// It does not touch ListTrades/GetAgentPortfolioSummary - it's a read-only
+// what-if report a caller runs alongside them, not a toggle on their
+// output, since both of those already have a fixed signature used by
+// existing handlers and callers.
+func (s *Store) SimulateTrailingStops(ctx context.Context, params TrailingStopParams) (TrailingSimulationSummary, error) {
+	if len(params.ActivationRatios) == 0 || len(params.ActivationRatios) != len(params.CallbackRates) {
+		return TrailingSimulationSummary{}, fmt.Errorf("trailing stop: activation_ratios and callback_rates must be equal-length and non-empty")
+	}
+	for i := 1; i < len(params.ActivationRatios); i++ {
+		if params.ActivationRatios[i] < params.ActivationRatios[i-1] {
+			return TrailingSimulationSummary{}, fmt.Errorf("trailing stop: activation_ratios must be ascending")
+		}
+	}
+
+	events, err := s.loadTradeEvents(ctx, params.AgentID, params.FromUnix, params.ToUnix)
+	if err != nil {
+		return TrailingSimulationSummary{}, err
+	}
+	modes, err := s.agentAccountingModes(ctx)
+	if err != nil {
+		return TrailingSimulationSummary{}, err
+	}
+	feeModel, err := s.loadFeeModel(ctx, params.ToUnix)
+	if err != nil {
+		return TrailingSimulationSummary{}, err
+	}
+	trades, _ := computeTradesAndLots(events, func(agentID string) AccountingMode {
+		if mode, ok := modes[agentID]; ok {
+			return mode
+		}
+		return AccountingWeightedAverage
+	}, feeModel)
+
+	marketSymbols, err := s.loadExecutionPriceMarketSymbols(ctx)
+	if err != nil {
+		return TrailingSimulationSummary{}, err
+	}
+
+	tierHits := make([]int, len(params.ActivationRatios))
+	results := make([]TrailingSimulationResult, 0, len(trades))
+	var pnlUpliftTotal float64
+	for _, trade := range trades {
+		if trade.ExitTime == 0 {
+			continue
+		}
+		result, err := s.simulateTrailingStopForTrade(ctx, trade, marketSymbols[trade.MarketID], params)
+		if err != nil {
+			return TrailingSimulationSummary{}, err
+		}
+		if result.ActivatedTier >= 0 {
+			tierHits[result.ActivatedTier]++
+		}
+		pnlUpliftTotal += result.HypotheticalPnl - result.ActualPnl
+		results = append(results, result)
+	}
+
+	tierHitRates := make([]float64, len(params.ActivationRatios))
+	if len(results) > 0 {
+		for i, hits := range tierHits {
+			tierHitRates[i] = float64(hits) / float64(len(results))
+		}
+	}
+	avgUplift := 0.0
+	if len(results) > 0 {
+		avgUplift = pnlUpliftTotal / float64(len(results))
+	}
+
+	return TrailingSimulationSummary{
+		Results:      results,
+		AvgPnlUplift: avgUplift,
+		TierHitRates: tierHitRates,
+	}, nil
+}
+
+// simulateTrailingStopForTrade walks trade's market_price_ticks between its
+// entry and exit, arming the highest tier whose ActivationRatios threshold
+// the running favorable extreme has cleared and closing the position the
+// first time price retraces from that extreme by the armed tier's
+// CallbackRates. The walk short-circuits the moment a tier's callback
+// fires - later ticks can't change an already-decided exit.
+func (s *Store) simulateTrailingStopForTrade(ctx context.Context, trade TradeRecord, marketSymbol string, params TrailingStopParams) (TrailingSimulationResult, error) {
+	isLong := trade.Side == "long"
+	result := TrailingSimulationResult{
+		TradeID:               trade.ID,
+		AgentID:               trade.AgentID,
+		MarketID:              trade.MarketID,
+		Side:                  trade.Side,
+		EntryPrice:            trade.EntryPrice,
+		ActualExitPrice:       trade.ExitPrice,
+		ActualPnl:             trade.Pnl,
+		HypotheticalExitTime:  trade.ExitTime,
+		HypotheticalExitPrice: trade.ExitPrice,
+		HypotheticalPnl:       trade.Pnl,
+		ActivatedTier:         -1,
+	}
+	if trade.EntryPrice <= 0 || marketSymbol == "" {
+		return result, nil
+	}
+
+	ticks, err := s.loadPriceTicksBetween(ctx, marketSymbol, trade.EntryTime, trade.ExitTime)
+	if err != nil {
+		return TrailingSimulationResult{}, err
+	}
+	if len(ticks) == 0 {
+		// Tick coverage is too sparse to replay - fall back to the same
+		// execution-price resolution ListTrades uses for market orders
+		// rather than reporting a replay that never processed anything.
+		price, err := s.lookupExecutionPrice(ctx, marketSymbol, trade.ExitTime)
+		if err == nil && price > 0 {
+			result.HypotheticalExitPrice = price
+			result.HypotheticalPnl = tradePnl(trade, price)
+		}
+		return result, nil
+	}
+
+	extreme := trade.EntryPrice
+	armedTier := -1
+	for _, tick := range ticks {
+		result.TicksProcessed++
+
+		if isLong {
+			if tick.Price > extreme {
+				extreme = tick.Price
+			}
+		} else if tick.Price < extreme {
+			extreme = tick.Price
+		}
+
+		for k, ratio := range params.ActivationRatios {
+			moveRatio := math.Abs(extreme-trade.EntryPrice) / trade.EntryPrice
+			if moveRatio >= ratio {
+				armedTier = k
+			}
+		}
+
+		if armedTier < 0 {
+			continue
+		}
+		callback := params.CallbackRates[armedTier]
+		var retrace float64
+		if isLong {
+			retrace = (extreme - tick.Price) / extreme
+		} else {
+			retrace = (tick.Price - extreme) / extreme
+		}
+		if retrace >= callback {
+			result.ActivatedTier = armedTier
+			result.HypotheticalExitTime = tick.PublishTime
+			result.HypotheticalExitPrice = tick.Price
+			result.HypotheticalPnl = tradePnl(trade, tick.Price)
+			return result, nil
+		}
+	}
+
+	return result, nil
+}
+
+// tradePnl re-derives what trade.Pnl would be had it closed at exitPrice
+// instead of trade.ExitPrice, scaling the fee proportionally the same way
+// trade.Pnl already nets it out so a hypothetical exit stays comparable to
+// the actual one.
+func tradePnl(trade TradeRecord, exitPrice float64) float64 {
+	sign := 1.0
+	if trade.Side != "long" {
+		sign = -1.0
+	}
+	return (exitPrice-trade.EntryPrice)*trade.Qty*sign - trade.Fee
+}
+
+type priceTick struct {
+	PublishTime int64
+	Price       float64
+}
+
+// loadPriceTicksBetween returns every market_price_ticks row for market
+// between fromUnix and toUnix inclusive, oldest first, for a trailing stop
+// replay to walk in order.
+func (s *Store) loadPriceTicksBetween(ctx context.Context, market string, fromUnix, toUnix int64) ([]priceTick, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT publish_time, price
+		 FROM market_price_ticks
+		 WHERE market = ? AND publish_time >= ? AND publish_time <= ?
+		 ORDER BY publish_time ASC, id ASC`,
+		market,
+		fromUnix,
+		toUnix,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ticks := make([]priceTick, 0, 64)
+	for rows.Next() {
+		var tick priceTick
+		if err := rows.Scan(&tick.PublishTime, &tick.Price); err != nil {
+			return nil, err
+		}
+		ticks = append(ticks, tick)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return ticks, nil
+}
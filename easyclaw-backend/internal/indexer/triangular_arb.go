@@ -0,0 +1,466 @@
+package indexer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// knownQuoteAssets mirrors normalizeOrderbookSymbolKey's suffix list: the
+// quote asset of a concatenated symbol like "ETHBTC" can only be
+// recovered by matching against assets known to trail a base asset, not
+// by parsing the string in isolation.
+var knownQuoteAssets = []string{"USDT", "USDC", "BUSD", "USD", "BTC", "ETH", "BNB", "SOL", "EUR"}
+
+// TriangularArbConfig describes the paths DetectTriangularArbitrage scans
+// and the assumptions it prices a round trip under. A path is a cycle of
+// symbols on the same exchange, e.g. []string{"BTCUSDT", "ETHBTC",
+// "ETHUSDT"}: starting from BTCUSDT's quote asset, each leg's base/quote
+// must chain into the next until the cycle returns to the starting
+// asset.
+type TriangularArbConfig struct {
+	Paths              [][]string
+	MinSpreadRatio     float64
+	TakerFeeByExchange map[string]float64
+	TargetNotional     float64
+}
+
+// TriangularArbLeg is one pair traded within a detected opportunity.
+type TriangularArbLeg struct {
+	Symbol      string  `json:"symbol"`
+	Side        string  `json:"side"`
+	Price       float64 `json:"price"`
+	Qty         float64 `json:"qty"`
+	SlippageBps float64 `json:"slippage_bps"`
+}
+
+// TriangularArbOpportunity is one detected and persisted round trip.
+type TriangularArbOpportunity struct {
+	ID           int64              `json:"id"`
+	Exchange     string             `json:"exchange"`
+	Path         []string           `json:"path"`
+	SnapshotTime int64              `json:"snapshot_time"`
+	Ratio        float64            `json:"ratio"`
+	Size         float64            `json:"size"`
+	Legs         []TriangularArbLeg `json:"legs"`
+	DetectedAt   int64              `json:"detected_at"`
+}
+
+type orderbookTopOfBook struct {
+	bestBid    float64
+	bestAsk    float64
+	levelsJSON string
+}
+
+// DetectTriangularArbitrage walks each configured path over every
+// snapshot_time bucket (on exchange) where all three legs have a
+// snapshot, computing the round-trip ratio a trader would realize
+// starting from one unit of the path's home asset. Detected
+// opportunities clearing MinSpreadRatio, net of
+// TakerFeeByExchange[exchange] charged per leg, are persisted to
+// triangular_arb_opportunities so a heatmap can query historical
+// frequency/size without re-scanning snapshots.
+func (s *Store) DetectTriangularArbitrage(ctx context.Context, exchange string, config TriangularArbConfig, fromUnix, toUnix int64) ([]TriangularArbOpportunity, error) {
+	feeRate := config.TakerFeeByExchange[exchange]
+
+	var opportunities []TriangularArbOpportunity
+	for _, path := range config.Paths {
+		if len(path) < 3 {
+			continue
+		}
+
+		byTime, err := s.loadTopOfBookByTime(ctx, exchange, path, fromUnix, toUnix)
+		if err != nil {
+			return nil, err
+		}
+
+		snapshotTimes := make([]int64, 0, len(byTime))
+		for snapshotTime := range byTime {
+			snapshotTimes = append(snapshotTimes, snapshotTime)
+		}
+		sort.Slice(snapshotTimes, func(i, j int) bool { return snapshotTimes[i] < snapshotTimes[j] })
+
+		for _, snapshotTime := range snapshotTimes {
+			bySymbol := byTime[snapshotTime]
+			if len(bySymbol) < len(path) {
+				continue
+			}
+
+			legs, ratio, size, ok := walkTriangularPath(path, bySymbol, config.TargetNotional, feeRate)
+			if !ok {
+				continue
+			}
+			if config.MinSpreadRatio > 0 && ratio < config.MinSpreadRatio {
+				continue
+			}
+
+			opportunities = append(opportunities, TriangularArbOpportunity{
+				Exchange:     exchange,
+				Path:         path,
+				SnapshotTime: snapshotTime,
+				Ratio:        ratio,
+				Size:         size,
+				Legs:         legs,
+			})
+		}
+	}
+
+	if len(opportunities) == 0 {
+		return nil, nil
+	}
+
+	now := nowUnix()
+	for i := range opportunities {
+		opportunities[i].DetectedAt = now
+		id, err := s.saveTriangularArbOpportunity(ctx, opportunities[i])
+		if err != nil {
+			return nil, err
+		}
+		opportunities[i].ID = id
+	}
+
+	return opportunities, nil
+}
+
+func (s *Store) loadTopOfBookByTime(ctx context.Context, exchange string, path []string, fromUnix, toUnix int64) (map[int64]map[string]orderbookTopOfBook, error) {
+	clauses := []string{"exchange = ?"}
+	args := make([]any, 0, len(path)+3)
+	args = append(args, exchange)
+
+	symbolPlaceholders := make([]string, 0, len(path))
+	for _, symbol := range path {
+		symbolPlaceholders = append(symbolPlaceholders, "?")
+		args = append(args, symbol)
+	}
+	clauses = append(clauses, fmt.Sprintf("symbol IN (%s)", strings.Join(symbolPlaceholders, ", ")))
+
+	if fromUnix > 0 {
+		clauses = append(clauses, "snapshot_time >= ?")
+		args = append(args, fromUnix)
+	}
+	if toUnix > 0 {
+		clauses = append(clauses, "snapshot_time <= ?")
+		args = append(args, toUnix)
+	}
+
+	rows, err := s.db.QueryContext(
+		ctx,
+		`
+		SELECT symbol, snapshot_time, best_bid, best_ask, levels_json
+		FROM exchange_orderbook_snapshots
+		WHERE `+strings.Join(clauses, " AND ")+`
+		ORDER BY snapshot_time ASC
+		`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byTime := make(map[int64]map[string]orderbookTopOfBook, 64)
+	for rows.Next() {
+		var symbol string
+		var snapshotTime int64
+		var bestBidRaw, bestAskRaw, levelsJSON string
+		if err := rows.Scan(&symbol, &snapshotTime, &bestBidRaw, &bestAskRaw, &levelsJSON); err != nil {
+			return nil, err
+		}
+		if byTime[snapshotTime] == nil {
+			byTime[snapshotTime] = make(map[string]orderbookTopOfBook, len(path))
+		}
+		byTime[snapshotTime][symbol] = orderbookTopOfBook{
+			bestBid:    parsePriceOrZero(bestBidRaw),
+			bestAsk:    parsePriceOrZero(bestAskRaw),
+			levelsJSON: levelsJSON,
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return byTime, nil
+}
+
+// walkTriangularPath prices one round trip through path starting from
+// one unit of the home asset (path[0]'s quote asset). size is the
+// executable amount of the home asset: top-of-book quantity alone when
+// targetNotional is 0, or the smallest amount any leg's book depth can
+// absorb of targetNotional otherwise, with per-leg slippage measured
+// against that leg's top-of-book price.
+func walkTriangularPath(path []string, bySymbol map[string]orderbookTopOfBook, targetNotional, feeRate float64) ([]TriangularArbLeg, float64, float64, bool) {
+	homeBase, homeQuote, ok := splitBaseQuote(path[0])
+	if !ok {
+		return nil, 0, 0, false
+	}
+	_ = homeBase
+	carry := homeQuote
+
+	startAmount := targetNotional
+	if startAmount <= 0 {
+		startAmount = 1
+	}
+	amount := startAmount
+
+	legs := make([]TriangularArbLeg, 0, len(path))
+	executableSize := startAmount
+
+	for _, symbol := range path {
+		book, ok := bySymbol[symbol]
+		if !ok {
+			return nil, 0, 0, false
+		}
+		base, quote, ok := splitBaseQuote(symbol)
+		if !ok {
+			return nil, 0, 0, false
+		}
+
+		var side string
+		var price float64
+		var nextAmount float64
+		var legQty float64
+		switch carry {
+		case quote:
+			side = "buy"
+			price = book.bestAsk
+			if price <= 0 {
+				return nil, 0, 0, false
+			}
+			legQty = amount / price
+			nextAmount = legQty
+			carry = base
+		case base:
+			side = "sell"
+			price = book.bestBid
+			if price <= 0 {
+				return nil, 0, 0, false
+			}
+			legQty = amount
+			nextAmount = amount * price
+			carry = quote
+		default:
+			return nil, 0, 0, false
+		}
+
+		avgPrice, filledQty := walkOrderbookDepth(book.levelsJSON, side, legQty)
+		slippageBps := 0.0
+		if targetNotional > 0 && avgPrice > 0 {
+			slippageBps = (avgPrice - price) / price * 10000
+			if side == "buy" {
+				slippageBps = -slippageBps
+			}
+			if filledQty < legQty {
+				executableSize = math.Min(executableSize, executableSizeFromFill(startAmount, amount, filledQty, legQty))
+			}
+		}
+		if feeRate > 0 {
+			nextAmount *= 1 - feeRate
+		}
+
+		legs = append(legs, TriangularArbLeg{
+			Symbol:      symbol,
+			Side:        side,
+			Price:       price,
+			Qty:         legQty,
+			SlippageBps: slippageBps,
+		})
+
+		amount = nextAmount
+	}
+
+	if carry != homeQuote {
+		return nil, 0, 0, false
+	}
+
+	ratio := amount / startAmount
+	return legs, ratio, executableSize, true
+}
+
+// executableSizeFromFill scales startAmount down proportionally to how
+// much of a leg's requested quantity its book depth could actually
+// absorb, so the reported Size reflects the shallowest leg in the path
+// rather than the requested target notional.
+func executableSizeFromFill(startAmount, legAmount, filledQty, requestedQty float64) float64 {
+	if requestedQty <= 0 {
+		return startAmount
+	}
+	return startAmount * (filledQty / requestedQty)
+}
+
+// walkOrderbookDepth consumes levelsJSON's bid or ask ladder (whichever
+// side matches a "sell"/"buy" leg) up to qty, returning the size-weighted
+// average fill price and the quantity actually filled. Falls back to
+// (0, 0) on any decode error so callers treat it as "no depth data" and
+// skip slippage accounting rather than failing the whole leg.
+func walkOrderbookDepth(levelsJSON string, side string, qty float64) (float64, float64) {
+	if strings.TrimSpace(levelsJSON) == "" {
+		return 0, 0
+	}
+	var levels []OrderbookHeatmapLevel
+	if err := json.Unmarshal([]byte(levelsJSON), &levels); err != nil {
+		return 0, 0
+	}
+
+	wantSide := orderbookSideAsk
+	if side == "sell" {
+		wantSide = orderbookSideBid
+	}
+
+	type priced struct {
+		price float64
+		qty   float64
+	}
+	matching := make([]priced, 0, len(levels))
+	for _, level := range levels {
+		if strings.ToLower(strings.TrimSpace(level.Side)) != wantSide {
+			continue
+		}
+		price := parsePriceOrZero(level.Price)
+		levelQty := parsePriceOrZero(level.Quantity)
+		if price <= 0 || levelQty <= 0 {
+			continue
+		}
+		matching = append(matching, priced{price: price, qty: levelQty})
+	}
+	if len(matching) == 0 {
+		return 0, 0
+	}
+
+	if wantSide == orderbookSideAsk {
+		sort.Slice(matching, func(i, j int) bool { return matching[i].price < matching[j].price })
+	} else {
+		sort.Slice(matching, func(i, j int) bool { return matching[i].price > matching[j].price })
+	}
+
+	remaining := qty
+	notional := 0.0
+	filled := 0.0
+	for _, level := range matching {
+		if remaining <= 0 {
+			break
+		}
+		take := math.Min(level.qty, remaining)
+		notional += take * level.price
+		filled += take
+		remaining -= take
+	}
+	if filled == 0 {
+		return 0, 0
+	}
+	return notional / filled, filled
+}
+
+// splitBaseQuote recovers a symbol's base/quote pair by matching a known
+// quote-asset suffix, the same approach normalizeOrderbookSymbolKey uses
+// to strip a quote suffix when matching a SymbolKey.
+func splitBaseQuote(symbol string) (base, quote string, ok bool) {
+	clean := strings.ToUpper(strings.TrimSpace(symbol))
+	for _, candidate := range knownQuoteAssets {
+		if strings.HasSuffix(clean, candidate) && len(clean) > len(candidate) {
+			return clean[:len(clean)-len(candidate)], candidate, true
+		}
+	}
+	return "", "", false
+}
+
+func (s *Store) saveTriangularArbOpportunity(ctx context.Context, opportunity TriangularArbOpportunity) (int64, error) {
+	pathJSON, err := json.Marshal(opportunity.Path)
+	if err != nil {
+		return 0, err
+	}
+	legsJSON, err := json.Marshal(opportunity.Legs)
+	if err != nil {
+		return 0, err
+	}
+
+	var id int64
+	row := s.db.QueryRowContext(
+		ctx,
+		`
+		INSERT INTO triangular_arb_opportunities (
+			exchange, path_json, snapshot_time, ratio, size, legs_json, detected_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+		`,
+		opportunity.Exchange,
+		string(pathJSON),
+		opportunity.SnapshotTime,
+		opportunity.Ratio,
+		opportunity.Size,
+		string(legsJSON),
+		opportunity.DetectedAt,
+	)
+	if err := row.Scan(&id); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// TriangularArbFilter selects previously detected and persisted
+// opportunities for a heatmap-style query.
+type TriangularArbFilter struct {
+	Exchange string
+	FromUnix int64
+	ToUnix   int64
+	Limit    int
+	Offset   int
+}
+
+// ListTriangularArbOpportunities returns previously detected
+// opportunities, most recent first.
+func (s *Store) ListTriangularArbOpportunities(ctx context.Context, filter TriangularArbFilter) ([]TriangularArbOpportunity, int, int, error) {
+	limit, offset := normalizePagination(filter.Limit, filter.Offset)
+
+	clauses := []string{"1 = 1"}
+	args := make([]any, 0, 4)
+	if filter.Exchange != "" {
+		clauses = append(clauses, "exchange = ?")
+		args = append(args, filter.Exchange)
+	}
+	if filter.FromUnix > 0 {
+		clauses = append(clauses, "snapshot_time >= ?")
+		args = append(args, filter.FromUnix)
+	}
+	if filter.ToUnix > 0 {
+		clauses = append(clauses, "snapshot_time <= ?")
+		args = append(args, filter.ToUnix)
+	}
+
+	rows, err := s.db.QueryContext(
+		ctx,
+		`
+		SELECT id, exchange, path_json, snapshot_time, ratio, size, legs_json, detected_at
+		FROM triangular_arb_opportunities
+		WHERE `+strings.Join(clauses, " AND ")+`
+		ORDER BY snapshot_time DESC
+		LIMIT ? OFFSET ?
+		`,
+		append(append(args, limit), offset)...,
+	)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	defer rows.Close()
+
+	opportunities := make([]TriangularArbOpportunity, 0, limit)
+	for rows.Next() {
+		var item TriangularArbOpportunity
+		var pathJSON, legsJSON string
+		if err := rows.Scan(&item.ID, &item.Exchange, &pathJSON, &item.SnapshotTime, &item.Ratio, &item.Size, &legsJSON, &item.DetectedAt); err != nil {
+			return nil, 0, 0, err
+		}
+		if err := json.Unmarshal([]byte(pathJSON), &item.Path); err != nil {
+			return nil, 0, 0, fmt.Errorf("decode path_json for opportunity %d: %w", item.ID, err)
+		}
+		if err := json.Unmarshal([]byte(legsJSON), &item.Legs); err != nil {
+			return nil, 0, 0, fmt.Errorf("decode legs_json for opportunity %d: %w", item.ID, err)
+		}
+		opportunities = append(opportunities, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, 0, err
+	}
+	return opportunities, limit, offset, nil
+}
@@ -0,0 +1,94 @@
+package keeper
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestComputeImpactBpsMonotonicInNotional(t *testing.T) {
+	const oi = uint64(1_000_000)
+	const baseSpreadBps = uint64(10)
+	const skewCoeffBps = uint64(5_000)
+
+	var prev uint64
+	for i, skew := range []int64{0, 10_000, 50_000, 100_000, 250_000, 500_000} {
+		impact, err := computeImpactBps(big.NewInt(skew), oi, baseSpreadBps, skewCoeffBps)
+		if err != nil {
+			t.Fatalf("computeImpactBps(%d): %v", skew, err)
+		}
+		if i > 0 && impact < prev {
+			t.Fatalf("impactBps decreased from %d to %d as |skew| grew to %d", prev, impact, skew)
+		}
+		prev = impact
+	}
+}
+
+func TestComputeImpactBpsSignIndependent(t *testing.T) {
+	const oi = uint64(1_000_000)
+	const baseSpreadBps = uint64(10)
+	const skewCoeffBps = uint64(5_000)
+
+	positive, err := computeImpactBps(big.NewInt(123_456), oi, baseSpreadBps, skewCoeffBps)
+	if err != nil {
+		t.Fatalf("computeImpactBps(+): %v", err)
+	}
+	negative, err := computeImpactBps(big.NewInt(-123_456), oi, baseSpreadBps, skewCoeffBps)
+	if err != nil {
+		t.Fatalf("computeImpactBps(-): %v", err)
+	}
+	if positive != negative {
+		t.Fatalf("impactBps should depend on |skew| only: +skew=%d -skew=%d", positive, negative)
+	}
+}
+
+func TestSolveFixedPointConvergesWithinEpsilon(t *testing.T) {
+	// evaluate models a decreasing f(fill): impact shrinks as fill rises
+	// (a larger candidate price implies a smaller base size for the same
+	// quote notional, per projectFill), so f(fill) = oracle + k/fill for
+	// some constant k has exactly the shape solveFixedPoint assumes.
+	const oracle = uint64(1_000_000)
+	const k = uint64(5_000_000_000)
+	evaluate := func(fill uint64) (uint64, error) {
+		return oracle + k/fill, nil
+	}
+
+	const epsilon = uint64(1)
+	fill, err := solveFixedPoint(oracle, oracle, oracle*2, epsilon, evaluate)
+	if err != nil {
+		t.Fatalf("solveFixedPoint: %v", err)
+	}
+
+	target, err := evaluate(fill)
+	if err != nil {
+		t.Fatalf("evaluate(%d): %v", fill, err)
+	}
+	if absDiffUint64(target, fill) > epsilon {
+		t.Fatalf("|f(fill) - fill| = %d, want <= %d (fill=%d, f(fill)=%d)", absDiffUint64(target, fill), epsilon, fill, target)
+	}
+}
+
+func TestSolveFixedPointReturnsErrFillNotConvergedWhenUnbracketed(t *testing.T) {
+	// evaluate always returns a constant far outside [lo, hi], so
+	// g(fill) = evaluate(fill) - fill never crosses zero inside the
+	// bracket and no candidate ever lands within epsilon of it.
+	const lo, hi = uint64(100), uint64(200)
+	evaluate := func(uint64) (uint64, error) { return hi + 1_000, nil }
+
+	_, err := solveFixedPoint(lo, lo, hi, 1, evaluate)
+	if !errors.Is(err, errFillNotConverged) {
+		t.Fatalf("expected errFillNotConverged, got %v", err)
+	}
+}
+
+func TestAbsDiffUint64(t *testing.T) {
+	if got := absDiffUint64(5, 3); got != 2 {
+		t.Fatalf("absDiffUint64(5, 3) = %d, want 2", got)
+	}
+	if got := absDiffUint64(3, 5); got != 2 {
+		t.Fatalf("absDiffUint64(3, 5) = %d, want 2", got)
+	}
+	if got := absDiffUint64(7, 7); got != 0 {
+		t.Fatalf("absDiffUint64(7, 7) = %d, want 0", got)
+	}
+}
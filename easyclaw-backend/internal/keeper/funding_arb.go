@@ -0,0 +1,198 @@
+package keeper
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+
+	orderengine "github.com/coldbell/dex/backend/internal/anchor/order_engine"
+	"github.com/coldbell/dex/backend/internal/config"
+	"github.com/coldbell/dex/backend/internal/dex"
+)
+
+// CEXClient is the external spot/futures venue leg a FundingArbKeeper
+// drives, kept narrow enough that a test double can stand in for it -
+// modeled on how xfunding's cross-exchange strategy hides its CEX side
+// behind a bbgo Session. This package has no real Binance-style client of
+// its own; a binary wiring up a FundingArbKeeper supplies one.
+type CEXClient interface {
+	// OpenSpotLong increases the spot long leg for symbol by quoteQuantity,
+	// denominated in the quote asset (e.g. USDT).
+	OpenSpotLong(ctx context.Context, symbol string, quoteQuantity float64) error
+	// CloseSpotLong reduces the spot long leg for symbol by quoteQuantity.
+	CloseSpotLong(ctx context.Context, symbol string, quoteQuantity float64) error
+}
+
+// FundingArbKeeper pairs a perpetual short on this engine's market against
+// a spot long on an external CEX to harvest funding: when the on-chain
+// funding rate runs hot (longs paying shorts above ShortFundingRateHigh),
+// it scales into both legs in IncrementalQuoteQuantity slices up to
+// QuoteInvestment; once the rate drops back below ShortFundingRateLow (or
+// flips), it unwinds both.
+//
+// The CEX leg is fully driven through CEXClient. The on-chain short leg
+// is sized using the same risk-cap guardrails calculateFillPrice/
+// projectFill already enforce for regular order execution, but this
+// package has no instruction to originate a brand-new on-chain order with
+// (execute_order only fills an order a client already placed; there's no
+// place_order-equivalent here yet). Until that exists, scaleIn/unwind log
+// the sized slice instead of submitting it - the funding signal, slice
+// sizing, and CEX leg are real and usable as-is; wiring up the on-chain
+// leg is a follow-up once a place-order instruction is available.
+type FundingArbKeeper struct {
+	service *Service
+	cfg     config.FundingArbConfig
+	cex     CEXClient
+
+	mu        sync.Mutex
+	openQuote float64
+}
+
+// NewFundingArbKeeper constructs a FundingArbKeeper for the already-loaded
+// keeper Service, driven by cex for its external leg. Callers should check
+// cfg.Symbol != "" before calling Tick in a loop, the same way other
+// optional subsystems in this package are gated by their own config.
+func NewFundingArbKeeper(service *Service, cfg config.FundingArbConfig, cex CEXClient) *FundingArbKeeper {
+	return &FundingArbKeeper{service: service, cfg: cfg, cex: cex}
+}
+
+// Tick reads the configured market's current funding rate from runtime/
+// cache (already fetched for this poll, same as Service.tick's candidates)
+// and scales the arb position in or out accordingly. It is a no-op when
+// funding-arb isn't configured.
+func (k *FundingArbKeeper) Tick(ctx context.Context, runtime *runtimeAccounts, cache *accountCache) error {
+	if k.cfg.Symbol == "" {
+		return nil
+	}
+
+	marketKey, _, err := dex.DeriveMarketPDA(runtime.engineConfig.RegistryProgram, k.cfg.MarketID)
+	if err != nil {
+		return fmt.Errorf("derive market PDA for funding arb: %w", err)
+	}
+	fundingKey, _, err := dex.DeriveFundingPDA(k.service.cfg.OrderEngineProgramID, k.cfg.MarketID)
+	if err != nil {
+		return fmt.Errorf("derive funding PDA for funding arb: %w", err)
+	}
+
+	marketAccount, ok := cache.get(marketKey)
+	if !ok || marketAccount == nil {
+		return fmt.Errorf("market account %s missing from funding-arb tick cache", marketKey)
+	}
+	fundingAccount, ok := cache.get(fundingKey)
+	if !ok || fundingAccount == nil {
+		return fmt.Errorf("funding account %s missing from funding-arb tick cache", fundingKey)
+	}
+
+	market, err := orderengine.ParseAccount_Market(marketAccount.Data.GetBinary())
+	if err != nil {
+		return fmt.Errorf("decode market %s: %w", marketKey, err)
+	}
+	funding, err := orderengine.ParseAccount_MarketFundingState(fundingAccount.Data.GetBinary())
+	if err != nil {
+		return fmt.Errorf("decode funding state %s: %w", fundingKey, err)
+	}
+
+	rate := fundingRateRatio(funding)
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	switch {
+	case rate >= k.cfg.ShortFundingRateHigh && k.openQuote < k.cfg.QuoteInvestment:
+		return k.scaleIn(ctx, market, funding)
+	case rate <= k.cfg.ShortFundingRateLow && k.openQuote > 0:
+		return k.unwind(ctx)
+	}
+	return nil
+}
+
+// fundingRateRatio converts the on-chain funding state's FundingRateBps
+// (assumed signed field, positive meaning longs pay shorts - not
+// verifiable here since order_engine isn't vendored in this tree) into a
+// plain ratio, e.g. 30 bps -> 0.003.
+func fundingRateRatio(funding *orderengine.MarketFundingState) float64 {
+	return float64(funding.FundingRateBps) / float64(bpsDenom)
+}
+
+func (k *FundingArbKeeper) scaleIn(ctx context.Context, market *orderengine.Market, funding *orderengine.MarketFundingState) error {
+	remainingQuote := k.cfg.QuoteInvestment - k.openQuote
+	quoteSlice := k.cfg.IncrementalQuoteQuantity
+	if quoteSlice > remainingQuote {
+		quoteSlice = remainingQuote
+	}
+	if quoteSlice <= 0 {
+		return nil
+	}
+
+	desiredMargin := uint64(quoteSlice * k.cfg.Leverage * float64(priceScale))
+	margin, err := sizeFundingArbSlice(market, funding, desiredMargin)
+	if err != nil {
+		return fmt.Errorf("size funding-arb slice: %w", err)
+	}
+	if margin == 0 {
+		return nil
+	}
+
+	quoteFilled := quoteSlice
+	if margin < desiredMargin {
+		quoteFilled = quoteSlice * (float64(margin) / float64(desiredMargin))
+	}
+
+	if err := k.cex.OpenSpotLong(ctx, k.cfg.Symbol, quoteFilled); err != nil {
+		return fmt.Errorf("open CEX spot long: %w", err)
+	}
+
+	k.service.logger.Warn("funding-arb short leg not submitted on-chain: no place_order instruction in this package yet",
+		"market_id", k.cfg.MarketID, "margin", margin, "quote", quoteFilled)
+
+	k.openQuote += quoteFilled
+	return nil
+}
+
+func (k *FundingArbKeeper) unwind(ctx context.Context) error {
+	if k.openQuote <= 0 {
+		return nil
+	}
+
+	if err := k.cex.CloseSpotLong(ctx, k.cfg.Symbol, k.openQuote); err != nil {
+		return fmt.Errorf("close CEX spot long: %w", err)
+	}
+
+	k.service.logger.Warn("funding-arb short leg not closed on-chain: no place_order/close instruction in this package yet",
+		"market_id", k.cfg.MarketID, "quote", k.openQuote)
+
+	k.openQuote = 0
+	return nil
+}
+
+// sizeFundingArbSlice clips desiredMargin down to whatever fits within
+// market's risk caps, reusing projectFill (the same guardrail
+// calculateFillPrice itself converges against) rather than re-deriving the
+// notional/OI/skew cap checks a second time. It halves the candidate slice
+// up to 8 times, the same bounded-iteration shape calculateFillPrice used
+// to converge on a fill price before it moved to solveFixedPoint. oraclePrice
+// and fill are both passed as priceScale (fixed-point 1.0) so projectFill's
+// quote-notional-to-base-size conversion is a no-op here: this call only
+// cares about the notional/oi/skew cap checks, not a real fill price.
+func sizeFundingArbSlice(market *orderengine.Market, funding *orderengine.MarketFundingState, desiredMargin uint64) (uint64, error) {
+	slice := desiredMargin
+	for i := 0; i < 8; i++ {
+		if slice == 0 {
+			return 0, nil
+		}
+		order := &orderengine.Order{Side: orderengine.Side_Sell, Margin: slice}
+		projection, err := projectFill(order, market, funding, priceScale, priceScale)
+		if err != nil {
+			return 0, err
+		}
+		withinCaps := projection.notional <= market.RiskParams.MaxTradeNotional &&
+			projection.oi <= market.RiskParams.OiCap &&
+			absBigInt(projection.skew).Cmp(new(big.Int).SetUint64(market.RiskParams.SkewCap)) <= 0
+		if withinCaps {
+			return slice, nil
+		}
+		slice /= 2
+	}
+	return 0, nil
+}
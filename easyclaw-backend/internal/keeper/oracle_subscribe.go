@@ -0,0 +1,125 @@
+package keeper
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// oracleWSCacheMaxAge bounds how long a push-subscribed oracleSnapshot is
+// trusted before pythPushOracleSource.Snapshot falls back to decoding the
+// polled account instead. It's deliberately generous relative to
+// KeeperConfig.PollInterval - the per-market staleness enforced against
+// market.PricingParams.MaxOracleStalenessSec in prepareExecuteOrder already
+// rejects a snapshot whose own publish_time is too old, so this only needs
+// to catch a cache that stopped receiving pushes altogether.
+const oracleWSCacheMaxAge = 30 * time.Second
+
+// oracleWSCache holds the most recently push-subscribed oracleSnapshot per
+// Pyth price-update account, so pythPushOracleSource.Snapshot can serve a
+// read from memory instead of waiting on the next polled
+// getMultipleAccounts batch - the same event-driven-over-periodic shape an
+// external bookticker-driven SLTP refactor uses in place of a periodic
+// price poll.
+type oracleWSCache struct {
+	mu      sync.Mutex
+	entries map[solana.PublicKey]cachedOracleSnapshot
+}
+
+type cachedOracleSnapshot struct {
+	snapshot *oracleSnapshot
+	storedAt time.Time
+}
+
+func newOracleWSCache() *oracleWSCache {
+	return &oracleWSCache{entries: make(map[solana.PublicKey]cachedOracleSnapshot)}
+}
+
+// get returns pubkey's cached snapshot if one was stored within maxAge, and
+// false otherwise (cache miss or stale entry).
+func (c *oracleWSCache) get(pubkey solana.PublicKey, maxAge time.Duration) (*oracleSnapshot, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[pubkey]
+	if !ok || time.Since(entry.storedAt) > maxAge {
+		return nil, false
+	}
+	return entry.snapshot, true
+}
+
+func (c *oracleWSCache) set(pubkey solana.PublicKey, snapshot *oracleSnapshot) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[pubkey] = cachedOracleSnapshot{snapshot: snapshot, storedAt: time.Now()}
+}
+
+// runOraclePriceSubscriptions starts one accountSubscribe goroutine per
+// configured Pyth price-update account over conn, feeding s.oracleCache.
+// It makes no reconnect attempt of its own: once conn (or one of its
+// subscriptions) breaks, the affected goroutine logs and returns, and the
+// next successful ConnectWS call (Run retries it every tick while
+// WSConnected is false) starts a fresh batch over the new connection.
+func (s *Service) runOraclePriceSubscriptions(ctx context.Context, conn wsClient) {
+	for marketID, pubkey := range s.cfg.PythPriceUpdateAccountByMarket {
+		go s.subscribeOraclePriceLoop(ctx, conn, marketID, pubkey)
+	}
+}
+
+func (s *Service) subscribeOraclePriceLoop(ctx context.Context, conn wsClient, marketID uint64, pubkey solana.PublicKey) {
+	sub, err := conn.AccountSubscribe(pubkey, s.cfg.Commitment)
+	if err != nil {
+		s.logger.Warn("oracle price subscription failed, relying on polled reads",
+			"market_id", marketID, "pubkey", pubkey, "err", err)
+		return
+	}
+	defer sub.Unsubscribe()
+
+	for {
+		notification, err := sub.Recv(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				s.logger.Warn("oracle price subscription dropped, relying on polled reads",
+					"market_id", marketID, "pubkey", pubkey, "err", err)
+			}
+			return
+		}
+
+		account, err := pythAccountFromPushedData(notification.data)
+		if err != nil {
+			s.logger.Warn("failed to decode pushed oracle account envelope",
+				"market_id", marketID, "pubkey", pubkey, "err", err)
+			continue
+		}
+		snapshot, err := decodePythPriceUpdateAccount(account, time.Now().Unix())
+		if err != nil {
+			s.logger.Warn("failed to decode pushed oracle price update",
+				"market_id", marketID, "pubkey", pubkey, "err", err)
+			continue
+		}
+		s.oracleCache.set(pubkey, snapshot)
+	}
+}
+
+// pythAccountFromPushedData wraps an accountSubscribe notification's raw
+// bytes back into an *rpc.Account so decodePythPriceUpdateAccount can read
+// it exactly as it would a polled getMultipleAccounts result. rpc.Account's
+// Data field only knows how to decode the [data, encoding] shape the
+// JSON-RPC wire format uses (see account_vectors_test.go in the indexer
+// package), so this round-trips through that instead of constructing it
+// directly.
+func pythAccountFromPushedData(data []byte) (*rpc.Account, error) {
+	var accountData rpc.DataBytesOrJSON
+	encoded, err := json.Marshal([2]string{base64.StdEncoding.EncodeToString(data), "base64"})
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(encoded, &accountData); err != nil {
+		return nil, err
+	}
+	return &rpc.Account{Owner: pythPushOracleProgramID, Data: accountData}, nil
+}
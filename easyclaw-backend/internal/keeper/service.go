@@ -11,6 +11,7 @@ import (
 	"math/big"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	lpvault "github.com/coldbell/dex/backend/internal/anchor/lp_vault"
@@ -34,15 +35,29 @@ var (
 	errInvalidOracle            = errors.New("invalid oracle price update account")
 	errStaleOracle              = errors.New("stale oracle")
 	errUnexpectedOracleEncoding = errors.New("unexpected oracle payload encoding")
+	errMarketReduceOnly         = errors.New("market is reduce-only")
+	errMarketPaused             = errors.New("market is paused")
+	errTrailingStopTriggered    = errors.New("trailing stop triggered")
+	errFillNotConverged         = errors.New("fill price solver did not converge")
+	errBlockhashExpired         = errors.New("blockhash expired before confirmation")
 )
 
 var errSkipOrder = errors.New("skip order")
 
 type Service struct {
-	cfg    config.KeeperConfig
-	rpc    *rpc.Client
-	signer solana.PrivateKey
-	logger *slog.Logger
+	cfg           config.KeeperConfig
+	rpc           *rpc.Client
+	signer        solana.PrivateKey
+	logger        *slog.Logger
+	notifier      *Notifier
+	trailingTiers []trailingStopTier
+	trailingStops *trailingStopTracker
+
+	wsDial func(ctx context.Context, url string) (wsClient, error)
+	wsMu   sync.Mutex
+	wsConn wsClient
+
+	oracleCache *oracleWSCache
 }
 
 type runtimeAccounts struct {
@@ -81,10 +96,15 @@ func New(cfg config.KeeperConfig, logger *slog.Logger) (*Service, error) {
 	lpvault.ProgramID = cfg.LpVaultProgramID
 
 	return &Service{
-		cfg:    cfg,
-		rpc:    rpc.New(cfg.RPCURL),
-		signer: signer,
-		logger: logger,
+		cfg:           cfg,
+		rpc:           rpc.New(cfg.RPCURL),
+		signer:        signer,
+		logger:        logger,
+		notifier:      newNotifier(),
+		trailingTiers: trailingTiersFromConfig(cfg.TrailingActivationRatio, cfg.TrailingCallbackRate),
+		trailingStops: newTrailingStopTracker(),
+		wsDial:        dialWS,
+		oracleCache:   newOracleWSCache(),
 	}, nil
 }
 
@@ -95,6 +115,11 @@ func (s *Service) Run(ctx context.Context) error {
 		"executor", s.signer.PublicKey(),
 		"order_engine_program", s.cfg.OrderEngineProgramID,
 	)
+	defer s.CloseWS()
+
+	if err := s.ConnectWS(ctx); err != nil {
+		s.logger.Warn("websocket connect failed, starting on polling only", "err", err)
+	}
 
 	if err := s.tick(ctx); err != nil {
 		s.logger.Error("keeper tick failed", "err", err)
@@ -109,6 +134,15 @@ func (s *Service) Run(ctx context.Context) error {
 			s.logger.Info("keeper stopped")
 			return nil
 		case <-ticker.C:
+			// Reattempt the WebSocket connection on the same cadence as
+			// tick itself reuses it for confirmation/oracle pushes: no
+			// separate backoff loop, since a connection that's still down
+			// just means this tick's work falls back to polling again.
+			if !s.WSConnected() {
+				if err := s.ConnectWS(ctx); err != nil {
+					s.logger.Warn("websocket reconnect failed, continuing on polling", "err", err)
+				}
+			}
 			if err := s.tick(ctx); err != nil {
 				s.logger.Error("keeper tick failed", "err", err)
 			}
@@ -144,44 +178,147 @@ func (s *Service) tick(ctx context.Context) error {
 	if limit > len(orders) {
 		limit = len(orders)
 	}
+	candidates := orders[:limit]
 
-	executed := 0
-	cancelled := 0
-	skipped := 0
-	for idx := 0; idx < limit; idx++ {
-		if ctx.Err() != nil {
-			return ctx.Err()
-		}
-		candidate := orders[idx]
-		if candidate.order.ExpiresAt <= now {
-			expiredReason := fmt.Errorf("%w: order expired at %d", errSkipOrder, candidate.order.ExpiresAt)
-			if cancelErr := s.cancelOrderByExecutor(ctx, runtimeAccounts, candidate, expiredReason); cancelErr != nil {
-				skipped++
-				s.logger.Warn("expired order cancel failed", "order", candidate.pubkey, "err", cancelErr)
-				continue
-			}
-			cancelled++
-			continue
+	// Only non-expired candidates actually reach processOrder and its
+	// account reads; expired ones are cancelled without touching the
+	// market/funding/margin/position/rebate/oracle accounts at all.
+	active := make([]openOrder, 0, len(candidates))
+	for _, candidate := range candidates {
+		if candidate.order.ExpiresAt > now {
+			active = append(active, candidate)
 		}
+	}
+	cache, err := s.buildAccountCache(ctx, runtimeAccounts, active)
+	if err != nil {
+		return err
+	}
 
-		err := s.processOrder(ctx, runtimeAccounts, candidate)
-		if err == nil {
-			executed++
+	// Resting orders on a paused market are cancelled instead of executed,
+	// so check each active candidate's market exactly once up front rather
+	// than re-deriving and re-decoding it per order.
+	pausedMarkets := make(map[uint64]bool)
+	for _, candidate := range active {
+		marketID := candidate.order.MarketId
+		if _, checked := pausedMarkets[marketID]; checked {
 			continue
 		}
-		if errors.Is(err, errSkipOrder) {
-			skipped++
-			s.logger.Warn("order skipped", "order", candidate.pubkey, "reason", err)
+		paused, pauseErr := s.isMarketPaused(runtimeAccounts, cache, marketID)
+		if pauseErr != nil {
+			s.logger.Warn("failed to check market paused status", "market_id", marketID, "err", pauseErr)
 			continue
-		} else {
-			s.logger.Warn("order processing failed", "order", candidate.pubkey, "err", err)
 		}
+		pausedMarkets[marketID] = paused
+	}
 
-		if cancelErr := s.cancelOrderByExecutor(ctx, runtimeAccounts, candidate, err); cancelErr != nil {
-			s.logger.Warn("order cancel-on-failure failed", "order", candidate.pubkey, "err", cancelErr)
-			continue
+	s.notifier.Broadcast(Event{
+		Kind:    EventTickStarted,
+		Time:    time.Now(),
+		Message: fmt.Sprintf("tick started with %d open orders, %d candidates", len(orders), len(candidates)),
+	})
+
+	var (
+		mu               sync.Mutex
+		wg               sync.WaitGroup
+		executed         int
+		cancelled        int
+		skipped          int
+		batchesAttempted int
+		batchesFailed    int
+	)
+
+	workers := s.cfg.MaxConcurrentExecutions
+	if workers < 1 {
+		workers = 1
+	}
+	sem := make(chan struct{}, workers)
+
+	// When batching is enabled, active candidates sharing a market are
+	// dispatched together as one transaction instead of one goroutine per
+	// order; expired candidates and markets with only a single ready order
+	// still go through the per-order path below.
+	singles := candidates
+	var batchGroups map[uint64][]openOrder
+	if s.cfg.BatchByMarket {
+		byMarket := make(map[uint64][]openOrder)
+		singles = make([]openOrder, 0, len(candidates))
+		for _, candidate := range candidates {
+			if candidate.order.ExpiresAt <= now || pausedMarkets[candidate.order.MarketId] {
+				singles = append(singles, candidate)
+				continue
+			}
+			byMarket[candidate.order.MarketId] = append(byMarket[candidate.order.MarketId], candidate)
+		}
+		batchGroups = make(map[uint64][]openOrder, len(byMarket))
+		for marketID, group := range byMarket {
+			if len(group) > 1 {
+				batchGroups[marketID] = group
+			} else {
+				singles = append(singles, group...)
+			}
+		}
+	}
+
+	for marketID, group := range batchGroups {
+		if ctx.Err() != nil {
+			break
 		}
-		cancelled++
+		marketID, group := marketID, group
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			mu.Lock()
+			batchesAttempted++
+			mu.Unlock()
+
+			attemptedOrders, batchErr := s.executeBatch(ctx, runtimeAccounts, cache, marketID, group)
+			if batchErr != nil {
+				s.logger.Warn("batched execution failed, falling back to individual sends",
+					"market_id", marketID, "batch_size", len(group), "err", batchErr)
+				mu.Lock()
+				batchesFailed++
+				mu.Unlock()
+				for _, candidate := range attemptedOrders {
+					s.executeSingleCandidate(ctx, runtimeAccounts, cache, candidate, now, pausedMarkets, &mu, &executed, &skipped, &cancelled)
+				}
+			} else {
+				mu.Lock()
+				executed += len(attemptedOrders)
+				mu.Unlock()
+			}
+
+			for _, candidate := range group[len(attemptedOrders):] {
+				s.executeSingleCandidate(ctx, runtimeAccounts, cache, candidate, now, pausedMarkets, &mu, &executed, &skipped, &cancelled)
+			}
+		}()
+	}
+
+	// singles is already sorted expired-first then by CreatedAt; workers
+	// are fed from the slice in that order, so ties in arrival within the
+	// worker pool still favor earlier (more urgent) candidates, even though
+	// completion order isn't guaranteed once more than one worker runs.
+	for idx := 0; idx < len(singles); idx++ {
+		if ctx.Err() != nil {
+			break
+		}
+		candidate := singles[idx]
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			s.executeSingleCandidate(ctx, runtimeAccounts, cache, candidate, now, pausedMarkets, &mu, &executed, &skipped, &cancelled)
+		}()
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return ctx.Err()
 	}
 
 	s.logger.Info(
@@ -196,10 +333,102 @@ func (s *Service) tick(ctx context.Context) error {
 		skipped,
 		"cancelled",
 		cancelled,
+		"batches_attempted",
+		batchesAttempted,
+		"batches_failed",
+		batchesFailed,
 	)
 	return nil
 }
 
+// executeSingleCandidate runs the expire-or-execute path for one order:
+// expired orders are cancelled directly, orders on a paused market are
+// cancelled rather than executed, and any other ready order goes through
+// processOrder and is classified into executed/skipped/cancelled exactly
+// as tick's pre-batching worker loop did. It's shared between tick's plain
+// per-order dispatch and the batch-fallback/leftover paths, which can't
+// use the shared transaction once any part of the batch has failed (or
+// wasn't included in it).
+func (s *Service) executeSingleCandidate(
+	ctx context.Context,
+	runtime *runtimeAccounts,
+	cache *accountCache,
+	candidate openOrder,
+	now int64,
+	pausedMarkets map[uint64]bool,
+	mu *sync.Mutex,
+	executed, skipped, cancelled *int,
+) {
+	if candidate.order.ExpiresAt <= now {
+		expiredReason := fmt.Errorf("%w: order expired at %d", errSkipOrder, candidate.order.ExpiresAt)
+		if cancelErr := s.cancelOrderByExecutor(ctx, runtime, candidate, expiredReason); cancelErr != nil {
+			s.logger.Warn("expired order cancel failed", "order", candidate.pubkey, "err", cancelErr)
+			mu.Lock()
+			*skipped++
+			mu.Unlock()
+			return
+		}
+		mu.Lock()
+		*cancelled++
+		mu.Unlock()
+		return
+	}
+
+	if pausedMarkets[candidate.order.MarketId] {
+		pausedReason := fmt.Errorf("%w: market %d", errMarketPaused, candidate.order.MarketId)
+		if cancelErr := s.cancelOrderByExecutor(ctx, runtime, candidate, pausedReason); cancelErr != nil {
+			s.logger.Warn("paused-market order cancel failed", "order", candidate.pubkey, "err", cancelErr)
+			mu.Lock()
+			*skipped++
+			mu.Unlock()
+			return
+		}
+		mu.Lock()
+		*cancelled++
+		mu.Unlock()
+		return
+	}
+
+	err := s.processOrder(ctx, runtime, cache, candidate)
+	if err == nil {
+		mu.Lock()
+		*executed++
+		mu.Unlock()
+		return
+	}
+	// GTC orders left unfillable this tick stay resting for the next one.
+	// IOC/FOK/PostOnly orders are only ever meant to fill immediately, so a
+	// skip for one of them falls through to the cancel-on-failure path
+	// below instead - there's no separate "partial fill then cancel"
+	// primitive in this engine (every execute_order fills its full margin
+	// notional or not at all), so IOC and FOK collapse to the same
+	// fill-now-or-cancel behavior here.
+	if errors.Is(err, errSkipOrder) && candidate.order.TimeInForce == orderengine.TimeInForce_GTC {
+		s.logger.Warn("order skipped", "order", candidate.pubkey, "reason", err)
+		s.notifier.Broadcast(Event{
+			Kind:     EventOrderSkipped,
+			Time:     time.Now(),
+			Order:    candidate.pubkey,
+			MarketID: candidate.order.MarketId,
+			Message:  "order skipped",
+			Err:      err,
+		})
+		mu.Lock()
+		*skipped++
+		mu.Unlock()
+		return
+	}
+	s.logger.Warn("order processing failed", "order", candidate.pubkey, "err", err)
+
+	if cancelErr := s.cancelOrderByExecutor(ctx, runtime, candidate, err); cancelErr != nil {
+		s.logger.Warn("order cancel-on-failure failed", "order", candidate.pubkey, "err", cancelErr)
+		return
+	}
+	mu.Lock()
+	*cancelled++
+	mu.Unlock()
+}
+
 func (s *Service) cancelOrderByExecutor(
 	ctx context.Context,
 	runtime *runtimeAccounts,
@@ -223,33 +452,13 @@ func (s *Service) cancelOrderByExecutor(
 		candidate.pubkey,
 	)
 
-	instructions := make([]solana.Instruction, 0, 3)
-	if s.cfg.ComputeUnitLimit > 0 {
-		cuLimitIx, buildErr := computebudget.NewSetComputeUnitLimitInstruction(s.cfg.ComputeUnitLimit).ValidateAndBuild()
-		if buildErr != nil {
-			return fmt.Errorf("build compute unit limit instruction for cancel: %w", buildErr)
-		}
-		instructions = append(instructions, cuLimitIx)
-	}
-	if s.cfg.ComputeUnitPriceMicroLamports > 0 {
-		cuPriceIx, buildErr := computebudget.NewSetComputeUnitPriceInstruction(s.cfg.ComputeUnitPriceMicroLamports).ValidateAndBuild()
-		if buildErr != nil {
-			return fmt.Errorf("build compute unit price instruction for cancel: %w", buildErr)
-		}
-		instructions = append(instructions, cuPriceIx)
-	}
-	instructions = append(instructions, cancelIx)
-
-	txCtx, cancel := context.WithTimeout(ctx, s.cfg.TxTimeout)
-	defer cancel()
-
-	signature, err := s.sendTransaction(txCtx, instructions)
+	writableAccounts := []solana.PublicKey{marketKey, candidate.order.UserMargin}
+	signature, fee, err := s.sendWithPriorityFee(ctx, writableAccounts, func(computeUnitPriceMicroLamports uint64) ([]solana.Instruction, error) {
+		return s.buildInstructions(cancelIx, computeUnitPriceMicroLamports)
+	})
 	if err != nil {
 		return fmt.Errorf("send cancel_order_by_executor transaction: %w", err)
 	}
-	if err := s.waitForConfirmation(txCtx, signature); err != nil {
-		return fmt.Errorf("confirm cancel_order_by_executor %s: %w", signature, err)
-	}
 
 	s.logger.Info(
 		"order cancelled by executor",
@@ -259,9 +468,19 @@ func (s *Service) cancelOrderByExecutor(
 		candidate.order.MarketId,
 		"reason",
 		cause,
+		"priority_fee_micro_lamports",
+		fee,
 		"signature",
 		signature,
 	)
+	s.notifier.Broadcast(Event{
+		Kind:      EventOrderCancelled,
+		Time:      time.Now(),
+		Order:     candidate.pubkey,
+		MarketID:  candidate.order.MarketId,
+		Message:   fmt.Sprintf("order cancelled by executor: %v", cause),
+		Signature: signature,
+	})
 
 	return nil
 }
@@ -312,6 +531,12 @@ func (s *Service) loadRuntimeAccounts(ctx context.Context) (*runtimeAccounts, er
 		return nil, fmt.Errorf("executor %s is not allowed in keeper set and not multisig", executor)
 	}
 
+	s.notifier.Broadcast(Event{
+		Kind:    EventRuntimeAccountsReload,
+		Time:    time.Now(),
+		Message: fmt.Sprintf("runtime accounts reloaded (engine_config=%s)", engineConfigKey),
+	})
+
 	return &runtimeAccounts{
 		engineConfigKey: engineConfigKey,
 		engineConfig:    engineConfig,
@@ -350,34 +575,195 @@ func (s *Service) fetchOpenOrders(ctx context.Context) ([]openOrder, error) {
 	return openOrders, nil
 }
 
-func (s *Service) processOrder(ctx context.Context, runtime *runtimeAccounts, candidate openOrder) error {
-	now := s.getClusterUnixTime(ctx)
-	if candidate.order.ExpiresAt <= now {
-		return fmt.Errorf("%w: order expired at %d", errSkipOrder, candidate.order.ExpiresAt)
+// accountCache holds account snapshots fetched once per tick (keyed by
+// pubkey) so that orders sharing a market, funding state, or oracle account
+// don't each re-issue their own GetMultipleAccounts RPC call.
+type accountCache struct {
+	accounts map[solana.PublicKey]*rpc.Account
+}
+
+// maxAccountsPerBatchFetch mirrors the Solana RPC's getMultipleAccounts
+// cap, so a tick with many distinct accounts still fetches them in bounded
+// batches instead of one unbounded call.
+const maxAccountsPerBatchFetch = 100
+
+func (c *accountCache) get(key solana.PublicKey) (*rpc.Account, bool) {
+	acc, ok := c.accounts[key]
+	return acc, ok
+}
+
+// resolveOrderAccountKeys derives the account keys processOrder needs for
+// candidate: the market, funding, user-position, and keeper-rebate PDAs,
+// plus whichever oracle account (if any) is configured for the order's
+// market.
+func (s *Service) resolveOrderAccountKeys(runtime *runtimeAccounts, candidate openOrder) (marketKey, fundingKey, userPositionKey, keeperRebateKey, oracleAccountKey solana.PublicKey, hasOracleAccount bool, err error) {
+	marketKey, _, err = dex.DeriveMarketPDA(runtime.engineConfig.RegistryProgram, candidate.order.MarketId)
+	if err != nil {
+		err = fmt.Errorf("derive market PDA: %w", err)
+		return
+	}
+	fundingKey, _, err = dex.DeriveFundingPDA(s.cfg.OrderEngineProgramID, candidate.order.MarketId)
+	if err != nil {
+		err = fmt.Errorf("derive funding PDA: %w", err)
+		return
+	}
+	userPositionKey, _, err = dex.DeriveUserMarketPositionPDA(s.cfg.OrderEngineProgramID, candidate.order.UserMargin, candidate.order.MarketId)
+	if err != nil {
+		err = fmt.Errorf("derive user market position PDA: %w", err)
+		return
 	}
+	keeperRebateKey, _, err = dex.DeriveKeeperRebatePDA(runtime.engineConfig.LpVaultProgram, runtime.engineConfig.LpPool, s.signer.PublicKey())
+	if err != nil {
+		err = fmt.Errorf("derive keeper rebate PDA: %w", err)
+		return
+	}
+	oracleAccountKey, hasOracleAccount = s.oracleAccountKeyForMarket(candidate.order.MarketId)
+	return
+}
 
-	marketKey, _, err := dex.DeriveMarketPDA(runtime.engineConfig.RegistryProgram, candidate.order.MarketId)
+// buildAccountCache batches all accounts that candidates' processOrder
+// calls will need for this tick into one or two GetMultipleAccounts calls,
+// deduplicating pubkeys that multiple orders share (same market, same
+// oracle account, etc). A candidate whose account keys can't be derived is
+// simply left out of the cache; processOrder will hit the same derivation
+// error and skip it.
+func (s *Service) buildAccountCache(ctx context.Context, runtime *runtimeAccounts, candidates []openOrder) (*accountCache, error) {
+	seen := make(map[solana.PublicKey]bool)
+	keys := make([]solana.PublicKey, 0, len(candidates)*6)
+	addKey := func(key solana.PublicKey) {
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		keys = append(keys, key)
+	}
+
+	for _, candidate := range candidates {
+		marketKey, fundingKey, userPositionKey, keeperRebateKey, oracleAccountKey, _, err := s.resolveOrderAccountKeys(runtime, candidate)
+		if err != nil {
+			continue
+		}
+		addKey(marketKey)
+		addKey(fundingKey)
+		addKey(candidate.order.UserMargin)
+		addKey(userPositionKey)
+		addKey(keeperRebateKey)
+		addKey(oracleAccountKey)
+	}
+
+	cache := &accountCache{accounts: make(map[solana.PublicKey]*rpc.Account, len(keys))}
+	for start := 0; start < len(keys); start += maxAccountsPerBatchFetch {
+		end := start + maxAccountsPerBatchFetch
+		if end > len(keys) {
+			end = len(keys)
+		}
+		chunk := keys[start:end]
+
+		fetched, err := s.rpc.GetMultipleAccountsWithOpts(ctx, chunk, &rpc.GetMultipleAccountsOpts{Commitment: s.cfg.Commitment})
+		if err != nil {
+			return nil, fmt.Errorf("batch fetch order accounts: %w", err)
+		}
+		if len(fetched.Value) != len(chunk) {
+			return nil, fmt.Errorf("unexpected account count in batch fetch")
+		}
+		for i, acc := range fetched.Value {
+			cache.accounts[chunk[i]] = acc
+		}
+	}
+
+	return cache, nil
+}
+
+// isMarketPaused reports whether marketID's Market account (already
+// present in cache, since every active candidate's market is fetched into
+// it) has its Paused flag set. Resting orders on a paused market are
+// cancelled rather than executed - see executeSingleCandidate.
+func (s *Service) isMarketPaused(runtime *runtimeAccounts, cache *accountCache, marketID uint64) (bool, error) {
+	marketKey, _, err := dex.DeriveMarketPDA(runtime.engineConfig.RegistryProgram, marketID)
 	if err != nil {
-		return fmt.Errorf("derive market PDA: %w", err)
+		return false, fmt.Errorf("derive market PDA for pause check: %w", err)
+	}
+	account, ok := cache.get(marketKey)
+	if !ok || account == nil {
+		return false, fmt.Errorf("market account %s missing from tick cache", marketKey)
 	}
-	fundingKey, _, err := dex.DeriveFundingPDA(s.cfg.OrderEngineProgramID, candidate.order.MarketId)
+	market, err := orderengine.ParseAccount_Market(account.Data.GetBinary())
 	if err != nil {
-		return fmt.Errorf("derive funding PDA: %w", err)
+		return false, fmt.Errorf("decode market %s: %w", marketKey, err)
 	}
-	userPositionKey, _, err := dex.DeriveUserMarketPositionPDA(s.cfg.OrderEngineProgramID, candidate.order.UserMargin, candidate.order.MarketId)
+	return market.Paused, nil
+}
+
+// reducesPosition reports whether an order on side would only reduce or
+// close an existing position rather than open one or add to it on the
+// same side - the condition a reduce-only market requires before
+// prepareExecuteOrder will build its execute_order instruction.
+func reducesPosition(side orderengine.Side, position *orderengine.UserMarketPosition) bool {
+	return position.Size > 0 && position.Side != side
+}
+
+func (s *Service) processOrder(ctx context.Context, runtime *runtimeAccounts, cache *accountCache, candidate openOrder) error {
+	executeIx, writableAccounts, quote, err := s.prepareExecuteOrder(ctx, runtime, cache, candidate)
 	if err != nil {
-		return fmt.Errorf("derive user market position PDA: %w", err)
+		return err
+	}
+
+	signature, fee, err := s.sendWithPriorityFee(ctx, writableAccounts, func(computeUnitPriceMicroLamports uint64) ([]solana.Instruction, error) {
+		return s.buildInstructions(executeIx, computeUnitPriceMicroLamports)
+	})
+	if err != nil {
+		return fmt.Errorf("send execute_order transaction: %w", err)
+	}
+
+	s.logger.Info("order executed",
+		"order", candidate.pubkey,
+		"market_id", candidate.order.MarketId,
+		"side", candidate.order.Side.String(),
+		"margin", candidate.order.Margin,
+		"fill_price", quote.fillPrice,
+		"oracle_price", quote.oraclePrice,
+		"priority_fee_micro_lamports", fee,
+		"signature", signature,
+	)
+	s.notifier.Broadcast(Event{
+		Kind:      EventOrderExecuted,
+		Time:      time.Now(),
+		Order:     candidate.pubkey,
+		MarketID:  candidate.order.MarketId,
+		Message:   fmt.Sprintf("order executed: side=%s fill_price=%d", candidate.order.Side.String(), quote.fillPrice),
+		Signature: signature,
+	})
+
+	return nil
+}
+
+// prepareExecuteOrder resolves candidate's accounts, decodes and validates
+// market/funding/margin/position/rebate/oracle state, and builds its
+// execute_order instruction, without sending anything. It's shared by
+// processOrder (one order per transaction) and executeBatch (several
+// orders for the same market packed into one transaction), the same
+// prepare/send split bbgo uses between BatchPlaceOrders and a plain
+// single-order placement.
+func (s *Service) prepareExecuteOrder(ctx context.Context, runtime *runtimeAccounts, cache *accountCache, candidate openOrder) (solana.Instruction, []solana.PublicKey, *executionQuote, error) {
+	now := s.getClusterUnixTime(ctx)
+	if candidate.order.ExpiresAt <= now {
+		return nil, nil, nil, fmt.Errorf("%w: order expired at %d", errSkipOrder, candidate.order.ExpiresAt)
 	}
-	keeperRebateKey, _, err := dex.DeriveKeeperRebatePDA(runtime.engineConfig.LpVaultProgram, runtime.engineConfig.LpPool, s.signer.PublicKey())
+
+	marketKey, fundingKey, userPositionKey, keeperRebateKey, oracleAccountKey, hasOracleAccount, err := s.resolveOrderAccountKeys(runtime, candidate)
 	if err != nil {
-		return fmt.Errorf("derive keeper rebate PDA: %w", err)
+		return nil, nil, nil, err
 	}
 
+	oracleKind := s.cfg.OracleKindForMarket(candidate.order.MarketId)
+	oracleSource, err := s.oracleSourceForMarket(candidate.order.MarketId)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("%w: %v", errSkipOrder, err)
+	}
 	oracleByMarket, oracleFallbackExists := s.cfg.OracleByMarket[candidate.order.MarketId]
-	oraclePriceUpdateKey, hasOracleAccount := s.cfg.PythPriceUpdateAccountByMarket[candidate.order.MarketId]
-	effectiveOraclePriceUpdateKey := oraclePriceUpdateKey
+	effectiveOraclePriceUpdateKey := oracleAccountKey
 	if !hasOracleAccount && !oracleFallbackExists {
-		return fmt.Errorf("%w: missing oracle price update account for market %d", errSkipOrder, candidate.order.MarketId)
+		return nil, nil, nil, fmt.Errorf("%w: missing oracle account for market %d", errSkipOrder, candidate.order.MarketId)
 	}
 	if !hasOracleAccount {
 		effectiveOraclePriceUpdateKey = solana.SystemProgramID
@@ -389,106 +775,109 @@ func (s *Service) processOrder(ctx context.Context, runtime *runtimeAccounts, ca
 		candidate.order.UserMargin,
 		userPositionKey,
 		keeperRebateKey,
-		oraclePriceUpdateKey,
+		oracleAccountKey,
 	}
-	fetched, err := s.rpc.GetMultipleAccountsWithOpts(ctx, accountKeys, &rpc.GetMultipleAccountsOpts{Commitment: s.cfg.Commitment})
-	if err != nil {
-		return fmt.Errorf("fetch order runtime accounts: %w", err)
-	}
-	if len(fetched.Value) != len(accountKeys) {
-		return fmt.Errorf("unexpected account count for order %s", candidate.pubkey)
+	fetchedValues := make([]*rpc.Account, len(accountKeys))
+	for i, key := range accountKeys {
+		acc, ok := cache.get(key)
+		if !ok {
+			return nil, nil, nil, fmt.Errorf("%w: account %s missing from tick cache", errSkipOrder, key)
+		}
+		fetchedValues[i] = acc
 	}
-	for i, acc := range fetched.Value {
+	for i, acc := range fetchedValues {
 		if acc == nil {
 			if i == 5 && oracleFallbackExists {
 				continue
 			}
-			return fmt.Errorf("%w: missing required account %s", errSkipOrder, accountKeys[i])
+			return nil, nil, nil, fmt.Errorf("%w: missing required account %s", errSkipOrder, accountKeys[i])
 		}
 	}
 
-	market, err := orderengine.ParseAccount_Market(fetched.Value[0].Data.GetBinary())
+	market, err := orderengine.ParseAccount_Market(fetchedValues[0].Data.GetBinary())
 	if err != nil {
-		return fmt.Errorf("decode market %s: %w", marketKey, err)
+		return nil, nil, nil, fmt.Errorf("decode market %s: %w", marketKey, err)
 	}
-	funding, err := orderengine.ParseAccount_MarketFundingState(fetched.Value[1].Data.GetBinary())
+	funding, err := orderengine.ParseAccount_MarketFundingState(fetchedValues[1].Data.GetBinary())
 	if err != nil {
-		return fmt.Errorf("decode funding state %s: %w", fundingKey, err)
+		return nil, nil, nil, fmt.Errorf("decode funding state %s: %w", fundingKey, err)
 	}
-	margin, err := orderengine.ParseAccount_UserMargin(fetched.Value[2].Data.GetBinary())
+	margin, err := orderengine.ParseAccount_UserMargin(fetchedValues[2].Data.GetBinary())
 	if err != nil {
-		return fmt.Errorf("decode margin %s: %w", candidate.order.UserMargin, err)
+		return nil, nil, nil, fmt.Errorf("decode margin %s: %w", candidate.order.UserMargin, err)
 	}
 	if !margin.Owner.Equals(candidate.order.User) {
-		return fmt.Errorf("%w: margin owner mismatch", errSkipOrder)
+		return nil, nil, nil, fmt.Errorf("%w: margin owner mismatch", errSkipOrder)
 	}
 
-	if _, err := orderengine.ParseAccount_UserMarketPosition(fetched.Value[3].Data.GetBinary()); err != nil {
-		return fmt.Errorf("decode user position %s: %w", userPositionKey, err)
+	position, err := orderengine.ParseAccount_UserMarketPosition(fetchedValues[3].Data.GetBinary())
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("decode user position %s: %w", userPositionKey, err)
+	}
+	if market.ReduceOnly && !reducesPosition(candidate.order.Side, position) {
+		return nil, nil, nil, fmt.Errorf("%w: %w", errSkipOrder, errMarketReduceOnly)
 	}
-	if _, err := orderengine.ParseAccount_KeeperRebate(fetched.Value[4].Data.GetBinary()); err != nil {
-		return fmt.Errorf("%w: keeper rebate account %s missing or invalid: %v", errSkipOrder, keeperRebateKey, err)
+	if _, err := orderengine.ParseAccount_KeeperRebate(fetchedValues[4].Data.GetBinary()); err != nil {
+		return nil, nil, nil, fmt.Errorf("%w: keeper rebate account %s missing or invalid: %v", errSkipOrder, keeperRebateKey, err)
 	}
 	oracle, err := func() (*oracleSnapshot, error) {
-		account := fetched.Value[5]
-		if account == nil {
-			if !oracleFallbackExists {
-				return nil, fmt.Errorf("%w: missing oracle price update account for market %d", errSkipOrder, candidate.order.MarketId)
-			}
-			effectiveOraclePriceUpdateKey = solana.SystemProgramID
-			conf, err := fallbackOracleConfForMarket(oracleByMarket, now)
-			if err != nil {
-				return nil, fmt.Errorf("%w: %v", errSkipOrder, err)
-			}
-			return &oracleSnapshot{
-				feedID:      [32]byte{},
-				price:       oracleByMarket.Price,
-				conf:        conf,
-				publishTime: fallbackPublishTime(oracleByMarket, now),
-			}, nil
+		account := fetchedValues[5]
+		decoded, decodeErr := oracleSource.Snapshot(ctx, account, now)
+		if decodeErr == nil {
+			return decoded, nil
 		}
-
-		decoded, decodeErr := decodePythPriceUpdateAccount(account, now)
-		if decodeErr != nil {
-			if !oracleFallbackExists {
-				return nil, fmt.Errorf("%w: %v", errSkipOrder, decodeErr)
-			}
-			effectiveOraclePriceUpdateKey = solana.SystemProgramID
-			conf, confErr := fallbackOracleConfForMarket(oracleByMarket, now)
-			if confErr != nil {
-				return nil, fmt.Errorf("%w: %v", errSkipOrder, confErr)
-			}
-			return &oracleSnapshot{
-				feedID:      [32]byte{},
-				price:       oracleByMarket.Price,
-				conf:        conf,
-				publishTime: fallbackPublishTime(oracleByMarket, now),
-			}, nil
+		if !oracleFallbackExists {
+			return nil, fmt.Errorf("%w: %v", errSkipOrder, decodeErr)
 		}
-		return decoded, nil
+		effectiveOraclePriceUpdateKey = solana.SystemProgramID
+		fallback, fallbackErr := (staticFallbackOracleSource{cfg: oracleByMarket}).Snapshot(ctx, nil, now)
+		if fallbackErr != nil {
+			return nil, fmt.Errorf("%w: %v", errSkipOrder, fallbackErr)
+		}
+		return fallback, nil
 	}()
 	if err != nil {
-		return err
+		return nil, nil, nil, err
 	}
 	if oracle == nil {
-		return fmt.Errorf("%w: no oracle snapshot for market %d", errSkipOrder, candidate.order.MarketId)
+		return nil, nil, nil, fmt.Errorf("%w: no oracle snapshot for market %d", errSkipOrder, candidate.order.MarketId)
 	}
 
-	if oracleFallbackExists {
+	// market.PythFeed only has a natural counterpart to compare against
+	// when the primary source is Pyth itself; other oracle kinds (and any
+	// market with a static fallback configured, same as before this
+	// source became pluggable) establish their identity through the
+	// account pubkey pinned in config instead, so the check below is
+	// trivially satisfied rather than enforced for them.
+	if oracleFallbackExists || oracleKind != config.OracleKindPythPush {
 		oracle.feedID = market.PythFeed
 	}
 
 	if !bytes.Equal(oracle.feedID[:], market.PythFeed[:]) {
-		return fmt.Errorf("%w: oracle feed id mismatch for market %d", errSkipOrder, candidate.order.MarketId)
+		return nil, nil, nil, fmt.Errorf("%w: oracle feed id mismatch for market %d", errSkipOrder, candidate.order.MarketId)
 	}
 	maxStaleness := int64(market.PricingParams.MaxOracleStalenessSec)
 	if maxStaleness < 0 || now < oracle.publishTime || now-oracle.publishTime > maxStaleness {
-		return fmt.Errorf("%w: %w", errSkipOrder, errStaleOracle)
+		s.notifier.Broadcast(Event{
+			Kind:     EventOracleStale,
+			Time:     time.Now(),
+			Order:    candidate.pubkey,
+			MarketID: candidate.order.MarketId,
+			Message:  fmt.Sprintf("oracle publish_time=%d stale against now=%d (max_staleness=%d)", oracle.publishTime, now, maxStaleness),
+		})
+		return nil, nil, nil, fmt.Errorf("%w: %w", errSkipOrder, errStaleOracle)
 	}
 
 	quote, err := s.buildExecutionQuote(candidate.order, market, funding, oracle)
 	if err != nil {
-		return err
+		return nil, nil, nil, err
+	}
+
+	if position.Size > 0 && len(s.trailingTiers) > 0 {
+		long := positionIsLong(position.Side)
+		if triggered, callback := s.trailingStops.update(userPositionKey.String(), long, float64(quote.oraclePrice), s.trailingTiers); triggered {
+			return nil, nil, nil, fmt.Errorf("%w: oracle price retraced past callback rate %.6f for position %s", errTrailingStopTriggered, callback, userPositionKey)
+		}
 	}
 
 	executeIx, err := orderengine.NewExecuteOrderInstruction(
@@ -518,48 +907,109 @@ func (s *Service) processOrder(ctx context.Context, runtime *runtimeAccounts, ca
 		solana.TokenProgramID,
 	)
 	if err != nil {
-		return fmt.Errorf("build execute_order instruction: %w", err)
+		return nil, nil, nil, fmt.Errorf("build execute_order instruction: %w", err)
 	}
 
-	instructions := make([]solana.Instruction, 0, 3)
-	if s.cfg.ComputeUnitLimit > 0 {
-		cuLimitIx, err := computebudget.NewSetComputeUnitLimitInstruction(s.cfg.ComputeUnitLimit).ValidateAndBuild()
-		if err != nil {
-			return fmt.Errorf("build compute unit limit instruction: %w", err)
-		}
-		instructions = append(instructions, cuLimitIx)
-	}
-	if s.cfg.ComputeUnitPriceMicroLamports > 0 {
-		cuPriceIx, err := computebudget.NewSetComputeUnitPriceInstruction(s.cfg.ComputeUnitPriceMicroLamports).ValidateAndBuild()
-		if err != nil {
-			return fmt.Errorf("build compute unit price instruction: %w", err)
-		}
-		instructions = append(instructions, cuPriceIx)
-	}
-	instructions = append(instructions, executeIx)
+	writableAccounts := []solana.PublicKey{marketKey, candidate.order.UserMargin, effectiveOraclePriceUpdateKey}
+	return executeIx, writableAccounts, quote, nil
+}
 
-	txCtx, cancel := context.WithTimeout(ctx, s.cfg.TxTimeout)
-	defer cancel()
+// maxBatchedAccountKeys bounds how many distinct account keys executeBatch
+// will pack into one batched transaction. It's a conservative proxy for
+// Solana's 1232-byte transaction size limit rather than an exact byte
+// count: each account key costs 32 bytes in the message, and staying well
+// under half of the budget leaves headroom for the compute-budget prefix,
+// per-instruction data, and signatures without this package having to
+// reimplement its solana-go dependency's wire serialization.
+const maxBatchedAccountKeys = 24
+
+type preparedBatchOrder struct {
+	candidate openOrder
+	ix        solana.Instruction
+	quote     *executionQuote
+}
 
-	signature, err := s.sendTransaction(txCtx, instructions)
-	if err != nil {
-		return fmt.Errorf("send transaction: %w", err)
+// executeBatch packs execute_order instructions for up to
+// cfg.MaxOrdersPerBatch orders in group into a single transaction,
+// deduplicating account keys across orders so the combined writable-account
+// set (and therefore signature/account overhead) stays small. group must
+// already be restricted to one market and to non-expired, not-yet-attempted
+// candidates.
+//
+// attempted is the prefix of group that was actually packed into the
+// transaction - shorter than group when MaxOrdersPerBatch or
+// maxBatchedAccountKeys cut it off first. Callers must still process
+// group[len(attempted):] individually. If err is non-nil, every order in
+// attempted failed together (the whole transaction failed or wasn't
+// confirmed) and callers should fall back to sending them one at a time,
+// analogous to bbgo's BatchPlaceOrders falling back to
+// BatchRetryPlaceOrders on partial failure.
+func (s *Service) executeBatch(ctx context.Context, runtime *runtimeAccounts, cache *accountCache, marketID uint64, group []openOrder) (attempted []openOrder, err error) {
+	limit := s.cfg.MaxOrdersPerBatch
+	if limit <= 0 || limit > len(group) {
+		limit = len(group)
+	}
+
+	var prepared []preparedBatchOrder
+	var writableAccounts []solana.PublicKey
+	for _, candidate := range group[:limit] {
+		ix, writable, quote, prepErr := s.prepareExecuteOrder(ctx, runtime, cache, candidate)
+		if prepErr != nil {
+			return nil, fmt.Errorf("prepare order %s for batch: %w", candidate.pubkey, prepErr)
+		}
+
+		merged := writableAccounts
+		for _, key := range writable {
+			if !containsPubkey(merged, key) {
+				merged = append(merged, key)
+			}
+		}
+		if len(merged) > maxBatchedAccountKeys && len(prepared) > 0 {
+			break
+		}
+		writableAccounts = merged
+		prepared = append(prepared, preparedBatchOrder{candidate: candidate, ix: ix, quote: quote})
 	}
-	if err := s.waitForConfirmation(txCtx, signature); err != nil {
-		return fmt.Errorf("wait confirmation %s: %w", signature, err)
+	if len(prepared) == 0 {
+		return nil, nil
 	}
 
-	s.logger.Info("order executed",
-		"order", candidate.pubkey,
-		"market_id", candidate.order.MarketId,
-		"side", candidate.order.Side.String(),
-		"margin", candidate.order.Margin,
-		"fill_price", quote.fillPrice,
-		"oracle_price", quote.oraclePrice,
-		"signature", signature,
-	)
+	instructions := make([]solana.Instruction, len(prepared))
+	attempted = make([]openOrder, len(prepared))
+	for i, p := range prepared {
+		instructions[i] = p.ix
+		attempted[i] = p.candidate
+	}
 
-	return nil
+	signature, fee, err := s.sendWithPriorityFee(ctx, writableAccounts, func(computeUnitPriceMicroLamports uint64) ([]solana.Instruction, error) {
+		return s.buildBatchInstructions(instructions, computeUnitPriceMicroLamports)
+	})
+	if err != nil {
+		return attempted, fmt.Errorf("send batched execute_order transaction (market %d, %d orders): %w", marketID, len(prepared), err)
+	}
+
+	for _, p := range prepared {
+		s.logger.Info("order executed (batched)",
+			"order", p.candidate.pubkey,
+			"market_id", marketID,
+			"side", p.candidate.order.Side.String(),
+			"margin", p.candidate.order.Margin,
+			"fill_price", p.quote.fillPrice,
+			"oracle_price", p.quote.oraclePrice,
+			"priority_fee_micro_lamports", fee,
+			"signature", signature,
+			"batch_size", len(prepared),
+		)
+		s.notifier.Broadcast(Event{
+			Kind:      EventOrderExecuted,
+			Time:      time.Now(),
+			Order:     p.candidate.pubkey,
+			MarketID:  marketID,
+			Message:   fmt.Sprintf("order executed (batch of %d): side=%s fill_price=%d", len(prepared), p.candidate.order.Side.String(), p.quote.fillPrice),
+			Signature: signature,
+		})
+	}
+	return attempted, nil
 }
 
 func (s *Service) getClusterUnixTime(ctx context.Context) int64 {
@@ -824,10 +1274,190 @@ func absInt32(v int32) int32 {
 	return v
 }
 
-func (s *Service) sendTransaction(ctx context.Context, instructions []solana.Instruction) (solana.Signature, error) {
+// maxAdaptivePriorityFeeRetries bounds how many times adaptive mode will
+// bump the priority fee and resend after a confirmation timeout before
+// giving up and surfacing the error like any other mode would.
+const maxAdaptivePriorityFeeRetries = 3
+
+// buildInstructions assembles the standard compute-budget prefix (unit
+// limit, then unit price if non-zero) ahead of mainIx, the pattern shared
+// by every keeper transaction.
+func (s *Service) buildInstructions(mainIx solana.Instruction, computeUnitPriceMicroLamports uint64) ([]solana.Instruction, error) {
+	return s.buildBatchInstructions([]solana.Instruction{mainIx}, computeUnitPriceMicroLamports)
+}
+
+// buildBatchInstructions is buildInstructions generalized to several main
+// instructions packed into one transaction, used for batched multi-order
+// execution.
+func (s *Service) buildBatchInstructions(mainIxs []solana.Instruction, computeUnitPriceMicroLamports uint64) ([]solana.Instruction, error) {
+	instructions := make([]solana.Instruction, 0, len(mainIxs)+2)
+	if s.cfg.ComputeUnitLimit > 0 {
+		cuLimitIx, err := computebudget.NewSetComputeUnitLimitInstruction(s.cfg.ComputeUnitLimit).ValidateAndBuild()
+		if err != nil {
+			return nil, fmt.Errorf("build compute unit limit instruction: %w", err)
+		}
+		instructions = append(instructions, cuLimitIx)
+	}
+	if computeUnitPriceMicroLamports > 0 {
+		cuPriceIx, err := computebudget.NewSetComputeUnitPriceInstruction(computeUnitPriceMicroLamports).ValidateAndBuild()
+		if err != nil {
+			return nil, fmt.Errorf("build compute unit price instruction: %w", err)
+		}
+		instructions = append(instructions, cuPriceIx)
+	}
+	return append(instructions, mainIxs...), nil
+}
+
+// resolvePriorityFee returns the compute-unit price (in micro-lamports) to
+// attach to a transaction touching writableAccounts. In static mode it's
+// just the configured ComputeUnitPriceMicroLamports; otherwise it samples
+// getRecentPrioritizationFees over those accounts and picks the configured
+// percentile, clamped to [MinMicroLamports, MaxMicroLamports].
+func (s *Service) resolvePriorityFee(ctx context.Context, writableAccounts []solana.PublicKey) (uint64, error) {
+	if s.cfg.PriorityFee.Strategy == config.PriorityFeeStrategyStatic {
+		return s.cfg.ComputeUnitPriceMicroLamports, nil
+	}
+
+	recent, err := s.rpc.GetRecentPrioritizationFees(ctx, writableAccounts)
+	if err != nil {
+		return 0, fmt.Errorf("getRecentPrioritizationFees: %w", err)
+	}
+	if len(recent) == 0 {
+		return clampPriorityFee(s.cfg.ComputeUnitPriceMicroLamports, s.cfg.PriorityFee), nil
+	}
+
+	fees := make([]uint64, len(recent))
+	for i, sample := range recent {
+		fees[i] = sample.PrioritizationFee
+	}
+	sort.Slice(fees, func(i, j int) bool { return fees[i] < fees[j] })
+
+	idx := len(fees) * s.cfg.PriorityFee.Percentile / 100
+	if idx >= len(fees) {
+		idx = len(fees) - 1
+	}
+	return clampPriorityFee(fees[idx], s.cfg.PriorityFee), nil
+}
+
+func clampPriorityFee(fee uint64, cfg config.PriorityFeeConfig) uint64 {
+	if cfg.MinMicroLamports > 0 && fee < cfg.MinMicroLamports {
+		fee = cfg.MinMicroLamports
+	}
+	if cfg.MaxMicroLamports > 0 && fee > cfg.MaxMicroLamports {
+		fee = cfg.MaxMicroLamports
+	}
+	return fee
+}
+
+// bumpPriorityFee raises fee by multiplierBps (e.g. 15000 = 1.5x),
+// guaranteeing at least a +1 increase so a zero fee can still escalate,
+// and caps the result at maxMicroLamports when one is configured.
+func bumpPriorityFee(fee, multiplierBps, maxMicroLamports uint64) uint64 {
+	bumped, err := mulDivFloor(fee, multiplierBps, bpsDenom)
+	if err != nil || bumped <= fee {
+		bumped = fee + 1
+	}
+	if maxMicroLamports > 0 && bumped > maxMicroLamports {
+		bumped = maxMicroLamports
+	}
+	return bumped
+}
+
+// sendWithPriorityFee resolves the effective priority fee for
+// writableAccounts, then builds (via buildTx), sends, and confirms a
+// transaction. A dropped/expired blockhash always triggers a resend (same
+// logical transaction, fresh blockhash); in adaptive mode a plain
+// confirmation timeout also triggers one, with the fee bumped first. Either
+// kind of retry is capped at maxAdaptivePriorityFeeRetries attempts before
+// the error is surfaced like any other.
+func (s *Service) sendWithPriorityFee(
+	ctx context.Context,
+	writableAccounts []solana.PublicKey,
+	buildTx func(computeUnitPriceMicroLamports uint64) ([]solana.Instruction, error),
+) (solana.Signature, uint64, error) {
+	fee, err := s.resolvePriorityFee(ctx, writableAccounts)
+	if err != nil {
+		return solana.Signature{}, 0, fmt.Errorf("resolve priority fee: %w", err)
+	}
+
+	for attempt := 0; ; attempt++ {
+		instructions, err := buildTx(fee)
+		if err != nil {
+			return solana.Signature{}, 0, err
+		}
+
+		txCtx, cancel := context.WithTimeout(ctx, s.cfg.TxTimeout)
+		signature, lastValidBlockHeight, sendErr := s.sendTransaction(txCtx, instructions)
+		if sendErr != nil {
+			cancel()
+			s.notifier.Broadcast(Event{
+				Kind:    EventTxFailed,
+				Time:    time.Now(),
+				Message: fmt.Sprintf("send transaction failed: %v", sendErr),
+				Err:     sendErr,
+			})
+			return solana.Signature{}, 0, fmt.Errorf("send transaction: %w", sendErr)
+		}
+		s.notifier.Broadcast(Event{
+			Kind:      EventTxSubmitted,
+			Time:      time.Now(),
+			Message:   fmt.Sprintf("transaction submitted with priority fee %d", fee),
+			Signature: signature,
+		})
+
+		waitErr := s.awaitConfirmationOrExpiry(txCtx, signature, lastValidBlockHeight)
+		cancel()
+		if waitErr == nil {
+			s.notifier.Broadcast(Event{
+				Kind:      EventTxConfirmed,
+				Time:      time.Now(),
+				Message:   "transaction confirmed",
+				Signature: signature,
+			})
+			return signature, fee, nil
+		}
+
+		// A dropped/expired blockhash is always worth a rebuild regardless
+		// of priority-fee strategy - the transaction simply can't land
+		// anymore. A plain confirmation timeout is only retried in
+		// adaptive mode, where it's the signal to bump the fee.
+		blockhashExpired := errors.Is(waitErr, errBlockhashExpired)
+		adaptiveTimeout := s.cfg.PriorityFee.Strategy == config.PriorityFeeStrategyAdaptive && errors.Is(waitErr, context.DeadlineExceeded)
+		if (!blockhashExpired && !adaptiveTimeout) || attempt >= maxAdaptivePriorityFeeRetries {
+			s.notifier.Broadcast(Event{
+				Kind:      EventTxFailed,
+				Time:      time.Now(),
+				Message:   fmt.Sprintf("wait confirmation %s: %v", signature, waitErr),
+				Signature: signature,
+				Err:       waitErr,
+			})
+			return solana.Signature{}, 0, fmt.Errorf("wait confirmation %s: %w", signature, waitErr)
+		}
+
+		nextFee := fee
+		if adaptiveTimeout {
+			nextFee = bumpPriorityFee(fee, s.cfg.PriorityFee.AdaptiveMultiplierBps, s.cfg.PriorityFee.MaxMicroLamports)
+		}
+		s.logger.Warn("transaction not confirmed, retrying with a fresh blockhash",
+			"signature", signature,
+			"attempt", attempt+1,
+			"reason", waitErr,
+			"previous_fee_micro_lamports", fee,
+			"next_fee_micro_lamports", nextFee,
+		)
+		fee = nextFee
+	}
+}
+
+// sendTransaction signs and submits instructions against a freshly fetched
+// blockhash, returning alongside the signature the block height at which
+// that blockhash expires (GetLatestBlockhash's LastValidBlockHeight), so
+// callers can tell a transaction that can no longer land from one that's
+// merely slow to confirm.
+func (s *Service) sendTransaction(ctx context.Context, instructions []solana.Instruction) (solana.Signature, uint64, error) {
 	recent, err := s.rpc.GetLatestBlockhash(ctx, s.cfg.Commitment)
 	if err != nil {
-		return solana.Signature{}, fmt.Errorf("get latest blockhash: %w", err)
+		return solana.Signature{}, 0, fmt.Errorf("get latest blockhash: %w", err)
 	}
 
 	tx, err := solana.NewTransaction(
@@ -836,7 +1466,7 @@ func (s *Service) sendTransaction(ctx context.Context, instructions []solana.Ins
 		solana.TransactionPayer(s.signer.PublicKey()),
 	)
 	if err != nil {
-		return solana.Signature{}, fmt.Errorf("build transaction: %w", err)
+		return solana.Signature{}, 0, fmt.Errorf("build transaction: %w", err)
 	}
 
 	_, err = tx.Sign(func(key solana.PublicKey) *solana.PrivateKey {
@@ -846,7 +1476,7 @@ func (s *Service) sendTransaction(ctx context.Context, instructions []solana.Ins
 		return nil
 	})
 	if err != nil {
-		return solana.Signature{}, fmt.Errorf("sign transaction: %w", err)
+		return solana.Signature{}, 0, fmt.Errorf("sign transaction: %w", err)
 	}
 
 	opts := rpc.TransactionOpts{
@@ -860,9 +1490,49 @@ func (s *Service) sendTransaction(ctx context.Context, instructions []solana.Ins
 
 	sig, err := s.rpc.SendTransactionWithOpts(ctx, tx, opts)
 	if err != nil {
-		return solana.Signature{}, err
+		return solana.Signature{}, 0, err
+	}
+	return sig, recent.Value.LastValidBlockHeight, nil
+}
+
+// awaitConfirmationOrExpiry waits for sig via waitForConfirmation, racing it
+// against a periodic check of the cluster's current block height: once
+// that height passes lastValidBlockHeight, the blockhash the transaction
+// was built against can no longer land, so there's no point waiting out
+// the rest of ctx's deadline for a signature that will never confirm.
+// sendWithPriorityFee treats errBlockhashExpired as always worth a rebuild
+// (fresh blockhash, same or bumped fee depending on strategy), unlike a
+// plain confirmation timeout which is only retried in adaptive mode.
+func (s *Service) awaitConfirmationOrExpiry(ctx context.Context, sig solana.Signature, lastValidBlockHeight uint64) error {
+	confirmCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	result := make(chan error, 1)
+	go func() { result <- s.waitForConfirmation(confirmCtx, sig) }()
+
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case err := <-result:
+			return err
+		case <-ctx.Done():
+			cancel()
+			<-result
+			return ctx.Err()
+		case <-ticker.C:
+			height, err := s.rpc.GetBlockHeight(ctx, s.cfg.Commitment)
+			if err != nil {
+				continue
+			}
+			if height > lastValidBlockHeight {
+				cancel()
+				<-result
+				return errBlockhashExpired
+			}
+		}
 	}
-	return sig, nil
 }
 
 func newCancelOrderByExecutorInstruction(
@@ -900,34 +1570,6 @@ func anchorInstructionDiscriminator(ixName string) [8]byte {
 	return out
 }
 
-func (s *Service) waitForConfirmation(ctx context.Context, sig solana.Signature) error {
-	ticker := time.NewTicker(700 * time.Millisecond)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case <-ticker.C:
-			result, err := s.rpc.GetSignatureStatuses(ctx, true, sig)
-			if err != nil {
-				continue
-			}
-			if len(result.Value) == 0 || result.Value[0] == nil {
-				continue
-			}
-			status := result.Value[0]
-			if status.Err != nil {
-				return fmt.Errorf("transaction failed: %v", status.Err)
-			}
-			if status.ConfirmationStatus == rpc.ConfirmationStatusConfirmed ||
-				status.ConfirmationStatus == rpc.ConfirmationStatusFinalized {
-				return nil
-			}
-		}
-	}
-}
-
 type projectedFill struct {
 	notional  uint64
 	oi        uint64
@@ -937,6 +1579,16 @@ type projectedFill struct {
 	lower     uint64
 }
 
+const (
+	// fillSolverEpsilonBps bounds how close a candidate fill must land to
+	// f(fill) - the upper/lower guardrail projectFill implies for that
+	// candidate - before solveFixedPoint accepts it as converged. It's
+	// expressed in bps of the oracle price so the absolute tolerance
+	// scales with the asset's own price magnitude.
+	fillSolverEpsilonBps    = 1
+	maxFillSolverIterations = 64
+)
+
 func calculateFillPrice(
 	order *orderengine.Order,
 	market *orderengine.Market,
@@ -965,31 +1617,56 @@ func calculateFillPrice(
 		return fill, nil
 	}
 
-	fill := oraclePrice
-	for i := 0; i < 8; i++ {
-		projection, err := projectFill(order, market, funding, oraclePrice, fill)
+	maxDeviationBps := uint64(market.PricingParams.MaxFillDeviationBps)
+	var lo, hi uint64
+	var err error
+	switch order.Side {
+	case orderengine.Side_Buy:
+		lo = oraclePrice
+		hi, err = mulDivFloor(oraclePrice, bpsDenom+maxDeviationBps, bpsDenom)
+	case orderengine.Side_Sell:
+		if maxDeviationBps > bpsDenom {
+			return 0, fmt.Errorf("invalid max deviation bps")
+		}
+		lo, err = mulDivFloor(oraclePrice, bpsDenom-maxDeviationBps, bpsDenom)
+		hi = oraclePrice
+	default:
+		return 0, fmt.Errorf("unknown side")
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	evaluate := func(candidate uint64) (uint64, error) {
+		projection, err := projectFill(order, market, funding, oraclePrice, candidate)
 		if err != nil {
 			return 0, err
 		}
-
-		nextFill := fill
 		switch order.Side {
 		case orderengine.Side_Buy:
-			if fill < projection.upper {
-				nextFill = projection.upper
-			}
+			return projection.upper, nil
 		case orderengine.Side_Sell:
-			if fill > projection.lower {
-				nextFill = projection.lower
-			}
+			return projection.lower, nil
 		default:
 			return 0, fmt.Errorf("unknown side")
 		}
+	}
 
-		if nextFill == fill {
-			break
-		}
-		fill = nextFill
+	seed, err := seedFillPrice(order, market, funding, oraclePrice)
+	if err != nil {
+		return 0, err
+	}
+	epsilon, err := mulDivFloor(oraclePrice, fillSolverEpsilonBps, bpsDenom)
+	if err != nil {
+		return 0, err
+	}
+	if epsilon == 0 {
+		epsilon = 1
+	}
+
+	fill, err := solveFixedPoint(seed, lo, hi, epsilon, evaluate)
+	if err != nil {
+		return 0, err
 	}
 
 	projection, err := projectFill(order, market, funding, oraclePrice, fill)
@@ -1038,12 +1715,32 @@ func calculateFillPrice(
 		return 0, fmt.Errorf("unknown side")
 	}
 
+	if order.TimeInForce == orderengine.TimeInForce_PostOnly {
+		switch side {
+		case orderengine.Side_Buy:
+			if fill >= projection.upper {
+				return 0, fmt.Errorf("post-only buy would cross: fill=%d upper=%d", fill, projection.upper)
+			}
+		case orderengine.Side_Sell:
+			if fill <= projection.lower {
+				return 0, fmt.Errorf("post-only sell would cross: fill=%d lower=%d", fill, projection.lower)
+			}
+		}
+	}
+
 	if fill == 0 {
 		return 0, fmt.Errorf("fill resolved to zero")
 	}
 	return fill, nil
 }
 
+// projectFill projects the order's effect on open interest and skew at a
+// candidate fill price, and derives the price-impact spread that implies.
+// OpenInterest/Skew are tracked on-chain in base-asset units, while
+// order.Margin is the trader's requested notional in quote terms, so
+// converting one into the other - and therefore impactBps itself - is a
+// genuine function of fill, not the price-independent approximation the
+// previous "_ = fill" left in its place.
 func projectFill(
 	order *orderengine.Order,
 	market *orderengine.Market,
@@ -1051,43 +1748,40 @@ func projectFill(
 	oraclePrice uint64,
 	fill uint64,
 ) (*projectedFill, error) {
-	_ = fill
+	if fill == 0 {
+		return nil, fmt.Errorf("fill price must be > 0")
+	}
 	notional := order.Margin
 	if notional == 0 {
 		return nil, fmt.Errorf("notional resolved to zero")
 	}
-	projectedOI := funding.OpenInterest + notional
+	size, err := mulDivFloor(notional, priceScale, fill)
+	if err != nil {
+		return nil, err
+	}
+	if size == 0 {
+		return nil, fmt.Errorf("projected size resolved to zero")
+	}
+	projectedOI := funding.OpenInterest + size
 	if projectedOI == 0 {
 		return nil, fmt.Errorf("projected open interest is zero")
 	}
 
 	projectedSkew := new(big.Int).Set(funding.Skew.BigInt())
-	notionalBig := new(big.Int).SetUint64(notional)
+	sizeBig := new(big.Int).SetUint64(size)
 	switch order.Side {
 	case orderengine.Side_Buy:
-		projectedSkew.Add(projectedSkew, notionalBig)
+		projectedSkew.Add(projectedSkew, sizeBig)
 	case orderengine.Side_Sell:
-		projectedSkew.Sub(projectedSkew, notionalBig)
+		projectedSkew.Sub(projectedSkew, sizeBig)
 	default:
 		return nil, fmt.Errorf("unknown side")
 	}
 
-	absSkew := absBigInt(projectedSkew)
-	skewRatioBpsBig := new(big.Int).Mul(absSkew, new(big.Int).SetUint64(bpsDenom))
-	skewRatioBpsBig.Div(skewRatioBpsBig, new(big.Int).SetUint64(projectedOI))
-	if !skewRatioBpsBig.IsUint64() {
-		return nil, fmt.Errorf("skew ratio overflow")
-	}
-	skewRatioBps := skewRatioBpsBig.Uint64()
-
-	skewImpactBps, err := mulDivFloor(uint64(market.PricingParams.SkewCoeffBps), skewRatioBps, bpsDenom)
+	impactBps, err := computeImpactBps(projectedSkew, projectedOI, uint64(market.PricingParams.BaseSpreadBps), uint64(market.PricingParams.SkewCoeffBps))
 	if err != nil {
 		return nil, err
 	}
-	impactBps := uint64(market.PricingParams.BaseSpreadBps) + skewImpactBps
-	if impactBps >= bpsDenom {
-		return nil, fmt.Errorf("impact bps too large")
-	}
 
 	upper, err := mulDivFloor(oraclePrice, bpsDenom+impactBps, bpsDenom)
 	if err != nil {
@@ -1108,6 +1802,105 @@ func projectFill(
 	}, nil
 }
 
+// computeImpactBps derives the price-impact spread (in bps above/below
+// oraclePrice) from a projected open-interest/skew pair. It's split out of
+// projectFill's order_engine-specific plumbing so the monotonicity of
+// impact in skew/notional can be exercised directly in tests.
+func computeImpactBps(projectedSkew *big.Int, projectedOI, baseSpreadBps, skewCoeffBps uint64) (uint64, error) {
+	if projectedOI == 0 {
+		return 0, fmt.Errorf("projected open interest is zero")
+	}
+
+	absSkew := absBigInt(projectedSkew)
+	skewRatioBpsBig := new(big.Int).Mul(absSkew, new(big.Int).SetUint64(bpsDenom))
+	skewRatioBpsBig.Div(skewRatioBpsBig, new(big.Int).SetUint64(projectedOI))
+	if !skewRatioBpsBig.IsUint64() {
+		return 0, fmt.Errorf("skew ratio overflow")
+	}
+	skewRatioBps := skewRatioBpsBig.Uint64()
+
+	skewImpactBps, err := mulDivFloor(skewCoeffBps, skewRatioBps, bpsDenom)
+	if err != nil {
+		return 0, err
+	}
+	impactBps := baseSpreadBps + skewImpactBps
+	if impactBps >= bpsDenom {
+		return 0, fmt.Errorf("impact bps too large")
+	}
+	return impactBps, nil
+}
+
+// seedFillPrice computes a zeroth-order guess for calculateFillPrice's
+// fixed point by evaluating projectFill at the oracle price itself (i.e.
+// size = notional/oraclePrice, ignoring the fill-price feedback on size).
+// It's exact for a quote-denominated OI/skew model (where impactBps truly
+// doesn't depend on fill), and is otherwise just a starting bracket point
+// for solveFixedPoint to refine - deriving the exact closed-form root of
+// the base-denominated model's quadratic was judged too fragile to get
+// right against floor/abs rounding without the on-chain engine to check
+// against, so bisection carries the correctness guarantee here instead.
+func seedFillPrice(
+	order *orderengine.Order,
+	market *orderengine.Market,
+	funding *orderengine.MarketFundingState,
+	oraclePrice uint64,
+) (uint64, error) {
+	projection, err := projectFill(order, market, funding, oraclePrice, oraclePrice)
+	if err != nil {
+		return 0, err
+	}
+	switch order.Side {
+	case orderengine.Side_Buy:
+		return projection.upper, nil
+	case orderengine.Side_Sell:
+		return projection.lower, nil
+	default:
+		return 0, fmt.Errorf("unknown side")
+	}
+}
+
+// solveFixedPoint bisects for a fill such that evaluate(fill) - the
+// upper/lower guardrail projectFill implies for that candidate - is within
+// epsilon of fill itself. lo and hi bracket the search (the oracle price
+// and the side's deviation guardrail); g(x) = evaluate(x) - x is assumed
+// monotonically decreasing across [lo, hi], which holds for this market
+// model since a higher candidate fill both reduces the projected size
+// (and so the impact evaluate derives from it) and directly increases the
+// "-x" term.
+func solveFixedPoint(seed, lo, hi, epsilon uint64, evaluate func(uint64) (uint64, error)) (uint64, error) {
+	fill := seed
+	if fill < lo {
+		fill = lo
+	}
+	if fill > hi {
+		fill = hi
+	}
+
+	for i := 0; i < maxFillSolverIterations; i++ {
+		target, err := evaluate(fill)
+		if err != nil {
+			return 0, err
+		}
+		if absDiffUint64(target, fill) <= epsilon {
+			return target, nil
+		}
+		if target > fill {
+			lo = fill
+		} else {
+			hi = fill
+		}
+		fill = lo + (hi-lo)/2
+	}
+	return 0, errFillNotConverged
+}
+
+func absDiffUint64(a, b uint64) uint64 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
 func mulDivFloor(a, b, denominator uint64) (uint64, error) {
 	if denominator == 0 {
 		return 0, fmt.Errorf("division by zero")
@@ -0,0 +1,151 @@
+package keeper
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	orderengine "github.com/coldbell/dex/backend/internal/anchor/order_engine"
+)
+
+// trailingStopTier pairs one activation ratio with the callback rate that
+// becomes active once a position's favorable move (relative to the price
+// first observed for it) reaches that ratio. Tiers model the multi-level
+// trailing stop shape used by drift/pivotshort-style strategies: price
+// moving further in the position's favor can arm a wider or narrower
+// callback depending on how the tier is configured, not necessarily a
+// monotonically tighter one.
+type trailingStopTier struct {
+	activation float64
+	callback   float64
+}
+
+// trailingTiersFromConfig zips KeeperConfig's TrailingActivationRatio and
+// TrailingCallbackRate into tiers sorted by ascending activation. The two
+// arrays are truncated to the shorter length when configured unevenly,
+// rather than erroring, since a keeper misconfigured this way should keep
+// executing orders with whatever tiers it can build rather than refuse to
+// start.
+func trailingTiersFromConfig(activation, callback []float64) []trailingStopTier {
+	n := len(activation)
+	if len(callback) < n {
+		n = len(callback)
+	}
+	tiers := make([]trailingStopTier, n)
+	for i := 0; i < n; i++ {
+		tiers[i] = trailingStopTier{activation: activation[i], callback: callback[i]}
+	}
+	sort.Slice(tiers, func(i, j int) bool { return tiers[i].activation < tiers[j].activation })
+	return tiers
+}
+
+// trailingStopState is one position's trailing-stop bookkeeping: the price
+// first observed for it (its reference "entry" for the purpose of this
+// tracker) and the most favorable price seen since.
+type trailingStopState struct {
+	reference float64
+	peak      float64
+}
+
+// trailingStopTracker tracks, per position key, the best oracle price seen
+// since this tracker first started watching that position and decides
+// whether a configured multi-tier trailing stop should fire. State lives
+// only in process memory and is reset if the keeper restarts - "since
+// entry" here means since the keeper first observed the position, not
+// since the position was opened on-chain.
+type trailingStopTracker struct {
+	mu     sync.Mutex
+	states map[trailingStopKey]*trailingStopState
+}
+
+// trailingStopKey identifies one position's trailing-stop state. Using the
+// user position PDA pubkey plus the side being trailed keeps a flip from
+// long to short (or a fresh position reusing the same PDA after a close)
+// from inheriting a stale peak.
+type trailingStopKey struct {
+	position string
+	long     bool
+}
+
+func newTrailingStopTracker() *trailingStopTracker {
+	return &trailingStopTracker{states: make(map[trailingStopKey]*trailingStopState)}
+}
+
+// reset drops tracked state for position, e.g. once it's fully closed.
+func (t *trailingStopTracker) reset(position string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.states, trailingStopKey{position: position, long: true})
+	delete(t.states, trailingStopKey{position: position, long: false})
+}
+
+// update records currentPrice for position (long selects whether favorable
+// means "price went up" or "price went down") and reports whether tiers
+// calls for a trailing-stop trigger given the new peak. A freshly observed
+// position anchors its reference price to currentPrice, so the first call
+// for any given position never triggers.
+func (t *trailingStopTracker) update(position string, long bool, currentPrice float64, tiers []trailingStopTier) (triggered bool, activeCallback float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := trailingStopKey{position: position, long: long}
+	state, ok := t.states[key]
+	if !ok {
+		state = &trailingStopState{reference: currentPrice, peak: currentPrice}
+		t.states[key] = state
+		return false, 0
+	}
+
+	if favorableMove(long, state.reference, currentPrice) > favorableMove(long, state.reference, state.peak) {
+		state.peak = currentPrice
+	}
+
+	return evaluateTrailingStop(long, state.reference, state.peak, currentPrice, tiers)
+}
+
+// favorableMove returns how far price has moved from reference in the
+// direction that benefits a long (price up) or short (price down)
+// position. A negative result means price moved against the position.
+func favorableMove(long bool, reference, price float64) float64 {
+	if long {
+		return price - reference
+	}
+	return reference - price
+}
+
+// evaluateTrailingStop is the pure decision at the heart of the trailing
+// stop: given the reference price, the best (most favorable) price seen
+// since, the current price, and the configured tiers, it selects whichever
+// tier's activation ratio the position's favorable move from reference has
+// reached - the highest such tier, per "walk the activation array in
+// increasing order to select the currently active callback" - and reports
+// whether the retracement from peak back to current has crossed that
+// tier's callback rate.
+func evaluateTrailingStop(long bool, reference, peak, current float64, tiers []trailingStopTier) (triggered bool, activeCallback float64) {
+	if reference == 0 || peak == 0 {
+		return false, 0
+	}
+
+	peakMove := favorableMove(long, reference, peak)
+	armed := -1
+	for i, tier := range tiers {
+		if peakMove/reference >= tier.activation {
+			armed = i
+		}
+	}
+	if armed < 0 {
+		return false, 0
+	}
+	activeCallback = tiers[armed].callback
+
+	retrace := favorableMove(long, current, peak) / peak
+	return retrace >= activeCallback, activeCallback
+}
+
+// positionIsLong reports whether side reads as a long position. It goes
+// through Side.String() rather than comparing against an orderengine
+// constant since the engine's Side enum values aren't otherwise referenced
+// by name in this package.
+func positionIsLong(side orderengine.Side) bool {
+	return strings.EqualFold(side.String(), "long")
+}
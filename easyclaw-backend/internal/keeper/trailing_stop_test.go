@@ -0,0 +1,128 @@
+package keeper
+
+import "testing"
+
+func TestTrailingTiersFromConfig(t *testing.T) {
+	t.Run("sorts by ascending activation", func(t *testing.T) {
+		tiers := trailingTiersFromConfig([]float64{0.004, 0.001, 0.002}, []float64{0.002, 0.0005, 0.0008})
+		want := []float64{0.001, 0.002, 0.004}
+		for i, tier := range tiers {
+			if tier.activation != want[i] {
+				t.Fatalf("tiers[%d].activation = %v, want %v", i, tier.activation, want[i])
+			}
+		}
+	})
+
+	t.Run("unequal length arrays truncate to the shorter one", func(t *testing.T) {
+		tiers := trailingTiersFromConfig([]float64{0.001, 0.002, 0.004}, []float64{0.0005, 0.0008})
+		if len(tiers) != 2 {
+			t.Fatalf("len(tiers) = %d, want 2", len(tiers))
+		}
+
+		tiers = trailingTiersFromConfig([]float64{0.001}, []float64{0.0005, 0.0008, 0.002})
+		if len(tiers) != 1 {
+			t.Fatalf("len(tiers) = %d, want 1", len(tiers))
+		}
+	})
+
+	t.Run("empty arrays produce no tiers", func(t *testing.T) {
+		if tiers := trailingTiersFromConfig(nil, nil); len(tiers) != 0 {
+			t.Fatalf("len(tiers) = %d, want 0", len(tiers))
+		}
+	})
+}
+
+func TestTrailingStopTrackerTierProgression(t *testing.T) {
+	tiers := trailingTiersFromConfig(
+		[]float64{0.001, 0.002, 0.004},
+		[]float64{0.0005, 0.0008, 0.002},
+	)
+	tracker := newTrailingStopTracker()
+
+	// First observation anchors the reference price; it can never trigger.
+	if triggered, _ := tracker.update("pos-1", true, 100.0, tiers); triggered {
+		t.Fatalf("first observation triggered a trailing stop")
+	}
+
+	// Price moves favorably past the first activation ratio (0.001 -> 100.1)
+	// but not far enough to retrace past its 0.0005 callback.
+	triggered, callback := tracker.update("pos-1", true, 100.1, tiers)
+	if triggered {
+		t.Fatalf("unexpected trigger at peak with no retracement")
+	}
+	if callback != 0.0005 {
+		t.Fatalf("active callback = %v, want tier 0's 0.0005", callback)
+	}
+
+	// Price keeps moving favorably past the second and third activation
+	// ratios; the active callback should track the highest tier reached.
+	_, callback = tracker.update("pos-1", true, 100.2, tiers)
+	if callback != 0.0008 {
+		t.Fatalf("active callback = %v, want tier 1's 0.0008 at peak move 0.002", callback)
+	}
+	_, callback = tracker.update("pos-1", true, 100.4, tiers)
+	if callback != 0.002 {
+		t.Fatalf("active callback = %v, want tier 2's 0.002 at peak move 0.004", callback)
+	}
+
+	// Retrace from the 100.4 peak by more than the active 0.002 callback.
+	triggered, callback = tracker.update("pos-1", true, 100.0, tiers)
+	if !triggered {
+		t.Fatalf("expected trailing stop to trigger on retracement past callback %v", callback)
+	}
+}
+
+func TestTrailingStopTrackerDisarmOnNewPeak(t *testing.T) {
+	tiers := trailingTiersFromConfig(
+		[]float64{0.001},
+		[]float64{0.0005},
+	)
+	tracker := newTrailingStopTracker()
+
+	tracker.update("pos-1", true, 100.0, tiers)
+	tracker.update("pos-1", true, 100.2, tiers) // arms tier 0, peak = 100.2
+
+	// A new peak should reset the retracement reference: right at the new
+	// peak, retrace is zero regardless of how far price has already moved.
+	if triggered, _ := tracker.update("pos-1", true, 100.3, tiers); triggered {
+		t.Fatalf("new peak incorrectly triggered a trailing stop")
+	}
+
+	// Now retrace from the new 100.3 peak enough to cross the callback.
+	if triggered, _ := tracker.update("pos-1", true, 100.25, tiers); triggered {
+		t.Fatalf("small retracement from the new peak should not trigger yet")
+	}
+	if triggered, _ := tracker.update("pos-1", true, 100.2, tiers); !triggered {
+		t.Fatalf("expected trigger after retracing past the callback from the new peak")
+	}
+}
+
+func TestTrailingStopTrackerShortSide(t *testing.T) {
+	tiers := trailingTiersFromConfig([]float64{0.001}, []float64{0.001})
+	tracker := newTrailingStopTracker()
+
+	tracker.update("pos-short", false, 100.0, tiers)
+	// Favorable move for a short is price going down.
+	tracker.update("pos-short", false, 99.8, tiers)
+	// Retrace back up past the callback.
+	if triggered, _ := tracker.update("pos-short", false, 99.95, tiers); !triggered {
+		t.Fatalf("expected short trailing stop to trigger on upward retracement")
+	}
+}
+
+func TestTrailingStopTrackerSeparatesPositionsAndSides(t *testing.T) {
+	tiers := trailingTiersFromConfig([]float64{0.001}, []float64{0.001})
+	tracker := newTrailingStopTracker()
+
+	tracker.update("pos-1", true, 100.0, tiers)
+	tracker.update("pos-2", true, 50.0, tiers)
+
+	if triggered, _ := tracker.update("pos-2", true, 50.5, tiers); triggered {
+		t.Fatalf("pos-2 should still be arming its own tiers independently of pos-1")
+	}
+
+	tracker.reset("pos-1")
+	if triggered, _ := tracker.update("pos-1", true, 200.0, tiers); triggered {
+		t.Fatalf("reset position should start fresh and not trigger on its first observation")
+	}
+}
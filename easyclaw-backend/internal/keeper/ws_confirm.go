@@ -0,0 +1,255 @@
+package keeper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	wsrpc "github.com/gagliardetto/solana-go/rpc/ws"
+)
+
+// errWSUnavailable classifies a failure that should fall back to the
+// polling path (subscribe failed, the connection dropped, recv errored)
+// from one that should propagate as-is (ctx cancellation, or a
+// transaction that genuinely failed on-chain).
+var errWSUnavailable = errors.New("websocket unavailable")
+
+// signatureNotification is the one piece of a signatureSubscribe push this
+// package reads. It's a narrow copy of ws.SignatureResult.Value rather than
+// that type itself, so signatureSubscription (and tests against it) don't
+// have to depend on the solana-go/rpc/ws wire types.
+type signatureNotification struct {
+	err interface{}
+}
+
+// signatureSubscription is the subset of *ws.SignatureSubscription
+// waitForConfirmationWS needs.
+type signatureSubscription interface {
+	Recv(ctx context.Context) (signatureNotification, error)
+	Unsubscribe()
+}
+
+// accountNotification is the one piece of an accountSubscribe push this
+// package reads: the account's raw data, decoded the same way a fetched
+// *rpc.Account's Data is.
+type accountNotification struct {
+	data []byte
+}
+
+// accountSubscription is the subset of *ws.AccountSubscription
+// subscribeOraclePriceLoop needs.
+type accountSubscription interface {
+	Recv(ctx context.Context) (accountNotification, error)
+	Unsubscribe()
+}
+
+// wsClient is the subset of *ws.Client (solana-go/rpc/ws) the keeper's
+// push-based confirmation and oracle-cache paths need, narrowed so tests
+// can substitute a fake that replays a scripted, ordered notification
+// sequence instead of dialing a real RPC node.
+type wsClient interface {
+	SignatureSubscribe(sig solana.Signature, commitment rpc.CommitmentType) (signatureSubscription, error)
+	AccountSubscribe(pubkey solana.PublicKey, commitment rpc.CommitmentType) (accountSubscription, error)
+	Close()
+}
+
+// liveWSClient adapts *ws.Client to wsClient. The solana-go/rpc/ws package
+// isn't vendored in this tree, so the exact shapes of
+// ws.SignatureResult/ws.AccountResult below are transcribed from its public
+// API rather than verified against source - they should be checked against
+// the dependency actually in use before relying on this in production.
+type liveWSClient struct{ inner *wsrpc.Client }
+
+// dialWS opens a solana-go/rpc/ws connection to url, the same client
+// program_subscribe.go's subscribeProgramOnce uses in the indexer package.
+func dialWS(ctx context.Context, url string) (wsClient, error) {
+	inner, err := wsrpc.Connect(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	return liveWSClient{inner: inner}, nil
+}
+
+func (c liveWSClient) SignatureSubscribe(sig solana.Signature, commitment rpc.CommitmentType) (signatureSubscription, error) {
+	sub, err := c.inner.SignatureSubscribe(sig, commitment)
+	if err != nil {
+		return nil, err
+	}
+	return liveSignatureSubscription{inner: sub}, nil
+}
+
+func (c liveWSClient) AccountSubscribe(pubkey solana.PublicKey, commitment rpc.CommitmentType) (accountSubscription, error) {
+	sub, err := c.inner.AccountSubscribe(pubkey, commitment)
+	if err != nil {
+		return nil, err
+	}
+	return liveAccountSubscription{inner: sub}, nil
+}
+
+func (c liveWSClient) Close() { c.inner.Close() }
+
+type liveSignatureSubscription struct{ inner *wsrpc.SignatureSubscription }
+
+func (l liveSignatureSubscription) Recv(ctx context.Context) (signatureNotification, error) {
+	got, err := l.inner.Recv(ctx)
+	if err != nil {
+		return signatureNotification{}, err
+	}
+	return signatureNotification{err: got.Value.Err}, nil
+}
+
+func (l liveSignatureSubscription) Unsubscribe() { l.inner.Unsubscribe() }
+
+type liveAccountSubscription struct{ inner *wsrpc.AccountSubscription }
+
+func (l liveAccountSubscription) Recv(ctx context.Context) (accountNotification, error) {
+	got, err := l.inner.Recv(ctx)
+	if err != nil {
+		return accountNotification{}, err
+	}
+	return accountNotification{data: got.Value.Data.GetBinary()}, nil
+}
+
+func (l liveAccountSubscription) Unsubscribe() { l.inner.Unsubscribe() }
+
+// ConnectWS dials the keeper's WebSocket RPC endpoint (KEEPER_WS_URL) if
+// configured and not already connected, starting the oracle push-price
+// subscriptions over it. It's a no-op when WSURL isn't configured, and safe
+// to call repeatedly - Run retries it on every tick while WSConnected
+// reports false, the same cadence it already polls on.
+func (s *Service) ConnectWS(ctx context.Context) error {
+	if strings.TrimSpace(s.cfg.WSURL) == "" {
+		return nil
+	}
+
+	s.wsMu.Lock()
+	defer s.wsMu.Unlock()
+	if s.wsConn != nil {
+		return nil
+	}
+
+	conn, err := s.wsDial(ctx, s.cfg.WSURL)
+	if err != nil {
+		return fmt.Errorf("connect websocket: %w", err)
+	}
+	s.wsConn = conn
+	go s.runOraclePriceSubscriptions(ctx, conn)
+	return nil
+}
+
+// CloseWS tears down the current WebSocket connection, if any. Safe to call
+// when not connected.
+func (s *Service) CloseWS() {
+	s.wsMu.Lock()
+	defer s.wsMu.Unlock()
+	if s.wsConn == nil {
+		return
+	}
+	s.wsConn.Close()
+	s.wsConn = nil
+}
+
+// WSConnected reports whether a WebSocket connection is currently held
+// open, so Run can tell whether confirmation/oracle subscriptions are live
+// or have fallen back to polling.
+func (s *Service) WSConnected() bool {
+	return s.wsConnSnapshot() != nil
+}
+
+func (s *Service) wsConnSnapshot() wsClient {
+	s.wsMu.Lock()
+	defer s.wsMu.Unlock()
+	return s.wsConn
+}
+
+// markWSDown drops the tracked connection if it's still the one conn
+// points at (a later ConnectWS/CloseWS may already have replaced it), so a
+// broken signature subscription doesn't close a connection some other
+// caller already reconnected.
+func (s *Service) markWSDown(conn wsClient) {
+	s.wsMu.Lock()
+	defer s.wsMu.Unlock()
+	if s.wsConn == conn {
+		s.wsConn.Close()
+		s.wsConn = nil
+	}
+}
+
+// waitForConfirmationWS waits for sig to confirm via a signatureSubscribe
+// push on conn instead of polling GetSignatureStatuses, returning as soon
+// as the RPC node pushes the notification. A subscribe or recv failure
+// (connection dropped, etc.) is wrapped in errWSUnavailable so the caller
+// knows to fall back to polling instead of treating it as a confirmed
+// transaction failure.
+func (s *Service) waitForConfirmationWS(ctx context.Context, conn wsClient, sig solana.Signature) error {
+	sub, err := conn.SignatureSubscribe(sig, s.cfg.Commitment)
+	if err != nil {
+		return fmt.Errorf("%w: subscribe: %v", errWSUnavailable, err)
+	}
+	defer sub.Unsubscribe()
+
+	notification, err := sub.Recv(ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("%w: recv: %v", errWSUnavailable, err)
+	}
+	if notification.err != nil {
+		return fmt.Errorf("transaction failed: %v", notification.err)
+	}
+	return nil
+}
+
+// waitForConfirmation waits for sig to land, preferring a push-based
+// signatureSubscribe over conn when a WebSocket connection is up and
+// falling back to the pre-existing GetSignatureStatuses poll when it
+// isn't (or when the subscription itself breaks mid-wait).
+func (s *Service) waitForConfirmation(ctx context.Context, sig solana.Signature) error {
+	if conn := s.wsConnSnapshot(); conn != nil {
+		err := s.waitForConfirmationWS(ctx, conn, sig)
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+		if !errors.Is(err, errWSUnavailable) {
+			return err
+		}
+		s.logger.Warn("signature subscription unavailable, falling back to polling", "signature", sig, "err", err)
+		s.markWSDown(conn)
+	}
+	return s.waitForConfirmationPoll(ctx, sig)
+}
+
+// waitForConfirmationPoll is the original 700ms GetSignatureStatuses poll,
+// kept as the fallback path for when no WebSocket connection is available.
+func (s *Service) waitForConfirmationPoll(ctx context.Context, sig solana.Signature) error {
+	ticker := time.NewTicker(700 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			result, err := s.rpc.GetSignatureStatuses(ctx, true, sig)
+			if err != nil {
+				continue
+			}
+			if len(result.Value) == 0 || result.Value[0] == nil {
+				continue
+			}
+			status := result.Value[0]
+			if status.Err != nil {
+				return fmt.Errorf("transaction failed: %v", status.Err)
+			}
+			if status.ConfirmationStatus == rpc.ConfirmationStatusConfirmed ||
+				status.ConfirmationStatus == rpc.ConfirmationStatusFinalized {
+				return nil
+			}
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package keeper
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// fakeSignatureSubscription replays a scripted, ordered sequence of
+// notifications the same way a real *ws.SignatureSubscription would push
+// them one at a time off the wire, or returns recvErr once the sequence is
+// exhausted.
+type fakeSignatureSubscription struct {
+	notifications []signatureNotification
+	recvErr       error
+	unsubscribed  bool
+}
+
+func (f *fakeSignatureSubscription) Recv(ctx context.Context) (signatureNotification, error) {
+	if len(f.notifications) > 0 {
+		next := f.notifications[0]
+		f.notifications = f.notifications[1:]
+		return next, nil
+	}
+	if f.recvErr != nil {
+		return signatureNotification{}, f.recvErr
+	}
+	<-ctx.Done()
+	return signatureNotification{}, ctx.Err()
+}
+
+func (f *fakeSignatureSubscription) Unsubscribe() { f.unsubscribed = true }
+
+// fakeWSClient is a minimal wsClient double exercising only the
+// signature-subscribe path waitForConfirmation drives; AccountSubscribe
+// isn't exercised by these tests.
+type fakeWSClient struct {
+	sub    *fakeSignatureSubscription
+	subErr error
+}
+
+func (f fakeWSClient) SignatureSubscribe(sig solana.Signature, commitment rpc.CommitmentType) (signatureSubscription, error) {
+	if f.subErr != nil {
+		return nil, f.subErr
+	}
+	return f.sub, nil
+}
+
+func (f fakeWSClient) AccountSubscribe(pubkey solana.PublicKey, commitment rpc.CommitmentType) (accountSubscription, error) {
+	return nil, errors.New("not implemented in this fake")
+}
+
+func (f fakeWSClient) Close() {}
+
+func TestWaitForConfirmationWS_Success(t *testing.T) {
+	svc := &Service{logger: discardLogger()}
+	sub := &fakeSignatureSubscription{notifications: []signatureNotification{{err: nil}}}
+
+	if err := svc.waitForConfirmationWS(context.Background(), fakeWSClient{sub: sub}, solana.Signature{}); err != nil {
+		t.Fatalf("waitForConfirmationWS: %v", err)
+	}
+	if !sub.unsubscribed {
+		t.Fatalf("expected Unsubscribe to be called")
+	}
+}
+
+func TestWaitForConfirmationWS_TransactionFailed(t *testing.T) {
+	svc := &Service{logger: discardLogger()}
+	sub := &fakeSignatureSubscription{notifications: []signatureNotification{{err: "InstructionError"}}}
+
+	err := svc.waitForConfirmationWS(context.Background(), fakeWSClient{sub: sub}, solana.Signature{})
+	if err == nil {
+		t.Fatalf("expected an error, got nil")
+	}
+	if errors.Is(err, errWSUnavailable) {
+		t.Fatalf("a confirmed-but-failed transaction should not be classified as errWSUnavailable: %v", err)
+	}
+}
+
+func TestWaitForConfirmationWS_SubscribeFailureIsWSUnavailable(t *testing.T) {
+	svc := &Service{logger: discardLogger()}
+
+	err := svc.waitForConfirmationWS(context.Background(), fakeWSClient{subErr: errors.New("dial closed")}, solana.Signature{})
+	if !errors.Is(err, errWSUnavailable) {
+		t.Fatalf("expected errWSUnavailable, got %v", err)
+	}
+}
+
+func TestWaitForConfirmationWS_RecvFailureIsWSUnavailable(t *testing.T) {
+	svc := &Service{logger: discardLogger()}
+	sub := &fakeSignatureSubscription{recvErr: errors.New("connection reset")}
+
+	err := svc.waitForConfirmationWS(context.Background(), fakeWSClient{sub: sub}, solana.Signature{})
+	if !errors.Is(err, errWSUnavailable) {
+		t.Fatalf("expected errWSUnavailable, got %v", err)
+	}
+}
+
+// TestWaitForConfirmation_FallsBackToPollingOnWSFailure checks that a
+// broken subscribe falls through to waitForConfirmationPoll (rather than
+// surfacing errWSUnavailable to the caller) and marks the connection down.
+// It uses an already-short-lived context so the poll loop's first select
+// hits ctx.Done() well before its 700ms ticker would ever fire, keeping
+// the test fast without needing a real RPC endpoint.
+func TestWaitForConfirmation_FallsBackToPollingOnWSFailure(t *testing.T) {
+	svc := &Service{logger: discardLogger(), rpc: rpc.New("http://127.0.0.1:1")}
+	svc.wsConn = fakeWSClient{subErr: errors.New("dial closed")}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+
+	err := svc.waitForConfirmation(ctx, solana.Signature{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the poll fallback's context deadline to surface, got %v", err)
+	}
+	if svc.WSConnected() {
+		t.Fatalf("expected the broken connection to be marked down")
+	}
+}
+
+func TestOracleWSCache_GetSetAndStaleness(t *testing.T) {
+	cache := newOracleWSCache()
+	pubkey := solana.NewWallet().PublicKey()
+
+	if _, ok := cache.get(pubkey, time.Minute); ok {
+		t.Fatalf("expected a miss before anything is cached")
+	}
+
+	cache.set(pubkey, &oracleSnapshot{price: 42})
+	snapshot, ok := cache.get(pubkey, time.Minute)
+	if !ok || snapshot.price != 42 {
+		t.Fatalf("get() = (%v, %v), want (price=42, true)", snapshot, ok)
+	}
+
+	if _, ok := cache.get(pubkey, 0); ok {
+		t.Fatalf("expected a miss once maxAge has elapsed")
+	}
+}
@@ -0,0 +1,65 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+)
+
+// loggerCtxKey is the context.Context key under which Inject stores the
+// decorated logger. It's an unexported type so only this package can set
+// or look it up.
+type loggerCtxKey struct{}
+
+// With returns the logger Inject has decorated onto ctx so far, or
+// slog.Default() if ctx carries none (e.g. a background goroutine with no
+// request in flight). Call it instead of threading a *slog.Logger through
+// every function signature: handlers and Store list methods can both just
+// call logging.With(ctx).Info(...) and get whatever request_id, trace_id,
+// span_id, and user_margin the caller has injected so far.
+func With(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerCtxKey{}).(*slog.Logger); ok && logger != nil {
+		return logger
+	}
+	return slog.Default()
+}
+
+// IntoContext seeds ctx with logger verbatim, replacing whatever Inject may
+// have already decorated onto it. Use it once, at the root of a context
+// tree (e.g. http.Server.BaseContext), to anchor request-scoped logging on
+// the service's own logger (with its "service" attr) instead of the
+// zero-value slog.Default() With(ctx) would otherwise fall back to.
+func IntoContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerCtxKey{}, logger)
+}
+
+// Inject decorates the logger already attached to ctx (With(ctx), falling
+// back to slog.Default()) with fields and stores the result back onto ctx.
+// It's layered rather than one-shot: a request-logging middleware injects
+// request_id/trace_id/span_id up front, and a later auth step can inject
+// user_margin once the session resolves, without either call clobbering
+// the other's fields.
+func Inject(ctx context.Context, fields ...any) context.Context {
+	logger := With(ctx).With(fields...)
+	return context.WithValue(ctx, loggerCtxKey{}, logger)
+}
+
+// ParseTraceparent extracts the trace ID and span ID from a W3C Trace
+// Context "traceparent" header (version-traceid-spanid-flags, e.g.
+// "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"). It reports
+// ok=false for an empty or malformed header rather than erroring, since a
+// missing/invalid traceparent just means "no upstream trace to join".
+func ParseTraceparent(header string) (traceID string, spanID string, ok bool) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) != 4 {
+		return "", "", false
+	}
+	version, traceID, spanID, flags := parts[0], parts[1], parts[2], parts[3]
+	if len(version) != 2 || len(traceID) != 32 || len(spanID) != 16 || len(flags) != 2 {
+		return "", "", false
+	}
+	if traceID == strings.Repeat("0", 32) || spanID == strings.Repeat("0", 16) {
+		return "", "", false
+	}
+	return traceID, spanID, true
+}
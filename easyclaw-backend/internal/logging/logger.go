@@ -11,15 +11,20 @@ import (
 	"github.com/coldbell/dex/backend/internal/config"
 )
 
-func New(serviceName string, cfg config.LogConfig) (*slog.Logger, func() error, error) {
+// New builds a slog.Logger for serviceName. Alongside the logger it
+// returns a closeFn (flushes and closes the underlying writer, waiting
+// for any pending rotation/compression work) and a reopenFn (reopens the
+// primary log file in place, for SIGHUP-driven logrotate cooperation).
+// Both are no-ops when the configured output is console-only.
+func New(serviceName string, cfg config.LogConfig) (*slog.Logger, func() error, func() error, error) {
 	level, err := parseLevel(cfg.Level)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 
-	writer, closeWriter, err := openWriter(serviceName, cfg)
+	writer, closeWriter, reopenWriter, err := openWriter(serviceName, cfg)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 
 	handlerOptions := &slog.HandlerOptions{Level: level}
@@ -36,55 +41,57 @@ func New(serviceName string, cfg config.LogConfig) (*slog.Logger, func() error,
 		handler = slog.NewJSONHandler(writer, handlerOptions)
 	default:
 		_ = closeWriter()
-		return nil, nil, fmt.Errorf("invalid log format %q (expected text|json)", cfg.Format)
+		return nil, nil, nil, fmt.Errorf("invalid log format %q (expected text|json)", cfg.Format)
 	}
 
 	logger := slog.New(handler).With("service", serviceName)
-	return logger, closeWriter, nil
+	return logger, closeWriter, reopenWriter, nil
 }
 
-func openWriter(serviceName string, cfg config.LogConfig) (io.Writer, func() error, error) {
+// NewRotatingFile opens (creating its directory if needed) a rotating,
+// size/time-bounded file at path per cfg, for callers outside this package
+// that want the same rotation/pruning/compression behavior as New's file
+// output but are writing their own structured records rather than slog
+// lines (e.g. a JSON-lines event sink).
+func NewRotatingFile(path string, cfg config.LogConfig) (io.WriteCloser, error) {
+	return newRotatingWriter(path, cfg)
+}
+
+func openWriter(serviceName string, cfg config.LogConfig) (io.Writer, func() error, func() error, error) {
 	output := strings.ToLower(strings.TrimSpace(cfg.Output))
 	if output == "" {
 		output = "console"
 	}
 
+	noopReopen := func() error { return nil }
+
 	switch output {
 	case "console":
-		return os.Stdout, func() error { return nil }, nil
+		return os.Stdout, func() error { return nil }, noopReopen, nil
 	case "file":
-		file, err := openLogFile(serviceName, cfg.FilePath)
+		file, err := newRotatingWriter(logFilePath(serviceName, cfg.FilePath), cfg)
 		if err != nil {
-			return nil, nil, err
+			return nil, nil, nil, err
 		}
-		return file, file.Close, nil
+		return file, file.Close, file.Reopen, nil
 	case "both":
-		file, err := openLogFile(serviceName, cfg.FilePath)
+		file, err := newRotatingWriter(logFilePath(serviceName, cfg.FilePath), cfg)
 		if err != nil {
-			return nil, nil, err
+			return nil, nil, nil, err
 		}
 		multi := io.MultiWriter(os.Stdout, file)
-		return multi, file.Close, nil
+		return multi, file.Close, file.Reopen, nil
 	default:
-		return nil, nil, fmt.Errorf("invalid log output %q (expected console|file|both)", cfg.Output)
+		return nil, nil, nil, fmt.Errorf("invalid log output %q (expected console|file|both)", cfg.Output)
 	}
 }
 
-func openLogFile(serviceName string, configuredPath string) (*os.File, error) {
+func logFilePath(serviceName string, configuredPath string) string {
 	logPath := strings.TrimSpace(configuredPath)
 	if logPath == "" {
 		logPath = filepath.Join(".docker", serviceName, serviceName+".log")
 	}
-
-	if err := os.MkdirAll(filepath.Dir(logPath), 0o755); err != nil {
-		return nil, fmt.Errorf("create log directory for %q: %w", logPath, err)
-	}
-
-	file, err := os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
-	if err != nil {
-		return nil, fmt.Errorf("open log file %q: %w", logPath, err)
-	}
-	return file, nil
+	return logPath
 }
 
 func parseLevel(raw string) (slog.Level, error) {
@@ -0,0 +1,218 @@
+package logging
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coldbell/dex/backend/internal/config"
+)
+
+// rotatingWriter is an io.WriteCloser over a single log file that rotates
+// itself once the file exceeds MaxSizeMB, pruning backups past MaxBackups
+// or older than MaxAgeDays, and optionally gzipping the rotated-out file.
+// It also supports Reopen, for picking up a rename external logrotate
+// tooling already performed (e.g. on SIGHUP).
+type rotatingWriter struct {
+	path       string
+	maxSizeMB  int
+	maxBackups int
+	maxAgeDays int
+	compress   bool
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+	wg   sync.WaitGroup
+}
+
+func newRotatingWriter(path string, cfg config.LogConfig) (*rotatingWriter, error) {
+	file, size, err := openForAppend(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rotatingWriter{
+		path:       path,
+		maxSizeMB:  cfg.MaxSizeMB,
+		maxBackups: cfg.MaxBackups,
+		maxAgeDays: cfg.MaxAgeDays,
+		compress:   cfg.Compress,
+		file:       file,
+		size:       size,
+	}, nil
+}
+
+func openForAppend(path string) (*os.File, int64, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, 0, fmt.Errorf("create log directory for %q: %w", path, err)
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, 0, fmt.Errorf("open log file %q: %w", path, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, 0, fmt.Errorf("stat log file %q: %w", path, err)
+	}
+	return file, info.Size(), nil
+}
+
+func (w *rotatingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.maxSizeMB > 0 && w.size > 0 && w.size+int64(len(p)) > int64(w.maxSizeMB)<<20 {
+		if err := w.rotateLocked(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// rotateLocked renames the current file aside, reopens path fresh, and
+// kicks off compression and backup pruning. Callers must hold w.mu.
+func (w *rotatingWriter) rotateLocked() error {
+	if err := w.file.Close(); err != nil {
+		return fmt.Errorf("close log file %q before rotating: %w", w.path, err)
+	}
+
+	ext := filepath.Ext(w.path)
+	base := strings.TrimSuffix(w.path, ext)
+	rotatedPath := fmt.Sprintf("%s-%s%s", base, time.Now().UTC().Format("20060102T150405"), ext)
+	if err := os.Rename(w.path, rotatedPath); err != nil {
+		return fmt.Errorf("rotate log file %q: %w", w.path, err)
+	}
+
+	file, _, err := openForAppend(w.path)
+	if err != nil {
+		return err
+	}
+	w.file = file
+	w.size = 0
+
+	if w.compress {
+		w.wg.Add(1)
+		go func() {
+			defer w.wg.Done()
+			compressAndRemove(rotatedPath)
+		}()
+	}
+
+	pruneBackups(base, ext, w.maxBackups, w.maxAgeDays)
+	return nil
+}
+
+// Reopen closes and reopens the writer's file at its configured path,
+// without renaming it aside first. It's for SIGHUP: external logrotate
+// tooling has already moved the old file out from under us, so all we
+// need to do is start writing to a fresh file at the same path.
+func (w *rotatingWriter) Reopen() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.file.Close(); err != nil {
+		return fmt.Errorf("close log file %q before reopening: %w", w.path, err)
+	}
+	file, size, err := openForAppend(w.path)
+	if err != nil {
+		return err
+	}
+	w.file = file
+	w.size = size
+	return nil
+}
+
+// Close waits for any in-flight compression goroutines to finish, then
+// closes the current file.
+func (w *rotatingWriter) Close() error {
+	w.wg.Wait()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Close()
+}
+
+func compressAndRemove(path string) {
+	src, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return
+	}
+	defer dst.Close()
+
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		_ = gz.Close()
+		return
+	}
+	if err := gz.Close(); err != nil {
+		return
+	}
+	_ = os.Remove(path)
+}
+
+// pruneBackups deletes rotated files named "base-*ext" or "base-*ext.gz"
+// beyond maxBackups (newest first) or older than maxAgeDays, whichever
+// limit is configured (zero disables that limit).
+func pruneBackups(base, ext string, maxBackups, maxAgeDays int) {
+	if maxBackups <= 0 && maxAgeDays <= 0 {
+		return
+	}
+
+	dir := filepath.Dir(base)
+	prefix := filepath.Base(base) + "-"
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	type backup struct {
+		path    string
+		modTime time.Time
+	}
+	var backups []backup
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if !strings.HasSuffix(name, ext) && !strings.HasSuffix(name, ext+".gz") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backup{path: filepath.Join(dir, name), modTime: info.ModTime()})
+	}
+
+	sort.Slice(backups, func(i, j int) bool { return backups[i].modTime.After(backups[j].modTime) })
+
+	cutoff := time.Now().Add(-time.Duration(maxAgeDays) * 24 * time.Hour)
+	for i, b := range backups {
+		tooMany := maxBackups > 0 && i >= maxBackups
+		tooOld := maxAgeDays > 0 && b.modTime.Before(cutoff)
+		if tooMany || tooOld {
+			_ = os.Remove(b.path)
+		}
+	}
+}
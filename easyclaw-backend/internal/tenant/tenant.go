@@ -0,0 +1,57 @@
+// Package tenant carries the tenant (namespace) a request or indexer pass
+// is scoped to through context.Context, the same way internal/logging
+// carries a decorated *slog.Logger, so a single deployment can index
+// multiple isolated program instances/clusters/cohorts without threading
+// an extra parameter through every call site.
+package tenant
+
+import (
+	"context"
+	"errors"
+)
+
+// Default is the tenant ID backfilled onto existing rows and used by any
+// caller that hasn't been made tenant-aware yet (a single-tenant
+// deployment can run unmodified by anchoring ctx to Default once, at its
+// root).
+const Default = "default"
+
+// tenantCtxKey is the context.Context key IntoContext stores the tenant ID
+// under. It's an unexported type so only this package can set or look it
+// up.
+type tenantCtxKey struct{}
+
+// ErrRequired is returned by RequireFromContext when ctx carries no
+// tenant. Callers in strict mode should treat it as a programming error -
+// every entry point into tenant-scoped code is expected to anchor ctx with
+// IntoContext first.
+var ErrRequired = errors.New("tenant: no tenant in context")
+
+// IntoContext returns a copy of ctx scoped to tenantID, replacing whatever
+// tenant ctx already carries.
+func IntoContext(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantCtxKey{}, tenantID)
+}
+
+// FromContext returns the tenant ID ctx was scoped to with IntoContext, or
+// ok=false if ctx carries none.
+func FromContext(ctx context.Context) (tenantID string, ok bool) {
+	tenantID, ok = ctx.Value(tenantCtxKey{}).(string)
+	if !ok || tenantID == "" {
+		return "", false
+	}
+	return tenantID, true
+}
+
+// RequireFromContext is FromContext in strict mode: it returns ErrRequired
+// instead of silently falling back to Default. Store methods that write or
+// read tenant-scoped rows call this rather than FromContext, so a caller
+// that forgot to scope ctx fails loudly instead of cross-contaminating the
+// Default tenant's data.
+func RequireFromContext(ctx context.Context) (string, error) {
+	tenantID, ok := FromContext(ctx)
+	if !ok {
+		return "", ErrRequired
+	}
+	return tenantID, nil
+}